@@ -0,0 +1,333 @@
+// Package stocd runs STOCD scans from Go programs, without the command line or the Slack bot.
+package stocd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcdannyboy/stocd/archive"
+	"github.com/bcdannyboy/stocd/deribit"
+	"github.com/bcdannyboy/stocd/futures"
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/positions"
+	"github.com/bcdannyboy/stocd/progress"
+	"github.com/bcdannyboy/stocd/screener"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	HistoryYears = 10 // Years of daily prices the models are calibrated to
+	activityDays = 5  // Daily chains, including the current scan, used to score contract activity
+
+	bestDirectionTop    = 5                // Screened spreads of each side the best indicator compares
+	bestDirectionBudget = 10 * time.Second // Analytic screening time per side when the indicator is best
+)
+
+// Analyzer fetches market data from Tradier and finds, scores and ranks spreads.
+type Analyzer struct {
+	TradierKey string
+	Signals    []screener.Signal     // Direction signals of the auto indicator, nil for the defaults
+	Archive    *archive.Archive      // Earlier scans used to score contract activity, nil to use the current chain only
+	Models     *positions.ModelCache // Models reused across scans of a symbol, nil to calibrate every scan
+	Futures    futures.Client        // Interactive Brokers gateway futures symbols such as /ES are fetched from
+	Deribit    deribit.Client        // Deribit API BTC-USD and ETH-USD are fetched from
+
+	SymbolWorkers int // Symbols AnalyzeSymbols scans concurrently, 0 for positions.DefaultSymbolWorkers
+}
+
+// NewAnalyzer returns an Analyzer using the Tradier API key, the gateway configured by IBKR_GATEWAY_URL
+// for futures and the Deribit API configured by DERIBIT_URL for coins.
+func NewAnalyzer(tradierKey string) *Analyzer {
+	return &Analyzer{TradierKey: tradierKey, Futures: futures.ClientFromEnv(), Deribit: deribit.ClientFromEnv()}
+}
+
+// AnalyzeRequest describes a scan, like the arguments of /fcs.
+type AnalyzeRequest struct {
+	Symbol string
+	// Indicator chooses what to scan: a number, > 0 for bull put spreads and otherwise bear call
+	// spreads, auto to choose the direction from the signals, best to screen both sides analytically and
+	// keep the better one, both, or a strategy such as csp, strangle or a declared strategy. Empty scans
+	// bull put spreads.
+	Indicator    string
+	MinDTE       int
+	MaxDTE       int
+	MinRoR       float64
+	RiskFreeRate float64
+
+	Options positions.ScanOptions        // Spread constraints and pricing, e.g. from positions.ScanOptionsFromEnv
+	Ranking positions.RankingConstraints // Limits on near-duplicate spreads among the first Top results
+	Top     int                          // Leading results Ranking applies to, 0 for all
+	Limit   int                          // Ranked spreads returned, the best by Ranking.Sort and at least Top; 0 for all
+	Tracker *progress.Tracker            // Reports the scan's progress, nil for none
+	Status  positions.StatusSink         // Receives the steps of the model calibration, nil for none
+	Now     time.Time                    // Time days to expiration are counted from, zero for market.Now()
+
+	Started func(Scan) // Called once the market data is fetched and the spread type chosen, before the spreads are simulated; nil for none
+}
+
+// Scan is a scan about to be simulated.
+type Scan struct {
+	Symbol       string
+	Data         MarketData
+	SpreadType   string
+	Direction    string                // Reason for the spread type when the indicator is auto or best
+	RiskFreeRate float64               // Rate the spreads are simulated with, 0 for futures
+	Options      positions.ScanOptions // Options the spreads are simulated with
+	Now          time.Time
+}
+
+// AnalyzeResult is a completed scan.
+type AnalyzeResult struct {
+	Symbol          string
+	UnderlyingPrice float64
+	SpreadType      string                           // Spread type scanned, Both for bull puts and bear calls together
+	Direction       string                           // Votes of the direction signals when the indicator is auto, the screened scores of both sides when it is best
+	Spreads         []models.SpreadWithProbabilities // Spreads meeting the criteria, best composite score first within the ranking constraints, at most the request's Limit
+	Found           int                              // Spreads meeting the criteria, including those beyond Limit
+}
+
+// SymbolsResult is a completed scan of several symbols.
+type SymbolsResult struct {
+	Results []AnalyzeResult                  // Scans of the symbols that succeeded, in the order requested
+	Failed  map[string]error                 // Error of each symbol whose scan failed
+	Spreads []models.SpreadWithProbabilities // Spreads of every symbol, scored together and ranked like those of a single scan
+	Found   int                              // Spreads meeting the criteria across the symbols, including those beyond Limit
+}
+
+// MarketData is a symbol's daily price history and options chain.
+type MarketData struct {
+	Quotes *tradier.QuoteHistory
+	Chain  map[string]*tradier.OptionChain
+	Price  float64 // Latest close
+}
+
+// MarketData fetches the symbol's last HistoryYears of daily prices and its options chain between
+// minDTE and maxDTE days to expiration. A futures symbol such as /ES is fetched from a.Futures instead:
+// the front contract's history and the options on it, and BTC-USD or ETH-USD from a.Deribit.
+func (a *Analyzer) MarketData(ctx context.Context, symbol string, minDTE, maxDTE int) (MarketData, error) {
+	if deribit.IsSymbol(symbol) {
+		data, err := a.Deribit.MarketData(symbol, minDTE, maxDTE, market.Now())
+		if err != nil {
+			return MarketData{}, fmt.Errorf("failed to fetch deribit market data: %s", err)
+		}
+		return MarketData{Quotes: data.Quotes, Chain: data.Chain, Price: data.Price}, nil
+	}
+	if futures.IsSymbol(symbol) {
+		data, err := a.Futures.MarketData(symbol, minDTE, maxDTE, market.Now())
+		if err != nil {
+			return MarketData{}, fmt.Errorf("failed to fetch futures market data: %s", err)
+		}
+		return MarketData{Quotes: data.Quotes, Chain: data.Chain, Price: data.Price}, nil
+	}
+	quotes, err := tradier.GET_QUOTES(symbol, market.Now().AddDate(-HistoryYears, 0, 0).Format(market.DateLayout), market.Today(), "daily", a.TradierKey)
+	if err != nil {
+		return MarketData{}, fmt.Errorf("failed to fetch quotes: %s", err)
+	}
+	if len(quotes.History.Day) == 0 {
+		return MarketData{}, fmt.Errorf("no price history for %s", symbol)
+	}
+	if err := ctx.Err(); err != nil {
+		return MarketData{}, err
+	}
+	chain, err := tradier.GET_OPTIONS_CHAIN(symbol, a.TradierKey, minDTE, maxDTE)
+	if err != nil {
+		return MarketData{}, fmt.Errorf("failed to fetch options chain: %s", err)
+	}
+	return MarketData{Quotes: quotes, Chain: chain, Price: quotes.History.Day[len(quotes.History.Day)-1].Close}, nil
+}
+
+// Analyze fetches the market data of the request's symbol, calibrates the models and returns the spreads
+// meeting the request's criteria ranked by composite score. The context is checked between stages; a
+// simulation already running is not interrupted.
+func (a *Analyzer) Analyze(ctx context.Context, req AnalyzeRequest) (AnalyzeResult, error) {
+	symbol := strings.ToUpper(req.Symbol)
+	switch {
+	case symbol == "":
+		return AnalyzeResult{}, fmt.Errorf("no symbol")
+	case req.MaxDTE < req.MinDTE:
+		return AnalyzeResult{}, fmt.Errorf("maxDTE (%d) must be at least minDTE (%d)", req.MaxDTE, req.MinDTE)
+	}
+
+	data, err := a.MarketData(ctx, symbol, req.MinDTE, req.MaxDTE)
+	if err != nil {
+		return AnalyzeResult{}, err
+	}
+	result := AnalyzeResult{Symbol: symbol, UnderlyingPrice: data.Price}
+
+	now := req.Now
+	if now.IsZero() {
+		now = market.Now()
+	}
+	riskFreeRate := req.RiskFreeRate
+	if futures.IsSymbol(symbol) {
+		riskFreeRate = 0 // A futures price has no drift under the risk-neutral measure (Black-76); discounting the premium is left out
+	}
+	opts := req.Options.WithIntradayVolatility(symbol, a.TradierKey, now)
+
+	result.SpreadType, result.Direction, err = a.spreadType(req, symbol, data, riskFreeRate, opts, now)
+	if err != nil {
+		return AnalyzeResult{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return AnalyzeResult{}, err
+	}
+	if req.Started != nil {
+		req.Started(Scan{Symbol: symbol, Data: data, SpreadType: result.SpreadType, Direction: result.Direction, RiskFreeRate: riskFreeRate, Options: opts, Now: now})
+	}
+
+	if a.Models != nil && opts.Models == nil {
+		req.Tracker.SetStage(positions.StageCalibrating, 0)
+		globalModels := a.Models.Models(symbol, data.Chain, data.Price, riskFreeRate, *data.Quotes, now, req.Status)
+		opts.Models = &globalModels
+	}
+	spreads := positions.IdentifySpreads(data.Chain, data.Price, riskFreeRate, *data.Quotes, req.MinRoR, now, result.SpreadType, opts, req.Tracker, req.Status)
+	if err := ctx.Err(); err != nil {
+		return AnalyzeResult{}, err
+	}
+
+	positions.AnnotateActivity(spreads, a.activityHistory(symbol))
+	positions.ScoreSpreads(spreads)
+	result.Found = len(spreads)
+	result.Spreads = positions.RankSpreads(spreads, req.Top, req.Limit, req.Ranking)
+	return result, nil
+}
+
+// AnalyzeSymbols runs the request for each symbol, at most SymbolWorkers at a time, then scores the
+// spreads of all the symbols together, so their composite scores compare across symbols, and ranks them
+// as Analyze does; with a Limit, the best of each symbol are ranked together. The request's Symbol and
+// Tracker are ignored; track, when not nil, returns the tracker of a symbol's scan, which is finished
+// when the scan ends. It fails only when every symbol fails.
+func (a *Analyzer) AnalyzeSymbols(ctx context.Context, symbols []string, req AnalyzeRequest, track func(symbol string) *progress.Tracker) (SymbolsResult, error) {
+	workers := a.SymbolWorkers
+	if workers <= 0 {
+		workers = positions.DefaultSymbolWorkers
+	}
+
+	results := make([]AnalyzeResult, len(symbols))
+	errs := make([]error, len(symbols))
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, workers)
+	for i, symbol := range symbols {
+		wg.Add(1)
+		go func(i int, symbol string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			symbolReq := req
+			symbolReq.Symbol = symbol
+			symbolReq.Tracker = nil
+			if track != nil {
+				symbolReq.Tracker = track(symbol)
+				defer symbolReq.Tracker.Finish()
+			}
+			results[i], errs[i] = a.Analyze(ctx, symbolReq)
+		}(i, symbol)
+	}
+	wg.Wait()
+
+	combined := SymbolsResult{Failed: make(map[string]error)}
+	for i, symbol := range symbols {
+		if errs[i] != nil {
+			combined.Failed[strings.ToUpper(symbol)] = errs[i]
+			continue
+		}
+		combined.Results = append(combined.Results, results[i])
+		combined.Spreads = append(combined.Spreads, results[i].Spreads...)
+		combined.Found += results[i].Found
+	}
+	if err := ctx.Err(); err != nil {
+		return SymbolsResult{}, err
+	}
+	switch {
+	case len(symbols) == 1 && errs[0] != nil:
+		return combined, errs[0]
+	case len(symbols) > 0 && len(combined.Results) == 0:
+		return combined, fmt.Errorf("failed to scan any of %d symbols, the first: %s", len(symbols), errs[0])
+	}
+
+	positions.ScoreSpreads(combined.Spreads)
+	combined.Spreads = positions.RankSpreads(combined.Spreads, req.Top, req.Limit, req.Ranking)
+	return combined, nil
+}
+
+// spreadType resolves the request's indicator to the spread type to scan, with the direction signals'
+// votes when it is auto and the screened scores of both sides when it is best.
+func (a *Analyzer) spreadType(req AnalyzeRequest, symbol string, data MarketData, riskFreeRate float64, opts positions.ScanOptions, now time.Time) (string, string, error) {
+	indicator := strings.ToLower(strings.TrimSpace(req.Indicator))
+	switch indicator {
+	case "":
+		return "Bull Put", "", nil
+	case "both":
+		return "Both", "", nil
+	case "auto":
+		signals := a.Signals
+		if signals == nil {
+			var err error
+			if signals, err = screener.ParseSignals(""); err != nil {
+				return "", "", err
+			}
+		}
+		closes := make([]float64, len(data.Quotes.History.Day))
+		for i, day := range data.Quotes.History.Day {
+			closes[i] = day.Close
+		}
+		direction := screener.ChooseDirection(screener.Input{Symbol: symbol, Price: data.Price, Chain: data.Chain, Closes: closes}, signals)
+		return direction.SpreadType(), direction.Describe(), nil
+	case "best":
+		bullPut := screenedScore(positions.QuickScreen(data.Chain, data.Price, riskFreeRate, req.MinRoR, now, "Bull Put", opts, bestDirectionTop, bestDirectionBudget))
+		bearCall := screenedScore(positions.QuickScreen(data.Chain, data.Price, riskFreeRate, req.MinRoR, now, "Bear Call", opts, bestDirectionTop, bestDirectionBudget))
+		spreadType := "Bull Put"
+		if bearCall > bullPut {
+			spreadType = "Bear Call"
+		}
+		return spreadType, fmt.Sprintf("%s (top %d analytic expected value per dollar at risk: Bull Put %.3f, Bear Call %.3f)", spreadType, bestDirectionTop, bullPut, bearCall), nil
+	}
+
+	if spreadType, ok := positions.StrategyIndicator(indicator); ok {
+		return spreadType, "", nil
+	}
+	value, err := strconv.ParseFloat(indicator, 64)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid indicator %q: expected a number, auto, best, both or one of %s", indicator, strings.Join(positions.StrategyIndicatorNames(), ", "))
+	}
+	if value > 0 {
+		return "Bull Put", "", nil
+	}
+	return "Bear Call", "", nil
+}
+
+// screenedScore averages the expected value per dollar at risk of screened spreads, -1 (a total loss)
+// when there are none so that a side without candidates loses.
+func screenedScore(spreads []models.SpreadWithProbabilities) float64 {
+	if len(spreads) == 0 {
+		return -1
+	}
+	total := 0.0
+	for _, spread := range spreads {
+		total += spread.CompositeScore
+	}
+	return total / float64(len(spreads))
+}
+
+// activityHistory loads the archived chains of the days before today used to score contract activity.
+func (a *Analyzer) activityHistory(symbol string) []map[string]*tradier.OptionChain {
+	if a.Archive == nil {
+		return nil
+	}
+	snapshots, err := a.Archive.Chains(symbol, activityDays-1, time.Now())
+	if err != nil {
+		log.Printf("Error loading archived chains for %s: %v", symbol, err)
+	}
+	var history []map[string]*tradier.OptionChain
+	for _, snapshot := range snapshots {
+		history = append(history, snapshot.Chain)
+	}
+	return history
+}
@@ -0,0 +1,161 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	manifestFile  = "manifest.json"
+	dirTimeLayout = "20060102_150405" // Timestamp suffix of each scan directory
+)
+
+// FileDigest is the SHA-256 of one archived artifact.
+type FileDigest struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest lists the artifacts of an archived scan. When the archive is signed the
+// signature covers the manifest with the Signature field left empty, so any change
+// to an artifact or to the manifest itself invalidates it.
+type Manifest struct {
+	Symbol    string       `json:"symbol"`
+	CreatedAt time.Time    `json:"created_at"`
+	Files     []FileDigest `json:"files"`
+	Algorithm string       `json:"algorithm,omitempty"`
+	Signature string       `json:"signature,omitempty"`
+}
+
+// Archive stores scan outputs together with the market data they were computed from.
+type Archive struct {
+	dir    string
+	signer Signer
+}
+
+// New returns an archive rooted at dir. signer may be nil to archive without signing.
+func New(dir string, signer Signer) *Archive {
+	return &Archive{dir: dir, signer: signer}
+}
+
+// Save writes each artifact as <name>.json in a new directory for the scan, followed by
+// a manifest of their digests, and returns the directory.
+func (a *Archive) Save(symbol string, artifacts map[string]interface{}) (string, error) {
+	createdAt := time.Now()
+	dir := filepath.Join(a.dir, fmt.Sprintf("%s_%s", symbol, createdAt.Format(dirTimeLayout)))
+	createdAt = createdAt.UTC() // Keep the manifest's timestamp stable across marshalling
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %s", err)
+	}
+
+	names := make([]string, 0, len(artifacts))
+	for name := range artifacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manifest := Manifest{Symbol: symbol, CreatedAt: createdAt}
+	for _, name := range names {
+		data, err := json.Marshal(artifacts[name])
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal %s: %s", name, err)
+		}
+
+		fileName := name + ".json"
+		if err := os.WriteFile(filepath.Join(dir, fileName), data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %s", fileName, err)
+		}
+
+		sum := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, FileDigest{Name: fileName, SHA256: hex.EncodeToString(sum[:])})
+	}
+
+	if a.signer != nil {
+		manifest.Algorithm = a.signer.Algorithm()
+		payload, err := signingPayload(manifest)
+		if err != nil {
+			return "", err
+		}
+		signature, err := a.signer.Sign(payload)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign manifest: %s", err)
+		}
+		manifest.Signature = base64.StdEncoding.EncodeToString(signature)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal manifest: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write manifest: %s", err)
+	}
+
+	return dir, nil
+}
+
+// Verify checks every artifact in an archived scan directory against its manifest and,
+// if verifier is not nil, the manifest signature.
+func Verify(dir string, verifier Verifier) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %s", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %s", err)
+	}
+
+	for _, file := range manifest.Files {
+		content, err := os.ReadFile(filepath.Join(dir, file.Name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %s", file.Name, err)
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != file.SHA256 {
+			return nil, fmt.Errorf("%s has been modified: digest does not match manifest", file.Name)
+		}
+	}
+
+	if verifier == nil {
+		return &manifest, nil
+	}
+
+	if manifest.Signature == "" {
+		return nil, fmt.Errorf("archive is not signed")
+	}
+	if manifest.Algorithm != verifier.Algorithm() {
+		return nil, fmt.Errorf("archive is signed with %s, not %s", manifest.Algorithm, verifier.Algorithm())
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(manifest.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %s", err)
+	}
+	payload, err := signingPayload(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifier.Verify(payload, signature); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+func signingPayload(manifest Manifest) ([]byte, error) {
+	manifest.Signature = ""
+	payload, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %s", err)
+	}
+	return payload, nil
+}
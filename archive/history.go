@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// ChainSnapshot is the options chain archived with an earlier scan.
+type ChainSnapshot struct {
+	ScannedAt time.Time
+	Chain     map[string]*tradier.OptionChain
+}
+
+// Chains returns the archived chains of symbol from up to days calendar days before the day of
+// before, newest first, keeping only the last scan of each day. Scans without a chain are skipped.
+func (a *Archive) Chains(symbol string, days int, before time.Time) ([]ChainSnapshot, error) {
+	entries, err := os.ReadDir(a.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %s", err)
+	}
+
+	type scanDir struct {
+		name      string
+		scannedAt time.Time
+	}
+	var scans []scanDir
+	prefix := symbol + "_"
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		scannedAt, err := time.ParseInLocation(dirTimeLayout, strings.TrimPrefix(entry.Name(), prefix), time.Local)
+		if err != nil {
+			continue
+		}
+		scans = append(scans, scanDir{name: entry.Name(), scannedAt: scannedAt})
+	}
+	sort.Slice(scans, func(i, j int) bool {
+		return scans[i].scannedAt.After(scans[j].scannedAt)
+	})
+
+	var snapshots []ChainSnapshot
+	seen := map[string]bool{before.Format("20060102"): true}
+	for _, scan := range scans {
+		if len(snapshots) >= days {
+			break
+		}
+		day := scan.scannedAt.Format("20060102")
+		if seen[day] || scan.scannedAt.After(before) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(a.dir, scan.name, "chain.json"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archived chain: %s", err)
+		}
+		var chain map[string]*tradier.OptionChain
+		if err := json.Unmarshal(data, &chain); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal archived chain %s: %s", scan.name, err)
+		}
+
+		seen[day] = true
+		snapshots = append(snapshots, ChainSnapshot{ScannedAt: scan.scannedAt, Chain: chain})
+	}
+
+	return snapshots, nil
+}
@@ -0,0 +1,144 @@
+package archive
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Signature algorithms recorded in archive manifests.
+const (
+	AlgorithmHMAC    = "hmac-sha256"
+	AlgorithmEd25519 = "ed25519"
+)
+
+// Signer signs archive manifests.
+type Signer interface {
+	Algorithm() string
+	Sign(data []byte) ([]byte, error)
+}
+
+// Verifier checks manifest signatures produced by a Signer.
+type Verifier interface {
+	Algorithm() string
+	Verify(data, signature []byte) error
+}
+
+// HMACSigner signs and verifies with a shared secret.
+type HMACSigner struct {
+	key []byte
+}
+
+func NewHMACSigner(key []byte) *HMACSigner {
+	return &HMACSigner{key: key}
+}
+
+func (s *HMACSigner) Algorithm() string {
+	return AlgorithmHMAC
+}
+
+func (s *HMACSigner) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+func (s *HMACSigner) Verify(data, signature []byte) error {
+	expected, _ := s.Sign(data)
+	if !hmac.Equal(expected, signature) {
+		return fmt.Errorf("HMAC signature mismatch")
+	}
+	return nil
+}
+
+// Ed25519Signer signs with a private key so recipients only need the public key to verify.
+type Ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+func NewEd25519Signer(key ed25519.PrivateKey) *Ed25519Signer {
+	return &Ed25519Signer{key: key}
+}
+
+func (s *Ed25519Signer) Algorithm() string {
+	return AlgorithmEd25519
+}
+
+func (s *Ed25519Signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, data), nil
+}
+
+// Ed25519Verifier verifies signatures made by an Ed25519Signer.
+type Ed25519Verifier struct {
+	key ed25519.PublicKey
+}
+
+func NewEd25519Verifier(key ed25519.PublicKey) *Ed25519Verifier {
+	return &Ed25519Verifier{key: key}
+}
+
+func (v *Ed25519Verifier) Algorithm() string {
+	return AlgorithmEd25519
+}
+
+func (v *Ed25519Verifier) Verify(data, signature []byte) error {
+	if !ed25519.Verify(v.key, data, signature) {
+		return fmt.Errorf("ed25519 signature mismatch")
+	}
+	return nil
+}
+
+// ParseSigningKey builds a Signer from "hmac:<secret>" or "ed25519:<base64 private key or seed>".
+func ParseSigningKey(spec string) (Signer, error) {
+	kind, value, ok := strings.Cut(spec, ":")
+	if !ok || value == "" {
+		return nil, fmt.Errorf("invalid signing key: expected hmac:<secret> or ed25519:<base64 key>")
+	}
+
+	switch strings.ToLower(kind) {
+	case "hmac":
+		return NewHMACSigner([]byte(value)), nil
+	case "ed25519":
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode ed25519 key: %s", err)
+		}
+		switch len(key) {
+		case ed25519.SeedSize:
+			return NewEd25519Signer(ed25519.NewKeyFromSeed(key)), nil
+		case ed25519.PrivateKeySize:
+			return NewEd25519Signer(ed25519.PrivateKey(key)), nil
+		default:
+			return nil, fmt.Errorf("invalid ed25519 private key length %d", len(key))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", kind)
+	}
+}
+
+// ParseVerifyKey builds a Verifier from "hmac:<secret>" or "ed25519:<base64 public key>".
+func ParseVerifyKey(spec string) (Verifier, error) {
+	kind, value, ok := strings.Cut(spec, ":")
+	if !ok || value == "" {
+		return nil, fmt.Errorf("invalid verification key: expected hmac:<secret> or ed25519:<base64 public key>")
+	}
+
+	switch strings.ToLower(kind) {
+	case "hmac":
+		return NewHMACSigner([]byte(value)), nil
+	case "ed25519":
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode ed25519 key: %s", err)
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key length %d", len(key))
+		}
+		return NewEd25519Verifier(ed25519.PublicKey(key)), nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", kind)
+	}
+}
@@ -0,0 +1,135 @@
+package calibration
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bcdannyboy/stocd/paper"
+)
+
+const (
+	DefaultBins = 10
+	barWidth    = 20 // Characters of the reliability diagram's bars at 100%
+)
+
+// Outcome is a predicted probability of profit and whether the position was profitable.
+type Outcome struct {
+	Predicted float64
+	Won       bool
+}
+
+// FromPaper returns the outcomes of the closed paper trades.
+func FromPaper(trades []paper.Trade) []Outcome {
+	var outcomes []Outcome
+	for _, trade := range trades {
+		if trade.Closed {
+			outcomes = append(outcomes, Outcome{Predicted: trade.PoP, Won: trade.RealizedPnL() > 0})
+		}
+	}
+	return outcomes
+}
+
+// Bin is one bucket of the calibration curve: the outcomes whose prediction fell in [Lower, Upper).
+type Bin struct {
+	Lower         float64
+	Upper         float64
+	Count         int
+	MeanPredicted float64
+	Realized      float64 // Fraction of the bin's outcomes that were profitable
+}
+
+// Curve is the calibration curve of a set of outcomes with its summary scores.
+type Curve struct {
+	Bins          []Bin // Non-empty bins in ascending order of prediction
+	Count         int
+	MeanPredicted float64
+	WinRate       float64
+	Brier         float64 // Mean squared error of the predictions
+	BaseBrier     float64 // Brier score of always predicting the realized win rate, for reference
+}
+
+// Compute buckets the outcomes into bins equal-width probability bins and scores the predictions.
+func Compute(outcomes []Outcome, bins int) Curve {
+	if bins < 1 {
+		bins = DefaultBins
+	}
+	buckets := make([]Bin, bins)
+	for i := range buckets {
+		buckets[i].Lower = float64(i) / float64(bins)
+		buckets[i].Upper = float64(i+1) / float64(bins)
+	}
+
+	var curve Curve
+	for _, outcome := range outcomes {
+		won := 0.0
+		if outcome.Won {
+			won = 1
+		}
+		p := math.Min(math.Max(outcome.Predicted, 0), 1)
+		b := &buckets[min(int(p*float64(bins)), bins-1)]
+		b.Count++
+		b.MeanPredicted += p
+		b.Realized += won
+
+		curve.Count++
+		curve.MeanPredicted += p
+		curve.WinRate += won
+		curve.Brier += (p - won) * (p - won)
+	}
+	if curve.Count == 0 {
+		return curve
+	}
+
+	n := float64(curve.Count)
+	curve.MeanPredicted /= n
+	curve.WinRate /= n
+	curve.Brier /= n
+	curve.BaseBrier = curve.WinRate * (1 - curve.WinRate)
+	for _, b := range buckets {
+		if b.Count > 0 {
+			b.MeanPredicted /= float64(b.Count)
+			b.Realized /= float64(b.Count)
+			curve.Bins = append(curve.Bins, b)
+		}
+	}
+	return curve
+}
+
+// Verdict compares the mean prediction with the win rate, calling the model over- or under-confident
+// only when they differ by more than two binomial standard errors.
+func (c Curve) Verdict() string {
+	if c.Count == 0 {
+		return "no outcomes yet"
+	}
+	se := math.Sqrt(c.MeanPredicted * (1 - c.MeanPredicted) / float64(c.Count))
+	diff := c.MeanPredicted - c.WinRate
+	switch {
+	case se > 0 && diff > 2*se:
+		return "over-confident"
+	case se > 0 && diff < -2*se:
+		return "under-confident"
+	}
+	return "consistent with the outcomes"
+}
+
+// Describe renders the curve as a text reliability diagram, one line per bin with bars for the predicted
+// and realized rates, followed by the scores.
+func (c Curve) Describe() string {
+	if c.Count == 0 {
+		return "No closed positions to calibrate against yet"
+	}
+	var msg strings.Builder
+	msg.WriteString("Calibration (predicted PoP vs realized win rate):\n")
+	for _, b := range c.Bins {
+		msg.WriteString(fmt.Sprintf("  %3.0f-%3.0f%%: %4d, predicted %5.1f%% %-*s realized %5.1f%% %s\n",
+			b.Lower*100, b.Upper*100, b.Count, b.MeanPredicted*100, barWidth, bar(b.MeanPredicted), b.Realized*100, bar(b.Realized)))
+	}
+	msg.WriteString(fmt.Sprintf("%d outcomes: mean predicted %.1f%%, realized %.1f%%, Brier %.4f (%.4f predicting the win rate); the model is %s\n",
+		c.Count, c.MeanPredicted*100, c.WinRate*100, c.Brier, c.BaseBrier, c.Verdict()))
+	return msg.String()
+}
+
+func bar(p float64) string {
+	return strings.Repeat("#", int(math.Round(p*barWidth)))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bcdannyboy/stocd"
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/positions"
+	"github.com/bcdannyboy/stocd/tradiertest"
+)
+
+// runBench benchmarks the scan's throughput under each concurrency configuration.
+func runBench(fs *flag.FlagSet, args []string) error {
+	synthetic := fs.Bool("synthetic", false, "benchmark a generated market instead of fetching SYMBOL's, with no network or Tradier key")
+	expirations := fs.Int("synthetic-expirations", 4, "weekly expirations of the generated market")
+	minDTE := fs.Int("min-dte", 14, "minimum days to expiration (setting MIN_DTE)")
+	maxDTE := fs.Int("max-dte", 45, "maximum days to expiration (setting MAX_DTE)")
+	rfr := fs.Float64("rfr", 0.04, "annual risk-free rate (setting RISK_FREE_RATE)")
+	candidates := fs.Int("candidates", 64, "screened candidates simulated per configuration")
+	symbols := parseArgs(fs, args, 0, 1)
+	useSettingDefault(fs, "min-dte", "MIN_DTE")
+	useSettingDefault(fs, "max-dte", "MAX_DTE")
+	useSettingDefault(fs, "rfr", "RISK_FREE_RATE")
+	if err := configureSimulation(); err != nil {
+		return err
+	}
+
+	var data positions.SymbolData
+	switch {
+	case *synthetic:
+		data = syntheticMarketData(*expirations)
+	case len(symbols) == 1:
+		symbol := strings.ToUpper(symbols[0])
+		fetched, err := stocd.NewAnalyzer(os.Getenv("TRADIER_KEY")).MarketData(context.Background(), symbol, *minDTE, *maxDTE)
+		if err != nil {
+			return err
+		}
+		data = positions.SymbolData{Symbol: symbol, Chain: fetched.Chain, UnderlyingPrice: fetched.Price, History: *fetched.Quotes}
+	default:
+		return fmt.Errorf("benchmarking needs a SYMBOL or -synthetic")
+	}
+
+	opts := positions.ScanOptionsFromEnv()
+	opts.SimulationCandidates = *candidates
+	results := positions.BenchmarkConcurrency(data, *rfr, 0, market.Now(), "Bull Put", opts, positions.DefaultConcurrencyConfigs())
+
+	fmt.Printf("\n%s at %.2f, %d candidates per configuration\n", data.Symbol, data.UnderlyingPrice, *candidates)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Configuration\tWorkers\tPer spread\tIn flight\tSpreads\tTime\tSpreads/sec\tSpeedup")
+	for _, result := range results {
+		speedup := 0.0
+		if results[0].Throughput > 0 {
+			speedup = result.Throughput / results[0].Throughput
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\t%.2f\t%.2fx\n", result.Config.Name, result.Config.Workers, result.Config.SimulationConcurrency,
+			result.Config.SimulationBudget, result.Spreads, result.Elapsed.Round(time.Millisecond), result.Throughput, speedup)
+	}
+	return w.Flush()
+}
+
+// syntheticMarketData generates a market to benchmark without the network, of a $100 underlying with a
+// 30% volatility.
+func syntheticMarketData(expirations int) positions.SymbolData {
+	chain, price, history := tradiertest.SyntheticMarket("SYNTH", 0.3, expirations)
+	return positions.SymbolData{Symbol: "SYNTH", Chain: chain, UnderlyingPrice: price, History: history}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	stocdconfig "github.com/bcdannyboy/stocd/config"
+	"github.com/bcdannyboy/stocd/execution"
+	"github.com/bcdannyboy/stocd/metrics"
+	"github.com/bcdannyboy/stocd/paper"
+	"github.com/bcdannyboy/stocd/positions"
+	"github.com/bcdannyboy/stocd/probability"
+	"github.com/bcdannyboy/stocd/tradier"
+	"github.com/joho/godotenv"
+)
+
+// command is a stocd subcommand. run defines the command's flags on fs, parses args and runs it.
+type command struct {
+	name    string
+	args    string // Positional arguments shown in the usage line
+	summary string
+	run     func(fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{name: "serve", summary: "run the Slack bot, with scheduled scans and position monitoring", run: runServe},
+	{name: "scan", args: "SYMBOL[,SYMBOL...]...", summary: "find and rank spreads for one or more symbols and print them", run: runScan},
+	{name: "screen", args: "SYMBOL...", summary: "rank symbols by their option premium and liquidity", run: runScreen},
+	{name: "calibrate", args: "SYMBOL", summary: "calibrate the Heston, Merton, Kou and CGMY models to a symbol and print them", run: runCalibrate},
+	{name: "cone", args: "SYMBOL", summary: "print a symbol's realized volatility cones and where its implied volatility sits within them", run: runCone},
+	{name: "backtest", summary: "settle and mark the paper trades, reporting their P&L and probability calibration", run: runBacktest},
+	{name: "hedge", summary: "aggregate the greeks of the open positions and suggest SPY or QQQ trades that flatten their delta and vega", run: runHedge},
+	{name: "order", args: "TICKET", summary: "preview a result's ticket JSON (or @file) as a brokerage order and place it once confirmed", run: runOrder},
+	{name: "bench", args: "[SYMBOL]", summary: "benchmark scan throughput with the simulations bounded per spread and globally", run: runBench},
+	{name: "convert", args: "FILE", summary: "convert a legacy JSON export, such as jspreads.json, to the current schema", run: runConvert},
+	{name: "verify", args: "DIR", summary: "verify an archived scan directory", run: runVerify},
+}
+
+func main() {
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			printUsage()
+			return
+		}
+		if !strings.HasPrefix(args[0], "-") {
+			name, args = args[0], args[1:]
+		}
+	}
+
+	cmd, ok := lookupCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		printUsage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("stocd "+cmd.name, flag.ExitOnError)
+	fs.String("config", "", "TOML configuration file, overridden by the environment and .env (default $STOCD_CONFIG, or stocd.toml if present)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: stocd %s [flags] %s\n\n%s.\n\nFlags:\n", cmd.name, cmd.args, strings.ToUpper(cmd.summary[:1])+cmd.summary[1:])
+		fs.PrintDefaults()
+	}
+	if err := cmd.run(fs, args); err != nil {
+		log.Fatalf("%s: %v", cmd.name, err)
+	}
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: stocd <command> [flags] [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nWithout a command stocd serves the Slack bot. Run stocd <command> -h for a command's flags.\n")
+}
+
+// parseArgs parses the command's flags and loads the settings, exiting with the command's usage unless
+// it is given between min and max positional arguments (max < 0 for no limit).
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) []string {
+	fs.Parse(args)
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	loadSettings(fs.Lookup("config").Value.String())
+	return fs.Args()
+}
+
+// loadSettings loads .env and the configuration file into the environment. The environment overrides
+// .env, which overrides the configuration file; .env is optional when there is a configuration file.
+func loadSettings(configPath string) {
+	envErr := godotenv.Load()
+	if configPath == "" {
+		configPath = os.Getenv("STOCD_CONFIG")
+	}
+	if _, err := os.Stat(stocdconfig.DefaultPath); configPath == "" && err == nil {
+		configPath = stocdconfig.DefaultPath
+	}
+	if configPath != "" {
+		if err := stocdconfig.Load(configPath); err != nil {
+			log.Fatalf("Error loading config file: %v", err)
+		}
+		log.Printf("Loaded configuration from %s", configPath)
+	} else if envErr != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	client, err := tradier.ClientFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring Tradier: %v", err)
+	}
+	if client.BaseURL == tradier.SandboxBaseURL {
+		log.Printf("Using the Tradier sandbox")
+	}
+	tradier.SetClient(client)
+}
+
+// useSettingDefault gives the flag the value of the environment variable when it is not set on the
+// command line.
+func useSettingDefault(fs *flag.FlagSet, name, env string) {
+	value := os.Getenv(env)
+	if value == "" {
+		return
+	}
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	if !set {
+		if err := fs.Set(name, value); err != nil {
+			log.Fatalf("Invalid %s %q: %v", env, value, err)
+		}
+	}
+}
+
+// configureSimulation applies the strategy and simulation settings shared by every command that scans.
+func configureSimulation() error {
+	strategies, err := positions.ParseStrategies(os.Getenv("STRATEGIES"))
+	if err != nil {
+		return fmt.Errorf("invalid STRATEGIES: %s", err)
+	}
+	positions.RegisterStrategies(strategies)
+
+	reduction, err := probability.ParseVarianceReduction(os.Getenv("VARIANCE_REDUCTION"))
+	if err != nil {
+		return fmt.Errorf("invalid VARIANCE_REDUCTION: %s", err)
+	}
+	probability.SetVarianceReduction(reduction)
+
+	ensemble, err := probability.ParseEnsemble(os.Getenv("SIMULATION_ENSEMBLE"), os.Getenv("SIMULATION_VOLS"), os.Getenv("SIMULATION_MODELS"))
+	if err != nil {
+		return fmt.Errorf("invalid simulation ensemble: %s", err)
+	}
+	probability.SetEnsemble(ensemble)
+
+	backend, err := probability.ParseBackend(os.Getenv("SIMULATION_BACKEND"))
+	if err != nil {
+		return fmt.Errorf("invalid SIMULATION_BACKEND: %s", err)
+	}
+	probability.SetSimulationBackend(backend)
+
+	cacheTTL := probability.DefaultSimulationCacheTTL
+	if spec := os.Getenv("SIMULATION_CACHE_TTL"); spec != "" {
+		if cacheTTL, err = time.ParseDuration(spec); err != nil {
+			return fmt.Errorf("invalid SIMULATION_CACHE_TTL: %s", err)
+		}
+	}
+	cacheSize := probability.DefaultSimulationCacheSize
+	if spec := os.Getenv("SIMULATION_CACHE_SIZE"); spec != "" {
+		if cacheSize, err = strconv.Atoi(spec); err != nil || cacheSize <= 0 {
+			return fmt.Errorf("invalid SIMULATION_CACHE_SIZE %q (expected a positive number of spreads)", spec)
+		}
+	}
+	probability.SetSimulationCache(cacheTTL, cacheSize)
+
+	var concurrency, budget int
+	if spec := os.Getenv("SIMULATION_CONCURRENCY"); spec != "" {
+		if concurrency, err = strconv.Atoi(spec); err != nil || concurrency < 0 {
+			return fmt.Errorf("invalid SIMULATION_CONCURRENCY %q (expected a number of simulations, 0 to autotune)", spec)
+		}
+	}
+	if spec := os.Getenv("SIMULATION_BUDGET"); spec != "" {
+		if budget, err = strconv.Atoi(spec); err != nil || budget < 0 {
+			return fmt.Errorf("invalid SIMULATION_BUDGET %q (expected a number of simulations, 0 to autotune)", spec)
+		}
+	}
+	positions.ConfigureSimulations(concurrency, budget)
+
+	switch weighting := os.Getenv("PROBABILITY_WEIGHTING"); weighting {
+	case "", "weighted":
+	case "equal":
+		probability.SetWeightedAveraging(false)
+	default:
+		return fmt.Errorf("invalid PROBABILITY_WEIGHTING %q (expected weighted or equal)", weighting)
+	}
+
+	levels, err := probability.ParseRiskLevels(os.Getenv("RISK_LEVELS"))
+	if err != nil {
+		return fmt.Errorf("invalid RISK_LEVELS: %s", err)
+	}
+	if spec := os.Getenv("SCORE_RISK_LEVEL"); spec != "" {
+		level, err := probability.ParseRiskLevel(spec)
+		if err != nil {
+			return fmt.Errorf("invalid SCORE_RISK_LEVEL: %s", err)
+		}
+		if !slices.Contains(levels, level) {
+			levels = append(levels, level) // The scored level is always reported
+		}
+	}
+	probability.SetRiskLevels(levels)
+
+	if width := os.Getenv("POP_CI_WIDTH"); width != "" {
+		w, err := strconv.ParseFloat(width, 64)
+		if err != nil || w < 0 {
+			return fmt.Errorf("invalid POP_CI_WIDTH %q", width)
+		}
+		probability.SetTargetIntervalWidth(w)
+	}
+
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		metrics.EnableTracing(endpoint, "stocd")
+		log.Printf("Exporting traces to %s", endpoint)
+	}
+	return nil
+}
+
+// brokerFromEnv returns the Tradier brokerage account configured by TRADIER_ACCOUNT_ID, or nil. With
+// TRADIER_SANDBOX the account is a paper trading account of the sandbox unless TRADIER_TRADING_URL says
+// otherwise.
+func brokerFromEnv() execution.Broker {
+	accountID := os.Getenv("TRADIER_ACCOUNT_ID")
+	if accountID == "" {
+		return nil
+	}
+	tradingURL := os.Getenv("TRADIER_TRADING_URL")
+	tradingKey := os.Getenv("TRADIER_TRADING_KEY")
+	if sandbox, _ := strconv.ParseBool(os.Getenv("TRADIER_SANDBOX")); sandbox {
+		if tradingURL == "" {
+			tradingURL = execution.SandboxTradierURL
+		}
+		if tradingKey == "" {
+			tradingKey = os.Getenv("TRADIER_SANDBOX_KEY")
+		}
+	}
+	if tradingKey == "" {
+		tradingKey = os.Getenv("TRADIER_KEY")
+	}
+	return execution.NewTradier(tradingKey, accountID, tradingURL)
+}
+
+// openPaperStore opens the paper trades at PAPER_PATH, paper.json by default.
+func openPaperStore() (*paper.Store, error) {
+	paperPath := os.Getenv("PAPER_PATH")
+	if paperPath == "" {
+		paperPath = "paper.json"
+	}
+	return paper.Open(paperPath)
+}
+
+// confirmOrder previews the order of a ticket, given as JSON or as @file, and places it if the user
+// answers yes on standard input.
+func confirmOrder(broker execution.Broker, spec string, quantity int) error {
+	if path, ok := strings.CutPrefix(spec, "@"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read ticket: %s", err)
+		}
+		spec = string(data)
+	}
+	ticket, err := execution.ParseTicket(spec)
+	if err != nil {
+		return err
+	}
+	order, err := execution.NewOrder(ticket, quantity)
+	if err != nil {
+		return err
+	}
+
+	preview, err := broker.PreviewOrder(order)
+	if err != nil {
+		return fmt.Errorf("failed to preview order: %s", err)
+	}
+	fmt.Printf("Order: %s\nStatus %s, cost %.2f, commission %.2f, fees %.2f, margin change %.2f\n",
+		order.Describe(), preview.Status, preview.Cost, preview.Commission, preview.Fees, preview.MarginChange)
+
+	fmt.Print("Place this order? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		fmt.Println("Order not placed")
+		return nil
+	}
+
+	result, err := broker.PlaceOrder(order)
+	if err != nil {
+		return fmt.Errorf("failed to place order: %s", err)
+	}
+	fmt.Printf("Placed order %d (%s)\n", result.ID, result.Status)
+	return nil
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd"
+	"github.com/bcdannyboy/stocd/archive"
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/notify"
+	"github.com/bcdannyboy/stocd/positions"
+	"github.com/bcdannyboy/stocd/probability"
+	"github.com/bcdannyboy/stocd/progress"
+	"github.com/bcdannyboy/stocd/results"
+	"github.com/bcdannyboy/stocd/screener"
+	"github.com/bcdannyboy/stocd/slippage"
+	"github.com/bcdannyboy/stocd/watchlist"
+)
+
+const progressInterval = 2 * time.Second // Interval between progress log lines of a scan
+
+func runScan(fs *flag.FlagSet, args []string) error {
+	indicator := fs.String("indicator", "1", "> 0 for bull put spreads, a number <= 0 for bear call spreads, auto to choose from the direction signals, best to screen both sides and keep the better one, both, or a strategy: csp, cc, strangle, straddle, putratio, callratio, lizard or a declared strategy")
+	minDTE := fs.Int("min-dte", 14, "minimum days to expiration (setting MIN_DTE)")
+	maxDTE := fs.Int("max-dte", 45, "maximum days to expiration (setting MAX_DTE)")
+	minRoR := fs.Float64("min-ror", 0.15, "minimum return on risk (setting MIN_ROR)")
+	rfr := fs.Float64("rfr", 0.04, "annual risk-free rate (setting RISK_FREE_RATE)")
+	top := fs.Int("top", 10, "number of spreads printed and ranked, 0 for all")
+	export := fs.String("export", "", "export the full scan results as format:path (format is json, ndjson, csv or parquet; ndjson is streamed as spreads are simulated)")
+	maxSpreads := fs.Int("max-spreads", 0, "viable spreads kept in memory per symbol, the best by probability of profit, 0 for all (setting MAX_SPREADS)")
+	traceSpread := fs.String("trace-spread", "", "record simulation paths for the spread with this ID (<shortSymbol>_<longSymbol>)")
+	tracePaths := fs.Int("trace-paths", 10, "number of paths to record per volatility/model combination when tracing")
+	traceOut := fs.String("trace-out", "", "CSV file for the simulation trace (default trace_<spreadID>.csv)")
+	notifyResults := fs.Bool("notify", false, "also push the results to the configured email, Slack, Discord and Telegram notifiers (setting NOTIFY)")
+	watchlistName := fs.String("watchlist", "", "also scan the symbols of this watchlist (a Slack channel ID) in WATCHLIST_PATH")
+	symbolWorkers := fs.Int("symbol-workers", positions.DefaultSymbolWorkers, "symbols scanned concurrently (setting SYMBOL_WORKERS)")
+	expirations := fs.String("expirations", "all", "expiration cycles to trade: all, or comma-separated weeklys, monthlys, quarterlys and eom (setting EXPIRATION_TYPES)")
+	shortDated := fs.Bool("short-dated", false, "simulate the trading hours left to expiration and intraday volatility, for 0-3 DTE spreads with -min-dte 0 (setting SHORT_DATED)")
+	adjacentStrikes := fs.Bool("adjacent-strikes", false, "pair only neighbouring listed strikes, for dense chains like SPX (setting ADJACENT_STRIKES)")
+	includeNonstandard := fs.Bool("include-nonstandard", false, "also trade minis and adjusted series (setting INCLUDE_NONSTANDARD)")
+	excludeStale := fs.Bool("exclude-stale-quotes", false, "leave out spreads with a leg quoted longer than MAX_QUOTE_AGE ago during market hours, rather than flagging them (setting EXCLUDE_STALE_QUOTES)")
+	sortBy := fs.String("sort", "score", "comma-separated keys the results are ranked by, ties broken by the next: score, ev, pop, ror, theta_per_day, theta_vega, theta_credit or dollar_var (setting SORT_BY)")
+	symbols, err := scanSymbols(parseArgs(fs, args, 0, -1), *watchlistName)
+	if err != nil {
+		return err
+	}
+	useSettingDefault(fs, "symbol-workers", "SYMBOL_WORKERS")
+	useSettingDefault(fs, "notify", "NOTIFY")
+	useSettingDefault(fs, "min-dte", "MIN_DTE")
+	useSettingDefault(fs, "max-dte", "MAX_DTE")
+	useSettingDefault(fs, "min-ror", "MIN_ROR")
+	useSettingDefault(fs, "rfr", "RISK_FREE_RATE")
+	useSettingDefault(fs, "expirations", "EXPIRATION_TYPES")
+	useSettingDefault(fs, "short-dated", "SHORT_DATED")
+	useSettingDefault(fs, "include-nonstandard", "INCLUDE_NONSTANDARD")
+	useSettingDefault(fs, "exclude-stale-quotes", "EXCLUDE_STALE_QUOTES")
+	useSettingDefault(fs, "adjacent-strikes", "ADJACENT_STRIKES")
+	useSettingDefault(fs, "sort", "SORT_BY")
+	useSettingDefault(fs, "max-spreads", "MAX_SPREADS")
+
+	if err := configureSimulation(); err != nil {
+		return err
+	}
+	if *traceSpread != "" {
+		probability.EnableTracing(*traceSpread, *tracePaths, *traceOut)
+	}
+
+	opts := positions.ScanOptionsFromEnv()
+	if opts.ExpirationTypes, err = positions.ParseExpirationTypes(*expirations); err != nil {
+		return fmt.Errorf("invalid --expirations value: %s", err)
+	}
+	opts.ShortDated = *shortDated
+	opts.IncludeNonstandard = *includeNonstandard
+	opts.ExcludeStaleQuotes = *excludeStale
+	opts.AdjacentStrikes = *adjacentStrikes
+	opts.MaxSpreads = *maxSpreads
+	fill, err := positions.ParseFillModel(os.Getenv("FILL_MODEL"))
+	if err != nil {
+		return fmt.Errorf("invalid FILL_MODEL: %s", err)
+	}
+	opts.Fill = fill
+	ranking := positions.RankingConstraintsFromEnv()
+	if ranking.Sort, err = positions.ParseSort(*sortBy); err != nil {
+		return fmt.Errorf("invalid --sort value: %s", err)
+	}
+	if fillsPath := os.Getenv("FILLS_PATH"); fillsPath != "" {
+		if opts.Slippage, err = slippage.Open(fillsPath); err != nil {
+			log.Printf("Error opening fill history, slippage adjustment disabled: %v", err)
+		}
+	}
+
+	var exportFormat, exportPath string
+	if *export != "" {
+		if exportFormat, exportPath, err = results.ParseExportFlag(*export); err != nil {
+			return fmt.Errorf("invalid --export value: %s", err)
+		}
+	}
+	name := strings.Join(symbols, "_")
+	var stream *results.NDJSONWriter
+	if exportFormat == results.FormatNDJSON {
+		if stream, exportPath, err = results.CreateNDJSON(exportPath, name); err != nil {
+			return fmt.Errorf("failed to export results: %s", err)
+		}
+		opts.Stream = stream
+	}
+
+	// Only the printed spreads are ranked, unless every spread is exported after the scan
+	limit := *top
+	if exportFormat != "" && stream == nil {
+		limit = 0
+	}
+
+	analyzer := stocd.NewAnalyzer(os.Getenv("TRADIER_KEY"))
+	analyzer.SymbolWorkers = *symbolWorkers
+	if analyzer.Signals, err = screener.ParseSignals(os.Getenv("DIRECTION_SIGNALS")); err != nil {
+		return fmt.Errorf("invalid DIRECTION_SIGNALS: %s", err)
+	}
+	if archiveDir := os.Getenv("ARCHIVE_DIR"); archiveDir != "" {
+		analyzer.Archive = archive.New(archiveDir, nil)
+	}
+
+	track := func(symbol string) *progress.Tracker {
+		tracker := progress.Start(symbol)
+		go tracker.Watch(progressInterval, nil, func(s progress.Snapshot) {
+			log.Printf("%s progress: %s", symbol, s)
+		})
+		return tracker
+	}
+	scanned, err := analyzer.AnalyzeSymbols(context.Background(), symbols, stocd.AnalyzeRequest{
+		Indicator:    *indicator,
+		MinDTE:       *minDTE,
+		MaxDTE:       *maxDTE,
+		MinRoR:       *minRoR,
+		RiskFreeRate: *rfr,
+		Options:      opts,
+		Ranking:      ranking,
+		Top:          *top,
+		Limit:        limit,
+	}, track)
+	if stream != nil {
+		if closeErr := stream.Close(); closeErr != nil && err == nil {
+			return fmt.Errorf("failed to export results: %s", closeErr)
+		}
+		log.Printf("Streamed %d spreads to %s", stream.Count(), exportPath)
+	}
+	if err != nil {
+		return err
+	}
+	for _, result := range scanned.Results {
+		if result.Direction != "" {
+			log.Printf("%s direction: %s selected %s", result.Symbol, strings.ToLower(*indicator), result.Direction)
+		}
+	}
+	spreads := scanned.Spreads
+
+	if exportFormat != "" && stream == nil {
+		path, err := results.Export(exportFormat, exportPath, name, spreads)
+		if err != nil {
+			return fmt.Errorf("failed to export results: %s", err)
+		}
+		log.Printf("Exported %d spreads to %s", len(spreads), path)
+	}
+
+	var summary strings.Builder
+	if len(symbols) == 1 {
+		result := scanned.Results[0]
+		fmt.Fprintf(&summary, "%s %s at %.2f: %d spreads meeting criteria\n", result.Symbol, result.SpreadType, result.UnderlyingPrice, scanned.Found)
+	} else {
+		fmt.Fprintf(&summary, "%d symbols: %d spreads meeting criteria, scored together\n", len(symbols), scanned.Found)
+		for _, result := range scanned.Results {
+			fmt.Fprintf(&summary, "  %s %s at %.2f: %d spreads\n", result.Symbol, result.SpreadType, result.UnderlyingPrice, result.Found)
+		}
+		for _, symbol := range symbols {
+			if err, ok := scanned.Failed[symbol]; ok {
+				fmt.Fprintf(&summary, "  %s failed: %v\n", symbol, err)
+			}
+		}
+	}
+	for i, spread := range spreads {
+		if *top > 0 && i >= *top {
+			break
+		}
+		legs := spread.Spread.ShortLeg.Option.Symbol
+		if spread.Spread.LongLeg.Option.Symbol != "" {
+			legs += " / " + spread.Spread.LongLeg.Option.Symbol
+		}
+		ci := spread.Probability.AverageInterval
+		maxLoss := fmt.Sprintf("$%.2f", spread.Dollars.MaxLoss)
+		if spread.Dollars.UnboundedLoss {
+			maxLoss = "unbounded"
+		}
+		fmt.Fprintf(&summary, "%2d. %s  credit %.2f, ROR %.1f%%, PoP %.1f%% (95%% CI %.1f-%.1f%%), EV %.2f, short strike %.2f EM\n", i+1, legs,
+			spread.Spread.SpreadCredit, spread.Spread.ROR*100, spread.Probability.AverageProbability*100,
+			ci.Lower*100, ci.Upper*100, spread.ExpectedValue, spread.Spread.ShortStrikeMoves)
+		fmt.Fprintf(&summary, "    per contract: max profit $%.2f, max loss %s, VaR 95%% $%.2f, ES 95%% $%.2f\n",
+			spread.Dollars.MaxProfit, maxLoss, spread.Dollars.VaR95, spread.Dollars.ExpectedShortfall)
+		fmt.Fprintf(&summary, "    decay: theta %.4f/day, theta/vega %.2f, %.2f%% of the credit per day\n",
+			spread.Spread.Decay.ThetaPerDay, spread.Spread.Decay.ThetaVegaRatio, spread.Spread.Decay.ThetaCreditRatio*100)
+		if note := models.SettlementNote(spread.Spread); note != "" {
+			fmt.Fprintf(&summary, "    index options: %s\n", note)
+		}
+		if spread.Quotes.Stale {
+			fmt.Fprintf(&summary, "    stale quotes: last quoted %s (%s ago)\n", spread.Quotes.QuotedAt.Format("Jan 2 15:04:05"), spread.Quotes.Age.Round(time.Second))
+		}
+	}
+	fmt.Printf("\n%s", summary.String())
+
+	// Notify last, so a failed notification never costs the printed and exported results
+	if *notifyResults {
+		notify.NotifyAll(notify.FromEnv(), fmt.Sprintf("STOCD results for %s", strings.Join(symbols, ", ")), summary.String())
+	}
+	return nil
+}
+
+// scanSymbols returns the symbols to scan: those of the arguments, each a symbol or a comma-separated
+// list, followed by those of the named watchlist, without duplicates.
+func scanSymbols(args []string, watchlistName string) ([]string, error) {
+	var symbols []string
+	seen := make(map[string]bool)
+	add := func(symbol string) {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol != "" && !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	for _, arg := range args {
+		for _, symbol := range strings.Split(arg, ",") {
+			add(symbol)
+		}
+	}
+
+	if watchlistName != "" {
+		path := os.Getenv("WATCHLIST_PATH")
+		if path == "" {
+			path = "watchlist.json"
+		}
+		store, err := watchlist.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		list := store.List(watchlistName)
+		if len(list) == 0 {
+			return nil, fmt.Errorf("watchlist %s in %s is empty", watchlistName, path)
+		}
+		for _, symbol := range list {
+			add(symbol)
+		}
+	}
+
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("no symbols: give symbols or -watchlist")
+	}
+	return symbols, nil
+}
+
+func runCalibrate(fs *flag.FlagSet, args []string) error {
+	rfr := fs.Float64("rfr", 0.04, "annual risk-free rate (setting RISK_FREE_RATE)")
+	maxDTE := fs.Int("max-dte", 365, "latest expiration whose options are used, in days")
+	symbol := strings.ToUpper(parseArgs(fs, args, 1, 1)[0])
+	useSettingDefault(fs, "rfr", "RISK_FREE_RATE")
+
+	data, err := stocd.NewAnalyzer(os.Getenv("TRADIER_KEY")).MarketData(context.Background(), symbol, 0, *maxDTE)
+	if err != nil {
+		return err
+	}
+	lastPrice := data.Price
+
+	gm := positions.CalibrateModels(data.Chain, lastPrice, *rfr, *data.Quotes, market.Now(), nil)
+	fmt.Printf("\n%s at %.2f, risk-free rate %.4f\n", symbol, lastPrice, *rfr)
+	fmt.Printf("Heston: V0 %.4f, Kappa %.4f, Theta %.4f, Xi %.4f, Rho %.4f\n", gm.Heston.V0, gm.Heston.Kappa, gm.Heston.Theta, gm.Heston.Xi, gm.Heston.Rho)
+	fmt.Printf("Merton: Lambda %.4f, Mu %.4f, Delta %.4f (fit error %.2f)\n", gm.Merton.Lambda, gm.Merton.Mu, gm.Merton.Delta, gm.FitErrors["Merton_Heston"])
+	fmt.Printf("Kou: Lambda %.4f, P %.4f, Eta1 %.4f, Eta2 %.4f (fit error %.2f)\n", gm.Kou.Lambda, gm.Kou.P, gm.Kou.Eta1, gm.Kou.Eta2, gm.FitErrors["Kou_Heston"])
+	if gm.SkewT != nil {
+		fmt.Printf("Student-t: Nu %.2f; skew-t: Nu %.2f, Lambda %.3f\n", gm.StudentT.Nu, gm.SkewT.Nu, gm.SkewT.Lambda)
+	}
+	p := gm.CGMY.Params
+	fmt.Printf("CGMY: C %.4f, G %.4f, M %.4f, Y %.4f (fit error %.2f)\n", p.C, p.G, p.M, p.Y, gm.FitErrors["CGMY_Heston"])
+	for _, tenor := range gm.Tenors {
+		feller := ""
+		if !tenor.Heston.FellerSatisfied() {
+			feller = ", violates the Feller condition"
+		}
+		fmt.Printf("  %d-%d DTE:\n    %s%s\n    %s\n", tenor.MinDays, tenor.MaxDays, tenor.HestonFit.Describe(), feller, tenor.CGMYFit.Describe())
+	}
+	fmt.Println("Fit errors are the distance from the historical skewness and kurtosis of daily returns, in standard errors.")
+	fmt.Printf("Tenor fits are the errors of the models' prices of the calibration options, with parameters ± standard errors; above %.0f%% MAPE a fit is poor and CGMY simulations are down-weighted.\n", models.PoorFitMAPE*100)
+	return nil
+}
+
+// runCone prints the symbol's volatility cones compared with its at-the-money implied volatility.
+func runCone(fs *flag.FlagSet, args []string) error {
+	maxDTE := fs.Int("max-dte", 200, "latest expiration whose implied volatility is compared, in days")
+	lookback := fs.Int("lookback", models.DefaultConeLookback, "trading days of history the cones' rolling windows cover, 0 for all")
+	symbol := strings.ToUpper(parseArgs(fs, args, 1, 1)[0])
+
+	data, err := stocd.NewAnalyzer(os.Getenv("TRADIER_KEY")).MarketData(context.Background(), symbol, 0, *maxDTE)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n%s at %.2f\n", symbol, data.Price)
+	fmt.Print(models.DescribeCones(models.VolatilityCones(*data.Quotes, *lookback), models.ATMTermStructure(data.Chain, data.Price, market.Now())))
+	fmt.Printf("Implied volatility is rich at or above the %.0fth percentile of realized and cheap at or below the %.0fth.\n", models.RichPercentile*100, models.CheapPercentile*100)
+	return nil
+}
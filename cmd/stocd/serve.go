@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bcdannyboy/stocd"
+	"github.com/bcdannyboy/stocd/archive"
+	"github.com/bcdannyboy/stocd/metrics"
+	"github.com/bcdannyboy/stocd/monitor"
+	"github.com/bcdannyboy/stocd/notify"
+	"github.com/bcdannyboy/stocd/positions"
+	"github.com/bcdannyboy/stocd/probability"
+	"github.com/bcdannyboy/stocd/progress"
+	"github.com/bcdannyboy/stocd/report"
+	"github.com/bcdannyboy/stocd/results"
+	"github.com/bcdannyboy/stocd/schedule"
+	"github.com/bcdannyboy/stocd/screener"
+	stocdslack "github.com/bcdannyboy/stocd/slack"
+	"github.com/bcdannyboy/stocd/watchlist"
+)
+
+func runServe(fs *flag.FlagSet, args []string) error {
+	export := fs.String("export", "", "export the full scan results as format:path (format is json, ndjson, csv or parquet; ndjson is streamed as spreads are simulated)")
+	traceSpread := fs.String("trace-spread", "", "record simulation paths for the spread with this ID (<shortSymbol>_<longSymbol>)")
+	tracePaths := fs.Int("trace-paths", 10, "number of paths to record per volatility/model combination when tracing")
+	traceOut := fs.String("trace-out", "", "CSV file for the simulation trace (default trace_<spreadID>.csv)")
+	htmlReport := fs.Bool("html-report", false, "attach an HTML report with payoff, distribution and volatility charts to each scan")
+	top := fs.Int("top", 10, "number of spreads shown per page of scan results")
+	fastAnswer := fs.Bool("fast-answer", true, "post a preliminary top 5 from an analytic screen while the full simulation runs")
+	notifyResults := fs.Bool("notify", true, "push scan results and exit signals to the configured email, Slack, Discord and Telegram notifiers (setting NOTIFY)")
+	symbolWorkers := fs.Int("symbol-workers", positions.DefaultSymbolWorkers, "symbols multi-symbol scans fetch, calibrate and scan concurrently (setting SYMBOL_WORKERS)")
+	parseArgs(fs, args, 0, 0)
+	useSettingDefault(fs, "notify", "NOTIFY")
+	useSettingDefault(fs, "symbol-workers", "SYMBOL_WORKERS")
+
+	if err := configureSimulation(); err != nil {
+		return err
+	}
+
+	factors, err := screener.ParseFactors(os.Getenv("SCREENER_FACTORS"))
+	if err != nil {
+		return fmt.Errorf("invalid SCREENER_FACTORS: %s", err)
+	}
+	signals, err := screener.ParseSignals(os.Getenv("DIRECTION_SIGNALS"))
+	if err != nil {
+		return fmt.Errorf("invalid DIRECTION_SIGNALS: %s", err)
+	}
+	fill, err := positions.ParseFillModel(os.Getenv("FILL_MODEL"))
+	if err != nil {
+		return fmt.Errorf("invalid FILL_MODEL: %s", err)
+	}
+
+	paperStore, err := openPaperStore()
+	if err != nil {
+		log.Printf("Error opening paper trades, /paper disabled: %v", err)
+	}
+
+	config := stocdslack.Config{TopN: *top, HTMLReport: *htmlReport, FastAnswer: *fastAnswer, ScreenerFactors: factors, Fill: fill, Broker: brokerFromEnv(), Paper: paperStore}
+	if *export != "" {
+		config.ExportFormat, config.ExportPath, err = results.ParseExportFlag(*export)
+		if err != nil {
+			return fmt.Errorf("invalid --export value: %s", err)
+		}
+	}
+
+	config.Ranking = positions.RankingConstraintsFromEnv()
+
+	if *traceSpread != "" {
+		probability.EnableTracing(*traceSpread, *tracePaths, *traceOut)
+	}
+
+	if archiveDir := os.Getenv("ARCHIVE_DIR"); archiveDir != "" {
+		var signer archive.Signer
+		if signingKey := os.Getenv("ARCHIVE_SIGNING_KEY"); signingKey != "" {
+			signer, err = archive.ParseSigningKey(signingKey)
+			if err != nil {
+				return fmt.Errorf("invalid ARCHIVE_SIGNING_KEY: %s", err)
+			}
+		}
+		config.Archive = archive.New(archiveDir, signer)
+	}
+
+	config.Analyzer = stocd.NewAnalyzer(os.Getenv("TRADIER_KEY"))
+	config.Analyzer.Signals = signals
+	config.Analyzer.Archive = config.Archive
+	config.Analyzer.Models = positions.ModelCacheFromEnv()
+	config.Analyzer.SymbolWorkers = *symbolWorkers
+
+	if *notifyResults {
+		config.Notifiers = notify.FromEnv()
+	}
+
+	monitorPath := os.Getenv("MONITOR_PATH")
+	if monitorPath == "" {
+		monitorPath = "positions.json"
+	}
+	monitorInterval, err := time.ParseDuration(os.Getenv("MONITOR_INTERVAL"))
+	if err != nil || monitorInterval <= 0 {
+		monitorInterval = 15 * time.Minute
+	}
+	positionStore, err := monitor.Open(monitorPath)
+	if err != nil {
+		log.Printf("Error opening monitored positions, exit signals disabled: %v", err)
+	} else {
+		config.Monitor = monitor.New(positionStore, monitor.RulesFromEnv(), config.Notifiers, monitorInterval)
+	}
+
+	schedulePath := os.Getenv("SCHEDULE_PATH")
+	if schedulePath == "" {
+		schedulePath = "schedules.json"
+	}
+	config.Scheduler, err = schedule.Open(schedulePath)
+	if err != nil {
+		log.Printf("Error opening schedules, scheduled scans disabled: %v", err)
+	}
+
+	watchlistPath := os.Getenv("WATCHLIST_PATH")
+	if watchlistPath == "" {
+		watchlistPath = "watchlist.json"
+	}
+	config.Watchlist, err = watchlist.Open(watchlistPath)
+	if err != nil {
+		log.Printf("Error opening watchlists, /scanall disabled: %v", err)
+	}
+
+	config.Report, err = report.NewFormatter(os.Getenv("REPORT_LOCALE"), os.Getenv("REPORT_TIMEZONE"))
+	if err != nil {
+		return fmt.Errorf("invalid report settings: %s", err)
+	}
+
+	if statusAddr := os.Getenv("STATUS_ADDR"); statusAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/status", progress.Handler())
+		mux.Handle("/metrics", metrics.Handler())
+		go func() {
+			log.Printf("Serving scan status on %s/status and metrics on %s/metrics", statusAddr, statusAddr)
+			if err := http.ListenAndServe(statusAddr, mux); err != nil {
+				log.Printf("Error serving scan status: %v", err)
+			}
+		}()
+	}
+
+	bot := stocdslack.NewSlackBot(os.Getenv("SLACK_APP_TOKEN"), os.Getenv("SLACK_BOT_TOKEN"), config)
+
+	log.Println("Starting SlackBot...")
+	if err := bot.Start(); err != nil {
+		return fmt.Errorf("failed to start SlackBot: %s", err)
+	}
+	return nil
+}
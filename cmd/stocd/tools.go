@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/archive"
+	"github.com/bcdannyboy/stocd/calibration"
+	"github.com/bcdannyboy/stocd/hedge"
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/monitor"
+	"github.com/bcdannyboy/stocd/paper"
+	"github.com/bcdannyboy/stocd/results"
+	"github.com/bcdannyboy/stocd/screener"
+)
+
+func runScreen(fs *flag.FlagSet, args []string) error {
+	minDTE := fs.Int("min-dte", screener.DefaultMinDTE, "minimum days to expiration of the screened options")
+	maxDTE := fs.Int("max-dte", screener.DefaultMaxDTE, "maximum days to expiration of the screened options")
+	var symbols []string
+	for _, arg := range parseArgs(fs, args, 1, -1) {
+		symbols = append(symbols, strings.Split(strings.ToUpper(arg), ",")...)
+	}
+
+	factors, err := screener.ParseFactors(os.Getenv("SCREENER_FACTORS"))
+	if err != nil {
+		return fmt.Errorf("invalid SCREENER_FACTORS: %s", err)
+	}
+	var history *archive.Archive
+	if archiveDir := os.Getenv("ARCHIVE_DIR"); archiveDir != "" {
+		history = archive.New(archiveDir, nil)
+	}
+
+	results, failed := screener.ScreenSymbols(symbols, os.Getenv("TRADIER_KEY"), *minDTE, *maxDTE, factors, history)
+	for i, result := range results {
+		fmt.Println(screener.Describe(i+1, result, factors))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to screen: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// runBacktest evaluates the recorded paper trades, the only trade history kept, against the market.
+func runBacktest(fs *flag.FlagSet, args []string) error {
+	bins := fs.Int("bins", calibration.DefaultBins, "probability bins of the calibration curve")
+	parseArgs(fs, args, 0, 0)
+
+	store, err := openPaperStore()
+	if err != nil {
+		return fmt.Errorf("failed to open paper trades: %s", err)
+	}
+	marks, err := paper.Refresh(store, os.Getenv("TRADIER_KEY"), market.Now())
+	if err != nil {
+		return fmt.Errorf("failed to mark paper trades: %s", err)
+	}
+	fmt.Print(paper.Report(store.List(), marks))
+	fmt.Print(calibration.Compute(calibration.FromPaper(store.List()), *bins).Describe())
+	return nil
+}
+
+// runHedge prints the beta-weighted greeks of a book of positions and the hedge that flattens them.
+func runHedge(fs *flag.FlagSet, args []string) error {
+	source := fs.String("source", "", "positions to hedge: broker, paper or monitor (default broker when TRADIER_ACCOUNT_ID is set, otherwise paper)")
+	with := fs.String("with", "SPY", "ETF the positions are hedged with, e.g. SPY or QQQ")
+	vega := fs.Bool("vega", false, "also flatten vega with an at-the-money call of the ETF")
+	parseArgs(fs, args, 0, 0)
+
+	broker := brokerFromEnv()
+	if *source == "" {
+		*source = hedge.SourcePaper
+		if broker != nil {
+			*source = hedge.SourceBroker
+		}
+	}
+	var paperStore *paper.Store
+	var monitorStore *monitor.Store
+	var err error
+	switch *source {
+	case hedge.SourcePaper:
+		if paperStore, err = openPaperStore(); err != nil {
+			return fmt.Errorf("failed to open paper trades: %s", err)
+		}
+	case hedge.SourceMonitor:
+		monitorPath := os.Getenv("MONITOR_PATH")
+		if monitorPath == "" {
+			monitorPath = "positions.json"
+		}
+		if monitorStore, err = monitor.Open(monitorPath); err != nil {
+			return fmt.Errorf("failed to open monitored positions: %s", err)
+		}
+	}
+	holdings, err := hedge.Holdings(*source, broker, paperStore, monitorStore)
+	if err != nil {
+		return err
+	}
+
+	portfolio, suggestion, err := hedge.Plan(holdings, strings.ToUpper(*with), *vega, os.Getenv("TRADIER_KEY"), market.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Print(hedge.Describe(portfolio, suggestion))
+	return nil
+}
+
+func runOrder(fs *flag.FlagSet, args []string) error {
+	quantity := fs.Int("quantity", 1, "units of the ticket's position to order")
+	ticket := parseArgs(fs, args, 1, 1)[0]
+
+	broker := brokerFromEnv()
+	if broker == nil {
+		return fmt.Errorf("ordering requires TRADIER_ACCOUNT_ID")
+	}
+	return confirmOrder(broker, ticket, *quantity)
+}
+
+func runConvert(fs *flag.FlagSet, args []string) error {
+	out := fs.String("o", "", "file to write the converted export to (default stdout)")
+	path := parseArgs(fs, args, 1, 1)[0]
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open export: %s", err)
+	}
+	defer file.Close()
+	doc, err := results.ConvertLegacy(file)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			return fmt.Errorf("failed to create converted export: %s", err)
+		}
+		defer w.Close()
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode converted export: %s", err)
+	}
+	if *out != "" {
+		log.Printf("Converted %d spreads to schema version %d in %s", len(doc.Spreads), doc.SchemaVersion, *out)
+	}
+	return nil
+}
+
+func runVerify(fs *flag.FlagSet, args []string) error {
+	key := fs.String("key", "", "key used to check the archive signature (hmac:<secret> or ed25519:<base64 public key>)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	dir := fs.Arg(0)
+
+	var verifier archive.Verifier
+	if *key != "" {
+		var err error
+		verifier, err = archive.ParseVerifyKey(*key)
+		if err != nil {
+			return fmt.Errorf("invalid --key value: %s", err)
+		}
+	}
+	manifest, err := archive.Verify(dir, verifier)
+	if err != nil {
+		return fmt.Errorf("archive verification failed: %s", err)
+	}
+	log.Printf("Archive %s verified: %d files for %s created %s", dir, len(manifest.Files), manifest.Symbol, manifest.CreatedAt.Format(time.RFC3339))
+	return nil
+}
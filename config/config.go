@@ -0,0 +1,182 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultPath is the configuration file loaded when none is given and it exists.
+const DefaultPath = "stocd.toml"
+
+// Load reads a TOML configuration file and sets the environment variable of every key the environment
+// does not already set, so the environment overrides the file. A key's variable is its table and name
+// joined by underscores and upper cased: key in [tradier] sets TRADIER_KEY and min_analytic_pop outside
+// any table sets MIN_ANALYTIC_POP. Arrays are joined with commas, as the comma separated settings expect.
+//
+// Only the TOML needed for settings is supported: tables, and strings, numbers, booleans and
+// single line arrays of them as values.
+func Load(path string) error {
+	values, err := Parse(path)
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		if _, set := os.LookupEnv(name); set {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return fmt.Errorf("failed to set %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// Parse reads a TOML configuration file into environment variable values by variable name.
+func Parse(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %s", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	table := ""
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("%s:%d: invalid table header %q", path, lineNumber, line)
+			}
+			table = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		key, raw = strings.TrimSpace(key), strings.TrimSpace(raw)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNumber)
+		}
+		value, err := parseValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %s", path, lineNumber, key, err)
+		}
+		name := EnvName(table, key)
+		if _, dup := values[name]; dup {
+			return nil, fmt.Errorf("%s:%d: %s is set more than once", path, lineNumber, name)
+		}
+		values[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %s", err)
+	}
+	return values, nil
+}
+
+// EnvName is the environment variable set by key in table, which may be empty or dotted.
+func EnvName(table, key string) string {
+	name := strings.Trim(strings.Trim(key, `"`), " ")
+	if table != "" {
+		name = table + "_" + name
+	}
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+// parseValue converts a TOML value to the text of its environment variable.
+func parseValue(raw string) (string, error) {
+	switch {
+	case raw == "":
+		return "", fmt.Errorf("missing value")
+	case strings.HasPrefix(raw, "["):
+		if !strings.HasSuffix(raw, "]") {
+			return "", fmt.Errorf("arrays must be on one line")
+		}
+		var items []string
+		for _, item := range splitArray(raw[1 : len(raw)-1]) {
+			value, err := parseValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, value)
+		}
+		return strings.Join(items, ","), nil
+	case strings.HasPrefix(raw, `"`):
+		value, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", raw)
+		}
+		return value, nil
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return "", fmt.Errorf("invalid string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw == "true" || raw == "false":
+		return raw, nil
+	}
+	number := strings.ReplaceAll(raw, "_", "")
+	if _, err := strconv.ParseFloat(number, 64); err != nil {
+		return "", fmt.Errorf("invalid value %s (strings must be quoted)", raw)
+	}
+	return number, nil
+}
+
+// splitArray splits the items of an array on the commas outside strings, dropping a trailing comma.
+func splitArray(s string) []string {
+	var items []string
+	var quote rune
+	escaped := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			quote, escaped = closeQuote(quote, r, escaped)
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+// stripComment removes a # comment outside strings from the line.
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			quote, escaped = closeQuote(quote, r, escaped)
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// closeQuote advances through a string opened by quote past r, returning the quote still open, 0 once
+// it closes, and whether the next rune is escaped. Only basic, double quoted strings have escapes.
+func closeQuote(quote, r rune, escaped bool) (rune, bool) {
+	switch {
+	case escaped:
+		return quote, false
+	case r == '\\' && quote == '"':
+		return quote, true
+	case r == quote:
+		return 0, false
+	}
+	return quote, false
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStripComment(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`key = 1 # comment`, `key = 1 `},
+		{`# comment`, ``},
+		{`key = "a # b"`, `key = "a # b"`},
+		{`key = 'a # b' # comment`, `key = 'a # b' `},
+		{`key = "say \"hi\" # there" # comment`, `key = "say \"hi\" # there" `},
+		{`key = "C:\\" # comment`, `key = "C:\\" `},
+		{`key = 'C:\' # comment`, `key = 'C:\' `}, // Literal strings have no escapes
+		{`key = "it's # fine"`, `key = "it's # fine"`},
+	}
+	for _, tt := range tests {
+		if got := stripComment(tt.line); got != tt.want {
+			t.Errorf("stripComment(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSplitArray(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{``, nil},
+		{`1, 2,`, []string{"1", "2"}},
+		{`"a", "b"`, []string{`"a"`, `"b"`}},
+		{`"a,b", 'c,d', 3`, []string{`"a,b"`, `'c,d'`, "3"}},
+		{`"a\",b", "c"`, []string{`"a\",b"`, `"c"`}},
+		{`"a\\", "b"`, []string{`"a\\"`, `"b"`}},
+	}
+	for _, tt := range tests {
+		if got := splitArray(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArray(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func writeConfig(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stocd.toml")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want map[string]string
+		err  string
+	}{
+		{
+			name: "tables and values",
+			text: "min_analytic_pop = 0.4 # comment\n\n[tradier]\nkey = \"abc#123\"\n[simulation]\nensemble = 'fast'\nmax_spreads = 10_000\nshort_dated = true\n",
+			want: map[string]string{"MIN_ANALYTIC_POP": "0.4", "TRADIER_KEY": "abc#123", "SIMULATION_ENSEMBLE": "fast", "SIMULATION_MAX_SPREADS": "10000", "SIMULATION_SHORT_DATED": "true"},
+		},
+		{
+			name: "escaped quotes",
+			text: "[notify]\nsubject = \"STOCD \\\"results\\\" # today\" # comment\n",
+			want: map[string]string{"NOTIFY_SUBJECT": `STOCD "results" # today`},
+		},
+		{
+			name: "arrays with quoted commas",
+			text: "symbols = [\"SPY\", 'QQQ', \"a,b\"]\nweights = [1, 2.5,]\n",
+			want: map[string]string{"SYMBOLS": "SPY,QQQ,a,b", "WEIGHTS": "1,2.5"},
+		},
+		{
+			name: "dotted tables and keys",
+			text: "[notify.email]\nsmtp-host = \"mail\"\n[score]\nweight.vrp = 0.2\n\"quoted\" = 1\n",
+			want: map[string]string{"NOTIFY_EMAIL_SMTP_HOST": "mail", "SCORE_WEIGHT_VRP": "0.2", "SCORE_QUOTED": "1"},
+		},
+		{
+			name: "duplicate keys",
+			text: "[tradier]\nkey = \"a\"\nkey = \"b\"\n",
+			err:  "TRADIER_KEY is set more than once",
+		},
+		{
+			name: "duplicate variables from a table and a dotted key",
+			text: "[notify]\nemail_to = \"a\"\n[notify.email]\nto = \"b\"\n",
+			err:  "NOTIFY_EMAIL_TO is set more than once",
+		},
+		{name: "unquoted string", text: "ensemble = fast\n", err: "strings must be quoted"},
+		{name: "multiline array", text: "symbols = [\"SPY\",\n\"QQQ\"]\n", err: "arrays must be on one line"},
+		{name: "array of tables", text: "[[accounts]]\n", err: "invalid table header"},
+		{name: "missing value", text: "key =\n", err: "missing value"},
+		{name: "missing key", text: "= 1\n", err: "expected key = value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(writeConfig(t, tt.text))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Parse error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadKeepsEnvironment(t *testing.T) {
+	t.Setenv("STOCD_TEST_SET", "environment")
+	t.Setenv("STOCD_TEST_EMPTY", "")
+	t.Setenv("STOCD_TEST_UNSET", "")
+	os.Unsetenv("STOCD_TEST_UNSET") // Restored to unset after the test
+
+	path := writeConfig(t, "[stocd_test]\nset = \"file\"\nempty = \"file\"\nunset = \"file\"\n")
+	if err := Load(path); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"STOCD_TEST_SET": "environment", "STOCD_TEST_EMPTY": "", "STOCD_TEST_UNSET": "file"} {
+		if got := os.Getenv(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
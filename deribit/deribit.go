@@ -0,0 +1,266 @@
+// Package deribit fetches BTC and ETH option chains from Deribit's public API for scanning. Deribit's
+// options are inverse: quoted, margined and settled in the coin, one coin per contract.
+package deribit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	DefaultBaseURL = "https://www.deribit.com/api/v2"  // Root of Deribit's production API
+	TestnetBaseURL = "https://test.deribit.com/api/v2" // Root of Deribit's testnet
+
+	historyYears = 5 // Years of daily perpetual prices fetched for the models
+)
+
+// Currencies are the coins whose options can be scanned, by the symbol they are scanned under.
+var Currencies = map[string]string{"BTC-USD": "BTC", "ETH-USD": "ETH"}
+
+// IsSymbol reports whether the symbol names a coin with Deribit options, BTC-USD or ETH-USD.
+func IsSymbol(symbol string) bool {
+	_, ok := Currencies[strings.ToUpper(strings.TrimSpace(symbol))]
+	return ok
+}
+
+// Client fetches market data from Deribit's public API, which needs no key.
+type Client struct {
+	BaseURL string       // Root of the API, DefaultBaseURL when empty
+	HTTP    *http.Client // Sends the requests, http.DefaultClient when nil
+}
+
+// ClientFromEnv returns the client configured by DERIBIT_URL, e.g. TestnetBaseURL.
+func ClientFromEnv() Client {
+	return Client{BaseURL: os.Getenv("DERIBIT_URL")}
+}
+
+// MarketData is a coin's daily price history and option chain in dollars.
+type MarketData struct {
+	Quotes *tradier.QuoteHistory
+	Chain  map[string]*tradier.OptionChain
+	Price  float64 // Deribit's index price of the coin in dollars
+}
+
+// MarketData fetches the coin's daily perpetual prices over the last historyYears, its index price and its
+// options between minDTE and maxDTE days to expiration, in Tradier's types so the scan pipeline is
+// unchanged. Option prices are converted from coin to dollars at the underlying price Deribit quotes for
+// their expiration, and spreads on them carry the inverse payoff (models.OptionSpread.InversePrice).
+func (c Client) MarketData(symbol string, minDTE, maxDTE int, now time.Time) (MarketData, error) {
+	currency, ok := Currencies[strings.ToUpper(strings.TrimSpace(symbol))]
+	if !ok {
+		return MarketData{}, fmt.Errorf("unknown coin %s, expected BTC-USD or ETH-USD", symbol)
+	}
+
+	quotes, err := c.history(currency, now)
+	if err != nil {
+		return MarketData{}, err
+	}
+	if len(quotes.History.Day) == 0 {
+		return MarketData{}, fmt.Errorf("no price history for %s", symbol)
+	}
+
+	var index struct {
+		IndexPrice float64 `json:"index_price"`
+	}
+	if err := c.get("/public/get_index_price", url.Values{"index_name": {strings.ToLower(currency) + "_usd"}}, &index); err != nil {
+		return MarketData{}, fmt.Errorf("failed to fetch index price: %s", err)
+	}
+
+	chain, err := c.chain(currency, minDTE, maxDTE, now)
+	if err != nil {
+		return MarketData{}, err
+	}
+	return MarketData{Quotes: quotes, Chain: chain, Price: index.IndexPrice}, nil
+}
+
+// history fetches the daily bars of the coin's perpetual future, the longest continuous price series.
+func (c Client) history(currency string, now time.Time) (*tradier.QuoteHistory, error) {
+	var bars struct {
+		Ticks  []int64   `json:"ticks"` // Milliseconds since the epoch
+		Open   []float64 `json:"open"`
+		High   []float64 `json:"high"`
+		Low    []float64 `json:"low"`
+		Close  []float64 `json:"close"`
+		Volume []float64 `json:"volume"`
+		Status string    `json:"status"`
+	}
+	query := url.Values{
+		"instrument_name": {currency + "-PERPETUAL"},
+		"start_timestamp": {strconv.FormatInt(now.AddDate(-historyYears, 0, 0).UnixMilli(), 10)},
+		"end_timestamp":   {strconv.FormatInt(now.UnixMilli(), 10)},
+		"resolution":      {"1D"},
+	}
+	if err := c.get("/public/get_tradingview_chart_data", query, &bars); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s history: %s", currency, err)
+	}
+	n := len(bars.Ticks)
+	if len(bars.Open) < n || len(bars.High) < n || len(bars.Low) < n || len(bars.Close) < n {
+		return nil, fmt.Errorf("malformed %s history", currency)
+	}
+
+	history := &tradier.QuoteHistory{}
+	days := slices.Grow(history.History.Day, n)[:n]
+	for i, tick := range bars.Ticks {
+		days[i].Date = time.UnixMilli(tick).UTC().Format(market.DateLayout)
+		days[i].Open, days[i].High, days[i].Low, days[i].Close = bars.Open[i], bars.High[i], bars.Low[i], bars.Close[i]
+		if i < len(bars.Volume) {
+			days[i].Volume = int(bars.Volume[i])
+		}
+	}
+	history.History.Day = days
+	return history, nil
+}
+
+// summary is one option of get_book_summary_by_currency, its prices in the coin.
+type summary struct {
+	InstrumentName  string   `json:"instrument_name"`
+	BidPrice        *float64 `json:"bid_price"`
+	AskPrice        *float64 `json:"ask_price"`
+	MarkPrice       float64  `json:"mark_price"`
+	MarkIV          float64  `json:"mark_iv"` // Percent
+	Last            *float64 `json:"last"`
+	UnderlyingPrice float64  `json:"underlying_price"`
+	Volume          float64  `json:"volume"`
+	OpenInterest    float64  `json:"open_interest"`
+}
+
+// chain fetches the coin's options expiring between minDTE and maxDTE days out, by expiration date.
+func (c Client) chain(currency string, minDTE, maxDTE int, now time.Time) (map[string]*tradier.OptionChain, error) {
+	var summaries []summary
+	if err := c.get("/public/get_book_summary_by_currency", url.Values{"currency": {currency}, "kind": {"option"}}, &summaries); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s options: %s", currency, err)
+	}
+
+	chain := make(map[string]*tradier.OptionChain)
+	for _, s := range summaries {
+		option, err := s.option(currency)
+		if err != nil {
+			continue
+		}
+		days, err := market.DaysToExpiration(option.ExpirationDate, now)
+		if err != nil || days < minDTE || days > maxDTE {
+			continue
+		}
+		if chain[option.ExpirationDate] == nil {
+			chain[option.ExpirationDate] = &tradier.OptionChain{ExpirationDate: option.ExpirationDate}
+		}
+		chain[option.ExpirationDate].Options.Option = append(chain[option.ExpirationDate].Options.Option, option)
+	}
+	for _, expiration := range chain {
+		sort.SliceStable(expiration.Options.Option, func(i, j int) bool {
+			return expiration.Options.Option[i].Strike < expiration.Options.Option[j].Strike
+		})
+	}
+	return chain, nil
+}
+
+// option converts the summary to a Tradier option priced in dollars. Instrument names are
+// <currency>-<expiration>-<strike>-<C or P>, e.g. BTC-27JUN25-60000-P.
+func (s summary) option(currency string) (tradier.Option, error) {
+	parts := strings.Split(s.InstrumentName, "-")
+	if len(parts) != 4 {
+		return tradier.Option{}, fmt.Errorf("invalid instrument name %q", s.InstrumentName)
+	}
+	expiration, err := parseExpiration(parts[1])
+	if err != nil {
+		return tradier.Option{}, fmt.Errorf("invalid expiration in instrument name %q", s.InstrumentName)
+	}
+	strike, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return tradier.Option{}, fmt.Errorf("invalid strike in instrument name %q", s.InstrumentName)
+	}
+
+	dollars := func(coin *float64) float64 {
+		if coin == nil {
+			return 0
+		}
+		return *coin * s.UnderlyingPrice
+	}
+	option := tradier.Option{
+		Symbol:         s.InstrumentName,
+		Description:    s.InstrumentName,
+		Exch:           models.InverseExchange,
+		Type:           "option",
+		Last:           tradier.FlexFloat(dollars(s.Last)),
+		Volume:         int(s.Volume),
+		Bid:            dollars(s.BidPrice),
+		Ask:            dollars(s.AskPrice),
+		Underlying:     currency + "-USD",
+		Strike:         strike,
+		OpenInterest:   int(s.OpenInterest),
+		ContractSize:   1,
+		ExpirationDate: expiration.Format(market.DateLayout),
+		OptionType:     "put",
+		RootSymbol:     currency,
+	}
+	if parts[3] == "C" {
+		option.OptionType = "call"
+	}
+	option.Greeks.MidIv = s.MarkIV / 100
+	return option, nil
+}
+
+// parseExpiration reads an instrument name's expiration date, e.g. 27JUN25 or 5JUL25.
+func parseExpiration(code string) (time.Time, error) {
+	if len(code) < 6 {
+		return time.Time{}, fmt.Errorf("invalid expiration %q", code)
+	}
+	month := code[len(code)-5 : len(code)-2]
+	return time.Parse("2Jan06", code[:len(code)-5]+month[:1]+strings.ToLower(month[1:])+code[len(code)-2:])
+}
+
+// get requests path with the query and decodes the result of the JSON-RPC response into v.
+func (c Client) get(path string, query url.Values, v interface{}) error {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Get(strings.TrimSuffix(base, "/") + path + "?" + query.Encode())
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %s", path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response data: %s", path, err)
+	}
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response data: %s", path, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("deribit returned error %d for %s: %s", response.Error.Code, path, response.Error.Message)
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("deribit returned %s for %s", resp.Status, path)
+	}
+	if err := json.Unmarshal(response.Result, v); err != nil {
+		return fmt.Errorf("failed to unmarshal %s result: %s", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package execution
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bcdannyboy/stocd/results"
+)
+
+// Broker submits the orders of spread tickets to a brokerage account.
+type Broker interface {
+	// PreviewOrder validates an order and returns its estimated cost without placing it.
+	PreviewOrder(order Order) (Result, error)
+	// PlaceOrder submits an order.
+	PlaceOrder(order Order) (Result, error)
+	// Positions lists the account's open positions.
+	Positions() ([]Position, error)
+}
+
+// Order is a ticket's limit order for a number of units of the position.
+type Order struct {
+	Underlying string
+	Quantity   int
+	results.TicketOrder
+}
+
+// Result is the broker's response to an order or its preview.
+type Result struct {
+	ID           int64
+	Status       string
+	Commission   float64
+	Fees         float64
+	Cost         float64
+	MarginChange float64
+}
+
+// Position is an open position held in the account.
+type Position struct {
+	Symbol       string
+	Quantity     float64
+	CostBasis    float64
+	DateAcquired string
+}
+
+// NewOrder builds the order for quantity units of a ticket's position, scaling every leg.
+func NewOrder(ticket results.Ticket, quantity int) (Order, error) {
+	if ticket.Underlying == "" || len(ticket.Order.Legs) == 0 {
+		return Order{}, fmt.Errorf("ticket has no underlying or legs")
+	}
+	if quantity < 1 {
+		return Order{}, fmt.Errorf("quantity must be at least 1")
+	}
+	if ticket.Order.Price <= 0 {
+		return Order{}, fmt.Errorf("ticket has no positive limit price")
+	}
+
+	order := Order{Underlying: ticket.Underlying, Quantity: quantity, TicketOrder: ticket.Order}
+	order.Legs = make([]results.TicketLeg, len(ticket.Order.Legs))
+	for i, leg := range ticket.Order.Legs {
+		leg.Quantity *= quantity
+		order.Legs[i] = leg
+	}
+	return order, nil
+}
+
+// ParseTicket reads a ticket as printed with each scan result. Slack's typographic quotes are accepted.
+func ParseTicket(text string) (results.Ticket, error) {
+	text = strings.Trim(strings.TrimSpace(text), "`")
+	text = strings.NewReplacer("“", `"`, "”", `"`).Replace(text)
+
+	var ticket results.Ticket
+	if err := json.Unmarshal([]byte(text), &ticket); err != nil {
+		return results.Ticket{}, fmt.Errorf("failed to parse ticket: %s", err)
+	}
+	return ticket, nil
+}
+
+// Describe summarizes the order, e.g. "AAPL multileg credit 0.65 day: sell_to_open 1 AAPL...P00180000, buy_to_open 1 AAPL...P00175000".
+func (o Order) Describe() string {
+	legs := make([]string, len(o.Legs))
+	for i, leg := range o.Legs {
+		legs[i] = fmt.Sprintf("%s %d %s", leg.Side, leg.Quantity, leg.OptionSymbol)
+	}
+	return fmt.Sprintf("%s %s %s %.2f %s: %s", o.Underlying, o.Class, o.Type, o.Price, o.Duration, strings.Join(legs, ", "))
+}
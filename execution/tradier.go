@@ -0,0 +1,168 @@
+package execution
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultTradierURL = "https://api.tradier.com/v1"     // Tradier's production brokerage API
+	SandboxTradierURL = "https://sandbox.tradier.com/v1" // Tradier's paper trading sandbox
+)
+
+// Tradier places orders through Tradier's brokerage API.
+type Tradier struct {
+	token     string
+	accountID string
+	baseURL   string
+	client    *http.Client
+}
+
+// NewTradier returns a broker for the account, using DefaultTradierURL when baseURL is empty.
+func NewTradier(token, accountID, baseURL string) *Tradier {
+	if baseURL == "" {
+		baseURL = DefaultTradierURL
+	}
+	return &Tradier{token: token, accountID: accountID, baseURL: strings.TrimRight(baseURL, "/"), client: &http.Client{}}
+}
+
+func (t *Tradier) PreviewOrder(order Order) (Result, error) {
+	return t.submit(order, true)
+}
+
+func (t *Tradier) PlaceOrder(order Order) (Result, error) {
+	return t.submit(order, false)
+}
+
+// submit posts the order's form. A single leg is sent as an option order and anything else as multileg.
+func (t *Tradier) submit(order Order, preview bool) (Result, error) {
+	form := url.Values{}
+	form.Set("class", order.Class)
+	form.Set("symbol", order.Underlying)
+	form.Set("type", order.Type)
+	form.Set("duration", order.Duration)
+	form.Set("price", strconv.FormatFloat(order.Price, 'f', 2, 64))
+	if order.Class == "option" {
+		form.Set("option_symbol", order.Legs[0].OptionSymbol)
+		form.Set("side", order.Legs[0].Side)
+		form.Set("quantity", strconv.Itoa(order.Legs[0].Quantity))
+	} else {
+		for i, leg := range order.Legs {
+			form.Set(fmt.Sprintf("option_symbol[%d]", i), leg.OptionSymbol)
+			form.Set(fmt.Sprintf("side[%d]", i), leg.Side)
+			form.Set(fmt.Sprintf("quantity[%d]", i), strconv.Itoa(leg.Quantity))
+		}
+	}
+	if preview {
+		form.Set("preview", "true")
+	}
+
+	var response struct {
+		Order struct {
+			ID           int64   `json:"id"`
+			Status       string  `json:"status"`
+			Commission   float64 `json:"commission"`
+			Fees         float64 `json:"fees"`
+			Cost         float64 `json:"cost"`
+			MarginChange float64 `json:"margin_change"`
+		} `json:"order"`
+	}
+	if err := t.do("POST", "/accounts/"+t.accountID+"/orders", strings.NewReader(form.Encode()), &response); err != nil {
+		return Result{}, err
+	}
+	o := response.Order
+	return Result{ID: o.ID, Status: o.Status, Commission: o.Commission, Fees: o.Fees, Cost: o.Cost, MarginChange: o.MarginChange}, nil
+}
+
+func (t *Tradier) Positions() ([]Position, error) {
+	var response struct {
+		Positions json.RawMessage `json:"positions"`
+	}
+	if err := t.do("GET", "/accounts/"+t.accountID+"/positions", nil, &response); err != nil {
+		return nil, err
+	}
+	// An account without positions returns "positions": "null"
+	if len(response.Positions) == 0 || response.Positions[0] != '{' {
+		return nil, nil
+	}
+	var list struct {
+		Position json.RawMessage `json:"position"`
+	}
+	if err := json.Unmarshal(response.Positions, &list); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal positions: %s", err)
+	}
+
+	type position struct {
+		Symbol       string  `json:"symbol"`
+		Quantity     float64 `json:"quantity"`
+		CostBasis    float64 `json:"cost_basis"`
+		DateAcquired string  `json:"date_acquired"`
+	}
+	var held []position
+	// Tradier returns a bare object instead of an array for a single position
+	data := list.Position
+	if len(data) > 0 && data[0] == '{' {
+		var single position
+		if err := json.Unmarshal(data, &single); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal position: %s", err)
+		}
+		held = append(held, single)
+	} else if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &held); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal positions: %s", err)
+		}
+	}
+
+	positions := make([]Position, len(held))
+	for i, p := range held {
+		positions[i] = Position{Symbol: p.Symbol, Quantity: p.Quantity, CostBasis: p.CostBasis, DateAcquired: p.DateAcquired}
+	}
+	return positions, nil
+}
+
+// do sends an authenticated request and decodes the JSON response into out, turning Tradier's error
+// responses into errors.
+func (t *Tradier) do(method, path string, body io.Reader, out interface{}) error {
+	r, err := http.NewRequest(method, t.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %s", err)
+	}
+	r.Header.Add("Authorization", fmt.Sprintf("Bearer %s", t.token))
+	r.Header.Add("Accept", "application/json")
+	if body != nil {
+		r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := t.client.Do(r)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response data: %s", err)
+	}
+
+	var failure struct {
+		Errors struct {
+			Error json.RawMessage `json:"error"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(data, &failure) == nil && len(failure.Errors.Error) > 0 {
+		return fmt.Errorf("request rejected: %s", string(failure.Errors.Error))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response data: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,405 @@
+package futures
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	DefaultGatewayURL = "https://localhost:5000/v1/api" // Root of a Client Portal Gateway running locally
+
+	strikeRange     = 0.2 // Strikes further than this fraction of the futures price from it are not fetched
+	snapshotBatch   = 50  // Contracts per market data snapshot request
+	snapshotRetries = 3   // Snapshot requests per batch, since the gateway answers the first with empty fields while it subscribes
+
+	// Client Portal market data fields
+	fieldLast          = "31"
+	fieldBid           = "84"
+	fieldAsk           = "86"
+	fieldVolume        = "87"
+	fieldImpliedVol    = "7283"
+	fieldDelta         = "7308"
+	fieldGamma         = "7309"
+	fieldTheta         = "7310"
+	fieldVega          = "7311"
+	fieldOpenInterest  = "7638"
+	snapshotFieldsList = fieldLast + "," + fieldBid + "," + fieldAsk + "," + fieldVolume + "," + fieldImpliedVol + "," + fieldDelta + "," + fieldGamma + "," + fieldTheta + "," + fieldVega + "," + fieldOpenInterest
+)
+
+// Client fetches futures and futures options market data from Interactive Brokers' Client Portal API,
+// through a Client Portal Gateway the user has started and logged in to.
+type Client struct {
+	BaseURL string       // Root of the gateway's API, DefaultGatewayURL when empty
+	HTTP    *http.Client // Sends the requests, http.DefaultClient when nil
+}
+
+// ClientFromEnv returns the client configured by IBKR_GATEWAY_URL. IBKR_GATEWAY_INSECURE=true accepts the
+// self-signed certificate the gateway ships with.
+func ClientFromEnv() Client {
+	c := Client{BaseURL: os.Getenv("IBKR_GATEWAY_URL")}
+	if insecure, _ := strconv.ParseBool(os.Getenv("IBKR_GATEWAY_INSECURE")); insecure {
+		c.HTTP = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	}
+	return c
+}
+
+// MarketData is a futures contract's daily price history and the chain of its options.
+type MarketData struct {
+	Spec       Spec
+	Contract   string // Local symbol of the futures contract the options are on, e.g. ESM5
+	Expiration string // Last trading day of the futures contract
+	Quotes     *tradier.QuoteHistory
+	Chain      map[string]*tradier.OptionChain
+	Price      float64 // Futures price
+}
+
+// MarketData fetches the front futures contract of the symbol, e.g. /ES, its daily history and the options
+// on it between minDTE and maxDTE days to expiration, in Tradier's types so the scan pipeline is unchanged.
+// Only options expiring by the front contract's last trading day are fetched, since later ones are on
+// later contracts.
+func (c Client) MarketData(symbol string, minDTE, maxDTE int, now time.Time) (MarketData, error) {
+	spec, ok := Lookup(symbol)
+	if !ok {
+		return MarketData{}, fmt.Errorf("unknown futures symbol %s, expected one of /%s", symbol, strings.Join(Roots(), ", /"))
+	}
+	front, err := c.frontContract(spec, now)
+	if err != nil {
+		return MarketData{}, err
+	}
+	quotes, err := c.history(front.Conid)
+	if err != nil {
+		return MarketData{}, err
+	}
+	if len(quotes.History.Day) == 0 {
+		return MarketData{}, fmt.Errorf("no price history for %s", symbol)
+	}
+	price := quotes.History.Day[len(quotes.History.Day)-1].Close
+	if snapshots, err := c.snapshots([]int{front.Conid}); err == nil && snapshots[front.Conid].last > 0 {
+		price = snapshots[front.Conid].last
+	}
+
+	data := MarketData{Spec: spec, Contract: front.LocalSymbol, Expiration: front.expiration(), Quotes: quotes, Price: price}
+	data.Chain, err = c.chain(spec, front, price, minDTE, maxDTE, now)
+	if err != nil {
+		return MarketData{}, err
+	}
+	return data, nil
+}
+
+// futuresContract is a futures contract listed by /trsrv/futures.
+type futuresContract struct {
+	Conid           int    `json:"conid"`
+	UnderlyingConid int    `json:"underlyingConid"`
+	LocalSymbol     string `json:"localSymbol"`
+	LastTradingDay  int    `json:"ltd"`
+	ExpirationDate  int    `json:"expirationDate"`
+}
+
+// expiration is the contract's last trading day in market.DateLayout.
+func (f futuresContract) expiration() string {
+	day := f.LastTradingDay
+	if day == 0 {
+		day = f.ExpirationDate
+	}
+	date, err := time.Parse("20060102", strconv.Itoa(day))
+	if err != nil {
+		return ""
+	}
+	return date.Format(market.DateLayout)
+}
+
+// frontContract returns the futures contract of the product nearest expiration that has not expired.
+func (c Client) frontContract(spec Spec, now time.Time) (futuresContract, error) {
+	var listed map[string][]futuresContract
+	if err := c.get("/trsrv/futures", url.Values{"symbols": {spec.Root}}, &listed); err != nil {
+		return futuresContract{}, err
+	}
+	contracts := listed[spec.Root]
+	slices.SortFunc(contracts, func(a, b futuresContract) int {
+		return strings.Compare(a.expiration(), b.expiration())
+	})
+	for _, contract := range contracts {
+		if days, err := market.DaysToExpiration(contract.expiration(), now); err == nil && days >= 0 {
+			return contract, nil
+		}
+	}
+	return futuresContract{}, fmt.Errorf("no %s futures contract listed", spec.Root)
+}
+
+// history fetches the contract's daily bars, about a year for a quarterly contract.
+func (c Client) history(conid int) (*tradier.QuoteHistory, error) {
+	var response struct {
+		Data []struct {
+			Open   float64 `json:"o"`
+			High   float64 `json:"h"`
+			Low    float64 `json:"l"`
+			Close  float64 `json:"c"`
+			Volume float64 `json:"v"`
+			Time   int64   `json:"t"` // Milliseconds since the epoch
+		} `json:"data"`
+	}
+	query := url.Values{"conid": {strconv.Itoa(conid)}, "period": {"2y"}, "bar": {"1d"}}
+	if err := c.get("/iserver/marketdata/history", query, &response); err != nil {
+		return nil, fmt.Errorf("failed to fetch futures history: %s", err)
+	}
+
+	history := &tradier.QuoteHistory{}
+	days := slices.Grow(history.History.Day, len(response.Data))[:len(response.Data)]
+	for i, bar := range response.Data {
+		days[i].Date = time.UnixMilli(bar.Time).In(market.Location).Format(market.DateLayout)
+		days[i].Open, days[i].High, days[i].Low, days[i].Close = bar.Open, bar.High, bar.Low, bar.Close
+		days[i].Volume = int(bar.Volume)
+	}
+	history.History.Day = days
+	return history, nil
+}
+
+// optionContract is a futures option listed by /iserver/secdef/info.
+type optionContract struct {
+	Conid        int     `json:"conid"`
+	Symbol       string  `json:"symbol"`
+	LocalSymbol  string  `json:"localSymbol"`
+	Right        string  `json:"right"`
+	Strike       float64 `json:"strike"`
+	MaturityDate string  `json:"maturityDate"`
+	Multiplier   string  `json:"multiplier"`
+	TradingClass string  `json:"tradingClass"`
+}
+
+// chain fetches the options on the front contract within strikeRange of the price, by expiration date.
+func (c Client) chain(spec Spec, front futuresContract, price float64, minDTE, maxDTE int, now time.Time) (map[string]*tradier.OptionChain, error) {
+	months, err := c.optionMonths(spec, front)
+	if err != nil {
+		return nil, err
+	}
+
+	var contracts []optionContract
+	for _, month := range months {
+		var strikes struct {
+			Call []float64 `json:"call"`
+			Put  []float64 `json:"put"`
+		}
+		query := url.Values{"conid": {strconv.Itoa(front.UnderlyingConid)}, "sectype": {"FOP"}, "month": {month}, "exchange": {spec.Exchange}}
+		if err := c.get("/iserver/secdef/strikes", query, &strikes); err != nil {
+			return nil, fmt.Errorf("failed to fetch %s option strikes: %s", month, err)
+		}
+		for right, list := range map[string][]float64{"C": strikes.Call, "P": strikes.Put} {
+			for _, strike := range list {
+				if strike < price*(1-strikeRange) || strike > price*(1+strikeRange) {
+					continue
+				}
+				var listed []optionContract
+				query := url.Values{"conid": {strconv.Itoa(front.UnderlyingConid)}, "sectype": {"FOP"}, "month": {month},
+					"exchange": {spec.Exchange}, "strike": {strconv.FormatFloat(strike, 'f', -1, 64)}, "right": {right}}
+				if err := c.get("/iserver/secdef/info", query, &listed); err != nil {
+					return nil, fmt.Errorf("failed to fetch %s %s%g options: %s", month, right, strike, err)
+				}
+				contracts = append(contracts, listed...)
+			}
+		}
+	}
+
+	var selected []optionContract
+	for _, contract := range contracts {
+		expiration, err := time.Parse("20060102", contract.MaturityDate)
+		if err != nil || expiration.Format(market.DateLayout) > front.expiration() {
+			continue
+		}
+		days, err := market.DaysToExpiration(expiration.Format(market.DateLayout), now)
+		if err != nil || days < minDTE || days > maxDTE {
+			continue
+		}
+		selected = append(selected, contract)
+	}
+
+	conids := make([]int, len(selected))
+	for i, contract := range selected {
+		conids[i] = contract.Conid
+	}
+	quotes, err := c.snapshots(conids)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make(map[string]*tradier.OptionChain)
+	for _, contract := range selected {
+		option := contract.option(spec, front, quotes[contract.Conid])
+		if chain[option.ExpirationDate] == nil {
+			chain[option.ExpirationDate] = &tradier.OptionChain{ExpirationDate: option.ExpirationDate}
+		}
+		chain[option.ExpirationDate].Options.Option = append(chain[option.ExpirationDate].Options.Option, option)
+	}
+	return chain, nil
+}
+
+// optionMonths returns the months, e.g. JUN25, the product lists options in on the exchange.
+func (c Client) optionMonths(spec Spec, front futuresContract) ([]string, error) {
+	var results []struct {
+		Conid    string `json:"conid"`
+		Sections []struct {
+			SecType  string `json:"secType"`
+			Months   string `json:"months"`
+			Exchange string `json:"exchange"`
+		} `json:"sections"`
+	}
+	if err := c.get("/iserver/secdef/search", url.Values{"symbol": {spec.Root}, "secType": {"FUT"}}, &results); err != nil {
+		return nil, fmt.Errorf("failed to search %s options: %s", spec.Root, err)
+	}
+	for _, result := range results {
+		if result.Conid != strconv.Itoa(front.UnderlyingConid) {
+			continue
+		}
+		for _, section := range result.Sections {
+			if section.SecType == "FOP" && (section.Exchange == "" || strings.Contains(section.Exchange, spec.Exchange)) {
+				return strings.Split(section.Months, ";"), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no %s options listed", spec.Root)
+}
+
+// snapshot is the market data of one contract.
+type snapshot struct {
+	last, bid, ask, impliedVol, delta, gamma, theta, vega float64
+	volume, openInterest                                  int
+}
+
+// snapshots fetches the market data of the contracts, retrying batches the gateway answers before it has
+// subscribed to them.
+func (c Client) snapshots(conids []int) (map[int]snapshot, error) {
+	quotes := make(map[int]snapshot, len(conids))
+	for start := 0; start < len(conids); start += snapshotBatch {
+		batch := conids[start:min(start+snapshotBatch, len(conids))]
+		ids := make([]string, len(batch))
+		for i, conid := range batch {
+			ids[i] = strconv.Itoa(conid)
+		}
+
+		for attempt := 0; attempt < snapshotRetries; attempt++ {
+			var response []map[string]interface{}
+			if err := c.get("/iserver/marketdata/snapshot", url.Values{"conids": {strings.Join(ids, ",")}, "fields": {snapshotFieldsList}}, &response); err != nil {
+				return nil, fmt.Errorf("failed to fetch futures quotes: %s", err)
+			}
+			complete := true
+			for _, fields := range response {
+				conid := int(snapshotNumber(fields["conid"]))
+				if _, ok := fields[fieldBid]; !ok {
+					complete = false
+				}
+				quotes[conid] = snapshot{
+					last: snapshotNumber(fields[fieldLast]), bid: snapshotNumber(fields[fieldBid]), ask: snapshotNumber(fields[fieldAsk]),
+					impliedVol: snapshotNumber(fields[fieldImpliedVol]) / 100, delta: snapshotNumber(fields[fieldDelta]),
+					gamma: snapshotNumber(fields[fieldGamma]), theta: snapshotNumber(fields[fieldTheta]), vega: snapshotNumber(fields[fieldVega]),
+					volume: int(snapshotNumber(fields[fieldVolume])), openInterest: int(snapshotNumber(fields[fieldOpenInterest])),
+				}
+			}
+			if complete && len(response) == len(batch) {
+				break
+			}
+			time.Sleep(time.Second)
+		}
+	}
+	return quotes, nil
+}
+
+// snapshotNumber reads a snapshot field, which the gateway sends as a string with markers such as a C
+// prefix for a prior close, a % suffix or K and M abbreviations. Anything unreadable is zero.
+func snapshotNumber(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		s := strings.TrimPrefix(strings.TrimPrefix(strings.ReplaceAll(v, ",", ""), "C"), "H")
+		scale := 1.0
+		switch {
+		case strings.HasSuffix(s, "K"):
+			s, scale = strings.TrimSuffix(s, "K"), 1e3
+		case strings.HasSuffix(s, "M"):
+			s, scale = strings.TrimSuffix(s, "M"), 1e6
+		}
+		number, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return 0
+		}
+		return number * scale
+	}
+	return 0
+}
+
+// option converts the contract and its quote to a Tradier option on the futures contract. The underlying
+// is the product's slash symbol, which marks it as a futures option, and the contract size its multiplier.
+func (o optionContract) option(spec Spec, front futuresContract, quote snapshot) tradier.Option {
+	expiration, _ := time.Parse("20060102", o.MaturityDate)
+	multiplier, err := strconv.ParseFloat(o.Multiplier, 64)
+	if err != nil || multiplier <= 0 {
+		multiplier = spec.Multiplier
+	}
+	option := tradier.Option{
+		Symbol:         o.LocalSymbol,
+		Description:    fmt.Sprintf("%s %s %g %s", front.LocalSymbol, expiration.Format("Jan 02"), o.Strike, map[string]string{"C": "Call", "P": "Put"}[o.Right]),
+		Exch:           spec.Exchange,
+		Type:           "option",
+		Last:           tradier.FlexFloat(quote.last),
+		Volume:         quote.volume,
+		Bid:            quote.bid,
+		Ask:            quote.ask,
+		Underlying:     "/" + spec.Root,
+		Strike:         o.Strike,
+		OpenInterest:   quote.openInterest,
+		ContractSize:   int(multiplier),
+		ExpirationDate: expiration.Format(market.DateLayout),
+		OptionType:     "put",
+		RootSymbol:     o.TradingClass,
+	}
+	if option.Symbol == "" {
+		option.Symbol = strconv.Itoa(o.Conid)
+	}
+	if o.Right == "C" {
+		option.OptionType = "call"
+	}
+	option.Greeks.Delta, option.Greeks.Gamma, option.Greeks.Theta, option.Greeks.Vega = quote.delta, quote.gamma, quote.theta, quote.vega
+	option.Greeks.MidIv = quote.impliedVol
+	return option
+}
+
+// get requests path with the query from the gateway and decodes the JSON response into v.
+func (c Client) get(path string, query url.Values, v interface{}) error {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultGatewayURL
+	}
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Get(strings.TrimSuffix(base, "/") + path + "?" + query.Encode())
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %s", path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response data: %s", path, err)
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("client portal returned %s for %s: %s", resp.Status, path, string(data[:min(len(data), 200)]))
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response data: %s", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Package futures scans options on futures, such as ES and CL, with market data from Interactive Brokers'
+// Client Portal API in place of Tradier.
+package futures
+
+import (
+	"sort"
+	"strings"
+)
+
+// Spec describes a futures product whose options can be scanned.
+type Spec struct {
+	Root       string  // Futures root, e.g. ES
+	Name       string  // Product name
+	Exchange   string  // Exchange listing the futures and their options
+	Multiplier float64 // Dollars per point of the futures price, the contract multiplier of its options
+	ScanRange  float64 // SPAN price scan range as a fraction of the futures price, the move a margin requirement covers
+}
+
+// Specs are the futures products with options the scanner knows, by root. The scan ranges approximate the
+// exchange's recent SPAN parameters and change with volatility; the broker's requirement is authoritative.
+var Specs = map[string]Spec{
+	"ES":  {Root: "ES", Name: "E-mini S&P 500", Exchange: "CME", Multiplier: 50, ScanRange: 0.06},
+	"MES": {Root: "MES", Name: "Micro E-mini S&P 500", Exchange: "CME", Multiplier: 5, ScanRange: 0.06},
+	"NQ":  {Root: "NQ", Name: "E-mini Nasdaq-100", Exchange: "CME", Multiplier: 20, ScanRange: 0.08},
+	"MNQ": {Root: "MNQ", Name: "Micro E-mini Nasdaq-100", Exchange: "CME", Multiplier: 2, ScanRange: 0.08},
+	"RTY": {Root: "RTY", Name: "E-mini Russell 2000", Exchange: "CME", Multiplier: 50, ScanRange: 0.08},
+	"CL":  {Root: "CL", Name: "Crude Oil", Exchange: "NYMEX", Multiplier: 1000, ScanRange: 0.12},
+	"NG":  {Root: "NG", Name: "Natural Gas", Exchange: "NYMEX", Multiplier: 10000, ScanRange: 0.20},
+	"GC":  {Root: "GC", Name: "Gold", Exchange: "COMEX", Multiplier: 100, ScanRange: 0.06},
+	"SI":  {Root: "SI", Name: "Silver", Exchange: "COMEX", Multiplier: 5000, ScanRange: 0.10},
+	"ZN":  {Root: "ZN", Name: "10-Year T-Note", Exchange: "CBOT", Multiplier: 1000, ScanRange: 0.02},
+	"ZB":  {Root: "ZB", Name: "30-Year T-Bond", Exchange: "CBOT", Multiplier: 1000, ScanRange: 0.04},
+}
+
+// IsSymbol reports whether the symbol names a futures product, written with a leading slash, e.g. /ES.
+func IsSymbol(symbol string) bool {
+	return strings.HasPrefix(strings.TrimSpace(symbol), "/")
+}
+
+// Lookup returns the spec of a futures symbol, /ES or ES.
+func Lookup(symbol string) (Spec, bool) {
+	spec, ok := Specs[strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(symbol), "/"))]
+	return spec, ok
+}
+
+// Roots lists the known futures roots alphabetically.
+func Roots() []string {
+	roots := make([]string, 0, len(Specs))
+	for root := range Specs {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+	return roots
+}
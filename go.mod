@@ -1,9 +1,10 @@
 module github.com/bcdannyboy/stocd
 
-go 1.20
+go 1.21
 
 require (
 	github.com/joho/godotenv v1.5.1
+	github.com/slack-go/slack v0.14.0
 	github.com/xhhuango/json v1.19.0
 	golang.org/x/exp v0.0.0-20231110203233-9a3e6036ecaa
 	gonum.org/v1/gonum v0.15.0
@@ -15,7 +16,6 @@ require (
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/sendgrid/rest v2.6.9+incompatible // indirect
 	github.com/sendgrid/sendgrid-go v3.15.0+incompatible // indirect
-	github.com/slack-go/slack v0.14.0 // indirect
 	github.com/twilio/twilio-go v1.22.3 // indirect
 	golang.org/x/tools v0.15.0 // indirect
 )
@@ -0,0 +1,260 @@
+// Package hedge aggregates the greeks of a book of option positions and suggests the shares and
+// options of a broad ETF, such as SPY or QQQ, that flatten its net delta and optionally its vega.
+package hedge
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/bcdannyboy/stocd/execution"
+	"github.com/bcdannyboy/stocd/monitor"
+	"github.com/bcdannyboy/stocd/paper"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const contractMultiplier = 100 // Shares per option contract when the quote does not say
+
+// Holding is a quantity of a stock, ETF or option, positive when long and negative when short. Options
+// are counted in contracts.
+type Holding struct {
+	Symbol   string
+	Quantity float64
+}
+
+// FromBroker returns the holdings of the brokerage positions.
+func FromBroker(positions []execution.Position) []Holding {
+	holdings := make([]Holding, 0, len(positions))
+	for _, p := range positions {
+		holdings = append(holdings, Holding{Symbol: p.Symbol, Quantity: p.Quantity})
+	}
+	return holdings
+}
+
+// FromPaper returns the option legs of the open paper trades.
+func FromPaper(trades []paper.Trade) []Holding {
+	var holdings []Holding
+	for _, trade := range trades {
+		if trade.Closed {
+			continue
+		}
+		for _, leg := range trade.Legs {
+			holdings = append(holdings, Holding{Symbol: leg.Symbol, Quantity: -float64(leg.Quantity * trade.Quantity)})
+		}
+	}
+	return holdings
+}
+
+// FromMonitor returns the legs of the monitored spreads, which do not record a size, as one contract each.
+func FromMonitor(positions []monitor.Position) []Holding {
+	var holdings []Holding
+	for _, p := range positions {
+		holdings = append(holdings, Holding{Symbol: p.ShortSymbol, Quantity: -1}, Holding{Symbol: p.LongSymbol, Quantity: 1})
+	}
+	return holdings
+}
+
+// Sources of holdings accepted by Holdings.
+const (
+	SourceBroker  = "broker"
+	SourcePaper   = "paper"
+	SourceMonitor = "monitor"
+)
+
+// Holdings returns the holdings of a source: the brokerage positions, the open paper trades or the
+// monitored spreads. The store of the source must not be nil.
+func Holdings(source string, broker execution.Broker, paperStore *paper.Store, monitorStore *monitor.Store) ([]Holding, error) {
+	switch source {
+	case SourceBroker:
+		if broker == nil {
+			return nil, fmt.Errorf("no brokerage account configured")
+		}
+		positions, err := broker.Positions()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch positions: %s", err)
+		}
+		return FromBroker(positions), nil
+	case SourcePaper:
+		if paperStore == nil {
+			return nil, fmt.Errorf("no paper trade store")
+		}
+		return FromPaper(paperStore.List()), nil
+	case SourceMonitor:
+		if monitorStore == nil {
+			return nil, fmt.Errorf("no monitored positions store")
+		}
+		return FromMonitor(monitorStore.List()), nil
+	}
+	return nil, fmt.Errorf("unknown source %q: expected %s, %s or %s", source, SourceBroker, SourcePaper, SourceMonitor)
+}
+
+// Exposure is the net greeks of the holdings of one underlying, in dollars and shares of the underlying.
+type Exposure struct {
+	Underlying string
+	Price      float64
+	Beta       float64 // Sensitivity of the underlying's daily returns to those of the hedge instrument
+	Delta      float64 // Share-equivalent delta: shares of the underlying with the same exposure
+	Gamma      float64 // Change of Delta for a $1 move of the underlying
+	Vega       float64 // Dollars gained per volatility point
+	Theta      float64 // Dollars gained per day
+}
+
+// HedgeDelta returns the exposure's delta in shares of an instrument priced at hedgePrice, weighted by beta.
+func (e Exposure) HedgeDelta(hedgePrice float64) float64 {
+	if hedgePrice <= 0 {
+		return 0
+	}
+	return e.Delta * e.Price * e.Beta / hedgePrice
+}
+
+// Portfolio is the aggregated exposure of a book of holdings.
+type Portfolio struct {
+	Hedge      string // Instrument the book is hedged with
+	HedgePrice float64
+	Exposures  []Exposure // One per underlying, alphabetically
+	Missing    []string   // Holdings without a quote, left out of the exposures
+
+	Delta float64 // Beta-weighted net delta in shares of the hedge instrument
+	Vega  float64 // Net dollars gained per volatility point, assuming the volatilities of all underlyings move together
+	Theta float64 // Net dollars gained per day
+}
+
+// Aggregate sums the greeks of the holdings by underlying from quotes, keyed by symbol, which must include
+// the underlyings and the hedge instrument. betas are keyed by underlying; underlyings without one count
+// with a beta of 1.
+func Aggregate(holdings []Holding, quotes map[string]tradier.Option, betas map[string]float64, hedge string) Portfolio {
+	portfolio := Portfolio{Hedge: hedge, HedgePrice: quotes[hedge].Price()}
+	byUnderlying := make(map[string]*Exposure)
+	exposure := func(underlying string) *Exposure {
+		if e, ok := byUnderlying[underlying]; ok {
+			return e
+		}
+		beta, ok := betas[underlying]
+		if !ok {
+			beta = 1
+		}
+		e := &Exposure{Underlying: underlying, Price: quotes[underlying].Price(), Beta: beta}
+		byUnderlying[underlying] = e
+		return e
+	}
+
+	for _, holding := range holdings {
+		quote, ok := quotes[holding.Symbol]
+		if !ok {
+			portfolio.Missing = append(portfolio.Missing, holding.Symbol)
+			continue
+		}
+		if quote.OptionType == "" {
+			exposure(holding.Symbol).Delta += holding.Quantity
+			continue
+		}
+		multiplier := float64(quote.ContractSize)
+		if multiplier <= 0 {
+			multiplier = contractMultiplier
+		}
+		shares := holding.Quantity * multiplier
+		e := exposure(quote.Underlying)
+		e.Delta += shares * quote.Greeks.Delta
+		e.Gamma += shares * quote.Greeks.Gamma
+		e.Vega += shares * quote.Greeks.Vega
+		e.Theta += shares * quote.Greeks.Theta
+	}
+
+	for _, e := range byUnderlying {
+		portfolio.Exposures = append(portfolio.Exposures, *e)
+		portfolio.Delta += e.HedgeDelta(portfolio.HedgePrice)
+		portfolio.Vega += e.Vega
+		portfolio.Theta += e.Theta
+	}
+	sort.Slice(portfolio.Exposures, func(i, j int) bool {
+		return portfolio.Exposures[i].Underlying < portfolio.Exposures[j].Underlying
+	})
+	return portfolio
+}
+
+// Suggestion is the trade in the hedge instrument that flattens a portfolio.
+type Suggestion struct {
+	Shares    int            // Shares of the hedge instrument to buy, negative to sell short
+	Option    tradier.Option // Option of the hedge instrument that flattens vega, empty when vega is not hedged
+	Contracts int            // Contracts of Option to buy, negative to sell
+
+	ResidualDelta float64 // Net delta left after the hedge, in shares of the hedge instrument
+	ResidualVega  float64 // Net vega left after the hedge, in dollars per volatility point
+}
+
+// Suggest returns the hedge of the portfolio. With options, the options of the hedge instrument, vega is
+// first flattened with the at-the-money call, and the shares then flatten the delta of the portfolio and
+// that call together.
+func Suggest(portfolio Portfolio, options []tradier.Option) Suggestion {
+	suggestion := Suggestion{ResidualDelta: portfolio.Delta, ResidualVega: portfolio.Vega}
+	if option, ok := atTheMoneyCall(options, portfolio.HedgePrice); ok && portfolio.Vega != 0 {
+		multiplier := float64(option.ContractSize)
+		if multiplier <= 0 {
+			multiplier = contractMultiplier
+		}
+		suggestion.Option = option
+		suggestion.Contracts = int(math.Round(-portfolio.Vega / (option.Greeks.Vega * multiplier)))
+		suggestion.ResidualDelta += float64(suggestion.Contracts) * multiplier * option.Greeks.Delta
+		suggestion.ResidualVega += float64(suggestion.Contracts) * multiplier * option.Greeks.Vega
+	}
+	suggestion.Shares = int(math.Round(-suggestion.ResidualDelta))
+	suggestion.ResidualDelta += float64(suggestion.Shares)
+	return suggestion
+}
+
+// atTheMoneyCall returns the quoted call with a vega nearest price.
+func atTheMoneyCall(options []tradier.Option, price float64) (tradier.Option, bool) {
+	var best tradier.Option
+	found := false
+	for _, option := range options {
+		if option.OptionType != "call" || option.Greeks.Vega <= 0 || option.Bid <= 0 {
+			continue
+		}
+		if !found || math.Abs(option.Strike-price) < math.Abs(best.Strike-price) {
+			best, found = option, true
+		}
+	}
+	return best, found
+}
+
+// Describe renders the portfolio's exposures and the suggested hedge.
+func Describe(portfolio Portfolio, suggestion Suggestion) string {
+	var b strings.Builder
+	b.WriteString("Exposure by underlying:\n")
+	for _, e := range portfolio.Exposures {
+		b.WriteString(fmt.Sprintf("  %s at %.2f (beta %.2f): delta %+.1f shares (%+.1f %s), gamma %+.2f, vega %+.2f, theta %+.2f per day\n",
+			e.Underlying, e.Price, e.Beta, e.Delta, e.HedgeDelta(portfolio.HedgePrice), portfolio.Hedge, e.Gamma, e.Vega, e.Theta))
+	}
+	if len(portfolio.Missing) > 0 {
+		b.WriteString(fmt.Sprintf("  No quotes for %s, left out\n", strings.Join(portfolio.Missing, ", ")))
+	}
+	b.WriteString(fmt.Sprintf("Net: %+.1f %s shares of beta-weighted delta, vega %+.2f, theta %+.2f per day\n", portfolio.Delta, portfolio.Hedge, portfolio.Vega, portfolio.Theta))
+
+	b.WriteString("Hedge:\n")
+	if suggestion.Contracts != 0 {
+		b.WriteString(fmt.Sprintf("  %s %d %s (delta %.2f, vega %.2f) to flatten vega\n", side(suggestion.Contracts), abs(suggestion.Contracts), suggestion.Option.Symbol, suggestion.Option.Greeks.Delta, suggestion.Option.Greeks.Vega))
+	}
+	if suggestion.Shares != 0 {
+		b.WriteString(fmt.Sprintf("  %s %d shares of %s at %.2f to flatten delta\n", side(suggestion.Shares), abs(suggestion.Shares), portfolio.Hedge, portfolio.HedgePrice))
+	}
+	if suggestion.Contracts == 0 && suggestion.Shares == 0 {
+		b.WriteString("  None needed\n")
+	}
+	b.WriteString(fmt.Sprintf("Residual: delta %+.1f %s shares, vega %+.2f\n", suggestion.ResidualDelta, portfolio.Hedge, suggestion.ResidualVega))
+	return b.String()
+}
+
+func side(quantity int) string {
+	if quantity < 0 {
+		return "SELL"
+	}
+	return "BUY"
+}
+
+func abs(quantity int) int {
+	if quantity < 0 {
+		return -quantity
+	}
+	return quantity
+}
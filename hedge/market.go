@@ -0,0 +1,182 @@
+package hedge
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	betaYears    = 1  // Years of daily returns the betas are estimated from
+	minBetaDays  = 20 // Common trading days needed to estimate a beta
+	optionMinDTE = 25 // Expirations of the options used to flatten vega
+	optionMaxDTE = 50
+)
+
+// Plan fetches quotes for the holdings, their underlyings and the hedge instrument, estimates the betas
+// of the underlyings to it and aggregates the portfolio. With hedgeVega, the instrument's options 25 to 50
+// days out are fetched to flatten vega as well. Underlyings whose beta cannot be estimated count with a
+// beta of 1.
+func Plan(holdings []Holding, hedge string, hedgeVega bool, token string, now time.Time) (Portfolio, Suggestion, error) {
+	if len(holdings) == 0 {
+		return Portfolio{}, Suggestion{}, fmt.Errorf("no holdings to hedge")
+	}
+	quotes, err := fetchQuotes(holdingSymbols(holdings, hedge), token)
+	if err != nil {
+		return Portfolio{}, Suggestion{}, err
+	}
+
+	// Quote the underlyings of the options as well
+	var underlyings []string
+	for _, quote := range quotes {
+		if quote.OptionType != "" {
+			if _, ok := quotes[quote.Underlying]; !ok && !contains(underlyings, quote.Underlying) {
+				underlyings = append(underlyings, quote.Underlying)
+			}
+		}
+	}
+	if len(underlyings) > 0 {
+		more, err := fetchQuotes(underlyings, token)
+		if err != nil {
+			return Portfolio{}, Suggestion{}, err
+		}
+		for symbol, quote := range more {
+			quotes[symbol] = quote
+		}
+	}
+	if quotes[hedge].Price() <= 0 {
+		return Portfolio{}, Suggestion{}, fmt.Errorf("no quote for %s", hedge)
+	}
+
+	start, end := now.AddDate(-betaYears, 0, 0).Format(market.DateLayout), now.Format(market.DateLayout)
+	benchmark, err := tradier.GET_QUOTES(hedge, start, end, "daily", token)
+	if err != nil {
+		return Portfolio{}, Suggestion{}, fmt.Errorf("failed to fetch %s history: %s", hedge, err)
+	}
+	betas := map[string]float64{hedge: 1}
+	for symbol, quote := range quotes {
+		if quote.OptionType != "" || symbol == hedge {
+			continue
+		}
+		history, err := tradier.GET_QUOTES(symbol, start, end, "daily", token)
+		if err != nil {
+			log.Printf("Error fetching %s history, using a beta of 1: %v", symbol, err)
+			continue
+		}
+		if beta, ok := Beta(*history, *benchmark); ok {
+			betas[symbol] = beta
+		}
+	}
+
+	portfolio := Aggregate(holdings, quotes, betas, hedge)
+	var options []tradier.Option
+	if hedgeVega {
+		chain, err := tradier.GET_OPTIONS_CHAIN(hedge, token, optionMinDTE, optionMaxDTE)
+		if err != nil {
+			return Portfolio{}, Suggestion{}, fmt.Errorf("failed to fetch %s options: %s", hedge, err)
+		}
+		options = nearestExpiration(chain, now)
+	}
+	return portfolio, Suggest(portfolio, options), nil
+}
+
+// Beta is the slope of the asset's daily log returns on the benchmark's over their common days. It is
+// not ok with fewer than minBetaDays common returns.
+func Beta(asset, benchmark tradier.QuoteHistory) (float64, bool) {
+	closes := make(map[string]float64, len(benchmark.History.Day))
+	for _, day := range benchmark.History.Day {
+		closes[day.Date] = day.Close
+	}
+
+	var x, y []float64
+	prevAsset, prevBenchmark := 0.0, 0.0
+	for _, day := range asset.History.Day {
+		benchmarkClose, ok := closes[day.Date]
+		if !ok || benchmarkClose <= 0 || day.Close <= 0 {
+			prevAsset = 0
+			continue
+		}
+		if prevAsset > 0 {
+			x = append(x, math.Log(benchmarkClose/prevBenchmark))
+			y = append(y, math.Log(day.Close/prevAsset))
+		}
+		prevAsset, prevBenchmark = day.Close, benchmarkClose
+	}
+	if len(x) < minBetaDays {
+		return 0, false
+	}
+
+	meanX, meanY := 0.0, 0.0
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(len(x))
+	meanY /= float64(len(y))
+	covariance, variance := 0.0, 0.0
+	for i := range x {
+		covariance += (x[i] - meanX) * (y[i] - meanY)
+		variance += (x[i] - meanX) * (x[i] - meanX)
+	}
+	if variance == 0 {
+		return 0, false
+	}
+	return covariance / variance, true
+}
+
+// nearestExpiration returns the options of the chain's earliest expiration.
+func nearestExpiration(chain map[string]*tradier.OptionChain, now time.Time) []tradier.Option {
+	nearest, nearestDays := "", 0
+	for expiration, options := range chain {
+		days, err := market.DaysToExpiration(expiration, now)
+		if err != nil || options == nil {
+			continue
+		}
+		if nearest == "" || days < nearestDays {
+			nearest, nearestDays = expiration, days
+		}
+	}
+	if nearest == "" {
+		return nil
+	}
+	return chain[nearest].Options.Option
+}
+
+// holdingSymbols returns the distinct symbols of the holdings followed by the hedge instrument.
+func holdingSymbols(holdings []Holding, hedge string) []string {
+	var symbols []string
+	for _, holding := range holdings {
+		if !contains(symbols, holding.Symbol) {
+			symbols = append(symbols, holding.Symbol)
+		}
+	}
+	if !contains(symbols, hedge) {
+		symbols = append(symbols, hedge)
+	}
+	return symbols
+}
+
+func fetchQuotes(symbols []string, token string) (map[string]tradier.Option, error) {
+	quotes, err := tradier.GET_MARKET_QUOTES(symbols, token)
+	if err != nil {
+		return nil, err
+	}
+	bySymbol := make(map[string]tradier.Option, len(quotes))
+	for _, quote := range quotes {
+		bySymbol[quote.Symbol] = quote
+	}
+	return bySymbol, nil
+}
+
+func contains(list []string, symbol string) bool {
+	for _, s := range list {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
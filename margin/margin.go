@@ -0,0 +1,133 @@
+package margin
+
+import (
+	"math"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	equityMove         = 0.15  // Portfolio margin stress range either side for equities and ETFs
+	indexDownMove      = 0.08  // Portfolio margin stress range below spot for broad-based indexes
+	indexUpMove        = 0.06  // Portfolio margin stress range above spot for broad-based indexes
+	stressPoints       = 10    // Equal steps across each side of the stress range
+	minimumPerContract = 0.375 // Portfolio margin minimum of $37.50 per short contract, per share
+	coveredStockMargin = 0.5   // Reg-T initial margin on the shares of a covered call
+)
+
+// Naked estimates the Reg-T requirement per share of a naked short option: 20% of the underlying less the
+// amount out of the money, at least 10% of the strike (puts) or underlying (calls), plus the premium.
+func Naked(option tradier.Option, underlyingPrice, premium float64) float64 {
+	if option.OptionType == "call" {
+		outOfTheMoney := math.Max(0, option.Strike-underlyingPrice)
+		return math.Max(0.2*underlyingPrice-outOfTheMoney, 0.1*underlyingPrice) + premium
+	}
+	outOfTheMoney := math.Max(0, underlyingPrice-option.Strike)
+	return math.Max(0.2*underlyingPrice-outOfTheMoney, 0.1*option.Strike) + premium
+}
+
+// Strangle is the Reg-T requirement of a short strangle or straddle: the larger side's naked requirement
+// plus the premium of the other side, since only one side can be in the money at expiration.
+func Strangle(put, call tradier.Option, underlyingPrice float64) float64 {
+	putMargin := Naked(put, underlyingPrice, put.Bid)
+	callMargin := Naked(call, underlyingPrice, call.Bid)
+	if putMargin >= callMargin {
+		return putMargin + call.Bid
+	}
+	return callMargin + put.Bid
+}
+
+// JadeLizard is the Reg-T requirement of a jade lizard: the larger of the naked put requirement and the
+// call spread's width, plus the premium of the other side.
+func JadeLizard(put, call, longCall tradier.Option, underlyingPrice float64) float64 {
+	putMargin := Naked(put, underlyingPrice, put.Bid)
+	callSpread := longCall.Strike - call.Strike
+	if putMargin >= callSpread {
+		return putMargin + math.Max(0, call.Bid-longCall.Ask)
+	}
+	return callSpread + put.Bid
+}
+
+// Ratio is the Reg-T requirement of a ratio spread: the long option covers one short contract and the
+// rest are naked.
+func Ratio(short tradier.Option, shortContracts, underlyingPrice float64) float64 {
+	return (shortContracts - 1) * Naked(short, underlyingPrice, short.Bid)
+}
+
+// RegT returns the Reg-T requirement per share of a position. Vertical spreads need their width, a
+// cash-secured put its strike and a covered call half the stock price; declared strategies use their
+// worst expiration loss plus the credit.
+func RegT(spread models.OptionSpread, underlyingPrice float64) float64 {
+	if len(spread.Legs) > 0 {
+		return spread.Margin
+	}
+
+	short := spread.ShortLeg.Option
+	switch spread.SpreadType {
+	case "Cash-Secured Put":
+		return short.Strike
+	case "Covered Call":
+		return coveredStockMargin * spread.StockPrice
+	case "Short Strangle", "Short Straddle":
+		return Strangle(short, spread.CallLeg.Option, underlyingPrice)
+	case "Put Ratio", "Call Ratio":
+		return Ratio(short, models.ShortContracts(spread), underlyingPrice)
+	case "Jade Lizard":
+		return JadeLizard(short, spread.CallLeg.Option, spread.LongLeg.Option, underlyingPrice)
+	}
+	return math.Abs(short.Strike - spread.LongLeg.Option.Strike)
+}
+
+// BuyingPower is the Reg-T buying power reduction per share: the requirement less the credit received.
+func BuyingPower(spread models.OptionSpread, underlyingPrice float64) float64 {
+	return math.Max(0, RegT(spread, underlyingPrice)-spread.SpreadCredit)
+}
+
+// Portfolio approximates the portfolio margin requirement per share in the manner of OCC's TIMS: the
+// largest loss from revaluing every leg with BSM at its implied volatility across stressed underlying
+// prices, ±15% for equities and -8%/+6% for broad-based indexes, and at least $37.50 per short contract.
+func Portfolio(spread models.OptionSpread, underlyingPrice, riskFreeRate, tau float64) float64 {
+	down, up := equityMove, equityMove
+	if models.IsIndexOption(spread.ShortLeg.Option) || models.IsIndexRoot(spread.ShortLeg.Option.Underlying) {
+		down, up = indexDownMove, indexUpMove
+	}
+
+	legs := models.PositionLegs(spread)
+	value := func(price float64) float64 {
+		v := 0.0
+		for _, leg := range legs {
+			v -= float64(leg.Quantity) * models.BlackScholesPrice(price, leg.Option.Strike, tau, riskFreeRate, legVolatility(leg.SpreadLeg), leg.Option.OptionType == "call")
+		}
+		if spread.SpreadType == "Covered Call" {
+			v += price
+		}
+		return v
+	}
+
+	current := value(underlyingPrice)
+	worst := 0.0
+	for i := -stressPoints; i <= stressPoints; i++ {
+		move := up * float64(i) / stressPoints
+		if i < 0 {
+			move = down * float64(i) / stressPoints
+		}
+		worst = math.Max(worst, current-value(underlyingPrice*(1+move)))
+	}
+
+	shortContracts := 0
+	for _, leg := range legs {
+		if leg.Quantity > 0 {
+			shortContracts += leg.Quantity
+		}
+	}
+	return math.Max(worst, minimumPerContract*float64(shortContracts))
+}
+
+// legVolatility prefers the leg's BSM implied volatility and falls back to the quoted mid IV.
+func legVolatility(leg models.SpreadLeg) float64 {
+	if leg.BSMResult.ImpliedVolatility > 0 {
+		return leg.BSMResult.ImpliedVolatility
+	}
+	return leg.Option.Greeks.MidIv
+}
@@ -0,0 +1,42 @@
+package margin
+
+import (
+	"math"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+const (
+	spanVolatilityScan = 0.25 // Relative change of the implied volatilities in SPAN's volatility up and down scenarios
+	spanExtremeMove    = 2    // Extreme scenario moves in scan ranges
+	spanExtremeCover   = 0.35 // Share of an extreme scenario's loss SPAN counts
+)
+
+// SPAN approximates the SPAN requirement per unit of the futures price of a position in options on futures:
+// the largest loss over CME's 16 risk scenarios, the futures price unchanged or moved by a third, two thirds
+// and the whole scan range either way with the volatility scanned up and down, and moved twice the range
+// either way with 35% of the loss counted. Options are revalued with Black-76, BSM with no drift, and
+// intercommodity credits and short option minimums are not modeled.
+func SPAN(spread models.OptionSpread, futuresPrice, tau, scanRange float64) float64 {
+	legs := models.PositionLegs(spread)
+	value := func(price, volShift float64) float64 {
+		v := 0.0
+		for _, leg := range legs {
+			vol := legVolatility(leg.SpreadLeg) * (1 + volShift)
+			v -= float64(leg.Quantity) * models.BlackScholesPrice(price, leg.Option.Strike, tau, 0, vol, leg.Option.OptionType == "call")
+		}
+		return v
+	}
+
+	current := value(futuresPrice, 0)
+	worst := 0.0
+	for _, move := range []float64{0, 1.0 / 3, -1.0 / 3, 2.0 / 3, -2.0 / 3, 1, -1} {
+		for _, volShift := range []float64{spanVolatilityScan, -spanVolatilityScan} {
+			worst = math.Max(worst, current-value(futuresPrice*(1+move*scanRange), volShift))
+		}
+	}
+	for _, move := range []float64{spanExtremeMove, -spanExtremeMove} {
+		worst = math.Max(worst, spanExtremeCover*(current-value(futuresPrice*(1+move*scanRange), 0)))
+	}
+	return worst
+}
@@ -0,0 +1,154 @@
+package market
+
+import "time"
+
+const (
+	TradingDaysPerYear = 252 // Trading days a year, annualizing daily volatility and trading-day times to expiration
+
+	earlyClose = 13 * time.Hour // Close of the half days before Independence Day and Christmas and after Thanksgiving, from midnight Eastern
+)
+
+// IsHoliday reports whether the NYSE is closed on the weekday of day's date for a holiday: New Year's
+// Day, Martin Luther King Jr. Day, Washington's Birthday, Good Friday, Memorial Day, Juneteenth (from
+// 2022), Independence Day, Labor Day, Thanksgiving and Christmas. A holiday on a Saturday is observed the
+// Friday before and one on a Sunday the Monday after, except that New Year's Day on a Saturday is not
+// observed. Unscheduled closures, such as days of national mourning, are not included.
+func IsHoliday(day time.Time) bool {
+	y, m, d := day.Date()
+	date := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	for _, holiday := range holidays(y) {
+		if holiday.Equal(date) {
+			return true
+		}
+	}
+	// New Year's Day on a Sunday is observed on January 2nd, and one on a Saturday never
+	return m == time.January && d == 2 && date.Weekday() == time.Monday
+}
+
+// IsTradingDay reports whether the NYSE holds a regular session on day's date.
+func IsTradingDay(day time.Time) bool {
+	weekday := day.Weekday()
+	return weekday != time.Saturday && weekday != time.Sunday && !IsHoliday(day)
+}
+
+// IsEarlyClose reports whether the NYSE closes at 1:00 PM on day's date: July 3rd when Independence Day
+// falls on a weekday after it, the day after Thanksgiving, and Christmas Eve on a weekday before Christmas.
+func IsEarlyClose(day time.Time) bool {
+	if !IsTradingDay(day) {
+		return false
+	}
+	y, m, d := day.Date()
+	switch {
+	case m == time.July && d == 3, m == time.December && d == 24:
+		return true // On a Friday they are the observed holiday instead
+	case m == time.November:
+		return time.Date(y, m, d-1, 0, 0, 0, 0, time.UTC).Equal(nthWeekday(y, time.November, time.Thursday, 4))
+	}
+	return false
+}
+
+// IsOpen reports whether t falls in a regular session, which holidays skip and half days end at 1:00 PM.
+func IsOpen(t time.Time) bool {
+	t = t.In(Location)
+	if !IsTradingDay(t) {
+		return false
+	}
+	y, m, d := t.Date()
+	return !t.Before(time.Date(y, m, d, 0, 0, 0, 0, Location).Add(sessionOpen)) && t.Before(SessionClose(t))
+}
+
+// SessionClose returns the close of the regular session on day's date in New York, 1:00 PM on half days
+// and 4:00 PM otherwise, whether or not the market is open that day.
+func SessionClose(day time.Time) time.Time {
+	y, m, d := day.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, Location)
+	if IsEarlyClose(day) {
+		return start.Add(earlyClose)
+	}
+	return start.Add(sessionClose)
+}
+
+// TradingDaysInYear counts the sessions the NYSE holds in the year, 250 to 253 depending on how the
+// weekends and holidays fall, against the TradingDaysPerYear convention.
+func TradingDaysInYear(year int) int {
+	days := 0
+	for day := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC); day.Year() == year; day = day.AddDate(0, 0, 1) {
+		if IsTradingDay(day) {
+			days++
+		}
+	}
+	return days
+}
+
+// MonthlyExpiration returns the date of the month's standard expiration in New York: the third Friday, or
+// the Thursday before it when the Friday is a holiday, such as Good Friday.
+func MonthlyExpiration(year int, month time.Month) time.Time {
+	third := nthWeekday(year, month, time.Friday, 3)
+	if IsHoliday(third) {
+		third = third.AddDate(0, 0, -1)
+	}
+	return time.Date(third.Year(), third.Month(), third.Day(), 0, 0, 0, 0, Location)
+}
+
+// holidays returns the dates of the year's holidays as observed, at midnight UTC.
+func holidays(year int) []time.Time {
+	easter := easterSunday(year)
+	dates := []time.Time{
+		nthWeekday(year, time.January, time.Monday, 3),  // Martin Luther King Jr. Day
+		nthWeekday(year, time.February, time.Monday, 3), // Washington's Birthday
+		easter.AddDate(0, 0, -2),                        // Good Friday
+		lastWeekday(year, time.May, time.Monday),        // Memorial Day
+		observed(time.Date(year, time.July, 4, 0, 0, 0, 0, time.UTC)),
+		nthWeekday(year, time.September, time.Monday, 1),  // Labor Day
+		nthWeekday(year, time.November, time.Thursday, 4), // Thanksgiving
+		observed(time.Date(year, time.December, 25, 0, 0, 0, 0, time.UTC)),
+	}
+	if newYear := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC); newYear.Weekday() != time.Saturday && newYear.Weekday() != time.Sunday {
+		dates = append(dates, newYear)
+	}
+	if year >= 2022 {
+		dates = append(dates, observed(time.Date(year, time.June, 19, 0, 0, 0, 0, time.UTC)))
+	}
+	return dates
+}
+
+// observed moves a holiday on a Saturday to the Friday before and one on a Sunday to the Monday after.
+func observed(date time.Time) time.Time {
+	switch date.Weekday() {
+	case time.Saturday:
+		return date.AddDate(0, 0, -1)
+	case time.Sunday:
+		return date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+// nthWeekday returns the nth weekday of the month, e.g. the 4th Thursday of November.
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+7*(n-1))
+}
+
+// lastWeekday returns the last weekday of the month, e.g. the last Monday of May.
+func lastWeekday(year int, month time.Month, weekday time.Weekday) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	offset := (int(last.Weekday()) - int(weekday) + 7) % 7
+	return last.AddDate(0, 0, -offset)
+}
+
+// easterSunday returns the date of Easter in the Gregorian calendar (the anonymous Gregorian algorithm).
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
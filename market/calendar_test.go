@@ -0,0 +1,142 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+// nyseHolidays are the NYSE's published full-day closures, without unscheduled ones such as the day of
+// mourning on January 9, 2025.
+var nyseHolidays = map[int][]string{
+	2021: {"2021-01-01", "2021-01-18", "2021-02-15", "2021-04-02", "2021-05-31", "2021-07-05", "2021-09-06", "2021-11-25", "2021-12-24"},
+	2022: {"2022-01-17", "2022-02-21", "2022-04-15", "2022-05-30", "2022-06-20", "2022-07-04", "2022-09-05", "2022-11-24", "2022-12-26"},
+	2023: {"2023-01-02", "2023-01-16", "2023-02-20", "2023-04-07", "2023-05-29", "2023-06-19", "2023-07-04", "2023-09-04", "2023-11-23", "2023-12-25"},
+	2024: {"2024-01-01", "2024-01-15", "2024-02-19", "2024-03-29", "2024-05-27", "2024-06-19", "2024-07-04", "2024-09-02", "2024-11-28", "2024-12-25"},
+	2025: {"2025-01-01", "2025-01-20", "2025-02-17", "2025-04-18", "2025-05-26", "2025-06-19", "2025-07-04", "2025-09-01", "2025-11-27", "2025-12-25"},
+	2026: {"2026-01-01", "2026-01-19", "2026-02-16", "2026-04-03", "2026-05-25", "2026-06-19", "2026-07-03", "2026-09-07", "2026-11-26", "2026-12-25"},
+}
+
+// nyseEarlyCloses are the NYSE's published 1:00 PM closes.
+var nyseEarlyCloses = map[int][]string{
+	2021: {"2021-11-26"},
+	2022: {"2022-11-25"},
+	2023: {"2023-07-03", "2023-11-24"},
+	2024: {"2024-07-03", "2024-11-29", "2024-12-24"},
+	2025: {"2025-07-03", "2025-11-28", "2025-12-24"},
+	2026: {"2026-11-27", "2026-12-24"},
+}
+
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	day, err := time.ParseInLocation(DateLayout, s, Location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return day
+}
+
+func TestCalendarMatchesNYSE(t *testing.T) {
+	for year := 2021; year <= 2026; year++ {
+		holidays := make(map[string]bool)
+		for _, s := range nyseHolidays[year] {
+			holidays[s] = true
+		}
+		earlyCloses := make(map[string]bool)
+		for _, s := range nyseEarlyCloses[year] {
+			earlyCloses[s] = true
+		}
+
+		sessions := 0
+		for day := time.Date(year, time.January, 1, 0, 0, 0, 0, Location); day.Year() == year; day = day.AddDate(0, 0, 1) {
+			s := day.Format(DateLayout)
+			weekend := day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+			if got := IsHoliday(day) && !weekend; got != holidays[s] {
+				t.Errorf("IsHoliday(%s) = %v, want %v", s, got, holidays[s])
+			}
+			if got := IsEarlyClose(day); got != earlyCloses[s] {
+				t.Errorf("IsEarlyClose(%s) = %v, want %v", s, got, earlyCloses[s])
+			}
+			if !weekend && !holidays[s] {
+				sessions++
+			}
+		}
+		if got := TradingDaysInYear(year); got != sessions {
+			t.Errorf("TradingDaysInYear(%d) = %d, want %d", year, got, sessions)
+		}
+	}
+}
+
+func TestIsHoliday(t *testing.T) {
+	tests := []struct {
+		day  string
+		want bool
+		why  string
+	}{
+		{"2021-12-31", false, "New Year's Day 2022 on a Saturday is not observed"},
+		{"2022-01-03", false, "New Year's Day on a Saturday is not moved to the Monday"},
+		{"2023-01-02", true, "New Year's Day 2023 on a Sunday is observed the Monday"},
+		{"2021-06-18", false, "Juneteenth is a holiday only from 2022"},
+		{"2022-06-20", true, "Juneteenth 2022 on a Sunday is observed the Monday"},
+		{"2021-07-05", true, "Independence Day on a Sunday is observed the Monday"},
+		{"2026-07-03", true, "Independence Day on a Saturday is observed the Friday"},
+		{"2021-12-24", true, "Christmas on a Saturday is observed the Friday"},
+		{"2022-12-26", true, "Christmas on a Sunday is observed the Monday"},
+		{"2019-04-19", true, "Good Friday"},
+		{"2008-03-21", true, "Good Friday of an early Easter"},
+		{"2038-04-23", true, "Good Friday of the latest Easter"},
+		{"2024-04-01", false, "Easter Monday is a trading day"},
+	}
+	for _, tt := range tests {
+		if got := IsHoliday(date(t, tt.day)); got != tt.want {
+			t.Errorf("IsHoliday(%s) = %v, want %v: %s", tt.day, got, tt.want, tt.why)
+		}
+	}
+}
+
+func TestIsEarlyClose(t *testing.T) {
+	tests := []struct {
+		day  string
+		want bool
+	}{
+		{"2023-07-03", true},  // Monday before Independence Day on a Tuesday
+		{"2026-07-03", false}, // The observed Independence Day
+		{"2021-12-24", false}, // The observed Christmas
+		{"2022-12-23", false}, // Friday before Christmas on a Sunday
+		{"2025-12-24", true},
+		{"2025-11-28", true}, // Day after Thanksgiving
+		{"2025-11-26", false},
+	}
+	for _, tt := range tests {
+		day := date(t, tt.day)
+		if got := IsEarlyClose(day); got != tt.want {
+			t.Errorf("IsEarlyClose(%s) = %v, want %v", tt.day, got, tt.want)
+		}
+		wantClose := 16
+		if tt.want {
+			wantClose = 13
+		}
+		if got := SessionClose(day).Hour(); got != wantClose {
+			t.Errorf("SessionClose(%s) at %d:00, want %d:00", tt.day, got, wantClose)
+		}
+	}
+}
+
+func TestMonthlyExpiration(t *testing.T) {
+	tests := []struct {
+		year  int
+		month time.Month
+		want  string
+	}{
+		{2024, time.April, "2024-04-19"},
+		{2022, time.April, "2022-04-14"}, // Good Friday on the third Friday
+		{2025, time.April, "2025-04-17"}, // Good Friday on the third Friday
+		{2026, time.June, "2026-06-18"},  // Juneteenth on the third Friday
+		{2021, time.June, "2021-06-18"},  // Juneteenth on the third Friday before it was a holiday
+		{2025, time.December, "2025-12-19"},
+	}
+	for _, tt := range tests {
+		if got := MonthlyExpiration(tt.year, tt.month).Format(DateLayout); got != tt.want {
+			t.Errorf("MonthlyExpiration(%d, %s) = %s, want %s", tt.year, tt.month, got, tt.want)
+		}
+	}
+}
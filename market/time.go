@@ -0,0 +1,105 @@
+package market
+
+import (
+	"time"
+	_ "time/tzdata" // Embed the zone database so America/New_York resolves on any host
+)
+
+const (
+	DateLayout   = "2006-01-02"
+	sessionOpen  = 9*time.Hour + 30*time.Minute // Regular session open, from midnight Eastern
+	sessionClose = 16 * time.Hour               // Regular session close, from midnight Eastern, when equity options stop trading on expiration day
+)
+
+// Location is the exchange timezone all expiry and DTE math is done in, regardless of
+// the timezone of the host running the bot.
+var Location = loadLocation()
+
+func loadLocation() *time.Location {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.FixedZone("EST", -5*60*60)
+	}
+	return location
+}
+
+// Now returns the current time in the market timezone.
+func Now() time.Time {
+	return time.Now().In(Location)
+}
+
+// Today returns the current market date as YYYY-MM-DD.
+func Today() string {
+	return Now().Format(DateLayout)
+}
+
+// ParseExpiration returns the close of trading in New York on an expiration date, 1:00 PM on half days.
+func ParseExpiration(date string) (time.Time, error) {
+	day, err := time.ParseInLocation(DateLayout, date, Location)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return SessionClose(day), nil
+}
+
+// DaysToExpiration counts the calendar days from now's market date to the expiration date,
+// so an option expiring today is 0 DTE wherever the host is.
+func DaysToExpiration(date string, now time.Time) (int, error) {
+	expiration, err := time.ParseInLocation(DateLayout, date, time.UTC)
+	if err != nil {
+		return 0, err
+	}
+	y, m, d := now.In(Location).Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return int(expiration.Sub(today).Hours() / 24), nil
+}
+
+// YearsToExpiration is the time from now until the expiration close, in years.
+func YearsToExpiration(date string, now time.Time) (float64, error) {
+	expiration, err := ParseExpiration(date)
+	if err != nil {
+		return 0, err
+	}
+	return expiration.Sub(now).Hours() / 24 / 365, nil
+}
+
+// TradingDaysToExpiration is the time from now until the expiration close in trading days: the part of
+// today's regular session still to come, all of it before the open and none after the close or when the
+// market is closed, plus a whole day for every session after today up to the expiration, skipping weekends
+// and exchange holidays. An option expiring at this afternoon's close is a fraction of a day out, and one
+// that has expired zero.
+func TradingDaysToExpiration(date string, now time.Time) (float64, error) {
+	expiration, err := time.ParseInLocation(DateLayout, date, Location)
+	if err != nil {
+		return 0, err
+	}
+	now = now.In(Location)
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, Location)
+	if expiration.Before(today) {
+		return 0, nil
+	}
+
+	days := 0.0
+	if IsTradingDay(today) {
+		start, end := today.Add(sessionOpen), SessionClose(today)
+		remaining := end.Sub(now)
+		if now.Before(start) {
+			remaining = end.Sub(start)
+		}
+		days = max(float64(remaining)/float64(end.Sub(start)), 0)
+	}
+	for day := today.AddDate(0, 0, 1); !day.After(expiration); day = day.AddDate(0, 0, 1) {
+		if IsTradingDay(day) {
+			days++
+		}
+	}
+	return days, nil
+}
+
+// TradingYearsToExpiration is TradingDaysToExpiration in years of TradingDaysPerYear, the time to
+// expiration matching volatilities annualized from daily returns.
+func TradingYearsToExpiration(date string, now time.Time) (float64, error) {
+	days, err := TradingDaysToExpiration(date, now)
+	return days / TradingDaysPerYear, err
+}
@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	spanQueueSize   = 4096
+	spanBatchSize   = 256
+	spanFlushPeriod = 5 * time.Second
+)
+
+// tracer exports finished spans to an OTLP/HTTP collector.
+type tracer struct {
+	endpoint string
+	service  string
+	spans    chan *Span
+	client   *http.Client
+}
+
+var activeTracer atomic.Pointer[tracer]
+
+// EnableTracing exports spans to the OTLP/HTTP collector at endpoint, e.g. http://localhost:4318, as JSON
+// under the given service name. Spans are batched and dropped when the collector falls behind.
+func EnableTracing(endpoint, service string) {
+	t := &tracer{
+		endpoint: strings.TrimRight(endpoint, "/") + "/v1/traces",
+		service:  service,
+		spans:    make(chan *Span, spanQueueSize),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	activeTracer.Store(t)
+	go t.run()
+}
+
+// Span times one operation of a trace. Spans are only recorded while tracing is enabled; otherwise
+// StartSpan returns nil and every method is a no-op.
+type Span struct {
+	name       string
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        error
+}
+
+// StartSpan starts a span, as a child of parent when it is not nil.
+func StartSpan(name string, parent *Span) *Span {
+	if activeTracer.Load() == nil {
+		return nil
+	}
+	s := &Span{name: name, start: time.Now(), attributes: make(map[string]string)}
+	if parent != nil {
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return s
+}
+
+// SetAttribute records a string attribute of the span.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.attributes[key] = value
+}
+
+// End finishes the span, marking it failed when err is not nil, and queues it for export.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.end, s.err = time.Now(), err
+	t := activeTracer.Load()
+	if t == nil {
+		return
+	}
+	select {
+	case t.spans <- s:
+	default:
+	}
+}
+
+func (t *tracer) run() {
+	ticker := time.NewTicker(spanFlushPeriod)
+	defer ticker.Stop()
+	var batch []*Span
+	for {
+		select {
+		case s := <-t.spans:
+			batch = append(batch, s)
+			if len(batch) < spanBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := t.export(batch); err != nil {
+			log.Printf("Error exporting %d spans: %v", len(batch), err)
+		}
+		batch = nil
+	}
+}
+
+// export posts the spans as an OTLP ExportTraceServiceRequest in its JSON encoding.
+func (t *tracer) export(spans []*Span) error {
+	otlpSpans := make([]map[string]interface{}, len(spans))
+	for i, s := range spans {
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.spanID[:]),
+			"name":              s.name,
+			"kind":              1, // SPAN_KIND_INTERNAL
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attributes),
+		}
+		if s.parentID != [8]byte{} {
+			span["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != nil {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.err.Error()} // STATUS_CODE_ERROR
+		}
+		otlpSpans[i] = span
+	}
+	request := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]string{"service.name": t.service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "github.com/bcdannyboy/stocd"},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spans: %s", err)
+	}
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post spans: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func otlpAttributes(attributes map[string]string) []interface{} {
+	keys := sortedKeys(attributes)
+	otlp := make([]interface{}, len(keys))
+	for i, key := range keys {
+		otlp[i] = map[string]interface{}{
+			"key":   key,
+			"value": map[string]interface{}{"stringValue": attributes[key]},
+		}
+	}
+	return otlp
+}
@@ -0,0 +1,22 @@
+package models
+
+import "math"
+
+// BlackScholesPrice prices a European option with the Black-Scholes-Merton formula.
+// At or past expiration it returns the intrinsic value.
+func BlackScholesPrice(s, k, t, r, sigma float64, isCall bool) float64 {
+	if t <= 0 || sigma <= 0 {
+		if isCall {
+			return math.Max(s-k, 0)
+		}
+		return math.Max(k-s, 0)
+	}
+
+	d1 := (math.Log(s/k) + (r+0.5*sigma*sigma)*t) / (sigma * math.Sqrt(t))
+	d2 := d1 - sigma*math.Sqrt(t)
+
+	if isCall {
+		return s*mathPhi(d1) - k*math.Exp(-r*t)*mathPhi(d2)
+	}
+	return k*math.Exp(-r*t)*mathPhi(-d2) - s*mathPhi(-d1)
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// OptionQuote is the market price of an option, a target of model calibration.
+type OptionQuote struct {
+	Strike float64
+	T      float64 // Years to expiration
+	Price  float64 // Mid price
+	IsCall bool
+}
+
+// CallPrice returns the price of the call with the quote's strike and expiration, converting a put's
+// price with put-call parity.
+func (q OptionQuote) CallPrice(s0, r float64) float64 {
+	if q.IsCall {
+		return q.Price
+	}
+	return q.Price + s0 - q.Strike*math.Exp(-r*q.T)
+}
+
+// PoorFitMAPE is the mean absolute pricing error, as a fraction of the market prices, above which a
+// calibration is considered poor.
+const PoorFitMAPE = 0.25
+
+// ModelFit measures how well a calibrated model reproduces the option prices it was fitted to.
+type ModelFit struct {
+	Model      string
+	Quotes     int            // Options fitted, 0 when the model was not calibrated
+	RMSE       float64        // Root mean squared pricing error, in dollars per share
+	MAPE       float64        // Mean absolute pricing error as a fraction of the market prices
+	Converged  bool           // Whether the optimizer converged before its iteration limit
+	Parameters []ParameterFit `json:",omitempty"`
+}
+
+// ParameterFit is a calibrated parameter with its approximate standard error, from the curvature of the
+// pricing error in that parameter alone. Large errors relative to the value mean the prices barely
+// determine the parameter.
+type ParameterFit struct {
+	Name     string
+	Value    float64
+	StdError float64 // +Inf when the pricing error is flat in the parameter
+}
+
+// Poor reports whether the calibration should not be trusted because the model misprices the options by
+// more than PoorFitMAPE on average. An optimizer stopped by its iteration limit may still fit well.
+func (f ModelFit) Poor() bool {
+	return f.Quotes > 0 && f.MAPE > PoorFitMAPE
+}
+
+// Describe summarizes the fit on one line.
+func (f ModelFit) Describe() string {
+	if f.Quotes == 0 {
+		return fmt.Sprintf("%s: not calibrated", f.Model)
+	}
+	status := "converged"
+	if !f.Converged {
+		status = "did not converge"
+	}
+	var params []string
+	for _, p := range f.Parameters {
+		params = append(params, fmt.Sprintf("%s %.4f ± %.4f", p.Name, p.Value, p.StdError))
+	}
+	msg := fmt.Sprintf("%s: %d options, RMSE %.4f, MAPE %.1f%%, %s (%s)", f.Model, f.Quotes, f.RMSE, f.MAPE*100, status, strings.Join(params, ", "))
+	if f.Poor() {
+		msg += ", poor fit"
+	}
+	return msg
+}
+
+// newModelFit measures the fit of prices, the model's call prices, to targets, the quotes' call prices.
+// x are the calibrated parameters and objective the mean squared error minimized over them.
+func newModelFit(model string, quotes []OptionQuote, targets, prices []float64, converged bool, names []string, x []float64, objective func([]float64) float64) ModelFit {
+	fit := ModelFit{Model: model, Quotes: len(quotes), Converged: converged}
+	mse := meanSquaredError(prices, targets)
+	fit.RMSE = math.Sqrt(mse)
+	for i, q := range quotes {
+		// Puts were converted to calls by parity, which leaves the error unchanged, so compare it with the quoted price
+		fit.MAPE += math.Abs(prices[i]-targets[i]) / q.Price
+	}
+	fit.MAPE /= float64(len(quotes))
+
+	// Least squares covariance s^2 (J'J)^-1 with J'J approximated by n/2 times the diagonal of the Hessian
+	// of the mean squared error
+	n, p := float64(len(quotes)), float64(len(x))
+	residualVariance := math.Inf(1)
+	if n > p {
+		residualVariance = n * mse / (n - p)
+	}
+	for i, name := range names {
+		h := 1e-3 * math.Max(math.Abs(x[i]), 1e-3)
+		shifted := append([]float64(nil), x...)
+		shifted[i] = x[i] + h
+		up := objective(shifted)
+		shifted[i] = x[i] - h
+		down := objective(shifted)
+		curvature := (up - 2*mse + down) / (h * h)
+
+		stdError := math.Inf(1)
+		if curvature > 0 {
+			stdError = math.Sqrt(residualVariance * 2 / (n * curvature))
+		}
+		fit.Parameters = append(fit.Parameters, ParameterFit{Name: name, Value: x[i], StdError: stdError})
+	}
+	return fit
+}
+
+// meanSquaredError is the mean squared difference between prices and targets.
+func meanSquaredError(prices, targets []float64) float64 {
+	mse := 0.0
+	for i := range prices {
+		mse += math.Pow(prices[i]-targets[i], 2)
+	}
+	return mse / float64(len(prices))
+}
+
+// callTargets returns the call prices of the quotes.
+func callTargets(quotes []OptionQuote, s0, r float64) []float64 {
+	targets := make([]float64, len(quotes))
+	for i, q := range quotes {
+		targets[i] = q.CallPrice(s0, r)
+	}
+	return targets
+}
+
+// maturities returns the distinct times to expiration of the quotes, shortest first.
+func maturities(quotes []OptionQuote) []float64 {
+	seen := make(map[float64]bool)
+	var times []float64
+	for _, q := range quotes {
+		if !seen[q.T] {
+			seen[q.T] = true
+			times = append(times, q.T)
+		}
+	}
+	sort.Float64s(times)
+	return times
+}
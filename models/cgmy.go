@@ -52,21 +52,38 @@ func mathPhi(x float64) float64 {
 	return 0.5 * (1 + math.Erf(x/math.Sqrt2))
 }
 
-func (cgmy *CGMYProcess) Calibrate(marketPrices []float64, strikes []float64, s0, r, t float64, isCall bool) {
-	objectiveFunc := func(params []float64) float64 {
+const cgmyCalibrationIterations = 500 // Nelder-Mead iterations of a CGMY calibration
+
+// Calibrate fits the process to the market prices of options, which may expire at different times, and
+// reports the quality of the fit. Prices are compared as calls, converting puts with put-call parity,
+// and the process's prices come from its characteristic function by the COS method.
+func (cgmy *CGMYProcess) Calibrate(quotes []OptionQuote, s0, r float64) ModelFit {
+	if len(quotes) == 0 {
+		return ModelFit{Model: "CGMY"}
+	}
+	targets := callTargets(quotes, s0, r)
+	prices := func(params []float64) []float64 {
 		tempCGMY := NewCGMYProcess(math.Abs(params[0]), math.Abs(params[1]), math.Abs(params[2]), math.Abs(params[3]))
-		var mse float64
-		for i, strike := range strikes {
-			modelPrice := tempCGMY.OptionPrice(s0, strike, r, t, isCall, 1000)
-			mse += math.Pow(modelPrice-marketPrices[i], 2)
+		modelPrices := make([]float64, len(quotes))
+		for i, q := range quotes {
+			modelPrices[i] = tempCGMY.AnalyticPrice(s0, q.Strike, r, q.T, true)
 		}
-		return mse / float64(len(strikes))
+		return modelPrices
+	}
+	objectiveFunc := func(params []float64) float64 {
+		mse := meanSquaredError(prices(params), targets)
+		if math.IsNaN(mse) || math.Abs(params[2]) <= 1 || math.Abs(params[3]) >= 2 {
+			return 1e10 // Outside the parameters' domain: the price needs M > 1 and Y < 2
+		}
+		return mse
 	}
 
 	initialGuess := []float64{cgmy.Params.C, cgmy.Params.G, cgmy.Params.M, cgmy.Params.Y}
-	result := NelderMead(objectiveFunc, initialGuess, 1e-6, 1000)
+	result, converged := NelderMead(objectiveFunc, initialGuess, 1e-6, cgmyCalibrationIterations)
 
 	cgmy.Params = CGMYParams{C: math.Abs(result[0]), G: math.Abs(result[1]), M: math.Abs(result[2]), Y: math.Abs(result[3])}
+	x := []float64{cgmy.Params.C, cgmy.Params.G, cgmy.Params.M, cgmy.Params.Y}
+	return newModelFit("CGMY", quotes, targets, prices(x), converged, []string{"C", "G", "M", "Y"}, x, objectiveFunc)
 }
 
 func (p *CGMYProcess) FastOptionPrice(s0, strike, r, t float64, isCall bool) float64 {
@@ -123,7 +140,8 @@ func integrate(f func(float64) float64, a, b float64, n int) float64 {
 	return sum * h
 }
 
-func NelderMead(f func([]float64) float64, start []float64, tol float64, maxIter int) []float64 {
+// NelderMead minimizes f from start, reporting whether the simplex converged within maxIter iterations.
+func NelderMead(f func([]float64) float64, start []float64, tol float64, maxIter int) ([]float64, bool) {
 	n := len(start)
 	simplex := make([][]float64, n+1)
 	simplex[0] = start
@@ -219,11 +237,11 @@ func NelderMead(f func([]float64) float64, start []float64, tol float64, maxIter
 
 		// Check for convergence
 		if math.Abs(values[order[n]]-values[order[0]]) < tol {
-			return best
+			return best, true
 		}
 	}
 
-	return best
+	return best, false
 }
 
 ///////////////////////////
@@ -251,7 +269,15 @@ func (p *CGMYProcess) CharacteristicFunction(u float64) complex128 {
 	return result
 }
 
-func (p *CGMYProcess) SimulatePath(t, dt float64, rng *rand.Rand) []float64 {
+// RandomSource supplies the random draws of a simulated path. *rand.Rand satisfies it, and callers may
+// substitute a source applying variance reduction.
+type RandomSource interface {
+	NormFloat64() float64
+	Float64() float64
+	ExpFloat64() float64
+}
+
+func (p *CGMYProcess) SimulatePath(t, dt float64, rng RandomSource) []float64 {
 	steps := int(t / dt)
 	path := make([]float64, steps+1)
 
@@ -262,7 +288,7 @@ func (p *CGMYProcess) SimulatePath(t, dt float64, rng *rand.Rand) []float64 {
 	return path
 }
 
-func (p *CGMYProcess) SimulateIncrement(dt float64, rng *rand.Rand) float64 {
+func (p *CGMYProcess) SimulateIncrement(dt float64, rng RandomSource) float64 {
 	c, g, m, y := p.Params.C, p.Params.G, p.Params.M, p.Params.Y
 
 	// Use the more stable series representation for small time steps
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// ContractMultiplier is the number of shares a standard equity option contract delivers, converting per
+// share prices and P&L to dollars per contract.
+const ContractMultiplier = 100
+
+// OptionMultiplier returns the shares the option's contract delivers, its contract size, or
+// ContractMultiplier when the chain does not give one.
+func OptionMultiplier(option tradier.Option) float64 {
+	if option.ContractSize > 0 {
+		return float64(option.ContractSize)
+	}
+	return ContractMultiplier
+}
+
+// SpreadMultiplier returns the contract multiplier of the position, that of its short leg.
+func SpreadMultiplier(spread OptionSpread) float64 {
+	if legs := PositionLegs(spread); len(legs) > 0 {
+		return OptionMultiplier(legs[0].Option)
+	}
+	return ContractMultiplier
+}
+
+// IsNonstandard reports whether the option is not a standard contract on 100 shares of its underlying: a
+// mini or other contract of a different size, or a series adjusted for a corporate action, whose
+// deliverable may include cash or other shares and whose root symbol gets a digit, e.g. AAPL1. Options on
+// futures deliver one futures contract and inverse options one coin, and are never nonstandard.
+func IsNonstandard(option tradier.Option) bool {
+	if IsFuturesOption(option) || IsInverseOption(option) {
+		return false
+	}
+	if option.ContractSize > 0 && option.ContractSize != ContractMultiplier {
+		return true
+	}
+	root := strings.TrimSpace(option.RootSymbol)
+	return root != "" && unicode.IsDigit(rune(root[len(root)-1]))
+}
+
+// HasNonstandardLegs reports whether any leg of the position is a nonstandard contract.
+func HasNonstandardLegs(spread OptionSpread) bool {
+	for _, leg := range PositionLegs(spread) {
+		if IsNonstandard(leg.Option) {
+			return true
+		}
+	}
+	return false
+}
+
+// SameDeliverable reports whether every leg of the position delivers the same thing, the same number of
+// shares under the same root symbol, so the legs offset each other.
+func SameDeliverable(spread OptionSpread) bool {
+	legs := PositionLegs(spread)
+	for _, leg := range legs[min(1, len(legs)):] {
+		if OptionMultiplier(leg.Option) != OptionMultiplier(legs[0].Option) || leg.Option.RootSymbol != legs[0].Option.RootSymbol {
+			return false
+		}
+	}
+	return true
+}
+
+// IsFuturesOption reports whether the option is on a futures contract, whose underlying is written with a
+// leading slash, e.g. /ES, and whose contract size is the futures multiplier.
+func IsFuturesOption(option tradier.Option) bool {
+	return strings.HasPrefix(option.Underlying, "/")
+}
+
+// InverseExchange is the exchange of inverse options, quoted and settled in the coin they are on.
+const InverseExchange = "DERIBIT"
+
+// IsInverseOption reports whether the option is an inverse option, one coin of BTC or ETH on Deribit.
+func IsInverseOption(option tradier.Option) bool {
+	return option.Exch == InverseExchange
+}
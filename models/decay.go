@@ -0,0 +1,23 @@
+package models
+
+import "math"
+
+// Decay is the time decay a position earns its seller, per share like the credit.
+type Decay struct {
+	ThetaPerDay      float64 // Value the position loses per calendar day, earned by its seller
+	ThetaVegaRatio   float64 // ThetaPerDay over the position's vega to a one point move in implied volatility; 0 without vega
+	ThetaCreditRatio float64 // ThetaPerDay as a fraction of the credit; 0 without a credit
+}
+
+// SpreadDecay computes the position's decay from its Greeks, which are those of the structure the seller
+// is short, annualized over calendar years.
+func SpreadDecay(spread OptionSpread) Decay {
+	decay := Decay{ThetaPerDay: -spread.Greeks.Theta / 365}
+	if vega := math.Abs(spread.Greeks.Vega) / 100; vega > 0 {
+		decay.ThetaVegaRatio = decay.ThetaPerDay / vega
+	}
+	if spread.SpreadCredit > 0 {
+		decay.ThetaCreditRatio = decay.ThetaPerDay / spread.SpreadCredit
+	}
+	return decay
+}
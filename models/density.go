@@ -0,0 +1,165 @@
+package models
+
+import (
+	"math"
+
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	densityPoints     = 400 // Prices of the density's grid
+	densityWidth      = 6   // Standard deviations of the at-the-money volatility the grid spans either side of spot
+	minDensityStrikes = 5   // Out-of-the-money strikes with implied volatility needed to extract a density
+)
+
+// RiskNeutralDensity is the market-implied distribution of the underlying's price at an expiration.
+type RiskNeutralDensity struct {
+	Prices  []float64 // Evenly spaced grid of prices at expiration
+	Density []float64 // Probability density at each price, integrating to one over the grid
+	CDF     []float64 // Probability of finishing below each price
+}
+
+// ImpliedDensity extracts the risk-neutral density of the underlying's price at an expiration tau years
+// away from its options by Breeden-Litzenberger: the density is e^(rT) times the second derivative of the
+// call price in the strike. Raw quotes are too noisy to differentiate twice, so the implied volatilities of
+// the out-of-the-money options with two-sided quotes are smoothed by a least-squares quadratic in log
+// moneyness, held flat beyond the quoted strikes, and the call price curve is rebuilt from it with
+// Black-Scholes before differencing. Negative densities from an arbitrageable smile are floored at zero and
+// the density renormalized. It is not ok with fewer than minDensityStrikes usable strikes.
+func ImpliedDensity(options []tradier.Option, underlyingPrice, riskFreeRate, tau float64) (RiskNeutralDensity, bool) {
+	if underlyingPrice <= 0 || tau <= 0 {
+		return RiskNeutralDensity{}, false
+	}
+
+	var ks, vols []float64
+	for _, option := range options {
+		otm := (option.OptionType == "put" && option.Strike <= underlyingPrice) || (option.OptionType == "call" && option.Strike > underlyingPrice)
+		if !otm || option.Strike <= 0 || option.Bid <= 0 || option.Ask < option.Bid {
+			continue
+		}
+		iv := option.Greeks.MidIv
+		if iv <= 0 {
+			iv = (option.Greeks.BidIv + option.Greeks.AskIv) / 2
+		}
+		if iv <= 0 {
+			continue
+		}
+		ks = append(ks, math.Log(option.Strike/underlyingPrice))
+		vols = append(vols, iv)
+	}
+	if len(ks) < minDensityStrikes {
+		return RiskNeutralDensity{}, false
+	}
+	smile, ok := fitQuadratic(ks, vols)
+	if !ok {
+		return RiskNeutralDensity{}, false
+	}
+	kMin, kMax := ks[0], ks[0]
+	for _, k := range ks {
+		kMin, kMax = math.Min(kMin, k), math.Max(kMax, k)
+	}
+	vol := func(strike float64) float64 {
+		k := math.Max(kMin, math.Min(kMax, math.Log(strike/underlyingPrice)))
+		return math.Max(smile[0]+smile[1]*k+smile[2]*k*k, 0.01)
+	}
+	call := func(strike float64) float64 {
+		if strike <= 0 {
+			return underlyingPrice
+		}
+		return BlackScholesPrice(underlyingPrice, strike, tau, riskFreeRate, vol(strike), true)
+	}
+
+	spread := densityWidth * vol(underlyingPrice) * math.Sqrt(tau)
+	lo, hi := underlyingPrice*math.Exp(-spread), underlyingPrice*math.Exp(spread)
+	h := (hi - lo) / densityPoints
+	d := RiskNeutralDensity{Prices: make([]float64, densityPoints+1), Density: make([]float64, densityPoints+1), CDF: make([]float64, densityPoints+1)}
+	growth := math.Exp(riskFreeRate * tau)
+	for i := range d.Prices {
+		strike := lo + float64(i)*h
+		d.Prices[i] = strike
+		d.Density[i] = math.Max(growth*(call(strike+h)-2*call(strike)+call(strike-h))/(h*h), 0)
+	}
+
+	total := 0.0
+	for i := 1; i < len(d.Prices); i++ {
+		total += (d.Density[i] + d.Density[i-1]) / 2 * h
+		d.CDF[i] = total
+	}
+	if total <= 0 {
+		return RiskNeutralDensity{}, false
+	}
+	for i := range d.Density {
+		d.Density[i] /= total
+		d.CDF[i] /= total
+	}
+	return d, true
+}
+
+// ProbabilityBelow returns the market-implied probability of finishing below price, interpolating the CDF.
+func (d RiskNeutralDensity) ProbabilityBelow(price float64) float64 {
+	n := len(d.Prices)
+	switch {
+	case n == 0:
+		return 0
+	case price <= d.Prices[0]:
+		return 0
+	case price >= d.Prices[n-1]:
+		return 1
+	}
+	h := d.Prices[1] - d.Prices[0]
+	i := int((price - d.Prices[0]) / h)
+	if i >= n-1 {
+		return 1
+	}
+	w := (price - d.Prices[i]) / h
+	return d.CDF[i] + w*(d.CDF[i+1]-d.CDF[i])
+}
+
+// ProbabilityOfProfit integrates the density over the prices at which the spread is profitable at expiration.
+func (d RiskNeutralDensity) ProbabilityOfProfit(spread OptionSpread) float64 {
+	probability := 0.0
+	for i := 1; i < len(d.Prices); i++ {
+		if IsProfitable(spread, (d.Prices[i]+d.Prices[i-1])/2) {
+			probability += d.CDF[i] - d.CDF[i-1]
+		}
+	}
+	return probability
+}
+
+// fitQuadratic returns the least-squares coefficients a, b, c of y = a + b x + c x^2.
+func fitQuadratic(xs, ys []float64) ([3]float64, bool) {
+	var s [5]float64 // Sums of x^0..x^4
+	var t [3]float64 // Sums of y x^0..x^2
+	for i, x := range xs {
+		p := 1.0
+		for j := 0; j < 5; j++ {
+			s[j] += p
+			if j < 3 {
+				t[j] += ys[i] * p
+			}
+			p *= x
+		}
+	}
+
+	// Solve the normal equations by Cramer's rule
+	m := [3][3]float64{{s[0], s[1], s[2]}, {s[1], s[2], s[3]}, {s[2], s[3], s[4]}}
+	det := determinant(m)
+	if math.Abs(det) < 1e-18 {
+		return [3]float64{}, false
+	}
+	var coefficients [3]float64
+	for col := 0; col < 3; col++ {
+		replaced := m
+		for row := 0; row < 3; row++ {
+			replaced[row][col] = t[row]
+		}
+		coefficients[col] = determinant(replaced) / det
+	}
+	return coefficients, true
+}
+
+func determinant(m [3][3]float64) float64 {
+	return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+}
@@ -0,0 +1,62 @@
+package models
+
+import "math"
+
+// DollarRisk is a position's reward and risk in dollars for one contract of each leg at its quantity,
+// the per share figures times the legs' contract multiplier.
+type DollarRisk struct {
+	MaxProfit           float64    // Best P&L at expiration
+	MaxLoss             float64    // Worst loss at expiration, 0 when UnboundedLoss
+	UnboundedLoss       bool       // The loss grows without bound as the underlying rises
+	VaR95               float64    // Simulated loss exceeded with 5% probability
+	VaR99               float64    // Simulated loss exceeded with 1% probability
+	ExpectedShortfall   float64    // Mean loss beyond VaR95
+	ExpectedShortfall99 float64    // Mean loss beyond VaR99
+	TailRisks           []TailRisk `json:",omitempty"` // TailRisks of the spread in dollars
+}
+
+// NewDollarRisk converts the spread's per share expiration extremes and simulated tail risk to dollars per contract.
+func NewDollarRisk(s SpreadWithProbabilities) DollarRisk {
+	m := SpreadMultiplier(s.Spread)
+	maxProfit, maxLoss, unbounded := ExpirationExtremes(s.Spread)
+	risk := DollarRisk{
+		MaxProfit:           maxProfit * m,
+		MaxLoss:             maxLoss * m,
+		UnboundedLoss:       unbounded,
+		VaR95:               s.VaR95 * m,
+		VaR99:               s.VaR99 * m,
+		ExpectedShortfall:   s.ExpectedShortfall * m,
+		ExpectedShortfall99: s.ExpectedShortfall99 * m,
+	}
+	for _, tail := range s.TailRisks {
+		risk.TailRisks = append(risk.TailRisks, TailRisk{Level: tail.Level, VaR: tail.VaR * m, ExpectedShortfall: tail.ExpectedShortfall * m})
+	}
+	return risk
+}
+
+// ExpirationExtremes returns the position's best P&L and worst loss per share at expiration, found at the
+// strikes and at an underlying price of zero, where its piecewise linear payoff bends or ends. A loss that
+// keeps growing above the highest strike is unbounded and reported as 0.
+func ExpirationExtremes(spread OptionSpread) (maxProfit, maxLoss float64, unboundedLoss bool) {
+	prices := []float64{0}
+	top := 0.0
+	for _, leg := range PositionLegs(spread) {
+		prices = append(prices, leg.Option.Strike)
+		top = math.Max(top, leg.Option.Strike)
+	}
+	if spread.StockPrice > 0 {
+		prices = append(prices, spread.StockPrice)
+		top = math.Max(top, spread.StockPrice)
+	}
+
+	maxProfit, worst := math.Inf(-1), math.Inf(1)
+	for _, price := range prices {
+		payoff := PayoffAtExpiration(spread, price)
+		maxProfit = math.Max(maxProfit, payoff)
+		worst = math.Min(worst, payoff)
+	}
+	if PayoffAtExpiration(spread, 2*top+1) < PayoffAtExpiration(spread, top)-1e-9 {
+		return maxProfit, 0, true
+	}
+	return maxProfit, math.Max(-worst, 0), false
+}
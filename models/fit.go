@@ -0,0 +1,110 @@
+package models
+
+import "math"
+
+// ReturnMoments summarizes the distribution of daily log returns.
+type ReturnMoments struct {
+	Count          int
+	Variance       float64
+	Skewness       float64
+	ExcessKurtosis float64
+}
+
+// HistoricalReturnMoments measures the moments of the daily log returns of prices.
+func HistoricalReturnMoments(prices []float64) ReturnMoments {
+	var returns []float64
+	for i := 1; i < len(prices); i++ {
+		if prices[i-1] > 0 && prices[i] > 0 {
+			returns = append(returns, math.Log(prices[i]/prices[i-1]))
+		}
+	}
+	n := float64(len(returns))
+	if n < 4 {
+		return ReturnMoments{Count: len(returns)}
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= n
+	var m2, m3, m4 float64
+	for _, r := range returns {
+		d := r - mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	m2, m3, m4 = m2/n, m3/n, m4/n
+	if m2 <= 0 {
+		return ReturnMoments{Count: len(returns)}
+	}
+	return ReturnMoments{
+		Count:          len(returns),
+		Variance:       m2,
+		Skewness:       m3 / math.Pow(m2, 1.5),
+		ExcessKurtosis: m4/(m2*m2) - 3,
+	}
+}
+
+// JumpCumulants returns the second to fourth annualized cumulants of the model's jumps.
+func (m *MertonJumpDiffusion) JumpCumulants() [3]float64 {
+	mu, d2 := m.Mu, m.Delta*m.Delta
+	return [3]float64{
+		m.Lambda * (mu*mu + d2),
+		m.Lambda * (mu*mu*mu + 3*mu*d2),
+		m.Lambda * (mu*mu*mu*mu + 6*mu*mu*d2 + 3*d2*d2),
+	}
+}
+
+// JumpCumulants returns the second to fourth annualized cumulants of the model's jumps.
+func (k *KouJumpDiffusion) JumpCumulants() [3]float64 {
+	var c [3]float64
+	if k.Eta1 <= 0 || k.Eta2 <= 0 {
+		return c
+	}
+	factorial := 1.0
+	for n := 2; n <= 4; n++ {
+		factorial *= float64(n)
+		sign := 1.0
+		if n%2 == 1 {
+			sign = -1
+		}
+		c[n-2] = k.Lambda * factorial * (k.P/math.Pow(k.Eta1, float64(n)) + sign*(1-k.P)/math.Pow(k.Eta2, float64(n)))
+	}
+	return c
+}
+
+// JumpCumulants returns the second to fourth annualized cumulants of the process.
+func (p *CGMYProcess) JumpCumulants() [3]float64 {
+	var c [3]float64
+	params := p.Params
+	if params.G <= 0 || params.M <= 0 || params.Y >= 2 {
+		return c
+	}
+	for n := 2; n <= 4; n++ {
+		sign := 1.0
+		if n%2 == 1 {
+			sign = -1
+		}
+		c[n-2] = params.C * math.Gamma(float64(n)-params.Y) * (math.Pow(params.M, params.Y-float64(n)) + sign*math.Pow(params.G, params.Y-float64(n)))
+	}
+	return c
+}
+
+// MomentFitError measures how far the daily returns implied by jumps with the given cumulants, added to a
+// diffusion making up the rest of the historical variance, are from the historical skewness and excess
+// kurtosis. It is the root mean square of the two differences in sample standard errors.
+func MomentFitError(jumpCumulants [3]float64, historical ReturnMoments, dt float64) float64 {
+	if historical.Count < 4 || historical.Variance <= 0 {
+		return 0
+	}
+	variance := math.Max(historical.Variance, jumpCumulants[0]*dt)
+	skewness := jumpCumulants[1] * dt / math.Pow(variance, 1.5)
+	kurtosis := jumpCumulants[2] * dt / (variance * variance)
+
+	n := float64(historical.Count)
+	zSkew := (skewness - historical.Skewness) / math.Sqrt(6/n)
+	zKurt := (kurtosis - historical.ExcessKurtosis) / math.Sqrt(24/n)
+	return math.Sqrt((zSkew*zSkew + zKurt*zKurt) / 2)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"math"
 	"runtime"
 	"sync"
@@ -97,53 +98,150 @@ func (p *HestonCalibrationProblem) objectiveFunction(x []float64) float64 {
 	return mse / float64(len(p.Strikes))
 }
 
-// CalculateOptionPrice calculates the option price using the Heston model
+// CalculateOptionPrice prices a call with the model's characteristic function by the COS method.
 func (h *HestonModel) CalculateOptionPrice(s0, k, r, t float64) float64 {
-	// Implement the Heston option pricing formula here
-	// You can use numerical integration or an approximation method
-	// For simplicity, we'll use a Monte Carlo simulation here
-	numSimulations := 1000
-	prices := h.SimulatePricesBatch(s0, r, t, 252, numSimulations)
-
-	sum := 0.0
-	for _, price := range prices {
-		sum += math.Max(price-k, 0)
+	return h.AnalyticPrice(s0, k, r, t, true)
+}
+
+const (
+	hestonCalibrationEvals  = 1000 // Evaluations of the objective per starting point
+	hestonCalibrationStarts = 4    // Starting points tried before calibration settles for the best fit
+	fellerPenalty           = 1.0  // Relative increase of the pricing error per unit of relative Feller violation
+)
+
+// hestonBounds are the lower and upper bounds of V0, Kappa, Theta, Xi and Rho enforced during calibration.
+var hestonBounds = [5][2]float64{
+	{1e-4, 4},       // V0
+	{1e-2, 20},      // Kappa
+	{1e-4, 4},       // Theta
+	{1e-2, 5},       // Xi
+	{-0.999, 0.999}, // Rho
+}
+
+// FellerSatisfied reports whether 2 Kappa Theta >= Xi^2, under which the variance process stays positive.
+func (h *HestonModel) FellerSatisfied() bool {
+	return 2*h.Kappa*h.Theta >= h.Xi*h.Xi
+}
+
+// fellerViolation is how far the parameters violate the Feller condition, relative to Xi^2, or 0.
+func (h *HestonModel) fellerViolation() float64 {
+	return math.Max(0, (h.Xi*h.Xi-2*h.Kappa*h.Theta)/(h.Xi*h.Xi))
+}
+
+// Calibrate fits the model to the market prices of options, which may expire at different times, and
+// reports the quality of the fit. Prices are compared as calls, converting puts with put-call parity,
+// and the model's prices come from its characteristic function by the COS method, so the objective is
+// fast and deterministic in the parameters.
+//
+// The parameters are kept within hestonBounds by optimizing their logistic transforms, and violating the
+// Feller condition is penalized. Calibration starts from the model's parameters and is re-initialized
+// from other starting points, up to hestonCalibrationStarts in all, while the fit is poor, did not
+// converge or violates the Feller condition. The best fit is kept; the model is unchanged on error.
+func (h *HestonModel) Calibrate(quotes []OptionQuote, s0, r float64) (ModelFit, error) {
+	if len(quotes) == 0 {
+		return ModelFit{Model: "Heston"}, fmt.Errorf("no option quotes to calibrate to")
+	}
+	targets := callTargets(quotes, s0, r)
+	pricingError := func(x []float64) float64 {
+		model := NewHestonModel(x[0], x[1], x[2], x[3], x[4])
+		mse := meanSquaredError(model.callPrices(quotes, s0, r), targets)
+		if !model.valid() || math.IsNaN(mse) {
+			return 1e10 // Outside the parameters' domain
+		}
+		return mse
+	}
+	objective := func(u []float64) float64 {
+		x := hestonFromUnbounded(u)
+		return pricingError(x) * (1 + fellerPenalty*NewHestonModel(x[0], x[1], x[2], x[3], x[4]).fellerViolation())
 	}
 
-	return math.Exp(-r*t) * sum / float64(numSimulations)
+	var best *HestonModel
+	var bestFit ModelFit
+	var bestObjective float64
+	var lastErr error
+	for _, start := range h.calibrationStarts() {
+		settings := &optimize.Settings{FuncEvaluations: hestonCalibrationEvals}
+		result, err := optimize.Minimize(optimize.Problem{Func: objective}, hestonToUnbounded(start), settings, &optimize.NelderMead{})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		x := hestonFromUnbounded(result.X)
+		model := NewHestonModel(x[0], x[1], x[2], x[3], x[4])
+		if best == nil || result.F < bestObjective {
+			converged := result.Status == optimize.MethodConverge || result.Status == optimize.FunctionConvergence
+			best, bestObjective = model, result.F
+			bestFit = newModelFit("Heston", quotes, targets, model.callPrices(quotes, s0, r), converged, []string{"V0", "Kappa", "Theta", "Xi", "Rho"}, x, pricingError)
+		}
+		if bestFit.Converged && !bestFit.Poor() && best.FellerSatisfied() {
+			break
+		}
+	}
+	if best == nil {
+		return ModelFit{Model: "Heston"}, lastErr
+	}
+
+	*h = *best
+	return bestFit, nil
 }
 
-func (h *HestonModel) Calibrate(marketPrices, strikes []float64, s0, r, t float64) error {
-	problem := optimize.Problem{
-		Func: func(x []float64) float64 {
-			h.V0 = x[0]
-			h.Kappa = x[1]
-			h.Theta = x[2]
-			h.Xi = x[3]
-			h.Rho = x[4]
-			return h.objectiveFunction(marketPrices, strikes, s0, r, t)
-		},
+// calibrationStarts returns the starting points of calibration: the model's parameters clamped to
+// hestonBounds, then fixed pseudo-random points satisfying the Feller condition.
+func (h *HestonModel) calibrationStarts() [][]float64 {
+	first := []float64{h.V0, h.Kappa, h.Theta, h.Xi, h.Rho}
+	for i := range first {
+		first[i] = math.Max(hestonBounds[i][0], math.Min(hestonBounds[i][1], first[i]))
+	}
+	starts := [][]float64{first}
+
+	rng := rand.New(rand.NewSource(1))
+	for len(starts) < hestonCalibrationStarts {
+		v0 := first[0] * math.Exp(rng.NormFloat64()*0.5)
+		kappa := 0.5 + rng.Float64()*4.5
+		theta := first[2] * math.Exp(rng.NormFloat64()*0.5)
+		xi := math.Sqrt(2*kappa*theta) * (0.3 + 0.6*rng.Float64())
+		rho := -0.9 + 0.8*rng.Float64()
+		start := []float64{v0, kappa, theta, xi, rho}
+		for i := range start {
+			start[i] = math.Max(hestonBounds[i][0], math.Min(hestonBounds[i][1], start[i]))
+		}
+		starts = append(starts, start)
 	}
+	return starts
+}
 
-	result, err := optimize.Minimize(problem, []float64{h.V0, h.Kappa, h.Theta, h.Xi, h.Rho}, nil, &optimize.NelderMead{})
-	if err != nil {
-		return err
+// hestonFromUnbounded maps unconstrained optimizer coordinates to parameters within hestonBounds.
+func hestonFromUnbounded(u []float64) []float64 {
+	x := make([]float64, len(u))
+	for i, ui := range u {
+		lo, hi := hestonBounds[i][0], hestonBounds[i][1]
+		x[i] = lo + (hi-lo)/(1+math.Exp(-ui))
 	}
+	return x
+}
 
-	h.V0 = result.X[0]
-	h.Kappa = result.X[1]
-	h.Theta = result.X[2]
-	h.Xi = result.X[3]
-	h.Rho = result.X[4]
+// hestonToUnbounded is the inverse of hestonFromUnbounded, for parameters strictly within hestonBounds.
+func hestonToUnbounded(x []float64) []float64 {
+	u := make([]float64, len(x))
+	for i, xi := range x {
+		lo, hi := hestonBounds[i][0], hestonBounds[i][1]
+		p := math.Max(1e-9, math.Min(1-1e-9, (xi-lo)/(hi-lo)))
+		u[i] = math.Log(p / (1 - p))
+	}
+	return u
+}
 
-	return nil
+// valid reports whether the parameters are in their domain.
+func (h *HestonModel) valid() bool {
+	return h.V0 > 0 && h.Kappa > 0 && h.Theta > 0 && h.Xi > 0 && math.Abs(h.Rho) < 1
 }
 
-func (h *HestonModel) objectiveFunction(marketPrices, strikes []float64, s0, r, t float64) float64 {
-	mse := 0.0
-	for i, strike := range strikes {
-		modelPrice := h.CalculateOptionPrice(s0, strike, r, t)
-		mse += math.Pow(modelPrice-marketPrices[i], 2)
+// callPrices prices calls at the quotes' strikes and expirations.
+func (h *HestonModel) callPrices(quotes []OptionQuote, s0, r float64) []float64 {
+	callPrices := make([]float64, len(quotes))
+	for i, q := range quotes {
+		callPrices[i] = h.AnalyticPrice(s0, q.Strike, r, q.T, true)
 	}
-	return mse / float64(len(strikes))
+	return callPrices
 }
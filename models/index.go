@@ -0,0 +1,108 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// indexRoots are the option roots of broad-based index options, which are cash settled and Section 1256
+// contracts, mapped to whether they settle at the special opening quotation (SOQ) on the morning of their
+// expiration date rather than at the close.
+var indexRoots = map[string]bool{
+	"SPX": true, "SPXW": false, "XSP": false, "NDX": true, "NDXP": false, "RUT": true, "RUTW": false,
+	"MRUT": false, "DJX": true, "OEX": false, "XEO": false,
+}
+
+// americanIndexRoots are the index roots exercisable before expiration.
+var americanIndexRoots = map[string]bool{"OEX": true}
+
+// optionRoot returns the option's root symbol, or its underlying when the chain gives no root.
+func optionRoot(option tradier.Option) string {
+	if root := strings.TrimSpace(option.RootSymbol); root != "" {
+		return strings.ToUpper(root)
+	}
+	return strings.ToUpper(option.Underlying)
+}
+
+// IsIndexRoot reports whether the option root, e.g. SPXW, is a cash-settled broad-based index option.
+func IsIndexRoot(root string) bool {
+	_, ok := indexRoots[strings.ToUpper(root)]
+	return ok
+}
+
+// IsIndexOption reports whether the option is a cash-settled broad-based index option, such as SPX, XSP or NDX.
+func IsIndexOption(option tradier.Option) bool {
+	return IsIndexRoot(optionRoot(option))
+}
+
+// IsAMSettledRoot reports whether options of the root settle at the opening quotation of their expiration
+// date, having stopped trading the day before, as standard monthly SPX, NDX and RUT options do.
+func IsAMSettledRoot(root string) bool {
+	return indexRoots[strings.ToUpper(root)]
+}
+
+// IsEuropean reports whether the option can only be exercised at expiration, so a short leg carries no
+// early assignment risk: every index option except OEX.
+func IsEuropean(option tradier.Option) bool {
+	root := optionRoot(option)
+	return IsIndexRoot(root) && !americanIndexRoots[root]
+}
+
+// IsCashSettled reports whether every leg of the position settles in cash rather than shares.
+func IsCashSettled(spread OptionSpread) bool {
+	legs := PositionLegs(spread)
+	for _, leg := range legs {
+		if !IsIndexOption(leg.Option) {
+			return false
+		}
+	}
+	return len(legs) > 0 && spread.SpreadType != "Covered Call"
+}
+
+// IsSection1256 reports whether the position is made of Section 1256 contracts, broad-based index options
+// taxed 60% long-term and 40% short-term and marked to market at year end.
+func IsSection1256(spread OptionSpread) bool {
+	return IsCashSettled(spread)
+}
+
+// SettlementStyle describes how the position settles at expiration: "cash-am" for index options settled at
+// the opening quotation, "cash-pm" for those settled at the close, and "physical" for options delivering shares.
+func SettlementStyle(spread OptionSpread) string {
+	if !IsCashSettled(spread) {
+		return "physical"
+	}
+	if IsAMSettledRoot(optionRoot(spread.ShortLeg.Option)) {
+		return "cash-am"
+	}
+	return "cash-pm"
+}
+
+// ExerciseStyle is "european" when no leg of the position can be exercised before expiration, and
+// "american" otherwise.
+func ExerciseStyle(spread OptionSpread) string {
+	for _, leg := range PositionLegs(spread) {
+		if !IsEuropean(leg.Option) {
+			return "american"
+		}
+	}
+	return "european"
+}
+
+// SettlementNote describes an index position's settlement, exercise and tax treatment for reports, and is
+// empty for options on shares.
+func SettlementNote(spread OptionSpread) string {
+	if !IsSection1256(spread) {
+		return ""
+	}
+	note := "cash settled at the close"
+	if SettlementStyle(spread) == "cash-am" {
+		note = "cash settled at the expiration morning's opening quotation, last traded the day before"
+	}
+	if ExerciseStyle(spread) == "european" {
+		note += ", European exercise with no early assignment"
+	} else {
+		note += ", American exercise"
+	}
+	return note + ", Section 1256 (taxed 60% long-term, 40% short-term)"
+}
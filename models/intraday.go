@@ -0,0 +1,39 @@
+package models
+
+import (
+	"math"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const minIntradayReturns = 10 // Returns between bars within sessions needed to estimate intraday volatility
+
+// IntradayVolatility annualizes the realized volatility of intraday bars: the mean squared log return
+// between consecutive closes of the same session, times the returns of the fullest session and
+// market.TradingDaysPerYear sessions a year, so today's session in progress counts by its bars rather than as a whole session.
+// Returns across the overnight gap are left out, so it measures the volatility of the trading hours a
+// short-dated option has left rather than that of whole days. It is zero with fewer than
+// minIntradayReturns returns.
+func IntradayVolatility(bars []tradier.TimeSale) float64 {
+	variance, returns := 0.0, 0
+	sessions := make(map[string]int)
+	for i := 1; i < len(bars); i++ {
+		prev, curr := bars[i-1], bars[i]
+		if len(prev.Time) < 10 || len(curr.Time) < 10 || prev.Time[:10] != curr.Time[:10] || prev.Close <= 0 || curr.Close <= 0 {
+			continue
+		}
+		r := math.Log(curr.Close / prev.Close)
+		variance += r * r
+		returns++
+		sessions[curr.Time[:10]]++
+	}
+	if returns < minIntradayReturns {
+		return 0
+	}
+	perSession := 0
+	for _, n := range sessions {
+		perSession = max(perSession, n)
+	}
+	return math.Sqrt(variance / float64(returns) * float64(perSession) * market.TradingDaysPerYear)
+}
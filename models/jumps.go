@@ -0,0 +1,104 @@
+package models
+
+import (
+	"math"
+)
+
+const (
+	BipowerWindow = 42  // Returns before each return whose bipower variation measures its local volatility
+	JumpThreshold = 4.0 // Local standard deviations beyond which a return is a jump
+)
+
+// Jump is a return detected as a jump.
+type Jump struct {
+	Index    int     // Position of the return
+	Return   float64 // Log return, the jump's size
+	LocalVol float64 // Local standard deviation of returns the jump was measured against
+}
+
+// DetectJumps returns the returns more than JumpThreshold local standard deviations from zero. The local
+// variance of a return is the bipower variation of the BipowerWindow returns before it (Barndorff-Nielsen
+// and Shephard), pi/2 times the mean product of adjacent absolute returns, which unlike the sample variance
+// is not inflated by the jumps themselves and follows volatility regimes, so volatile stretches do not
+// read as runs of jumps and jumps in calm ones are not missed. Returns before a full window are measured
+// against the bipower variation of all the returns.
+func DetectJumps(returns []float64) []Jump {
+	if len(returns) < 3 {
+		return nil
+	}
+	overall := bipowerVariation(returns)
+
+	var jumps []Jump
+	for i, r := range returns {
+		variance := overall
+		if i >= BipowerWindow {
+			variance = bipowerVariation(returns[i-BipowerWindow : i])
+		}
+		if variance <= 0 {
+			continue
+		}
+		vol := math.Sqrt(variance)
+		if math.Abs(r) > JumpThreshold*vol {
+			jumps = append(jumps, Jump{Index: i, Return: r, LocalVol: vol})
+		}
+	}
+	return jumps
+}
+
+// bipowerVariation is the per-return variance of the continuous part of returns, pi/2 times the mean of
+// the products of adjacent absolute returns.
+func bipowerVariation(returns []float64) float64 {
+	if len(returns) < 2 {
+		return 0
+	}
+	sum := 0.0
+	for i := 1; i < len(returns); i++ {
+		sum += math.Abs(returns[i]) * math.Abs(returns[i-1])
+	}
+	return math.Pi / 2 * sum / float64(len(returns)-1)
+}
+
+// JumpTest is the Barndorff-Nielsen and Shephard test for jumps in a series of returns.
+type JumpTest struct {
+	RealizedVariance float64 // Sum of squared returns, continuous and jump variation together
+	BipowerVariation float64 // Continuous variation, robust to jumps
+	RelativeJump     float64 // Share of the realized variance due to jumps, (RV - BV) / RV
+	Z                float64 // Ratio statistic, standard normal without jumps
+}
+
+// Significant reports whether the returns have jumps at the 1% level.
+func (t JumpTest) Significant() bool {
+	return t.Z > 2.326
+}
+
+// TestJumps computes the ratio statistic of the returns' relative jump, scaled by their tripower
+// quarticity. It is zero for fewer than three returns.
+func TestJumps(returns []float64) JumpTest {
+	n := len(returns)
+	if n < 3 {
+		return JumpTest{}
+	}
+
+	rv := 0.0
+	for _, r := range returns {
+		rv += r * r
+	}
+	bv := bipowerVariation(returns) * float64(n)
+
+	// Tripower quarticity, scaled by mu_{4/3}^-3 with mu_{4/3} = E|Z|^(4/3)
+	mu := math.Pow(2, 2.0/3) * math.Gamma(7.0/6) / math.Gamma(0.5)
+	tq := 0.0
+	for i := 2; i < n; i++ {
+		tq += math.Pow(math.Abs(returns[i])*math.Abs(returns[i-1])*math.Abs(returns[i-2]), 4.0/3)
+	}
+	tq *= float64(n) * float64(n) / float64(n-2) / (mu * mu * mu)
+
+	test := JumpTest{RealizedVariance: rv, BipowerVariation: bv}
+	if rv <= 0 || bv <= 0 {
+		return test
+	}
+	test.RelativeJump = (rv - bv) / rv
+	theta := math.Pi*math.Pi/4 + math.Pi - 5
+	test.Z = test.RelativeJump / math.Sqrt(theta*math.Max(1, tq/(bv*bv))/float64(n))
+	return test
+}
@@ -45,6 +45,9 @@ func estimateLambdaAndP(prices []float64, timeStep float64) (float64, float64) {
 	jumps := identifyJumps(returns)
 
 	lambda := float64(len(jumps)) / (float64(len(prices)-1) * timeStep)
+	if len(jumps) == 0 {
+		return 0, 0.5
+	}
 
 	upJumps := 0
 	for _, jump := range jumps {
@@ -71,8 +74,22 @@ func estimateEta1AndEta2(prices []float64) (float64, float64) {
 		}
 	}
 
-	eta1 := 1.0 / calculateMean(upJumps)
-	eta2 := 1.0 / calculateMean(downJumps)
+	// A side without jumps takes the other's rate, and both sides a mean jump of 10% when there are none
+	eta1, eta2 := 0.0, 0.0
+	if len(upJumps) > 0 {
+		eta1 = 1.0 / calculateMean(upJumps)
+	}
+	if len(downJumps) > 0 {
+		eta2 = 1.0 / calculateMean(downJumps)
+	}
+	switch {
+	case eta1 == 0 && eta2 == 0:
+		eta1, eta2 = 10, 10
+	case eta1 == 0:
+		eta1 = eta2
+	case eta2 == 0:
+		eta2 = eta1
+	}
 
 	return eta1, eta2
 }
@@ -86,17 +103,11 @@ func calculateReturns(prices []float64) []float64 {
 	return returns
 }
 
-// identifyJumps detects jumps in returns using a threshold method
+// identifyJumps returns the returns DetectJumps detects as jumps
 func identifyJumps(returns []float64) []float64 {
-	mean := calculateMean(returns)
-	std := calculateStdDeviation(returns, mean)
-	threshold := 3 * std // Use 3 standard deviations as the threshold
-
 	var jumps []float64
-	for _, r := range returns {
-		if math.Abs(r-mean) > threshold {
-			jumps = append(jumps, r)
-		}
+	for _, jump := range DetectJumps(returns) {
+		jumps = append(jumps, jump.Return)
 	}
 	return jumps
 }
@@ -3,8 +3,8 @@ package models
 import (
 	"math"
 	"sort"
-	"time"
 
+	"github.com/bcdannyboy/stocd/market"
 	"github.com/bcdannyboy/stocd/tradier"
 	"golang.org/x/exp/rand"
 )
@@ -21,11 +21,10 @@ func CalculateLocalVolatilitySurface(chain map[string]*tradier.OptionChain, unde
 	var vols [][]float64
 
 	for expDate, expChain := range chain {
-		t, err := time.Parse("2006-01-02", expDate)
+		timeToExpiry, err := market.YearsToExpiration(expDate, market.Now()) // in years
 		if err != nil {
 			continue
 		}
-		timeToExpiry := t.Sub(time.Now()).Hours() / 24 / 365 // in years
 
 		var strikeVols []struct {
 			strike float64
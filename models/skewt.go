@@ -0,0 +1,156 @@
+package models
+
+import (
+	"math"
+)
+
+// SkewT is Hansen's skewed Student-t distribution, standardized to zero mean and unit variance, with Nu
+// degrees of freedom (> 2) and skewness Lambda in (-1, 1). Lambda = 0 is the standardized Student-t;
+// negative Lambda fattens the left tail.
+type SkewT struct {
+	Nu     float64
+	Lambda float64
+}
+
+// RandSource supplies the standard normal and uniform draws SkewT samples from.
+type RandSource interface {
+	NormFloat64() float64
+	Float64() float64
+}
+
+// constants returns Hansen's a, b and c.
+func (d SkewT) constants() (float64, float64, float64) {
+	lgNum, _ := math.Lgamma((d.Nu + 1) / 2)
+	lgDen, _ := math.Lgamma(d.Nu / 2)
+	c := math.Exp(lgNum-lgDen) / math.Sqrt(math.Pi*(d.Nu-2))
+	a := 4 * d.Lambda * c * (d.Nu - 2) / (d.Nu - 1)
+	b := math.Sqrt(1 + 3*d.Lambda*d.Lambda - a*a)
+	return a, b, c
+}
+
+// LogPDF returns the log density at z.
+func (d SkewT) LogPDF(z float64) float64 {
+	a, b, c := d.constants()
+	return d.logPDF(z, a, b, c)
+}
+
+func (d SkewT) logPDF(z, a, b, c float64) float64 {
+	side := 1 + d.Lambda
+	if z < -a/b {
+		side = 1 - d.Lambda
+	}
+	y := (b*z + a) / side
+	return math.Log(b*c) - (d.Nu+1)/2*math.Log1p(y*y/(d.Nu-2))
+}
+
+// Sample draws from the distribution: a unit variance Student-t magnitude is scaled by 1 - Lambda on the
+// left, chosen with probability (1 - Lambda) / 2, or by 1 + Lambda on the right, then standardized.
+func (d SkewT) Sample(src RandSource) float64 {
+	a, b, _ := d.constants()
+	chiSquare := 2 * sampleGamma(d.Nu/2, src)
+	magnitude := math.Abs(src.NormFloat64()/math.Sqrt(chiSquare/d.Nu)) * math.Sqrt((d.Nu-2)/d.Nu)
+	y := (1 + d.Lambda) * magnitude
+	if src.Float64() < (1-d.Lambda)/2 {
+		y = -(1 - d.Lambda) * magnitude
+	}
+	return (y - a) / b
+}
+
+// LogMGF returns log E[exp(s Z)], integrated numerically over |z| <= 30. The Student-t's moment
+// generating function is infinite, so the truncation is what keeps the martingale correction of a
+// simulation with these shocks finite; beyond 30 standard deviations the density is negligible.
+func (d SkewT) LogMGF(s float64) float64 {
+	const limit, step = 30.0, 0.005
+	a, b, c := d.constants()
+	total := 0.0
+	for z := -limit; z <= limit; z += step {
+		total += math.Exp(s*z+d.logPDF(z, a, b, c)) * step
+	}
+	return math.Log(total)
+}
+
+// FitSkewT fits the Student-t and Hansen skew-t to returns, standardized by their mean and standard
+// deviation, by maximum likelihood over a grid of Nu and Lambda refined around the best point. It is not ok
+// with fewer than 30 returns.
+func FitSkewT(returns []float64) (studentT, skewT SkewT, ok bool) {
+	if len(returns) < 30 {
+		return SkewT{}, SkewT{}, false
+	}
+	mean := calculateMean(returns)
+	std := calculateStdDeviation(returns, mean)
+	if std <= 0 {
+		return SkewT{}, SkewT{}, false
+	}
+	z := make([]float64, len(returns))
+	for i, r := range returns {
+		z[i] = (r - mean) / std
+	}
+
+	logLikelihood := func(d SkewT) float64 {
+		a, b, c := d.constants()
+		total := 0.0
+		for _, v := range z {
+			total += d.logPDF(v, a, b, c)
+		}
+		return total
+	}
+	best := func(nus, lambdas []float64) SkewT {
+		var fit SkewT
+		bestLL := math.Inf(-1)
+		for _, nu := range nus {
+			for _, lambda := range lambdas {
+				if nu <= 2.05 || math.Abs(lambda) >= 0.95 {
+					continue
+				}
+				d := SkewT{Nu: nu, Lambda: lambda}
+				if ll := logLikelihood(d); ll > bestLL {
+					fit, bestLL = d, ll
+				}
+			}
+		}
+		return fit
+	}
+
+	nus := []float64{2.2, 2.5, 3, 3.5, 4, 5, 6, 7, 8, 10, 12, 15, 20, 30, 50}
+	var lambdas []float64
+	for lambda := -0.5; lambda <= 0.501; lambda += 0.05 {
+		lambdas = append(lambdas, lambda)
+	}
+	refine := func(fit SkewT, symmetric bool) SkewT {
+		var fineNus, fineLambdas []float64
+		for i := -4; i <= 4; i++ {
+			fineNus = append(fineNus, fit.Nu*math.Pow(1.05, float64(i)))
+		}
+		fineLambdas = []float64{0}
+		if !symmetric {
+			fineLambdas = nil
+			for i := -5; i <= 5; i++ {
+				fineLambdas = append(fineLambdas, fit.Lambda+float64(i)*0.01)
+			}
+		}
+		return best(fineNus, fineLambdas)
+	}
+
+	studentT = refine(best(nus, []float64{0}), true)
+	skewT = refine(best(nus, lambdas), false)
+	return studentT, skewT, studentT.Nu > 0 && skewT.Nu > 0
+}
+
+// sampleGamma draws from the gamma distribution with the shape, at least 1, and unit scale by Marsaglia
+// and Tsang's method.
+func sampleGamma(shape float64, src RandSource) float64 {
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := src.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := src.Float64()
+		if u > 0 && math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
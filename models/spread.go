@@ -1,6 +1,9 @@
 package models
 
 import (
+	"math"
+	"time"
+
 	"github.com/bcdannyboy/stocd/tradier"
 )
 
@@ -15,15 +18,38 @@ type SpreadLeg struct {
 }
 
 type OptionSpread struct {
-	ShortLeg       SpreadLeg
-	LongLeg        SpreadLeg
-	SpreadType     string
-	SpreadCredit   float64
-	SpreadBSMPrice float64
-	ExtrinsicValue float64
-	IntrinsicValue float64
-	Greeks         BSMResult
-	ROR            float64
+	ShortLeg         SpreadLeg
+	LongLeg          SpreadLeg
+	SpreadType       string
+	SpreadCredit     float64
+	CreditAdjustment float64 // Historical fill slippage included in SpreadCredit
+	FillAdjustment   float64 // Credit over the natural bid/ask price from the fill assumption, included in SpreadCredit
+	Fees             float64 // Opening commissions and exchange fees per share deducted from SpreadCredit
+	SpreadBSMPrice   float64
+	ExtrinsicValue   float64
+	IntrinsicValue   float64
+	Greeks           BSMResult
+	ROR              float64
+	CreditWidthRatio float64       // Credit received as a fraction of the strike width, or of the collateral of a single leg
+	StockPrice       float64       // Price paid per share for the stock of a covered call
+	ShortQuantity    int           // Short contracts per long contract, 2 for a 1x2 ratio spread; 0 means 1
+	CallLeg          SpreadLeg     // Short call of a strangle, straddle or jade lizard, whose ShortLeg is the short put
+	Margin           float64       // Estimated Reg-T requirement per share of an undefined-risk position, 0 for others
+	ExpectedMove     float64       // Market-implied one standard deviation move of the underlying to expiration, in dollars
+	ShortStrikeMoves float64       // Distance from the underlying price to the nearest short strike in expected moves
+	Legs             []PositionLeg // Every leg of a declared strategy; empty for the built-in structures
+	BuyingPower      float64       // Reg-T buying power reduction per share: the requirement less the credit
+	PortfolioMargin  float64       // Approximate portfolio margin requirement per share
+	ReturnOnMargin   float64       // Credit per dollar of Reg-T buying power
+	Decay            Decay         // Time decay per share and relative to vega and the credit
+	InversePrice     float64       // Underlying price the coin premium of inverse options, e.g. Deribit's, was converted to dollars at; 0 for options paid in dollars
+}
+
+// PositionLeg is one leg of a declared strategy. Quantity is the number of contracts, positive when
+// short and negative when long.
+type PositionLeg struct {
+	SpreadLeg
+	Quantity int
 }
 
 type BSMResult struct {
@@ -37,6 +63,7 @@ type BSMResult struct {
 	ShadowUpGamma     float64
 	ShadowDownGamma   float64
 	SkewGamma         float64
+	IVError           string `json:",omitempty"` // Why no volatility reproduces the option's mid price, empty when ImpliedVolatility was solved
 }
 
 type VolatilityInfo struct {
@@ -51,16 +78,24 @@ type VolatilityInfo struct {
 }
 
 type SpreadWithProbabilities struct {
-	Spread            OptionSpread
-	VaR95             float64
-	VaR99             float64
-	ExpectedShortfall float64
-	Liquidity         float64
-	CompositeScore    float64
-	Probability       ProbabilityResult
-	MeetsRoR          bool
-	CGMYParams        CGMYParams
-	MertonParams      struct {
+	Spread              OptionSpread
+	VaR95               float64
+	VaR99               float64
+	ExpectedShortfall   float64
+	ExpectedShortfall99 float64    // Mean loss beyond VaR99, per share
+	TailRisks           []TailRisk `json:",omitempty"` // VaR and expected shortfall at each configured confidence level, ascending
+	Dollars             DollarRisk // Max profit, max loss, VaR and expected shortfall in dollars per contract
+	ExpectedValue       float64    // P(win) * max profit - P(loss) * expected shortfall, per share
+	ExpectedProfit      float64    // P(win) * max profit, per share
+	Liquidity           float64
+	VarianceRiskPremium float64 // ATM implied variance of the expiration minus the realized variance forecast to it
+	Breakeven           BreakevenInfo
+	CompositeScore      float64
+	Probability         ProbabilityResult
+	MeetsRoR            bool
+	CGMYParams          CGMYParams
+	ModelFits           []ModelFit // Fits of the models calibrated to option prices for the spread's expiration
+	MertonParams        struct {
 		Lambda float64
 		Mu     float64
 		Delta  float64
@@ -78,13 +113,113 @@ type SpreadWithProbabilities struct {
 		Xi    float64
 		Rho   float64
 	}
-	VolatilityInfo VolatilityInfo
+	VolatilityInfo    VolatilityInfo
+	Scenarios         []ScenarioResult
+	WorstScenarioLoss float64
+	PriceDistribution Histogram
+	PayoffCurve       PayoffCurve
+	Activity          ContractActivity
+	Quotes            QuoteFreshness
+}
+
+// QuoteFreshness is how recently the spread's legs were quoted.
+type QuoteFreshness struct {
+	QuotedAt time.Time     // Last bid or ask of the stalest leg, zero when the chain has no quote times
+	Age      time.Duration // How long before the scan the stalest leg was quoted
+	Stale    bool          // Quoted longer ago than the scan's MaxQuoteAge during market hours
+}
+
+// ContractActivity summarizes how consistently a spread's contracts traded over recent scans.
+type ContractActivity struct {
+	Days               int     // Daily snapshots considered, including the current scan
+	ActiveDays         int     // Days on which every leg traded
+	MedianVolume       float64 // Median of the legs' combined daily volume
+	OpenInterestChange int     // Combined open interest now minus at the oldest snapshot
+}
+
+// PayoffCurve samples the spread's per share P&L over a grid of underlying prices.
+type PayoffCurve struct {
+	Prices        []float64
+	AtExpiration  []float64
+	AtHalfLife    []float64 // Marked with BSM at half the time remaining to expiration
+	HalfLifeDelta []float64 // Position delta per share at half the time remaining
+}
+
+// Histogram bins simulated underlying prices at expiration. Counts[i] covers [Edges[i], Edges[i+1]).
+type Histogram struct {
+	Edges  []float64
+	Counts []int
+}
+
+// TailRisk is the simulated loss of a spread at a confidence level, per share.
+type TailRisk struct {
+	Level             float64 // Confidence level, e.g. 0.95
+	VaR               float64 // Loss exceeded with probability 1 - Level
+	ExpectedShortfall float64 // Mean loss beyond VaR
+}
+
+// TailRiskAt returns the spread's VaR and expected shortfall at the confidence level, from TailRisks or
+// the VaR95 and VaR99 fields, and whether they were computed at that level.
+func (s SpreadWithProbabilities) TailRiskAt(level float64) (TailRisk, bool) {
+	for _, risk := range s.TailRisks {
+		if math.Abs(risk.Level-level) < 1e-9 {
+			return risk, true
+		}
+	}
+	switch {
+	case math.Abs(level-0.95) < 1e-9:
+		return TailRisk{Level: level, VaR: s.VaR95, ExpectedShortfall: s.ExpectedShortfall}, true
+	case math.Abs(level-0.99) < 1e-9:
+		return TailRisk{Level: level, VaR: s.VaR99, ExpectedShortfall: s.ExpectedShortfall99}, true
+	}
+	return TailRisk{}, false
+}
+
+// BreakevenInfo describes where the spread breaks even at expiration relative to the current spot.
+type BreakevenInfo struct {
+	Price       float64 // Underlying price at which the spread neither makes nor loses money at expiration
+	UpperPrice  float64 // Upper breakeven of a strangle or straddle, 0 for one-sided positions
+	DistancePct float64 // Cushion from spot to breakeven as a fraction of spot (negative if already past breakeven)
+	DistanceSD  float64 // Cushion in standard deviations of the log price over the spread's term
+}
+
+// ScenarioResult is the outcome of replaying a historical stress path against a spread.
+type ScenarioResult struct {
+	Name       string
+	WorstLoss  float64 // Largest marked loss per share over the replay (positive = loss)
+	WorstDay   int     // Trading day of the replay on which the worst loss occurred
+	FinalPnL   float64 // Marked P&L per share at the end of the replay
+	SpotChange float64 // Total underlying move applied by the scenario
 }
 
 type ProbabilityResult struct {
 	Probabilities      map[string]float64
 	AverageProbability float64
+	StandardErrors     map[string]float64            // Monte Carlo standard error of each probability
+	Weights            map[string]float64            // Weight of each probability in AverageProbability, summing to one
+	Intervals          map[string]ConfidenceInterval // 95% confidence interval of each probability
+	StandardError      float64                       // Monte Carlo standard error of AverageProbability
+	AverageInterval    ConfidenceInterval            // 95% confidence interval of AverageProbability
+	MarketImplied      float64                       // Probability of profit under the chain's risk-neutral density, 0 when it could not be extracted
+	Bootstrap          float64                       // Probability of profit of the historical block bootstrap, 0 when the history is too short
 }
+
+// ConfidenceInterval bounds a probability estimate.
+type ConfidenceInterval struct {
+	Lower float64
+	Upper float64
+}
+
+// NewConfidenceInterval is the normal approximation 95% interval of an estimate with the given standard
+// error, clipped to [0, 1].
+func NewConfidenceInterval(estimate, standardError float64) ConfidenceInterval {
+	const z95 = 1.959964
+	return ConfidenceInterval{
+		Lower: math.Max(0, estimate-z95*standardError),
+		Upper: math.Min(1, estimate+z95*standardError),
+	}
+}
+
 type HestonParams struct {
 	V0    float64 // Initial variance
 	Kappa float64 // Mean reversion speed of variance
@@ -106,12 +241,149 @@ type KouParams struct {
 	Eta2   float64 // Magnitude of down jump
 }
 
+// IsSingleLeg reports whether the position is a lone short option (a cash-secured put or covered call)
+// rather than a spread.
+func IsSingleLeg(spread OptionSpread) bool {
+	return spread.SpreadType == "Cash-Secured Put" || spread.SpreadType == "Covered Call"
+}
+
+// IsStrangle reports whether the position is a short strangle or straddle, a short put and a short call
+// with undefined risk above the call.
+func IsStrangle(spread OptionSpread) bool {
+	return spread.SpreadType == "Short Strangle" || spread.SpreadType == "Short Straddle"
+}
+
+// ShortContracts returns the number of short contracts per long contract.
+func ShortContracts(spread OptionSpread) float64 {
+	if spread.ShortQuantity > 1 {
+		return float64(spread.ShortQuantity)
+	}
+	return 1
+}
+
+// UndefinedRisk reports on which sides of the underlying the position's losses are unbounded by a long
+// option: below for naked puts, above for naked calls. Cash-secured puts and covered calls are fully
+// collateralized and count as defined.
+func UndefinedRisk(spread OptionSpread) (below, above bool) {
+	if len(spread.Legs) > 0 {
+		return legsUndefinedRisk(spread.Legs)
+	}
+	switch spread.SpreadType {
+	case "Short Strangle", "Short Straddle":
+		return true, true
+	case "Put Ratio", "Jade Lizard":
+		return true, false
+	case "Call Ratio":
+		return false, true
+	}
+	return false, false
+}
+
+// UpperBreakevenPrice returns the breakeven above the calls of a strangle, straddle or jade lizard, 0 for
+// other positions and for a jade lizard whose credit covers its call spread.
+func UpperBreakevenPrice(spread OptionSpread) float64 {
+	if len(spread.Legs) > 0 || spread.InversePrice > 0 {
+		if breakevens := LegsBreakevens(spread); len(breakevens) > 1 {
+			return breakevens[len(breakevens)-1]
+		}
+		return 0
+	}
+	switch {
+	case IsStrangle(spread):
+		return spread.CallLeg.Option.Strike + spread.SpreadCredit
+	case spread.SpreadType == "Jade Lizard" && spread.SpreadCredit < spread.LongLeg.Option.Strike-spread.CallLeg.Option.Strike:
+		return spread.CallLeg.Option.Strike + spread.SpreadCredit
+	}
+	return 0
+}
+
+// BreakevenPrice returns the expiration breakeven of a credit spread, the lower one of a strangle or straddle.
+func BreakevenPrice(spread OptionSpread) float64 {
+	if len(spread.Legs) > 0 || spread.InversePrice > 0 {
+		if breakevens := LegsBreakevens(spread); len(breakevens) > 0 {
+			return breakevens[0]
+		}
+		return 0
+	}
+	switch spread.SpreadType {
+	case "Bear Call":
+		return spread.ShortLeg.Option.Strike + spread.SpreadCredit
+	case "Covered Call":
+		return spread.StockPrice - spread.SpreadCredit
+	case "Put Ratio":
+		// Below the short strike the naked contracts lose a dollar per contract beyond the covered one
+		q := ShortContracts(spread)
+		return (q*spread.ShortLeg.Option.Strike - spread.LongLeg.Option.Strike - spread.SpreadCredit) / (q - 1)
+	case "Call Ratio":
+		q := ShortContracts(spread)
+		return (q*spread.ShortLeg.Option.Strike - spread.LongLeg.Option.Strike + spread.SpreadCredit) / (q - 1)
+	}
+	return spread.ShortLeg.Option.Strike - spread.SpreadCredit
+}
+
+// PayoffAtExpiration returns the per share P&L of a credit spread held to expiration, in dollars. The credit
+// of inverse options is held in the coin they settle in, so its dollar value moves with the underlying.
+func PayoffAtExpiration(spread OptionSpread, finalPrice float64) float64 {
+	payoff := linearPayoff(spread, finalPrice)
+	if spread.InversePrice > 0 {
+		payoff += spread.SpreadCredit * (finalPrice/spread.InversePrice - 1)
+	}
+	return payoff
+}
+
+// linearPayoff is the expiration P&L of a position whose credit is paid in dollars.
+func linearPayoff(spread OptionSpread, finalPrice float64) float64 {
+	if len(spread.Legs) > 0 {
+		return legsPayoff(spread, finalPrice)
+	}
+	switch spread.SpreadType {
+	case "Bull Put":
+		return spread.SpreadCredit -
+			math.Max(0, spread.ShortLeg.Option.Strike-finalPrice) +
+			math.Max(0, spread.LongLeg.Option.Strike-finalPrice)
+	case "Cash-Secured Put":
+		return spread.SpreadCredit - math.Max(0, spread.ShortLeg.Option.Strike-finalPrice)
+	case "Covered Call":
+		return finalPrice - spread.StockPrice + spread.SpreadCredit - math.Max(0, finalPrice-spread.ShortLeg.Option.Strike)
+	case "Short Strangle", "Short Straddle":
+		return spread.SpreadCredit -
+			math.Max(0, spread.ShortLeg.Option.Strike-finalPrice) -
+			math.Max(0, finalPrice-spread.CallLeg.Option.Strike)
+	case "Put Ratio":
+		return spread.SpreadCredit -
+			ShortContracts(spread)*math.Max(0, spread.ShortLeg.Option.Strike-finalPrice) +
+			math.Max(0, spread.LongLeg.Option.Strike-finalPrice)
+	case "Call Ratio":
+		return spread.SpreadCredit -
+			ShortContracts(spread)*math.Max(0, finalPrice-spread.ShortLeg.Option.Strike) +
+			math.Max(0, finalPrice-spread.LongLeg.Option.Strike)
+	case "Jade Lizard":
+		return spread.SpreadCredit -
+			math.Max(0, spread.ShortLeg.Option.Strike-finalPrice) -
+			math.Max(0, finalPrice-spread.CallLeg.Option.Strike) +
+			math.Max(0, finalPrice-spread.LongLeg.Option.Strike)
+	}
+	// Bear Call
+	return spread.SpreadCredit -
+		math.Max(0, finalPrice-spread.ShortLeg.Option.Strike) +
+		math.Max(0, finalPrice-spread.LongLeg.Option.Strike)
+}
+
 func IsProfitable(spread OptionSpread, finalPrice float64) bool {
+	if len(spread.Legs) > 0 || spread.InversePrice > 0 {
+		return PayoffAtExpiration(spread, finalPrice) > 0
+	}
 	switch spread.SpreadType {
 	case "Bear Call":
 		return finalPrice <= spread.ShortLeg.Option.Strike
-	case "Bull Put":
+	case "Bull Put", "Cash-Secured Put":
 		return finalPrice >= spread.ShortLeg.Option.Strike
+	case "Covered Call":
+		return finalPrice >= BreakevenPrice(spread)
+	case "Short Strangle", "Short Straddle":
+		return finalPrice >= BreakevenPrice(spread) && finalPrice <= UpperBreakevenPrice(spread)
+	case "Put Ratio", "Call Ratio", "Jade Lizard":
+		return PayoffAtExpiration(spread, finalPrice) > 0
 	default:
 		return false
 	}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// OptionLegs returns the options of every leg the position holds: the declared legs of a strategy, or
+// the short, long and call legs present in a built-in structure.
+func OptionLegs(spread OptionSpread) []tradier.Option {
+	var options []tradier.Option
+	for _, leg := range PositionLegs(spread) {
+		options = append(options, leg.Option)
+	}
+	return options
+}
+
+// PositionLegs returns every leg the position holds with its signed quantity, positive when short: the
+// declared legs of a strategy as they are, or the short, long and call legs of a built-in structure.
+func PositionLegs(spread OptionSpread) []PositionLeg {
+	if len(spread.Legs) > 0 {
+		return spread.Legs
+	}
+	legs := []PositionLeg{{SpreadLeg: spread.ShortLeg, Quantity: int(ShortContracts(spread))}}
+	if spread.LongLeg.Option.Symbol != "" {
+		legs = append(legs, PositionLeg{SpreadLeg: spread.LongLeg, Quantity: -1})
+	}
+	if spread.CallLeg.Option.Symbol != "" {
+		legs = append(legs, PositionLeg{SpreadLeg: spread.CallLeg, Quantity: 1})
+	}
+	return legs
+}
+
+// legsPayoff returns the per share P&L at expiration of a declared strategy: the credit less the
+// intrinsic value of every leg weighted by its signed quantity.
+func legsPayoff(spread OptionSpread, finalPrice float64) float64 {
+	payoff := spread.SpreadCredit
+	for _, leg := range spread.Legs {
+		payoff -= float64(leg.Quantity) * intrinsicAt(leg.Option.OptionType, leg.Option.Strike, finalPrice)
+	}
+	return payoff
+}
+
+func intrinsicAt(optionType string, strike, price float64) float64 {
+	if optionType == "call" {
+		return math.Max(0, price-strike)
+	}
+	return math.Max(0, strike-price)
+}
+
+// legsUndefinedRisk compares short and long contracts per option type: more short puts than long puts
+// leaves naked risk below, more short calls than long calls leaves it above.
+func legsUndefinedRisk(legs []PositionLeg) (below, above bool) {
+	puts, calls := 0, 0
+	for _, leg := range legs {
+		if leg.Option.OptionType == "call" {
+			calls += leg.Quantity
+		} else {
+			puts += leg.Quantity
+		}
+	}
+	return puts > 0, calls > 0
+}
+
+// LegsPriceGrid returns the prices at which a position's expiration payoff can change slope: zero, every
+// strike and twice the highest strike. The payoff is linear between consecutive points.
+func LegsPriceGrid(spread OptionSpread) []float64 {
+	prices := []float64{0}
+	highest := 0.0
+	for _, leg := range PositionLegs(spread) {
+		prices = append(prices, leg.Option.Strike)
+		highest = math.Max(highest, leg.Option.Strike)
+	}
+	prices = append(prices, 2*highest)
+	sort.Float64s(prices)
+	return prices
+}
+
+// LegsBreakevens returns the expiration breakevens of a declared strategy or inverse position in ascending order, found
+// where the piecewise linear payoff changes sign between grid points.
+func LegsBreakevens(spread OptionSpread) []float64 {
+	prices := LegsPriceGrid(spread)
+	var breakevens []float64
+	for i := 1; i < len(prices); i++ {
+		lo, hi := prices[i-1], prices[i]
+		if hi <= lo {
+			continue
+		}
+		a, b := PayoffAtExpiration(spread, lo), PayoffAtExpiration(spread, hi)
+		if (a < 0 && b >= 0) || (a >= 0 && b < 0) {
+			breakeven := lo + (hi-lo)*a/(a-b)
+			if n := len(breakevens); n == 0 || breakevens[n-1] != breakeven {
+				breakevens = append(breakevens, breakeven)
+			}
+		}
+	}
+	return breakevens
+}
@@ -0,0 +1,292 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// ConeWindows are the rolling windows of a volatility cone in trading days, from a week to six months.
+var ConeWindows = []int{5, 10, 21, 42, 63, 126}
+
+// DefaultConeLookback is the trading days, about two years, whose rolling windows make up a cone.
+const DefaultConeLookback = 504
+
+// Realized volatility estimators of the cones, in the order VolatilityCones returns them.
+const (
+	EstimatorYangZhang      = "Yang-Zhang"
+	EstimatorRogersSatchell = "Rogers-Satchell"
+	EstimatorParkinson      = "Parkinson"
+	EstimatorGarmanKlass    = "Garman-Klass"
+)
+
+var coneEstimators = []struct {
+	name     string
+	estimate func(opens, highs, lows, closes []float64) float64
+}{
+	{EstimatorYangZhang, calculateYangZhang},
+	{EstimatorRogersSatchell, calculateRogersSatchell},
+	{EstimatorParkinson, calculateParkinson},
+	{EstimatorGarmanKlass, calculateGarmanKlass},
+}
+
+// ConeWindow is the spread of annualized realized volatility over every rolling window of one length.
+type ConeWindow struct {
+	Days    int // Trading days in the window
+	Min     float64
+	Median  float64
+	Max     float64
+	Current float64 // Realized volatility of the latest window
+
+	vols []float64 // Every window's volatility, ascending
+}
+
+// Percentile returns the share of the windows whose realized volatility was below vol.
+func (w ConeWindow) Percentile(vol float64) float64 {
+	if len(w.vols) == 0 {
+		return 0
+	}
+	return float64(sort.SearchFloat64s(w.vols, vol)) / float64(len(w.vols))
+}
+
+// VolCone is the realized volatility of one estimator across the ConeWindows.
+type VolCone struct {
+	Estimator string
+	Windows   []ConeWindow // Ascending length; windows longer than the history are left out
+}
+
+// VolatilityCones returns the cone of each estimator from the daily bars of the history. Each window
+// length is rolled a day at a time over the last lookback days, or the whole history when lookback is 0.
+func VolatilityCones(history tradier.QuoteHistory, lookback int) []VolCone {
+	days := history.History.Day
+	if lookback > 0 && len(days) > lookback {
+		days = days[len(days)-lookback:]
+	}
+	opens := make([]float64, len(days))
+	highs := make([]float64, len(days))
+	lows := make([]float64, len(days))
+	closes := make([]float64, len(days))
+	for i, day := range days {
+		opens[i], highs[i], lows[i], closes[i] = day.Open, day.High, day.Low, day.Close
+	}
+
+	cones := make([]VolCone, 0, len(coneEstimators))
+	for _, estimator := range coneEstimators {
+		cone := VolCone{Estimator: estimator.name}
+		for _, length := range ConeWindows {
+			var vols []float64
+			for end := length; end <= len(days); end++ {
+				start := end - length
+				if !validBars(opens[start:end], highs[start:end], lows[start:end], closes[start:end]) {
+					continue
+				}
+				vol := estimator.estimate(opens[start:end], highs[start:end], lows[start:end], closes[start:end])
+				if vol > 0 && !math.IsNaN(vol) && !math.IsInf(vol, 0) {
+					vols = append(vols, vol)
+				}
+			}
+			if len(vols) == 0 {
+				continue
+			}
+			window := ConeWindow{Days: length, Current: vols[len(vols)-1]}
+			sort.Float64s(vols)
+			window.vols = vols
+			window.Min, window.Max = vols[0], vols[len(vols)-1]
+			window.Median = vols[len(vols)/2]
+			if len(vols)%2 == 0 {
+				window.Median = (vols[len(vols)/2-1] + vols[len(vols)/2]) / 2
+			}
+			cone.Windows = append(cone.Windows, window)
+		}
+		cones = append(cones, cone)
+	}
+	return cones
+}
+
+// validBars reports whether every price of the bars is positive, as the estimators' logs require.
+func validBars(opens, highs, lows, closes []float64) bool {
+	for i := range opens {
+		if opens[i] <= 0 || highs[i] <= 0 || lows[i] <= 0 || closes[i] <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// calculateParkinson estimates annualized volatility from the daily high-low ranges alone.
+func calculateParkinson(opens, highs, lows, closes []float64) float64 {
+	n := len(highs)
+	if n == 0 || n != len(lows) {
+		return 0
+	}
+
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		hl := math.Log(highs[i] / lows[i])
+		sum += hl * hl
+	}
+
+	// Annualize the volatility
+	return math.Sqrt(sum / (4 * math.Ln2 * float64(n)) * 252)
+}
+
+// calculateGarmanKlass estimates annualized volatility from the daily high-low ranges and open-to-close
+// returns.
+func calculateGarmanKlass(opens, highs, lows, closes []float64) float64 {
+	n := len(opens)
+	if n == 0 || n != len(highs) || n != len(lows) || n != len(closes) {
+		return 0
+	}
+
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		hl := math.Log(highs[i] / lows[i])
+		co := math.Log(closes[i] / opens[i])
+		sum += 0.5*hl*hl - (2*math.Ln2-1)*co*co
+	}
+	if sum <= 0 {
+		return 0
+	}
+
+	// Annualize the volatility
+	return math.Sqrt(sum / float64(n) * 252)
+}
+
+// TermIV is the at-the-money implied volatility of one expiration.
+type TermIV struct {
+	Expiration string
+	Days       int // Calendar days to expiration
+	IV         float64
+}
+
+// TermStructure is the at-the-money implied volatility of a chain's expirations, nearest first.
+type TermStructure []TermIV
+
+// ATMTermStructure returns the implied volatility of each expiration at the strike nearest
+// underlyingPrice, averaging the call and put when both are quoted.
+func ATMTermStructure(chain map[string]*tradier.OptionChain, underlyingPrice float64, now time.Time) TermStructure {
+	var term TermStructure
+	for expiration, options := range chain {
+		if options == nil {
+			continue
+		}
+		days, err := market.DaysToExpiration(expiration, now)
+		if err != nil || days <= 0 {
+			continue
+		}
+
+		atmStrike, found := 0.0, false
+		vols := make(map[float64][]float64)
+		for _, option := range options.Options.Option {
+			iv := option.Greeks.MidIv
+			if iv <= 0 {
+				iv = (option.Greeks.BidIv + option.Greeks.AskIv) / 2
+			}
+			if iv <= 0 {
+				continue
+			}
+			vols[option.Strike] = append(vols[option.Strike], iv)
+			if !found || math.Abs(option.Strike-underlyingPrice) < math.Abs(atmStrike-underlyingPrice) {
+				atmStrike, found = option.Strike, true
+			}
+		}
+		if !found {
+			continue
+		}
+		iv := 0.0
+		for _, vol := range vols[atmStrike] {
+			iv += vol
+		}
+		term = append(term, TermIV{Expiration: expiration, Days: days, IV: iv / float64(len(vols[atmStrike]))})
+	}
+	sort.Slice(term, func(i, j int) bool { return term[i].Days < term[j].Days })
+	return term
+}
+
+// At interpolates the implied volatility linearly in calendar days to expiration, holding the nearest
+// and farthest expirations' volatility beyond them. It is not ok when the structure is empty.
+func (t TermStructure) At(days float64) (float64, bool) {
+	if len(t) == 0 {
+		return 0, false
+	}
+	if days <= float64(t[0].Days) {
+		return t[0].IV, true
+	}
+	for i := 1; i < len(t); i++ {
+		if days <= float64(t[i].Days) {
+			lo, hi := t[i-1], t[i]
+			w := (days - float64(lo.Days)) / float64(hi.Days-lo.Days)
+			return lo.IV + w*(hi.IV-lo.IV), true
+		}
+	}
+	return t[len(t)-1].IV, true
+}
+
+// IVComparison places the implied volatility of a cone window's horizon within the window's realized range.
+type IVComparison struct {
+	Window     ConeWindow
+	IV         float64 // Implied volatility interpolated to the window's length in calendar days
+	Percentile float64 // Share of the window's realized volatilities below IV
+}
+
+// Thresholds of IVComparison.Verdict.
+const (
+	RichPercentile  = 0.75
+	CheapPercentile = 0.25
+)
+
+// Verdict is rich when implied volatility is at or above RichPercentile of realized, cheap when at or
+// below CheapPercentile and fair otherwise.
+func (c IVComparison) Verdict() string {
+	switch {
+	case c.Percentile >= RichPercentile:
+		return "rich"
+	case c.Percentile <= CheapPercentile:
+		return "cheap"
+	}
+	return "fair"
+}
+
+// CompareIV compares the term structure with each window of the cone, matching a window of N trading days
+// with the implied volatility N*365/252 calendar days out.
+func CompareIV(cone VolCone, term TermStructure) []IVComparison {
+	var comparisons []IVComparison
+	for _, window := range cone.Windows {
+		iv, ok := term.At(float64(window.Days) * 365 / 252)
+		if !ok {
+			return nil
+		}
+		comparisons = append(comparisons, IVComparison{Window: window, IV: iv, Percentile: window.Percentile(iv)})
+	}
+	return comparisons
+}
+
+// DescribeCones renders each cone and, with a term structure, where implied volatility sits within it.
+func DescribeCones(cones []VolCone, term TermStructure) string {
+	var b strings.Builder
+	for _, cone := range cones {
+		b.WriteString(fmt.Sprintf("%s realized volatility:\n", cone.Estimator))
+		comparisons := CompareIV(cone, term)
+		for i, window := range cone.Windows {
+			b.WriteString(fmt.Sprintf("  %3dd: min %5.1f%%, median %5.1f%%, max %5.1f%%, current %5.1f%%",
+				window.Days, window.Min*100, window.Median*100, window.Max*100, window.Current*100))
+			if comparisons != nil {
+				c := comparisons[i]
+				b.WriteString(fmt.Sprintf("; IV %5.1f%%, percentile %3.0f, %s", c.IV*100, c.Percentile*100, c.Verdict()))
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(term) > 0 {
+		b.WriteString("At-the-money implied volatility:\n")
+		for _, t := range term {
+			b.WriteString(fmt.Sprintf("  %s (%d days): %.1f%%\n", t.Expiration, t.Days, t.IV*100))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"math"
+
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// Realized volatility forecast windows in trading days: the daily, weekly and monthly components of a
+// HAR model are read over a week, a month and a quarter, and the long-run level over a year.
+var forecastWindows = []int{5, 21, 63}
+
+const (
+	longRunWindow      = 252
+	reversionTradeDays = 63 // Horizon over which recent realized variance gives way to its long-run level
+)
+
+// ForecastVolatility forecasts the annualized realized volatility of the next days trading days from the
+// history's daily bars. The mean Yang-Zhang variance of the forecast windows, recent realized variance,
+// reverts toward the variance of the last year as the horizon grows, with weight exp(-days/63) on the
+// recent. It is not ok when the history is shorter than a week.
+func ForecastVolatility(history tradier.QuoteHistory, days float64) (float64, bool) {
+	recent, count := 0.0, 0
+	for _, window := range forecastWindows {
+		if vol := calculatePeriodYangZhang(history, window); vol > 0 && !math.IsNaN(vol) {
+			recent += vol * vol
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, false
+	}
+	recent /= float64(count)
+
+	longRun := recent
+	window := min(len(history.History.Day), longRunWindow)
+	if vol := calculatePeriodYangZhang(history, window); window > forecastWindows[len(forecastWindows)-1] && vol > 0 && !math.IsNaN(vol) {
+		longRun = vol * vol
+	}
+
+	w := math.Exp(-math.Max(days, 0) / reversionTradeDays)
+	return math.Sqrt(w*recent + (1-w)*longRun), true
+}
+
+// VarianceRiskPremium is the premium an expiration's options pay over the volatility expected to be
+// realized until they expire, the edge of selling them.
+type VarianceRiskPremium struct {
+	Expiration string
+	Days       int     // Calendar days to expiration
+	IV         float64 // At-the-money implied volatility
+	ForecastRV float64 // Realized volatility forecast to expiration
+	Premium    float64 // IV² minus ForecastRV², positive when options are rich
+}
+
+// VarianceRiskPremiumAt is the premium at the calendar days to expiration, interpolating the term
+// structure and forecasting realized volatility over the matching days*252/365 trading days.
+func VarianceRiskPremiumAt(term TermStructure, history tradier.QuoteHistory, days int) (VarianceRiskPremium, bool) {
+	iv, ok := term.At(float64(days))
+	if !ok || iv <= 0 {
+		return VarianceRiskPremium{}, false
+	}
+	rv, ok := ForecastVolatility(history, float64(days)*252/365)
+	if !ok {
+		return VarianceRiskPremium{}, false
+	}
+	return VarianceRiskPremium{Days: days, IV: iv, ForecastRV: rv, Premium: iv*iv - rv*rv}, true
+}
+
+// VarianceRiskPremiums returns the premium of each expiration of the term structure, nearest first.
+func VarianceRiskPremiums(term TermStructure, history tradier.QuoteHistory) []VarianceRiskPremium {
+	var premiums []VarianceRiskPremium
+	for _, t := range term {
+		rv, ok := ForecastVolatility(history, float64(t.Days)*252/365)
+		if !ok || t.IV <= 0 {
+			continue
+		}
+		premiums = append(premiums, VarianceRiskPremium{Expiration: t.Expiration, Days: t.Days, IV: t.IV, ForecastRV: rv, Premium: t.IV*t.IV - rv*rv})
+	}
+	return premiums
+}
@@ -0,0 +1,249 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/notify"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// Snapshot is a monitored position marked to the current market.
+type Snapshot struct {
+	Position        Position
+	UnderlyingPrice float64
+	ShortDelta      float64 // Absolute delta of the short leg
+	PoP             float64 // Probability the short strike finishes out of the money, driftless lognormal at the short leg's IV
+	DTE             int
+	NaturalDebit    float64 // Debit to close crossing both bid-ask spreads
+	MidDebit        float64 // Debit to close at the legs' mid prices
+	ProfitCaptured  float64 // Fraction of the opening credit kept if closed at the mid prices, negative when losing
+}
+
+// Signal is a position for which one or more exit rules triggered.
+type Signal struct {
+	Snapshot
+	Reasons    []string
+	LimitPrice float64 // Recommended limit debit for the closing order
+	Rolls      []Roll  // Net credit rolls to a later expiration, when the short strike is breached
+}
+
+// Message describes the signal and the closing order for alerts.
+func (s Signal) Message() string {
+	p := s.Position
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("Exit signal for %s %s %s/%s expiring %s (position %d):\n", p.Underlying, p.SpreadType, formatStrike(p.ShortStrike), formatStrike(p.LongStrike), p.Expiration, p.ID))
+	for _, reason := range s.Reasons {
+		msg.WriteString(fmt.Sprintf("  - %s\n", reason))
+	}
+	msg.WriteString(fmt.Sprintf("  Underlying: %.2f, Short Delta: %.2f, PoP: %.1f%%, DTE: %d, Max Profit Captured: %.0f%%\n", s.UnderlyingPrice, s.ShortDelta, s.PoP*100, s.DTE, s.ProfitCaptured*100))
+	msg.WriteString(fmt.Sprintf("  Opened for %.2f credit, closing now costs %.2f mid / %.2f natural (P&L %+.2f per share at the limit)\n", p.Credit, s.MidDebit, s.NaturalDebit, p.Credit-s.LimitPrice))
+	msg.WriteString(fmt.Sprintf("  Recommended close: BUY %s / SELL %s at %.2f debit limit\n", p.ShortSymbol, p.LongSymbol, s.LimitPrice))
+	if len(s.Rolls) > 0 {
+		msg.WriteString("  Roll candidates:\n")
+		for _, roll := range s.Rolls {
+			msg.WriteString(fmt.Sprintf("    - %s\n", roll.Describe()))
+		}
+	}
+	return msg.String()
+}
+
+func formatStrike(strike float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", strike), "0"), ".")
+}
+
+// Monitor periodically marks the stored positions to market and raises exit signals.
+type Monitor struct {
+	store     *Store
+	rules     []Rule
+	notifiers []notify.Notifier
+	interval  time.Duration
+
+	mu      sync.Mutex
+	alerted map[int]string // Reasons last alerted per position, so unchanged signals are not repeated every cycle
+}
+
+func New(store *Store, rules []Rule, notifiers []notify.Notifier, interval time.Duration) *Monitor {
+	return &Monitor{store: store, rules: rules, notifiers: notifiers, interval: interval, alerted: make(map[int]string)}
+}
+
+// Store returns the monitored positions.
+func (m *Monitor) Store() *Store {
+	return m.store
+}
+
+// Run evaluates the positions every interval until stop is closed. New signals are pushed to the
+// notifiers and passed to alert, if set.
+func (m *Monitor) Run(stop <-chan struct{}, alert func(Signal)) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			signals, err := m.Cycle()
+			if err != nil {
+				log.Printf("Error monitoring positions: %v", err)
+			}
+			for _, signal := range signals {
+				notify.NotifyAll(m.notifiers, fmt.Sprintf("STOCD exit signal for %s", signal.Position.Underlying), signal.Message())
+				if alert != nil {
+					alert(signal)
+				}
+			}
+		}
+	}
+}
+
+// Cycle marks every position once and returns the signals whose reasons changed since they were last raised.
+func (m *Monitor) Cycle() ([]Signal, error) {
+	signals, err := m.Evaluate()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var fresh []Signal
+	for _, signal := range signals {
+		key := strings.Join(signal.Reasons, "; ")
+		if m.alerted[signal.Position.ID] == key {
+			continue
+		}
+		m.alerted[signal.Position.ID] = key
+		fresh = append(fresh, signal)
+	}
+	return fresh, nil
+}
+
+// Evaluate marks every position to market and returns those for which an exit rule triggers.
+func (m *Monitor) Evaluate() ([]Signal, error) {
+	positions := m.store.List()
+	if len(positions) == 0 {
+		return nil, nil
+	}
+
+	symbolSet := make(map[string]bool)
+	var symbols []string
+	for _, position := range positions {
+		for _, symbol := range []string{position.Underlying, position.ShortSymbol, position.LongSymbol} {
+			if !symbolSet[symbol] {
+				symbolSet[symbol] = true
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+
+	quotes, err := tradier.GET_MARKET_QUOTES(symbols, os.Getenv("TRADIER_KEY"))
+	if err != nil {
+		return nil, err
+	}
+	bySymbol := make(map[string]tradier.Option, len(quotes))
+	for _, quote := range quotes {
+		bySymbol[quote.Symbol] = quote
+	}
+
+	var signals []Signal
+	now := market.Now()
+	chains := make(map[string]map[string]*tradier.OptionChain)
+	for _, position := range positions {
+		snapshot, err := Mark(position, bySymbol, now)
+		if err != nil {
+			log.Printf("Error marking position %d: %v", position.ID, err)
+			continue
+		}
+		signal, ok := Check(snapshot, m.rules)
+		if !ok {
+			continue
+		}
+		if Breached(snapshot) {
+			key := fmt.Sprintf("%s:%d", position.Underlying, snapshot.DTE)
+			if _, fetched := chains[key]; !fetched {
+				chains[key], err = tradier.GET_OPTIONS_CHAIN(position.Underlying, os.Getenv("TRADIER_KEY"), snapshot.DTE+1, snapshot.DTE+rollMaxExtraDTE)
+				if err != nil {
+					log.Printf("Error fetching roll candidates for position %d: %v", position.ID, err)
+				}
+			}
+			signal.Rolls = FindRolls(snapshot, chains[key])
+		}
+		signals = append(signals, signal)
+	}
+	return signals, nil
+}
+
+// Mark values a position from current quotes of its legs and underlying.
+func Mark(position Position, quotes map[string]tradier.Option, now time.Time) (Snapshot, error) {
+	short, ok := quotes[position.ShortSymbol]
+	if !ok {
+		return Snapshot{}, fmt.Errorf("no quote for %s", position.ShortSymbol)
+	}
+	long, ok := quotes[position.LongSymbol]
+	if !ok {
+		return Snapshot{}, fmt.Errorf("no quote for %s", position.LongSymbol)
+	}
+	underlying, ok := quotes[position.Underlying]
+	if !ok {
+		return Snapshot{}, fmt.Errorf("no quote for %s", position.Underlying)
+	}
+
+	spot := underlying.Price()
+
+	dte, err := market.DaysToExpiration(position.Expiration, now)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	tau, _ := market.YearsToExpiration(position.Expiration, now)
+
+	snapshot := Snapshot{
+		Position:        position,
+		UnderlyingPrice: spot,
+		ShortDelta:      math.Abs(short.Greeks.Delta),
+		PoP:             probabilityOTM(position, spot, short.Greeks.MidIv, tau),
+		DTE:             dte,
+		NaturalDebit:    short.Ask - long.Bid,
+		MidDebit:        (short.Bid+short.Ask)/2 - (long.Bid+long.Ask)/2,
+	}
+	if position.Credit > 0 {
+		snapshot.ProfitCaptured = (position.Credit - snapshot.MidDebit) / position.Credit
+	}
+	return snapshot, nil
+}
+
+// Check evaluates the rules against a snapshot.
+func Check(snapshot Snapshot, rules []Rule) (Signal, bool) {
+	signal := Signal{Snapshot: snapshot, LimitPrice: math.Max(math.Ceil(snapshot.MidDebit*100-1e-9)/100, 0)}
+	for _, rule := range rules {
+		if reason, ok := rule.Check(snapshot); ok {
+			signal.Reasons = append(signal.Reasons, reason)
+		}
+	}
+	return signal, len(signal.Reasons) > 0
+}
+
+// probabilityOTM is the probability of the short strike expiring out of the money under a driftless
+// lognormal at vol. Without a usable volatility it reports whether the strike is currently out of the money.
+func probabilityOTM(position Position, spot, vol, tau float64) float64 {
+	otmAbove := position.SpreadType == "Bull Put"
+	if vol <= 0 || tau <= 0 {
+		if (spot > position.ShortStrike) == otmAbove {
+			return 1
+		}
+		return 0
+	}
+
+	d2 := (math.Log(spot/position.ShortStrike) - 0.5*vol*vol*tau) / (vol * math.Sqrt(tau))
+	probAbove := 0.5 * math.Erfc(-d2/math.Sqrt2)
+	if otmAbove {
+		return probAbove
+	}
+	return 1 - probAbove
+}
@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Position is an open credit spread being monitored for exit signals.
+type Position struct {
+	ID          int       `json:"id"`
+	Underlying  string    `json:"underlying"`
+	SpreadType  string    `json:"spread_type"`
+	ShortSymbol string    `json:"short_symbol"`
+	LongSymbol  string    `json:"long_symbol"`
+	ShortStrike float64   `json:"short_strike"`
+	LongStrike  float64   `json:"long_strike"`
+	Expiration  string    `json:"expiration"`
+	Credit      float64   `json:"credit"`               // Credit received per share when the position was opened
+	ChannelID   string    `json:"channel_id,omitempty"` // Slack channel the position was added from, alerted on exit signals
+	OpenedAt    time.Time `json:"opened_at"`
+}
+
+// Store persists monitored positions to a JSON file.
+type Store struct {
+	path      string
+	mu        sync.RWMutex
+	positions []Position
+}
+
+// Open loads the monitored positions at path, starting empty if the file does not exist yet.
+func Open(path string) (*Store, error) {
+	store := &Store{path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read positions: %s", err)
+	}
+
+	if err := json.Unmarshal(data, &store.positions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal positions: %s", err)
+	}
+
+	return store, nil
+}
+
+// Add assigns the position the next free ID and writes the positions back to disk.
+func (s *Store) Add(position Position) (Position, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	position.ID = 1
+	for _, existing := range s.positions {
+		position.ID = max(position.ID, existing.ID+1)
+	}
+	s.positions = append(s.positions, position)
+
+	return position, s.save()
+}
+
+// Remove stops monitoring the position with the given ID.
+func (s *Store) Remove(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, position := range s.positions {
+		if position.ID == id {
+			s.positions = append(s.positions[:i], s.positions[i+1:]...)
+			return s.save()
+		}
+	}
+	return fmt.Errorf("no monitored position with ID %d", id)
+}
+
+// List returns a copy of the monitored positions.
+func (s *Store) List() []Position {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	positions := make([]Position, len(s.positions))
+	copy(positions, s.positions)
+	return positions
+}
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.positions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal positions: %s", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write positions: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package monitor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	rollMaxExtraDTE = 45 // Latest roll expiration, in days beyond the position's expiration
+	rollCandidates  = 3  // Roll candidates presented per signal
+)
+
+// Roll is a candidate for rolling a tested position: close it and open the same type of spread, at the
+// same width, in a later expiration.
+type Roll struct {
+	Expiration  string
+	ShortSymbol string
+	LongSymbol  string
+	ShortStrike float64
+	LongStrike  float64
+	Credit      float64 // Natural credit opening the new spread
+	NetCredit   float64 // Credit less the natural debit closing the current position
+}
+
+// Breached reports whether the underlying has moved through the position's short strike.
+func Breached(snapshot Snapshot) bool {
+	if snapshot.Position.SpreadType == "Bull Put" {
+		return snapshot.UnderlyingPrice < snapshot.Position.ShortStrike
+	}
+	return snapshot.UnderlyingPrice > snapshot.Position.ShortStrike
+}
+
+// FindRolls searches chains for rolls to a later expiration with the short strike at or beyond the current
+// one, away from the money, that collect a net credit. Rolls moving the short strike furthest come first,
+// then those collecting the most.
+func FindRolls(snapshot Snapshot, chains map[string]*tradier.OptionChain) []Roll {
+	position := snapshot.Position
+	optionType, direction := "put", -1.0
+	if position.SpreadType == "Bear Call" {
+		optionType, direction = "call", 1.0
+	}
+	width := math.Abs(position.ShortStrike - position.LongStrike)
+
+	var rolls []Roll
+	for expiration, chain := range chains {
+		if chain == nil || expiration <= position.Expiration {
+			continue
+		}
+		byStrike := make(map[float64]tradier.Option)
+		for _, option := range chain.Options.Option {
+			if option.OptionType == optionType {
+				byStrike[option.Strike] = option
+			}
+		}
+		for strike, short := range byStrike {
+			if (strike-position.ShortStrike)*direction < 0 || short.Bid <= 0 {
+				continue
+			}
+			long, ok := byStrike[strike+direction*width]
+			if !ok {
+				continue
+			}
+			credit := short.Bid - long.Ask
+			netCredit := credit - snapshot.NaturalDebit
+			if netCredit <= 0 {
+				continue
+			}
+			rolls = append(rolls, Roll{
+				Expiration:  expiration,
+				ShortSymbol: short.Symbol,
+				LongSymbol:  long.Symbol,
+				ShortStrike: short.Strike,
+				LongStrike:  long.Strike,
+				Credit:      credit,
+				NetCredit:   netCredit,
+			})
+		}
+	}
+
+	sort.Slice(rolls, func(i, j int) bool {
+		moveI := math.Abs(rolls[i].ShortStrike - position.ShortStrike)
+		moveJ := math.Abs(rolls[j].ShortStrike - position.ShortStrike)
+		if moveI != moveJ {
+			return moveI > moveJ
+		}
+		if rolls[i].NetCredit != rolls[j].NetCredit {
+			return rolls[i].NetCredit > rolls[j].NetCredit
+		}
+		return rolls[i].Expiration < rolls[j].Expiration
+	})
+	return rolls[:min(rollCandidates, len(rolls))]
+}
+
+// Describe summarizes the roll and its opening order, e.g. "to 2024-10-18 190/185 for 1.10 credit (0.15 net): SELL ... / BUY ...".
+func (r Roll) Describe() string {
+	return fmt.Sprintf("to %s %s/%s for %.2f credit (%.2f net): SELL %s / BUY %s", r.Expiration, formatStrike(r.ShortStrike), formatStrike(r.LongStrike), r.Credit, r.NetCredit, r.ShortSymbol, r.LongSymbol)
+}
@@ -0,0 +1,109 @@
+package monitor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Default exit thresholds, overridable with EXIT_MAX_SHORT_DELTA, EXIT_MIN_POP, EXIT_DTE, EXIT_PROFIT_TARGET
+// and EXIT_STOP_LOSS.
+const (
+	defaultMaxShortDelta = 0.40
+	defaultMinPoP        = 0.55
+	defaultExitDTE       = 21
+	defaultProfitTarget  = 0.50 // Close after keeping half the credit
+	defaultStopLoss      = 2.0  // Close once the loss reaches twice the credit
+)
+
+// Rule is an exit condition evaluated against a marked position. Check returns a reason when it triggers.
+type Rule struct {
+	Name  string
+	Check func(snapshot Snapshot) (string, bool)
+}
+
+// ShortDeltaRule triggers once the short leg's absolute delta exceeds limit.
+func ShortDeltaRule(limit float64) Rule {
+	return Rule{Name: "short_delta", Check: func(snapshot Snapshot) (string, bool) {
+		if snapshot.ShortDelta > limit {
+			return fmt.Sprintf("short leg delta %.2f exceeds %.2f", snapshot.ShortDelta, limit), true
+		}
+		return "", false
+	}}
+}
+
+// PoPRule triggers once the probability of profit falls below limit.
+func PoPRule(limit float64) Rule {
+	return Rule{Name: "pop", Check: func(snapshot Snapshot) (string, bool) {
+		if snapshot.PoP < limit {
+			return fmt.Sprintf("probability of profit %.1f%% is below %.1f%%", snapshot.PoP*100, limit*100), true
+		}
+		return "", false
+	}}
+}
+
+// DTERule triggers once the position has dte or fewer days to expiration.
+func DTERule(dte int) Rule {
+	return Rule{Name: "dte", Check: func(snapshot Snapshot) (string, bool) {
+		if snapshot.DTE <= dte {
+			return fmt.Sprintf("%d days to expiration reached (exit at %d)", snapshot.DTE, dte), true
+		}
+		return "", false
+	}}
+}
+
+// ProfitTargetRule triggers once closing at the mid prices keeps at least target of the opening credit.
+func ProfitTargetRule(target float64) Rule {
+	return Rule{Name: "profit_target", Check: func(snapshot Snapshot) (string, bool) {
+		if snapshot.Position.Credit > 0 && snapshot.ProfitCaptured >= target {
+			return fmt.Sprintf("%.0f%% of max profit captured (target %.0f%%)", snapshot.ProfitCaptured*100, target*100), true
+		}
+		return "", false
+	}}
+}
+
+// StopLossRule triggers once closing at the mid prices loses multiple times the opening credit or more.
+func StopLossRule(multiple float64) Rule {
+	return Rule{Name: "stop_loss", Check: func(snapshot Snapshot) (string, bool) {
+		if snapshot.Position.Credit > 0 && -snapshot.ProfitCaptured >= multiple {
+			return fmt.Sprintf("loss of %.1fx the credit reached (stop at %.1fx)", -snapshot.ProfitCaptured, multiple), true
+		}
+		return "", false
+	}}
+}
+
+// BreachRule triggers once the underlying moves through the short strike.
+func BreachRule() Rule {
+	return Rule{Name: "breach", Check: func(snapshot Snapshot) (string, bool) {
+		if Breached(snapshot) {
+			return fmt.Sprintf("underlying %.2f has breached the short strike %s", snapshot.UnderlyingPrice, formatStrike(snapshot.Position.ShortStrike)), true
+		}
+		return "", false
+	}}
+}
+
+// RulesFromEnv returns the breach rule and the short delta, probability of profit, DTE, profit target and
+// stop loss rules with thresholds from the environment. A profit target or stop loss of 0 disables that rule.
+func RulesFromEnv() []Rule {
+	maxShortDelta := envFloat("EXIT_MAX_SHORT_DELTA", defaultMaxShortDelta)
+	minPoP := envFloat("EXIT_MIN_POP", defaultMinPoP)
+	exitDTE := int(envFloat("EXIT_DTE", defaultExitDTE))
+	profitTarget := envFloat("EXIT_PROFIT_TARGET", defaultProfitTarget)
+	stopLoss := envFloat("EXIT_STOP_LOSS", defaultStopLoss)
+
+	rules := []Rule{BreachRule(), ShortDeltaRule(maxShortDelta), PoPRule(minPoP), DTERule(exitDTE)}
+	if profitTarget > 0 {
+		rules = append(rules, ProfitTargetRule(profitTarget))
+	}
+	if stopLoss > 0 {
+		rules = append(rules, StopLossRule(stopLoss))
+	}
+	return rules
+}
+
+func envFloat(name string, fallback float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil {
+		return value
+	}
+	return fallback
+}
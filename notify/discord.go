@@ -0,0 +1,25 @@
+package notify
+
+const discordMessageLimit = 2000
+
+// DiscordNotifier posts messages to a Discord channel webhook.
+type DiscordNotifier struct {
+	webhookURL string
+}
+
+func NewDiscordNotifier(webhookURL string) *DiscordNotifier {
+	return &DiscordNotifier{webhookURL: webhookURL}
+}
+
+func (d *DiscordNotifier) Name() string {
+	return "Discord"
+}
+
+func (d *DiscordNotifier) Notify(subject, message string) error {
+	for _, part := range chunk("**"+subject+"**\n"+message, discordMessageLimit) {
+		if err := postJSON(d.webhookURL, map[string]string{"content": part}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
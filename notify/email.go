@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const sendGridURL = "https://api.sendgrid.com/v3/mail/send"
+
+// EmailTransport delivers a plain text email, with optional attachments, to a list of recipients.
+type EmailTransport interface {
+	Send(from string, to []string, subject, body string, attachments []Attachment) error
+}
+
+// EmailNotifier emails results to one or more recipients.
+type EmailNotifier struct {
+	from      string
+	to        []string
+	transport EmailTransport
+}
+
+func NewEmailNotifier(from string, to []string, transport EmailTransport) *EmailNotifier {
+	return &EmailNotifier{from: from, to: to, transport: transport}
+}
+
+func (e *EmailNotifier) Name() string {
+	return "email"
+}
+
+func (e *EmailNotifier) Notify(subject, message string) error {
+	return e.transport.Send(e.from, e.to, subject, message, nil)
+}
+
+func (e *EmailNotifier) NotifyWithAttachments(subject, message string, attachments []Attachment) error {
+	return e.transport.Send(e.from, e.to, subject, message, attachments)
+}
+
+// SendGridTransport sends email through the SendGrid v3 API.
+type SendGridTransport struct {
+	apiKey string
+}
+
+func NewSendGridTransport(apiKey string) *SendGridTransport {
+	return &SendGridTransport{apiKey: apiKey}
+}
+
+func (s *SendGridTransport) Send(from string, to []string, subject, body string, attachments []Attachment) error {
+	recipients := make([]map[string]string, len(to))
+	for i, address := range to {
+		recipients[i] = map[string]string{"email": address}
+	}
+
+	payload := map[string]interface{}{
+		"personalizations": []map[string]interface{}{{"to": recipients}},
+		"from":             map[string]string{"email": from},
+		"subject":          subject,
+		"content":          []map[string]string{{"type": "text/plain", "value": body}},
+	}
+	if len(attachments) > 0 {
+		files := make([]map[string]string, len(attachments))
+		for i, attachment := range attachments {
+			files[i] = map[string]string{
+				"content":     base64.StdEncoding.EncodeToString(attachment.Data),
+				"filename":    attachment.Name,
+				"type":        attachment.ContentType,
+				"disposition": "attachment",
+			}
+		}
+		payload["attachments"] = files
+	}
+
+	return postJSON(sendGridURL, payload, "Authorization", "Bearer "+s.apiKey)
+}
+
+// SMTPTransport sends email through an SMTP server, authenticating with PLAIN auth
+// when a username is configured.
+type SMTPTransport struct {
+	addr     string
+	host     string
+	username string
+	password string
+}
+
+func NewSMTPTransport(host, port, username, password string) *SMTPTransport {
+	if port == "" {
+		port = "587"
+	}
+	return &SMTPTransport{addr: host + ":" + port, host: host, username: username, password: password}
+}
+
+func (s *SMTPTransport) Send(from string, to []string, subject, body string, attachments []Attachment) error {
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + from + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + subject + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+
+	text := strings.ReplaceAll(body, "\n", "\r\n")
+	if len(attachments) == 0 {
+		msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		msg.WriteString(text)
+	} else {
+		boundary := fmt.Sprintf("stocd-%d", time.Now().UnixNano())
+		msg.WriteString("Content-Type: multipart/mixed; boundary=" + boundary + "\r\n\r\n")
+		msg.WriteString("--" + boundary + "\r\n")
+		msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		msg.WriteString(text + "\r\n")
+		for _, attachment := range attachments {
+			msg.WriteString("--" + boundary + "\r\n")
+			msg.WriteString("Content-Type: " + attachment.ContentType + "\r\n")
+			msg.WriteString("Content-Transfer-Encoding: base64\r\n")
+			msg.WriteString("Content-Disposition: attachment; filename=\"" + attachment.Name + "\"\r\n\r\n")
+			encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+			for len(encoded) > 76 {
+				msg.WriteString(encoded[:76] + "\r\n")
+				encoded = encoded[76:]
+			}
+			msg.WriteString(encoded + "\r\n")
+		}
+		msg.WriteString("--" + boundary + "--\r\n")
+	}
+
+	if err := smtp.SendMail(s.addr, auth, from, to, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send email via %s: %s", s.addr, err)
+	}
+	return nil
+}
+
+// parseRecipients splits a comma separated address list, dropping empty entries.
+func parseRecipients(value string) []string {
+	var recipients []string
+	for _, address := range strings.Split(value, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+	return recipients
+}
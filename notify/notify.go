@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Notifier pushes scan results to a messaging platform.
+type Notifier interface {
+	Name() string
+	Notify(subject, message string) error
+}
+
+// Attachment is a file sent with a notification by channels that support files.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// AttachmentNotifier is implemented by notifiers that can deliver attachments.
+type AttachmentNotifier interface {
+	Notifier
+	NotifyWithAttachments(subject, message string, attachments []Attachment) error
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// FromEnv builds a notifier for every channel configured in the environment:
+// SLACK_NOTIFY_CHANNEL for a Slack channel, DISCORD_WEBHOOK_URL for Discord,
+// TELEGRAM_BOT_TOKEN plus TELEGRAM_CHAT_ID for Telegram, and EMAIL_FROM plus
+// EMAIL_TO for email, sent through SendGrid when SENDGRID_API_KEY is set or SMTP_HOST otherwise.
+func FromEnv() []Notifier {
+	var notifiers []Notifier
+
+	if channel := os.Getenv("SLACK_NOTIFY_CHANNEL"); channel != "" {
+		notifiers = append(notifiers, NewSlackNotifier(slack.New(os.Getenv("SLACK_BOT_TOKEN")), channel))
+	}
+
+	if webhookURL := os.Getenv("DISCORD_WEBHOOK_URL"); webhookURL != "" {
+		notifiers = append(notifiers, NewDiscordNotifier(webhookURL))
+	}
+
+	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
+	chatID := os.Getenv("TELEGRAM_CHAT_ID")
+	if botToken != "" && chatID != "" {
+		notifiers = append(notifiers, NewTelegramNotifier(botToken, chatID))
+	} else if botToken != "" || chatID != "" {
+		log.Printf("Telegram notifications disabled: both TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID are required")
+	}
+
+	if to := parseRecipients(os.Getenv("EMAIL_TO")); len(to) > 0 {
+		from := os.Getenv("EMAIL_FROM")
+		switch {
+		case from == "":
+			log.Printf("Email notifications disabled: EMAIL_FROM is required")
+		case os.Getenv("SENDGRID_API_KEY") != "":
+			notifiers = append(notifiers, NewEmailNotifier(from, to, NewSendGridTransport(os.Getenv("SENDGRID_API_KEY"))))
+		case os.Getenv("SMTP_HOST") != "":
+			transport := NewSMTPTransport(os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+			notifiers = append(notifiers, NewEmailNotifier(from, to, transport))
+		default:
+			log.Printf("Email notifications disabled: set SENDGRID_API_KEY or SMTP_HOST")
+		}
+	}
+
+	return notifiers
+}
+
+// NotifyAll sends the message through every notifier, logging failures as warnings so one broken
+// channel does not prevent delivery to the others or fail the scan that produced the message. Attachments are only sent to
+// notifiers that support them.
+func NotifyAll(notifiers []Notifier, subject, message string, attachments ...Attachment) {
+	for _, n := range notifiers {
+		var err error
+		if an, ok := n.(AttachmentNotifier); ok && len(attachments) > 0 {
+			err = an.NotifyWithAttachments(subject, message, attachments)
+		} else {
+			err = n.Notify(subject, message)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to send %s notification: %v", n.Name(), err)
+		}
+	}
+}
+
+// postJSON posts payload as JSON; headers are additional name/value pairs.
+func postJSON(url string, payload interface{}, headers ...string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %s", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		responseData, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, responseData)
+	}
+
+	return nil
+}
+
+// chunk splits text into pieces of at most limit bytes, preferring line boundaries.
+func chunk(text string, limit int) []string {
+	var chunks []string
+	for len(text) > limit {
+		cut := strings.LastIndexByte(text[:limit], '\n')
+		if cut <= 0 {
+			cut = limit
+		}
+		chunks = append(chunks, text[:cut])
+		text = text[cut:]
+		if len(text) > 0 && text[0] == '\n' {
+			text = text[1:]
+		}
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
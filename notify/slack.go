@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"bytes"
+
+	"github.com/slack-go/slack"
+)
+
+// SlackNotifier posts messages to a fixed Slack channel, independent of the channel a command came from.
+type SlackNotifier struct {
+	client  *slack.Client
+	channel string
+}
+
+func NewSlackNotifier(client *slack.Client, channel string) *SlackNotifier {
+	return &SlackNotifier{client: client, channel: channel}
+}
+
+func (s *SlackNotifier) Name() string {
+	return "Slack"
+}
+
+func (s *SlackNotifier) Notify(subject, message string) error {
+	_, _, err := s.client.PostMessage(s.channel, slack.MsgOptionText("*"+subject+"*\n"+message, false))
+	return err
+}
+
+func (s *SlackNotifier) NotifyWithAttachments(subject, message string, attachments []Attachment) error {
+	if err := s.Notify(subject, message); err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		_, err := s.client.UploadFileV2(slack.UploadFileV2Parameters{
+			Reader:   bytes.NewReader(attachment.Data),
+			FileSize: len(attachment.Data),
+			Filename: attachment.Name,
+			Title:    attachment.Name,
+			Channel:  s.channel,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
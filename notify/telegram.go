@@ -0,0 +1,33 @@
+package notify
+
+import "fmt"
+
+const telegramMessageLimit = 4096
+
+// TelegramNotifier sends messages to a chat through a Telegram bot.
+type TelegramNotifier struct {
+	botToken string
+	chatID   string
+}
+
+func NewTelegramNotifier(botToken, chatID string) *TelegramNotifier {
+	return &TelegramNotifier{botToken: botToken, chatID: chatID}
+}
+
+func (t *TelegramNotifier) Name() string {
+	return "Telegram"
+}
+
+func (t *TelegramNotifier) Notify(subject, message string) error {
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.botToken)
+	for _, part := range chunk(subject+"\n"+message, telegramMessageLimit) {
+		payload := map[string]string{
+			"chat_id": t.chatID,
+			"text":    part,
+		}
+		if err := postJSON(apiURL, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package paper
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// settlementLookback is how many days of history before expiration are fetched for the settlement close,
+// so a holiday expiration still finds the last trading day.
+const settlementLookback = 7
+
+// Mark is an open trade valued at current quotes.
+type Mark struct {
+	Trade           Trade
+	UnderlyingPrice float64
+	MidDebit        float64 // Debit per share to close at the legs' mid prices
+	NaturalDebit    float64 // Debit per share to close crossing every bid-ask spread
+}
+
+// OpenPnL is the dollar profit of the trade were it closed at the mid prices.
+func (m Mark) OpenPnL() float64 {
+	return (m.Trade.Credit - m.MidDebit) * m.Trade.multiplier() * float64(m.Trade.Quantity)
+}
+
+// Refresh settles the open trades whose expiration has passed at the underlying's close on the
+// expiration date, or its open for AM-settled index options, then marks the remaining open trades to market with fresh quotes.
+func Refresh(store *Store, token string, now time.Time) ([]Mark, error) {
+	var open []Trade
+	for _, trade := range store.List() {
+		if trade.Closed {
+			continue
+		}
+		tau, err := market.YearsToExpiration(trade.Expiration, now)
+		if err != nil {
+			log.Printf("Error reading expiration of paper trade %d: %v", trade.ID, err)
+			continue
+		}
+		if tau > 0 {
+			open = append(open, trade)
+			continue
+		}
+		settlement, err := settlementPrice(trade.Underlying, trade.Expiration, trade.amSettled(), token)
+		if err != nil {
+			log.Printf("Error settling paper trade %d: %v", trade.ID, err)
+			continue
+		}
+		if _, err := store.Close(trade.ID, trade.intrinsicDebit(settlement), "expired", now); err != nil {
+			return nil, err
+		}
+	}
+	if len(open) == 0 {
+		return nil, nil
+	}
+
+	symbolSet := make(map[string]bool)
+	var symbols []string
+	for _, trade := range open {
+		for _, symbol := range append([]string{trade.Underlying}, legSymbols(trade)...) {
+			if !symbolSet[symbol] {
+				symbolSet[symbol] = true
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	quotes, err := fetchQuotes(symbols, token)
+	if err != nil {
+		return nil, err
+	}
+
+	var marks []Mark
+	for _, trade := range open {
+		mark, err := MarkTrade(trade, quotes)
+		if err != nil {
+			log.Printf("Error marking paper trade %d: %v", trade.ID, err)
+			continue
+		}
+		marks = append(marks, mark)
+	}
+	return marks, nil
+}
+
+// CloseAtMarket closes an open trade at the natural debit of its legs' current quotes.
+func CloseAtMarket(store *Store, id int, token string, now time.Time) (Trade, error) {
+	trade, ok := store.Get(id)
+	if !ok {
+		return Trade{}, fmt.Errorf("no paper trade with ID %d", id)
+	}
+	if trade.Closed {
+		return Trade{}, fmt.Errorf("paper trade %d is already closed", id)
+	}
+	quotes, err := fetchQuotes(append([]string{trade.Underlying}, legSymbols(trade)...), token)
+	if err != nil {
+		return Trade{}, err
+	}
+	mark, err := MarkTrade(trade, quotes)
+	if err != nil {
+		return Trade{}, err
+	}
+	return store.Close(id, mark.NaturalDebit, "closed", now)
+}
+
+// MarkTrade values a trade from current quotes of its legs and underlying. Closing buys back the short
+// legs at the ask and sells the long legs at the bid.
+func MarkTrade(trade Trade, quotes map[string]tradier.Option) (Mark, error) {
+	underlying, ok := quotes[trade.Underlying]
+	if !ok {
+		return Mark{}, fmt.Errorf("no quote for %s", trade.Underlying)
+	}
+	spot := underlying.Price()
+
+	mark := Mark{Trade: trade, UnderlyingPrice: spot}
+	for _, leg := range trade.Legs {
+		quote, ok := quotes[leg.Symbol]
+		if !ok {
+			return Mark{}, fmt.Errorf("no quote for %s", leg.Symbol)
+		}
+		q := float64(leg.Quantity)
+		mark.MidDebit += q * (quote.Bid + quote.Ask) / 2
+		if leg.Quantity > 0 {
+			mark.NaturalDebit += q * quote.Ask
+		} else {
+			mark.NaturalDebit += q * quote.Bid
+		}
+	}
+	return mark, nil
+}
+
+// Summary compares the outcomes of the closed trades with the model's predictions at entry.
+type Summary struct {
+	Closed       int
+	Wins         int
+	WinRate      float64 // Fraction of closed trades with a positive realized P&L
+	PredictedPoP float64 // Mean predicted probability of profit of the closed trades
+	Brier        float64 // Mean squared error of the predicted PoP against the outcomes
+	RealizedPnL  float64 // Total dollar P&L of the closed trades
+	MeanPnL      float64 // Mean realized P&L per share
+	PredictedEV  float64 // Mean predicted expected value per share
+	Open         int
+	OpenPnL      float64 // Dollar P&L of the marked open trades at mid prices
+}
+
+// Summarize reports the realized results of the trades against their predictions, and the open P&L of
+// the marked trades.
+func Summarize(trades []Trade, marks []Mark) Summary {
+	var summary Summary
+	for _, trade := range trades {
+		if !trade.Closed {
+			continue
+		}
+		win := 0.0
+		if trade.RealizedPnL() > 0 {
+			win = 1
+			summary.Wins++
+		}
+		summary.Closed++
+		summary.PredictedPoP += trade.PoP
+		summary.Brier += math.Pow(trade.PoP-win, 2)
+		summary.RealizedPnL += trade.RealizedPnL()
+		summary.MeanPnL += trade.Credit - trade.ExitDebit
+		summary.PredictedEV += trade.EV
+	}
+	if summary.Closed > 0 {
+		n := float64(summary.Closed)
+		summary.WinRate = float64(summary.Wins) / n
+		summary.PredictedPoP /= n
+		summary.Brier /= n
+		summary.MeanPnL /= n
+		summary.PredictedEV /= n
+	}
+	for _, mark := range marks {
+		summary.Open++
+		summary.OpenPnL += mark.OpenPnL()
+	}
+	return summary
+}
+
+// Report describes the open marks, the closed trades and the summary.
+func Report(trades []Trade, marks []Mark) string {
+	var msg strings.Builder
+	if len(marks) > 0 {
+		msg.WriteString("Open paper trades:\n")
+		for _, m := range marks {
+			t := m.Trade
+			msg.WriteString(fmt.Sprintf("  %d: %dx %s %s expiring %s, entered %s for %.2f credit (PoP %.1f%%), underlying %.2f, close %.2f mid / %.2f natural, open P&L %+.2f\n",
+				t.ID, t.Quantity, t.Underlying, t.Strategy, t.Expiration, t.EnteredAt.Format("2006-01-02 15:04"), t.Credit, t.PoP*100,
+				m.UnderlyingPrice, m.MidDebit, m.NaturalDebit, m.OpenPnL()))
+		}
+	}
+
+	var closed []Trade
+	for _, trade := range trades {
+		if trade.Closed {
+			closed = append(closed, trade)
+		}
+	}
+	if len(closed) > 0 {
+		msg.WriteString("Closed paper trades:\n")
+		for _, t := range closed {
+			msg.WriteString(fmt.Sprintf("  %d: %dx %s %s expiring %s, %.2f credit, %s %s for %.2f, realized P&L %+.2f (PoP %.1f%%)\n",
+				t.ID, t.Quantity, t.Underlying, t.Strategy, t.Expiration, t.Credit, t.ExitReason, t.ClosedAt.Format("2006-01-02"), t.ExitDebit, t.RealizedPnL(), t.PoP*100))
+		}
+	}
+
+	s := Summarize(trades, marks)
+	if s.Closed == 0 && s.Open == 0 {
+		return "No paper trades"
+	}
+	msg.WriteString(fmt.Sprintf("Open: %d trades, P&L %+.2f\n", s.Open, s.OpenPnL))
+	if s.Closed > 0 {
+		msg.WriteString(fmt.Sprintf("Closed: %d trades, realized P&L %+.2f, %d wins (%.1f%% vs %.1f%% predicted PoP, Brier %.3f), mean %+.2f per share vs %+.2f predicted EV\n",
+			s.Closed, s.RealizedPnL, s.Wins, s.WinRate*100, s.PredictedPoP*100, s.Brier, s.MeanPnL, s.PredictedEV))
+	}
+	return msg.String()
+}
+
+func legSymbols(trade Trade) []string {
+	symbols := make([]string, len(trade.Legs))
+	for i, leg := range trade.Legs {
+		symbols[i] = leg.Symbol
+	}
+	return symbols
+}
+
+func fetchQuotes(symbols []string, token string) (map[string]tradier.Option, error) {
+	quotes, err := tradier.GET_MARKET_QUOTES(symbols, token)
+	if err != nil {
+		return nil, err
+	}
+	bySymbol := make(map[string]tradier.Option, len(quotes))
+	for _, quote := range quotes {
+		bySymbol[quote.Symbol] = quote
+	}
+	return bySymbol, nil
+}
+
+// settlementPrice is the underlying's close on the last trading day up to the expiration date. AM-settled
+// options settle at the opening prices of the expiration date instead, approximated by the day's open.
+func settlementPrice(underlying, expiration string, amSettled bool, token string) (float64, error) {
+	date, err := time.Parse(market.DateLayout, expiration)
+	if err != nil {
+		return 0, err
+	}
+	start := date.AddDate(0, 0, -settlementLookback).Format(market.DateLayout)
+	history, err := tradier.GET_QUOTES(underlying, start, expiration, "daily", token)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch settlement price: %s", err)
+	}
+	days := history.History.Day
+	if len(days) == 0 {
+		return 0, fmt.Errorf("no history for %s up to %s", underlying, expiration)
+	}
+	last := days[len(days)-1]
+	if amSettled && last.Date == expiration {
+		return last.Open, nil
+	}
+	return last.Close, nil
+}
@@ -0,0 +1,229 @@
+package paper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/results"
+)
+
+// Leg is one option of a paper trade. Quantity is per unit of the trade, positive for short contracts
+// and negative for long ones.
+type Leg struct {
+	Symbol     string  `json:"symbol"`
+	OptionType string  `json:"option_type"`
+	Strike     float64 `json:"strike"`
+	Quantity   int     `json:"quantity"`
+}
+
+// Trade is a hypothetical fill of a scan result's ticket, with the model's predictions at entry.
+type Trade struct {
+	ID         int       `json:"id"`
+	Underlying string    `json:"underlying"`
+	Strategy   string    `json:"strategy"`
+	Expiration string    `json:"expiration"`
+	Legs       []Leg     `json:"legs"`
+	Quantity   int       `json:"quantity"`             // Units of the ticket's position
+	Credit     float64   `json:"credit"`               // Credit per share assumed filled at entry
+	PoP        float64   `json:"pop"`                  // Predicted probability of profit at entry
+	EV         float64   `json:"expected_value"`       // Predicted expected value per share at entry
+	Multiplier float64   `json:"multiplier,omitempty"` // Dollars per point of the position's price, 0 for trades entered before it was recorded
+	ChannelID  string    `json:"channel_id,omitempty"` // Slack channel the trade was entered from
+	EnteredAt  time.Time `json:"entered_at"`
+
+	Closed     bool      `json:"closed"`
+	ClosedAt   time.Time `json:"closed_at,omitempty"`
+	ExitDebit  float64   `json:"exit_debit,omitempty"`  // Debit per share paid to close, or the intrinsic value at expiry
+	ExitReason string    `json:"exit_reason,omitempty"` // "closed" or "expired"
+}
+
+// NewTrade enters quantity units of a ticket at its credit. Leg strikes and types are read from the OCC
+// option symbols so expired trades can be settled without quotes.
+func NewTrade(ticket results.Ticket, quantity int, channelID string, now time.Time) (Trade, error) {
+	if ticket.Underlying == "" || len(ticket.Order.Legs) == 0 {
+		return Trade{}, fmt.Errorf("ticket has no underlying or legs")
+	}
+	if quantity < 1 {
+		return Trade{}, fmt.Errorf("quantity must be at least 1")
+	}
+
+	trade := Trade{
+		Underlying: ticket.Underlying,
+		Strategy:   ticket.Strategy,
+		Expiration: ticket.Expiration,
+		Quantity:   quantity,
+		Credit:     ticket.Credit,
+		PoP:        ticket.PoP,
+		EV:         ticket.EV,
+		Multiplier: ticket.Multiplier,
+		ChannelID:  channelID,
+		EnteredAt:  now,
+	}
+	for _, ticketLeg := range ticket.Order.Legs {
+		optionType, strike, err := parseOptionSymbol(ticketLeg.OptionSymbol)
+		if err != nil {
+			return Trade{}, err
+		}
+		leg := Leg{Symbol: ticketLeg.OptionSymbol, OptionType: optionType, Strike: strike, Quantity: ticketLeg.Quantity}
+		if ticketLeg.Side == "buy_to_open" {
+			leg.Quantity = -leg.Quantity
+		}
+		trade.Legs = append(trade.Legs, leg)
+	}
+	return trade, nil
+}
+
+// parseOptionSymbol reads the type and strike from an OCC symbol such as AAPL240119P00180000.
+func parseOptionSymbol(symbol string) (string, float64, error) {
+	if len(symbol) < 16 {
+		return "", 0, fmt.Errorf("invalid option symbol %q", symbol)
+	}
+	code := symbol[len(symbol)-15:]
+	strike, err := strconv.Atoi(code[7:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid strike in option symbol %q", symbol)
+	}
+	switch code[6] {
+	case 'P':
+		return "put", float64(strike) / 1000, nil
+	case 'C':
+		return "call", float64(strike) / 1000, nil
+	}
+	return "", 0, fmt.Errorf("invalid option type in option symbol %q", symbol)
+}
+
+// amSettled reports whether the trade's options settle at the opening quotation of their expiration date,
+// read from the root of the first leg's OCC symbol.
+func (t Trade) amSettled() bool {
+	if len(t.Legs) == 0 || len(t.Legs[0].Symbol) < 16 {
+		return false
+	}
+	symbol := t.Legs[0].Symbol
+	return models.IsAMSettledRoot(strings.TrimSpace(symbol[:len(symbol)-15]))
+}
+
+// RealizedPnL is the dollar profit of a closed trade.
+func (t Trade) RealizedPnL() float64 {
+	return (t.Credit - t.ExitDebit) * t.multiplier() * float64(t.Quantity)
+}
+
+// multiplier is the dollars per point of the trade's price, the standard contract's for trades and
+// tickets without one.
+func (t Trade) multiplier() float64 {
+	if t.Multiplier > 0 {
+		return t.Multiplier
+	}
+	return models.ContractMultiplier
+}
+
+// intrinsicDebit is the per share cost of settling the trade's legs with the underlying at price.
+func (t Trade) intrinsicDebit(price float64) float64 {
+	debit := 0.0
+	for _, leg := range t.Legs {
+		intrinsic := max(0, leg.Strike-price)
+		if leg.OptionType == "call" {
+			intrinsic = max(0, price-leg.Strike)
+		}
+		debit += float64(leg.Quantity) * intrinsic
+	}
+	return debit
+}
+
+// Store persists paper trades to a JSON file.
+type Store struct {
+	path   string
+	mu     sync.RWMutex
+	trades []Trade
+}
+
+// Open loads the paper trades at path, starting empty if the file does not exist yet.
+func Open(path string) (*Store, error) {
+	store := &Store{path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read paper trades: %s", err)
+	}
+
+	if err := json.Unmarshal(data, &store.trades); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal paper trades: %s", err)
+	}
+
+	return store, nil
+}
+
+// Enter assigns the trade the next free ID and writes the trades back to disk.
+func (s *Store) Enter(trade Trade) (Trade, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trade.ID = 1
+	for _, existing := range s.trades {
+		trade.ID = max(trade.ID, existing.ID+1)
+	}
+	s.trades = append(s.trades, trade)
+
+	return trade, s.save()
+}
+
+// Close records the exit of an open trade.
+func (s *Store) Close(id int, debit float64, reason string, at time.Time) (Trade, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, trade := range s.trades {
+		if trade.ID != id {
+			continue
+		}
+		if trade.Closed {
+			return Trade{}, fmt.Errorf("paper trade %d is already closed", id)
+		}
+		trade.Closed, trade.ClosedAt, trade.ExitDebit, trade.ExitReason = true, at, debit, reason
+		s.trades[i] = trade
+		return trade, s.save()
+	}
+	return Trade{}, fmt.Errorf("no paper trade with ID %d", id)
+}
+
+// Get returns the trade with the given ID.
+func (s *Store) Get(id int) (Trade, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, trade := range s.trades {
+		if trade.ID == id {
+			return trade, true
+		}
+	}
+	return Trade{}, false
+}
+
+// List returns a copy of the paper trades.
+func (s *Store) List() []Trade {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trades := make([]Trade, len(s.trades))
+	copy(trades, s.trades)
+	return trades
+}
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.trades, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal paper trades: %s", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write paper trades: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package paper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/results"
+)
+
+func TestPnLUsesTicketMultiplier(t *testing.T) {
+	var spread models.SpreadWithProbabilities
+	spread.Spread.SpreadType = "Bull Put"
+	spread.Spread.ShortLeg.Option.Underlying = "/ES"
+	spread.Spread.ShortLeg.Option.Symbol, spread.Spread.ShortLeg.Option.Strike = "ES  260116P05000000", 5000
+	spread.Spread.LongLeg.Option.Symbol, spread.Spread.LongLeg.Option.Strike = "ES  260116P04950000", 4950
+	spread.Spread.ShortLeg.Option.ContractSize, spread.Spread.LongLeg.Option.ContractSize = 50, 50
+	spread.Spread.SpreadCredit = 10
+
+	trade, err := NewTrade(results.NewTicket(spread), 2, "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	trade.ExitDebit = 4
+	if got, want := trade.RealizedPnL(), 6.0*50*2; got != want {
+		t.Errorf("realized P&L = %v, want %v at the ES multiplier", got, want)
+	}
+	mark := Mark{Trade: trade, MidDebit: 7}
+	if got, want := mark.OpenPnL(), 3.0*50*2; got != want {
+		t.Errorf("open P&L = %v, want %v at the ES multiplier", got, want)
+	}
+
+	// Trades saved before the multiplier was recorded are standard equity options
+	trade.Multiplier = 0
+	if got, want := trade.RealizedPnL(), 6.0*100*2; got != want {
+		t.Errorf("realized P&L without a multiplier = %v, want %v", got, want)
+	}
+}
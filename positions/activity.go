@@ -0,0 +1,91 @@
+package positions
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// contractDay is one contract's trading activity in an archived chain.
+type contractDay struct {
+	volume       int
+	openInterest int
+}
+
+// AnnotateActivity sets each spread's contract activity from its current legs and the chains of
+// earlier scans, ordered newest first. Contracts missing from an earlier chain count as not traded.
+func AnnotateActivity(spreads []models.SpreadWithProbabilities, history []map[string]*tradier.OptionChain) {
+	days := make([]map[string]contractDay, len(history))
+	for i, chain := range history {
+		days[i] = make(map[string]contractDay)
+		for _, expiration := range chain {
+			if expiration == nil {
+				continue
+			}
+			for _, option := range expiration.Options.Option {
+				days[i][option.Symbol] = contractDay{volume: option.Volume, openInterest: option.OpenInterest}
+			}
+		}
+	}
+
+	for i := range spreads {
+		legs := models.OptionLegs(spreads[i].Spread)
+
+		volume, openInterest := 0, 0
+		for _, leg := range legs {
+			volume += leg.Volume
+			openInterest += leg.OpenInterest
+		}
+		volumes := []float64{float64(volume)}
+		activity := models.ContractActivity{Days: 1 + len(days)}
+		if legsTraded(legs...) {
+			activity.ActiveDays++
+		}
+
+		oldestOI := openInterest
+		for _, day := range days {
+			dayVolume, dayOI, traded := 0, 0, true
+			for _, leg := range legs {
+				dayVolume += day[leg.Symbol].volume
+				dayOI += day[leg.Symbol].openInterest
+				traded = traded && day[leg.Symbol].volume > 0
+			}
+			volumes = append(volumes, float64(dayVolume))
+			if traded {
+				activity.ActiveDays++
+			}
+			if _, ok := day[legs[0].Symbol]; ok {
+				oldestOI = dayOI
+			}
+		}
+
+		activity.MedianVolume = median(volumes)
+		activity.OpenInterestChange = openInterest - oldestOI
+		spreads[i].Activity = activity
+	}
+}
+
+// ActivityScore rewards steady trading: the log of the median daily volume, scaled by the share of
+// days on which both legs traded, so a one-day spike counts for little. With no history it is the
+// log of the current volume when both legs traded.
+func ActivityScore(activity models.ContractActivity) float64 {
+	if activity.Days == 0 {
+		return 0
+	}
+	return math.Log1p(activity.MedianVolume) * float64(activity.ActiveDays) / float64(activity.Days)
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
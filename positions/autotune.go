@@ -0,0 +1,223 @@
+package positions
+
+import (
+	"log"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/probability"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	tuneInterval   = 2 * time.Second  // Minimum measurement window per setting
+	tuneMinSamples = 8                // Simulated spreads a window needs before its throughput is trusted
+	tuneDuration   = 30 * time.Second // Settings are fixed after this long
+	tuneGain       = 1.05             // Improvement required to keep doubling a setting
+)
+
+// workerPool runs spread workers whose number can change while jobs are being processed.
+// The result channel is closed once the job queue is drained and every worker has exited.
+type workerPool struct {
+	jobs    <-chan job
+	results chan<- models.SpreadWithProbabilities
+	work    func(job)
+
+	mu      sync.Mutex
+	size    int
+	active  int
+	drained bool
+	retire  chan struct{}
+}
+
+func newWorkerPool(jobs <-chan job, results chan<- models.SpreadWithProbabilities, work func(job)) *workerPool {
+	return &workerPool{jobs: jobs, results: results, work: work, retire: make(chan struct{}, maxSpreadWorkers())}
+}
+
+// Size returns the target number of workers.
+func (p *workerPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+// Resize starts or retires workers to reach n, never going below one.
+func (p *workerPool) Resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n = max(n, 1)
+	if p.drained {
+		return
+	}
+	for ; p.size < n; p.size++ {
+		p.active++
+		go p.run()
+	}
+	for ; p.size > n; p.size-- {
+		p.retire <- struct{}{}
+	}
+}
+
+func (p *workerPool) run() {
+	defer p.exit()
+	for {
+		select {
+		case <-p.retire:
+			return
+		case j, ok := <-p.jobs:
+			if !ok {
+				p.mu.Lock()
+				p.drained = true
+				p.mu.Unlock()
+				return
+			}
+			p.work(j)
+		}
+	}
+}
+
+func (p *workerPool) exit() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active--
+	if p.active == 0 && p.drained {
+		close(p.results)
+	}
+}
+
+// knob is a concurrency setting the autotuner can change.
+type knob struct {
+	name string
+	get  func() int
+	set  func(int)
+	max  int
+}
+
+// autotune doubles each knob in turn while the spreads simulated per second keep improving,
+// settling on the best setting found, and stops once done is closed or tuneDuration has passed.
+func autotune(knobs []knob, simulated *atomic.Int64, done <-chan struct{}) {
+	deadline := time.Now().Add(tuneDuration)
+
+	measure := func() (float64, bool) {
+		start, startCount := time.Now(), simulated.Load()
+		ticker := time.NewTicker(tuneInterval / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return 0, false
+			case <-ticker.C:
+			}
+			elapsed, count := time.Since(start), simulated.Load()-startCount
+			if elapsed >= tuneInterval && count >= tuneMinSamples {
+				return float64(count) / elapsed.Seconds(), true
+			}
+			if time.Now().After(deadline) {
+				return 0, false
+			}
+		}
+	}
+
+	for _, k := range knobs {
+		best, ok := measure()
+		if !ok {
+			return
+		}
+		for k.get()*2 <= k.max {
+			previous := k.get()
+			k.set(previous * 2)
+			throughput, ok := measure()
+			if !ok {
+				k.set(previous)
+				return
+			}
+			if throughput < best*tuneGain {
+				k.set(previous)
+				break
+			}
+			best = throughput
+		}
+		log.Printf("Autotune: %d %s, %.2f spreads/sec", k.get(), k.name, best)
+	}
+}
+
+// maxSpreadWorkers bounds the spread workers. Simulations are limited by the simulation budget, so
+// workers beyond a few per CPU only wait for it.
+func maxSpreadWorkers() int {
+	return 4 * runtime.NumCPU()
+}
+
+// maxSimulationBudget bounds the simulation budget the autotuner tries, twice the CPUs to cover the time
+// simulations spend off the CPU.
+func maxSimulationBudget() int {
+	return 2 * runtime.NumCPU()
+}
+
+// The simulations per spread and in flight are shared by every scan in the process. Those not configured
+// at startup are tuned by one scan at a time, the one holding simulationTuning, so concurrent scans do not
+// fight over them.
+var (
+	simulationTuning          sync.Mutex
+	tuneSimulationConcurrency atomic.Bool
+	tuneSimulationBudget      atomic.Bool
+)
+
+func init() {
+	tuneSimulationConcurrency.Store(true)
+	tuneSimulationBudget.Store(true)
+}
+
+// ConfigureSimulations fixes the simulations run concurrently per spread and across every spread for the
+// life of the process, before any scan starts. Settings left at zero start at the number of CPUs and are
+// autotuned by the scans.
+func ConfigureSimulations(concurrency, budget int) {
+	if concurrency > 0 {
+		probability.SetSimulationConcurrency(concurrency)
+	}
+	if budget > 0 {
+		probability.SetSimulationBudget(budget)
+	}
+	tuneSimulationConcurrency.Store(concurrency <= 0)
+	tuneSimulationBudget.Store(budget <= 0)
+}
+
+// startWorkers launches the spread workers for a scan. With opts.Workers at zero the workers start at the
+// number of CPUs and are tuned for throughput during the first seconds of the scan, as are the simulation
+// settings not configured by ConfigureSimulations unless another scan is already tuning them. Whatever the
+// workers and simulations per spread, at most the simulation budget of simulations run at once.
+func startWorkers(jobs <-chan job, results chan<- models.SpreadWithProbabilities, opts ScanOptions, history tradier.QuoteHistory, chain map[string]*tradier.OptionChain, globalModels probability.GlobalModels, avgVol float64, done <-chan struct{}) {
+	var simulated atomic.Int64
+	pool := newWorkerPool(jobs, results, func(j job) {
+		processJob(j, results, history, chain, globalModels, avgVol)
+		simulated.Add(1)
+	})
+
+	var knobs []knob
+	if opts.Workers > 0 {
+		pool.Resize(min(opts.Workers, maxSpreadWorkers()))
+	} else {
+		pool.Resize(runtime.NumCPU())
+		knobs = append(knobs, knob{name: "spread workers", get: pool.Size, set: pool.Resize, max: maxSpreadWorkers()})
+	}
+
+	tuning := (tuneSimulationConcurrency.Load() || tuneSimulationBudget.Load()) && simulationTuning.TryLock()
+	if tuning && tuneSimulationConcurrency.Load() {
+		knobs = append(knobs, knob{name: "simulations per spread", get: probability.SimulationConcurrency, set: probability.SetSimulationConcurrency, max: probability.MaxSimulationConcurrency})
+	}
+	if tuning && tuneSimulationBudget.Load() {
+		knobs = append(knobs, knob{name: "simulations in flight", get: probability.SimulationBudget, set: probability.SetSimulationBudget, max: maxSimulationBudget()})
+	}
+
+	if len(knobs) > 0 {
+		go func() {
+			if tuning {
+				defer simulationTuning.Unlock()
+			}
+			autotune(knobs, &simulated, done)
+		}()
+	}
+}
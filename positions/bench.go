@@ -0,0 +1,123 @@
+package positions
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/probability"
+	"github.com/bcdannyboy/stocd/progress"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// ConcurrencyConfig is a fixed setting of a scan's concurrency, benchmarked by BenchmarkConcurrency.
+type ConcurrencyConfig struct {
+	Name                  string
+	Workers               int // Spreads evaluated concurrently
+	SimulationConcurrency int // Simulations run concurrently per spread
+	SimulationBudget      int // Simulations run concurrently across every spread
+}
+
+// ConcurrencyResult is the throughput of a benchmarked ConcurrencyConfig.
+type ConcurrencyResult struct {
+	Config     ConcurrencyConfig
+	Spreads    int // Spreads simulated
+	Elapsed    time.Duration
+	Throughput float64 // Spreads simulated per second
+}
+
+// DefaultConcurrencyConfigs compares nested parallelism left unbounded, every spread worker running a
+// simulation per CPU, with the same simulations bounded by a global budget of one per CPU.
+func DefaultConcurrencyConfigs() []ConcurrencyConfig {
+	cpus := runtime.NumCPU()
+	return []ConcurrencyConfig{
+		{Name: "unbounded", Workers: maxSpreadWorkers(), SimulationConcurrency: cpus, SimulationBudget: probability.MaxSimulationBudget},
+		{Name: "per-spread", Workers: 1, SimulationConcurrency: cpus, SimulationBudget: probability.MaxSimulationBudget},
+		{Name: "budgeted", Workers: cpus, SimulationConcurrency: cpus, SimulationBudget: cpus},
+	}
+}
+
+// BenchmarkConcurrency scans the symbol's spreads once per configuration, with the concurrency fixed
+// rather than autotuned, and reports the spreads simulated per second. The models are calibrated once
+// and the probability cache is cleared before each run, so every configuration simulates the same
+// candidates from scratch. No scan tunes the simulation settings meanwhile, and they are restored after.
+func BenchmarkConcurrency(data SymbolData, riskFreeRate, minReturnOnRisk float64, currentDate time.Time, spreadType string, opts ScanOptions, configs []ConcurrencyConfig) []ConcurrencyResult {
+	bench := newConcurrencyBench(data, riskFreeRate, minReturnOnRisk, currentDate, spreadType, opts)
+	defer bench.close()
+
+	var results []ConcurrencyResult
+	for _, config := range configs {
+		fmt.Printf("Benchmarking %s: %d spread workers, %d simulations per spread, %d in flight\n", config.Name, config.Workers, config.SimulationConcurrency, config.SimulationBudget)
+		results = append(results, bench.run(config))
+	}
+	return results
+}
+
+// concurrencyBench is a symbol's chain with the models calibrated to it, scanned under fixed concurrency
+// settings. It holds simulationTuning until closed.
+type concurrencyBench struct {
+	data                           SymbolData
+	chain                          map[string]*tradier.OptionChain
+	riskFreeRate, minReturnOnRisk  float64
+	currentDate                    time.Time
+	spreadType                     string
+	opts                           ScanOptions
+	yzVolatilities, rsVolatilities map[string]float64
+	localVolSurface                models.VolatilitySurface
+	globalModels                   probability.GlobalModels
+	avgVol                         float64
+	premiums                       map[string]float64
+	restore                        func()
+}
+
+func newConcurrencyBench(data SymbolData, riskFreeRate, minReturnOnRisk float64, currentDate time.Time, spreadType string, opts ScanOptions) *concurrencyBench {
+	b := &concurrencyBench{data: data, riskFreeRate: riskFreeRate, minReturnOnRisk: minReturnOnRisk, currentDate: currentDate, spreadType: spreadType, opts: opts}
+	b.chain, _ = opts.standardChain(data.Chain)
+	b.chain, _ = opts.validQuotes(b.chain, data.UnderlyingPrice, riskFreeRate, currentDate)
+	b.yzVolatilities = models.CalculateYangZhangVolatility(data.History)
+	b.rsVolatilities = models.CalculateRogersSatchellVolatility(data.History)
+	b.localVolSurface = models.CalculateLocalVolatilitySurface(b.chain, data.UnderlyingPrice)
+	b.avgVol = (calculateAverageVolatility(b.yzVolatilities) + calculateAverageVolatility(b.rsVolatilities) + calculateAverageImpliedVolatility(b.chain)) / 3
+
+	b.globalModels = calibrateGlobalModels(data.History, b.chain, data.UnderlyingPrice, riskFreeRate, b.yzVolatilities, b.rsVolatilities, currentDate, nil)
+	b.globalModels.IntradayVol = opts.IntradayVolatility
+	b.premiums = varianceRiskPremiums(b.chain, data.UnderlyingPrice, data.History, currentDate)
+	b.opts.Stream = nil
+
+	simulationTuning.Lock()
+	concurrency, budget := probability.SimulationConcurrency(), probability.SimulationBudget()
+	b.restore = func() {
+		probability.SetSimulationConcurrency(concurrency)
+		probability.SetSimulationBudget(budget)
+		simulationTuning.Unlock()
+	}
+	return b
+}
+
+// run scans the chain from scratch under the configuration.
+func (b *concurrencyBench) run(config ConcurrencyConfig) ConcurrencyResult {
+	probability.ClearProbabilityCache()
+	b.globalModels.Paths = probability.NewPathCache()
+	opts := b.opts
+	opts.Workers = config.Workers
+	probability.SetSimulationConcurrency(config.SimulationConcurrency)
+	probability.SetSimulationBudget(config.SimulationBudget)
+
+	start := time.Now()
+	tracker := progress.Start("benchmark " + config.Name)
+	processChainOptimized(b.chain, b.data.UnderlyingPrice, b.riskFreeRate, b.yzVolatilities, b.rsVolatilities, b.localVolSurface, b.minReturnOnRisk, b.currentDate, b.spreadType, opts, b.data.History, b.globalModels, b.avgVol, b.premiums, tracker, nil)
+	elapsed := time.Since(start)
+	tracker.Finish()
+
+	result := ConcurrencyResult{Config: config, Spreads: tracker.Snapshot().Done, Elapsed: elapsed}
+	if elapsed > 0 {
+		result.Throughput = float64(result.Spreads) / elapsed.Seconds()
+	}
+	return result
+}
+
+// close restores the simulation settings and lets scans tune them again.
+func (b *concurrencyBench) close() {
+	b.restore()
+}
@@ -0,0 +1,28 @@
+package positions
+
+import (
+	"testing"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/tradiertest"
+)
+
+// BenchmarkScanConcurrency simulates the same Bull Put candidates of a synthetic market under each of the
+// DefaultConcurrencyConfigs, reporting the spreads simulated per second.
+func BenchmarkScanConcurrency(b *testing.B) {
+	chain, price, history := tradiertest.SyntheticMarket("SYNTH", 0.3, 2)
+	opts := ScanOptions{SimulationCandidates: 16}
+	bench := newConcurrencyBench(SymbolData{Symbol: "SYNTH", Chain: chain, UnderlyingPrice: price, History: history}, 0.04, 0, market.Now(), "Bull Put", opts)
+	defer bench.close()
+
+	for _, config := range DefaultConcurrencyConfigs() {
+		b.Run(config.Name, func(b *testing.B) {
+			spreads := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				spreads += bench.run(config).Spreads
+			}
+			b.ReportMetric(float64(spreads)/b.Elapsed().Seconds(), "spreads/s")
+		})
+	}
+}
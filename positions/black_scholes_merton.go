@@ -1,88 +1,165 @@
 package positions
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/bcdannyboy/stocd/tradier"
 )
 
 const (
-	maxIterations = 1000
-	epsilon       = 1e-8
+	maxIterations        = 1000
+	newtonIterations     = 50
+	epsilon              = 1e-8
+	minImpliedVolatility = 1e-4 // Bracket of the implied volatility solve
+	maxImpliedVolatility = 20
 )
 
+// CalculateOptionMetrics prices the option and its greeks with Black-Scholes-Merton at the implied
+// volatility of its mid price. When no volatility reproduces the mid price, IVError says why and the greeks
+// are computed at Tradier's mid implied volatility, or left zero with the mid price when there is none.
 func CalculateOptionMetrics(option *tradier.Option, underlyingPrice, riskFreeRate float64) BSMResult {
-	T := calculateTimeToMaturity(option.ExpirationDate)
+	return optionMetrics(option, underlyingPrice, riskFreeRate, calculateTimeToMaturity(option.ExpirationDate))
+}
+
+// ExpirationMetrics computes CalculateOptionMetrics for every option of an expiration in one pass, keyed
+// by option symbol, finding each expiration date's time to maturity once.
+func ExpirationMetrics(options []tradier.Option, underlyingPrice, riskFreeRate float64) map[string]BSMResult {
+	maturities := make(map[string]float64)
+	metrics := make(map[string]BSMResult, len(options))
+	for i := range options {
+		option := &options[i]
+		T, ok := maturities[option.ExpirationDate]
+		if !ok {
+			T = calculateTimeToMaturity(option.ExpirationDate)
+			maturities[option.ExpirationDate] = T
+		}
+		metrics[option.Symbol] = optionMetrics(option, underlyingPrice, riskFreeRate, T)
+	}
+	return metrics
+}
+
+// optionMetrics is CalculateOptionMetrics for an option T years from expiration.
+func optionMetrics(option *tradier.Option, underlyingPrice, riskFreeRate, T float64) BSMResult {
 	isCall := option.OptionType == "call"
 
 	// Use mid price as target
 	targetPrice := (option.Bid + option.Ask) / 2
 
 	// Calculate implied volatility
-	impliedVol := calculateImpliedVolatility(targetPrice, underlyingPrice, option.Strike, T, riskFreeRate, isCall)
+	impliedVol, err := calculateImpliedVolatility(targetPrice, underlyingPrice, option.Strike, T, riskFreeRate, isCall)
+	var ivError string
+	volatility := impliedVol
+	if err != nil {
+		ivError = err.Error()
+		impliedVol = 0
+		volatility = option.Greeks.MidIv
+		if volatility <= 0 || T <= 0 {
+			return BSMResult{Price: targetPrice, IVError: ivError}
+		}
+	}
 
-	// Calculate BSM metrics
-	d1 := (math.Log(underlyingPrice/option.Strike) + (riskFreeRate+0.5*impliedVol*impliedVol)*T) / (impliedVol * math.Sqrt(T))
-	d2 := d1 - impliedVol*math.Sqrt(T)
+	result := calculateBSM(underlyingPrice, option.Strike, T, riskFreeRate, volatility, isCall)
+	result.ImpliedVolatility = impliedVol
+	result.IVError = ivError
 
-	var delta, price float64
-	if isCall {
-		delta = normCDF(d1)
-		price = underlyingPrice*normCDF(d1) - option.Strike*math.Exp(-riskFreeRate*T)*normCDF(d2)
-	} else {
-		delta = normCDF(d1) - 1
-		price = option.Strike*math.Exp(-riskFreeRate*T)*normCDF(-d2) - underlyingPrice*normCDF(-d1)
-	}
+	// Calculate Shadow Gammas and Skew Gamma
+	result.ShadowUpGamma, result.ShadowDownGamma = calculateShadowGamma(option, underlyingPrice, riskFreeRate, volatility, T)
+	result.SkewGamma = calculateBSMSkewGamma(option, underlyingPrice, riskFreeRate, volatility, T)
+	return result
+}
 
-	gamma := normPDF(d1) / (underlyingPrice * impliedVol * math.Sqrt(T))
-	vega := underlyingPrice * normPDF(d1) * math.Sqrt(T)
-	theta := -(underlyingPrice*normPDF(d1)*impliedVol)/(2*math.Sqrt(T)) - riskFreeRate*option.Strike*math.Exp(-riskFreeRate*T)*normCDF(d2)
-	rho := option.Strike * T * math.Exp(-riskFreeRate*T) * normCDF(d2)
+// calculateImpliedVolatility solves for the volatility at which Black-Scholes-Merton prices the option at
+// targetPrice. Newton-Raphson from the Brenner-Subrahmanyam approximation usually converges in a few
+// steps; when it stalls or leaves the bracket, Brent's method on the bracket finishes the solve. It fails
+// on invalid inputs and on prices outside the no-arbitrage bounds, which no volatility reproduces.
+func calculateImpliedVolatility(targetPrice, S, K, T, r float64, isCall bool) (float64, error) {
+	switch {
+	case !(S > 0) || !(K > 0) || math.IsInf(S, 0) || math.IsInf(K, 0):
+		return 0, fmt.Errorf("invalid underlying price %v or strike %v", S, K)
+	case !(T > 0):
+		return 0, fmt.Errorf("option has expired")
+	case !(targetPrice > 0) || math.IsInf(targetPrice, 0):
+		return 0, fmt.Errorf("invalid option price %v", targetPrice)
+	}
 
+	discountedStrike := K * math.Exp(-r*T)
+	lower, upper := math.Max(S-discountedStrike, 0), S
 	if !isCall {
-		theta = theta + riskFreeRate*option.Strike*math.Exp(-riskFreeRate*T)
-		rho = -option.Strike * T * math.Exp(-riskFreeRate*T) * normCDF(-d2)
+		lower, upper = math.Max(discountedStrike-S, 0), discountedStrike
+	}
+	if targetPrice <= lower {
+		return 0, fmt.Errorf("price %.4f is at or below the no-arbitrage lower bound %.4f", targetPrice, lower)
+	}
+	if targetPrice >= upper {
+		return 0, fmt.Errorf("price %.4f is at or above the no-arbitrage upper bound %.4f", targetPrice, upper)
 	}
 
-	// Calculate Shadow Gammas and Skew Gamma
-	shadowUpGamma, shadowDownGamma := calculateShadowGamma(option, underlyingPrice, riskFreeRate, impliedVol)
-	skewGamma := calculateBSMSkewGamma(option, underlyingPrice, riskFreeRate, impliedVol)
-
-	return BSMResult{
-		Price:             price,
-		ImpliedVolatility: impliedVol,
-		Delta:             delta,
-		Gamma:             gamma,
-		Theta:             theta,
-		Vega:              vega,
-		Rho:               rho,
-		ShadowUpGamma:     shadowUpGamma,
-		ShadowDownGamma:   shadowDownGamma,
-		SkewGamma:         skewGamma,
+	diff := func(sigma float64) float64 {
+		return calculateOptionPrice(S, K, T, r, sigma, isCall) - targetPrice
 	}
-}
 
-func calculateImpliedVolatility(targetPrice, S, K, T, r float64, isCall bool) float64 {
-	sigma := 0.5 // Initial guess
-	for i := 0; i < maxIterations; i++ {
-		price := calculateOptionPrice(S, K, T, r, sigma, isCall)
+	sigma := math.Max(minImpliedVolatility, math.Min(maxImpliedVolatility, math.Sqrt(2*math.Pi/T)*targetPrice/S))
+	for i := 0; i < newtonIterations; i++ {
+		d := diff(sigma)
+		if math.Abs(d) < epsilon {
+			return sigma, nil
+		}
 		vega := calculateBSMVega(S, K, T, r, sigma)
-
-		diff := price - targetPrice
-		if math.Abs(diff) < epsilon {
-			return sigma
+		if vega < epsilon {
+			break
 		}
-
-		if math.Abs(vega) < epsilon {
+		sigma -= d / vega
+		if !(sigma > minImpliedVolatility && sigma < maxImpliedVolatility) {
 			break
 		}
+	}
+
+	return brentRoot(diff, minImpliedVolatility, maxImpliedVolatility, epsilon, maxIterations)
+}
 
-		sigma = sigma - diff/vega
-		if sigma <= 0 {
-			sigma = 0.0001 // Avoid negative volatility
+// brentRoot finds a root of f in [a, b], where f changes sign, with Brent's method.
+func brentRoot(f func(float64) float64, a, b, tol float64, maxIter int) (float64, error) {
+	fa, fb := f(a), f(b)
+	if fa*fb > 0 {
+		return 0, fmt.Errorf("no root between %v and %v", a, b)
+	}
+	if math.Abs(fa) < math.Abs(fb) {
+		a, b, fa, fb = b, a, fb, fa
+	}
+	c, fc := a, fa
+	d := b - a
+	bisected := true
+	for i := 0; i < maxIter; i++ {
+		if math.Abs(fb) < tol || math.Abs(b-a) < tol*1e-3 {
+			return b, nil
+		}
+		var s float64
+		if fa != fc && fb != fc { // Inverse quadratic interpolation
+			s = a*fb*fc/((fa-fb)*(fa-fc)) + b*fa*fc/((fb-fa)*(fb-fc)) + c*fa*fb/((fc-fa)*(fc-fb))
+		} else { // Secant
+			s = b - fb*(b-a)/(fb-fa)
+		}
+		if (s-(3*a+b)/4)*(s-b) >= 0 ||
+			(bisected && math.Abs(s-b) >= math.Abs(b-c)/2) ||
+			(!bisected && math.Abs(s-b) >= math.Abs(c-d)/2) {
+			s = (a + b) / 2
+			bisected = true
+		} else {
+			bisected = false
+		}
+		fs := f(s)
+		d, c, fc = c, b, fb
+		if fa*fs < 0 {
+			b, fb = s, fs
+		} else {
+			a, fa = s, fs
+		}
+		if math.Abs(fa) < math.Abs(fb) {
+			a, b, fa, fb = b, a, fb, fa
 		}
 	}
-	return math.NaN() // Failed to converge
+	return 0, fmt.Errorf("did not converge in %d iterations", maxIter)
 }
 
 func calculateBSM(S, K, T, r, sigma float64, isCall bool) BSMResult {
@@ -132,8 +209,7 @@ func calculateBSMVega(S, K, T, r, sigma float64) float64 {
 	return S * normPDF(d1) * math.Sqrt(T)
 }
 
-func calculateShadowGamma(option *tradier.Option, S, r, sigma float64) (float64, float64) {
-	T := calculateTimeToMaturity(option.ExpirationDate)
+func calculateShadowGamma(option *tradier.Option, S, r, sigma, T float64) (float64, float64) {
 	isCall := option.OptionType == "call"
 
 	// Calculate up and down scenarios
@@ -154,8 +230,7 @@ func calculateShadowGamma(option *tradier.Option, S, r, sigma float64) (float64,
 	return shadowUpGamma, shadowDownGamma
 }
 
-func calculateBSMSkewGamma(option *tradier.Option, S, r, sigma float64) float64 {
-	T := calculateTimeToMaturity(option.ExpirationDate)
+func calculateBSMSkewGamma(option *tradier.Option, S, r, sigma, T float64) float64 {
 	isCall := option.OptionType == "call"
 
 	// Calculate vega for slightly different volatilities
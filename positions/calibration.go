@@ -0,0 +1,128 @@
+package positions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/probability"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	quotesPerExpiration = 6  // Out-of-the-money options nearest the money calibrated to per expiration
+	quotesPerTenor      = 18 // Options calibrated to per tenor, nearest the money first
+	minTenorQuotes      = 3  // Options a tenor needs to be calibrated
+)
+
+// tenorBounds are the longest days to expiration of the tenors whose options are calibrated together;
+// longer expirations form a last tenor.
+var tenorBounds = []int{7, 21, 45, 90, 180}
+
+// tenorQuotes are the calibration quotes of the expirations between minDays and maxDays.
+type tenorQuotes struct {
+	minDays int
+	maxDays int
+	quotes  []models.OptionQuote
+}
+
+// calibrationQuotes groups the mid prices of the out-of-the-money options with a two-sided market nearest
+// the money into tenors, shortest first. Tenors with too few quotes to calibrate are left out.
+func calibrationQuotes(chain map[string]*tradier.OptionChain, underlyingPrice float64, currentDate time.Time) []tenorQuotes {
+	type candidate struct {
+		quote     models.OptionQuote
+		moneyness float64
+	}
+	tenors := make([]*tenorQuotes, len(tenorBounds)+1)
+	candidates := make([][]candidate, len(tenors))
+
+	for expDate, expiration := range chain {
+		if expiration == nil {
+			continue
+		}
+		days, err := market.DaysToExpiration(expDate, currentDate)
+		if err != nil || days < 0 {
+			continue
+		}
+		t, err := market.YearsToExpiration(expDate, currentDate)
+		if err != nil || t <= 0 {
+			continue
+		}
+
+		var options []candidate
+		for _, option := range expiration.Options.Option {
+			isCall := option.OptionType == "call"
+			if option.Bid <= 0 || option.Ask < option.Bid || isCall != (option.Strike >= underlyingPrice) {
+				continue
+			}
+			options = append(options, candidate{
+				quote:     models.OptionQuote{Strike: option.Strike, T: t, Price: (option.Bid + option.Ask) / 2, IsCall: isCall},
+				moneyness: math.Abs(math.Log(option.Strike / underlyingPrice)),
+			})
+		}
+		sort.Slice(options, func(i, j int) bool {
+			return options[i].moneyness < options[j].moneyness
+		})
+		if len(options) == 0 {
+			continue
+		}
+
+		bucket := sort.SearchInts(tenorBounds, days)
+		if tenors[bucket] == nil {
+			tenors[bucket] = &tenorQuotes{minDays: days, maxDays: days}
+		}
+		tenors[bucket].minDays = min(tenors[bucket].minDays, days)
+		tenors[bucket].maxDays = max(tenors[bucket].maxDays, days)
+		candidates[bucket] = append(candidates[bucket], options[:min(quotesPerExpiration, len(options))]...)
+	}
+
+	var result []tenorQuotes
+	for i, tenor := range tenors {
+		if tenor == nil || len(candidates[i]) < minTenorQuotes {
+			continue
+		}
+		sort.Slice(candidates[i], func(a, b int) bool {
+			return candidates[i][a].moneyness < candidates[i][b].moneyness
+		})
+		for _, c := range candidates[i][:min(quotesPerTenor, len(candidates[i]))] {
+			tenor.quotes = append(tenor.quotes, c.quote)
+		}
+		result = append(result, *tenor)
+	}
+	return result
+}
+
+// calibrateTenors fits a Heston and a CGMY model to the options of every tenor concurrently, starting from
+// heston and cgmy. Tenors whose Heston calibration fails keep the starting Heston parameters.
+func calibrateTenors(tenors []tenorQuotes, heston *models.HestonModel, cgmy *models.CGMYProcess, underlyingPrice, riskFreeRate float64, status StatusSink) []probability.TenorModels {
+	calibrated := make([]probability.TenorModels, len(tenors))
+	var wg sync.WaitGroup
+	for i, tenor := range tenors {
+		wg.Add(1)
+		go func(i int, tenor tenorQuotes) {
+			defer wg.Done()
+			tenorHeston := *heston
+			hestonFit, err := tenorHeston.Calibrate(tenor.quotes, underlyingPrice, riskFreeRate)
+			if err != nil {
+				reportStatus(status, fmt.Sprintf("Error calibrating Heston model to %d-%d DTE options: %v", tenor.minDays, tenor.maxDays, err))
+				tenorHeston = *heston
+			}
+			tenorCGMY := *cgmy
+			cgmyFit := tenorCGMY.Calibrate(tenor.quotes, underlyingPrice, riskFreeRate)
+			calibrated[i] = probability.TenorModels{
+				MinDays:   tenor.minDays,
+				MaxDays:   tenor.maxDays,
+				Heston:    &tenorHeston,
+				CGMY:      &tenorCGMY,
+				HestonFit: hestonFit,
+				CGMYFit:   cgmyFit,
+			}
+		}(i, tenor)
+	}
+	wg.Wait()
+	return calibrated
+}
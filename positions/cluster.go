@@ -0,0 +1,177 @@
+package positions
+
+import (
+	"math"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+)
+
+const maxClusterIterations = 50
+
+// SpreadCluster is a group of spreads with similar short delta, width, DTE, PoP and RoR.
+// Spreads keep their input order, so the first one is the cluster's representative.
+type SpreadCluster struct {
+	Spreads []models.SpreadWithProbabilities
+	Indices []int // Position of each spread in the slice passed to ClusterSpreads
+}
+
+// Representative returns the best ranked spread of the cluster.
+func (c SpreadCluster) Representative() models.SpreadWithProbabilities {
+	return c.Spreads[0]
+}
+
+// ClusterSpreads groups ranked spreads into at most k clusters with k-means over standardized
+// feature vectors. Clusters are ordered by the rank of their representative.
+func ClusterSpreads(spreads []models.SpreadWithProbabilities, k int, currentDate time.Time) []SpreadCluster {
+	if len(spreads) == 0 || k <= 0 {
+		return nil
+	}
+	k = min(k, len(spreads))
+
+	features := standardize(spreadFeatures(spreads, currentDate))
+	centroids := seedCentroids(features, k)
+	assignment := make([]int, len(features))
+
+	for iter := 0; iter < maxClusterIterations; iter++ {
+		changed := false
+		for i, feature := range features {
+			if nearest := nearestCentroid(feature, centroids); nearest != assignment[i] {
+				assignment[i] = nearest
+				changed = true
+			}
+		}
+		if !changed && iter > 0 {
+			break
+		}
+
+		sums := make([][]float64, len(centroids))
+		counts := make([]int, len(centroids))
+		for c := range sums {
+			sums[c] = make([]float64, len(features[0]))
+		}
+		for i, feature := range features {
+			counts[assignment[i]]++
+			for d, v := range feature {
+				sums[assignment[i]][d] += v
+			}
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue // Keep an empty cluster's centroid where it is
+			}
+			for d := range centroids[c] {
+				centroids[c][d] = sums[c][d] / float64(counts[c])
+			}
+		}
+	}
+
+	clusterIndex := make(map[int]int)
+	var clusters []SpreadCluster
+	for i, spread := range spreads {
+		c, ok := clusterIndex[assignment[i]]
+		if !ok {
+			c = len(clusters)
+			clusterIndex[assignment[i]] = c
+			clusters = append(clusters, SpreadCluster{})
+		}
+		clusters[c].Spreads = append(clusters[c].Spreads, spread)
+		clusters[c].Indices = append(clusters[c].Indices, i)
+	}
+
+	return clusters
+}
+
+func spreadFeatures(spreads []models.SpreadWithProbabilities, currentDate time.Time) [][]float64 {
+	features := make([][]float64, len(spreads))
+	for i, spread := range spreads {
+		dte, _ := market.DaysToExpiration(spread.Spread.ShortLeg.Option.ExpirationDate, currentDate)
+		features[i] = []float64{
+			math.Abs(spread.Spread.ShortLeg.Option.Greeks.Delta),
+			SpreadWidth(spread.Spread),
+			float64(dte),
+			spread.Probability.AverageProbability,
+			spread.Spread.ROR,
+		}
+		for d, v := range features[i] {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				features[i][d] = 0
+			}
+		}
+	}
+	return features
+}
+
+// standardize rescales each feature to zero mean and unit variance so no single unit dominates the distance.
+func standardize(features [][]float64) [][]float64 {
+	dims := len(features[0])
+	for d := 0; d < dims; d++ {
+		mean := 0.0
+		for _, feature := range features {
+			mean += feature[d]
+		}
+		mean /= float64(len(features))
+
+		variance := 0.0
+		for _, feature := range features {
+			variance += (feature[d] - mean) * (feature[d] - mean)
+		}
+		sd := math.Sqrt(variance / float64(len(features)))
+
+		for _, feature := range features {
+			if sd == 0 {
+				feature[d] = 0
+			} else {
+				feature[d] = (feature[d] - mean) / sd
+			}
+		}
+	}
+	return features
+}
+
+// seedCentroids picks the best ranked spread and then repeatedly the spread farthest from every
+// chosen centroid, which is deterministic and spreads the initial centroids across the feature space.
+func seedCentroids(features [][]float64, k int) [][]float64 {
+	centroids := [][]float64{append([]float64(nil), features[0]...)}
+	distances := make([]float64, len(features))
+	for i, feature := range features {
+		distances[i] = squaredDistance(feature, centroids[0])
+	}
+
+	for len(centroids) < k {
+		farthest := 0
+		for i := range distances {
+			if distances[i] > distances[farthest] {
+				farthest = i
+			}
+		}
+		if distances[farthest] == 0 {
+			break // Fewer distinct spreads than clusters requested
+		}
+		centroid := append([]float64(nil), features[farthest]...)
+		centroids = append(centroids, centroid)
+		for i, feature := range features {
+			distances[i] = math.Min(distances[i], squaredDistance(feature, centroid))
+		}
+	}
+	return centroids
+}
+
+func nearestCentroid(feature []float64, centroids [][]float64) int {
+	nearest, best := 0, math.Inf(1)
+	for c, centroid := range centroids {
+		if d := squaredDistance(feature, centroid); d < best {
+			nearest, best = c, d
+		}
+	}
+	return nearest
+}
+
+func squaredDistance(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return sum
+}
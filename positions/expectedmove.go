@@ -0,0 +1,94 @@
+package positions
+
+import (
+	"math"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// ExpectedMove returns the market-implied one standard deviation move of the underlying to the expiration
+// of options, in dollars. It is taken from the mid price of the straddle at the strike nearest the money,
+// which prices the mean absolute move, sqrt(2/pi) of a standard deviation; when that straddle has no
+// two-sided quotes, from the at-the-money implied volatility as underlyingPrice * IV * sqrt(tau). It returns
+// 0 when neither is available.
+func ExpectedMove(options []tradier.Option, underlyingPrice, tau float64) float64 {
+	if tau <= 0 {
+		return 0
+	}
+	calls := make(map[float64]tradier.Option)
+	puts := make(map[float64]tradier.Option)
+	for _, option := range options {
+		if option.OptionType == "call" {
+			calls[option.Strike] = option
+		} else {
+			puts[option.Strike] = option
+		}
+	}
+
+	atmStrike, found := 0.0, false
+	for strike := range calls {
+		if _, ok := puts[strike]; ok && (!found || math.Abs(strike-underlyingPrice) < math.Abs(atmStrike-underlyingPrice)) {
+			atmStrike, found = strike, true
+		}
+	}
+	if !found {
+		return 0
+	}
+	call, put := calls[atmStrike], puts[atmStrike]
+	if call.Bid > 0 && call.Ask >= call.Bid && put.Bid > 0 && put.Ask >= put.Bid {
+		straddle := (call.Bid+call.Ask)/2 + (put.Bid+put.Ask)/2
+		return straddle * math.Sqrt(math.Pi/2)
+	}
+
+	vol := (call.Greeks.MidIv + put.Greeks.MidIv) / 2
+	if call.Greeks.MidIv <= 0 || put.Greeks.MidIv <= 0 {
+		vol = math.Max(call.Greeks.MidIv, put.Greeks.MidIv)
+	}
+	if vol <= 0 {
+		return 0
+	}
+	return underlyingPrice * vol * math.Sqrt(tau)
+}
+
+// setExpectedMove sets the spread's expected move to move, the market-implied move of its expiration, or
+// when that is 0 and the position has none of its own, to the move at its short leg's implied volatility,
+// and measures its short strikes against it.
+func setExpectedMove(spread *models.OptionSpread, move, underlyingPrice float64) {
+	if move > 0 {
+		spread.ExpectedMove = move
+	} else if spread.ExpectedMove == 0 {
+		spread.ExpectedMove = expectedMove(underlyingPrice, spread.ShortLeg)
+	}
+	spread.ShortStrikeMoves = shortStrikeMoves(*spread, underlyingPrice)
+}
+
+// shortStrikeMoves returns the distance from the underlying price to the spread's nearest short strike in
+// expected moves, or 0 when the spread has no expected move.
+func shortStrikeMoves(spread models.OptionSpread, underlyingPrice float64) float64 {
+	if spread.ExpectedMove <= 0 {
+		return 0
+	}
+	var strikes []float64
+	if len(spread.Legs) > 0 {
+		for _, leg := range spread.Legs {
+			if leg.Quantity > 0 {
+				strikes = append(strikes, leg.Option.Strike)
+			}
+		}
+	} else {
+		strikes = append(strikes, spread.ShortLeg.Option.Strike)
+		if spread.CallLeg.Option.Symbol != "" {
+			strikes = append(strikes, spread.CallLeg.Option.Strike)
+		}
+	}
+
+	distance := math.Inf(1)
+	for _, strike := range strikes {
+		distance = math.Min(distance, math.Abs(strike-underlyingPrice))
+	}
+	if math.IsInf(distance, 1) {
+		return 0
+	}
+	return distance / spread.ExpectedMove
+}
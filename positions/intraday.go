@@ -0,0 +1,42 @@
+package positions
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const intradayLookback = 7 // Calendar days of 5 minute bars the intraday volatility is measured over
+
+// FetchIntradayVolatility measures the annualized realized volatility of symbol's 5 minute bars over the
+// last intradayLookback days up to now, for ScanOptions.IntradayVolatility.
+func FetchIntradayVolatility(symbol, token string, now time.Time) (float64, error) {
+	const layout = "2006-01-02 15:04"
+	timeSales, err := tradier.GET_TIMESALES(symbol, "5min", now.AddDate(0, 0, -intradayLookback).Format(layout), now.Format(layout), token)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch intraday bars: %s", err)
+	}
+	vol := models.IntradayVolatility(timeSales.Series.Data)
+	if vol <= 0 {
+		return 0, fmt.Errorf("too few intraday bars for %s to measure volatility", symbol)
+	}
+	return vol, nil
+}
+
+// WithIntradayVolatility returns the options with IntradayVolatility measured for symbol when they scan
+// short-dated spreads and do not set it already. A failure to measure it is logged and leaves it unset.
+func (o ScanOptions) WithIntradayVolatility(symbol, token string, now time.Time) ScanOptions {
+	if !o.ShortDated || o.IntradayVolatility > 0 {
+		return o
+	}
+	vol, err := FetchIntradayVolatility(symbol, token, now)
+	if err != nil {
+		log.Printf("Scanning %s without intraday volatility: %v", symbol, err)
+		return o
+	}
+	o.IntradayVolatility = vol
+	return o
+}
@@ -0,0 +1,125 @@
+package positions
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bcdannyboy/stocd/probability"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	DefaultCalibrationTTL     = 30 * time.Minute // How long a symbol's calibrated models are reused
+	DefaultCalibrationWorkers = 4                // Symbols calibrated concurrently
+	DefaultSymbolWorkers      = 2                // Symbols a multi-symbol scan runs concurrently, each fetched, calibrated and scanned
+)
+
+// ModelCache keeps the models calibrated to each symbol for a freshness window, so that repeated and
+// multi-symbol scans skip recalibrating. It is safe for concurrent use; a nil cache calibrates every time.
+type ModelCache struct {
+	TTL     time.Duration // How long calibrated models are reused
+	Workers int           // Symbols CalibrateSymbols calibrates concurrently
+
+	mu      sync.Mutex
+	entries map[string]cachedModels
+}
+
+type cachedModels struct {
+	models     probability.GlobalModels
+	calibrated time.Time
+}
+
+// NewModelCache returns a cache reusing models for ttl and calibrating up to workers symbols at a time.
+func NewModelCache(ttl time.Duration, workers int) *ModelCache {
+	return &ModelCache{TTL: ttl, Workers: workers, entries: make(map[string]cachedModels)}
+}
+
+// ModelCacheFromEnv returns a cache configured by CALIBRATION_TTL, a duration such as 30m, and
+// CALIBRATION_WORKERS, or nil when CALIBRATION_TTL is 0 to disable caching.
+func ModelCacheFromEnv() *ModelCache {
+	ttl := DefaultCalibrationTTL
+	if value := os.Getenv("CALIBRATION_TTL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			fmt.Printf("Warning: invalid CALIBRATION_TTL %q, using %v\n", value, ttl)
+		} else {
+			ttl = parsed
+		}
+	}
+	if ttl == 0 {
+		return nil
+	}
+	return NewModelCache(ttl, int(envFloat("CALIBRATION_WORKERS", DefaultCalibrationWorkers)))
+}
+
+// Models returns the models calibrated to the symbol at the risk-free rate within TTL, calibrating and
+// caching them if there are none.
+func (c *ModelCache) Models(symbol string, chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, currentDate time.Time, status StatusSink) probability.GlobalModels {
+	key := fmt.Sprintf("%s@%g", symbol, riskFreeRate)
+	if c != nil {
+		c.mu.Lock()
+		entry, ok := c.entries[key]
+		c.mu.Unlock()
+		if ok && time.Since(entry.calibrated) < c.TTL {
+			reportStatus(status, fmt.Sprintf("Using models calibrated %v ago", time.Since(entry.calibrated).Round(time.Second)))
+			return entry.models
+		}
+	}
+
+	globalModels := CalibrateModels(chain, underlyingPrice, riskFreeRate, history, currentDate, status)
+	globalModels.Paths = nil // Paths are specific to a scan
+	if c != nil {
+		c.mu.Lock()
+		c.entries[key] = cachedModels{models: globalModels, calibrated: time.Now()}
+		for k, entry := range c.entries {
+			if time.Since(entry.calibrated) >= c.TTL {
+				delete(c.entries, k)
+			}
+		}
+		c.mu.Unlock()
+	}
+	return globalModels
+}
+
+// SymbolData is the market data a symbol's models are calibrated to.
+type SymbolData struct {
+	Symbol          string
+	Chain           map[string]*tradier.OptionChain
+	UnderlyingPrice float64
+	History         tradier.QuoteHistory
+}
+
+// CalibrateSymbols returns the models of every symbol, keyed by symbol, calibrating those without fresh
+// cached models concurrently, at most Workers at a time. status receives each symbol's calibration steps
+// prefixed with the symbol.
+func (c *ModelCache) CalibrateSymbols(symbols []SymbolData, riskFreeRate float64, currentDate time.Time, status StatusSink) map[string]probability.GlobalModels {
+	workers := DefaultCalibrationWorkers
+	if c != nil && c.Workers > 0 {
+		workers = c.Workers
+	}
+
+	calibrated := make(map[string]probability.GlobalModels, len(symbols))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, workers)
+	for _, data := range symbols {
+		wg.Add(1)
+		go func(data SymbolData) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			symbolStatus := StatusFunc(func(msg string) {
+				reportStatus(status, fmt.Sprintf("%s: %s", data.Symbol, msg))
+			})
+			globalModels := c.Models(data.Symbol, data.Chain, data.UnderlyingPrice, riskFreeRate, data.History, currentDate, symbolStatus)
+			mu.Lock()
+			calibrated[data.Symbol] = globalModels
+			mu.Unlock()
+		}(data)
+	}
+	wg.Wait()
+	return calibrated
+}
@@ -0,0 +1,366 @@
+package positions
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/probability"
+	"github.com/bcdannyboy/stocd/slippage"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	DefaultMinAnalyticPoP       = 0.4 // Closed-form probability of profit below which candidates are not simulated
+	DefaultSimulationCandidates = 200 // Screened candidates simulated per scan
+)
+
+// ScanOptions controls which candidate spreads are generated during a scan and how they are priced.
+// Zero values leave the corresponding constraint disabled.
+type ScanOptions struct {
+	MinWidth     float64 // Minimum distance between strikes in dollars
+	MaxWidth     float64 // Maximum distance between strikes in dollars
+	MinStrikeGap int     // Minimum number of listed strikes between the legs
+	MaxStrikeGap int     // Maximum number of listed strikes between the legs
+
+	MaxWidthPercent float64 // Maximum distance between strikes as a fraction of the underlying price (e.g. 0.1)
+	AdjacentStrikes bool    // Pair only neighbouring listed strikes, for dense chains like SPX
+
+	MinCreditWidthRatio  float64 // Minimum credit as a fraction of the strike width (e.g. 0.25)
+	MinExpectedMoves     float64 // Minimum distance of the short strikes from the underlying price in expected moves, 1 to keep them outside the expected move
+	MinThetaPerDay       float64 // Minimum time decay per share per day earned by the seller
+	MinThetaVegaRatio    float64 // Minimum daily theta per point of vega
+	MinThetaCreditRatio  float64 // Minimum daily theta as a fraction of the credit (e.g. 0.02)
+	MinAnalyticPoP       float64 // Minimum closed-form probability of profit for a candidate to be simulated, 0 to simulate all
+	SimulationCandidates int     // Screened candidates with the best analytic probability of profit to simulate, 0 for all
+
+	ExpirationTypes []string // Tradier expiration types to trade, e.g. from ParseExpirationTypes; nil for every expiration
+
+	IncludeNonstandard bool // Also trade nonstandard contracts, minis and adjusted series, which are left out by default
+
+	MinImpliedVol  float64       // Implied volatilities below this are re-solved from the option's price or dropped
+	MaxImpliedVol  float64       // Implied volatilities above this are re-solved from the option's price or dropped
+	MaxGreeksAge   time.Duration // Greeks older than the option's quotes by more than this are re-solved, 0 to trust them
+	IVOutlierRatio float64       // Ratio to the neighbouring strikes' implied volatility beyond which an option's is repaired, 0 to disable
+
+	MaxQuoteAge        time.Duration // Spreads with a leg last quoted longer ago than this during market hours are flagged stale, 0 to disable
+	ExcludeStaleQuotes bool          // Leave out the spreads flagged stale rather than only flagging them
+
+	ShortDated         bool    // Simulate over the trading hours left to expiration rather than whole calendar days, for 0-3 DTE spreads
+	IntradayVolatility float64 // Annualized realized volatility of intraday bars, e.g. from FetchIntradayVolatility; 0 to leave it out
+
+	Slippage *slippage.Store // Historical fills used to adjust the modeled credit, nil to disable
+	Fees     FeeModel        // Commissions and exchange fees deducted from the modeled credit
+	Fill     FillModel       // Price the legs are assumed to fill at when no fill history applies
+
+	MaxSpreads int          // Viable spreads kept per scan, the best by probability of profit then expected value; 0 for all
+	Stream     SpreadWriter // Receives every viable spread as it is simulated, before scoring and ranking; nil for none
+
+	Workers int // Spreads evaluated concurrently, 0 to autotune
+
+	Models *probability.GlobalModels // Models already calibrated to the symbol, e.g. by a ModelCache; nil to calibrate them
+}
+
+// keepKeys rank the spreads kept when a scan keeps at most MaxSpreads. The composite score is relative to
+// the spreads scored together, so it cannot rank them as they are produced.
+var keepKeys = []string{SortPoP, SortEV}
+
+// SpreadWriter receives the spreads of a scan as they are produced, e.g. a results.NDJSONWriter. Scans of
+// several symbols may share one, so WriteSpread must be safe for concurrent use.
+type SpreadWriter interface {
+	WriteSpread(spread models.SpreadWithProbabilities) error
+}
+
+// ScanOptionsFromEnv reads the optional spread constraints from the environment. Slippage and Fill are
+// left for the caller to set.
+func ScanOptionsFromEnv() ScanOptions {
+	return ScanOptions{
+		MinWidth:     envFloat("MIN_SPREAD_WIDTH", 0),
+		MaxWidth:     envFloat("MAX_SPREAD_WIDTH", 0),
+		MinStrikeGap: int(envFloat("MIN_STRIKE_GAP", 0)),
+		MaxStrikeGap: int(envFloat("MAX_STRIKE_GAP", 0)),
+
+		MaxWidthPercent: envFloat("MAX_SPREAD_WIDTH_PCT", 0),
+		AdjacentStrikes: envBool("ADJACENT_STRIKES"),
+
+		MinCreditWidthRatio:  envFloat("MIN_CREDIT_WIDTH_RATIO", 0),
+		MinExpectedMoves:     envFloat("MIN_EXPECTED_MOVES", 0),
+		MinThetaPerDay:       envFloat("MIN_THETA_PER_DAY", 0),
+		MinThetaVegaRatio:    envFloat("MIN_THETA_VEGA_RATIO", 0),
+		MinThetaCreditRatio:  envFloat("MIN_THETA_CREDIT_RATIO", 0),
+		MinAnalyticPoP:       envFloat("MIN_ANALYTIC_POP", DefaultMinAnalyticPoP),
+		SimulationCandidates: int(envFloat("SIMULATION_CANDIDATES", DefaultSimulationCandidates)),
+		ExpirationTypes:      envExpirationTypes(),
+		ShortDated:           envBool("SHORT_DATED"),
+		IncludeNonstandard:   envBool("INCLUDE_NONSTANDARD"),
+
+		MinImpliedVol:  envFloat("MIN_IMPLIED_VOL", DefaultMinImpliedVol),
+		MaxImpliedVol:  envFloat("MAX_IMPLIED_VOL", DefaultMaxImpliedVol),
+		MaxGreeksAge:   envDuration("MAX_GREEKS_AGE", DefaultMaxGreeksAge),
+		IVOutlierRatio: envFloat("IV_OUTLIER_RATIO", DefaultIVOutlierRatio),
+
+		MaxQuoteAge:        envDuration("MAX_QUOTE_AGE", DefaultMaxQuoteAge),
+		ExcludeStaleQuotes: envBool("EXCLUDE_STALE_QUOTES"),
+
+		Fees: FeeModel{
+			PerContract: envFloat("FEE_PER_CONTRACT", 0),
+			PerLeg:      envFloat("FEE_PER_LEG", 0),
+		},
+
+		MaxSpreads: int(envFloat("MAX_SPREADS", 0)),
+
+		Workers: int(envFloat("SPREAD_WORKERS", 0)),
+	}
+}
+
+// envExpirationTypes reads EXPIRATION_TYPES, logging an invalid value and trading every expiration.
+func envExpirationTypes() []string {
+	types, err := ParseExpirationTypes(os.Getenv("EXPIRATION_TYPES"))
+	if err != nil {
+		log.Printf("Invalid EXPIRATION_TYPES, trading every expiration: %v", err)
+		return nil
+	}
+	return types
+}
+
+func envBool(name string) bool {
+	value, _ := strconv.ParseBool(os.Getenv(name))
+	return value
+}
+
+// envDuration reads a duration such as 15m, warning about an invalid one and using the fallback.
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		fmt.Printf("Warning: invalid %s %q, using %v\n", name, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func envFloat(name string, fallback float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// FeeModel is the commissions and exchange fees paid to open a position, in dollars.
+type FeeModel struct {
+	PerContract float64 // Charged for every option contract traded
+	PerLeg      float64 // Charged once for every leg of the order
+}
+
+// perShare returns the fees to open one unit of the position per share, the unit the credit is quoted in.
+func (f FeeModel) perShare(spread models.OptionSpread) float64 {
+	contracts, legs := 0, 0
+	for _, leg := range models.PositionLegs(spread) {
+		contracts += max(leg.Quantity, -leg.Quantity)
+		legs++
+	}
+	return (f.PerContract*float64(contracts) + f.PerLeg*float64(legs)) / models.SpreadMultiplier(spread)
+}
+
+// FillModel is the price the legs are assumed to fill at. Improvement is the fraction of each leg's half
+// bid/ask spread recovered over the natural price of selling at the bid and buying at the ask: 0 fills at
+// the natural price, 1 at mid.
+type FillModel struct {
+	Improvement float64
+}
+
+// ParseFillModel reads a fill assumption: natural (or bidask), mid, or mid-X% for X percent of the half
+// spread short of mid. An empty spec is natural.
+func ParseFillModel(spec string) (FillModel, error) {
+	switch spec = strings.ToLower(strings.TrimSpace(spec)); spec {
+	case "", "natural", "bidask":
+		return FillModel{}, nil
+	case "mid":
+		return FillModel{Improvement: 1}, nil
+	}
+	percent, ok := strings.CutPrefix(spec, "mid-")
+	if ok {
+		percent, ok = strings.CutSuffix(percent, "%")
+	}
+	value, err := strconv.ParseFloat(percent, 64)
+	if !ok || err != nil || value < 0 || value > 100 {
+		return FillModel{}, fmt.Errorf("invalid fill model %q: expected natural, mid or mid-X%% with X from 0 to 100", spec)
+	}
+	return FillModel{Improvement: 1 - value/100}, nil
+}
+
+// perShare returns the credit the fill assumption adds over the natural price for one unit of the position.
+func (f FillModel) perShare(spread models.OptionSpread) float64 {
+	if f.Improvement <= 0 {
+		return 0
+	}
+	halfSpreads := 0.0
+	for _, leg := range models.PositionLegs(spread) {
+		if leg.Option.Ask > leg.Option.Bid {
+			halfSpreads += float64(max(leg.Quantity, -leg.Quantity)) * (leg.Option.Ask - leg.Option.Bid) / 2
+		}
+	}
+	return f.Improvement * halfSpreads
+}
+
+// allowsWidth reports whether a pair of legs width dollars and gap strikes apart passes the width constraints.
+// Legs at the same strike are never a pair.
+func (o ScanOptions) allowsWidth(width float64, gap int) bool {
+	if width < 1e-9 || gap < 1 {
+		return false
+	}
+	if o.AdjacentStrikes && gap != 1 {
+		return false
+	}
+	if o.MinWidth > 0 && width < o.MinWidth-1e-9 {
+		return false
+	}
+	if o.MaxWidth > 0 && width > o.MaxWidth+1e-9 {
+		return false
+	}
+	if o.MinStrikeGap > 0 && gap < o.MinStrikeGap {
+		return false
+	}
+	if o.MaxStrikeGap > 0 && gap > o.MaxStrikeGap {
+		return false
+	}
+	return true
+}
+
+// forUnderlying resolves MaxWidthPercent at the underlying price into MaxWidth, keeping the tighter limit.
+func (o ScanOptions) forUnderlying(underlyingPrice float64) ScanOptions {
+	if o.MaxWidthPercent > 0 && underlyingPrice > 0 {
+		limit := o.MaxWidthPercent * underlyingPrice
+		if o.MaxWidth <= 0 || limit < o.MaxWidth {
+			o.MaxWidth = limit
+		}
+	}
+	return o
+}
+
+// expirationTypes maps the names of expiration cycles to Tradier's expiration types. Tradier calls the
+// monthly cycle, the third Friday of the month, standard.
+var expirationTypes = map[string]string{
+	"standard":   "standard",
+	"monthly":    "standard",
+	"monthlys":   "standard",
+	"weekly":     "weeklys",
+	"weeklys":    "weeklys",
+	"quarterly":  "quarterlys",
+	"quarterlys": "quarterlys",
+	"eom":        "eom",
+}
+
+// ParseExpirationTypes reads a comma-separated list of expiration cycles to trade: weeklys, monthlys,
+// quarterlys and eom (end of month), singular names and Tradier's standard for monthlys included. An
+// empty spec or all trades every expiration and returns nil.
+func ParseExpirationTypes(spec string) ([]string, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "" || spec == "all" {
+		return nil, nil
+	}
+	var types []string
+	for _, name := range strings.Split(spec, ",") {
+		expirationType, ok := expirationTypes[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown expiration type %q: expected all or weeklys, monthlys, quarterlys and eom", name)
+		}
+		if !slices.Contains(types, expirationType) {
+			types = append(types, expirationType)
+		}
+	}
+	return types, nil
+}
+
+// allowsExpiration reports whether the expiration's cycle is one of ExpirationTypes. The cycle is the one
+// Tradier gives its options; without one, the month's standard expiration (see market.MonthlyExpiration) is standard and any other day weeklys.
+func (o ScanOptions) allowsExpiration(date string, expiration *tradier.OptionChain) bool {
+	if len(o.ExpirationTypes) == 0 {
+		return true
+	}
+	expirationType := ""
+	for _, option := range expiration.Options.Option {
+		if option.ExpirationType != "" {
+			expirationType = option.ExpirationType
+			break
+		}
+	}
+	if expirationType == "" {
+		expirationType = "weeklys"
+		if day, err := time.Parse(market.DateLayout, date); err == nil && market.MonthlyExpiration(day.Year(), day.Month()).Format(market.DateLayout) == date {
+			expirationType = "standard"
+		}
+	}
+	return slices.Contains(o.ExpirationTypes, expirationType)
+}
+
+// standardChain returns the chain without its nonstandard contracts, minis and adjusted series, unless
+// the options include them, and how many options it left out.
+func (o ScanOptions) standardChain(chain map[string]*tradier.OptionChain) (map[string]*tradier.OptionChain, int) {
+	if o.IncludeNonstandard {
+		return chain, 0
+	}
+	standard := make(map[string]*tradier.OptionChain, len(chain))
+	removed := 0
+	for date, expiration := range chain {
+		if expiration == nil {
+			standard[date] = expiration
+			continue
+		}
+		var options []tradier.Option
+		for _, option := range expiration.Options.Option {
+			if models.IsNonstandard(option) {
+				removed++
+				continue
+			}
+			options = append(options, option)
+		}
+		if len(options) == len(expiration.Options.Option) {
+			standard[date] = expiration
+			continue
+		}
+		filtered := *expiration
+		filtered.Options.Option = options
+		standard[date] = &filtered
+	}
+	return standard, removed
+}
+
+// allowsCredit reports whether the spread collects enough credit relative to its width. Single legs and
+// positions with undefined risk collect a small fraction of their collateral or margin and are not held to the ratio.
+func (o ScanOptions) allowsCredit(spread models.OptionSpread) bool {
+	return o.MinCreditWidthRatio <= 0 || models.IsSingleLeg(spread) || spread.Margin > 0 || spread.CreditWidthRatio >= o.MinCreditWidthRatio
+}
+
+// allowsDecay reports whether the spread decays fast enough, in absolute terms and relative to its vega
+// and credit.
+func (o ScanOptions) allowsDecay(spread models.OptionSpread) bool {
+	decay := spread.Decay
+	return (o.MinThetaPerDay <= 0 || decay.ThetaPerDay >= o.MinThetaPerDay) &&
+		(o.MinThetaVegaRatio <= 0 || decay.ThetaVegaRatio >= o.MinThetaVegaRatio) &&
+		(o.MinThetaCreditRatio <= 0 || decay.ThetaCreditRatio >= o.MinThetaCreditRatio)
+}
+
+// allowsShortStrikes reports whether the spread's short strikes are far enough outside the expected move.
+// Spreads of expirations without an expected move are kept.
+func (o ScanOptions) allowsShortStrikes(spread models.OptionSpread) bool {
+	return o.MinExpectedMoves <= 0 || spread.ExpectedMove <= 0 || spread.ShortStrikeMoves >= o.MinExpectedMoves
+}
+
+// creditAdjustment returns the historical fill slippage for spreads similar to this pair of legs. Fills
+// are only recorded for spreads, so single legs are not adjusted.
+func (o ScanOptions) creditAdjustment(shortOpt, longOpt tradier.Option) float64 {
+	if longOpt.Symbol == "" {
+		return 0
+	}
+	return o.Slippage.Adjustment(shortOpt.Underlying, slippage.LiquidityBucket(shortOpt, longOpt))
+}
@@ -0,0 +1,264 @@
+package positions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	DefaultMinImpliedVol  = 0.01             // Implied volatilities below this are implausible
+	DefaultMaxImpliedVol  = 5.0              // Implied volatilities above this are implausible
+	DefaultMaxGreeksAge   = 2 * time.Hour    // Greeks computed longer than this before the option's last quote are stale
+	DefaultIVOutlierRatio = 2.0              // Ratio to the neighbouring strikes' implied volatility beyond which an option's is an outlier
+	DefaultMaxQuoteAge    = 15 * time.Minute // Legs last quoted longer ago than this during market hours are stale
+
+	greeksTimeLayout = "2006-01-02 15:04:05" // Tradier's Greeks.UpdatedAt, in UTC
+	ivNeighbours     = 2                     // Listed strikes on each side an option's implied volatility is compared with
+)
+
+// QuoteCheck counts what validating the quotes of a chain found and did about it.
+type QuoteCheck struct {
+	Crossed     int // Options left out for a bid above the ask
+	Stale       int // Options whose greeks were older than their quotes by more than MaxGreeksAge
+	Implausible int // Options with an implied volatility outside the bounds
+	Outliers    int // Options whose implied volatility was out of line with the neighbouring strikes
+	Repaired    int // Options whose implied volatilities were re-solved from their prices or neighbours
+	Cleared     int // Options whose implied volatilities could not be repaired and were dropped
+}
+
+// Problems counts the options with a bad quote.
+func (c QuoteCheck) Problems() int {
+	return c.Crossed + c.Stale + c.Implausible + c.Outliers
+}
+
+func (c QuoteCheck) String() string {
+	var found []string
+	for _, count := range []struct {
+		n    int
+		what string
+	}{
+		{c.Crossed, "crossed quotes left out"},
+		{c.Stale, "stale greeks"},
+		{c.Implausible, "implausible implied volatilities"},
+		{c.Outliers, "implied volatility outliers"},
+	} {
+		if count.n > 0 {
+			found = append(found, fmt.Sprintf("%d %s", count.n, count.what))
+		}
+	}
+	if len(found) == 0 {
+		return "no bad quotes"
+	}
+	return fmt.Sprintf("%s (%d repaired, %d dropped)", strings.Join(found, ", "), c.Repaired, c.Cleared)
+}
+
+// validQuotes checks the chain's quotes before they are priced, calibrated to or enter the volatility
+// ensemble, returning a copy with the bad ones left out or repaired:
+//
+//   - an option bid above its ask is left out, as no fill price can be trusted;
+//   - an option whose greeks are stale (Greeks.UpdatedAt older than its last bid or ask by more than
+//     MaxGreeksAge) or which has an implied volatility outside MinImpliedVol to MaxImpliedVol has that
+//     volatility re-solved from its bid, mid or ask price with Black-Scholes-Merton, or dropped (zero) when
+//     no plausible volatility reproduces the price;
+//   - an option whose mid implied volatility is more than IVOutlierRatio times above or below the median of
+//     the neighbouring strikes of its type has it re-solved from the mid price, or replaced by the
+//     neighbours' median when the price is out of line too, and its bid and ask volatilities scaled to match
+//     when they are out of line as well.
+//
+// Dropped volatilities leave the ensemble to the volatility surface and historical estimates.
+func (o ScanOptions) validQuotes(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, now time.Time) (map[string]*tradier.OptionChain, QuoteCheck) {
+	var check QuoteCheck
+	valid := make(map[string]*tradier.OptionChain, len(chain))
+	for date, expiration := range chain {
+		if expiration == nil {
+			valid[date] = expiration
+			continue
+		}
+		T, _ := market.YearsToExpiration(date, now)
+		options := make([]tradier.Option, 0, len(expiration.Options.Option))
+		for _, option := range expiration.Options.Option {
+			if option.Ask > 0 && option.Bid > option.Ask {
+				check.Crossed++
+				continue
+			}
+			o.repairImpliedVols(&option, underlyingPrice, riskFreeRate, T, now, &check)
+			options = append(options, option)
+		}
+		o.repairOutliers(options, underlyingPrice, riskFreeRate, T, &check)
+
+		checked := *expiration
+		checked.Options.Option = options
+		valid[date] = &checked
+	}
+	return valid, check
+}
+
+// repairImpliedVols re-solves the option's stale or implausible implied volatilities from its prices,
+// dropping those no plausible volatility reproduces.
+func (o ScanOptions) repairImpliedVols(option *tradier.Option, underlyingPrice, riskFreeRate, T float64, now time.Time, check *QuoteCheck) {
+	stale := o.MaxGreeksAge > 0 && greeksAge(*option, now) > o.MaxGreeksAge
+	implausible := false
+	for _, iv := range []float64{option.Greeks.BidIv, option.Greeks.MidIv, option.Greeks.AskIv} {
+		if iv != 0 && !o.plausibleIV(iv) {
+			implausible = true
+		}
+	}
+	if !stale && !implausible {
+		return
+	}
+	if stale {
+		check.Stale++
+	}
+	if implausible {
+		check.Implausible++
+	}
+
+	isCall := option.OptionType == "call"
+	dropped := false
+	for _, field := range []struct {
+		iv    *float64
+		price float64
+	}{
+		{&option.Greeks.BidIv, option.Bid},
+		{&option.Greeks.MidIv, (option.Bid + option.Ask) / 2},
+		{&option.Greeks.AskIv, option.Ask},
+	} {
+		if !stale && (*field.iv == 0 || o.plausibleIV(*field.iv)) {
+			continue
+		}
+		solved, err := calculateImpliedVolatility(field.price, underlyingPrice, option.Strike, T, riskFreeRate, isCall)
+		if err != nil || !o.plausibleIV(solved) {
+			dropped = dropped || *field.iv != 0
+			solved = 0
+		}
+		*field.iv = solved
+	}
+	if dropped {
+		check.Cleared++
+	} else {
+		check.Repaired++
+	}
+}
+
+// repairOutliers repairs the mid implied volatilities of an expiration's options that are out of line with
+// the neighbouring strikes of the same type.
+func (o ScanOptions) repairOutliers(options []tradier.Option, underlyingPrice, riskFreeRate, T float64, check *QuoteCheck) {
+	if o.IVOutlierRatio <= 1 {
+		return
+	}
+	for _, optionType := range []string{"call", "put"} {
+		var indices []int
+		for i, option := range options {
+			if option.OptionType == optionType && option.Greeks.MidIv > 0 {
+				indices = append(indices, i)
+			}
+		}
+		sort.Slice(indices, func(a, b int) bool { return options[indices[a]].Strike < options[indices[b]].Strike })
+
+		// Neighbours are compared as quoted, so repairing one option does not move the others' medians
+		mids := make([]float64, len(indices))
+		for n, i := range indices {
+			mids[n] = options[i].Greeks.MidIv
+		}
+		for n, i := range indices {
+			var neighbours []float64
+			for m := max(n-ivNeighbours, 0); m <= min(n+ivNeighbours, len(mids)-1); m++ {
+				if m != n {
+					neighbours = append(neighbours, mids[m])
+				}
+			}
+			if len(neighbours) < 2 {
+				continue
+			}
+			reference := median(neighbours)
+			if !o.outlierIV(mids[n], reference) {
+				continue
+			}
+			check.Outliers++
+
+			option := &options[i]
+			repaired, err := calculateImpliedVolatility((option.Bid+option.Ask)/2, underlyingPrice, option.Strike, T, riskFreeRate, option.OptionType == "call")
+			if err != nil || !o.plausibleIV(repaired) || o.outlierIV(repaired, reference) {
+				repaired = reference
+			}
+			scale := repaired / option.Greeks.MidIv
+			option.Greeks.MidIv = repaired
+			for _, iv := range []*float64{&option.Greeks.BidIv, &option.Greeks.AskIv} {
+				if *iv > 0 && o.outlierIV(*iv, reference) {
+					*iv *= scale
+				}
+			}
+			check.Repaired++
+		}
+	}
+}
+
+// quoteFreshness finds when the spread's stalest leg was last quoted, each leg by its latest bid or ask, and
+// whether that was longer than MaxQuoteAge before now during market hours, when quotes should be updating.
+func (o ScanOptions) quoteFreshness(spread models.OptionSpread, now time.Time) models.QuoteFreshness {
+	var freshness models.QuoteFreshness
+	for _, leg := range models.PositionLegs(spread) {
+		last := max(leg.Option.BidDate, leg.Option.AskDate)
+		if last <= 0 {
+			continue
+		}
+		if quoted := time.UnixMilli(last).In(market.Location); freshness.QuotedAt.IsZero() || quoted.Before(freshness.QuotedAt) {
+			freshness.QuotedAt = quoted
+		}
+	}
+	if freshness.QuotedAt.IsZero() {
+		return freshness
+	}
+	freshness.Age = max(now.Sub(freshness.QuotedAt), 0)
+	freshness.Stale = o.MaxQuoteAge > 0 && freshness.Age > o.MaxQuoteAge && market.IsOpen(now)
+	return freshness
+}
+
+// allowsQuotes reports whether the spread's quotes are fresh enough to trade, always unless
+// ExcludeStaleQuotes is set.
+func (o ScanOptions) allowsQuotes(spread models.OptionSpread, now time.Time) bool {
+	return !o.ExcludeStaleQuotes || !o.quoteFreshness(spread, now).Stale
+}
+
+// countStaleQuotes counts the spreads flagged as having stale quotes.
+func countStaleQuotes(spreads []models.SpreadWithProbabilities) int {
+	stale := 0
+	for _, spread := range spreads {
+		if spread.Quotes.Stale {
+			stale++
+		}
+	}
+	return stale
+}
+
+// plausibleIV reports whether the implied volatility is within MinImpliedVol and MaxImpliedVol, which
+// are not enforced when zero. Zero and negative volatilities are never plausible.
+func (o ScanOptions) plausibleIV(iv float64) bool {
+	return iv > 0 && !math.IsInf(iv, 0) && iv >= o.MinImpliedVol && (o.MaxImpliedVol <= 0 || iv <= o.MaxImpliedVol)
+}
+
+// outlierIV reports whether iv is more than IVOutlierRatio times above or below the reference volatility.
+func (o ScanOptions) outlierIV(iv, reference float64) bool {
+	return iv > reference*o.IVOutlierRatio || iv*o.IVOutlierRatio < reference
+}
+
+// greeksAge returns how long before the option's last bid or ask, or now when it has none, its greeks
+// were computed, zero when their time is unknown.
+func greeksAge(option tradier.Option, now time.Time) time.Duration {
+	updated, err := time.ParseInLocation(greeksTimeLayout, option.Greeks.UpdatedAt, time.UTC)
+	if err != nil {
+		return 0
+	}
+	quoted := now
+	if last := max(option.BidDate, option.AskDate); last > 0 {
+		quoted = time.UnixMilli(last)
+	}
+	return max(quoted.Sub(updated), 0)
+}
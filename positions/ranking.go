@@ -0,0 +1,137 @@
+package positions
+
+import (
+	"log"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// RankingConstraints keep the top of a ranking from filling up with near-identical spreads.
+type RankingConstraints struct {
+	MaxPerExpiration    int      // Spreads of one expiration among the top results, 0 for no limit
+	MinStrikeSeparation float64  // Dollars between the short strikes of top spreads of the same type and expiration, 0 for none
+	EveryExpiration     bool     // Include the best spread of every expiration among the top results, as room allows
+	Sort                []string // Keys the spreads are ranked by, from ParseSort; nil for the composite score
+}
+
+// RankingConstraintsFromEnv reads the constraints from MAX_PER_EXPIRATION, MIN_STRIKE_SEPARATION,
+// EVERY_EXPIRATION and SORT_BY, logging an invalid SORT_BY and ranking by composite score.
+func RankingConstraintsFromEnv() RankingConstraints {
+	everyExpiration, _ := strconv.ParseBool(os.Getenv("EVERY_EXPIRATION"))
+	sortBy, err := ParseSort(os.Getenv("SORT_BY"))
+	if err != nil {
+		log.Printf("Invalid SORT_BY, ranking by composite score: %v", err)
+		sortBy = nil
+	}
+	return RankingConstraints{
+		MaxPerExpiration:    int(envFloat("MAX_PER_EXPIRATION", 0)),
+		MinStrikeSeparation: envFloat("MIN_STRIKE_SEPARATION", 0),
+		EveryExpiration:     everyExpiration,
+		Sort:                sortBy,
+	}
+}
+
+// RankSpreads ranks scored spreads by c.Sort and diversifies the first top of them, keeping only the best
+// limit: the SortSpreads and Diversify of every spread a frontend truncates to the results it shows. Without
+// constraints the best limit, raised to top, are selected with TopSpreads. The constraints can reach
+// anywhere in the ranking for a spread to swap in, the best of an expiration or one far enough from those
+// chosen, so with any set every spread is ranked and diversified before keeping the best limit. limit <= 0
+// keeps every spread, sorting them in place.
+func RankSpreads(spreads []models.SpreadWithProbabilities, top, limit int, c RankingConstraints) []models.SpreadWithProbabilities {
+	if limit > 0 && top > limit {
+		limit = top
+	}
+	if limit > 0 && limit < len(spreads) && !c.constrained() {
+		return Diversify(TopSpreads(spreads, c.Sort, limit), top, c)
+	}
+	SortSpreads(spreads, c.Sort)
+	ranked := Diversify(spreads, top, c)
+	if limit > 0 && limit < len(ranked) {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// constrained reports whether any constraint can reach past the best top spreads, which Diversify only
+// deduplicates otherwise.
+func (c RankingConstraints) constrained() bool {
+	return c.MaxPerExpiration > 0 || c.MinStrikeSeparation > 0 || c.EveryExpiration
+}
+
+// Diversify reorders ranked spreads, best first, so the first top of them meet the constraints: each is
+// the best remaining spread that no spread already chosen duplicates. Duplicated spreads follow the chosen
+// ones in rank order, so nothing is dropped. With EveryExpiration, the best spread of each expiration is
+// chosen first, up to top of them, and the rest of the top is filled by rank. top <= 0 diversifies every
+// spread.
+func Diversify(spreads []models.SpreadWithProbabilities, top int, c RankingConstraints) []models.SpreadWithProbabilities {
+	if top <= 0 || top > len(spreads) {
+		top = len(spreads)
+	}
+
+	chosen := make([]bool, len(spreads))
+	var picks []int
+	perExpiration := make(map[string]int)
+	choose := func(i int) {
+		chosen[i] = true
+		picks = append(picks, i)
+		perExpiration[spreadExpiration(spreads[i])]++
+	}
+	allowed := func(i int) bool {
+		expiration := spreadExpiration(spreads[i])
+		if c.MaxPerExpiration > 0 && perExpiration[expiration] >= c.MaxPerExpiration {
+			return false
+		}
+		for _, j := range picks {
+			if duplicates(spreads[i], spreads[j], c.MinStrikeSeparation) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if c.EveryExpiration {
+		for i, spread := range spreads {
+			if len(picks) < top && perExpiration[spreadExpiration(spread)] == 0 {
+				choose(i)
+			}
+		}
+	}
+	for i := range spreads {
+		if len(picks) < top && !chosen[i] && allowed(i) {
+			choose(i)
+		}
+	}
+
+	// Chosen spreads keep their rank order, whatever order they were chosen in
+	diversified := make([]models.SpreadWithProbabilities, 0, len(spreads))
+	for i, spread := range spreads {
+		if chosen[i] {
+			diversified = append(diversified, spread)
+		}
+	}
+	for i, spread := range spreads {
+		if !chosen[i] {
+			diversified = append(diversified, spread)
+		}
+	}
+	return diversified
+}
+
+// spreadExpiration is the expiration date of the spread's short leg.
+func spreadExpiration(spread models.SpreadWithProbabilities) string {
+	return spread.Spread.ShortLeg.Option.ExpirationDate
+}
+
+// duplicates reports whether two spreads have the same legs, or are of the same type and expiration with
+// short strikes less than separation apart.
+func duplicates(a, b models.SpreadWithProbabilities, separation float64) bool {
+	if a.Spread.ShortLeg.Option.Symbol == b.Spread.ShortLeg.Option.Symbol && a.Spread.LongLeg.Option.Symbol == b.Spread.LongLeg.Option.Symbol &&
+		a.Spread.CallLeg.Option.Symbol == b.Spread.CallLeg.Option.Symbol {
+		return true
+	}
+	return a.Spread.SpreadType == b.Spread.SpreadType && spreadExpiration(a) == spreadExpiration(b) &&
+		math.Abs(a.Spread.ShortLeg.Option.Strike-b.Spread.ShortLeg.Option.Strike) < separation
+}
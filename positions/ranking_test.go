@@ -0,0 +1,102 @@
+package positions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// rankingSpread is a Bull Put spread expiring on expiration, its short strike at strike, scored score.
+func rankingSpread(expiration string, strike, score float64) models.SpreadWithProbabilities {
+	var s models.SpreadWithProbabilities
+	s.Spread.SpreadType = "Bull Put"
+	s.Spread.ShortLeg.Option.ExpirationDate = expiration
+	s.Spread.ShortLeg.Option.Strike = strike
+	s.Spread.ShortLeg.Option.Symbol = fmt.Sprintf("X%sP%g", expiration, strike)
+	s.Spread.LongLeg.Option.Symbol = fmt.Sprintf("X%sP%g", expiration, strike-5)
+	s.CompositeScore = score
+	return s
+}
+
+// rankingSpreads lists 20 spreads of 2026-01-16 a dollar apart, all ranked above 5 of 2026-02-20.
+func rankingSpreads() []models.SpreadWithProbabilities {
+	var spreads []models.SpreadWithProbabilities
+	for i := 0; i < 20; i++ {
+		spreads = append(spreads, rankingSpread("2026-01-16", 100+float64(i), 100-float64(i)))
+	}
+	for i := 0; i < 5; i++ {
+		spreads = append(spreads, rankingSpread("2026-02-20", 100+float64(i), 50-float64(i)))
+	}
+	return spreads
+}
+
+func TestRankSpreadsConstraintsHoldWhenLimitIsTop(t *testing.T) {
+	const top = 4
+	tests := []struct {
+		name  string
+		c     RankingConstraints
+		check func(t *testing.T, ranked []models.SpreadWithProbabilities)
+	}{
+		{
+			name: "max per expiration",
+			c:    RankingConstraints{MaxPerExpiration: 2},
+			check: func(t *testing.T, ranked []models.SpreadWithProbabilities) {
+				perExpiration := make(map[string]int)
+				for _, spread := range ranked[:top] {
+					perExpiration[spreadExpiration(spread)]++
+				}
+				if perExpiration["2026-01-16"] != 2 || perExpiration["2026-02-20"] != 2 {
+					t.Errorf("top %d by expiration = %v, want 2 of each", top, perExpiration)
+				}
+			},
+		},
+		{
+			name: "min strike separation",
+			c:    RankingConstraints{MinStrikeSeparation: 5},
+			check: func(t *testing.T, ranked []models.SpreadWithProbabilities) {
+				for i, a := range ranked[:top] {
+					for _, b := range ranked[i+1 : top] {
+						if duplicates(a, b, 5) {
+							t.Errorf("%s and %s are less than 5 apart", a.Spread.ShortLeg.Option.Symbol, b.Spread.ShortLeg.Option.Symbol)
+						}
+					}
+				}
+			},
+		},
+		{
+			name: "every expiration",
+			c:    RankingConstraints{EveryExpiration: true},
+			check: func(t *testing.T, ranked []models.SpreadWithProbabilities) {
+				found := false
+				for _, spread := range ranked[:top] {
+					found = found || spreadExpiration(spread) == "2026-02-20"
+				}
+				if !found {
+					t.Errorf("no spread of 2026-02-20 among the top %d", top)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := RankSpreads(rankingSpreads(), top, top, tt.c)
+			if len(ranked) != top {
+				t.Fatalf("kept %d spreads, want %d", len(ranked), top)
+			}
+			tt.check(t, ranked)
+		})
+	}
+}
+
+func TestRankSpreadsUnconstrainedKeepsBestLimit(t *testing.T) {
+	ranked := RankSpreads(rankingSpreads(), 3, 5, RankingConstraints{})
+	if len(ranked) != 5 {
+		t.Fatalf("kept %d spreads, want 5", len(ranked))
+	}
+	for i, spread := range ranked {
+		if want := 100 - float64(i); spread.CompositeScore != want {
+			t.Errorf("spread %d scored %g, want %g", i, spread.CompositeScore, want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package positions
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bcdannyboy/stocd/margin"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// ratioShortContracts is the number of short contracts sold per long contract in a ratio spread.
+const ratioShortContracts = 2
+
+// createRatioSpread prices a 1x2 ratio spread: one long option closer to the money and two short options
+// further out. The long option covers one short contract; the other is naked, leaving undefined risk
+// beyond the short strike.
+func createRatioSpread(shortOpt, longOpt tradier.Option, underlyingPrice, riskFreeRate float64, legs legCache) models.OptionSpread {
+	shortLeg := legs.leg(shortOpt, underlyingPrice, riskFreeRate)
+	longLeg := legs.leg(longOpt, underlyingPrice, riskFreeRate)
+
+	spread := models.OptionSpread{
+		ShortLeg:       shortLeg,
+		LongLeg:        longLeg,
+		ShortQuantity:  ratioShortContracts,
+		SpreadType:     determineSpreadType(shortOpt, longOpt),
+		SpreadCredit:   ratioShortContracts*shortOpt.Bid - longOpt.Ask,
+		SpreadBSMPrice: ratioShortContracts*shortLeg.BSMResult.Price - longLeg.BSMResult.Price,
+		IntrinsicValue: math.Max(0, ratioShortContracts*shortLeg.IntrinsicValue-longLeg.IntrinsicValue),
+		Greeks:         combineGreeks(scaledLeg{shortLeg, ratioShortContracts}, scaledLeg{longLeg, -1}),
+		Margin:         margin.Ratio(shortOpt, ratioShortContracts, underlyingPrice),
+		ExpectedMove:   expectedMove(underlyingPrice, shortLeg, longLeg),
+	}
+	spread.ExtrinsicValue = spread.SpreadCredit - spread.IntrinsicValue
+
+	if margin := SpreadWidth(spread); margin > 0 {
+		spread.CreditWidthRatio = spread.SpreadCredit / margin
+	}
+	spread.ROR = calculateReturnOnRisk(spread)
+	return spread
+}
+
+// createJadeLizard prices a short put with a bear call spread above it. The put is the short leg, the
+// short call the call leg and the long call the long leg. With a credit of at least the call spread's
+// width nothing can be lost above the calls, leaving the undefined risk below the put.
+func createJadeLizard(putOpt, callOpt, longCallOpt tradier.Option, underlyingPrice, riskFreeRate float64, legs legCache) models.OptionSpread {
+	putLeg := legs.leg(putOpt, underlyingPrice, riskFreeRate)
+	callLeg := legs.leg(callOpt, underlyingPrice, riskFreeRate)
+	longLeg := legs.leg(longCallOpt, underlyingPrice, riskFreeRate)
+
+	spread := models.OptionSpread{
+		ShortLeg:       putLeg,
+		LongLeg:        longLeg,
+		CallLeg:        callLeg,
+		SpreadType:     "Jade Lizard",
+		SpreadCredit:   putOpt.Bid + callOpt.Bid - longCallOpt.Ask,
+		SpreadBSMPrice: putLeg.BSMResult.Price + callLeg.BSMResult.Price - longLeg.BSMResult.Price,
+		IntrinsicValue: math.Max(0, putLeg.IntrinsicValue+callLeg.IntrinsicValue-longLeg.IntrinsicValue),
+		Greeks:         combineGreeks(scaledLeg{putLeg, 1}, scaledLeg{callLeg, 1}, scaledLeg{longLeg, -1}),
+		Margin:         margin.JadeLizard(putOpt, callOpt, longCallOpt, underlyingPrice),
+		ExpectedMove:   expectedMove(underlyingPrice, putLeg, callLeg, longLeg),
+	}
+	spread.ExtrinsicValue = spread.SpreadCredit - spread.IntrinsicValue
+
+	if margin := SpreadWidth(spread); margin > 0 {
+		spread.CreditWidthRatio = spread.SpreadCredit / margin
+	}
+	spread.ROR = calculateReturnOnRisk(spread)
+	return spread
+}
+
+// candidateJadeLizards combines each put with the call spreads above it that pass the width constraints,
+// keeping those whose credit covers the call spread's width so no risk is taken above the calls.
+func candidateJadeLizards(options []tradier.Option, opts ScanOptions) [][3]tradier.Option {
+	var puts, calls []tradier.Option
+	for _, option := range options {
+		if option.OptionType == "put" {
+			puts = append(puts, option)
+		} else {
+			calls = append(calls, option)
+		}
+	}
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].Strike < calls[j].Strike
+	})
+
+	index := strikeIndex(calls)
+	var legs [][3]tradier.Option
+	for i := 0; i < len(calls)-1; i++ {
+		for j := i + 1; j < len(calls); j++ {
+			width := calls[j].Strike - calls[i].Strike
+			if !opts.allowsWidth(width, index[calls[j].Strike]-index[calls[i].Strike]) {
+				continue
+			}
+			for _, put := range puts {
+				if put.Strike < calls[i].Strike && put.Bid+calls[i].Bid-calls[j].Ask >= width {
+					legs = append(legs, [3]tradier.Option{put, calls[j], calls[i]})
+				}
+			}
+		}
+	}
+	return legs
+}
+
+// scaledLeg is a leg with its signed number of short contracts, negative for long legs.
+type scaledLeg struct {
+	leg      models.SpreadLeg
+	quantity float64
+}
+
+// combineGreeks adds the greeks of several legs weighted by their short quantities, in the same sign
+// convention as calculateSpreadGreeks. The implied volatility is vega weighted over the absolute vegas.
+func combineGreeks(legs ...scaledLeg) models.BSMResult {
+	var greeks models.BSMResult
+	weighted, totalVega := 0.0, 0.0
+	for _, l := range legs {
+		g, q := l.leg.BSMResult, l.quantity
+		greeks.Price += q * g.Price
+		greeks.Delta += q * g.Delta
+		greeks.Gamma += q * g.Gamma
+		greeks.Theta += q * g.Theta
+		greeks.Vega += q * g.Vega
+		greeks.Rho += q * g.Rho
+		greeks.ShadowUpGamma += q * g.ShadowUpGamma
+		greeks.ShadowDownGamma += q * g.ShadowDownGamma
+		greeks.SkewGamma += q * g.SkewGamma
+		weighted += math.Abs(q*g.Vega) * g.ImpliedVolatility
+		totalVega += math.Abs(q * g.Vega)
+	}
+	if totalVega > 0 {
+		greeks.ImpliedVolatility = weighted / totalVega
+	}
+	return greeks
+}
@@ -0,0 +1,120 @@
+package positions
+
+import (
+	"log"
+	"math"
+	"os"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/probability"
+)
+
+// Default composite score weights, overridden by the SCORE_WEIGHT_* settings
+const (
+	WeightLiquidity   = 0.3
+	WeightProbability = 0.3
+	WeightVaR         = 0.1
+	WeightES          = 0.1
+	WeightCreditWidth = 0.1
+	WeightVRP         = 0.1
+
+	DefaultScoreRiskLevel = 0.95 // Confidence level of the VaR and expected shortfall scored, overridden by SCORE_RISK_LEVEL
+)
+
+// ScoreSpreads sets the composite score of each spread, weighing its probability of profit, VaR,
+// expected shortfall, liquidity, credit/width ratio and variance risk premium, normalized over the set, and its contract
+// activity. The weights default to the Weight* constants and are overridden by the SCORE_WEIGHT_*
+// settings, and the VaR and expected shortfall are those at SCORE_RISK_LEVEL. Scores only compare within
+// the set, so score spreads ranked together at once.
+func ScoreSpreads(spreads []models.SpreadWithProbabilities) {
+	level := scoreRiskLevel()
+
+	minProb, maxProb := math.Inf(1), math.Inf(-1)
+	minVaR, maxVaR := math.Inf(1), math.Inf(-1)
+	minES, maxES := math.Inf(1), math.Inf(-1)
+	minLiquidity, maxLiquidity := math.Inf(1), math.Inf(-1)
+	minCreditWidth, maxCreditWidth := math.Inf(1), math.Inf(-1)
+	minVRP, maxVRP := math.Inf(1), math.Inf(-1)
+
+	// Find min and max values
+	for _, spread := range spreads {
+		prob := spread.Probability.AverageProbability
+		risk := scoredTailRisk(spread, level)
+		tailVaR := math.Abs(risk.VaR)
+		es := math.Abs(risk.ExpectedShortfall)
+		liquidity := spread.Liquidity
+		creditWidth := spread.Spread.CreditWidthRatio
+
+		minProb = math.Min(minProb, prob)
+		maxProb = math.Max(maxProb, prob)
+		minVaR = math.Min(minVaR, tailVaR)
+		maxVaR = math.Max(maxVaR, tailVaR)
+		minES = math.Min(minES, es)
+		maxES = math.Max(maxES, es)
+		minLiquidity = math.Min(minLiquidity, liquidity)
+		maxLiquidity = math.Max(maxLiquidity, liquidity)
+		minCreditWidth = math.Min(minCreditWidth, creditWidth)
+		maxCreditWidth = math.Max(maxCreditWidth, creditWidth)
+		minVRP = math.Min(minVRP, spread.VarianceRiskPremium)
+		maxVRP = math.Max(maxVRP, spread.VarianceRiskPremium)
+	}
+
+	normalizeValue := func(value, min, max float64) float64 {
+		if min == max {
+			return 0.5 // Return middle value if min and max are the same
+		}
+		return (value - min) / (max - min)
+	}
+
+	// Calculate composite scores
+	for i := range spreads {
+		prob := spreads[i].Probability.AverageProbability
+		risk := scoredTailRisk(spreads[i], level)
+		tailVaR := math.Abs(risk.VaR)
+		es := math.Abs(risk.ExpectedShortfall)
+		liquidity := spreads[i].Liquidity
+		creditWidth := spreads[i].Spread.CreditWidthRatio
+		activity := ActivityScore(spreads[i].Activity)
+
+		// Normalize values
+		normProb := normalizeValue(prob, minProb, maxProb)
+		normVaR := 1 - normalizeValue(tailVaR, minVaR, maxVaR)                     // Invert so lower is better
+		normES := 1 - normalizeValue(es, minES, maxES)                             // Invert so lower is better
+		normLiquidity := 1 - normalizeValue(liquidity, minLiquidity, maxLiquidity) // Invert so lower is better
+		normCreditWidth := normalizeValue(creditWidth, minCreditWidth, maxCreditWidth)
+		normVRP := normalizeValue(spreads[i].VarianceRiskPremium, minVRP, maxVRP)
+
+		// Calculate weighted score
+		weightedScore := (normLiquidity * envFloat("SCORE_WEIGHT_LIQUIDITY", WeightLiquidity)) +
+			(normProb * envFloat("SCORE_WEIGHT_PROBABILITY", WeightProbability)) +
+			(normVaR * envFloat("SCORE_WEIGHT_VAR", WeightVaR)) +
+			(normES * envFloat("SCORE_WEIGHT_ES", WeightES)) +
+			(normCreditWidth * envFloat("SCORE_WEIGHT_CREDIT_WIDTH", WeightCreditWidth)) +
+			(normVRP * envFloat("SCORE_WEIGHT_VRP", WeightVRP))
+
+		spreads[i].CompositeScore = weightedScore * (1 + activity) // Activity is logged to dampen the effect of volume
+	}
+}
+
+// scoreRiskLevel reads SCORE_RISK_LEVEL, logging an invalid value and using DefaultScoreRiskLevel.
+func scoreRiskLevel() float64 {
+	spec := os.Getenv("SCORE_RISK_LEVEL")
+	if spec == "" {
+		return DefaultScoreRiskLevel
+	}
+	level, err := probability.ParseRiskLevel(spec)
+	if err != nil {
+		log.Printf("Invalid SCORE_RISK_LEVEL, scoring the 95%% tail risk: %v", err)
+		return DefaultScoreRiskLevel
+	}
+	return level
+}
+
+// scoredTailRisk is the spread's tail risk at the level, or at 95% when it was not computed at the level.
+func scoredTailRisk(spread models.SpreadWithProbabilities, level float64) models.TailRisk {
+	if risk, ok := spread.TailRiskAt(level); ok {
+		return risk
+	}
+	risk, _ := spread.TailRiskAt(DefaultScoreRiskLevel)
+	return risk
+}
@@ -0,0 +1,20 @@
+package positions
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+func TestScoreSpreadsNormalizesOverTheSet(t *testing.T) {
+	// Every factor but the probability of profit is equal, so the spreads differ by its full weight
+	spreads := make([]models.SpreadWithProbabilities, 2)
+	spreads[0].Probability.AverageProbability = 0.7
+	spreads[1].Probability.AverageProbability = 0.9
+	ScoreSpreads(spreads)
+
+	if got := spreads[1].CompositeScore - spreads[0].CompositeScore; math.Abs(got-WeightProbability) > 1e-9 {
+		t.Errorf("score difference = %v, want the probability weight %v", got, WeightProbability)
+	}
+}
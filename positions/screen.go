@@ -0,0 +1,191 @@
+package positions
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// QuickScreen ranks candidate spreads with a closed-form probability of profit instead of Monte Carlo
+// simulation, for a preliminary answer while the full scan runs. The probability is that of the short
+// strike expiring out of the money under a lognormal at the short leg's implied volatility, and spreads
+// are ranked by expected value per dollar at risk. Evaluation stops once budget has elapsed, ranking
+// what was screened so far.
+func QuickScreen(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate, minReturnOnRisk float64, currentDate time.Time, spreadType string, opts ScanOptions, topN int, budget time.Duration) []models.SpreadWithProbabilities {
+	deadline := time.Now().Add(budget)
+	chain, _ = opts.standardChain(chain)
+	chain, _ = opts.validQuotes(chain, underlyingPrice, riskFreeRate, currentDate)
+
+	expirations := make([]string, 0, len(chain))
+	for expiration := range chain {
+		if opts.allowsExpiration(expiration, chain[expiration]) {
+			expirations = append(expirations, expiration)
+		}
+	}
+	sort.Strings(expirations)
+
+	var spreads []models.SpreadWithProbabilities
+	for _, expiration := range expirations {
+		tau, err := market.YearsToExpiration(expiration, currentDate)
+		if err != nil || tau <= 0 {
+			continue
+		}
+		move := ExpectedMove(chain[expiration].Options.Option, underlyingPrice, tau)
+
+		for _, side := range spreadSides(spreadType) {
+			candidates, price := screenCandidates(filterOptions(chain[expiration].Options.Option, side), side, underlyingPrice, riskFreeRate, opts)
+			for _, legs := range candidates {
+				if time.Now().After(deadline) {
+					return rankScreened(spreads, topN)
+				}
+				if !legsTraded(legs...) {
+					continue
+				}
+
+				spread := price(legs)
+				setExpectedMove(&spread, move, underlyingPrice)
+				if spread.ROR <= minReturnOnRisk || !opts.allowsCredit(spread) || !opts.allowsDecay(spread) || !opts.allowsShortStrikes(spread) || !models.SameDeliverable(spread) || !opts.allowsQuotes(spread, currentDate) {
+					continue
+				}
+
+				pop := analyticProbabilityOfProfit(spread, underlyingPrice, riskFreeRate, tau)
+				maxLoss := SpreadWidth(spread) - spread.SpreadCredit
+				spreads = append(spreads, models.SpreadWithProbabilities{
+					Spread:        spread,
+					Probability:   models.ProbabilityResult{AverageProbability: pop, Probabilities: map[string]float64{"Analytic": pop}},
+					ExpectedValue: pop*spread.SpreadCredit - (1-pop)*maxLoss,
+					Breakeven:     models.BreakevenInfo{Price: models.BreakevenPrice(spread)},
+					MeetsRoR:      true,
+				})
+			}
+		}
+	}
+
+	return rankScreened(spreads, topN)
+}
+
+// screenCandidates returns the candidate legs of one expiration for a spread type and the function that
+// prices them: a declared strategy's legs in declaration order, or the (short, long, call) legs of a
+// built-in structure. The options are priced once up front and shared by the candidates, and legs are
+// only combined within a root symbol, so an SPX chain's AM-settled SPX and PM-settled SPXW series at the
+// same expiration are never mixed.
+func screenCandidates(options []tradier.Option, spreadType string, underlyingPrice, riskFreeRate float64, opts ScanOptions) ([][]tradier.Option, func([]tradier.Option) models.OptionSpread) {
+	legs := newLegCache(options, underlyingPrice, riskFreeRate)
+	roots := optionsByRoot(options)
+	var candidates [][]tradier.Option
+	if strategy, ok := LookupStrategy(spreadType); ok {
+		for _, rootOptions := range roots {
+			candidates = append(candidates, strategy.candidates(rootOptions)...)
+		}
+		return candidates, func(options []tradier.Option) models.OptionSpread {
+			return createStrategyPosition(strategy, options, underlyingPrice, riskFreeRate, opts, legs)
+		}
+	}
+
+	for _, rootOptions := range roots {
+		for _, legs := range candidateLegs(rootOptions, spreadType, opts.forUnderlying(underlyingPrice)) {
+			candidates = append(candidates, []tradier.Option{legs[0], legs[1], legs[2]})
+		}
+	}
+	return candidates, func(options []tradier.Option) models.OptionSpread {
+		return createOptionSpread(options[0], options[1], options[2], underlyingPrice, riskFreeRate, opts, legs)
+	}
+}
+
+// optionsByRoot splits the options by root symbol, in the order the roots first appear.
+func optionsByRoot(options []tradier.Option) [][]tradier.Option {
+	index := make(map[string]int)
+	var roots [][]tradier.Option
+	for _, option := range options {
+		i, ok := index[option.RootSymbol]
+		if !ok {
+			i = len(roots)
+			index[option.RootSymbol] = i
+			roots = append(roots, nil)
+		}
+		roots[i] = append(roots[i], option)
+	}
+	return roots
+}
+
+func rankScreened(spreads []models.SpreadWithProbabilities, topN int) []models.SpreadWithProbabilities {
+	for i := range spreads {
+		if risk := SpreadWidth(spreads[i].Spread) - spreads[i].Spread.SpreadCredit; risk > 0 {
+			spreads[i].CompositeScore = spreads[i].ExpectedValue / risk
+		}
+	}
+	sort.SliceStable(spreads, func(i, j int) bool {
+		return spreads[i].CompositeScore > spreads[j].CompositeScore
+	})
+	return spreads[:min(topN, len(spreads))]
+}
+
+// analyticProbabilityOfProfit is the closed-form probability of the spread profiting at expiration. With
+// no time left (tau <= 0) the underlying settles where it is, so it is 1 when the spread profits at the
+// current price, e.g. a credit spread's short leg is out of the money, and 0 otherwise.
+func analyticProbabilityOfProfit(spread models.OptionSpread, underlyingPrice, riskFreeRate, tau float64) float64 {
+	if tau <= 0 {
+		if models.IsProfitable(spread, underlyingPrice) {
+			return 1
+		}
+		return 0
+	}
+	vol := spread.ShortLeg.BSMResult.ImpliedVolatility
+	if vol <= 0 || math.IsNaN(vol) {
+		vol = spread.ShortLeg.Option.Greeks.MidIv
+	}
+	strike := spread.ShortLeg.Option.Strike
+	if vol <= 0 {
+		return 0.5
+	}
+
+	if spread.SpreadType == "Covered Call" {
+		strike = models.BreakevenPrice(spread) // Covered calls profit above the breakeven, not the strike
+	}
+	d2 := func(price float64) float64 {
+		return (math.Log(underlyingPrice/price) + (riskFreeRate-0.5*vol*vol)*tau) / (vol * math.Sqrt(tau))
+	}
+	if len(spread.Legs) > 0 || spread.InversePrice > 0 {
+		// Sum the probability of every stretch between breakevens where the strategy profits
+		bounds := append(append([]float64{0}, models.LegsBreakevens(spread)...), math.Inf(1))
+		pop := 0.0
+		for i := 1; i < len(bounds); i++ {
+			lo, hi := bounds[i-1], bounds[i]
+			mid := (lo + hi) / 2
+			if math.IsInf(hi, 1) {
+				mid = 2 * lo
+			}
+			if !models.IsProfitable(spread, mid) {
+				continue
+			}
+			upper, lower := 1.0, 0.0
+			if lo > 0 {
+				upper = normCDF(d2(lo))
+			}
+			if !math.IsInf(hi, 1) {
+				lower = normCDF(d2(hi))
+			}
+			pop += upper - lower
+		}
+		return pop
+	}
+	switch spread.SpreadType {
+	case "Short Strangle", "Short Straddle", "Put Ratio", "Jade Lizard":
+		// Above the breakeven and below any upper one, with every leg at the short leg's volatility
+		pop := normCDF(d2(models.BreakevenPrice(spread)))
+		if upper := models.UpperBreakevenPrice(spread); upper > 0 {
+			pop -= normCDF(d2(upper))
+		}
+		return pop
+	case "Call Ratio":
+		return normCDF(-d2(models.BreakevenPrice(spread)))
+	}
+	if spread.SpreadType == "Bear Call" {
+		return normCDF(-d2(strike))
+	}
+	return normCDF(d2(strike))
+}
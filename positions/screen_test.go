@@ -0,0 +1,29 @@
+package positions
+
+import (
+	"testing"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+func TestAnalyticProbabilityOfProfitAtExpiration(t *testing.T) {
+	var spread models.OptionSpread
+	spread.SpreadType = "Bull Put"
+	spread.ShortLeg.Option.Strike, spread.LongLeg.Option.Strike = 100, 95
+	spread.ShortLeg.Option.Greeks.MidIv = 0.3
+
+	for _, tt := range []struct {
+		price, tau, want float64
+	}{
+		{105, 0, 1},  // Short put out of the money at expiration
+		{98, 0, 0},   // Short put in the money at expiration
+		{105, -1, 1}, // Expired earlier today
+	} {
+		if got := analyticProbabilityOfProfit(spread, tt.price, 0.04, tt.tau); got != tt.want {
+			t.Errorf("PoP at %g with tau %g = %g, want %g", tt.price, tt.tau, got, tt.want)
+		}
+	}
+	if pop := analyticProbabilityOfProfit(spread, 105, 0.04, 1.0/365); pop <= DefaultMinAnalyticPoP || pop >= 1 {
+		t.Errorf("PoP with a day left = %g, want between the default minimum and 1", pop)
+	}
+}
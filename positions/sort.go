@@ -0,0 +1,128 @@
+package positions
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// Keys spreads can be ranked by, best first.
+const (
+	SortScore       = "score"         // Composite score, the default
+	SortEV          = "ev"            // Expected value
+	SortPoP         = "pop"           // Probability of profit
+	SortROR         = "ror"           // Return on risk
+	SortThetaPerDay = "theta_per_day" // Time decay per share per day
+	SortThetaVega   = "theta_vega"    // Daily theta per point of vega
+	SortThetaCredit = "theta_credit"  // Daily theta as a fraction of the credit
+	SortDollarVaR   = "dollar_var"    // 95% VaR in dollars per contract, smallest first
+)
+
+// sortKeys give each key's value, higher ranking first.
+var sortKeys = map[string]func(models.SpreadWithProbabilities) float64{
+	SortScore:       func(s models.SpreadWithProbabilities) float64 { return s.CompositeScore },
+	SortEV:          func(s models.SpreadWithProbabilities) float64 { return s.ExpectedValue },
+	SortPoP:         func(s models.SpreadWithProbabilities) float64 { return s.Probability.AverageProbability },
+	SortROR:         func(s models.SpreadWithProbabilities) float64 { return s.Spread.ROR },
+	SortThetaPerDay: func(s models.SpreadWithProbabilities) float64 { return s.Spread.Decay.ThetaPerDay },
+	SortThetaVega:   func(s models.SpreadWithProbabilities) float64 { return s.Spread.Decay.ThetaVegaRatio },
+	SortThetaCredit: func(s models.SpreadWithProbabilities) float64 { return s.Spread.Decay.ThetaCreditRatio },
+	SortDollarVaR:   func(s models.SpreadWithProbabilities) float64 { return -math.Abs(s.Dollars.VaR95) },
+}
+
+// sortAliases are the shorter names the sort keys are also known by.
+var sortAliases = map[string]string{"theta": SortThetaPerDay, "var": SortDollarVaR, "probability": SortPoP}
+
+// SortKeys lists the known sort keys in a stable order.
+func SortKeys() []string {
+	return []string{SortScore, SortEV, SortPoP, SortROR, SortThetaPerDay, SortThetaVega, SortThetaCredit, SortDollarVaR}
+}
+
+// ParseSort reads a comma-separated list of sort keys, case insensitively, e.g. "pop,ev": spreads are
+// ranked by the first key, ties broken by the next. An empty spec ranks by SortScore.
+func ParseSort(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return []string{SortScore}, nil
+	}
+	var keys []string
+	for _, entry := range strings.Split(spec, ",") {
+		key := strings.ToLower(strings.TrimSpace(entry))
+		if alias, ok := sortAliases[key]; ok {
+			key = alias
+		}
+		if _, ok := sortKeys[key]; !ok {
+			return nil, fmt.Errorf("unknown sort key %q (known: %s)", entry, strings.Join(SortKeys(), ", "))
+		}
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// SortSpreads orders the spreads best first by the keys, each breaking the ties of those before it,
+// skipping unknown keys. Spreads tied on every key, or with no keys given, are ordered by composite score,
+// so score the spreads before sorting them.
+func SortSpreads(spreads []models.SpreadWithProbabilities, keys []string) {
+	better := sortBetter(keys)
+	sort.SliceStable(spreads, func(i, j int) bool { return better(spreads[i], spreads[j]) })
+}
+
+// TopSpreads returns the best n spreads by the keys, best first, as SortSpreads would order them. They are
+// selected with a heap of n spreads, in O(len(spreads) log n) rather than sorting every spread; n <= 0 or
+// at least len(spreads) sorts them all. The spreads are not modified.
+func TopSpreads(spreads []models.SpreadWithProbabilities, keys []string, n int) []models.SpreadWithProbabilities {
+	if n <= 0 || n >= len(spreads) {
+		sorted := slices.Clone(spreads)
+		SortSpreads(sorted, keys)
+		return sorted
+	}
+	top := newTopSpreads(n, sortBetter(keys))
+	for _, spread := range spreads {
+		top.Add(spread)
+	}
+	best := top.Spreads()
+	SortSpreads(best, keys)
+	return best
+}
+
+// sortBetter reports whether a ranks before b by the keys, as SortSpreads orders them.
+func sortBetter(keys []string) func(a, b models.SpreadWithProbabilities) bool {
+	var values []func(models.SpreadWithProbabilities) float64
+	for _, key := range keys {
+		if value, ok := sortKeys[key]; ok {
+			values = append(values, value)
+		}
+	}
+	values = append(values, sortKeys[SortScore])
+	return func(a, b models.SpreadWithProbabilities) bool {
+		for _, value := range values {
+			if x, y := value(a), value(b); x != y {
+				return x > y
+			}
+		}
+		return false
+	}
+}
+
+// DescribeSort names the keys for messages, e.g. "PoP, then EV".
+func DescribeSort(keys []string) string {
+	names := map[string]string{
+		SortScore: "score", SortEV: "EV", SortPoP: "PoP", SortROR: "ROR", SortThetaPerDay: "theta/day",
+		SortThetaVega: "theta/vega", SortThetaCredit: "theta/credit", SortDollarVaR: "dollar VaR",
+	}
+	var described []string
+	for _, key := range keys {
+		if name, ok := names[key]; ok {
+			described = append(described, name)
+		}
+	}
+	if len(described) == 0 {
+		return names[SortScore]
+	}
+	return strings.Join(described, ", then ")
+}
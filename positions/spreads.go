@@ -6,30 +6,59 @@ import (
 	"math"
 	"runtime"
 	"sort"
-	"strings"
-	"sync"
+	"strconv"
 	"time"
 
+	"github.com/bcdannyboy/stocd/futures"
+	"github.com/bcdannyboy/stocd/margin"
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/metrics"
 	"github.com/bcdannyboy/stocd/models"
 	"github.com/bcdannyboy/stocd/probability"
+	"github.com/bcdannyboy/stocd/progress"
 	"github.com/bcdannyboy/stocd/tradier"
-	"github.com/slack-go/slack"
 )
 
-const (
-	workerPoolSize = 1000
+var (
+	scanDuration       = metrics.NewHistogram("stocd_scan_duration_seconds", "Duration of spread scans, by strategy.", metrics.DurationBuckets, "strategy")
+	spreadsScreened    = metrics.NewCounter("stocd_spreads_screened_total", "Candidate spreads priced and screened, by strategy.", "strategy")
+	spreadsSimulated   = metrics.NewCounter("stocd_spreads_simulated_total", "Spreads simulated with the model ensemble, by strategy.", "strategy")
+	simulationDuration = metrics.NewHistogram("stocd_spread_simulation_duration_seconds", "Duration of the ensemble simulation of one spread.", metrics.DurationBuckets)
 )
 
-var globalModels probability.GlobalModels
+// Stages of a scan reported to its progress tracker.
+const (
+	StageCalibrating = "calibrating"
+	StageScreening   = "screening"
+	StageSimulating  = "simulating"
+)
 
-func IdentifySpreads(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, spreadType string, progressChan chan<- int, slackClient *slack.Client, channelID string, calibrationChan chan<- string) []models.SpreadWithProbabilities {
+// IdentifySpreads scans the chain for spreads of spreadType. Progress reporting is optional: tracker and
+// status may be nil.
+func IdentifySpreads(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, spreadType string, opts ScanOptions, tracker *progress.Tracker, status StatusSink) []models.SpreadWithProbabilities {
 	startTime := time.Now()
 	log.Printf("IdentifySpreads started at %v", startTime)
+	span := metrics.StartSpan("scan", nil)
+	span.SetAttribute("strategy", spreadType)
+	defer span.End(nil)
+	defer scanDuration.ObserveSince(startTime, spreadType)
 
 	if len(chain) == 0 {
 		fmt.Printf("Warning: Option chain is empty for %s spreads\n", spreadType)
 		return nil
 	}
+	chain, nonstandard := opts.standardChain(chain)
+	if nonstandard > 0 {
+		nonstandardMsg := fmt.Sprintf("Left out %d nonstandard options (minis and adjusted series)", nonstandard)
+		fmt.Println(nonstandardMsg)
+		reportStatus(status, nonstandardMsg)
+	}
+	chain, quoteCheck := opts.validQuotes(chain, underlyingPrice, riskFreeRate, currentDate)
+	if quoteCheck.Problems() > 0 {
+		quoteMsg := fmt.Sprintf("Checked the chain's quotes: %s", quoteCheck)
+		fmt.Println(quoteMsg)
+		reportStatus(status, quoteMsg)
+	}
 
 	fmt.Printf("Identifying %s Spreads for underlying price: %.2f, Risk-Free Rate: %.4f, Min Return on Risk: %.4f\n", spreadType, underlyingPrice, riskFreeRate, minReturnOnRisk)
 
@@ -47,18 +76,43 @@ func IdentifySpreads(chain map[string]*tradier.OptionChain, underlyingPrice, ris
 	fmt.Printf("Average Implied Volatility: %.4f\n", avgIV)
 	fmt.Printf("Average Volatility: %.4f\n", avgVol)
 
-	calibrateGlobalModels(history, chain, underlyingPrice, riskFreeRate, yzVolatilities, rsVolatilities, spreadType, slackClient, channelID, calibrationChan)
+	tracker.SetStage(StageCalibrating, 0)
+	var globalModels probability.GlobalModels
+	if opts.Models != nil {
+		globalModels = *opts.Models
+		globalModels.Paths = probability.NewPathCache() // Paths are specific to the scan's underlying price
+		reportStatus(status, "Using previously calibrated models")
+	} else {
+		calibrationSpan := metrics.StartSpan("calibrate", span)
+		globalModels = calibrateGlobalModels(history, chain, underlyingPrice, riskFreeRate, yzVolatilities, rsVolatilities, currentDate, status)
+		calibrationSpan.End(nil)
+	}
+	globalModels.IntradayVol = opts.IntradayVolatility
+	if opts.IntradayVolatility > 0 {
+		fmt.Printf("Intraday Volatility: %.4f\n", opts.IntradayVolatility)
+	}
 
 	numCPU := runtime.NumCPU()
 	runtime.GOMAXPROCS(numCPU)
 	fmt.Printf("Using %d CPUs\n", numCPU)
 
-	totalJobs := calculateTotalJobs(chain, spreadType)
-	fmt.Printf("Total spreads to process: %d\n", totalJobs)
-
 	log.Printf("Starting processChainOptimized at %v", time.Now())
-	spreads := processChainOptimized(chain, underlyingPrice, riskFreeRate, yzVolatilities, rsVolatilities, localVolSurface, minReturnOnRisk, currentDate, spreadType, totalJobs, history, avgVol, progressChan)
+	premiums := varianceRiskPremiums(chain, underlyingPrice, history, currentDate)
+	cacheBefore := probability.CacheStats()
+	spreads := processChainOptimized(chain, underlyingPrice, riskFreeRate, yzVolatilities, rsVolatilities, localVolSurface, minReturnOnRisk, currentDate, spreadType, opts, history, globalModels, avgVol, premiums, tracker, span)
 	log.Printf("Finished processChainOptimized at %v", time.Now())
+	if cacheStats := probability.CacheStats().Sub(cacheBefore); cacheStats.Hits > 0 {
+		cacheMsg := fmt.Sprintf("Reused the cached simulations of %d spreads: %s", cacheStats.Hits, cacheStats)
+		fmt.Println(cacheMsg)
+		reportStatus(status, cacheMsg)
+	} else {
+		fmt.Printf("Simulation cache: %s\n", cacheStats)
+	}
+	if stale := countStaleQuotes(spreads); stale > 0 {
+		staleMsg := fmt.Sprintf("%d of %d spreads have a leg last quoted more than %v ago and are flagged as stale", stale, len(spreads), opts.MaxQuoteAge)
+		fmt.Println(staleMsg)
+		reportStatus(status, staleMsg)
+	}
 
 	log.Printf("Sorting %d spreads by highest probability", len(spreads))
 	sort.Slice(spreads, func(i, j int) bool {
@@ -71,8 +125,19 @@ func IdentifySpreads(chain map[string]*tradier.OptionChain, underlyingPrice, ris
 	for i, spread := range spreads {
 		fmt.Printf("\nSpread %d:\n", i+1)
 		fmt.Printf("  Short Leg: %s, Long Leg: %s\n", spread.Spread.ShortLeg.Option.Symbol, spread.Spread.LongLeg.Option.Symbol)
-		fmt.Printf("  Spread Credit: %.2f, ROR: %.2f%%\n", spread.Spread.SpreadCredit, spread.Spread.ROR*100)
+		fmt.Printf("  Spread Credit: %.2f, ROR: %.2f%%, Credit/Width: %.2f%%\n", spread.Spread.SpreadCredit, spread.Spread.ROR*100, spread.Spread.CreditWidthRatio*100)
 		fmt.Printf("  Probability of Profit: %.2f%%\n", spread.Probability.AverageProbability*100)
+		fmt.Printf("  Largest Probability Weights:\n")
+		for _, key := range largestWeights(spread.Probability.Weights, 5) {
+			fmt.Printf("    %s: %.2f%% (weight %.3f)\n", key, spread.Probability.Probabilities[key]*100, spread.Probability.Weights[key])
+		}
+		fmt.Printf("  Expected Value: %.2f, Expected Profit: %.2f\n", spread.ExpectedValue, spread.ExpectedProfit)
+		fmt.Printf("  Theta/Day: %.4f, Theta/Vega: %.2f, Theta/Credit: %.2f%%\n", spread.Spread.Decay.ThetaPerDay, spread.Spread.Decay.ThetaVegaRatio, spread.Spread.Decay.ThetaCreditRatio*100)
+		fmt.Printf("  Variance Risk Premium: %.4f\n", spread.VarianceRiskPremium)
+		fmt.Printf("  Breakeven: %.2f (%.2f%% / %.2f SD from spot)\n", spread.Breakeven.Price, spread.Breakeven.DistancePct*100, spread.Breakeven.DistanceSD)
+		if spread.Quotes.Stale {
+			fmt.Printf("  Stale Quotes: last quoted %s (%v ago)\n", spread.Quotes.QuotedAt.Format("Jan 2 15:04:05"), spread.Quotes.Age.Round(time.Second))
+		}
 
 		fmt.Printf("  Merton Model Parameters:\n")
 		fmt.Printf("    Lambda: %.4f, Mu: %.4f, Delta: %.4f\n", spread.MertonParams.Lambda, spread.MertonParams.Mu, spread.MertonParams.Delta)
@@ -112,75 +177,92 @@ func IdentifySpreads(chain map[string]*tradier.OptionChain, underlyingPrice, ris
 	return spreads
 }
 
-func processChainOptimized(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, yzVolatilities, rsVolatilities map[string]float64, localVolSurface models.VolatilitySurface, minReturnOnRisk float64, currentDate time.Time, spreadType string, totalJobs int, history tradier.QuoteHistory, avgVol float64, progressChan chan<- int) []models.SpreadWithProbabilities {
+// varianceRiskPremiums returns the variance risk premium of each expiration of the chain, the premium of
+// the spreads whose short leg expires then.
+func varianceRiskPremiums(chain map[string]*tradier.OptionChain, underlyingPrice float64, history tradier.QuoteHistory, currentDate time.Time) map[string]float64 {
+	premiums := make(map[string]float64)
+	for _, premium := range models.VarianceRiskPremiums(models.ATMTermStructure(chain, underlyingPrice, currentDate), history) {
+		premiums[premium.Expiration] = premium.Premium
+		fmt.Printf("Variance risk premium %s (%d days): IV %.4f, forecast RV %.4f, premium %.4f\n", premium.Expiration, premium.Days, premium.IV, premium.ForecastRV, premium.Premium)
+	}
+	return premiums
+}
+
+// processChainOptimized evaluates the chain in two stages: every candidate is priced and screened on its
+// credit, ROR and analytic probability of profit, then only the best opts.SimulationCandidates survivors
+// are simulated with the full model ensemble. Viable spreads are written to opts.Stream as they complete,
+// and only the best opts.MaxSpreads of them are kept.
+func processChainOptimized(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, yzVolatilities, rsVolatilities map[string]float64, localVolSurface models.VolatilitySurface, minReturnOnRisk float64, currentDate time.Time, spreadType string, opts ScanOptions, history tradier.QuoteHistory, globalModels probability.GlobalModels, avgVol float64, premiums map[string]float64, tracker *progress.Tracker, span *metrics.Span) []models.SpreadWithProbabilities {
 	startTime := time.Now()
 	log.Printf("processChainOptimized started at %v", startTime)
 
-	jobChan := make(chan job, workerPoolSize)
-	resultChan := make(chan models.SpreadWithProbabilities, workerPoolSize)
-
-	var wg sync.WaitGroup
-	for i := 0; i < workerPoolSize; i++ {
-		wg.Add(1)
-		go worker(jobChan, resultChan, &wg, minReturnOnRisk, history, chain, avgVol)
-	}
+	tracker.SetStage(StageScreening, 0)
+	screeningSpan := metrics.StartSpan("screen", span)
+	candidates, screened := screenJobs(chain, underlyingPrice, riskFreeRate, yzVolatilities, rsVolatilities, localVolSurface, minReturnOnRisk, currentDate, spreadType, opts)
+	screeningSpan.SetAttribute("screened", strconv.Itoa(screened))
+	screeningSpan.End(nil)
+	spreadsScreened.Add(float64(screened), spreadType)
+	spreadsSimulated.Add(float64(len(candidates)), spreadType)
+	fmt.Printf("Screened %d spreads in %v, simulating %d\n", screened, time.Since(startTime), len(candidates))
+	tracker.SetStage(StageSimulating, len(candidates))
+	simulationSpan := metrics.StartSpan("simulate", span)
+	simulationSpan.SetAttribute("candidates", strconv.Itoa(len(candidates)))
+	defer simulationSpan.End(nil)
+
+	jobChan := make(chan job, maxSpreadWorkers())
+	resultChan := make(chan models.SpreadWithProbabilities, maxSpreadWorkers())
+
+	done := make(chan struct{})
+	defer close(done)
+	startWorkers(jobChan, resultChan, opts, history, chain, globalModels, avgVol, done)
 
 	go func() {
-		generateJobs(chain, underlyingPrice, riskFreeRate, yzVolatilities, rsVolatilities, localVolSurface, currentDate, spreadType, jobChan)
+		for _, j := range candidates {
+			jobChan <- j
+		}
 		close(jobChan)
 	}()
 
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
-
-	var spreads []models.SpreadWithProbabilities
-	var processed int
+	kept := newTopSpreads(opts.MaxSpreads, sortBetter(keepKeys))
+	viable := 0
+	stream := opts.Stream
 	for spread := range resultChan {
-		// Skip spreads with zero volume in either leg
-		if spread.Spread.ShortLeg.Option.Volume == 0 || spread.Spread.LongLeg.Option.Volume == 0 {
-			processed++
-			if processed >= totalJobs {
-				break
-			}
+		tracker.Add(1)
+		if !isSpreadViable(spread, minReturnOnRisk) {
 			continue
 		}
-
-		if isSpreadViable(spread, minReturnOnRisk) && spread.MeetsRoR {
-			spreads = append(spreads, spread)
-		}
-		processed++
-		if processed >= totalJobs {
-			break
+		viable++
+		spread.VarianceRiskPremium = premiums[spread.Spread.ShortLeg.Option.ExpirationDate]
+		spread.Quotes = opts.quoteFreshness(spread.Spread, currentDate)
+		if stream != nil {
+			if err := stream.WriteSpread(spread); err != nil {
+				log.Printf("Error streaming spreads, no longer streaming this scan: %v", err)
+				stream = nil
+			}
 		}
-		progress := int((float64(processed) / float64(totalJobs)) * 100)
-		progressChan <- progress
+		kept.Add(spread)
+	}
+	spreads := kept.Spreads()
+	if viable > len(spreads) {
+		fmt.Printf("Kept the best %d of %d viable spreads by probability of profit\n", len(spreads), viable)
 	}
 
 	log.Printf("processChainOptimized finished at %v. Total time: %v", time.Now(), time.Since(startTime))
 	return spreads
 }
 
-func calibrateGlobalModels(history tradier.QuoteHistory, chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, yangzhangVolatilities, rogerssatchelVolatilities map[string]float64, spreadType string, slackClient *slack.Client, channelID string, calibrationChan chan<- string) {
-
-	sendCalibrationMessage := func(message string) {
-		calibrationChan <- message
-	}
-
-	sendCalibrationMessage("Starting model calibration...")
-	sendCalibrationMessage(fmt.Sprintf("Risk-Free Rate: %.4f", riskFreeRate))
+// calibrateGlobalModels fits the models to the price history and the chain's option prices.
+func calibrateGlobalModels(history tradier.QuoteHistory, chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, yangzhangVolatilities, rogerssatchelVolatilities map[string]float64, currentDate time.Time, status StatusSink) probability.GlobalModels {
+	var globalModels probability.GlobalModels
+	reportStatus(status, "Starting model calibration...")
+	reportStatus(status, fmt.Sprintf("Risk-Free Rate: %.4f", riskFreeRate))
 
 	fmt.Printf("Calibrating models...\n")
 	fmt.Printf("Risk-Free Rate: %.4f\n", riskFreeRate)
-	fmt.Printf("Extracting historical prices and strikes...\n")
-	sendCalibrationMessage("Extracting historical prices and strikes...")
+	fmt.Printf("Extracting historical prices and option quotes...\n")
+	reportStatus(status, "Extracting historical prices and option quotes...")
 	marketPrices := extractHistoricalPrices(history)
-	fmt.Printf("Extracting all strikes...\n")
-	sendCalibrationMessage("Extracting all strikes...")
-	strikes := extractAllStrikes(chain)
-	s0 := marketPrices[len(marketPrices)-1]
-	t := 1.0 // Use 1 year as a default time to maturity
+	tenors := calibrationQuotes(chain, underlyingPrice, currentDate)
 
 	// Calculate average volatilities
 	avgYZ := calculateAverageVolatility(yangzhangVolatilities)
@@ -189,135 +271,273 @@ func calibrateGlobalModels(history tradier.QuoteHistory, chain map[string]*tradi
 	avgVol := (avgYZ + avgRS + avgIV) / 3
 
 	volatilityMsg := fmt.Sprintf("Average Volatilities:\nYang-Zhang: %.4f\nRogers-Satchell: %.4f\nImplied: %.4f\nOverall: %.4f", avgYZ, avgRS, avgIV, avgVol)
-	sendCalibrationMessage(volatilityMsg)
+	reportStatus(status, volatilityMsg)
 
 	// Calibrate Merton model
-	sendCalibrationMessage("Calibrating Merton model...")
+	reportStatus(status, "Calibrating Merton model...")
 	fmt.Printf("Calculating historical jumps...\n")
-	historicalJumps := calculateHistoricalJumps(history)
-	mertonModel := models.NewMertonJumpDiffusion(riskFreeRate, avgVol, 1.0, 0, avgVol)
-	fmt.Printf("Calibrating Merton model with historical jumps...\n")
-	mertonModel.CalibrateJumpSizes(historicalJumps, 1)
+	historicalJumps, jumpTest, jumpRate := calculateHistoricalJumps(history)
+	jumpMsg := fmt.Sprintf("Detected %d jumps beyond %.0f bipower standard deviations (%.2f per year); jumps are %.1f%% of realized variance (z %.2f)",
+		len(historicalJumps), models.JumpThreshold, jumpRate, jumpTest.RelativeJump*100, jumpTest.Z)
+	fmt.Println(jumpMsg)
+	reportStatus(status, jumpMsg)
+	mertonModel := models.NewMertonJumpDiffusion(riskFreeRate, avgVol, jumpRate, 0, 0)
+	if len(historicalJumps) >= 2 {
+		fmt.Printf("Calibrating Merton model with historical jumps...\n")
+		mertonModel.CalibrateJumpSizes(historicalJumps, 1)
+	} else {
+		// Too few jumps to size them: no jumps at all
+		mertonModel.Lambda = 0
+	}
 	globalModels.Merton = mertonModel
 
 	// Calibrate Kou model
-	sendCalibrationMessage("Calibrating Kou model...")
+	reportStatus(status, "Calibrating Kou model...")
 	fmt.Printf("Calibrating Kou model...\n")
 	kouModel := models.NewKouJumpDiffusion(riskFreeRate, avgVol, marketPrices, 1.0/252.0)
 	globalModels.Kou = kouModel
 
-	// Calibrate CGMY model
-	sendCalibrationMessage("Calibrating CGMY model...")
-	fmt.Printf("Calibrating CGMY model...\n")
-	cgmyProcess := models.NewCGMYProcess(0.1, 5.0, 10.0, 0.5) // Initial guess
-	cgmyt := 1.0                                              // Use 1 year as a default time to maturity
-	isCall := true                                            // Assume we're using call options for calibration
-
-	if strings.Contains(strings.ToLower(spreadType), "put") {
-		fmt.Printf("Using put options for CGMY calibration\n")
-		sendCalibrationMessage("Using put options for CGMY calibration")
+	// Fit the heavy-tailed return distributions
+	if studentT, skewT, ok := models.FitSkewT(logReturns(marketPrices)); ok {
+		globalModels.StudentT, globalModels.SkewT = &studentT, &skewT
+		tailMsg := fmt.Sprintf("Fitted Student-t (Nu %.2f) and skew-t (Nu %.2f, Lambda %.3f) to daily returns", studentT.Nu, skewT.Nu, skewT.Lambda)
+		fmt.Println(tailMsg)
+		reportStatus(status, tailMsg)
 	}
 
-	cgmyProcess.Calibrate(marketPrices, strikes, underlyingPrice, riskFreeRate, cgmyt, isCall)
-	globalModels.CGMY = cgmyProcess
-
-	// Calibrate Heston model
-	sendCalibrationMessage("Calibrating Heston model...")
-	fmt.Printf("Calibrating Heston model...\n")
-	hestonModel := models.NewHestonModel(avgVol*avgVol, 2, avgVol*avgVol, 0.4, -0.5)
-	err := hestonModel.Calibrate(marketPrices, strikes, s0, riskFreeRate, t)
-	if err != nil {
-		errMsg := fmt.Sprintf("Error calibrating Heston model: %v", err)
-		fmt.Println(errMsg)
-		sendCalibrationMessage(errMsg)
-		// TODO: Handle calibration error
+	// Calibrate the CGMY and Heston models to the option prices of each tenor
+	heston := models.NewHestonModel(avgVol*avgVol, 2, avgVol*avgVol, 0.4, -0.5)
+	cgmy := models.NewCGMYProcess(0.1, 5.0, 10.0, 0.5) // Initial guess
+	globalModels.Heston, globalModels.CGMY, globalModels.Tenors = heston, cgmy, nil
+	if len(tenors) == 0 {
+		reportStatus(status, "No option quotes to calibrate the CGMY and Heston models to, using their initial parameters")
+	} else {
+		reportStatus(status, fmt.Sprintf("Calibrating CGMY and Heston models to %d tenors...", len(tenors)))
+		fmt.Printf("Calibrating CGMY and Heston models to %d tenors...\n", len(tenors))
+		globalModels.Tenors = calibrateTenors(tenors, heston, cgmy, underlyingPrice, riskFreeRate, status)
+
+		// Expirations of no tenor use the models of the tenor with the most quotes
+		most := 0
+		for i, tenor := range tenors {
+			if len(tenor.quotes) > len(tenors[most].quotes) {
+				most = i
+			}
+		}
+		top := globalModels.Tenors[most]
+		globalModels.Heston, globalModels.CGMY, globalModels.HestonFit, globalModels.CGMYFit = top.Heston, top.CGMY, top.HestonFit, top.CGMYFit
+		for _, tenor := range globalModels.Tenors {
+			reportStatus(status, fmt.Sprintf("%d-%d DTE: %s; %s", tenor.MinDays, tenor.MaxDays, tenor.HestonFit.Describe(), tenor.CGMYFit.Describe()))
+			if !tenor.Heston.FellerSatisfied() {
+				reportStatus(status, fmt.Sprintf("%d-%d DTE: Heston parameters violate the Feller condition (2 Kappa Theta < Xi^2), the variance may hit zero", tenor.MinDays, tenor.MaxDays))
+			}
+			if tenor.HestonFit.Poor() || tenor.CGMYFit.Poor() {
+				fmt.Printf("Warning: poor model fit to %d-%d DTE option prices: %s; %s\n", tenor.MinDays, tenor.MaxDays, tenor.HestonFit.Describe(), tenor.CGMYFit.Describe())
+			}
+		}
 	}
-	globalModels.Heston = hestonModel
+
+	globalModels.FitErrors = probability.ModelFitErrors(globalModels, marketPrices)
+	globalModels.Paths = probability.NewPathCache()
+	fitMsg := fmt.Sprintf("Model fit to historical skewness and kurtosis (error in standard errors): CGMY %.2f, Merton %.2f, Kou %.2f",
+		globalModels.FitErrors["CGMY_Heston"], globalModels.FitErrors["Merton_Heston"], globalModels.FitErrors["Kou_Heston"])
+	fmt.Println(fitMsg)
+	reportStatus(status, fitMsg)
 
 	fmt.Printf("Models calibrated\n")
-	sendCalibrationMessage("All models calibrated successfully")
+	reportStatus(status, "All models calibrated successfully")
+	return globalModels
 }
 
-func generateJobs(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, yzVolatilities, rsVolatilities map[string]float64, localVolSurface models.VolatilitySurface, currentDate time.Time, spreadType string, jobQueue chan<- job) {
+// screenJobs is the first stage of a scan: it prices every candidate of the chain and keeps those with
+// traded legs that meet the minimum ROR, the credit and expected move constraints and opts.MinAnalyticPoP. Survivors are
+// returned best analytic probability of profit first, at most opts.SimulationCandidates of them, along with
+// the number of candidates screened.
+func screenJobs(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, yzVolatilities, rsVolatilities map[string]float64, localVolSurface models.VolatilitySurface, minReturnOnRisk float64, currentDate time.Time, spreadType string, opts ScanOptions) ([]job, int) {
+	var survivors []job
+	screened := 0
 	for exp_date, expiration := range chain {
-		options := filterOptions(expiration.Options.Option, spreadType)
-		if len(options) == 0 {
+		if !opts.allowsExpiration(exp_date, expiration) {
 			continue
 		}
-
-		expirationDate, err := time.Parse("2006-01-02", exp_date)
+		daysToExpiration, err := market.DaysToExpiration(exp_date, currentDate)
 		if err != nil {
 			fmt.Printf("Error parsing expiration date %s: %v\n", exp_date, err)
 			continue
 		}
-		daysToExpiration := int(expirationDate.Sub(currentDate).Hours() / 24)
-
-		for i := 0; i < len(options)-1; i++ {
-			for j := i + 1; j < len(options); j++ {
-				var option1, option2 tradier.Option
-				if spreadType == "Bull Put" {
-					if options[i].Strike > options[j].Strike {
-						option1, option2 = options[i], options[j]
-					} else {
-						option1, option2 = options[j], options[i]
-					}
-				} else { // Bear Call
-					if options[i].Strike < options[j].Strike {
-						option1, option2 = options[i], options[j]
-					} else {
-						option1, option2 = options[j], options[i]
-					}
-				}
+		tau, _ := market.YearsToExpiration(exp_date, currentDate)
+		simulationTau := float64(daysToExpiration) / 365
+		if opts.ShortDated {
+			// Short-dated spreads are priced and simulated over the trading hours they have left
+			tau, _ = market.TradingYearsToExpiration(exp_date, currentDate)
+			simulationTau = tau
+			if tau <= 0 {
+				continue
+			}
+		}
+		move := ExpectedMove(expiration.Options.Option, underlyingPrice, tau)
 
-				jobQueue <- job{
-					option1:          option1,
-					option2:          option2,
-					underlyingPrice:  underlyingPrice,
-					riskFreeRate:     riskFreeRate,
-					yzVolatilities:   yzVolatilities,
-					rsVolatilities:   rsVolatilities,
-					localVolSurface:  localVolSurface,
-					daysToExpiration: daysToExpiration,
+		for _, side := range spreadSides(spreadType) {
+			options := filterOptions(expiration.Options.Option, side)
+			if len(options) == 0 {
+				continue
+			}
+
+			base := job{
+				underlyingPrice:  underlyingPrice,
+				riskFreeRate:     riskFreeRate,
+				yzVolatilities:   yzVolatilities,
+				rsVolatilities:   rsVolatilities,
+				localVolSurface:  localVolSurface,
+				daysToExpiration: daysToExpiration,
+				tau:              simulationTau,
+			}
+			candidates, price := screenCandidates(options, side, underlyingPrice, riskFreeRate, opts)
+			screened += len(candidates)
+			for _, legs := range candidates {
+				if !legsTraded(legs...) {
+					continue
+				}
+				j := base
+				j.spread = price(legs)
+				setExpectedMove(&j.spread, move, underlyingPrice)
+				if j.spread.ROR <= minReturnOnRisk || !opts.allowsCredit(j.spread) || !opts.allowsDecay(j.spread) || !opts.allowsShortStrikes(j.spread) || !models.SameDeliverable(j.spread) || !opts.allowsQuotes(j.spread, currentDate) {
+					continue
 				}
+				j.analyticPoP = analyticProbabilityOfProfit(j.spread, underlyingPrice, riskFreeRate, tau)
+				if j.analyticPoP < opts.MinAnalyticPoP {
+					continue
+				}
+				survivors = append(survivors, j)
 			}
 		}
 	}
+
+	sort.SliceStable(survivors, func(i, j int) bool {
+		return survivors[i].analyticPoP > survivors[j].analyticPoP
+	})
+	if opts.SimulationCandidates > 0 && len(survivors) > opts.SimulationCandidates {
+		survivors = survivors[:opts.SimulationCandidates]
+	}
+	return survivors, screened
 }
 
-func worker(jobQueue <-chan job, resultChan chan<- models.SpreadWithProbabilities, wg *sync.WaitGroup, minReturnOnRisk float64, history tradier.QuoteHistory, chain map[string]*tradier.OptionChain, avgVol float64) {
-	defer wg.Done()
-	for j := range jobQueue {
-		spread := createOptionSpread(j.option1, j.option2, j.underlyingPrice, j.riskFreeRate)
-		returnOnRisk := calculateReturnOnRisk(spread)
+// processJob simulates one screened candidate with the full model ensemble.
+func processJob(j job, resultChan chan<- models.SpreadWithProbabilities, history tradier.QuoteHistory, chain map[string]*tradier.OptionChain, globalModels probability.GlobalModels, avgVol float64) {
+	defer simulationDuration.ObserveSince(time.Now())
+	spreadWithProb := probability.MonteCarloSimulation(j.spread, j.underlyingPrice, j.riskFreeRate, j.daysToExpiration, j.tau, j.yzVolatilities, j.rsVolatilities, j.localVolSurface, history, chain, globalModels, avgVol)
+	spreadWithProb.MeetsRoR = true
+	resultChan <- spreadWithProb
+}
 
-		if returnOnRisk >= minReturnOnRisk {
-			spreadWithProb := probability.MonteCarloSimulation(spread, j.underlyingPrice, j.riskFreeRate, j.daysToExpiration, j.yzVolatilities, j.rsVolatilities, j.localVolSurface, history, chain, globalModels, avgVol)
-			spreadWithProb.MeetsRoR = true
-			resultChan <- spreadWithProb
-		} else {
-			resultChan <- models.SpreadWithProbabilities{
-				Spread:   spread,
-				MeetsRoR: false,
-			}
+// createOptionSpread prices a candidate position at the assumed fill net of fees, and its margin. callOpt is the short call of a strangle,
+// straddle or jade lizard and empty for other positions, and longOpt is empty for single legs and strangles.
+func createOptionSpread(shortOpt, longOpt, callOpt tradier.Option, underlyingPrice, riskFreeRate float64, opts ScanOptions, legs legCache) models.OptionSpread {
+	spread := priceOptionSpread(shortOpt, longOpt, callOpt, underlyingPrice, riskFreeRate, opts, legs)
+	applyInverse(&spread, underlyingPrice)
+	applyFill(&spread, opts.Fill)
+	applyFees(&spread, opts.Fees)
+	applyMargin(&spread, underlyingPrice, riskFreeRate)
+	spread.Decay = models.SpreadDecay(spread)
+	return spread
+}
+
+// applyInverse marks inverse options' credit, paid in the coin, as converted to dollars at the underlying price.
+func applyInverse(spread *models.OptionSpread, underlyingPrice float64) {
+	if models.IsInverseOption(spread.ShortLeg.Option) {
+		spread.InversePrice = underlyingPrice
+	}
+}
+
+// applyFill adds the credit the fill assumption expects over the natural price. Spreads already adjusted
+// by recorded fills keep that adjustment instead, since it is measured from the natural price too.
+func applyFill(spread *models.OptionSpread, fill FillModel) {
+	if spread.CreditAdjustment != 0 {
+		return
+	}
+	if improvement := fill.perShare(*spread); improvement != 0 {
+		spread.FillAdjustment = improvement
+		adjustCredit(spread, improvement)
+	}
+}
+
+// applyFees deducts the opening fees from the credit, so the return on risk, breakeven and simulated P&L
+// are all net of fees.
+func applyFees(spread *models.OptionSpread, fees FeeModel) {
+	if fee := fees.perShare(*spread); fee != 0 {
+		spread.Fees = fee
+		adjustCredit(spread, -fee)
+	}
+}
+
+// adjustCredit moves the credit by amount and recomputes the ratios that depend on it.
+func adjustCredit(spread *models.OptionSpread, amount float64) {
+	spread.SpreadCredit += amount
+	spread.ExtrinsicValue += amount
+	if width := SpreadWidth(*spread); width > 0 {
+		spread.CreditWidthRatio = spread.SpreadCredit / width
+	}
+	spread.ROR = calculateReturnOnRisk(*spread)
+}
+
+// applyMargin fills in the position's Reg-T buying power reduction, return on it and approximate
+// portfolio margin. Options on futures are margined with SPAN instead, which sets all three, and the
+// return on risk of their undefined risk positions is on the SPAN requirement rather than Reg-T's.
+func applyMargin(spread *models.OptionSpread, underlyingPrice, riskFreeRate float64) {
+	if spec, ok := futures.Lookup(spread.ShortLeg.Option.Underlying); ok && models.IsFuturesOption(spread.ShortLeg.Option) {
+		requirement := margin.SPAN(*spread, underlyingPrice, calculateTimeToMaturity(spread.ShortLeg.Option.ExpirationDate), spec.ScanRange)
+		if spread.Margin > 0 && len(spread.Legs) == 0 {
+			spread.Margin = requirement + spread.SpreadCredit
+			adjustCredit(spread, 0)
+		}
+		spread.BuyingPower, spread.PortfolioMargin = requirement, requirement
+		if requirement > 0 {
+			spread.ReturnOnMargin = spread.SpreadCredit / requirement
 		}
+		return
+	}
+	spread.BuyingPower = margin.BuyingPower(*spread, underlyingPrice)
+	if spread.BuyingPower > 0 {
+		spread.ReturnOnMargin = spread.SpreadCredit / spread.BuyingPower
 	}
+	spread.PortfolioMargin = margin.Portfolio(*spread, underlyingPrice, riskFreeRate, calculateTimeToMaturity(spread.ShortLeg.Option.ExpirationDate))
 }
 
-func createOptionSpread(shortOpt, longOpt tradier.Option, underlyingPrice, riskFreeRate float64) models.OptionSpread {
-	shortLeg := createSpreadLeg(shortOpt, underlyingPrice, riskFreeRate)
-	longLeg := createSpreadLeg(longOpt, underlyingPrice, riskFreeRate)
+func priceOptionSpread(shortOpt, longOpt, callOpt tradier.Option, underlyingPrice, riskFreeRate float64, opts ScanOptions, legs legCache) models.OptionSpread {
+	if callOpt.Symbol != "" {
+		if longOpt.Symbol != "" {
+			return createJadeLizard(shortOpt, callOpt, longOpt, underlyingPrice, riskFreeRate, legs)
+		}
+		return createStrangle(shortOpt, callOpt, underlyingPrice, riskFreeRate, legs)
+	}
+	if spreadType := determineSpreadType(shortOpt, longOpt); spreadType == "Put Ratio" || spreadType == "Call Ratio" {
+		return createRatioSpread(shortOpt, longOpt, underlyingPrice, riskFreeRate, legs)
+	}
+
+	shortLeg := legs.leg(shortOpt, underlyingPrice, riskFreeRate)
+	longLeg := models.SpreadLeg{} // Single legs have no long leg to price
+	if longOpt.Symbol != "" {
+		longLeg = legs.leg(longOpt, underlyingPrice, riskFreeRate)
+	}
 
 	spreadType := determineSpreadType(shortOpt, longOpt)
+	stockPrice := 0.0
+	if spreadType == "Covered Call" {
+		stockPrice = underlyingPrice // The shares are bought at the current price
+	}
 
 	intrinsicValue := calculateIntrinsicValue(shortLeg, longLeg, underlyingPrice, spreadType)
-	spreadCredit := shortLeg.Option.Bid - longLeg.Option.Ask
+	creditAdjustment := opts.creditAdjustment(shortOpt, longOpt)
+	spreadCredit := shortLeg.Option.Bid - longLeg.Option.Ask + creditAdjustment
 	extrinsicValue := spreadCredit - intrinsicValue
 
 	spreadBSMPrice := shortLeg.BSMResult.Price - longLeg.BSMResult.Price
 
 	greeks := calculateSpreadGreeks(shortLeg, longLeg)
+	if spreadType == "Covered Call" {
+		greeks.Delta -= 1 // The shares, in the sign convention of the legs
+	}
 
-	ror := calculateReturnOnRisk(models.OptionSpread{
+	position := models.OptionSpread{
 		ShortLeg:       shortLeg,
 		LongLeg:        longLeg,
 		SpreadType:     spreadType,
@@ -326,44 +546,81 @@ func createOptionSpread(shortOpt, longOpt tradier.Option, underlyingPrice, riskF
 		ExtrinsicValue: extrinsicValue,
 		IntrinsicValue: intrinsicValue,
 		Greeks:         greeks,
-	})
+		StockPrice:     stockPrice,
+	}
+
+	creditWidthRatio := 0.0
+	if width := SpreadWidth(position); width > 0 {
+		creditWidthRatio = spreadCredit / width
+	}
+
+	ror := calculateReturnOnRisk(position)
 
 	return models.OptionSpread{
-		ShortLeg:       shortLeg,
-		LongLeg:        longLeg,
-		SpreadType:     spreadType,
-		SpreadCredit:   spreadCredit,
-		SpreadBSMPrice: spreadBSMPrice,
-		ExtrinsicValue: extrinsicValue,
-		IntrinsicValue: intrinsicValue,
-		Greeks:         greeks,
-		ROR:            ror,
+		ShortLeg:         shortLeg,
+		LongLeg:          longLeg,
+		SpreadType:       spreadType,
+		SpreadCredit:     spreadCredit,
+		CreditAdjustment: creditAdjustment,
+		SpreadBSMPrice:   spreadBSMPrice,
+		ExtrinsicValue:   extrinsicValue,
+		IntrinsicValue:   intrinsicValue,
+		Greeks:           greeks,
+		ROR:              ror,
+		CreditWidthRatio: creditWidthRatio,
+		StockPrice:       stockPrice,
 	}
 }
 
-func calculateTotalJobs(chain map[string]*tradier.OptionChain, spreadType string) int {
-	totalJobs := 0
-	for _, expiration := range chain {
-		options := filterOptions(expiration.Options.Option, spreadType)
-		if len(options) == 0 {
-			continue
+// largestWeights returns the keys of the n largest weights, largest first.
+func largestWeights(weights map[string]float64, n int) []string {
+	keys := make([]string, 0, len(weights))
+	for key := range weights {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if weights[keys[i]] != weights[keys[j]] {
+			return weights[keys[i]] > weights[keys[j]]
 		}
+		return keys[i] < keys[j]
+	})
+	return keys[:min(n, len(keys))]
+}
 
-		for i := 0; i < len(options)-1; i++ {
-			for j := i + 1; j < len(options); j++ {
-				totalJobs++
-			}
+func isSpreadViable(spread models.SpreadWithProbabilities, minROR float64) bool {
+	return spread.Spread.ROR > minROR
+}
+
+// legCache holds the priced legs of an expiration's options by symbol, so each option's implied volatility
+// is solved once however many candidates it is a leg of. A nil cache prices every leg afresh.
+type legCache map[string]models.SpreadLeg
+
+// newLegCache prices every option of an expiration in one pass.
+func newLegCache(options []tradier.Option, underlyingPrice, riskFreeRate float64) legCache {
+	metrics := ExpirationMetrics(options, underlyingPrice, riskFreeRate)
+	cache := make(legCache, len(options))
+	for _, option := range options {
+		if option.Symbol != "" {
+			cache[option.Symbol] = spreadLeg(option, metrics[option.Symbol], underlyingPrice)
 		}
 	}
-	return totalJobs
+	return cache
 }
 
-func isSpreadViable(spread models.SpreadWithProbabilities, minROR float64) bool {
-	return spread.Spread.ROR > minROR
+// leg returns the cached leg of the option, pricing it if it is not cached.
+func (c legCache) leg(option tradier.Option, underlyingPrice, riskFreeRate float64) models.SpreadLeg {
+	if leg, ok := c[option.Symbol]; ok {
+		return leg
+	}
+	return createSpreadLeg(option, underlyingPrice, riskFreeRate)
 }
 
 func createSpreadLeg(option tradier.Option, underlyingPrice, riskFreeRate float64) models.SpreadLeg {
-	bsmResult := CalculateOptionMetrics(&option, underlyingPrice, riskFreeRate)
+	return spreadLeg(option, CalculateOptionMetrics(&option, underlyingPrice, riskFreeRate), underlyingPrice)
+}
+
+// spreadLeg builds the leg of an option priced at bsmResult.
+func spreadLeg(option tradier.Option, bsmResult BSMResult, underlyingPrice float64) models.SpreadLeg {
 	intrinsicValue := calculateSingleOptionIntrinsicValue(option, underlyingPrice)
 	extrinsicValue := math.Max(0, bsmResult.Price-intrinsicValue)
 
@@ -379,24 +636,96 @@ func createSpreadLeg(option tradier.Option, underlyingPrice, riskFreeRate float6
 }
 
 func determineSpreadType(shortOpt, longOpt tradier.Option) string {
+	if longOpt.Symbol == "" {
+		if shortOpt.OptionType == "put" {
+			return "Cash-Secured Put"
+		}
+		return "Covered Call"
+	}
+	// A short put below the long put, or a short call above the long call, is only sold as a ratio
 	if shortOpt.OptionType == "put" && longOpt.OptionType == "put" {
+		if shortOpt.Strike < longOpt.Strike {
+			return "Put Ratio"
+		}
 		return "Bull Put"
 	} else if shortOpt.OptionType == "call" && longOpt.OptionType == "call" {
+		if shortOpt.Strike > longOpt.Strike {
+			return "Call Ratio"
+		}
 		return "Bear Call"
+	} else if shortOpt.OptionType == "put" && longOpt.OptionType == "call" {
+		if shortOpt.Strike == longOpt.Strike {
+			return "Short Straddle"
+		}
+		return "Short Strangle"
 	}
 	return "Unknown"
 }
 
-func IdentifyBullPutSpreads(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, progressChan chan<- int, slackClient *slack.Client, channelID string, calibrationChan chan<- string) []models.SpreadWithProbabilities {
-	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Bull Put", progressChan, slackClient, channelID, calibrationChan)
+func IdentifyBullPutSpreads(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, opts ScanOptions, tracker *progress.Tracker, status StatusSink) []models.SpreadWithProbabilities {
+	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Bull Put", opts, tracker, status)
+}
+
+func IdentifyBearCallSpreads(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, opts ScanOptions, tracker *progress.Tracker, status StatusSink) []models.SpreadWithProbabilities {
+	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Bear Call", opts, tracker, status)
+}
+
+// IdentifyBothSpreads identifies bull put and bear call spreads in one scan, sharing the calibration.
+func IdentifyBothSpreads(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, opts ScanOptions, tracker *progress.Tracker, status StatusSink) []models.SpreadWithProbabilities {
+	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Both", opts, tracker, status)
+}
+
+// IdentifyCashSecuredPuts identifies short puts secured by cash for assignment at the strike.
+func IdentifyCashSecuredPuts(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, opts ScanOptions, tracker *progress.Tracker, status StatusSink) []models.SpreadWithProbabilities {
+	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Cash-Secured Put", opts, tracker, status)
+}
+
+// IdentifyCoveredCalls identifies short calls written against 100 shares bought at the underlying price.
+func IdentifyCoveredCalls(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, opts ScanOptions, tracker *progress.Tracker, status StatusSink) []models.SpreadWithProbabilities {
+	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Covered Call", opts, tracker, status)
 }
 
-func IdentifyBearCallSpreads(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, progressChan chan<- int, slackClient *slack.Client, channelID string, calibrationChan chan<- string) []models.SpreadWithProbabilities {
-	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Bear Call", progressChan, slackClient, channelID, calibrationChan)
+// IdentifyShortStrangles identifies short out-of-the-money put and call pairs with undefined risk.
+func IdentifyShortStrangles(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, opts ScanOptions, tracker *progress.Tracker, status StatusSink) []models.SpreadWithProbabilities {
+	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Short Strangle", opts, tracker, status)
+}
+
+// IdentifyShortStraddles identifies a short put and call at the same strike with undefined risk.
+func IdentifyShortStraddles(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, opts ScanOptions, tracker *progress.Tracker, status StatusSink) []models.SpreadWithProbabilities {
+	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Short Straddle", opts, tracker, status)
+}
+
+// IdentifyPutRatioSpreads identifies 1x2 put ratio spreads, buying one put and selling two further below.
+func IdentifyPutRatioSpreads(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, opts ScanOptions, tracker *progress.Tracker, status StatusSink) []models.SpreadWithProbabilities {
+	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Put Ratio", opts, tracker, status)
+}
+
+// IdentifyCallRatioSpreads identifies 1x2 call ratio spreads, buying one call and selling two further above.
+func IdentifyCallRatioSpreads(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, opts ScanOptions, tracker *progress.Tracker, status StatusSink) []models.SpreadWithProbabilities {
+	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Call Ratio", opts, tracker, status)
+}
+
+// IdentifyJadeLizards identifies short puts combined with a bear call spread whose credit covers its width.
+func IdentifyJadeLizards(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, minReturnOnRisk float64, currentDate time.Time, opts ScanOptions, tracker *progress.Tracker, status StatusSink) []models.SpreadWithProbabilities {
+	return IdentifySpreads(chain, underlyingPrice, riskFreeRate, history, minReturnOnRisk, currentDate, "Jade Lizard", opts, tracker, status)
+}
+
+// spreadSides expands the spread type "Both" into the bull put and bear call sides.
+func spreadSides(spreadType string) []string {
+	if spreadType == "Both" {
+		return []string{"Bull Put", "Bear Call"}
+	}
+	return []string{spreadType}
 }
 
 func filterOptions(options []tradier.Option, spreadType string) []tradier.Option {
-	if spreadType == "Bull Put" {
+	if _, ok := LookupStrategy(spreadType); ok {
+		return options
+	}
+	switch spreadType {
+	case "Short Strangle", "Short Straddle", "Jade Lizard":
+		return options
+	case "Bull Put", "Cash-Secured Put", "Put Ratio":
 		return filterPutOptions(options)
 	}
 	return filterCallOptions(options)
@@ -424,14 +753,21 @@ func sanitizeBSMResult(result BSMResult) models.BSMResult {
 		ShadowUpGamma:     sanitizeFloat(result.ShadowUpGamma),
 		ShadowDownGamma:   sanitizeFloat(result.ShadowDownGamma),
 		SkewGamma:         sanitizeFloat(result.SkewGamma),
+		IVError:           result.IVError,
 	}
 }
 
+// calculateReturnOnRisk divides the credit by the most the position can lose. For a single leg that is
+// its collateral less the credit, the loss if the underlying goes to zero, and for a position with
+// undefined risk the buying power it takes, its margin less the credit.
 func calculateReturnOnRisk(spread models.OptionSpread) float64 {
 	var maxRisk float64
-	if spread.SpreadType == "Bull Put" {
+	switch {
+	case models.IsSingleLeg(spread) || spread.Margin > 0:
+		maxRisk = SpreadWidth(spread) - spread.SpreadCredit
+	case spread.SpreadType == "Bull Put":
 		maxRisk = spread.ShortLeg.Option.Strike - spread.LongLeg.Option.Strike - spread.SpreadCredit
-	} else { // Bear Call Spread
+	default: // Bear Call Spread
 		maxRisk = spread.LongLeg.Option.Strike - spread.ShortLeg.Option.Strike - spread.SpreadCredit
 	}
 
@@ -445,6 +781,17 @@ func calculateReturnOnRisk(spread models.OptionSpread) float64 {
 	return returnOnRisk
 }
 
+// legsTraded reports whether every leg of a candidate traded today. Legs a position does not have are
+// empty and skipped.
+func legsTraded(legs ...tradier.Option) bool {
+	for _, leg := range legs {
+		if leg.Symbol != "" && leg.Volume == 0 {
+			return false
+		}
+	}
+	return len(legs) > 0 && legs[0].Volume > 0
+}
+
 func filterPutOptions(options []tradier.Option) []tradier.Option {
 	var puts []tradier.Option
 	for _, opt := range options {
@@ -0,0 +1,53 @@
+package positions
+
+import (
+	"log"
+	"sync"
+)
+
+// StatusSink receives the status messages of a scan, such as the steps of the model calibration. Status
+// may be called from several of the scan's goroutines at once and should not block.
+type StatusSink interface {
+	Status(message string)
+}
+
+// StatusFunc adapts a function to a StatusSink.
+type StatusFunc func(message string)
+
+func (f StatusFunc) Status(message string) {
+	f(message)
+}
+
+// QueueStatus returns a StatusSink that hands messages to deliver in order on a goroutine of its own,
+// for slow destinations such as chat messages. Messages are dropped rather than blocking the scan when
+// more than size are waiting. The returned function stops the sink once the queued messages are delivered.
+func QueueStatus(deliver func(message string), size int) (StatusSink, func()) {
+	queue := make(chan string, size)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for message := range queue {
+			deliver(message)
+		}
+	}()
+
+	sink := StatusFunc(func(message string) {
+		select {
+		case queue <- message:
+		default:
+			log.Printf("Dropped status message, too many waiting: %s", message)
+		}
+	})
+	return sink, func() {
+		close(queue)
+		wg.Wait()
+	}
+}
+
+// reportStatus sends message to status, which may be nil.
+func reportStatus(status StatusSink, message string) {
+	if status != nil {
+		status.Status(message)
+	}
+}
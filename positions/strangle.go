@@ -0,0 +1,86 @@
+package positions
+
+import (
+	"math"
+
+	"github.com/bcdannyboy/stocd/margin"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// createStrangle prices a short put and a short call of the same expiration as one position. The put is
+// held in ShortLeg and the call in CallLeg; there is no long leg.
+func createStrangle(putOpt, callOpt tradier.Option, underlyingPrice, riskFreeRate float64, legs legCache) models.OptionSpread {
+	putLeg := legs.leg(putOpt, underlyingPrice, riskFreeRate)
+	callLeg := legs.leg(callOpt, underlyingPrice, riskFreeRate)
+
+	spread := models.OptionSpread{
+		ShortLeg:       putLeg,
+		CallLeg:        callLeg,
+		SpreadType:     determineSpreadType(putOpt, callOpt),
+		SpreadCredit:   putOpt.Bid + callOpt.Bid,
+		SpreadBSMPrice: putLeg.BSMResult.Price + callLeg.BSMResult.Price,
+		IntrinsicValue: putLeg.IntrinsicValue + callLeg.IntrinsicValue,
+		Greeks:         combineGreeks(scaledLeg{putLeg, 1}, scaledLeg{callLeg, 1}),
+		Margin:         margin.Strangle(putOpt, callOpt, underlyingPrice),
+	}
+	spread.ExtrinsicValue = spread.SpreadCredit - spread.IntrinsicValue
+
+	spread.ExpectedMove = expectedMove(underlyingPrice, putLeg, callLeg)
+
+	if margin := SpreadWidth(spread); margin > 0 {
+		spread.CreditWidthRatio = spread.SpreadCredit / margin
+	}
+	spread.ROR = calculateReturnOnRisk(spread)
+	return spread
+}
+
+// expectedMove is the one standard deviation move of the underlying to the legs' expiration at their
+// average implied volatility.
+func expectedMove(underlyingPrice float64, legs ...models.SpreadLeg) float64 {
+	vol := 0.0
+	for _, leg := range legs {
+		vol += legImpliedVolatility(leg)
+	}
+	vol /= float64(len(legs))
+	return underlyingPrice * vol * math.Sqrt(calculateTimeToMaturity(legs[0].Option.ExpirationDate))
+}
+
+// legImpliedVolatility prefers the leg's BSM implied volatility and falls back to the quoted mid IV.
+func legImpliedVolatility(leg models.SpreadLeg) float64 {
+	if leg.BSMResult.ImpliedVolatility > 0 {
+		return leg.BSMResult.ImpliedVolatility
+	}
+	return leg.Option.Greeks.MidIv
+}
+
+// candidateStrangles pairs the puts and calls of one expiration: equal strikes for straddles, and a put
+// below the call within the width constraints for strangles. The put is the short leg and the call the
+// third leg, as in candidateLegs.
+func candidateStrangles(options []tradier.Option, spreadType string, opts ScanOptions) [][3]tradier.Option {
+	var puts, calls []tradier.Option
+	for _, option := range options {
+		if option.OptionType == "put" {
+			puts = append(puts, option)
+		} else {
+			calls = append(calls, option)
+		}
+	}
+	index := strikeIndex(options)
+
+	var legs [][3]tradier.Option
+	for _, put := range puts {
+		for _, call := range calls {
+			if spreadType == "Short Straddle" {
+				if call.Strike == put.Strike {
+					legs = append(legs, [3]tradier.Option{put, {}, call})
+				}
+				continue
+			}
+			if call.Strike > put.Strike && opts.allowsWidth(call.Strike-put.Strike, index[call.Strike]-index[put.Strike]) {
+				legs = append(legs, [3]tradier.Option{put, {}, call})
+			}
+		}
+	}
+	return legs
+}
@@ -0,0 +1,343 @@
+package positions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// DefaultStrategies are the declared strategies available when STRATEGIES is not set.
+const DefaultStrategies = "iron_condor: short put delta=0.16, long put strikes=-2, short call delta=0.16, long call strikes=2; " +
+	"iron_butterfly: short put delta=0.5, short call offset=0, long put strikes=-2, long call strikes=2"
+
+// strategyDeltaCandidates is the number of strikes nearest a delta target tried for a leg.
+const strategyDeltaCandidates = 3
+
+// Strategy is a declared multi-leg position, priced, simulated and ranked through the same pipeline as
+// the built-in structures. Its name is the spread type of the positions it produces.
+type Strategy struct {
+	Name string
+	Legs []StrategyLeg
+}
+
+// StrategyLeg declares one leg of a strategy. A delta selector picks the strikes whose absolute delta is
+// nearest Value; offset and strikes selectors place the leg Value dollars or Value listed strikes from
+// the last earlier leg of the same option type, or from the leg just before when there is none.
+type StrategyLeg struct {
+	Short    bool
+	Type     string // "put" or "call"
+	Quantity int
+	Selector string // "delta", "offset" or "strikes"
+	Value    float64
+}
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = make(map[string]Strategy)
+)
+
+// RegisterStrategies makes declared strategies scannable under their names.
+func RegisterStrategies(declared []Strategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	for _, strategy := range declared {
+		strategies[strategy.Name] = strategy
+	}
+}
+
+// strategyIndicators maps the indicator values that select a strategy other than a vertical spread to its spread type.
+var strategyIndicators = map[string]string{
+	"csp":       "Cash-Secured Put",
+	"cc":        "Covered Call",
+	"strangle":  "Short Strangle",
+	"straddle":  "Short Straddle",
+	"putratio":  "Put Ratio",
+	"callratio": "Call Ratio",
+	"lizard":    "Jade Lizard",
+}
+
+// StrategyIndicatorNames lists the strategy indicator values, built in and declared, in a stable order.
+func StrategyIndicatorNames() []string {
+	names := make([]string, 0, len(strategyIndicators))
+	for name := range strategyIndicators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(names, StrategyNames()...)
+}
+
+// StrategyIndicator returns the spread type an indicator value selects when it names a built-in or
+// declared strategy.
+func StrategyIndicator(indicator string) (string, bool) {
+	if spreadType, ok := strategyIndicators[indicator]; ok {
+		return spreadType, true
+	}
+	if strategy, ok := LookupStrategy(indicator); ok {
+		return strategy.Name, true
+	}
+	return "", false
+}
+
+// LookupStrategy returns the registered strategy with the given name.
+func LookupStrategy(name string) (Strategy, bool) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	strategy, ok := strategies[name]
+	return strategy, ok
+}
+
+// StrategyNames returns the names of the registered strategies in sorted order.
+func StrategyNames() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseStrategies reads a "name: leg, leg; name: leg" list of strategies. Each leg is
+// "short|long [quantity] put|call delta=<abs delta>|offset=<dollars>|strikes=<count>", and the first leg
+// of a strategy must use a delta selector. An empty spec yields DefaultStrategies.
+func ParseStrategies(spec string) ([]Strategy, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = DefaultStrategies
+	}
+
+	var declared []Strategy
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, body, ok := strings.Cut(entry, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid strategy %q: expected name: leg, leg", strings.TrimSpace(entry))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("strategy %s is given more than once", name)
+		}
+
+		strategy := Strategy{Name: name}
+		for _, legSpec := range strings.Split(body, ",") {
+			leg, err := parseStrategyLeg(legSpec)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse strategy %s: %s", name, err)
+			}
+			strategy.Legs = append(strategy.Legs, leg)
+		}
+		if strategy.Legs[0].Selector != "delta" {
+			return nil, fmt.Errorf("strategy %s must choose its first leg by delta", name)
+		}
+		seen[name] = true
+		declared = append(declared, strategy)
+	}
+	return declared, nil
+}
+
+func parseStrategyLeg(spec string) (StrategyLeg, error) {
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) != 3 && len(fields) != 4 {
+		return StrategyLeg{}, fmt.Errorf("invalid leg %q: expected short|long [quantity] put|call selector=value", strings.TrimSpace(spec))
+	}
+
+	leg := StrategyLeg{Quantity: 1}
+	switch fields[0] {
+	case "short":
+		leg.Short = true
+	case "long":
+	default:
+		return StrategyLeg{}, fmt.Errorf("invalid side %q in leg %q", fields[0], strings.TrimSpace(spec))
+	}
+	if len(fields) == 4 {
+		quantity, err := strconv.Atoi(fields[1])
+		if err != nil || quantity < 1 {
+			return StrategyLeg{}, fmt.Errorf("invalid quantity %q in leg %q", fields[1], strings.TrimSpace(spec))
+		}
+		leg.Quantity = quantity
+		fields = append(fields[:1], fields[2:]...)
+	}
+	if fields[1] != "put" && fields[1] != "call" {
+		return StrategyLeg{}, fmt.Errorf("invalid option type %q in leg %q", fields[1], strings.TrimSpace(spec))
+	}
+	leg.Type = fields[1]
+
+	selector, value, ok := strings.Cut(fields[2], "=")
+	number, err := strconv.ParseFloat(value, 64)
+	if !ok || err != nil {
+		return StrategyLeg{}, fmt.Errorf("invalid selector %q in leg %q", fields[2], strings.TrimSpace(spec))
+	}
+	switch selector {
+	case "delta":
+		if number <= 0 || number >= 1 {
+			return StrategyLeg{}, fmt.Errorf("delta %v in leg %q is outside (0, 1)", number, strings.TrimSpace(spec))
+		}
+	case "offset":
+	case "strikes":
+		if number != math.Trunc(number) {
+			return StrategyLeg{}, fmt.Errorf("strikes %v in leg %q is not a whole number", number, strings.TrimSpace(spec))
+		}
+	default:
+		return StrategyLeg{}, fmt.Errorf("unknown selector %q in leg %q (known: delta, offset, strikes)", selector, strings.TrimSpace(spec))
+	}
+	leg.Selector, leg.Value = selector, number
+	return leg, nil
+}
+
+// candidates enumerates the legs of the strategy's candidate positions among one expiration's options,
+// in declaration order. Delta legs branch over the strikes nearest their target; relative legs follow
+// from the legs chosen before them.
+func (s Strategy) candidates(options []tradier.Option) [][]tradier.Option {
+	byType := map[string][]tradier.Option{"put": filterPutOptions(options), "call": filterCallOptions(options)}
+	for _, listed := range byType {
+		sort.SliceStable(listed, func(i, j int) bool {
+			return listed[i].Strike < listed[j].Strike
+		})
+	}
+
+	var results [][]tradier.Option
+	var choose func(chosen []tradier.Option)
+	choose = func(chosen []tradier.Option) {
+		if len(chosen) == len(s.Legs) {
+			results = append(results, append([]tradier.Option(nil), chosen...))
+			return
+		}
+		for _, option := range s.legChoices(len(chosen), chosen, byType) {
+			if !containsSymbol(chosen, option.Symbol) {
+				choose(append(chosen, option))
+			}
+		}
+	}
+	choose(nil)
+	return results
+}
+
+// legChoices returns the options leg i of the strategy may use given the legs chosen before it.
+func (s Strategy) legChoices(i int, chosen []tradier.Option, byType map[string][]tradier.Option) []tradier.Option {
+	leg := s.Legs[i]
+	listed := byType[leg.Type]
+	if len(listed) == 0 {
+		return nil
+	}
+
+	if leg.Selector == "delta" {
+		var priced []tradier.Option
+		for _, option := range listed {
+			if option.Greeks.Delta != 0 {
+				priced = append(priced, option)
+			}
+		}
+		sort.SliceStable(priced, func(a, b int) bool {
+			return math.Abs(math.Abs(priced[a].Greeks.Delta)-leg.Value) < math.Abs(math.Abs(priced[b].Greeks.Delta)-leg.Value)
+		})
+		return priced[:min(strategyDeltaCandidates, len(priced))]
+	}
+
+	anchor := chosen[i-1]
+	for j := i - 1; j >= 0; j-- {
+		if s.Legs[j].Type == leg.Type {
+			anchor = chosen[j]
+			break
+		}
+	}
+	nearest := nearestStrikeIndex(listed, anchor.Strike)
+	if leg.Selector == "strikes" {
+		if index := nearest + int(leg.Value); index >= 0 && index < len(listed) {
+			return []tradier.Option{listed[index]}
+		}
+		return nil
+	}
+	target := nearestStrikeIndex(listed, anchor.Strike+leg.Value)
+	if leg.Value != 0 && listed[target].Strike == listed[nearest].Strike {
+		return nil // No listed strike in the offset's direction
+	}
+	return []tradier.Option{listed[target]}
+}
+
+func nearestStrikeIndex(listed []tradier.Option, strike float64) int {
+	best := 0
+	for i, option := range listed {
+		if math.Abs(option.Strike-strike) < math.Abs(listed[best].Strike-strike) {
+			best = i
+		}
+	}
+	return best
+}
+
+func containsSymbol(options []tradier.Option, symbol string) bool {
+	for _, option := range options {
+		if option.Symbol == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// createStrategyPosition prices one candidate of a declared strategy. The first short leg is also held
+// in ShortLeg, which the volatility estimates key off, and the margin is the worst expiration loss plus
+// the credit, so the return on risk is the credit over the most the position can lose.
+func createStrategyPosition(strategy Strategy, options []tradier.Option, underlyingPrice, riskFreeRate float64, opts ScanOptions, legs legCache) models.OptionSpread {
+	spread := models.OptionSpread{SpreadType: strategy.Name}
+	var scaled []scaledLeg
+	var spreadLegs []models.SpreadLeg
+	intrinsicValue := 0.0
+	for i, option := range options {
+		leg := legs.leg(option, underlyingPrice, riskFreeRate)
+		quantity := strategy.Legs[i].Quantity
+		price := option.Bid
+		if !strategy.Legs[i].Short {
+			quantity, price = -quantity, option.Ask
+		}
+		q := float64(quantity)
+
+		spread.Legs = append(spread.Legs, models.PositionLeg{SpreadLeg: leg, Quantity: quantity})
+		spread.SpreadCredit += q * price
+		spread.SpreadBSMPrice += q * leg.BSMResult.Price
+		intrinsicValue += q * leg.IntrinsicValue
+		scaled = append(scaled, scaledLeg{leg, q})
+		spreadLegs = append(spreadLegs, leg)
+		if quantity > 0 && spread.ShortLeg.Option.Symbol == "" {
+			spread.ShortLeg = leg
+		}
+	}
+	if spread.ShortLeg.Option.Symbol == "" {
+		spread.ShortLeg = spreadLegs[0]
+	}
+
+	spread.IntrinsicValue = math.Max(0, intrinsicValue)
+	spread.ExtrinsicValue = spread.SpreadCredit - spread.IntrinsicValue
+	spread.Greeks = combineGreeks(scaled...)
+	spread.ExpectedMove = expectedMove(underlyingPrice, spreadLegs...)
+	applyInverse(&spread, underlyingPrice)
+	spread.Margin = strategyMargin(spread)
+
+	if margin := SpreadWidth(spread); margin > 0 {
+		spread.CreditWidthRatio = spread.SpreadCredit / margin
+	}
+	spread.ROR = calculateReturnOnRisk(spread)
+	applyFill(&spread, opts.Fill)
+	applyFees(&spread, opts.Fees)
+	applyMargin(&spread, underlyingPrice, riskFreeRate)
+	spread.Decay = models.SpreadDecay(spread)
+	return spread
+}
+
+// strategyMargin is the largest expiration loss of a declared strategy over underlying prices from zero
+// to twice its highest strike, plus the credit. It matches Reg-T for defined-risk structures and stands
+// in as a stress requirement for undefined ones.
+func strategyMargin(spread models.OptionSpread) float64 {
+	worst := 0.0
+	for _, price := range models.LegsPriceGrid(spread) {
+		worst = math.Min(worst, models.PayoffAtExpiration(spread, price))
+	}
+	return spread.SpreadCredit - worst
+}
@@ -0,0 +1,32 @@
+package positions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/probability"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// StrikeTermStructure calibrates the models and reports, for every expiration in the chain,
+// the simulated probability of the underlying finishing above strike.
+func StrikeTermStructure(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, strike float64, currentDate time.Time, status StatusSink) []probability.TermPoint {
+	if len(chain) == 0 {
+		fmt.Printf("Warning: Option chain is empty for strike %.2f term structure\n", strike)
+		return nil
+	}
+
+	yzVolatilities := models.CalculateYangZhangVolatility(history)
+	rsVolatilities := models.CalculateRogersSatchellVolatility(history)
+	globalModels := calibrateGlobalModels(history, chain, underlyingPrice, riskFreeRate, yzVolatilities, rsVolatilities, currentDate, status)
+
+	return probability.StrikeTermStructure(strike, underlyingPrice, riskFreeRate, chain, globalModels, currentDate)
+}
+
+// CalibrateModels calibrates the models to the price history and chain and returns them.
+func CalibrateModels(chain map[string]*tradier.OptionChain, underlyingPrice, riskFreeRate float64, history tradier.QuoteHistory, currentDate time.Time, status StatusSink) probability.GlobalModels {
+	yzVolatilities := models.CalculateYangZhangVolatility(history)
+	rsVolatilities := models.CalculateRogersSatchellVolatility(history)
+	return calibrateGlobalModels(history, chain, underlyingPrice, riskFreeRate, yzVolatilities, rsVolatilities, currentDate, status)
+}
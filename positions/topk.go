@@ -0,0 +1,54 @@
+package positions
+
+import (
+	"container/heap"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// topSpreads keeps the n best spreads added to it, evicting the worst once full, so its memory stays
+// bounded however many spreads are added. It is a min-heap: the root is the worst spread kept.
+type topSpreads struct {
+	n       int
+	better  func(a, b models.SpreadWithProbabilities) bool
+	spreads []models.SpreadWithProbabilities
+}
+
+// newTopSpreads keeps the n best spreads by better, every spread when n <= 0.
+func newTopSpreads(n int, better func(a, b models.SpreadWithProbabilities) bool) *topSpreads {
+	return &topSpreads{n: n, better: better}
+}
+
+func (t *topSpreads) Len() int           { return len(t.spreads) }
+func (t *topSpreads) Less(i, j int) bool { return t.better(t.spreads[j], t.spreads[i]) }
+func (t *topSpreads) Swap(i, j int)      { t.spreads[i], t.spreads[j] = t.spreads[j], t.spreads[i] }
+
+func (t *topSpreads) Push(x interface{}) {
+	t.spreads = append(t.spreads, x.(models.SpreadWithProbabilities))
+}
+func (t *topSpreads) Pop() interface{} {
+	last := t.spreads[len(t.spreads)-1]
+	t.spreads = t.spreads[:len(t.spreads)-1]
+	return last
+}
+
+// Add offers a spread, reporting whether it was kept.
+func (t *topSpreads) Add(spread models.SpreadWithProbabilities) bool {
+	switch {
+	case t.n <= 0:
+		t.spreads = append(t.spreads, spread)
+	case len(t.spreads) < t.n:
+		heap.Push(t, spread)
+	case t.better(spread, t.spreads[0]):
+		t.spreads[0] = spread
+		heap.Fix(t, 0)
+	default:
+		return false
+	}
+	return true
+}
+
+// Spreads returns the spreads kept, in no particular order.
+func (t *topSpreads) Spreads() []models.SpreadWithProbabilities {
+	return t.spreads
+}
@@ -6,13 +6,15 @@ import (
 )
 
 type job struct {
-	option1, option2 tradier.Option
 	underlyingPrice  float64
 	riskFreeRate     float64
 	yzVolatilities   map[string]float64
 	rsVolatilities   map[string]float64
 	localVolSurface  models.VolatilitySurface
 	daysToExpiration int
+	tau              float64             // Time to expiration in years the spread is simulated over
+	spread           models.OptionSpread // The candidate priced when the job was generated
+	analyticPoP      float64             // Closed-form probability of profit, gating the simulation
 }
 type BSMResult struct {
 	Price             float64
@@ -25,6 +27,7 @@ type BSMResult struct {
 	ShadowUpGamma     float64
 	ShadowDownGamma   float64
 	SkewGamma         float64
+	IVError           string // Why no volatility reproduces the option's mid price, empty when ImpliedVolatility was solved
 }
 
 type ParkinsonsResult struct {
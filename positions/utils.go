@@ -2,15 +2,16 @@ package positions
 
 import (
 	"math"
-	"sort"
-	"strconv"
-	"time"
 
+	"github.com/bcdannyboy/stocd/market"
 	"github.com/bcdannyboy/stocd/models"
 	"github.com/bcdannyboy/stocd/tradier"
 )
 
 func calculateIntrinsicValue(shortLeg, longLeg models.SpreadLeg, underlyingPrice float64, spreadType string) float64 {
+	if spreadType == "Cash-Secured Put" || spreadType == "Covered Call" {
+		return shortLeg.IntrinsicValue
+	}
 	if spreadType == "Bull Put" {
 		return math.Max(0, shortLeg.Option.Strike-longLeg.Option.Strike-(shortLeg.Option.Strike-underlyingPrice))
 	} else { // Bear Call
@@ -33,9 +34,8 @@ func sanitizeFloat(f float64) float64 {
 }
 
 func calculateTimeToMaturity(expirationDate string) float64 {
-	expDate, _ := time.Parse("2006-01-02", expirationDate)
-	now := time.Now()
-	return expDate.Sub(now).Hours() / 24 / 365 // Convert to years
+	years, _ := market.YearsToExpiration(expirationDate, market.Now())
+	return years
 }
 
 func calculateAverageVolatility(volatilities map[string]float64) float64 {
@@ -68,54 +68,46 @@ func calculateAverageImpliedVolatility(chain map[string]*tradier.OptionChain) fl
 	return sum / float64(count)
 }
 
-func calculateHistoricalJumps(history tradier.QuoteHistory) []float64 {
-	jumps := []float64{}
-	for i := 1; i < len(history.History.Day); i++ {
-		prevClose := history.History.Day[i-1].Close
-		currOpen := history.History.Day[i].Open
-		jump := math.Log(currOpen / prevClose)
-		jumps = append(jumps, jump)
+// calculateHistoricalJumps returns the daily log returns of the history that models.DetectJumps detects as
+// jumps, along with the jump test of all the returns and the jumps' rate per year.
+func calculateHistoricalJumps(history tradier.QuoteHistory) ([]float64, models.JumpTest, float64) {
+	returns := logReturns(extractHistoricalPrices(history))
+	if len(returns) == 0 {
+		return nil, models.JumpTest{}, 0
 	}
-	return jumps
-}
 
-func extractHistoricalPrices(history tradier.QuoteHistory) []float64 {
-	prices := make([]float64, len(history.History.Day))
-	for i, day := range history.History.Day {
-		prices[i] = day.Close
+	var jumps []float64
+	for _, jump := range models.DetectJumps(returns) {
+		jumps = append(jumps, jump.Return)
 	}
-	return prices
+	return jumps, models.TestJumps(returns), float64(len(jumps)) / float64(len(returns)) * 252
 }
 
-func extractAllStrikes(chain map[string]*tradier.OptionChain) []float64 {
-	strikeSet := make(map[float64]struct{})
-
-	for _, expiration := range chain {
-		for _, option := range expiration.Options.Option {
-			strikeSet[option.Strike] = struct{}{}
+// logReturns returns the log returns between consecutive positive prices.
+func logReturns(prices []float64) []float64 {
+	var returns []float64
+	for i := 1; i < len(prices); i++ {
+		if prices[i-1] > 0 && prices[i] > 0 {
+			returns = append(returns, math.Log(prices[i]/prices[i-1]))
 		}
 	}
+	return returns
+}
 
-	strikes := make([]float64, 0, len(strikeSet))
-	for strike := range strikeSet {
-		strikes = append(strikes, strike)
+func extractHistoricalPrices(history tradier.QuoteHistory) []float64 {
+	prices := make([]float64, len(history.History.Day))
+	for i, day := range history.History.Day {
+		prices[i] = day.Close
 	}
-
-	sort.Float64s(strikes)
-
-	return strikes
+	return prices
 }
 
 func extractOptionPrices(chain map[string]*tradier.OptionChain) []float64 {
 	var prices []float64
 	for _, expiration := range chain {
 		for _, option := range expiration.Options.Option {
-			if last, ok := option.Last.(float64); ok && last > 0 {
-				prices = append(prices, last)
-			} else if lastStr, ok := option.Last.(string); ok {
-				if lastFloat, err := strconv.ParseFloat(lastStr, 64); err == nil && lastFloat > 0 {
-					prices = append(prices, lastFloat)
-				}
+			if option.Last > 0 {
+				prices = append(prices, float64(option.Last))
 			}
 		}
 	}
@@ -0,0 +1,120 @@
+package positions
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// WidthGroup collects the spreads sharing the same strike width.
+type WidthGroup struct {
+	Width   float64
+	Spreads []models.SpreadWithProbabilities
+}
+
+// SpreadWidth returns the distance between the strikes of a spread in dollars. For a single leg it is
+// the collateral per share instead: the strike of a cash-secured put or the stock price of a covered call,
+// and for a position with undefined risk its estimated margin.
+func SpreadWidth(spread models.OptionSpread) float64 {
+	switch spread.SpreadType {
+	case "Cash-Secured Put":
+		return spread.ShortLeg.Option.Strike
+	case "Covered Call":
+		return math.Round(spread.StockPrice*100) / 100
+	}
+	if spread.Margin > 0 {
+		return math.Round(spread.Margin*100) / 100
+	}
+	return math.Round(math.Abs(spread.ShortLeg.Option.Strike-spread.LongLeg.Option.Strike)*100) / 100
+}
+
+// GroupSpreadsByWidth buckets spreads by strike width, ordered from narrowest to widest.
+// Spreads keep their relative order within each group.
+func GroupSpreadsByWidth(spreads []models.SpreadWithProbabilities) []WidthGroup {
+	index := make(map[float64]int)
+	var groups []WidthGroup
+
+	for _, spread := range spreads {
+		width := SpreadWidth(spread.Spread)
+		i, ok := index[width]
+		if !ok {
+			i = len(groups)
+			index[width] = i
+			groups = append(groups, WidthGroup{Width: width})
+		}
+		groups[i].Spreads = append(groups[i].Spreads, spread)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Width < groups[j].Width
+	})
+
+	return groups
+}
+
+// candidateLegs enumerates the (short, long, call) legs of the candidate positions of one expiration that
+// satisfy the width constraints. The call is the short call of a strangle, straddle or jade lizard; legs a
+// position does not have are empty.
+func candidateLegs(options []tradier.Option, spreadType string, opts ScanOptions) [][3]tradier.Option {
+	switch spreadType {
+	case "Cash-Secured Put", "Covered Call":
+		var legs [][3]tradier.Option
+		for _, option := range options {
+			// Index and coin options deliver cash or coin, so there are no shares to cover a call with
+			if spreadType == "Covered Call" && (models.IsIndexOption(option) || models.IsInverseOption(option)) {
+				continue
+			}
+			legs = append(legs, [3]tradier.Option{option})
+		}
+		return legs
+	case "Short Strangle", "Short Straddle":
+		return candidateStrangles(options, spreadType, opts)
+	case "Jade Lizard":
+		return candidateJadeLizards(options, opts)
+	}
+
+	sorted := make([]tradier.Option, len(options))
+	copy(sorted, options)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Strike < sorted[j].Strike
+	})
+
+	index := strikeIndex(sorted)
+	var legs [][3]tradier.Option
+	for i := 0; i < len(sorted)-1; i++ {
+		for j := i + 1; j < len(sorted); j++ {
+			width := sorted[j].Strike - sorted[i].Strike
+			if !opts.allowsWidth(width, index[sorted[j].Strike]-index[sorted[i].Strike]) {
+				continue
+			}
+
+			// Bull puts and call ratios sell the higher strike, bear calls and put ratios the lower
+			if spreadType == "Bull Put" || spreadType == "Call Ratio" {
+				legs = append(legs, [3]tradier.Option{sorted[j], sorted[i]})
+			} else {
+				legs = append(legs, [3]tradier.Option{sorted[i], sorted[j]})
+			}
+		}
+	}
+
+	return legs
+}
+
+// strikeIndex numbers the distinct strikes of the options in ascending order, so the number of listed
+// strikes between two legs is the difference of their indexes whatever the duplicate listings.
+func strikeIndex(options []tradier.Option) map[float64]int {
+	strikes := make([]float64, 0, len(options))
+	for _, option := range options {
+		strikes = append(strikes, option.Strike)
+	}
+	sort.Float64s(strikes)
+	index := make(map[float64]int, len(strikes))
+	for _, strike := range strikes {
+		if _, ok := index[strike]; !ok {
+			index[strike] = len(index)
+		}
+	}
+	return index
+}
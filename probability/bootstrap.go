@@ -0,0 +1,82 @@
+package probability
+
+import (
+	"math"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+	"golang.org/x/exp/rand"
+)
+
+// BootstrapModel names the historical simulation, which resamples the price history instead of simulating
+// a model, and joins the simulations when the ensemble includes it.
+const BootstrapModel = "Bootstrap"
+
+const (
+	bootstrapBlock      = 5     // Consecutive daily returns drawn together, keeping volatility clustering within a week
+	bootstrapPaths      = 10000 // Terminal prices drawn per spread
+	minBootstrapReturns = 252   // Daily returns of history needed to bootstrap
+	bootstrapDivergence = 0.15  // Gap from the ensemble's probability of profit beyond which the bootstrap's is logged
+)
+
+// bootstrapPrices draws terminal prices tau years out, at 252 trading days a year, by stitching together
+// blocks of bootstrapBlock consecutive daily log returns of the history, each starting at a random day (a
+// moving block bootstrap). The returns are demeaned so the history's drift does not carry into the
+// forecast. Less than a day out, a single return is drawn and scaled by the square root of the fraction of
+// the day. It returns nil with fewer than minBootstrapReturns returns.
+func bootstrapPrices(history tradier.QuoteHistory, underlyingPrice, tau float64, rng *rand.Rand) []float64 {
+	var returns []float64
+	for i := 1; i < len(history.History.Day); i++ {
+		prevClose, currClose := history.History.Day[i-1].Close, history.History.Day[i].Close
+		if prevClose > 0 && currClose > 0 {
+			returns = append(returns, math.Log(currClose/prevClose))
+		}
+	}
+	if len(returns) < minBootstrapReturns {
+		return nil
+	}
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	steps, scale := int(math.Round(tau*market.TradingDaysPerYear)), 1.0
+	if steps < 1 {
+		steps, scale = 1, math.Sqrt(math.Max(tau*market.TradingDaysPerYear, 0))
+	}
+	prices := make([]float64, bootstrapPaths)
+	for i := range prices {
+		logReturn := 0.0
+		for drawn := 0; drawn < steps; {
+			start := rng.Intn(len(returns) - bootstrapBlock + 1)
+			for j := start; j < start+bootstrapBlock && drawn < steps; j++ {
+				logReturn += returns[j] - mean
+				drawn++
+			}
+		}
+		prices[i] = underlyingPrice * math.Exp(logReturn*scale)
+	}
+	return prices
+}
+
+// bootstrapProbability is the share of the bootstrapped terminal prices at which the spread is profitable,
+// with its standard error. It is not ok when the history is too short.
+func bootstrapProbability(spread models.OptionSpread, history tradier.QuoteHistory, underlyingPrice, tau float64) (float64, float64, bool) {
+	rng := rngPool.Get().(*rand.Rand)
+	defer rngPool.Put(rng)
+	prices := bootstrapPrices(history, underlyingPrice, tau, rng)
+	if prices == nil {
+		return 0, 0, false
+	}
+
+	profitable := 0
+	for _, price := range prices {
+		if models.IsProfitable(spread, price) {
+			profitable++
+		}
+	}
+	probability := float64(profitable) / float64(len(prices))
+	return probability, math.Sqrt(probability * (1 - probability) / float64(len(prices))), true
+}
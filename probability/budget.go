@@ -0,0 +1,70 @@
+package probability
+
+import (
+	"runtime"
+	"sync"
+)
+
+// MaxSimulationBudget bounds the simulations run concurrently across every spread and scan.
+const MaxSimulationBudget = 1024
+
+// simulationBudget caps the simulations running at once in the process, however many spread workers and
+// simulations per spread there are, so nested parallelism does not oversubscribe the CPUs.
+var simulationBudget = newBudget(runtime.NumCPU())
+
+// budget is a counting semaphore whose capacity can change while it is held.
+type budget struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	size int
+	used int
+}
+
+func newBudget(size int) *budget {
+	b := &budget{size: size}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire blocks until a unit of the budget is free and takes it.
+func (b *budget) acquire() {
+	b.mu.Lock()
+	for b.used >= b.size {
+		b.cond.Wait()
+	}
+	b.used++
+	b.mu.Unlock()
+}
+
+// release returns a unit taken by acquire.
+func (b *budget) release() {
+	b.mu.Lock()
+	b.used--
+	b.mu.Unlock()
+	b.cond.Signal()
+}
+
+// resize changes the capacity. Units held beyond a smaller capacity are kept until released.
+func (b *budget) resize(size int) {
+	b.mu.Lock()
+	b.size = size
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+func (b *budget) capacity() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// SimulationBudget returns how many simulations run concurrently across every spread, the number of CPUs
+// by default.
+func SimulationBudget() int {
+	return simulationBudget.capacity()
+}
+
+// SetSimulationBudget changes how many simulations run concurrently across every spread.
+func SetSimulationBudget(n int) {
+	simulationBudget.resize(max(1, min(n, MaxSimulationBudget)))
+}
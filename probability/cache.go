@@ -0,0 +1,212 @@
+package probability
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+const (
+	DefaultSimulationCacheTTL  = 5 * time.Minute // How long a spread's simulations are reused
+	DefaultSimulationCacheSize = 10000           // Spreads whose simulations are kept
+
+	cacheVolatilityPrecision = 3 // Decimals of the volatility inputs compared, a tenth of a volatility point
+)
+
+// simulationCache reuses the simulations of a spread across scans, e.g. Slack scans a few minutes apart.
+var simulationCache = newResultCache(DefaultSimulationCacheTTL, DefaultSimulationCacheSize)
+
+// simulationKey identifies a spread's simulations by everything they depend on: the spread, by underlying,
+// expiration, strikes and credit, the market it was simulated in, the volatility inputs and models, and the
+// calibrated parameters and simulation settings. A rescan after the underlying or the credit moves by a cent,
+// or that recalibrates the models, simulates again.
+type simulationKey struct {
+	symbol       string
+	expiration   string
+	spreadType   string
+	strikes      string // Short, long and call strikes
+	credit       string // Credit to the cent, which the VaR and expected shortfall are measured from
+	market       string // Underlying price to the cent, risk-free rate and years to expiration simulated over
+	volatilities string // Name=value of each volatility input, sorted, at cacheVolatilityPrecision
+	models       string // Models simulated, in order
+	parameters   uint64 // Hash of the calibrated models and the simulation settings
+}
+
+// newSimulationKey builds the key of simulating the spread over tau years with the volatilities and models.
+func newSimulationKey(spread models.OptionSpread, underlyingPrice, riskFreeRate, tau float64, volatilities []VolType, modelNames []string, globalModels GlobalModels) simulationKey {
+	vols := make([]string, len(volatilities))
+	for i, vol := range volatilities {
+		vols[i] = vol.Name + "=" + strconv.FormatFloat(vol.Vol, 'f', cacheVolatilityPrecision, 64)
+	}
+	sort.Strings(vols)
+	return simulationKey{
+		symbol:       spread.ShortLeg.Option.Underlying,
+		expiration:   spread.ShortLeg.Option.ExpirationDate,
+		spreadType:   spread.SpreadType,
+		strikes:      fmt.Sprintf("%g/%g/%g", spread.ShortLeg.Option.Strike, spread.LongLeg.Option.Strike, spread.CallLeg.Option.Strike),
+		credit:       strconv.FormatFloat(spread.SpreadCredit, 'f', 2, 64),
+		market:       fmt.Sprintf("%.2f/%g/%g", underlyingPrice, riskFreeRate, tau),
+		volatilities: strings.Join(vols, ","),
+		models:       strings.Join(modelNames, ","),
+		parameters:   simulationParameters(globalModels),
+	}
+}
+
+// simulationParameters hashes the calibrated models of a spread's tenor and the settings the simulations
+// run with.
+func simulationParameters(globalModels GlobalModels) uint64 {
+	h := fnv.New64a()
+	for _, model := range []interface{}{globalModels.Heston, globalModels.Merton, globalModels.Kou, globalModels.CGMY, globalModels.StudentT, globalModels.SkewT} {
+		fmt.Fprintf(h, "%+v;", model)
+	}
+	fmt.Fprintf(h, "%+v;%d;%g;%v", SimulationVarianceReduction(), SimulationBackend(), TargetIntervalWidth(), RiskLevels())
+	return h.Sum64()
+}
+
+// cachedSimulation is what a spread's simulations produced: the probability of each volatility/model
+// combination and the risk measured over the simulated prices, which are not kept.
+type cachedSimulation struct {
+	probabilities  map[string]float64
+	standardErrors map[string]float64
+
+	var95, var99, es, es99 float64
+	tailRisks              []models.TailRisk
+	distribution           models.Histogram
+
+	stored time.Time
+}
+
+// SimulationCacheStats counts the lookups of the simulation cache since the process started.
+type SimulationCacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64 // Entries dropped before they expired to make room
+	Entries   int   // Spreads currently cached
+}
+
+// HitRate is the fraction of lookups that were hits, 0 without lookups.
+func (s SimulationCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Sub returns the lookups since an earlier snapshot, with the current entries.
+func (s SimulationCacheStats) Sub(earlier SimulationCacheStats) SimulationCacheStats {
+	return SimulationCacheStats{Hits: s.Hits - earlier.Hits, Misses: s.Misses - earlier.Misses, Evictions: s.Evictions - earlier.Evictions, Entries: s.Entries}
+}
+
+// String describes the stats, e.g. "12 hits, 188 misses (6.0% hit rate), 350 cached".
+func (s SimulationCacheStats) String() string {
+	return fmt.Sprintf("%d hits, %d misses (%.1f%% hit rate), %d cached", s.Hits, s.Misses, s.HitRate()*100, s.Entries)
+}
+
+// resultCache is a map of simulations that expire after a TTL, holding at most size of them.
+type resultCache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[simulationKey]cachedSimulation
+
+	hits, misses, evictions atomic.Int64
+}
+
+func newResultCache(ttl time.Duration, size int) *resultCache {
+	return &resultCache{ttl: ttl, size: max(size, 1), entries: make(map[simulationKey]cachedSimulation)}
+}
+
+// get returns the unexpired simulations of the key.
+func (c *resultCache) get(key simulationKey) (cachedSimulation, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && time.Since(entry.stored) > c.ttl {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if ok {
+		c.hits.Add(1)
+		probabilityCacheRequests.Inc("hit")
+	} else {
+		c.misses.Add(1)
+		probabilityCacheRequests.Inc("miss")
+	}
+	return entry, ok
+}
+
+// set stores the simulations of the key. When the cache is full, expired entries are dropped, then the
+// oldest.
+func (c *resultCache) set(key simulationKey, entry cachedSimulation) {
+	entry.stored = time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		var oldest simulationKey
+		var oldestStored time.Time
+		for k, e := range c.entries {
+			if time.Since(e.stored) > c.ttl {
+				delete(c.entries, k)
+				continue
+			}
+			if oldestStored.IsZero() || e.stored.Before(oldestStored) {
+				oldest, oldestStored = k, e.stored
+			}
+		}
+		if len(c.entries) >= c.size {
+			delete(c.entries, oldest)
+			c.evictions.Add(1)
+		}
+	}
+	c.entries[key] = entry
+}
+
+// configure changes the TTL and size, forgetting the simulations cached.
+func (c *resultCache) configure(ttl time.Duration, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl, c.size = ttl, max(size, 1)
+	c.entries = make(map[simulationKey]cachedSimulation)
+}
+
+func (c *resultCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[simulationKey]cachedSimulation)
+}
+
+func (c *resultCache) stats() SimulationCacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	return SimulationCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Evictions: c.evictions.Load(), Entries: entries}
+}
+
+// SetSimulationCache reuses a spread's simulations for ttl, 0 to simulate every spread, keeping those of at
+// most size spreads.
+func SetSimulationCache(ttl time.Duration, size int) {
+	simulationCache.configure(ttl, size)
+}
+
+// CacheStats returns the simulation cache's lookups since the process started.
+func CacheStats() SimulationCacheStats {
+	return simulationCache.stats()
+}
+
+// ClearProbabilityCache forgets the cached simulations, so the next spreads are simulated again.
+func ClearProbabilityCache() {
+	simulationCache.clear()
+}
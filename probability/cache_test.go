@@ -0,0 +1,64 @@
+package probability
+
+import (
+	"testing"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+func TestSimulationKeyCoversMarketAndModels(t *testing.T) {
+	var spread models.OptionSpread
+	spread.SpreadType = "Bull Put"
+	spread.ShortLeg.Option.Underlying = "SPY"
+	spread.ShortLeg.Option.ExpirationDate = "2026-01-16"
+	spread.ShortLeg.Option.Strike, spread.LongLeg.Option.Strike = 500, 495
+	spread.SpreadCredit = 1.25
+	volatilities := []VolType{{Name: "ShortLegVol", Vol: 0.2}}
+	modelNames := []string{"Kou_Heston"}
+	globalModels := GlobalModels{Heston: &models.HestonModel{V0: 0.04, Kappa: 2, Theta: 0.04, Xi: 0.3, Rho: -0.7}}
+	key := func(spread models.OptionSpread, price, rate, tau float64, g GlobalModels) simulationKey {
+		return newSimulationKey(spread, price, rate, tau, volatilities, modelNames, g)
+	}
+	base := key(spread, 510, 0.04, 0.1, globalModels)
+
+	if same := key(spread, 510.001, 0.04, 0.1, globalModels); same != base {
+		t.Errorf("a move of a tenth of a cent changed the key")
+	}
+	moved := spread
+	moved.SpreadCredit = 1.40
+	recalibrated := globalModels
+	recalibrated.Heston = &models.HestonModel{V0: 0.05, Kappa: 2, Theta: 0.04, Xi: 0.3, Rho: -0.7}
+	for name, changed := range map[string]simulationKey{
+		"underlying price": key(spread, 512, 0.04, 0.1, globalModels),
+		"credit":           key(moved, 510, 0.04, 0.1, globalModels),
+		"risk-free rate":   key(spread, 510, 0.05, 0.1, globalModels),
+		"time to expiry":   key(spread, 510, 0.04, 0.09, globalModels),
+		"models":           key(spread, 510, 0.04, 0.1, recalibrated),
+	} {
+		if changed == base {
+			t.Errorf("changing the %s kept the key", name)
+		}
+	}
+}
+
+func TestResultCacheExpiresAndEvicts(t *testing.T) {
+	cache := newResultCache(DefaultSimulationCacheTTL, 1)
+	first, second := simulationKey{symbol: "A"}, simulationKey{symbol: "B"}
+	cache.set(first, cachedSimulation{var95: 1})
+	if cached, ok := cache.get(first); !ok || cached.var95 != 1 {
+		t.Fatalf("get after set = %v, %v", cached, ok)
+	}
+	cache.set(second, cachedSimulation{var95: 2})
+	if _, ok := cache.get(first); ok {
+		t.Errorf("the oldest entry was not evicted")
+	}
+	if stats := cache.stats(); stats.Evictions != 1 || stats.Entries != 1 {
+		t.Errorf("stats = %+v, want 1 eviction and 1 entry", stats)
+	}
+
+	disabled := newResultCache(0, 1)
+	disabled.set(first, cachedSimulation{})
+	if _, ok := disabled.get(first); ok {
+		t.Errorf("a cache with no TTL kept an entry")
+	}
+}
@@ -0,0 +1,170 @@
+package probability
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Ensemble selects the volatility inputs and models simulated for every spread. An empty list selects all
+// of them.
+type Ensemble struct {
+	Volatilities []string // Names of the volatility inputs, e.g. ShortLegVol or YZ_1m
+	Models       []string // Names of the models, e.g. Kou_Heston
+}
+
+// EnsemblePresets are the named ensembles: "full" simulates every volatility with every model, "balanced"
+// the term-matched, implied and averaged historical volatilities with the Merton and Kou models and the
+// market-implied probability, and "fast"
+// only the term-matched volatility with the Kou model.
+var EnsemblePresets = map[string]Ensemble{
+	"full": {},
+	"balanced": {
+		Volatilities: []string{"ShortLegVol", "LongLegVol", "ShortLeg_MidIV", "CallLeg_MidIV", "AvgYZ_RS", "TotalAvgVolSurface"},
+		Models:       []string{"Merton_Heston", "Kou_Heston", RiskNeutralModel},
+	},
+	"fast": {
+		Volatilities: []string{"ShortLegVol"},
+		Models:       []string{"Kou_Heston"},
+	},
+}
+
+// DefaultEnsemble is the preset simulated unless configured otherwise.
+const DefaultEnsemble = "full"
+
+var ensembleModels = []string{"CGMY_Heston", "Merton_Heston", "Kou_Heston", "StudentT", "SkewT", RiskNeutralModel, BootstrapModel}
+
+// RiskNeutralModel names the market-implied probability of the chain's risk-neutral density, which joins
+// the simulations when the ensemble includes it.
+const RiskNeutralModel = "RiskNeutral"
+
+var ensembleVolatilities = []string{
+	"ShortLegVol", "LongLegVol",
+	"YZ_1m", "YZ_3m", "YZ_6m", "YZ_1y", "RS_1m", "RS_3m", "RS_6m", "RS_1y",
+	"ShortLeg_AskIV", "ShortLeg_BidIV", "ShortLeg_MidIV", "ShortLeg_AvgIV",
+	"LongLeg_AskIV", "LongLeg_BidIV", "LongLeg_MidIV", "LongLeg_AvgIV",
+	"YZ_avg", "RS_avg", "AvgYZ_RS", "TotalAvgVolSurface", "HestonModelVol",
+	"CallLeg_AskIV", "CallLeg_BidIV", "CallLeg_MidIV", "Complete_AvgVol", "Intraday",
+}
+
+// ProbabilityKey names the probability of a volatility input simulated with a model in the maps of
+// models.ProbabilityResult, e.g. YZ_1m_Kou_Heston_probability.
+func ProbabilityKey(volatility, model string) string {
+	return volatility + "_" + model + "_probability"
+}
+
+// SplitProbabilityKey returns the volatility input and model a ProbabilityKey names, not ok when the key
+// is not of that form or names an unknown model.
+func SplitProbabilityKey(key string) (volatility, model string, ok bool) {
+	name, found := strings.CutSuffix(key, "_probability")
+	if !found {
+		return "", "", false
+	}
+	for _, model := range ensembleModels {
+		if volatility, found := strings.CutSuffix(name, "_"+model); found && volatility != "" {
+			return volatility, model, true
+		}
+	}
+	return "", "", false
+}
+
+var ensemble atomic.Pointer[Ensemble]
+
+func init() {
+	SetEnsemble(EnsemblePresets[DefaultEnsemble])
+}
+
+// SimulationEnsemble returns the volatility inputs and models the simulations use.
+func SimulationEnsemble() Ensemble {
+	return *ensemble.Load()
+}
+
+// SetEnsemble changes the ensemble for spreads simulated afterwards.
+func SetEnsemble(e Ensemble) {
+	ensemble.Store(&e)
+}
+
+// ParseEnsemble reads a preset name, empty for DefaultEnsemble, whose volatilities and models are replaced
+// by the comma separated lists vols and simModels when given. Models may be named without the _Heston
+// suffix, e.g. kou,merton.
+func ParseEnsemble(preset, vols, simModels string) (Ensemble, error) {
+	if strings.TrimSpace(preset) == "" {
+		preset = DefaultEnsemble
+	}
+	e, ok := EnsemblePresets[strings.ToLower(strings.TrimSpace(preset))]
+	if !ok {
+		return Ensemble{}, fmt.Errorf("unknown ensemble preset %q (known: %s)", preset, strings.Join(presetNames(), ", "))
+	}
+
+	if strings.TrimSpace(vols) != "" {
+		e.Volatilities = nil
+		for _, name := range strings.Split(vols, ",") {
+			vol, ok := lookupName(ensembleVolatilities, strings.TrimSpace(name))
+			if !ok {
+				return Ensemble{}, fmt.Errorf("unknown volatility input %q (known: %s)", strings.TrimSpace(name), strings.Join(ensembleVolatilities, ", "))
+			}
+			e.Volatilities = append(e.Volatilities, vol)
+		}
+	}
+	if strings.TrimSpace(simModels) != "" {
+		e.Models = nil
+		for _, name := range strings.Split(simModels, ",") {
+			name = strings.TrimSpace(name)
+			model, ok := lookupName(ensembleModels, name)
+			if !ok {
+				model, ok = lookupName(ensembleModels, name+"_Heston")
+			}
+			if !ok {
+				return Ensemble{}, fmt.Errorf("unknown model %q (known: %s)", name, strings.Join(ensembleModels, ", "))
+			}
+			e.Models = append(e.Models, model)
+		}
+	}
+	return e, nil
+}
+
+// selectVolatilities keeps the selected volatility inputs, falling back to the first one, the term-matched
+// volatility, when none of the selected inputs applies to the spread.
+func (e Ensemble) selectVolatilities(volatilities []VolType) []VolType {
+	if len(e.Volatilities) == 0 || len(volatilities) == 0 {
+		return volatilities
+	}
+	var kept []VolType
+	for _, vol := range volatilities {
+		if contains(e.Volatilities, vol.Name) {
+			kept = append(kept, vol)
+		}
+	}
+	if len(kept) == 0 {
+		kept = volatilities[:1]
+	}
+	return kept
+}
+
+func (e Ensemble) includesModel(name string) bool {
+	return len(e.Models) == 0 || contains(e.Models, name)
+}
+
+func lookupName(names []string, name string) (string, bool) {
+	for _, known := range names {
+		if strings.EqualFold(known, name) {
+			return known, true
+		}
+	}
+	return "", false
+}
+
+func contains(names []string, name string) bool {
+	_, ok := lookupName(names, name)
+	return ok
+}
+
+func presetNames() []string {
+	names := make([]string, 0, len(EnsemblePresets))
+	for name := range EnsemblePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,21 +1,26 @@
 package probability
 
 import (
+	"log"
+	"maps"
 	"math"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 
+	"github.com/bcdannyboy/stocd/metrics"
 	"github.com/bcdannyboy/stocd/models"
 	"github.com/bcdannyboy/stocd/tradier"
 	"golang.org/x/exp/rand"
 )
 
 const (
-	minSimulations            = 500
-	maxSimulations            = 1000
-	timeSteps                 = 252 // Assuming 252 trading days in a year
-	numWorkers                = 100
+	maxSimulations            = 1000  // Paths per batch of a volatility/model simulation
+	maxAdaptiveSimulations    = 20000 // Paths a simulation may grow to while narrowing its confidence interval
+	timeSteps                 = 252   // Assuming 252 trading days in a year
 	earlyTerminationThreshold = 0.25
+	priceHistogramBins        = 40
 )
 
 var (
@@ -25,23 +30,112 @@ var (
 		},
 	}
 
-	volatilityCache  sync.Map
-	probabilityCache sync.Map
+	probabilityCacheRequests = metrics.NewCounter("stocd_probability_cache_requests_total", "Lookups of a spread's cached simulations, by whether they were cached.", "result")
+
+	simulationConcurrency atomic.Int64
+	targetIntervalWidth   atomic.Uint64 // Bits of the float64 confidence interval width
 )
 
+// MaxSimulationConcurrency bounds the volatility/model simulations run concurrently for one spread.
+const MaxSimulationConcurrency = 64
+
+func init() {
+	simulationConcurrency.Store(int64(min(runtime.NumCPU(), MaxSimulationConcurrency)))
+}
+
+// SimulationConcurrency returns how many volatility/model simulations run concurrently per spread.
+func SimulationConcurrency() int {
+	return int(simulationConcurrency.Load())
+}
+
+// SetSimulationConcurrency changes the simulations run concurrently per spread for spreads started afterwards.
+func SetSimulationConcurrency(n int) {
+	simulationConcurrency.Store(int64(max(1, min(n, MaxSimulationConcurrency))))
+}
+
+// TargetIntervalWidth returns the 95% confidence interval width simulations add paths to reach, 0 when the
+// path count is fixed.
+func TargetIntervalWidth() float64 {
+	return math.Float64frombits(targetIntervalWidth.Load())
+}
+
+// SetTargetIntervalWidth makes simulations started afterwards add batches of paths until the 95% confidence
+// interval of their probability is at most width wide, up to 20000 paths. 0 disables it.
+func SetTargetIntervalWidth(width float64) {
+	targetIntervalWidth.Store(math.Float64bits(math.Max(0, width)))
+}
+
 type GlobalModels struct {
 	Heston *models.HestonModel
 	Merton *models.MertonJumpDiffusion
 	Kou    *models.KouJumpDiffusion
 	CGMY   *models.CGMYProcess
+
+	StudentT *models.SkewT // Student-t fitted to the daily returns, nil when too short a history to fit
+	SkewT    *models.SkewT // Hansen skew-t fitted to the daily returns, nil when too short a history to fit
+
+	IntradayVol float64 // Annualized realized volatility of intraday bars, simulated as the Intraday input when set
+
+	Tenors []TenorModels // Heston and CGMY fitted to the options of each tenor, shortest first; Heston and CGMY apply to expirations of no tenor
+
+	HestonFit models.ModelFit // Fit of Heston to option prices
+	CGMYFit   models.ModelFit // Fit of CGMY to option prices
+
+	FitErrors map[string]float64 // Calibration fit error of each model, keyed by simulation name
+	Paths     *PathCache         // Underlying paths shared by the spreads simulated with these models, nil to simulate each spread's own
+}
+
+// TenorModels are the Heston and CGMY models fitted to the options expiring between MinDays and MaxDays.
+type TenorModels struct {
+	MinDays int
+	MaxDays int
+	Heston  *models.HestonModel
+	CGMY    *models.CGMYProcess
+
+	HestonFit models.ModelFit
+	CGMYFit   models.ModelFit
+}
+
+// ForExpiration returns the models with the Heston and CGMY models, and their fits, of the tenor covering
+// daysToExpiration, or of the nearest tenor when none covers it.
+func (g GlobalModels) ForExpiration(daysToExpiration int) GlobalModels {
+	best, bestDistance := -1, 0
+	for i, tenor := range g.Tenors {
+		distance := 0
+		if daysToExpiration < tenor.MinDays {
+			distance = tenor.MinDays - daysToExpiration
+		} else if daysToExpiration > tenor.MaxDays {
+			distance = daysToExpiration - tenor.MaxDays
+		}
+		if best < 0 || distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	if best >= 0 {
+		tenor := g.Tenors[best]
+		g.Heston, g.CGMY, g.HestonFit, g.CGMYFit = tenor.Heston, tenor.CGMY, tenor.HestonFit, tenor.CGMYFit
+	}
+	return g
 }
 
-func MonteCarloSimulation(spread models.OptionSpread, underlyingPrice, riskFreeRate float64, daysToExpiration int, yangzhangVolatilities, rogerssatchelVolatilities map[string]float64, localVolSurface models.VolatilitySurface, history tradier.QuoteHistory, chain map[string]*tradier.OptionChain, globalModels GlobalModels, avgVol float64) models.SpreadWithProbabilities {
+// MonteCarloSimulation simulates the spread's probability of profit and risk. daysToExpiration picks the
+// models of the spread's tenor and weighs the volatility inputs; tau, the time to expiration in years, is
+// what the paths are simulated over, e.g. float64(daysToExpiration)/365 or, for short-dated spreads, the
+// trading days left over 252.
+func MonteCarloSimulation(spread models.OptionSpread, underlyingPrice, riskFreeRate float64, daysToExpiration int, tau float64, yangzhangVolatilities, rogerssatchelVolatilities map[string]float64, localVolSurface models.VolatilitySurface, history tradier.QuoteHistory, chain map[string]*tradier.OptionChain, globalModels GlobalModels, avgVol float64) models.SpreadWithProbabilities {
+	globalModels = globalModels.ForExpiration(daysToExpiration)
+	poorFits := PoorPricingFits(globalModels)
 	shortLegVol, longLegVol := confirmVolatilities(spread, localVolSurface, daysToExpiration, yangzhangVolatilities, rogerssatchelVolatilities)
 
-	shortLegLiquidity := calculateLiquidity(spread.ShortLeg.Option)
-	longLegLiquidity := calculateLiquidity(spread.LongLeg.Option)
-	spreadLiquidity := (shortLegLiquidity + longLegLiquidity) / 2
+	spreadLiquidity := 0.0
+	legs := models.OptionLegs(spread)
+	for _, leg := range legs {
+		spreadLiquidity += calculateLiquidity(leg)
+	}
+	spreadLiquidity /= float64(len(legs))
+	if spread.LongLeg.Option.Symbol == "" {
+		longLegVol = shortLegVol
+	}
 
 	volatilities := []VolType{
 		{Name: "ShortLegVol", Vol: shortLegVol},
@@ -57,17 +151,32 @@ func MonteCarloSimulation(spread models.OptionSpread, underlyingPrice, riskFreeR
 		{Name: "ShortLeg_AskIV", Vol: spread.ShortLeg.Option.Greeks.AskIv},
 		{Name: "ShortLeg_BidIV", Vol: spread.ShortLeg.Option.Greeks.BidIv},
 		{Name: "ShortLeg_MidIV", Vol: spread.ShortLeg.Option.Greeks.MidIv},
-		{Name: "ShortLeg_AvgIV", Vol: (spread.ShortLeg.Option.Greeks.AskIv + spread.ShortLeg.Option.Greeks.BidIv) / 2},
+		{Name: "ShortLeg_AvgIV", Vol: averageIV(spread.ShortLeg.Option)},
 		{Name: "LongLeg_AskIV", Vol: spread.LongLeg.Option.Greeks.AskIv},
 		{Name: "LongLeg_BidIV", Vol: spread.LongLeg.Option.Greeks.BidIv},
 		{Name: "LongLeg_MidIV", Vol: spread.LongLeg.Option.Greeks.MidIv},
-		{Name: "LongLeg_AvgIV", Vol: (spread.LongLeg.Option.Greeks.AskIv + spread.LongLeg.Option.Greeks.BidIv) / 2},
+		{Name: "LongLeg_AvgIV", Vol: averageIV(spread.LongLeg.Option)},
 		{Name: "YZ_avg", Vol: calculateAverage(yangzhangVolatilities)},
 		{Name: "RS_avg", Vol: calculateAverage(rogerssatchelVolatilities)},
 		{Name: "AvgYZ_RS", Vol: (calculateAverage(yangzhangVolatilities) + calculateAverage(rogerssatchelVolatilities)) / 2},
 		{Name: "TotalAvgVolSurface", Vol: avgVol},
 		{Name: "HestonModelVol", Vol: globalModels.Heston.V0},
 	}
+	if globalModels.IntradayVol > 0 {
+		volatilities = append(volatilities, VolType{Name: "Intraday", Vol: globalModels.IntradayVol})
+	}
+	if spread.LongLeg.Option.Symbol == "" {
+		volatilities = withoutLongLeg(volatilities)
+	}
+	if spread.CallLeg.Option.Symbol != "" {
+		volatilities = append(volatilities,
+			VolType{Name: "CallLeg_AskIV", Vol: spread.CallLeg.Option.Greeks.AskIv},
+			VolType{Name: "CallLeg_BidIV", Vol: spread.CallLeg.Option.Greeks.BidIv},
+			VolType{Name: "CallLeg_MidIV", Vol: spread.CallLeg.Option.Greeks.MidIv},
+		)
+	}
+
+	volatilities = plausibleVolatilities(volatilities)
 
 	totalAvg := 0.0
 	for _, vol := range volatilities {
@@ -76,76 +185,178 @@ func MonteCarloSimulation(spread models.OptionSpread, underlyingPrice, riskFreeR
 	averageVol := totalAvg / float64(len(volatilities))
 	volatilities = append(volatilities, VolType{Name: "Complete_AvgVol", Vol: averageVol})
 
-	simulationFuncs := []struct {
-		name string
-		fn   func(models.OptionSpread, float64, float64, float64, int, *rand.Rand, tradier.QuoteHistory, GlobalModels, bool) (map[string]float64, []float64)
-	}{
-		{name: "CGMY_Heston", fn: simulateCGMY},
-		{name: "Merton_Heston", fn: simulateMertonJumpDiffusion},
-		{name: "Kou_Heston", fn: simulateKouJumpDiffusion},
-	}
-
-	results := make(map[string]float64, len(volatilities)*len(simulationFuncs))
-	var wg sync.WaitGroup
-	var mu sync.Mutex
+	ensemble := SimulationEnsemble()
+	volatilities = ensemble.selectVolatilities(volatilities)
 
-	semaphore := make(chan struct{}, numWorkers)
-	var finalPrices []float64
+	type simulationFunc struct {
+		name     string
+		simulate pathModel
+	}
+	var simulationFuncs []simulationFunc
+	for _, simFunc := range []simulationFunc{
+		{name: "CGMY_Heston", simulate: simulateCGMYPaths},
+		{name: "Merton_Heston", simulate: simulateMertonPaths},
+		{name: "Kou_Heston", simulate: simulateKouPaths},
+		{name: "StudentT", simulate: simulateStudentTPaths},
+		{name: "SkewT", simulate: simulateSkewTPaths},
+	} {
+		if (simFunc.name == "StudentT" && globalModels.StudentT == nil) || (simFunc.name == "SkewT" && globalModels.SkewT == nil) {
+			continue
+		}
+		if ensemble.includesModel(simFunc.name) {
+			simulationFuncs = append(simulationFuncs, simFunc)
+		}
+	}
 
 	spreadID := spread.ShortLeg.Option.Symbol + "_" + spread.LongLeg.Option.Symbol
+	spreadTracer := tracerFor(spreadID)
 
-	for _, vol := range volatilities {
-		for _, simFunc := range simulationFuncs {
-			wg.Add(1)
-			go func(volName, simName string, volatility float64, simFunc func(models.OptionSpread, float64, float64, float64, int, *rand.Rand, tradier.QuoteHistory, GlobalModels, bool) (map[string]float64, []float64)) {
-				defer wg.Done()
-				semaphore <- struct{}{}
-				defer func() { <-semaphore }()
-
-				cacheKey := cacheKey{spreadID: spreadID, volType: volName, modelName: simName}
-				if cachedProb, ok := getCachedProbability(cacheKey); ok {
+	modelNames := make([]string, len(simulationFuncs))
+	for i, simFunc := range simulationFuncs {
+		modelNames[i] = simFunc.name
+	}
+	simulationKey := newSimulationKey(spread, underlyingPrice, riskFreeRate, tau, volatilities, modelNames, globalModels)
+	var simulated cachedSimulation
+	cached := false
+	if spreadTracer == nil { // Traced spreads are always simulated so the paths can be recorded
+		simulated, cached = simulationCache.get(simulationKey)
+	}
+	if !cached {
+		// Traced spreads simulate their own paths so every path can be recorded
+		paths := globalModels.Paths
+		if spreadTracer != nil {
+			paths = nil
+		}
+
+		simulated = cachedSimulation{
+			probabilities:  make(map[string]float64, len(volatilities)*len(simulationFuncs)),
+			standardErrors: make(map[string]float64, len(volatilities)*len(simulationFuncs)),
+		}
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		semaphore := make(chan struct{}, SimulationConcurrency())
+		var finalPrices []float64
+
+		for _, vol := range volatilities {
+			for _, simFunc := range simulationFuncs {
+				wg.Add(1)
+				go func(volName, simName string, volatility float64, simulate pathModel) {
+					defer wg.Done()
+					semaphore <- struct{}{}
+					defer func() { <-semaphore }()
+					simulationBudget.acquire()
+					defer simulationBudget.release()
+
+					rng := rngPool.Get().(*rand.Rand)
+					defer rngPool.Put(rng)
+
+					trace := spreadTracer.newPathTrace(volName, simName, volatility)
+					pathKey := pathKey{model: simName, tau: tau, underlyingPrice: underlyingPrice, riskFreeRate: riskFreeRate, volatility: volatility, useHeston: strings.HasSuffix(simName, "Heston")}
+					shared := paths
+					if !sharedVolatility(volName) {
+						shared = nil // No other spread would reuse the paths
+					}
+					probMap, prices := dynamicMonteCarloSimulation(spread, pathKey, rng, globalModels, simulate, shared, trace)
+					spreadTracer.add(trace)
+
+					key := ProbabilityKey(volName, simName)
 					mu.Lock()
-					results[volName+"_"+simName+"_probability"] = cachedProb
+					simulated.probabilities[key], simulated.standardErrors[key] = probMap["probability"], probMap["standard_error"]
+					finalPrices = append(finalPrices, prices...)
 					mu.Unlock()
-					return
-				}
-
-				rng := rngPool.Get().(*rand.Rand)
-				defer rngPool.Put(rng)
-
-				useHeston := strings.HasSuffix(simName, "Heston")
-				probMap, prices := dynamicMonteCarloSimulation(spread, underlyingPrice, riskFreeRate, volatility, daysToExpiration, rng, history, globalModels, useHeston, simFunc)
-
-				mu.Lock()
-				for key, value := range probMap {
-					results[volName+"_"+simName+"_"+key] = value
-					setCachedProbability(cacheKey, value)
-				}
-				finalPrices = append(finalPrices, prices...)
-				mu.Unlock()
-			}(vol.Name, simFunc.name, vol.Vol, simFunc.fn)
+				}(vol.Name, simFunc.name, vol.Vol, simFunc.simulate)
+			}
+		}
+
+		wg.Wait()
+		spreadTracer.flush()
+
+		simulated.var95 = calculateVaR(spread, finalPrices, 0.95)
+		simulated.var99 = calculateVaR(spread, finalPrices, 0.99)
+		simulated.es = calculateExpectedShortfall(spread, finalPrices, 0.95)
+		simulated.es99 = calculateExpectedShortfall(spread, finalPrices, 0.99)
+		simulated.tailRisks = calculateTailRisks(spread, finalPrices, RiskLevels())
+		simulated.distribution = priceHistogram(finalPrices, priceHistogramBins)
+		if spreadTracer == nil {
+			simulationCache.set(simulationKey, simulated)
 		}
 	}
 
-	wg.Wait()
+	// The bootstrap and market-implied estimates are added to copies, leaving the cached maps as simulated
+	results := maps.Clone(simulated.probabilities)
+	standardErrors := maps.Clone(simulated.standardErrors)
+	weights := make(map[string]float64, len(results))
+	for key := range results {
+		volName, simName, _ := SplitProbabilityKey(key)
+		weights[key] = simulationWeight(volName, simName, daysToExpiration, globalModels.FitErrors, poorFits)
+	}
+	var95, var99, es, es99, tailRisks := simulated.var95, simulated.var99, simulated.es, simulated.es99, simulated.tailRisks
+
+	bootstrap := 0.0
+	if ensemble.includesModel(BootstrapModel) {
+		if probability, standardError, ok := bootstrapProbability(spread, history, underlyingPrice, tau); ok {
+			bootstrap = probability
+			key := ProbabilityKey("Historical", BootstrapModel)
+			results[key], standardErrors[key] = probability, standardError
+			weights[key] = simulationWeight("Historical", BootstrapModel, daysToExpiration, globalModels.FitErrors, poorFits)
+		}
+	}
+
+	weights = normalizeWeights(weights)
+	marketImplied := 0.0
+	if ensemble.includesModel(RiskNeutralModel) {
+		if expiration, ok := chain[spread.ShortLeg.Option.ExpirationDate]; ok && expiration != nil {
+			if density, ok := models.ImpliedDensity(expiration.Options.Option, underlyingPrice, riskFreeRate, tau); ok {
+				marketImplied = density.ProbabilityOfProfit(spread)
+				key := ProbabilityKey("MarketImplied", RiskNeutralModel)
+				results[key], standardErrors[key] = marketImplied, 0
+				weights = withRiskNeutralWeight(weights, key)
+			}
+		}
+	}
+	averageProbability := calculateAverageProbability(results, weights)
+	if bootstrap > 0 && math.Abs(bootstrap-averageProbability) > bootstrapDivergence {
+		log.Printf("Warning: %s historical bootstrap probability of profit %.1f%% is far from the ensemble's %.1f%%", spreadID, bootstrap*100, averageProbability*100)
+	}
+	averageError := calculateAverageStandardError(standardErrors, weights)
+	intervals := make(map[string]models.ConfidenceInterval, len(results))
+	for key, value := range results {
+		intervals[key] = models.NewConfidenceInterval(value, standardErrors[key])
+	}
+	expectedValue, expectedProfit := calculateExpectedValue(spread, averageProbability, es)
 
-	var95 := calculateVaR(spread, finalPrices, 0.95)
-	var99 := calculateVaR(spread, finalPrices, 0.99)
-	es := calculateExpectedShortfall(spread, finalPrices, 0.95)
+	breakeven := calculateBreakeven(spread, underlyingPrice, shortLegVol, tau)
 
-	averageProbability := calculateAverageProbability(results)
+	markShortVol, markLongVol := legVolatility(spread.ShortLeg, shortLegVol), legVolatility(spread.LongLeg, longLegVol)
+	scenarios, worstScenarioLoss := replayHistoricalScenarios(spread, underlyingPrice, riskFreeRate, tau, markShortVol, markLongVol)
 
 	result := models.SpreadWithProbabilities{
-		Spread:            spread,
-		VaR95:             var95,
-		VaR99:             var99,
-		ExpectedShortfall: es,
-		Liquidity:         spreadLiquidity,
+		Spread:              spread,
+		VaR95:               var95,
+		VaR99:               var99,
+		ExpectedShortfall:   es,
+		ExpectedShortfall99: es99,
+		TailRisks:           tailRisks,
+		Liquidity:           spreadLiquidity,
+		Breakeven:           breakeven,
 		Probability: models.ProbabilityResult{
 			AverageProbability: averageProbability,
 			Probabilities:      results,
+			StandardErrors:     standardErrors,
+			Weights:            weights,
+			Intervals:          intervals,
+			StandardError:      averageError,
+			AverageInterval:    models.NewConfidenceInterval(averageProbability, averageError),
+			MarketImplied:      marketImplied,
+			Bootstrap:          bootstrap,
 		},
-		MeetsRoR: true,
+		ExpectedValue:     expectedValue,
+		ExpectedProfit:    expectedProfit,
+		MeetsRoR:          true,
+		Scenarios:         scenarios,
+		WorstScenarioLoss: worstScenarioLoss,
+		PriceDistribution: simulated.distribution,
+		PayoffCurve:       calculatePayoffCurve(spread, underlyingPrice, riskFreeRate, tau, markShortVol, markLongVol),
 	}
 
 	result.MertonParams = models.MertonParams{
@@ -175,6 +386,8 @@ func MonteCarloSimulation(spread models.OptionSpread, underlyingPrice, riskFreeR
 		M: globalModels.CGMY.Params.M,
 		Y: globalModels.CGMY.Params.Y,
 	}
+	result.ModelFits = []models.ModelFit{globalModels.HestonFit, globalModels.CGMYFit}
+	result.Dollars = models.NewDollarRisk(result)
 
 	result.VolatilityInfo = models.VolatilityInfo{
 		ShortLegVol:        shortLegVol,
@@ -198,135 +411,59 @@ func MonteCarloSimulation(spread models.OptionSpread, underlyingPrice, riskFreeR
 	return result
 }
 
-func dynamicMonteCarloSimulation(spread models.OptionSpread, underlyingPrice, riskFreeRate, volatility float64, daysToExpiration int, rng *rand.Rand, history tradier.QuoteHistory, globalModels GlobalModels, useHeston bool, simFunc func(models.OptionSpread, float64, float64, float64, int, *rand.Rand, tradier.QuoteHistory, GlobalModels, bool) (map[string]float64, []float64)) (map[string]float64, []float64) {
-	initialSimulations := minSimulations
-	probMap, prices := simFunc(spread, underlyingPrice, riskFreeRate, volatility, daysToExpiration, rng, history, globalModels, useHeston)
-
-	probability := probMap["probability"]
+// dynamicMonteCarloSimulation evaluates the spread against a batch of paths and, unless the probability is
+// below the early termination threshold, adds batches while the confidence interval is wider than
+// TargetIntervalWidth. The batches come from paths, so spreads sharing the key share them.
+func dynamicMonteCarloSimulation(spread models.OptionSpread, key pathKey, rng *rand.Rand, globalModels GlobalModels, simulate pathModel, paths *PathCache, trace *pathTrace) (map[string]float64, []float64) {
+	tau := key.tau
+	outcome := func(price float64) bool {
+		return models.IsProfitable(spread, price)
+	}
+	generate := func() pathBatch {
+		batch := simulate(key.underlyingPrice, key.riskFreeRate, key.volatility, tau, rng, globalModels, key.useHeston, trace, outcome)
+		simulatedPaths.Add(float64(len(batch.finalPrices)), key.model)
+		return batch
+	}
 
+	batch := paths.batch(key, 0, generate)
+	probability, standardError := evaluateBatch(spread, batch, key.underlyingPrice, key.riskFreeRate, key.volatility, tau)
+	prices := batch.finalPrices
+	probMap := map[string]float64{"probability": probability, "standard_error": standardError}
 	if probability <= earlyTerminationThreshold {
 		return probMap, prices
 	}
 
-	for len(prices) < maxSimulations {
-		additionalSimulations := int(math.Min(float64(maxSimulations-len(prices)), float64(initialSimulations)))
-		additionalProbMap, additionalPrices := simFunc(spread, underlyingPrice, riskFreeRate, volatility, daysToExpiration, rng, history, globalModels, useHeston)
-
-		prices = append(prices, additionalPrices...)
-		probability = (probability*float64(len(prices)-additionalSimulations) + additionalProbMap["probability"]*float64(additionalSimulations)) / float64(len(prices))
-
-		if math.Abs(probability-0.5) > 0.1 {
-			break
-		}
+	target := TargetIntervalWidth()
+	n := float64(len(prices))
+	for i := 1; target > 0 && interval(probability, standardError) > target && len(prices) < maxAdaptiveSimulations; i++ {
+		batch := paths.batch(key, i, generate)
+		additionalProbability, additionalError := evaluateBatch(spread, batch, key.underlyingPrice, key.riskFreeRate, key.volatility, tau)
+		m := float64(len(batch.finalPrices))
+
+		probability = (probability*n + additionalProbability*m) / (n + m)
+		standardError = math.Sqrt(n*n*standardError*standardError+m*m*additionalError*additionalError) / (n + m)
+		n += m
+		prices = append(prices[:len(prices):len(prices)], batch.finalPrices...)
 	}
 
-	probMap["probability"] = probability
+	probMap["probability"], probMap["standard_error"] = probability, standardError
 	return probMap, prices
 }
 
-func simulateMertonJumpDiffusion(spread models.OptionSpread, underlyingPrice, riskFreeRate, volatility float64, daysToExpiration int, rng *rand.Rand, history tradier.QuoteHistory, globalModels GlobalModels, useHeston bool) (map[string]float64, []float64) {
-	tau := float64(daysToExpiration) / 365.0
-
-	merton := *globalModels.Merton // Create a copy of the global model
-	merton.Sigma = volatility      // Use the provided volatility
-
-	profitCount := 0
-	finalPrices := make([]float64, maxSimulations)
-
-	for i := 0; i < maxSimulations; i++ {
-		var finalPrice float64
-		if useHeston {
-			volPath := simulateHestonVolPath(globalModels.Heston, volatility, tau, timeSteps, rng)
-			finalPrice = simulateMertonPriceWithHestonVol(underlyingPrice, riskFreeRate, tau, timeSteps, rng, merton, volPath)
-		} else {
-			finalPrice = merton.SimulatePrice(underlyingPrice, riskFreeRate, tau, timeSteps, rng)
-		}
-		finalPrices[i] = finalPrice
-
-		if models.IsProfitable(spread, finalPrice) {
-			profitCount++
-		}
-	}
-
-	return map[string]float64{
-		"probability": float64(profitCount) / float64(maxSimulations),
-	}, finalPrices
-}
-
-func simulateKouJumpDiffusion(spread models.OptionSpread, underlyingPrice, riskFreeRate, volatility float64, daysToExpiration int, rng *rand.Rand, history tradier.QuoteHistory, globalModels GlobalModels, useHeston bool) (map[string]float64, []float64) {
-	tau := float64(daysToExpiration) / 365.0
-
-	kou := *globalModels.Kou // Create a copy of the global model
-	kou.Sigma = volatility   // Use the provided volatility
-	kou.R = riskFreeRate     // Set the risk-free rate
-
-	profitCount := 0
-	finalPrices := make([]float64, maxSimulations)
-
-	for i := 0; i < maxSimulations; i++ {
-		var finalPrice float64
-		if useHeston {
-			volPath := simulateHestonVolPath(globalModels.Heston, volatility, tau, timeSteps, rng)
-			finalPrice = simulateKouPriceWithHestonVol(underlyingPrice, riskFreeRate, tau, timeSteps, rng, kou, volPath)
-		} else {
-			finalPrice = kou.SimulatePrice(underlyingPrice, riskFreeRate, tau, timeSteps, rng)
-		}
-		finalPrices[i] = finalPrice
-
-		if models.IsProfitable(spread, finalPrice) {
-			profitCount++
-		}
-	}
-
-	return map[string]float64{
-		"probability": float64(profitCount) / float64(maxSimulations),
-	}, finalPrices
-}
-
-func simulateCGMY(spread models.OptionSpread, underlyingPrice, riskFreeRate, volatility float64, daysToExpiration int, rng *rand.Rand, history tradier.QuoteHistory, globalModels GlobalModels, useHeston bool) (map[string]float64, []float64) {
-	tau := float64(daysToExpiration) / 365.0
-	cgmy := *globalModels.CGMY
-
-	profitCount := 0
-	finalPrices := make([]float64, maxSimulations)
-
-	for i := 0; i < maxSimulations; i++ {
-		path := cgmy.SimulatePath(tau, tau/float64(timeSteps), rng)
-		var finalPrice float64
-		if useHeston {
-			volPath := simulateHestonVolPath(globalModels.Heston, volatility, tau, timeSteps, rng)
-			finalPrice = simulateCGMYPriceWithHestonVol(underlyingPrice, riskFreeRate, tau, path, volPath)
-		} else {
-			finalPrice = underlyingPrice * math.Exp(path[len(path)-1])
-		}
-		finalPrices[i] = finalPrice
-
-		if models.IsProfitable(spread, finalPrice) {
-			profitCount++
-		}
-	}
-
-	probability := float64(profitCount) / float64(maxSimulations)
-
-	// Adjust probability for bear call spreads
-	if spread.SpreadType == "Bear Call" {
-		probability = 1 - probability
-	}
-
-	return map[string]float64{
-		"probability": probability,
-	}, finalPrices
+// interval is the width of a probability's 95% confidence interval.
+func interval(probability, standardError float64) float64 {
+	ci := models.NewConfidenceInterval(probability, standardError)
+	return ci.Upper - ci.Lower
 }
 
-func simulateHestonVolPath(heston *models.HestonModel, initialVol, T float64, steps int, rng *rand.Rand) []float64 {
+func simulateHestonVolPath(heston *models.HestonModel, initialVol, T float64, steps int, sampler *sampler) []float64 {
 	dt := T / float64(steps)
-	sqrtDt := math.Sqrt(dt)
+	dW := sampler.brownian(1, steps, dt)
 	volPath := make([]float64, steps+1)
 	volPath[0] = initialVol * initialVol // Heston model uses variance, not volatility
 
 	for i := 0; i < steps; i++ {
-		dW := rng.NormFloat64() * sqrtDt
-		volPath[i+1] = volPath[i] + heston.Kappa*(heston.Theta-volPath[i])*dt + heston.Xi*math.Sqrt(volPath[i])*dW
+		volPath[i+1] = volPath[i] + heston.Kappa*(heston.Theta-volPath[i])*dt + heston.Xi*math.Sqrt(volPath[i])*dW[i]
 		volPath[i+1] = math.Max(0, volPath[i+1]) // Ensure non-negative variance
 	}
 
@@ -338,53 +475,62 @@ func simulateHestonVolPath(heston *models.HestonModel, initialVol, T float64, st
 	return volPath
 }
 
-func simulateMertonPriceWithHestonVol(S0, r, T float64, steps int, rng *rand.Rand, merton models.MertonJumpDiffusion, volPath []float64) float64 {
+// simulateMertonPriceWithHestonVol evolves the price over the Brownian increments dW, drawing the jumps from sampler.
+func simulateMertonPriceWithHestonVol(S0, r, T float64, sampler *sampler, merton models.MertonJumpDiffusion, volPath, dW []float64, trace *pathTrace) float64 {
+	steps := len(dW)
 	dt := T / float64(steps)
 	price := S0
+	trace.step(0, 0, price, volPath[0], 0, 0)
 
 	for i := 0; i < steps; i++ {
-		dW := rng.NormFloat64() * math.Sqrt(dt)
 		jump := 0.0
-		if rng.Float64() < merton.Lambda*dt {
-			jump = rng.NormFloat64()*merton.Delta + merton.Mu
+		if sampler.Float64() < merton.Lambda*dt {
+			jump = sampler.NormFloat64()*merton.Delta + merton.Mu
 		}
-		price *= math.Exp((r-0.5*volPath[i]*volPath[i])*dt + volPath[i]*dW + jump)
+		price *= math.Exp((r-0.5*volPath[i]*volPath[i])*dt + volPath[i]*dW[i] + jump)
+		trace.step(i+1, float64(i+1)*dt, price, volPath[i], dW[i], jump)
 	}
 
 	return price
 }
 
-func simulateKouPriceWithHestonVol(S0, r, T float64, steps int, rng *rand.Rand, kou models.KouJumpDiffusion, volPath []float64) float64 {
+// simulateKouPriceWithHestonVol evolves the price over the Brownian increments dW, drawing the jumps from sampler.
+func simulateKouPriceWithHestonVol(S0, r, T float64, sampler *sampler, kou models.KouJumpDiffusion, volPath, dW []float64, trace *pathTrace) float64 {
+	steps := len(dW)
 	dt := T / float64(steps)
 	price := S0
+	trace.step(0, 0, price, volPath[0], 0, 0)
 
 	for i := 0; i < steps; i++ {
-		dW := rng.NormFloat64() * math.Sqrt(dt)
-		diffusion := math.Exp((r-0.5*volPath[i]*volPath[i])*dt + volPath[i]*dW)
+		diffusion := math.Exp((r-0.5*volPath[i]*volPath[i])*dt + volPath[i]*dW[i])
 
-		if rng.Float64() < kou.Lambda*dt {
+		if sampler.Float64() < kou.Lambda*dt {
 			var jump float64
-			if rng.Float64() < kou.P {
-				jump = math.Exp(rng.ExpFloat64() / kou.Eta1)
+			if sampler.Float64() < kou.P {
+				jump = math.Exp(sampler.ExpFloat64() / kou.Eta1)
 			} else {
-				jump = math.Exp(-rng.ExpFloat64() / kou.Eta2)
+				jump = math.Exp(-sampler.ExpFloat64() / kou.Eta2)
 			}
 			price *= diffusion * jump
+			trace.step(i+1, float64(i+1)*dt, price, volPath[i], dW[i], math.Log(jump))
 		} else {
 			price *= diffusion
+			trace.step(i+1, float64(i+1)*dt, price, volPath[i], dW[i], 0)
 		}
 	}
 
 	return price
 }
 
-func simulateCGMYPriceWithHestonVol(S0, r, T float64, cgmyPath []float64, volPath []float64) float64 {
+func simulateCGMYPriceWithHestonVol(S0, r, T float64, cgmyPath []float64, volPath []float64, trace *pathTrace) float64 {
 	steps := len(cgmyPath) - 1
 	dt := T / float64(steps)
 	price := S0
+	trace.step(0, 0, price, volPath[0], 0, 0)
 
 	for i := 0; i < steps; i++ {
 		price *= math.Exp((r-0.5*volPath[i]*volPath[i])*dt + cgmyPath[i+1] - cgmyPath[i])
+		trace.step(i+1, float64(i+1)*dt, price, volPath[i], 0, cgmyPath[i+1]-cgmyPath[i])
 	}
 
 	return price
@@ -0,0 +1,82 @@
+package probability
+
+import (
+	"testing"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+func TestModelsAgreeOnFarOutOfTheMoneyBearCall(t *testing.T) {
+	ensemble, err := ParseEnsemble("full", "ShortLeg_MidIV", "cgmy,merton,kou")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := SimulationEnsemble()
+	SetEnsemble(ensemble)
+	defer SetEnsemble(previous)
+
+	var spread models.OptionSpread
+	spread.SpreadType = "Bear Call"
+	spread.ShortLeg.Option.Symbol, spread.LongLeg.Option.Symbol = "TEST260116C00130000", "TEST260116C00135000"
+	spread.ShortLeg.Option.ExpirationDate = "2026-01-16"
+	spread.ShortLeg.Option.Strike, spread.LongLeg.Option.Strike = 130, 135
+	spread.ShortLeg.Option.OptionType, spread.LongLeg.Option.OptionType = "call", "call"
+	spread.ShortLeg.Option.Greeks.MidIv, spread.LongLeg.Option.Greeks.MidIv = 0.2, 0.2
+	spread.SpreadCredit = 0.2
+
+	globalModels := GlobalModels{
+		Heston: &models.HestonModel{V0: 0.04, Kappa: 2, Theta: 0.04, Xi: 0.3, Rho: -0.7},
+		Merton: &models.MertonJumpDiffusion{Lambda: 0.5, Mu: -0.02, Delta: 0.05},
+		Kou:    &models.KouJumpDiffusion{Lambda: 0.5, P: 0.4, Eta1: 25, Eta2: 20},
+		CGMY:   &models.CGMYProcess{Params: models.CGMYParams{C: 0.5, G: 10, M: 12, Y: 0.5}},
+	}
+	vols := map[string]float64{"1m": 0.2}
+	result := MonteCarloSimulation(spread, 100, 0.04, 30, 30.0/365, vols, vols, models.VolatilitySurface{}, tradier.QuoteHistory{}, nil, globalModels, 0.2)
+
+	for _, model := range []string{"CGMY_Heston", "Merton_Heston", "Kou_Heston"} {
+		pop, ok := result.Probability.Probabilities[ProbabilityKey("ShortLeg_MidIV", model)]
+		if !ok {
+			t.Errorf("%s was not simulated", model)
+		} else if pop < 0.9 {
+			t.Errorf("%s probability of profit of a Bear Call 30%% out of the money = %.3f, want above 0.9", model, pop)
+		}
+	}
+}
+
+func TestPathCacheKeepsSharedVolatilitiesOnly(t *testing.T) {
+	ensemble, err := ParseEnsemble("full", "ShortLeg_MidIV,YZ_1m", "kou")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := SimulationEnsemble()
+	SetEnsemble(ensemble)
+	defer SetEnsemble(previous)
+	ClearProbabilityCache()
+
+	var spread models.OptionSpread
+	spread.SpreadType = "Bull Put"
+	spread.ShortLeg.Option.Symbol, spread.LongLeg.Option.Symbol = "TEST260116P00095000", "TEST260116P00090000"
+	spread.ShortLeg.Option.Strike, spread.LongLeg.Option.Strike = 95, 90
+	spread.ShortLeg.Option.Greeks.MidIv, spread.LongLeg.Option.Greeks.MidIv = 0.27, 0.29
+	spread.SpreadCredit = 1
+
+	globalModels := GlobalModels{
+		Heston: &models.HestonModel{V0: 0.04, Kappa: 2, Theta: 0.04, Xi: 0.3, Rho: -0.7},
+		Merton: &models.MertonJumpDiffusion{},
+		Kou:    &models.KouJumpDiffusion{Lambda: 0.5, P: 0.4, Eta1: 25, Eta2: 20},
+		CGMY:   &models.CGMYProcess{Params: models.CGMYParams{C: 0.5, G: 10, M: 12, Y: 0.5}},
+		Paths:  NewPathCache(),
+	}
+	vols := map[string]float64{"1m": 0.2}
+	MonteCarloSimulation(spread, 100, 0.04, 30, 30.0/365, vols, vols, models.VolatilitySurface{}, tradier.QuoteHistory{}, nil, globalModels, 0.2)
+
+	var cached []float64
+	globalModels.Paths.entries.Range(func(key, _ interface{}) bool {
+		cached = append(cached, key.(pathKey).volatility)
+		return true
+	})
+	if len(cached) != 1 || cached[0] != 0.2 {
+		t.Errorf("cached paths of volatilities %v, want only YZ_1m's 0.2", cached)
+	}
+}
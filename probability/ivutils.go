@@ -3,19 +3,17 @@ package probability
 import (
 	"math"
 	"sort"
-	"time"
+	"strings"
 
+	"github.com/bcdannyboy/stocd/market"
 	"github.com/bcdannyboy/stocd/models"
 	"github.com/bcdannyboy/stocd/tradier"
-	"golang.org/x/exp/rand"
 )
 
 func confirmVolatilities(spread models.OptionSpread, localVolSurface models.VolatilitySurface, daysToExpiration int, gkVolatilities, parkinsonVolatilities map[string]float64) (float64, float64) {
-	shortLegExpiration, _ := time.Parse("2006-01-02", spread.ShortLeg.Option.ExpirationDate)
-	longLegExpiration, _ := time.Parse("2006-01-02", spread.LongLeg.Option.ExpirationDate)
-
-	shortTimeToExpiry := shortLegExpiration.Sub(time.Now()).Hours() / 24 / 365
-	longTimeToExpiry := longLegExpiration.Sub(time.Now()).Hours() / 24 / 365
+	now := market.Now()
+	shortTimeToExpiry, _ := market.YearsToExpiration(spread.ShortLeg.Option.ExpirationDate, now)
+	longTimeToExpiry, _ := market.YearsToExpiration(spread.LongLeg.Option.ExpirationDate, now)
 
 	shortLegVol := interpolateVolatilityFromSurface(localVolSurface, spread.ShortLeg.Option.Strike, shortTimeToExpiry)
 	longLegVol := interpolateVolatilityFromSurface(localVolSurface, spread.LongLeg.Option.Strike, longTimeToExpiry)
@@ -46,76 +44,91 @@ func incorporateOptionIVs(baseVol float64, option tradier.Option) float64 {
 	return totalVol / count
 }
 
-func calculateVolatilities(shortLegVol, longLegVol float64, daysToExpiration int, gkVolatilities, parkinsonVolatilities map[string]float64, localVolSurface models.VolatilitySurface, history tradier.QuoteHistory, spread models.OptionSpread) []VolType {
-	volatilities := []VolType{
-		{Name: "ShortLegVol", Vol: shortLegVol},
-		{Name: "LongLegVol", Vol: longLegVol},
-		{Name: "ShortLeg_BidIV", Vol: spread.ShortLeg.Option.Greeks.BidIv},
-		{Name: "ShortLeg_AskIV", Vol: spread.ShortLeg.Option.Greeks.AskIv},
-		{Name: "ShortLeg_MidIV", Vol: spread.ShortLeg.Option.Greeks.MidIv},
-		{Name: "LongLeg_BidIV", Vol: spread.LongLeg.Option.Greeks.BidIv},
-		{Name: "LongLeg_AskIV", Vol: spread.LongLeg.Option.Greeks.AskIv},
-		{Name: "LongLeg_MidIV", Vol: spread.LongLeg.Option.Greeks.MidIv},
-	}
-
-	yang_zhang := models.CalculateYangZhangVolatility(history)
-	rogers_satchell := models.CalculateRogersSatchellVolatility(history)
+// impliedOutlierRatio is the ratio to the median of a spread's volatility inputs beyond which an implied
+// volatility of its legs is left out of the ensemble.
+const impliedOutlierRatio = 3
 
-	for period, vol := range yang_zhang {
-		volatilities = append(volatilities, VolType{Name: "YangZhangIV_" + period, Vol: vol})
+// plausibleVolatilities drops the volatility inputs the spread should not be simulated at: missing (zero)
+// estimates, such as the bid implied volatility of a leg without a bid, and implied volatilities of the legs
+// more than impliedOutlierRatio times above or below the median of the inputs. The inputs are returned
+// unchanged if none would be left.
+func plausibleVolatilities(volatilities []VolType) []VolType {
+	var positive []float64
+	for _, vol := range volatilities {
+		if vol.Vol > 0 {
+			positive = append(positive, vol.Vol)
+		}
 	}
-	for period, vol := range rogers_satchell {
-		volatilities = append(volatilities, VolType{Name: "RogersSatchellIV_" + period, Vol: vol})
+	if len(positive) == 0 {
+		return volatilities
 	}
+	sort.Float64s(positive)
+	median := positive[len(positive)/2]
 
-	avgYZ := calculateAverage(yang_zhang)
-	volatilities = append(volatilities, VolType{Name: "avg_YangZhangIV", Vol: avgYZ})
-
-	avgRS := calculateAverage(rogers_satchell)
-	volatilities = append(volatilities, VolType{Name: "avg_RogersSatchellIV", Vol: avgRS})
-
-	totalVolatilitySurface := calculateTotalAverageVolatilitySurface(localVolSurface, history)
-	volatilities = append(volatilities, VolType{Name: "total_avg_volatility_surface", Vol: totalVolatilitySurface})
-
-	hestonVol := calculateHestonVolatility(spread, history)
-	volatilities = append(volatilities, VolType{Name: "HestonModelVol", Vol: hestonVol})
-
-	return volatilities
+	var kept []VolType
+	for _, vol := range volatilities {
+		implied := strings.HasSuffix(vol.Name, "IV")
+		if vol.Vol > 0 && !(implied && (vol.Vol > median*impliedOutlierRatio || vol.Vol*impliedOutlierRatio < median)) {
+			kept = append(kept, vol)
+		}
+	}
+	if len(kept) == 0 {
+		return volatilities
+	}
+	return kept
 }
 
-func interpolateVolatilityFromSurface(surface models.VolatilitySurface, strike, timeToExpiry float64) float64 {
-	return models.InterpolateVolatility(surface, strike, timeToExpiry)
+// averageIV averages the option's bid and ask implied volatilities, or returns whichever it has.
+func averageIV(option tradier.Option) float64 {
+	bid, ask := option.Greeks.BidIv, option.Greeks.AskIv
+	switch {
+	case bid > 0 && ask > 0:
+		return (bid + ask) / 2
+	case bid > 0:
+		return bid
+	}
+	return max(ask, 0)
 }
 
-func calculateTotalAverageVolatilitySurface(surface models.VolatilitySurface, history tradier.QuoteHistory) float64 {
-	totalVol := 0.0
-	count := 0
-
-	for _, volList := range surface.Vols {
-		for i, vol := range volList {
-			if vol == 0 || history.History.Day[i].Volume == 0 {
-				continue
-			}
-			totalVol += vol
-			count++
+// withoutLongLeg drops the long leg's volatilities, which single legs, strangles and straddles do not have.
+func withoutLongLeg(volatilities []VolType) []VolType {
+	kept := volatilities[:0]
+	for _, vol := range volatilities {
+		if !strings.HasPrefix(vol.Name, "LongLeg") {
+			kept = append(kept, vol)
 		}
 	}
+	return kept
+}
 
-	if count == 0 {
-		return 0
+// legVolatility prefers the leg's own implied volatility and falls back to the surface estimate.
+func legVolatility(leg models.SpreadLeg, fallback float64) float64 {
+	if leg.BSMResult.ImpliedVolatility > 0 {
+		return leg.BSMResult.ImpliedVolatility
 	}
+	return fallback
+}
 
-	return totalVol / float64(count)
+func interpolateVolatilityFromSurface(surface models.VolatilitySurface, strike, timeToExpiry float64) float64 {
+	return models.InterpolateVolatility(surface, strike, timeToExpiry)
 }
 
-func calculateAverageProbability(results map[string]float64) float64 {
+// calculateAverageProbability is the average of the simulated probabilities under the normalized weights.
+func calculateAverageProbability(results, weights map[string]float64) float64 {
 	var sum float64
-	var count int64
-	for _, value := range results {
-		sum += value
-		count++
+	for key, value := range results {
+		sum += weights[key] * value
 	}
-	return sum / float64(count)
+	return sum
+}
+
+// calculateAverageStandardError is the standard error of the weighted average of independently simulated probabilities.
+func calculateAverageStandardError(standardErrors, weights map[string]float64) float64 {
+	var sumSquares float64
+	for key, se := range standardErrors {
+		sumSquares += weights[key] * weights[key] * se * se
+	}
+	return math.Sqrt(sumSquares)
 }
 
 func calculateAverage(volatilities map[string]float64) float64 {
@@ -126,51 +139,6 @@ func calculateAverage(volatilities map[string]float64) float64 {
 	return total / float64(len(volatilities))
 }
 
-func calculateHestonVolatility(spread models.OptionSpread, history tradier.QuoteHistory) float64 {
-	// Extract necessary data for calibration
-	marketPrices := []float64{}
-	strikes := []float64{}
-	for _, day := range history.History.Day {
-		marketPrices = append(marketPrices, day.Close)
-	}
-	strikes = append(strikes, spread.ShortLeg.Option.Strike, spread.LongLeg.Option.Strike)
-
-	// Create and calibrate Heston model
-	heston := models.NewHestonModel(0.04, 2, 0.04, 0.4, -0.5) // Initial guess
-	s0 := marketPrices[len(marketPrices)-1]                   // Use last price as current price
-	r := 0.02                                                 // Risk-free rate (placeholder)
-
-	// Parse the expiration date string into a time.Time object
-	expirationDate, err := time.Parse("2006-01-02", spread.ShortLeg.Option.ExpirationDate)
-	if err != nil {
-		// Handle parsing error
-		return 0.0
-	}
-
-	t := expirationDate.Sub(time.Now()).Hours() / 24 / 365 // Time to expiration in years
-
-	err = heston.Calibrate(marketPrices, strikes, s0, r, t)
-	if err != nil {
-		// Handle calibration error
-		return 0.0
-	}
-
-	rng := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
-
-	// Simulate prices using calibrated Heston model
-	numSimulations := 1000
-	var sumSquaredReturns float64
-	for i := 0; i < numSimulations; i++ {
-		finalPrice := heston.SimulatePrice(s0, r, t, 252, rng) // 252 trading days in a year
-		logReturn := math.Log(finalPrice / s0)
-		sumSquaredReturns += logReturn * logReturn
-	}
-
-	// Calculate annualized volatility
-	hestonVol := math.Sqrt(sumSquaredReturns / float64(numSimulations) / t)
-	return hestonVol
-}
-
 func extractAllStrikes(chain map[string]*tradier.OptionChain) []float64 {
 	strikeSet := make(map[float64]struct{})
 
@@ -216,44 +184,95 @@ func calculateExpectedShortfall(spread models.OptionSpread, simulations []float6
 }
 
 func calculatePnL(spread models.OptionSpread, finalPrice float64) float64 {
-	var pnl float64
-	if spread.SpreadType == "Bull Put" {
-		pnl = math.Max(0, spread.ShortLeg.Option.Strike-finalPrice) -
-			math.Max(0, spread.LongLeg.Option.Strike-finalPrice) +
-			spread.SpreadCredit
-	} else { // Bear Call
-		pnl = math.Max(0, finalPrice-spread.ShortLeg.Option.Strike) -
-			math.Max(0, finalPrice-spread.LongLeg.Option.Strike) +
-			spread.SpreadCredit
-	}
-	return pnl
+	return models.PayoffAtExpiration(spread, finalPrice)
 }
 
-func calculateLiquidity(option tradier.Option) float64 {
-	if option.Ask == option.Bid {
-		return 1.0 // Avoid division by zero
+// priceHistogram bins simulated final prices into equal width buckets.
+func priceHistogram(prices []float64, bins int) models.Histogram {
+	if len(prices) == 0 || bins <= 0 {
+		return models.Histogram{}
 	}
-	return (option.Ask - option.Bid) / ((option.Ask + option.Bid) / 2)
-}
 
-func getCachedVolatility(key cacheKey) (float64, bool) {
-	if val, ok := volatilityCache.Load(key); ok {
-		return val.(float64), true
+	lo, hi := prices[0], prices[0]
+	for _, p := range prices {
+		lo = math.Min(lo, p)
+		hi = math.Max(hi, p)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+
+	width := (hi - lo) / float64(bins)
+	histogram := models.Histogram{Edges: make([]float64, bins+1), Counts: make([]int, bins)}
+	for i := range histogram.Edges {
+		histogram.Edges[i] = lo + float64(i)*width
+	}
+	for _, p := range prices {
+		bin := int((p - lo) / width)
+		if bin >= bins {
+			bin = bins - 1
+		}
+		histogram.Counts[bin]++
 	}
-	return 0, false
+	return histogram
 }
 
-func setCachedVolatility(key cacheKey, value float64) {
-	volatilityCache.Store(key, value)
+// calculateExpectedValue weighs the maximum profit by the probability of profit and
+// the tail-conditioned loss (expected shortfall) by the probability of loss.
+func calculateExpectedValue(spread models.OptionSpread, probabilityOfProfit, expectedShortfall float64) (float64, float64) {
+	expectedProfit := probabilityOfProfit * spread.SpreadCredit
+	expectedLoss := (1 - probabilityOfProfit) * math.Max(expectedShortfall, 0)
+	return expectedProfit - expectedLoss, expectedProfit
 }
 
-func getCachedProbability(key cacheKey) (float64, bool) {
-	if val, ok := probabilityCache.Load(key); ok {
-		return val.(float64), true
+// calculateBreakeven measures the distance from spot to breakeven in percent and in
+// standard deviations, using a volatility matched to the spread's expiration.
+func calculateBreakeven(spread models.OptionSpread, underlyingPrice, termVol, tau float64) models.BreakevenInfo {
+	breakeven := models.BreakevenPrice(spread)
+	info := models.BreakevenInfo{Price: breakeven}
+	if underlyingPrice <= 0 || breakeven <= 0 {
+		return info
 	}
-	return 0, false
+
+	direction := 1.0
+	if spread.SpreadType == "Bear Call" || spread.SpreadType == "Call Ratio" {
+		direction = -1.0
+	}
+	if len(spread.Legs) > 0 && models.PayoffAtExpiration(spread, 1.01*breakeven) < 0 {
+		direction = -1.0 // A declared strategy that profits below its breakeven
+	}
+
+	info.DistancePct = direction * (underlyingPrice - breakeven) / underlyingPrice
+
+	stdDev := termVol * math.Sqrt(tau)
+	if stdDev > 0 {
+		info.DistanceSD = direction * math.Log(underlyingPrice/breakeven) / stdDev
+	}
+
+	// A strangle or straddle is only as safe as its nearer breakeven
+	if upper := models.UpperBreakevenPrice(spread); upper > 0 {
+		info.UpperPrice = upper
+		info.DistancePct = math.Min(info.DistancePct, (upper-underlyingPrice)/underlyingPrice)
+		if stdDev > 0 {
+			info.DistanceSD = math.Min(info.DistanceSD, math.Log(upper/underlyingPrice)/stdDev)
+		}
+	}
+
+	return info
 }
 
-func setCachedProbability(key cacheKey, value float64) {
-	probabilityCache.Store(key, value)
+// calculateLiquidity is the option's relative bid-ask spread, lower being more liquid. When the option has
+// traded today it is averaged with the effective spread of its last trade, twice the trade's distance from
+// the mid price relative to it, since trades inside a wide quote show it is easier to fill than it looks.
+func calculateLiquidity(option tradier.Option) float64 {
+	if option.Ask == option.Bid {
+		return 1.0 // Avoid division by zero
+	}
+	mid := (option.Ask + option.Bid) / 2
+	quoted := (option.Ask - option.Bid) / mid
+	if option.Last <= 0 || option.Volume == 0 || mid <= 0 {
+		return quoted
+	}
+	effective := 2 * math.Abs(float64(option.Last)-mid) / mid
+	return (quoted + effective) / 2
 }
@@ -0,0 +1,244 @@
+package probability
+
+import (
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/metrics"
+	"github.com/bcdannyboy/stocd/models"
+	"golang.org/x/exp/rand"
+)
+
+var (
+	pathCacheRequests = metrics.NewCounter("stocd_path_cache_requests_total", "Requests for a batch of shared paths, by whether the batch was already simulated.", "result")
+	simulatedPaths    = metrics.NewCounter("stocd_simulated_paths_total", "Underlying paths simulated, by model.", "model")
+)
+
+// pathBatch is a batch of simulated underlying paths, in the order the sampler drew them so antithetic
+// pairs stay adjacent.
+type pathBatch struct {
+	finalPrices []float64
+	brownian    []float64 // Terminal value of each path's price Brownian motion, nil for models without a control variate
+}
+
+// pathModel simulates a batch of paths of the underlying. outcome reports whether a final price is
+// profitable and is only called for traced paths.
+type pathModel func(underlyingPrice, riskFreeRate, volatility, tau float64, rng *rand.Rand, globalModels GlobalModels, useHeston bool, trace *pathTrace, outcome func(float64) bool) pathBatch
+
+// pathKey identifies the paths of one volatility/model combination. Every spread with the same underlying
+// price, rate, expiration and volatility input can be evaluated against the same paths.
+type pathKey struct {
+	model           string
+	tau             float64
+	underlyingPrice float64
+	riskFreeRate    float64
+	volatility      float64
+	useHeston       bool
+}
+
+// PathCache shares simulated underlying paths between the spreads of a scan, so each volatility/model
+// combination is simulated once per expiration instead of once per spread. Only the volatility inputs
+// every spread shares are cached (see sharedVolatility). A nil cache shares nothing.
+type PathCache struct {
+	entries sync.Map // pathKey to *pathEntry
+}
+
+type pathEntry struct {
+	mu      sync.Mutex
+	batches []pathBatch
+}
+
+func NewPathCache() *PathCache {
+	return &PathCache{}
+}
+
+// sharedVolatility reports whether the named volatility input is the same for every spread of a scan: the
+// historical, surface average, Heston and intraday volatilities. Those of the legs, and averages including
+// them, are specific to a spread, so their paths are never reused and are not cached.
+func sharedVolatility(name string) bool {
+	switch name {
+	case "AvgYZ_RS", "TotalAvgVolSurface", "HestonModelVol", "Intraday":
+		return true
+	}
+	return strings.HasPrefix(name, "YZ_") || strings.HasPrefix(name, "RS_")
+}
+
+// batch returns the i-th batch of paths for key, generating the batches up to it if no spread has yet.
+// Spreads asking for a batch being generated wait for it rather than simulating it again.
+func (c *PathCache) batch(key pathKey, i int, generate func() pathBatch) pathBatch {
+	if c == nil {
+		return generate()
+	}
+	value, _ := c.entries.LoadOrStore(key, &pathEntry{})
+	entry := value.(*pathEntry)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if len(entry.batches) > i {
+		pathCacheRequests.Inc("hit")
+		return entry.batches[i]
+	}
+	pathCacheRequests.Inc("miss")
+	for len(entry.batches) <= i {
+		entry.batches = append(entry.batches, generate())
+	}
+	return entry.batches[i]
+}
+
+// evaluateBatch estimates the spread's probability of profit and its standard error over a batch of paths,
+// with the control variate when the batch carries the paths' Brownian motion.
+func evaluateBatch(spread models.OptionSpread, batch pathBatch, underlyingPrice, riskFreeRate, volatility, tau float64) (float64, float64) {
+	estimate := newEstimator(spread, underlyingPrice, riskFreeRate, volatility, tau, SimulationVarianceReduction(), batch.brownian != nil)
+	for i, price := range batch.finalPrices {
+		w := 0.0
+		if batch.brownian != nil {
+			w = batch.brownian[i]
+		}
+		estimate.add(models.IsProfitable(spread, price), w)
+	}
+	return estimate.result()
+}
+
+func simulateMertonPaths(underlyingPrice, riskFreeRate, volatility, tau float64, rng *rand.Rand, globalModels GlobalModels, useHeston bool, trace *pathTrace, outcome func(float64) bool) pathBatch {
+	merton := *globalModels.Merton // Create a copy of the global model
+	merton.Sigma = volatility      // Use the provided volatility
+	if useHeston && trace == nil && SimulationBackend() == VectorizedBackend {
+		return simulateHestonJumpBatch(underlyingPrice, riskFreeRate, volatility, tau, rng, globalModels.Heston, merton.Lambda, mertonLogJump(merton))
+	}
+
+	sampler := newSampler(rng, SimulationVarianceReduction())
+	batch := pathBatch{finalPrices: make([]float64, maxSimulations)}
+	if useHeston {
+		batch.brownian = make([]float64, maxSimulations)
+	}
+
+	for i := 0; i < maxSimulations; i++ {
+		sampler.startPath()
+		sampled := trace.sample()
+		var finalPrice float64
+		if useHeston {
+			volPath := simulateHestonVolPath(globalModels.Heston, volatility, tau, timeSteps, sampler)
+			dW := sampler.brownian(0, timeSteps, tau/timeSteps)
+			finalPrice = simulateMertonPriceWithHestonVol(underlyingPrice, riskFreeRate, tau, sampler, merton, volPath, dW, sampled)
+			batch.brownian[i] = sum(dW)
+		} else {
+			finalPrice = merton.SimulatePrice(underlyingPrice, riskFreeRate, tau, timeSteps, rng)
+			sampled.step(timeSteps, tau, finalPrice, volatility, 0, 0)
+		}
+		batch.finalPrices[i] = finalPrice
+		if sampled != nil {
+			sampled.finish(outcome(finalPrice))
+		}
+	}
+	return batch
+}
+
+func simulateKouPaths(underlyingPrice, riskFreeRate, volatility, tau float64, rng *rand.Rand, globalModels GlobalModels, useHeston bool, trace *pathTrace, outcome func(float64) bool) pathBatch {
+	kou := *globalModels.Kou // Create a copy of the global model
+	kou.Sigma = volatility   // Use the provided volatility
+	kou.R = riskFreeRate     // Set the risk-free rate
+	if useHeston && trace == nil && SimulationBackend() == VectorizedBackend {
+		return simulateHestonJumpBatch(underlyingPrice, riskFreeRate, volatility, tau, rng, globalModels.Heston, kou.Lambda, kouLogJump(kou))
+	}
+
+	sampler := newSampler(rng, SimulationVarianceReduction())
+	batch := pathBatch{finalPrices: make([]float64, maxSimulations)}
+	if useHeston {
+		batch.brownian = make([]float64, maxSimulations)
+	}
+
+	for i := 0; i < maxSimulations; i++ {
+		sampler.startPath()
+		sampled := trace.sample()
+		var finalPrice float64
+		if useHeston {
+			volPath := simulateHestonVolPath(globalModels.Heston, volatility, tau, timeSteps, sampler)
+			dW := sampler.brownian(0, timeSteps, tau/timeSteps)
+			finalPrice = simulateKouPriceWithHestonVol(underlyingPrice, riskFreeRate, tau, sampler, kou, volPath, dW, sampled)
+			batch.brownian[i] = sum(dW)
+		} else {
+			finalPrice = kou.SimulatePrice(underlyingPrice, riskFreeRate, tau, timeSteps, rng)
+			sampled.step(timeSteps, tau, finalPrice, volatility, 0, 0)
+		}
+		batch.finalPrices[i] = finalPrice
+		if sampled != nil {
+			sampled.finish(outcome(finalPrice))
+		}
+	}
+	return batch
+}
+
+// simulateCGMYPaths has no control variate: its shocks are not driven by a Brownian motion.
+func simulateCGMYPaths(underlyingPrice, riskFreeRate, volatility, tau float64, rng *rand.Rand, globalModels GlobalModels, useHeston bool, trace *pathTrace, outcome func(float64) bool) pathBatch {
+	cgmy := *globalModels.CGMY
+	sampler := newSampler(rng, SimulationVarianceReduction())
+	batch := pathBatch{finalPrices: make([]float64, maxSimulations)}
+
+	for i := 0; i < maxSimulations; i++ {
+		sampler.startPath()
+		sampled := trace.sample()
+		path := cgmy.SimulatePath(tau, tau/float64(timeSteps), sampler)
+		var finalPrice float64
+		if useHeston {
+			volPath := simulateHestonVolPath(globalModels.Heston, volatility, tau, timeSteps, sampler)
+			finalPrice = simulateCGMYPriceWithHestonVol(underlyingPrice, riskFreeRate, tau, path, volPath, sampled)
+		} else {
+			finalPrice = underlyingPrice * math.Exp(path[len(path)-1])
+			sampled.step(len(path)-1, tau, finalPrice, volatility, 0, path[len(path)-1])
+		}
+		batch.finalPrices[i] = finalPrice
+		if sampled != nil {
+			sampled.finish(outcome(finalPrice))
+		}
+	}
+	return batch
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// simulateStudentTPaths drives the price with the Student-t fitted to the daily returns.
+func simulateStudentTPaths(underlyingPrice, riskFreeRate, volatility, tau float64, rng *rand.Rand, globalModels GlobalModels, useHeston bool, trace *pathTrace, outcome func(float64) bool) pathBatch {
+	return simulateHeavyTailedPaths(*globalModels.StudentT, underlyingPrice, riskFreeRate, volatility, tau, rng, trace, outcome)
+}
+
+// simulateSkewTPaths drives the price with the Hansen skew-t fitted to the daily returns.
+func simulateSkewTPaths(underlyingPrice, riskFreeRate, volatility, tau float64, rng *rand.Rand, globalModels GlobalModels, useHeston bool, trace *pathTrace, outcome func(float64) bool) pathBatch {
+	return simulateHeavyTailedPaths(*globalModels.SkewT, underlyingPrice, riskFreeRate, volatility, tau, rng, trace, outcome)
+}
+
+// simulateHeavyTailedPaths steps the price a trading day at a time with shocks of the distribution scaled
+// to the volatility, at a constant volatility: the fitted tails already carry the returns' excess kurtosis.
+// The drift is corrected by the shocks' moment generating function so the price grows at the risk-free
+// rate. There is no control variate: the shocks are not Brownian.
+func simulateHeavyTailedPaths(dist models.SkewT, underlyingPrice, riskFreeRate, volatility, tau float64, rng *rand.Rand, trace *pathTrace, outcome func(float64) bool) pathBatch {
+	steps := int(math.Max(math.Round(tau*market.TradingDaysPerYear), 1))
+	dt := tau / float64(steps)
+	scale := volatility * math.Sqrt(dt)
+	drift := riskFreeRate*dt - dist.LogMGF(scale)
+
+	sampler := newSampler(rng, SimulationVarianceReduction())
+	batch := pathBatch{finalPrices: make([]float64, maxSimulations)}
+	for i := 0; i < maxSimulations; i++ {
+		sampler.startPath()
+		sampled := trace.sample()
+		logPrice := math.Log(underlyingPrice)
+		for step := 1; step <= steps; step++ {
+			shock := scale * dist.Sample(sampler)
+			logPrice += drift + shock
+			sampled.step(step, float64(step)*dt, math.Exp(logPrice), volatility, shock, 0)
+		}
+		batch.finalPrices[i] = math.Exp(logPrice)
+		if sampled != nil {
+			sampled.finish(outcome(batch.finalPrices[i]))
+		}
+	}
+	return batch
+}
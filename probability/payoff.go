@@ -0,0 +1,96 @@
+package probability
+
+import (
+	"math"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+const payoffGridPoints = 61
+
+// calculatePayoffCurve samples the spread's P&L from 1.5 widths beyond each strike, always including
+// spot, at expiration and at half the remaining time marked with BSM at the leg volatilities.
+func calculatePayoffCurve(spread models.OptionSpread, underlyingPrice, riskFreeRate, tau, shortLegVol, longLegVol float64) models.PayoffCurve {
+	short := spread.ShortLeg.Option.Strike
+	long := spread.LongLeg.Option.Strike
+	width := math.Abs(short - long)
+	if models.IsSingleLeg(spread) {
+		long = short
+		width = 0.2 * short // No second strike, so span a fifth of the strike either side
+	}
+	if models.IsStrangle(spread) {
+		long = spread.CallLeg.Option.Strike
+		width = math.Max(long-short, 0.1*short)
+	}
+	if len(spread.Legs) > 0 {
+		short, long = math.Inf(1), 0
+		for _, leg := range spread.Legs {
+			short = math.Min(short, leg.Option.Strike)
+			long = math.Max(long, leg.Option.Strike)
+		}
+		width = math.Max(long-short, 0.1*short)
+	}
+	if width == 0 {
+		width = 1
+	}
+
+	lo := math.Max(math.Min(math.Min(short, long)-1.5*width, underlyingPrice), 0.01)
+	hi := math.Max(math.Max(short, long)+1.5*width, underlyingPrice)
+
+	halfLife := tau / 2
+	markToModel := func(price float64) float64 {
+		return markPosition(spread, price, halfLife, riskFreeRate, shortLegVol, longLegVol, 1)
+	}
+
+	curve := models.PayoffCurve{
+		Prices:        make([]float64, payoffGridPoints),
+		AtExpiration:  make([]float64, payoffGridPoints),
+		AtHalfLife:    make([]float64, payoffGridPoints),
+		HalfLifeDelta: make([]float64, payoffGridPoints),
+	}
+	bump := 0.001 * underlyingPrice
+	for i := range curve.Prices {
+		price := lo + float64(i)/float64(payoffGridPoints-1)*(hi-lo)
+		curve.Prices[i] = price
+		curve.AtExpiration[i] = models.PayoffAtExpiration(spread, price)
+		curve.AtHalfLife[i] = markToModel(price)
+		curve.HalfLifeDelta[i] = (markToModel(price+bump) - markToModel(price-bump)) / (2 * bump)
+	}
+
+	return curve
+}
+
+// markPosition returns the per share P&L of the position with the legs marked with BSM at spot and tau
+// years to expiration, their volatilities multiplied by volScale. The short call of a strangle or jade
+// lizard and every leg of a declared strategy are marked at their own implied volatility. Covered calls
+// include the P&L of the shares, and inverse options the change in the dollar value of their coin credit.
+func markPosition(spread models.OptionSpread, spot, tau, riskFreeRate, shortLegVol, longLegVol, volScale float64) float64 {
+	pnl := markLegs(spread, spot, tau, riskFreeRate, shortLegVol, longLegVol, volScale)
+	if spread.InversePrice > 0 {
+		pnl += spread.SpreadCredit * (spot/spread.InversePrice - 1)
+	}
+	return pnl
+}
+
+func markLegs(spread models.OptionSpread, spot, tau, riskFreeRate, shortLegVol, longLegVol, volScale float64) float64 {
+	if len(spread.Legs) > 0 {
+		pnl := spread.SpreadCredit
+		for _, leg := range spread.Legs {
+			value := models.BlackScholesPrice(spot, leg.Option.Strike, tau, riskFreeRate, legVolatility(leg.SpreadLeg, shortLegVol)*volScale, leg.Option.OptionType == "call")
+			pnl -= float64(leg.Quantity) * value
+		}
+		return pnl
+	}
+	shortValue := models.BlackScholesPrice(spot, spread.ShortLeg.Option.Strike, tau, riskFreeRate, shortLegVol*volScale, spread.ShortLeg.Option.OptionType == "call")
+	pnl := spread.SpreadCredit - models.ShortContracts(spread)*shortValue
+	if spread.LongLeg.Option.Symbol != "" {
+		pnl += models.BlackScholesPrice(spot, spread.LongLeg.Option.Strike, tau, riskFreeRate, longLegVol*volScale, spread.LongLeg.Option.OptionType == "call")
+	}
+	if spread.CallLeg.Option.Symbol != "" {
+		pnl -= models.BlackScholesPrice(spot, spread.CallLeg.Option.Strike, tau, riskFreeRate, legVolatility(spread.CallLeg, shortLegVol)*volScale, true)
+	}
+	if spread.SpreadType == "Covered Call" {
+		pnl += spot - spread.StockPrice
+	}
+	return pnl
+}
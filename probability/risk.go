@@ -0,0 +1,86 @@
+package probability
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// DefaultRiskLevels are the confidence levels VaR and expected shortfall are reported at.
+var DefaultRiskLevels = []float64{0.90, 0.95, 0.99}
+
+var riskLevels atomic.Pointer[[]float64]
+
+func init() {
+	SetRiskLevels(DefaultRiskLevels)
+}
+
+// RiskLevels returns the confidence levels VaR and expected shortfall are reported at, ascending.
+func RiskLevels() []float64 {
+	return *riskLevels.Load()
+}
+
+// SetRiskLevels changes the confidence levels of the tail risk of spreads simulated afterwards.
+// VaR95, VaR99 and their expected shortfalls are computed whatever the levels.
+func SetRiskLevels(levels []float64) {
+	levels = slices.Clone(levels)
+	sort.Float64s(levels)
+	riskLevels.Store(&levels)
+}
+
+// ParseRiskLevels reads a comma separated list of confidence levels, as fractions or percentages, e.g.
+// 0.9,0.95,0.99 or 90,95,99. An empty spec yields DefaultRiskLevels.
+func ParseRiskLevels(spec string) ([]float64, error) {
+	if strings.TrimSpace(spec) == "" {
+		return DefaultRiskLevels, nil
+	}
+
+	var levels []float64
+	for _, field := range strings.Split(spec, ",") {
+		level, err := ParseRiskLevel(field)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(levels, level) {
+			levels = append(levels, level)
+		}
+	}
+	sort.Float64s(levels)
+	return levels, nil
+}
+
+// ParseRiskLevel reads one confidence level, a fraction between 0.5 and 1 or a percentage between 50 and 100.
+func ParseRiskLevel(spec string) (float64, error) {
+	level, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(spec), "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid confidence level %q", strings.TrimSpace(spec))
+	}
+	if level > 1 {
+		level /= 100
+	}
+	if level < 0.5 || level >= 1 {
+		return 0, fmt.Errorf("confidence level %q is not between 50%% and 100%%", strings.TrimSpace(spec))
+	}
+	return level, nil
+}
+
+// calculateTailRisks computes the VaR and expected shortfall of the simulated final prices at each level.
+func calculateTailRisks(spread models.OptionSpread, simulations []float64, levels []float64) []models.TailRisk {
+	if len(simulations) == 0 {
+		return nil
+	}
+	risks := make([]models.TailRisk, len(levels))
+	for i, level := range levels {
+		risks[i] = models.TailRisk{
+			Level:             level,
+			VaR:               calculateVaR(spread, simulations, level),
+			ExpectedShortfall: calculateExpectedShortfall(spread, simulations, level),
+		}
+	}
+	return risks
+}
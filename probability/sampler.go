@@ -0,0 +1,355 @@
+package probability
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bcdannyboy/stocd/models"
+	"golang.org/x/exp/rand"
+)
+
+// VarianceReduction selects the variance reduction techniques applied to the Monte Carlo simulations.
+type VarianceReduction struct {
+	Antithetic     bool // Pair every path with one driven by its mirrored random draws
+	ControlVariate bool // Correct each probability with a GBM path sharing the simulated Brownian motion
+	QuasiRandom    bool // Set the terminal Brownian values with a randomly shifted Sobol sequence
+}
+
+// DefaultVarianceReduction applies antithetic variates and the BSM control variate.
+var DefaultVarianceReduction = VarianceReduction{Antithetic: true, ControlVariate: true}
+
+var varianceReduction atomic.Pointer[VarianceReduction]
+
+func init() {
+	SetVarianceReduction(DefaultVarianceReduction)
+}
+
+// SimulationVarianceReduction returns the variance reduction the simulations use.
+func SimulationVarianceReduction() VarianceReduction {
+	return *varianceReduction.Load()
+}
+
+// SetVarianceReduction changes the variance reduction for simulations started afterwards.
+func SetVarianceReduction(v VarianceReduction) {
+	varianceReduction.Store(&v)
+}
+
+// ParseVarianceReduction reads a comma separated list of antithetic, control and sobol, or none. An empty
+// spec yields DefaultVarianceReduction.
+func ParseVarianceReduction(spec string) (VarianceReduction, error) {
+	if strings.TrimSpace(spec) == "" {
+		return DefaultVarianceReduction, nil
+	}
+
+	var v VarianceReduction
+	for _, name := range strings.Split(spec, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "none":
+		case "antithetic":
+			v.Antithetic = true
+		case "control":
+			v.ControlVariate = true
+		case "sobol":
+			v.QuasiRandom = true
+		default:
+			return VarianceReduction{}, fmt.Errorf("unknown variance reduction %q (known: antithetic, control, sobol, none)", strings.TrimSpace(name))
+		}
+	}
+	return v, nil
+}
+
+type drawKind uint8
+
+const (
+	normalDraw drawKind = iota
+	uniformDraw
+	expDraw
+)
+
+type draw struct {
+	kind  drawKind
+	value float64
+}
+
+// sampler supplies the random draws of one simulation's paths. With antithetic variates every second path
+// replays the draws of the path before it mirrored: normals negated and uniforms reflected. Once the paths
+// branch differently, e.g. on a jump, unmatched draws are fresh, which keeps each path correctly distributed.
+type sampler struct {
+	rng       *rand.Rand
+	reduction VarianceReduction
+
+	paths  int
+	mirror bool
+	tape   []draw
+	pos    int
+
+	sobol sobol
+	shift [2]float64
+	point [2]float64 // The path's quasi-random point, one dimension per Brownian motion
+}
+
+func newSampler(rng *rand.Rand, reduction VarianceReduction) *sampler {
+	return &sampler{rng: rng, reduction: reduction, shift: [2]float64{rng.Float64(), rng.Float64()}}
+}
+
+// startPath begins the next path.
+func (s *sampler) startPath() {
+	s.mirror = s.reduction.Antithetic && s.paths%2 == 1
+	s.paths++
+	s.pos = 0
+	if !s.mirror {
+		s.tape = s.tape[:0]
+	}
+
+	if s.reduction.QuasiRandom {
+		if s.mirror {
+			s.point = [2]float64{1 - s.point[0], 1 - s.point[1]}
+		} else {
+			x := s.sobol.next()
+			for d := range s.point {
+				s.point[d] = math.Max(math.Mod(x[d]+s.shift[d], 1), math.SmallestNonzeroFloat64)
+			}
+		}
+	}
+}
+
+// replay returns the mirror of the original path's draw at the current position, if it was of the same kind.
+func (s *sampler) replay(kind drawKind) (float64, bool) {
+	if !s.mirror {
+		return 0, false
+	}
+	pos := s.pos
+	s.pos++
+	if pos >= len(s.tape) || s.tape[pos].kind != kind {
+		return 0, false
+	}
+	return s.tape[pos].value, true
+}
+
+func (s *sampler) record(kind drawKind, value float64) float64 {
+	if s.reduction.Antithetic && !s.mirror {
+		s.tape = append(s.tape, draw{kind: kind, value: value})
+	}
+	return value
+}
+
+func (s *sampler) NormFloat64() float64 {
+	if v, ok := s.replay(normalDraw); ok {
+		return -v
+	}
+	return s.record(normalDraw, s.rng.NormFloat64())
+}
+
+func (s *sampler) Float64() float64 {
+	if v, ok := s.replay(uniformDraw); ok {
+		return 1 - v
+	}
+	return s.record(uniformDraw, s.rng.Float64())
+}
+
+func (s *sampler) ExpFloat64() float64 {
+	if v, ok := s.replay(expDraw); ok {
+		return -math.Log(-math.Expm1(-v)) // The exponential draw of the reflected uniform
+	}
+	return s.record(expDraw, s.rng.ExpFloat64())
+}
+
+// brownian draws the increments of a Brownian motion over steps of dt. With quasi-random sampling the
+// increments are bridged to the terminal value set by the path's Sobol point in dimension dim.
+func (s *sampler) brownian(dim, steps int, dt float64) []float64 {
+	increments := make([]float64, steps)
+	sqrtDt := math.Sqrt(dt)
+	sum := 0.0
+	for i := range increments {
+		increments[i] = s.NormFloat64() * sqrtDt
+		sum += increments[i]
+	}
+
+	if s.reduction.QuasiRandom {
+		terminal := math.Sqrt(float64(steps)*dt) * math.Sqrt2 * math.Erfinv(2*s.point[dim]-1)
+		adjust := (sum - terminal) / float64(steps)
+		for i := range increments {
+			increments[i] -= adjust
+		}
+	}
+	return increments
+}
+
+// sobol generates the first two dimensions of the Sobol sequence in Gray code order.
+type sobol struct {
+	index uint32
+	x     [2]uint32
+}
+
+var sobolDirections = func() [2][32]uint32 {
+	var v [2][32]uint32
+	for k := 0; k < 32; k++ {
+		v[0][k] = 1 << (31 - k)
+		if k == 0 {
+			v[1][k] = 1 << 31
+		} else {
+			v[1][k] = v[1][k-1] ^ (v[1][k-1] >> 1) // Primitive polynomial x + 1
+		}
+	}
+	return v
+}()
+
+func (q *sobol) next() [2]float64 {
+	c := bits.TrailingZeros32(^q.index)
+	for d := range q.x {
+		q.x[d] ^= sobolDirections[d][c]
+	}
+	q.index++
+	return [2]float64{float64(q.x[0]) / (1 << 32), float64(q.x[1]) / (1 << 32)}
+}
+
+// estimator turns the outcomes of a simulation's paths into a probability of profit and its standard
+// error. With the control variate it corrects the estimate with the GBM path driven by the same Brownian
+// motion at the simulation's volatility, whose probability of profit is known in closed form; with
+// antithetic variates the error is measured over the averages of each path and its mirror.
+type estimator struct {
+	pairs   bool
+	control bool
+	spread  models.OptionSpread
+	s0      float64
+	drift   float64
+	vol     float64
+	exact   float64
+
+	outcomes []float64
+	controls []float64
+}
+
+func newEstimator(spread models.OptionSpread, underlyingPrice, riskFreeRate, volatility, tau float64, reduction VarianceReduction, control bool) *estimator {
+	e := &estimator{pairs: reduction.Antithetic, control: control && reduction.ControlVariate && volatility > 0 && tau > 0 && underlyingPrice > 0}
+	if e.control {
+		e.spread, e.s0, e.vol = spread, underlyingPrice, volatility
+		e.drift = (riskFreeRate - 0.5*volatility*volatility) * tau
+		e.exact = lognormalProbabilityOfProfit(spread, underlyingPrice, riskFreeRate, volatility, tau)
+	}
+	return e
+}
+
+// add records a path's outcome and, with the control variate, the control's outcome on its Brownian
+// motion's terminal value w.
+func (e *estimator) add(profitable bool, w float64) {
+	y := 0.0
+	if profitable {
+		y = 1
+	}
+	e.outcomes = append(e.outcomes, y)
+	if !e.control {
+		return
+	}
+
+	c := 0.0
+	if models.IsProfitable(e.spread, e.s0*math.Exp(e.drift+e.vol*w)) {
+		c = 1
+	}
+	e.controls = append(e.controls, c)
+}
+
+// result returns the estimated probability and its Monte Carlo standard error.
+func (e *estimator) result() (float64, float64) {
+	n := len(e.outcomes)
+	if n == 0 {
+		return 0, 0
+	}
+
+	values := e.outcomes
+	if e.control && len(e.controls) == n {
+		meanY, meanC, covYC, varC := 0.0, 0.0, 0.0, 0.0
+		for i := range values {
+			meanY += e.outcomes[i]
+			meanC += e.controls[i]
+		}
+		meanY, meanC = meanY/float64(n), meanC/float64(n)
+		for i := range values {
+			covYC += (e.outcomes[i] - meanY) * (e.controls[i] - meanC)
+			varC += (e.controls[i] - meanC) * (e.controls[i] - meanC)
+		}
+		if varC > 0 {
+			beta := covYC / varC
+			values = make([]float64, n)
+			for i := range values {
+				values[i] = e.outcomes[i] - beta*(e.controls[i]-e.exact)
+			}
+		}
+	}
+
+	if e.pairs && n%2 == 0 {
+		paired := make([]float64, n/2)
+		for i := range paired {
+			paired[i] = (values[2*i] + values[2*i+1]) / 2
+		}
+		values = paired
+	}
+
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return math.Min(math.Max(mean, 0), 1), 0
+	}
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values) - 1)
+	return math.Min(math.Max(mean, 0), 1), math.Sqrt(variance / float64(len(values)))
+}
+
+// lognormalProbabilityOfProfit is the probability the spread finishes profitable when the underlying
+// follows GBM at volatility. Profitable regions are found on a grid of standard normal quantiles and their
+// boundaries refined by bisection.
+func lognormalProbabilityOfProfit(spread models.OptionSpread, underlyingPrice, riskFreeRate, volatility, tau float64) float64 {
+	const (
+		gridPoints = 400
+		zRange     = 8.0
+	)
+	m := math.Log(underlyingPrice) + (riskFreeRate-0.5*volatility*volatility)*tau
+	sd := volatility * math.Sqrt(tau)
+	profitable := func(z float64) bool {
+		return models.IsProfitable(spread, math.Exp(m+sd*z))
+	}
+	cdf := func(z float64) float64 {
+		return 0.5 * math.Erfc(-z/math.Sqrt2)
+	}
+
+	boundaries := []float64{math.Inf(-1)}
+	prevZ := -zRange
+	prev := profitable(prevZ)
+	for i := 1; i <= gridPoints; i++ {
+		z := -zRange + 2*zRange*float64(i)/gridPoints
+		current := profitable(z)
+		if current != prev {
+			lo, hi := prevZ, z
+			for j := 0; j < 40; j++ {
+				mid := (lo + hi) / 2
+				if profitable(mid) == prev {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			boundaries = append(boundaries, (lo+hi)/2)
+		}
+		prevZ, prev = z, current
+	}
+	boundaries = append(boundaries, math.Inf(1))
+
+	probability := 0.0
+	for i := 0; i+1 < len(boundaries); i++ {
+		lo, hi := boundaries[i], boundaries[i+1]
+		mid := (math.Max(lo, -zRange-1) + math.Min(hi, zRange+1)) / 2
+		if profitable(mid) {
+			probability += cdf(hi) - cdf(lo)
+		}
+	}
+	return probability
+}
@@ -0,0 +1,80 @@
+package probability
+
+import (
+	"math"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// historicalScenario holds the daily S&P 500 closes and VIX closes observed
+// during a market shock. Only the shape of the paths (relative to the first
+// day) is applied to the current underlying.
+type historicalScenario struct {
+	name   string
+	closes []float64
+	vix    []float64
+}
+
+var historicalScenarios = []historicalScenario{
+	{
+		name: "Oct 2008 Crash",
+		closes: []float64{1166.36, 1161.06, 1114.28, 1099.23, 1056.89, 996.23, 984.94, 909.92, 899.22, 1003.35,
+			998.01, 907.84, 946.43, 940.55, 985.40, 955.05, 896.78, 908.11, 876.77, 848.92},
+		vix: []float64{39.39, 39.81, 45.26, 45.14, 52.05, 53.68, 57.53, 63.92, 69.95, 54.99,
+			55.13, 69.25, 67.61, 70.33, 52.97, 53.11, 69.65, 67.80, 79.13, 80.06},
+	},
+	{
+		name: "Mar 2020 COVID Crash",
+		closes: []float64{3386.15, 3373.23, 3337.75, 3225.89, 3128.21, 3116.39, 2978.76, 2954.22, 3090.23, 3003.37,
+			3130.12, 3023.94, 2972.37, 2746.56, 2882.23, 2741.38, 2480.64, 2711.02, 2386.13, 2529.19,
+			2398.10, 2409.39, 2304.92, 2237.40},
+		vix: []float64{14.38, 15.56, 17.08, 25.03, 27.85, 27.56, 39.16, 40.11, 33.42, 36.82,
+			31.99, 39.62, 41.94, 54.46, 47.30, 53.90, 75.47, 57.83, 82.69, 75.91,
+			76.45, 72.00, 66.04, 61.59},
+	},
+	{
+		name:   "Aug 2024 Vol Spike",
+		closes: []float64{5522.30, 5446.68, 5346.56, 5186.33, 5240.03, 5199.50, 5319.31},
+		vix:    []float64{16.36, 18.59, 23.39, 38.57, 27.71, 27.85, 23.79},
+	},
+}
+
+// replayHistoricalScenarios applies each canned shock to the current underlying
+// and marks the spread to market every day of the replay using BSM with the
+// leg volatilities scaled by the scenario's VIX path.
+func replayHistoricalScenarios(spread models.OptionSpread, underlyingPrice, riskFreeRate, tau, shortLegVol, longLegVol float64) ([]models.ScenarioResult, float64) {
+	results := make([]models.ScenarioResult, 0, len(historicalScenarios))
+	worst := 0.0
+
+	for _, scenario := range historicalScenarios {
+		result := replayScenario(scenario, spread, underlyingPrice, riskFreeRate, tau, shortLegVol, longLegVol)
+		results = append(results, result)
+		worst = math.Max(worst, result.WorstLoss)
+	}
+
+	return results, worst
+}
+
+func replayScenario(scenario historicalScenario, spread models.OptionSpread, underlyingPrice, riskFreeRate, tau, shortLegVol, longLegVol float64) models.ScenarioResult {
+	result := models.ScenarioResult{Name: scenario.name}
+	for day := range scenario.closes {
+		spot := underlyingPrice * scenario.closes[day] / scenario.closes[0]
+		volScale := scenario.vix[day] / scenario.vix[0]
+		remaining := math.Max(tau-float64(day)/market.TradingDaysPerYear, 0)
+
+		pnl := markPosition(spread, spot, remaining, riskFreeRate, shortLegVol, longLegVol, volScale)
+		if -pnl > result.WorstLoss {
+			result.WorstLoss = -pnl
+			result.WorstDay = day
+		}
+		result.FinalPnL = pnl
+
+		if remaining == 0 {
+			break
+		}
+	}
+	result.SpotChange = scenario.closes[len(scenario.closes)-1]/scenario.closes[0] - 1
+
+	return result
+}
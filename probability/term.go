@@ -0,0 +1,132 @@
+package probability
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/tradier"
+	"golang.org/x/exp/rand"
+)
+
+// TermPoint is the simulated probability of the underlying finishing above a strike at one expiration.
+type TermPoint struct {
+	Expiration string
+	DTE        int
+	Volatility float64 // Implied volatility used for the expiration, taken at the listed strike nearest the target
+	ProbAbove  float64
+}
+
+// StrikeTermStructure simulates the underlying to every expiration in the chain with the Merton,
+// Kou and CGMY models under Heston volatility, each expiration with the models of its tenor, and reports
+// the probability of finishing above strike.
+func StrikeTermStructure(strike, underlyingPrice, riskFreeRate float64, chain map[string]*tradier.OptionChain, globalModels GlobalModels, now time.Time) []TermPoint {
+	var points []TermPoint
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for expiration, expirationChain := range chain {
+		dte, err := market.DaysToExpiration(expiration, now)
+		if err != nil || dte < 0 {
+			continue
+		}
+		tau, _ := market.YearsToExpiration(expiration, now)
+		if tau <= 0 {
+			continue
+		}
+
+		tenorModels := globalModels.ForExpiration(dte)
+		volatility := strikeVolatility(expirationChain.Options.Option, strike)
+		if volatility <= 0 {
+			volatility = math.Sqrt(tenorModels.Heston.V0)
+		}
+
+		wg.Add(1)
+		go func(expiration string, dte int, tau, volatility float64, globalModels GlobalModels) {
+			defer wg.Done()
+
+			rng := rngPool.Get().(*rand.Rand)
+			defer rngPool.Put(rng)
+
+			probAbove := simulateProbabilityAbove(strike, underlyingPrice, riskFreeRate, tau, volatility, globalModels, rng)
+
+			mu.Lock()
+			points = append(points, TermPoint{Expiration: expiration, DTE: dte, Volatility: volatility, ProbAbove: probAbove})
+			mu.Unlock()
+		}(expiration, dte, tau, volatility, tenorModels)
+	}
+
+	wg.Wait()
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Expiration < points[j].Expiration
+	})
+	return points
+}
+
+func simulateProbabilityAbove(strike, underlyingPrice, riskFreeRate, tau, volatility float64, globalModels GlobalModels, rng *rand.Rand) float64 {
+	merton := *globalModels.Merton
+	merton.Sigma = volatility
+
+	kou := *globalModels.Kou
+	kou.Sigma = volatility
+	kou.R = riskFreeRate
+
+	cgmy := *globalModels.CGMY
+	sampler := newSampler(rng, SimulationVarianceReduction())
+	dt := tau / timeSteps
+
+	above := 0
+	for i := 0; i < maxSimulations; i++ {
+		sampler.startPath()
+		volPath := simulateHestonVolPath(globalModels.Heston, volatility, tau, timeSteps, sampler)
+		if simulateMertonPriceWithHestonVol(underlyingPrice, riskFreeRate, tau, sampler, merton, volPath, sampler.brownian(0, timeSteps, dt), nil) > strike {
+			above++
+		}
+
+		volPath = simulateHestonVolPath(globalModels.Heston, volatility, tau, timeSteps, sampler)
+		if simulateKouPriceWithHestonVol(underlyingPrice, riskFreeRate, tau, sampler, kou, volPath, sampler.brownian(0, timeSteps, dt), nil) > strike {
+			above++
+		}
+
+		volPath = simulateHestonVolPath(globalModels.Heston, volatility, tau, timeSteps, sampler)
+		path := cgmy.SimulatePath(tau, dt, sampler)
+		if simulateCGMYPriceWithHestonVol(underlyingPrice, riskFreeRate, tau, path, volPath, nil) > strike {
+			above++
+		}
+	}
+
+	return float64(above) / float64(3*maxSimulations)
+}
+
+// strikeVolatility averages the implied volatility of the options listed at the strike closest to target.
+func strikeVolatility(options []tradier.Option, target float64) float64 {
+	nearest := math.Inf(1)
+	for _, option := range options {
+		if math.Abs(option.Strike-target) < math.Abs(nearest-target) {
+			nearest = option.Strike
+		}
+	}
+
+	total, count := 0.0, 0
+	for _, option := range options {
+		if option.Strike != nearest {
+			continue
+		}
+		iv := option.Greeks.MidIv
+		if iv <= 0 {
+			iv = (option.Greeks.BidIv + option.Greeks.AskIv) / 2
+		}
+		if iv > 0 {
+			total += iv
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
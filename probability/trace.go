@@ -0,0 +1,180 @@
+package probability
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// TraceStep is one time step of a traced simulation path.
+type TraceStep struct {
+	VolType    string
+	Model      string
+	Volatility float64 // Volatility the simulation was seeded with
+	Path       int
+	Step       int
+	Time       float64 // Years since the start of the path
+	Price      float64
+	Vol        float64 // Instantaneous (Heston) volatility used for the step
+	Shock      float64 // Brownian increment dW
+	Jump       float64 // Log jump (or CGMY increment) applied during the step
+	Profitable bool    // Whether the path's final price is profitable for the spread
+}
+
+// Tracer records full paths and intermediate values for a sampled subset of the
+// simulations run for a single spread, so model implementations can be checked
+// against reference results.
+type Tracer struct {
+	SpreadID string // Short and long leg symbols joined by "_"
+	Paths    int    // Paths recorded per volatility/model combination
+	Output   string // CSV file the trace is written to
+
+	mu    sync.Mutex
+	steps []TraceStep
+}
+
+var tracer *Tracer
+
+// EnableTracing turns on path tracing for the spread with the given ID. Tracing is
+// throttled to the first paths simulations of each volatility/model combination.
+func EnableTracing(spreadID string, paths int, output string) {
+	if output == "" {
+		output = fmt.Sprintf("trace_%s.csv", spreadID)
+	}
+	tracer = &Tracer{SpreadID: spreadID, Paths: paths, Output: output}
+}
+
+func tracerFor(spreadID string) *Tracer {
+	if tracer == nil || tracer.SpreadID != spreadID {
+		return nil
+	}
+	return tracer
+}
+
+// pathTrace collects the sampled paths of one volatility/model combination. It is
+// owned by a single goroutine; all methods are no-ops on a nil receiver.
+type pathTrace struct {
+	volType    string
+	model      string
+	volatility float64
+	limit      int
+	paths      int
+	current    []TraceStep
+	steps      []TraceStep
+}
+
+func (t *Tracer) newPathTrace(volType, model string, volatility float64) *pathTrace {
+	if t == nil {
+		return nil
+	}
+	return &pathTrace{volType: volType, model: model, volatility: volatility, limit: t.Paths}
+}
+
+// sample returns the trace if the next path should be recorded, nil otherwise.
+func (p *pathTrace) sample() *pathTrace {
+	if p == nil || p.paths >= p.limit {
+		return nil
+	}
+	p.current = p.current[:0]
+	return p
+}
+
+func (p *pathTrace) step(step int, t, price, vol, shock, jump float64) {
+	if p == nil {
+		return
+	}
+	p.current = append(p.current, TraceStep{
+		VolType:    p.volType,
+		Model:      p.model,
+		Volatility: p.volatility,
+		Path:       p.paths,
+		Step:       step,
+		Time:       t,
+		Price:      price,
+		Vol:        vol,
+		Shock:      shock,
+		Jump:       jump,
+	})
+}
+
+// finish closes the current path, marking every step with the path's outcome.
+func (p *pathTrace) finish(profitable bool) {
+	if p == nil {
+		return
+	}
+	for i := range p.current {
+		p.current[i].Profitable = profitable
+	}
+	p.steps = append(p.steps, p.current...)
+	p.paths++
+}
+
+func (t *Tracer) add(p *pathTrace) {
+	if t == nil || p == nil {
+		return
+	}
+	t.mu.Lock()
+	t.steps = append(t.steps, p.steps...)
+	t.mu.Unlock()
+}
+
+// WriteCSV writes every recorded step with a header row.
+func (t *Tracer) WriteCSV(w io.Writer) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"vol_type", "model", "volatility", "path", "step", "time", "price", "vol", "shock", "jump", "profitable"}); err != nil {
+		return fmt.Errorf("failed to write trace header: %s", err)
+	}
+
+	for _, s := range t.steps {
+		record := []string{
+			s.VolType,
+			s.Model,
+			strconv.FormatFloat(s.Volatility, 'g', -1, 64),
+			strconv.Itoa(s.Path),
+			strconv.Itoa(s.Step),
+			strconv.FormatFloat(s.Time, 'g', -1, 64),
+			strconv.FormatFloat(s.Price, 'g', -1, 64),
+			strconv.FormatFloat(s.Vol, 'g', -1, 64),
+			strconv.FormatFloat(s.Shock, 'g', -1, 64),
+			strconv.FormatFloat(s.Jump, 'g', -1, 64),
+			strconv.FormatBool(s.Profitable),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write trace row: %s", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// flush writes the recorded trace to the output file and clears it for the next scan.
+func (t *Tracer) flush() {
+	if t == nil {
+		return
+	}
+
+	file, err := os.Create(t.Output)
+	if err != nil {
+		log.Printf("Error creating trace file for %s: %v", t.SpreadID, err)
+		return
+	}
+	defer file.Close()
+
+	if err := t.WriteCSV(file); err != nil {
+		log.Printf("Error writing trace for %s: %v", t.SpreadID, err)
+		return
+	}
+
+	t.mu.Lock()
+	log.Printf("Wrote %d traced simulation steps for %s to %s", len(t.steps), t.SpreadID, t.Output)
+	t.steps = nil
+	t.mu.Unlock()
+}
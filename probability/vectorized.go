@@ -0,0 +1,161 @@
+package probability
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bcdannyboy/stocd/models"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/floats"
+)
+
+// Backend selects how the Heston driven Merton and Kou paths are generated.
+type Backend int32
+
+const (
+	// ScalarBackend simulates one path at a time, supporting every variance reduction and path tracing.
+	ScalarBackend Backend = iota
+	// VectorizedBackend advances all paths of a batch together one trading day at a time with gonum's vector
+	// kernels, accumulating log prices and drawing each path's jumps at once. It applies antithetic variates
+	// and the control variate but not Sobol sampling; traced paths and CGMY always use the scalar backend.
+	VectorizedBackend
+)
+
+// minVectorizedSteps is the fewest time steps of a vectorized path, for expirations within a month.
+const minVectorizedSteps = 21
+
+var simulationBackend atomic.Int32
+
+// SimulationBackend returns the backend generating the simulated paths.
+func SimulationBackend() Backend {
+	return Backend(simulationBackend.Load())
+}
+
+// SetSimulationBackend changes the backend for paths generated afterwards.
+func SetSimulationBackend(b Backend) {
+	simulationBackend.Store(int32(b))
+}
+
+// ParseBackend reads a backend name, scalar or vectorized. An empty name is scalar.
+func ParseBackend(name string) (Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "scalar":
+		return ScalarBackend, nil
+	case "vectorized":
+		return VectorizedBackend, nil
+	}
+	return ScalarBackend, fmt.Errorf("unknown simulation backend %q (known: scalar, vectorized)", name)
+}
+
+// logJump draws the total log price jump of n jumps.
+type logJump func(rng *rand.Rand, n int) float64
+
+func mertonLogJump(merton models.MertonJumpDiffusion) logJump {
+	return func(rng *rand.Rand, n int) float64 {
+		return float64(n)*merton.Mu + math.Sqrt(float64(n))*merton.Delta*rng.NormFloat64()
+	}
+}
+
+func kouLogJump(kou models.KouJumpDiffusion) logJump {
+	return func(rng *rand.Rand, n int) float64 {
+		total := 0.0
+		for i := 0; i < n; i++ {
+			if rng.Float64() < kou.P {
+				total += rng.ExpFloat64() / kou.Eta1
+			} else {
+				total -= rng.ExpFloat64() / kou.Eta2
+			}
+		}
+		return total
+	}
+}
+
+// simulateHestonJumpBatch generates a batch of paths whose diffusion follows the Heston variance starting
+// at volatility, with jumps arriving at rate lambda, in daily steps. The price Brownian terminal values are
+// kept for the control variate.
+func simulateHestonJumpBatch(underlyingPrice, riskFreeRate, volatility, tau float64, rng *rand.Rand, heston *models.HestonModel, lambda float64, jump logJump) pathBatch {
+	n := maxSimulations
+	steps := max(int(math.Ceil(tau*timeSteps)), minVectorizedSteps)
+	dt := tau / float64(steps)
+	sqrtDt := math.Sqrt(dt)
+	antithetic := SimulationVarianceReduction().Antithetic
+
+	variance := make([]float64, n)
+	for i := range variance {
+		variance[i] = volatility * volatility
+	}
+	logPrice := make([]float64, n)
+	brownian := make([]float64, n)
+	vol := make([]float64, n)
+	dWPrice := make([]float64, n)
+	dWVar := make([]float64, n)
+	shock := make([]float64, n)
+
+	for step := 0; step < steps; step++ {
+		fillNormals(rng, dWPrice, antithetic)
+		fillNormals(rng, dWVar, antithetic)
+		floats.Scale(sqrtDt, dWPrice)
+		floats.Scale(sqrtDt, dWVar)
+		for i, v := range variance {
+			vol[i] = math.Sqrt(v)
+		}
+
+		// Log price: (r - v/2) dt + sqrt(v) dW
+		floats.Add(brownian, dWPrice)
+		floats.AddConst(riskFreeRate*dt, logPrice)
+		floats.AddScaled(logPrice, -0.5*dt, variance)
+		floats.Add(logPrice, floats.MulTo(shock, vol, dWPrice))
+
+		// Variance: kappa (theta - v) dt + xi sqrt(v) dW, kept non-negative
+		floats.Scale(1-heston.Kappa*dt, variance)
+		floats.AddConst(heston.Kappa*heston.Theta*dt, variance)
+		floats.AddScaled(variance, heston.Xi, floats.MulTo(shock, vol, dWVar))
+		for i, v := range variance {
+			if v < 0 {
+				variance[i] = 0
+			}
+		}
+	}
+
+	finalPrices := make([]float64, n)
+	for i := range finalPrices {
+		if jumps := poisson(rng, lambda*tau); jumps > 0 {
+			logPrice[i] += jump(rng, jumps)
+		}
+		finalPrices[i] = underlyingPrice * math.Exp(logPrice[i])
+	}
+	return pathBatch{finalPrices: finalPrices, brownian: brownian}
+}
+
+// fillNormals fills values with standard normal draws, with antithetic variates pairing each even index
+// with the negated draw at the next one.
+func fillNormals(rng *rand.Rand, values []float64, antithetic bool) {
+	if !antithetic {
+		for i := range values {
+			values[i] = rng.NormFloat64()
+		}
+		return
+	}
+	for i := 0; i < len(values); i += 2 {
+		values[i] = rng.NormFloat64()
+		if i+1 < len(values) {
+			values[i+1] = -values[i]
+		}
+	}
+}
+
+// poisson draws a Poisson count with the given mean by multiplying uniforms, fine for the few jumps
+// expected before an expiration.
+func poisson(rng *rand.Rand, mean float64) int {
+	if mean <= 0 {
+		return 0
+	}
+	limit := math.Exp(-mean)
+	count := 0
+	for product := rng.Float64(); product > limit; product *= rng.Float64() {
+		count++
+	}
+	return count
+}
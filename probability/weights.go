@@ -0,0 +1,126 @@
+package probability
+
+import (
+	"math"
+	"sync/atomic"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// mixedHorizonWeight is the weight of volatility inputs averaged over several horizons.
+const mixedHorizonWeight = 0.5
+
+// volatilityHorizons are the calendar days of history behind each historical volatility input.
+var volatilityHorizons = map[string]float64{
+	"YZ_1m": 30, "YZ_3m": 91, "YZ_6m": 182, "YZ_1y": 365,
+	"RS_1m": 30, "RS_3m": 91, "RS_6m": 182, "RS_1y": 365,
+	"Intraday": 7,
+}
+
+var mixedHorizonVolatilities = map[string]bool{
+	"YZ_avg": true, "RS_avg": true, "AvgYZ_RS": true, "TotalAvgVolSurface": true, "HestonModelVol": true, "Complete_AvgVol": true,
+}
+
+var equalWeighting atomic.Bool
+
+// WeightedAveraging reports whether the average probability weights each volatility/model simulation by
+// relevance rather than equally.
+func WeightedAveraging() bool {
+	return !equalWeighting.Load()
+}
+
+// SetWeightedAveraging switches between relevance weighted and equal averaging for spreads simulated afterwards.
+func SetWeightedAveraging(weighted bool) {
+	equalWeighting.Store(!weighted)
+}
+
+// ModelFitErrors measures how well each calibrated jump model reproduces the skewness and excess kurtosis
+// of the daily returns of prices, keyed by simulation name.
+func ModelFitErrors(globalModels GlobalModels, prices []float64) map[string]float64 {
+	const dt = 1.0 / 252
+	historical := models.HistoricalReturnMoments(prices)
+	errors := make(map[string]float64)
+	if globalModels.CGMY != nil {
+		errors["CGMY_Heston"] = models.MomentFitError(globalModels.CGMY.JumpCumulants(), historical, dt)
+	}
+	if globalModels.Merton != nil {
+		errors["Merton_Heston"] = models.MomentFitError(globalModels.Merton.JumpCumulants(), historical, dt)
+	}
+	if globalModels.Kou != nil {
+		errors["Kou_Heston"] = models.MomentFitError(globalModels.Kou.JumpCumulants(), historical, dt)
+	}
+	return errors
+}
+
+// poorFitWeight scales the weight of simulations of a model poorly fitted to option prices.
+const poorFitWeight = 0.25
+
+// PoorPricingFits returns the simulations of models poorly fitted to option prices, keyed by simulation
+// name. Heston drives the volatility of every simulation, so a poor Heston fit is reported but cannot
+// down-weight one simulation against the others.
+func PoorPricingFits(globalModels GlobalModels) map[string]bool {
+	return map[string]bool{"CGMY_Heston": globalModels.CGMYFit.Poor()}
+}
+
+// simulationWeight is the unnormalized weight of a volatility/model simulation. Historical volatilities
+// count by how close their horizon is to the spread's, as the ratio of the shorter to the longer; implied
+// volatilities count fully and averages over several horizons half. Models count inversely to one plus
+// their calibration fit error, and by poorFitWeight when poorly fitted to option prices.
+func simulationWeight(volName, simName string, daysToExpiration int, fitErrors map[string]float64, poorFits map[string]bool) float64 {
+	if !WeightedAveraging() {
+		return 1
+	}
+
+	weight := 1.0
+	if horizon, ok := volatilityHorizons[volName]; ok {
+		days := math.Max(float64(daysToExpiration), 1)
+		weight = math.Min(horizon/days, days/horizon)
+	} else if mixedHorizonVolatilities[volName] {
+		weight = mixedHorizonWeight
+	}
+	if fitError, ok := fitErrors[simName]; ok && !math.IsNaN(fitError) {
+		weight /= 1 + fitError
+	}
+	if poorFits[simName] {
+		weight *= poorFitWeight
+	}
+	return weight
+}
+
+// riskNeutralShare is the weight of the market-implied probability in the average when weighting by relevance.
+const riskNeutralShare = 0.2
+
+// withRiskNeutralWeight adds the market-implied probability's key to normalized weights, scaling the others
+// so they still sum to one. It takes riskNeutralShare of the average, or an equal share when averaging
+// equally, and all of it when nothing was simulated.
+func withRiskNeutralWeight(weights map[string]float64, key string) map[string]float64 {
+	share := riskNeutralShare
+	switch {
+	case len(weights) == 0:
+		share = 1
+	case !WeightedAveraging():
+		share = 1 / float64(len(weights)+1)
+	}
+	for k := range weights {
+		weights[k] *= 1 - share
+	}
+	weights[key] = share
+	return weights
+}
+
+// normalizeWeights scales the weights to sum to one, falling back to equal weights if they sum to zero.
+func normalizeWeights(weights map[string]float64) map[string]float64 {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	normalized := make(map[string]float64, len(weights))
+	for key, w := range weights {
+		if total > 0 {
+			normalized[key] = w / total
+		} else {
+			normalized[key] = 1 / float64(len(weights))
+		}
+	}
+	return normalized
+}
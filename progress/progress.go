@@ -0,0 +1,175 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tracker counts the progress of one scan. Updates are atomic and never block, so the scan does not
+// depend on anyone reading its progress. Every method is a no-op on a nil Tracker.
+type Tracker struct {
+	name    string
+	started time.Time
+
+	mu         sync.Mutex
+	stage      string
+	stageStart time.Time
+
+	total    atomic.Int64
+	done     atomic.Int64
+	finished atomic.Bool
+}
+
+// Snapshot is the state of a tracker at one point in time.
+type Snapshot struct {
+	Name     string        `json:"name"`
+	Stage    string        `json:"stage"`
+	Done     int           `json:"done"`
+	Total    int           `json:"total"`
+	Percent  float64       `json:"percent"`
+	Rate     float64       `json:"rate"` // Items per second in the current stage
+	Elapsed  time.Duration `json:"elapsed_ns"`
+	ETA      time.Duration `json:"eta_ns"` // Estimated time left in the current stage, 0 when unknown
+	Finished bool          `json:"finished"`
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[*Tracker]struct{})
+)
+
+// Start creates a tracker and registers it with the active scans until it finishes.
+func Start(name string) *Tracker {
+	now := time.Now()
+	t := &Tracker{name: name, started: now, stage: "starting", stageStart: now}
+	registryMu.Lock()
+	registry[t] = struct{}{}
+	registryMu.Unlock()
+	return t
+}
+
+// SetStage starts a new stage of total items, resetting the count.
+func (t *Tracker) SetStage(stage string, total int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.stage, t.stageStart = stage, time.Now()
+	t.mu.Unlock()
+	t.total.Store(int64(total))
+	t.done.Store(0)
+}
+
+// Add counts n more items done in the current stage.
+func (t *Tracker) Add(n int) {
+	if t == nil {
+		return
+	}
+	t.done.Add(int64(n))
+}
+
+// Finish marks the scan complete and removes it from the active scans.
+func (t *Tracker) Finish() {
+	if t == nil {
+		return
+	}
+	t.finished.Store(true)
+	registryMu.Lock()
+	delete(registry, t)
+	registryMu.Unlock()
+}
+
+// Snapshot returns the tracker's current state.
+func (t *Tracker) Snapshot() Snapshot {
+	if t == nil {
+		return Snapshot{}
+	}
+	t.mu.Lock()
+	stage, stageStart := t.stage, t.stageStart
+	t.mu.Unlock()
+
+	s := Snapshot{
+		Name:     t.name,
+		Stage:    stage,
+		Done:     int(t.done.Load()),
+		Total:    int(t.total.Load()),
+		Elapsed:  time.Since(t.started),
+		Finished: t.finished.Load(),
+	}
+	if s.Total > 0 {
+		s.Percent = 100 * float64(s.Done) / float64(s.Total)
+	}
+	if seconds := time.Since(stageStart).Seconds(); seconds > 0 && s.Done > 0 {
+		s.Rate = float64(s.Done) / seconds
+		s.ETA = time.Duration(float64(s.Total-s.Done) / s.Rate * float64(time.Second))
+	}
+	return s
+}
+
+// Watch calls report with a snapshot every interval until the tracker finishes or stop is closed, then
+// once more with the final state. It runs in the caller's goroutine.
+func (t *Tracker) Watch(interval time.Duration, stop <-chan struct{}, report func(Snapshot)) {
+	if t == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if t.finished.Load() {
+			break
+		}
+		report(t.Snapshot())
+	}
+	report(t.Snapshot())
+}
+
+// String describes the snapshot, e.g. "simulating 120/200 (60%), 14.2/s, ETA 6s".
+func (s Snapshot) String() string {
+	msg := fmt.Sprintf("%s %d/%d (%.0f%%)", s.Stage, s.Done, s.Total, s.Percent)
+	if s.Rate > 0 {
+		msg += fmt.Sprintf(", %.1f/s", s.Rate)
+	}
+	if eta := s.ETA.Round(time.Second); eta > 0 && !s.Finished {
+		msg += fmt.Sprintf(", ETA %s", eta)
+	}
+	return msg
+}
+
+// Active returns snapshots of the scans in progress, oldest first.
+func Active() []Snapshot {
+	registryMu.Lock()
+	trackers := make([]*Tracker, 0, len(registry))
+	for t := range registry {
+		trackers = append(trackers, t)
+	}
+	registryMu.Unlock()
+
+	sort.Slice(trackers, func(i, j int) bool {
+		return trackers[i].started.Before(trackers[j].started)
+	})
+	snapshots := make([]Snapshot, len(trackers))
+	for i, t := range trackers {
+		snapshots[i] = t.Snapshot()
+	}
+	return snapshots
+}
+
+// Handler serves the active scans as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{"scans": Active()}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
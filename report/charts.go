@@ -0,0 +1,402 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	chartWidth   = 640
+	chartHeight  = 260
+	chartPadding = 44
+	payoffPoints = 120
+)
+
+var palette = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// chart is a pre-scaled SVG chart rendered by the "chart" template.
+type chart struct {
+	Title  string
+	Width  int
+	Height int
+	Lines  []chartLine
+	Bars   []chartBar
+	Marks  []chartMark
+	XTicks []chartTick
+	YTicks []chartTick
+	Legend []chartLegend
+}
+
+type chartLine struct {
+	Points string
+	Color  string
+}
+
+type chartBar struct {
+	X, Y, W, H float64
+	Color      string
+}
+
+// chartMark is a labelled vertical or horizontal reference line.
+type chartMark struct {
+	X1, Y1, X2, Y2 float64
+	Label          string
+	LabelX, LabelY float64
+	Color          string
+}
+
+type chartTick struct {
+	Pos   float64
+	Label string
+}
+
+type chartLegend struct {
+	Label string
+	Color string
+	Y     float64
+}
+
+// scale maps data coordinates onto the plot area.
+type scale struct {
+	xMin, xMax, yMin, yMax float64
+}
+
+func (s scale) x(v float64) float64 {
+	return chartPadding + (v-s.xMin)/(s.xMax-s.xMin)*(chartWidth-2*chartPadding)
+}
+
+func (s scale) y(v float64) float64 {
+	return chartHeight - chartPadding - (v-s.yMin)/(s.yMax-s.yMin)*(chartHeight-2*chartPadding)
+}
+
+func (s scale) ticks(f Formatter, decimals int) ([]chartTick, []chartTick) {
+	var xTicks, yTicks []chartTick
+	for i := 0; i <= 5; i++ {
+		xv := s.xMin + float64(i)/5*(s.xMax-s.xMin)
+		yv := s.yMin + float64(i)/5*(s.yMax-s.yMin)
+		xTicks = append(xTicks, chartTick{Pos: s.x(xv), Label: f.Number(xv, 2)})
+		yTicks = append(yTicks, chartTick{Pos: s.y(yv), Label: f.Number(yv, decimals)})
+	}
+	return xTicks, yTicks
+}
+
+func (s scale) vertical(v float64, label, color string) chartMark {
+	return chartMark{X1: s.x(v), Y1: chartPadding, X2: s.x(v), Y2: chartHeight - chartPadding, Label: label, LabelX: s.x(v) + 3, LabelY: chartPadding + 10, Color: color}
+}
+
+func (s scale) points(xs, ys []float64) string {
+	var b strings.Builder
+	for i := range xs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%.1f,%.1f", s.x(xs[i]), s.y(ys[i]))
+	}
+	return b.String()
+}
+
+func newChart(title string) chart {
+	return chart{Title: title, Width: chartWidth, Height: chartHeight}
+}
+
+// payoffChart plots the spread's P&L at expiration and, when the scan sampled it, halfway to
+// expiration across underlying prices around its strikes.
+func payoffChart(spread models.SpreadWithProbabilities, underlyingPrice float64, f Formatter) chart {
+	curve := spread.PayoffCurve
+	if len(curve.Prices) == 0 {
+		curve = expirationPayoff(spread.Spread, underlyingPrice)
+	}
+
+	xs := curve.Prices
+	yMin, yMax := 0.0, 0.0
+	for _, ys := range [][]float64{curve.AtExpiration, curve.AtHalfLife} {
+		for _, y := range ys {
+			yMin = math.Min(yMin, y)
+			yMax = math.Max(yMax, y)
+		}
+	}
+	if yMax == yMin {
+		yMax = yMin + 1
+	}
+	margin := (yMax - yMin) * 0.1
+	s := scale{xMin: xs[0], xMax: xs[len(xs)-1], yMin: yMin - margin, yMax: yMax + margin}
+
+	c := newChart("Payoff (per share)")
+	c.Lines = []chartLine{{Points: s.points(xs, curve.AtExpiration), Color: palette[0]}}
+	c.Legend = []chartLegend{{Label: "At expiration", Color: palette[0], Y: chartPadding}}
+	if len(curve.AtHalfLife) == len(xs) {
+		c.Lines = append(c.Lines, chartLine{Points: s.points(xs, curve.AtHalfLife), Color: palette[1]})
+		c.Legend = append(c.Legend, chartLegend{Label: "Halfway to expiration", Color: palette[1], Y: chartPadding + 12})
+	}
+	c.Marks = []chartMark{
+		{X1: chartPadding, Y1: s.y(0), X2: chartWidth - chartPadding, Y2: s.y(0), Color: "#999"},
+		s.vertical(underlyingPrice, "Spot "+f.Number(underlyingPrice, 2), palette[2]),
+		s.vertical(spread.Breakeven.Price, "Breakeven "+f.Number(spread.Breakeven.Price, 2), palette[3]),
+	}
+	c.XTicks, c.YTicks = s.ticks(f, 2)
+	return c
+}
+
+// deltaChart plots the position delta halfway to expiration across the payoff grid.
+func deltaChart(spread models.SpreadWithProbabilities, underlyingPrice float64, f Formatter) (chart, bool) {
+	curve := spread.PayoffCurve
+	if len(curve.HalfLifeDelta) == 0 || len(curve.HalfLifeDelta) != len(curve.Prices) {
+		return chart{}, false
+	}
+
+	yMin, yMax := 0.0, 0.0
+	for _, delta := range curve.HalfLifeDelta {
+		yMin = math.Min(yMin, delta)
+		yMax = math.Max(yMax, delta)
+	}
+	if yMax == yMin {
+		yMax = yMin + 1
+	}
+	margin := (yMax - yMin) * 0.1
+	s := scale{xMin: curve.Prices[0], xMax: curve.Prices[len(curve.Prices)-1], yMin: yMin - margin, yMax: yMax + margin}
+
+	c := newChart("Delta halfway to expiration (per share)")
+	c.Lines = []chartLine{{Points: s.points(curve.Prices, curve.HalfLifeDelta), Color: palette[4]}}
+	c.Marks = []chartMark{
+		{X1: chartPadding, Y1: s.y(0), X2: chartWidth - chartPadding, Y2: s.y(0), Color: "#999"},
+		s.vertical(underlyingPrice, "Spot", palette[2]),
+	}
+	c.XTicks, c.YTicks = s.ticks(f, 3)
+	return c, true
+}
+
+// expirationPayoff samples the expiration payoff for spreads scanned without a payoff curve.
+func expirationPayoff(spread models.OptionSpread, underlyingPrice float64) models.PayoffCurve {
+	short := spread.ShortLeg.Option.Strike
+	long := spread.LongLeg.Option.Strike
+	width := math.Abs(short - long)
+	if models.IsSingleLeg(spread) {
+		long = short
+		width = 0.2 * short
+	}
+	if models.IsStrangle(spread) {
+		long = spread.CallLeg.Option.Strike
+		width = math.Max(long-short, 0.1*short)
+	}
+	if width == 0 {
+		width = 1
+	}
+
+	lo := math.Min(math.Min(short, long)-1.5*width, underlyingPrice)
+	hi := math.Max(math.Max(short, long)+1.5*width, underlyingPrice)
+
+	curve := models.PayoffCurve{Prices: make([]float64, payoffPoints+1), AtExpiration: make([]float64, payoffPoints+1)}
+	for i := range curve.Prices {
+		curve.Prices[i] = lo + float64(i)/payoffPoints*(hi-lo)
+		curve.AtExpiration[i] = models.PayoffAtExpiration(spread, curve.Prices[i])
+	}
+	return curve
+}
+
+// histogramChart plots the distribution of simulated prices at expiration with the strikes marked.
+func histogramChart(spread models.SpreadWithProbabilities, underlyingPrice float64, f Formatter) (chart, bool) {
+	hist := spread.PriceDistribution
+	if len(hist.Counts) == 0 {
+		return chart{}, false
+	}
+
+	total, maxCount := 0, 0
+	for _, count := range hist.Counts {
+		total += count
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+	if total == 0 {
+		return chart{}, false
+	}
+
+	s := scale{xMin: hist.Edges[0], xMax: hist.Edges[len(hist.Edges)-1], yMin: 0, yMax: float64(maxCount) / float64(total) * 1.1}
+
+	c := newChart("Simulated price at expiration")
+	for i, count := range hist.Counts {
+		share := float64(count) / float64(total)
+		x0, x1 := s.x(hist.Edges[i]), s.x(hist.Edges[i+1])
+		color := palette[0]
+		if !models.IsProfitable(spread.Spread, (hist.Edges[i]+hist.Edges[i+1])/2) {
+			color = palette[3]
+		}
+		c.Bars = append(c.Bars, chartBar{X: x0, Y: s.y(share), W: math.Max(x1-x0-1, 1), H: s.y(0) - s.y(share), Color: color})
+	}
+
+	for _, mark := range []struct {
+		value float64
+		label string
+		color string
+	}{
+		{underlyingPrice, "Spot", palette[2]},
+		{spread.Spread.ShortLeg.Option.Strike, "Short", palette[1]},
+		{spread.Spread.LongLeg.Option.Strike, "Long", palette[4]},
+		{spread.Spread.CallLeg.Option.Strike, "Call", palette[4]},
+	} {
+		if mark.value > 0 && mark.value >= s.xMin && mark.value <= s.xMax {
+			c.Marks = append(c.Marks, s.vertical(mark.value, mark.label, mark.color))
+		}
+	}
+
+	c.XTicks, c.YTicks = s.ticks(f, 3)
+	for i := range c.YTicks {
+		c.YTicks[i].Label = f.Percent(s.yMin+float64(i)/5*(s.yMax-s.yMin), 1)
+	}
+	return c, true
+}
+
+// surfaceChart plots the implied volatility smile of each expiration, limited to strikes within
+// 30% of spot, as a flattened view of the volatility surface.
+func surfaceChart(chain map[string]*tradier.OptionChain, underlyingPrice float64, f Formatter) (chart, bool) {
+	expirations := make([]string, 0, len(chain))
+	for expiration := range chain {
+		expirations = append(expirations, expiration)
+	}
+	sort.Strings(expirations)
+
+	type smile struct {
+		expiration string
+		strikes    []float64
+		vols       []float64
+	}
+
+	var smiles []smile
+	s := scale{xMin: math.Inf(1), xMax: math.Inf(-1), yMin: math.Inf(1), yMax: math.Inf(-1)}
+	for _, expiration := range expirations {
+		byStrike := make(map[float64][]float64)
+		for _, option := range chain[expiration].Options.Option {
+			iv := (option.Greeks.BidIv + option.Greeks.AskIv) / 2
+			if iv <= 0 || math.Abs(option.Strike-underlyingPrice) > 0.3*underlyingPrice {
+				continue
+			}
+			byStrike[option.Strike] = append(byStrike[option.Strike], iv)
+		}
+		if len(byStrike) < 2 {
+			continue
+		}
+
+		sm := smile{expiration: expiration}
+		for strike := range byStrike {
+			sm.strikes = append(sm.strikes, strike)
+		}
+		sort.Float64s(sm.strikes)
+		for _, strike := range sm.strikes {
+			vol := 0.0
+			for _, iv := range byStrike[strike] {
+				vol += iv
+			}
+			vol /= float64(len(byStrike[strike]))
+			sm.vols = append(sm.vols, vol)
+
+			s.xMin, s.xMax = math.Min(s.xMin, strike), math.Max(s.xMax, strike)
+			s.yMin, s.yMax = math.Min(s.yMin, vol), math.Max(s.yMax, vol)
+		}
+		smiles = append(smiles, sm)
+	}
+	if len(smiles) == 0 || s.xMax == s.xMin || s.yMax == s.yMin {
+		return chart{}, false
+	}
+
+	c := newChart("Implied volatility by strike and expiration")
+	for i, sm := range smiles {
+		color := palette[i%len(palette)]
+		c.Lines = append(c.Lines, chartLine{Points: s.points(sm.strikes, sm.vols), Color: color})
+		c.Legend = append(c.Legend, chartLegend{Label: sm.expiration, Color: color, Y: float64(chartPadding + 12*i)})
+	}
+	if underlyingPrice >= s.xMin && underlyingPrice <= s.xMax {
+		c.Marks = append(c.Marks, s.vertical(underlyingPrice, "Spot", "#999"))
+	}
+
+	c.XTicks, c.YTicks = s.ticks(f, 2)
+	for i := range c.YTicks {
+		c.YTicks[i].Label = f.Percent(s.yMin+float64(i)/5*(s.yMax-s.yMin), 0)
+	}
+	return c, true
+}
+
+// coneChart plots the cone's minimum, median and maximum realized volatility by window length, with the
+// latest realized volatility and the at-the-money implied volatility of the same horizons.
+func coneChart(cone models.VolCone, term models.TermStructure, f Formatter) (chart, bool) {
+	if len(cone.Windows) < 2 {
+		return chart{}, false
+	}
+
+	comparisons := models.CompareIV(cone, term)
+	days := make([]float64, len(cone.Windows))
+	series := [][]float64{make([]float64, len(days)), make([]float64, len(days)), make([]float64, len(days)), make([]float64, len(days))}
+	s := scale{xMin: float64(cone.Windows[0].Days), xMax: float64(cone.Windows[len(cone.Windows)-1].Days), yMin: math.Inf(1), yMax: math.Inf(-1)}
+	for i, window := range cone.Windows {
+		days[i] = float64(window.Days)
+		series[0][i], series[1][i], series[2][i], series[3][i] = window.Min, window.Median, window.Max, window.Current
+		s.yMin, s.yMax = math.Min(s.yMin, window.Min), math.Max(s.yMax, window.Max)
+		if comparisons != nil {
+			s.yMin, s.yMax = math.Min(s.yMin, comparisons[i].IV), math.Max(s.yMax, comparisons[i].IV)
+		}
+	}
+	if s.yMax == s.yMin {
+		s.yMax = s.yMin + 0.01
+	}
+	margin := (s.yMax - s.yMin) * 0.1
+	s.yMin, s.yMax = math.Max(s.yMin-margin, 0), s.yMax+margin
+
+	labels := []string{"Minimum", "Median", "Maximum", "Current"}
+	colors := []string{palette[0], palette[7], palette[3], palette[2]}
+	if comparisons != nil {
+		ivs := make([]float64, len(comparisons))
+		for i, c := range comparisons {
+			ivs[i] = c.IV
+		}
+		series = append(series, ivs)
+		labels = append(labels, "Implied")
+		colors = append(colors, palette[1])
+	}
+
+	c := newChart(cone.Estimator + " volatility cone by window (trading days)")
+	for i, ys := range series {
+		c.Lines = append(c.Lines, chartLine{Points: s.points(days, ys), Color: colors[i]})
+		c.Legend = append(c.Legend, chartLegend{Label: labels[i], Color: colors[i], Y: float64(chartPadding + 12*i)})
+	}
+	_, c.YTicks = s.ticks(f, 2)
+	for i := range c.YTicks {
+		c.YTicks[i].Label = f.Percent(s.yMin+float64(i)/5*(s.yMax-s.yMin), 0)
+	}
+	for _, window := range cone.Windows {
+		c.XTicks = append(c.XTicks, chartTick{Pos: s.x(float64(window.Days)), Label: fmt.Sprintf("%d", window.Days)})
+	}
+	return c, true
+}
+
+// coneTable returns the estimators and, for each window of the first cone, the implied volatility of its
+// horizon with its percentile and verdict within each estimator's cone.
+func coneTable(cones []models.VolCone, term models.TermStructure, f Formatter) ([]string, []coneRow) {
+	if len(term) == 0 {
+		return nil, nil
+	}
+	var estimators []string
+	for _, cone := range cones {
+		estimators = append(estimators, cone.Estimator)
+	}
+
+	var rows []coneRow
+	for _, comparison := range models.CompareIV(cones[0], term) {
+		row := coneRow{Days: comparison.Window.Days, IV: comparison.IV}
+		for _, cone := range cones {
+			cell := "-"
+			for _, window := range cone.Windows {
+				if window.Days == comparison.Window.Days {
+					c := models.IVComparison{Window: window, IV: comparison.IV, Percentile: window.Percentile(comparison.IV)}
+					cell = fmt.Sprintf("%s (%s)", f.Percent(c.Percentile, 0), c.Verdict())
+				}
+			}
+			row.Percentiles = append(row.Percentiles, cell)
+		}
+		rows = append(rows, row)
+	}
+	return estimators, rows
+}
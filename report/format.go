@@ -0,0 +1,115 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+)
+
+// locale describes how numbers and timestamps are written for a reader.
+type locale struct {
+	decimal   string
+	thousands string
+	datetime  string
+}
+
+var locales = map[string]locale{
+	"en-US": {decimal: ".", thousands: ",", datetime: "01/02/2006 3:04 PM MST"},
+	"en-GB": {decimal: ".", thousands: ",", datetime: "02/01/2006 15:04 MST"},
+	"de-DE": {decimal: ",", thousands: ".", datetime: "02.01.2006 15:04 MST"},
+	"fr-FR": {decimal: ",", thousands: " ", datetime: "02/01/2006 15:04 MST"},
+	"es-ES": {decimal: ",", thousands: ".", datetime: "02/01/2006 15:04 MST"},
+	"it-IT": {decimal: ",", thousands: ".", datetime: "02/01/2006 15:04 MST"},
+	"ja-JP": {decimal: ".", thousands: ",", datetime: "2006/01/02 15:04 MST"},
+	"zh-CN": {decimal: ".", thousands: ",", datetime: "2006-01-02 15:04 MST"},
+}
+
+// Formatter renders numbers and timestamps in a report's locale and timezone. The zero
+// value formats like en-US in the market timezone.
+type Formatter struct {
+	Locale   string
+	Location *time.Location
+}
+
+// NewFormatter validates a locale (e.g. "de-DE") and IANA timezone (e.g. "Europe/Berlin").
+// Empty values fall back to en-US and the market timezone.
+func NewFormatter(localeName, timezone string) (Formatter, error) {
+	formatter := Formatter{Locale: "en-US", Location: market.Location}
+
+	if localeName != "" {
+		if _, ok := locales[localeName]; !ok {
+			return formatter, fmt.Errorf("unsupported locale %q", localeName)
+		}
+		formatter.Locale = localeName
+	}
+
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return formatter, fmt.Errorf("failed to load timezone %q: %s", timezone, err)
+		}
+		formatter.Location = location
+	}
+
+	return formatter, nil
+}
+
+func (f Formatter) locale() locale {
+	if l, ok := locales[f.Locale]; ok {
+		return l
+	}
+	return locales["en-US"]
+}
+
+// Number formats v with the given number of decimals and locale separators.
+func (f Formatter) Number(v float64, decimals int) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', decimals, 64)
+	}
+
+	l := f.locale()
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	integer, fraction, _ := strings.Cut(s, ".")
+
+	var out strings.Builder
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		out.WriteByte('-')
+	}
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			out.WriteString(l.thousands)
+		}
+		out.WriteRune(digit)
+	}
+	if fraction != "" {
+		out.WriteString(l.decimal)
+		out.WriteString(fraction)
+	}
+	return out.String()
+}
+
+// Signed formats v like Number with an explicit sign.
+func (f Formatter) Signed(v float64, decimals int) string {
+	if v >= 0 {
+		return "+" + f.Number(v, decimals)
+	}
+	return f.Number(v, decimals)
+}
+
+// Percent formats a fraction (0.25) as a percentage ("25.00%").
+func (f Formatter) Percent(v float64, decimals int) string {
+	return f.Number(v*100, decimals) + "%"
+}
+
+// Time formats t in the report timezone.
+func (f Formatter) Time(t time.Time) string {
+	location := f.Location
+	if location == nil {
+		location = market.Location
+	}
+	return t.In(location).Format(f.locale().datetime)
+}
@@ -0,0 +1,174 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// Report is the input for an HTML scan report.
+type Report struct {
+	Symbol          string
+	GeneratedAt     time.Time
+	UnderlyingPrice float64
+	Spreads         []models.SpreadWithProbabilities // Ranked spreads to include
+	Chain           map[string]*tradier.OptionChain  // Options chain the scan used, for the volatility surface
+	Cones           []models.VolCone                 // Realized volatility cones compared with the chain's implied volatility, nil for none
+}
+
+type spreadSection struct {
+	Rank      int
+	Spread    models.SpreadWithProbabilities
+	Payoff    chart
+	Histogram chart
+	HasHist   bool
+	Delta     chart
+	HasDelta  bool
+}
+
+type reportPage struct {
+	Report
+	Format     Formatter
+	Generated  string
+	Sections   []spreadSection
+	Surface    chart
+	HasSurface bool
+	Cone       chart
+	HasCone    bool
+	ConeRows   []coneRow
+	Estimators []string
+}
+
+// coneRow is a cone window's implied volatility and its percentile within each estimator's cone.
+type coneRow struct {
+	Days        int
+	IV          float64
+	Percentiles []string
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"num":        func(f Formatter, v float64) string { return f.Number(v, 2) },
+	"pct":        func(f Formatter, v float64) string { return f.Percent(v, 2) },
+	"usd":        func(f Formatter, v float64) string { return "$" + f.Number(v, 2) },
+	"settlement": models.SettlementNote,
+}).Parse(reportHTML))
+
+// RenderHTML writes a self-contained HTML report with inline SVG charts.
+func RenderHTML(w io.Writer, r Report, f Formatter) error {
+	page := reportPage{Report: r, Format: f, Generated: f.Time(r.GeneratedAt)}
+	for i, spread := range r.Spreads {
+		section := spreadSection{Rank: i + 1, Spread: spread, Payoff: payoffChart(spread, r.UnderlyingPrice, f)}
+		section.Histogram, section.HasHist = histogramChart(spread, r.UnderlyingPrice, f)
+		section.Delta, section.HasDelta = deltaChart(spread, r.UnderlyingPrice, f)
+		page.Sections = append(page.Sections, section)
+	}
+	page.Surface, page.HasSurface = surfaceChart(r.Chain, r.UnderlyingPrice, f)
+	if len(r.Cones) > 0 {
+		term := models.ATMTermStructure(r.Chain, r.UnderlyingPrice, r.GeneratedAt)
+		page.Cone, page.HasCone = coneChart(r.Cones[0], term, f)
+		page.Estimators, page.ConeRows = coneTable(r.Cones, term, f)
+	}
+
+	if err := reportTemplate.Execute(w, page); err != nil {
+		return fmt.Errorf("failed to render report: %s", err)
+	}
+	return nil
+}
+
+// HTML renders the report into memory, e.g. for attaching to a message.
+func HTML(r Report, f Formatter) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, r, f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="{{.Format.Locale}}">
+<head>
+<meta charset="utf-8">
+<title>STOC'D report: {{.Symbol}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: right; }
+th { background: #f4f4f4; }
+td.text, th.text { text-align: left; }
+section { margin-bottom: 2.5em; }
+.charts { display: flex; flex-wrap: wrap; gap: 1em; }
+svg text { font-size: 10px; fill: #444; }
+</style>
+</head>
+<body>
+<h1>{{.Symbol}} credit spreads</h1>
+<p>Generated {{.Generated}}. Underlying price {{num .Format .UnderlyingPrice}}.</p>
+
+<table>
+<tr><th>#</th><th class="text">Type</th><th class="text">Expiration</th><th class="text">Short</th><th class="text">Long</th><th>Credit</th><th>ROR</th><th>PoP</th><th>EV</th><th>Breakeven</th><th>VaR 95% / contract</th><th>Score</th></tr>
+{{- range .Sections}}
+<tr><td><a href="#spread-{{.Rank}}">{{.Rank}}</a></td><td class="text">{{.Spread.Spread.SpreadType}}</td><td class="text">{{.Spread.Spread.ShortLeg.Option.ExpirationDate}}</td><td class="text">{{.Spread.Spread.ShortLeg.Option.Symbol}}</td><td class="text">{{.Spread.Spread.LongLeg.Option.Symbol}}</td><td>{{num $.Format .Spread.Spread.SpreadCredit}}</td><td>{{pct $.Format .Spread.Spread.ROR}}</td><td>{{pct $.Format .Spread.Probability.AverageProbability}}</td><td>{{num $.Format .Spread.ExpectedValue}}</td><td>{{num $.Format .Spread.Breakeven.Price}}</td><td>{{usd $.Format .Spread.Dollars.VaR95}}</td><td>{{num $.Format .Spread.CompositeScore}}</td></tr>
+{{- end}}
+</table>
+
+{{if .HasSurface}}<section>
+<h2>Volatility surface</h2>
+{{template "chart" .Surface}}
+</section>{{end}}
+
+{{if .HasCone}}<section>
+<h2>Volatility cone</h2>
+{{template "chart" .Cone}}
+{{if .ConeRows}}<table>
+<tr><th>Window</th><th>Implied volatility</th>{{range .Estimators}}<th>{{.}} percentile</th>{{end}}</tr>
+{{- range .ConeRows}}
+<tr><td>{{.Days}} days</td><td>{{pct $.Format .IV}}</td>{{range .Percentiles}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+<p>Percentiles are the share of each window's realized volatilities below the implied volatility of the same horizon.</p>{{end}}
+</section>{{end}}
+
+{{range .Sections}}<section id="spread-{{.Rank}}">
+<h2>Spread {{.Rank}}: {{.Spread.Spread.ShortLeg.Option.Symbol}} / {{.Spread.Spread.LongLeg.Option.Symbol}}</h2>
+<p>{{.Spread.Spread.SpreadType}} expiring {{.Spread.Spread.ShortLeg.Option.ExpirationDate}}: credit {{num $.Format .Spread.Spread.SpreadCredit}}, probability of profit {{pct $.Format .Spread.Probability.AverageProbability}}, max profit {{usd $.Format .Spread.Dollars.MaxProfit}} and max loss {{if .Spread.Dollars.UnboundedLoss}}unbounded{{else}}{{usd $.Format .Spread.Dollars.MaxLoss}}{{end}} per contract, expected shortfall {{usd $.Format .Spread.Dollars.ExpectedShortfall}} per contract, breakeven {{num $.Format .Spread.Breakeven.Price}} ({{pct $.Format .Spread.Breakeven.DistancePct}} from spot).</p>
+{{with settlement .Spread.Spread}}<p>Index options: {{.}}.</p>{{end}}
+{{if .Spread.Quotes.Stale}}<p>Stale quotes: last quoted {{.Spread.Quotes.QuotedAt.Format "Jan 2 15:04:05"}}; check the market before trading.</p>{{end}}
+<div class="charts">
+{{template "chart" .Payoff}}
+{{if .HasHist}}{{template "chart" .Histogram}}{{end}}
+{{if .HasDelta}}{{template "chart" .Delta}}{{end}}
+</div>
+</section>
+{{end}}
+</body>
+</html>
+
+{{define "chart"}}<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}">
+<text x="{{.Width}}" y="14" text-anchor="end" style="font-size:12px">{{.Title}}</text>
+{{- range .Bars}}
+<rect x="{{printf "%.1f" .X}}" y="{{printf "%.1f" .Y}}" width="{{printf "%.1f" .W}}" height="{{printf "%.1f" .H}}" fill="{{.Color}}" fill-opacity="0.7"/>
+{{- end}}
+{{- range .Lines}}
+<polyline points="{{.Points}}" fill="none" stroke="{{.Color}}" stroke-width="1.5"/>
+{{- end}}
+{{- range .Marks}}
+<line x1="{{printf "%.1f" .X1}}" y1="{{printf "%.1f" .Y1}}" x2="{{printf "%.1f" .X2}}" y2="{{printf "%.1f" .Y2}}" stroke="{{.Color}}" stroke-dasharray="4 3"/>
+{{- if .Label}}<text x="{{printf "%.1f" .LabelX}}" y="{{printf "%.1f" .LabelY}}">{{.Label}}</text>{{end}}
+{{- end}}
+{{- range .XTicks}}
+<text x="{{printf "%.1f" .Pos}}" y="{{$.Height}}" dy="-28" text-anchor="middle">{{.Label}}</text>
+{{- end}}
+{{- range .YTicks}}
+<text x="40" y="{{printf "%.1f" .Pos}}" text-anchor="end" dy="3">{{.Label}}</text>
+{{- end}}
+{{- range .Legend}}
+<rect x="{{$.Width}}" y="{{printf "%.1f" .Y}}" width="8" height="8" fill="{{.Color}}" transform="translate(-130,0)"/>
+<text x="{{$.Width}}" y="{{printf "%.1f" .Y}}" dy="8" transform="translate(-118,0)">{{.Label}}</text>
+{{- end}}
+</svg>{{end}}
+`
@@ -0,0 +1,116 @@
+package results
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindFloat
+	kindInt
+)
+
+// column describes one flattened field of a spread result shared by the tabular exporters.
+type column struct {
+	name  string
+	kind  columnKind
+	value func(models.SpreadWithProbabilities) interface{}
+}
+
+var columns = []column{
+	{"underlying", kindString, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.ShortLeg.Option.Underlying }},
+	{"expiration", kindString, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.ShortLeg.Option.ExpirationDate }},
+	{"spread_type", kindString, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.SpreadType }},
+	{"short_symbol", kindString, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.ShortLeg.Option.Symbol }},
+	{"long_symbol", kindString, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.LongLeg.Option.Symbol }},
+	{"short_strike", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.ShortLeg.Option.Strike }},
+	{"long_strike", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.LongLeg.Option.Strike }},
+	{"credit", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.SpreadCredit }},
+	{"credit_adjustment", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.CreditAdjustment }},
+	{"credit_width_ratio", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.CreditWidthRatio }},
+	{"ror", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.ROR }},
+	{"bsm_price", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.SpreadBSMPrice }},
+	{"probability", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Probability.AverageProbability }},
+	{"market_probability", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Probability.MarketImplied }},
+	{"bootstrap_probability", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Probability.Bootstrap }},
+	{"probability_standard_error", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Probability.StandardError }},
+	{"probability_ci_lower", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Probability.AverageInterval.Lower }},
+	{"probability_ci_upper", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Probability.AverageInterval.Upper }},
+	{"expected_value", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.ExpectedValue }},
+	{"expected_profit", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.ExpectedProfit }},
+	{"var95", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.VaR95 }},
+	{"var99", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.VaR99 }},
+	{"expected_shortfall", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.ExpectedShortfall }},
+	{"worst_scenario_loss", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.WorstScenarioLoss }},
+	{"breakeven", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Breakeven.Price }},
+	{"breakeven_distance_pct", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Breakeven.DistancePct }},
+	{"breakeven_distance_sd", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Breakeven.DistanceSD }},
+	{"liquidity", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Liquidity }},
+	{"variance_risk_premium", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.VarianceRiskPremium }},
+	{"composite_score", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.CompositeScore }},
+	{"delta", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.Greeks.Delta }},
+	{"gamma", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.Greeks.Gamma }},
+	{"theta", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.Greeks.Theta }},
+	{"vega", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.Greeks.Vega }},
+	{"theta_per_day", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.Decay.ThetaPerDay }},
+	{"theta_vega_ratio", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.Decay.ThetaVegaRatio }},
+	{"theta_credit_ratio", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.Decay.ThetaCreditRatio }},
+	{"short_volume", kindInt, func(s models.SpreadWithProbabilities) interface{} { return int64(s.Spread.ShortLeg.Option.Volume) }},
+	{"long_volume", kindInt, func(s models.SpreadWithProbabilities) interface{} { return int64(s.Spread.LongLeg.Option.Volume) }},
+	{"short_open_interest", kindInt, func(s models.SpreadWithProbabilities) interface{} {
+		return int64(s.Spread.ShortLeg.Option.OpenInterest)
+	}},
+	{"long_open_interest", kindInt, func(s models.SpreadWithProbabilities) interface{} { return int64(s.Spread.LongLeg.Option.OpenInterest) }},
+	{"call_symbol", kindString, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.CallLeg.Option.Symbol }},
+	{"call_strike", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.CallLeg.Option.Strike }},
+	{"margin", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.Margin }},
+	{"expected_move", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.ExpectedMove }},
+	{"upper_breakeven", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Breakeven.UpperPrice }},
+	{"expected_shortfall99", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.ExpectedShortfall99 }},
+	{"short_quantity", kindInt, func(s models.SpreadWithProbabilities) interface{} { return int64(models.ShortContracts(s.Spread)) }},
+	{"buying_power", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.BuyingPower }},
+	{"return_on_margin", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.ReturnOnMargin }},
+	{"portfolio_margin", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.PortfolioMargin }},
+	{"fees", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.Fees }},
+	{"fill_adjustment", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.FillAdjustment }},
+	{"short_strike_moves", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Spread.ShortStrikeMoves }},
+	{"dollar_max_profit", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Dollars.MaxProfit }},
+	{"dollar_max_loss", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Dollars.MaxLoss }},
+	{"unbounded_loss", kindInt, func(s models.SpreadWithProbabilities) interface{} { return boolInt(s.Dollars.UnboundedLoss) }},
+	{"dollar_var95", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Dollars.VaR95 }},
+	{"dollar_var99", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Dollars.VaR99 }},
+	{"dollar_expected_shortfall", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Dollars.ExpectedShortfall }},
+	{"dollar_expected_shortfall99", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Dollars.ExpectedShortfall99 }},
+	{"contract_multiplier", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return models.SpreadMultiplier(s.Spread) }},
+	{"settlement", kindString, func(s models.SpreadWithProbabilities) interface{} { return models.SettlementStyle(s.Spread) }},
+	{"exercise", kindString, func(s models.SpreadWithProbabilities) interface{} { return models.ExerciseStyle(s.Spread) }},
+	{"section_1256", kindInt, func(s models.SpreadWithProbabilities) interface{} { return boolInt(models.IsSection1256(s.Spread)) }},
+	{"nonstandard", kindInt, func(s models.SpreadWithProbabilities) interface{} {
+		return boolInt(models.HasNonstandardLegs(s.Spread))
+	}},
+	{"quote_age_seconds", kindFloat, func(s models.SpreadWithProbabilities) interface{} { return s.Quotes.Age.Seconds() }},
+	{"stale_quotes", kindInt, func(s models.SpreadWithProbabilities) interface{} { return boolInt(s.Quotes.Stale) }},
+	{"tail_risks", kindString, func(s models.SpreadWithProbabilities) interface{} { return formatTailRisks(s.TailRisks) }},
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// formatTailRisks flattens the tail risks into one field of space separated level:VaR:expected shortfall
+// triples, e.g. "0.9:1.2:1.5 0.95:1.85:2.1".
+func formatTailRisks(risks []models.TailRisk) string {
+	fields := make([]string, len(risks))
+	for i, risk := range risks {
+		fields[i] = strconv.FormatFloat(risk.Level, 'g', -1, 64) + ":" + strconv.FormatFloat(risk.VaR, 'g', 6, 64) + ":" + strconv.FormatFloat(risk.ExpectedShortfall, 'g', 6, 64)
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,43 @@
+package results
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// WriteCSV writes one row per spread with a header row naming each column.
+func WriteCSV(w io.Writer, spreads []models.SpreadWithProbabilities) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %s", err)
+	}
+
+	record := make([]string, len(columns))
+	for _, spread := range spreads {
+		for i, col := range columns {
+			switch v := col.value(spread).(type) {
+			case string:
+				record[i] = v
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			case int64:
+				record[i] = strconv.FormatInt(v, 10)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: %s", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,84 @@
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// Supported export formats.
+const (
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+	FormatNDJSON  = "ndjson" // One spread per line, streamed as a scan produces them
+)
+
+// WriteJSON writes the spreads as a Document of the current SchemaVersion.
+func WriteJSON(w io.Writer, spreads []models.SpreadWithProbabilities) error {
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(NewDocument(spreads, time.Now())); err != nil {
+		return fmt.Errorf("failed to encode spreads: %s", err)
+	}
+	return nil
+}
+
+// ParseExportFlag splits a "format:path" export specification. The path is optional
+// and defaults to the current directory.
+func ParseExportFlag(value string) (string, string, error) {
+	format, path, _ := strings.Cut(value, ":")
+	format = strings.ToLower(strings.TrimSpace(format))
+
+	switch format {
+	case FormatJSON, FormatCSV, FormatParquet, FormatNDJSON:
+	default:
+		return "", "", fmt.Errorf("unsupported export format %q (expected json, ndjson, csv or parquet)", format)
+	}
+
+	if path == "" {
+		path = "."
+	}
+	return format, path, nil
+}
+
+// Export writes the full spread universe for a scan of symbol in the given format. If path is
+// an existing directory a file named after the symbol and scan time is created inside it.
+func Export(format, path, symbol string, spreads []models.SpreadWithProbabilities) (string, error) {
+	path = exportPath(format, path, symbol)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create export file: %s", err)
+	}
+	defer file.Close()
+
+	switch format {
+	case FormatCSV:
+		err = WriteCSV(file, spreads)
+	case FormatParquet:
+		err = WriteParquet(file, spreads)
+	case FormatNDJSON:
+		err = WriteNDJSON(file, spreads)
+	default:
+		err = WriteJSON(file, spreads)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// exportPath is the file a scan of symbol is exported to: path, or a file named after the symbol and the
+// current time inside it when path is an existing directory.
+func exportPath(format, path, symbol string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return filepath.Join(path, fmt.Sprintf("%s_%s.%s", symbol, time.Now().Format("20060102_150405"), format))
+	}
+	return path
+}
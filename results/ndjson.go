@@ -0,0 +1,87 @@
+package results
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// NDJSONWriter writes spreads as newline-delimited JSON, one Spread of the current SchemaVersion per
+// line, as a scan produces them, so the spreads need not be held in memory. It is safe for concurrent use
+// and implements positions.SpreadWriter.
+type NDJSONWriter struct {
+	mu      sync.Mutex
+	buf     *bufio.Writer
+	encoder *json.Encoder
+	closer  io.Closer
+	count   int
+}
+
+// NewNDJSONWriter writes to w.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	buf := bufio.NewWriter(w)
+	writer := &NDJSONWriter{buf: buf, encoder: json.NewEncoder(buf)}
+	if closer, ok := w.(io.Closer); ok {
+		writer.closer = closer
+	}
+	return writer
+}
+
+// CreateNDJSON creates the file spreads of a scan of symbol are streamed to, named like Export's when
+// path is a directory, and returns it with its path.
+func CreateNDJSON(path, symbol string) (*NDJSONWriter, string, error) {
+	path = exportPath(FormatNDJSON, path, symbol)
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create export file: %s", err)
+	}
+	return NewNDJSONWriter(file), path, nil
+}
+
+// WriteSpread writes the spread as a line.
+func (w *NDJSONWriter) WriteSpread(spread models.SpreadWithProbabilities) error {
+	line := NewSpread(spread)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.encoder.Encode(line); err != nil {
+		return fmt.Errorf("failed to encode spread: %s", err)
+	}
+	w.count++
+	return nil
+}
+
+// Count returns the number of spreads written.
+func (w *NDJSONWriter) Count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+// Close flushes the lines written and closes the underlying writer when it is a Closer.
+func (w *NDJSONWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write spreads: %s", err)
+	}
+	if w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
+
+// WriteNDJSON writes the spreads as newline-delimited JSON.
+func WriteNDJSON(w io.Writer, spreads []models.SpreadWithProbabilities) error {
+	writer := NewNDJSONWriter(w)
+	for _, spread := range spreads {
+		if err := writer.WriteSpread(spread); err != nil {
+			return err
+		}
+	}
+	return writer.buf.Flush()
+}
@@ -0,0 +1,258 @@
+package results
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// Parquet physical types, encodings and page types used by the writer.
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetUTF8     = 0
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetDataPage     = 0
+	parquetUncompressed = 0
+)
+
+// Thrift compact protocol field types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// WriteParquet writes the spreads as a single row group Parquet file with one
+// uncompressed, PLAIN encoded page per column.
+func WriteParquet(w io.Writer, spreads []models.SpreadWithProbabilities) error {
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+
+	type chunk struct {
+		col       column
+		offset    int64
+		size      int64
+		physical  int32
+		numValues int64
+	}
+	chunks := make([]chunk, 0, len(columns))
+
+	for _, col := range columns {
+		data := encodePlain(col, spreads)
+
+		header := &thriftWriter{}
+		header.i32Field(1, parquetDataPage)
+		header.i32Field(2, int32(len(data)))
+		header.i32Field(3, int32(len(data)))
+		header.beginStruct(5)
+		header.i32Field(1, int32(len(spreads)))
+		header.i32Field(2, parquetPlain)
+		header.i32Field(3, parquetRLE)
+		header.i32Field(4, parquetRLE)
+		header.endStruct()
+		header.stop()
+
+		offset := int64(file.Len())
+		file.Write(header.buf.Bytes())
+		file.Write(data)
+
+		chunks = append(chunks, chunk{
+			col:       col,
+			offset:    offset,
+			size:      int64(header.buf.Len() + len(data)),
+			physical:  physicalType(col.kind),
+			numValues: int64(len(spreads)),
+		})
+	}
+
+	meta := &thriftWriter{}
+	meta.i32Field(1, 1)
+
+	meta.listField(2, thriftStruct, len(columns)+1)
+	meta.beginListStruct()
+	meta.binaryField(4, "schema")
+	meta.i32Field(5, int32(len(columns)))
+	meta.endListStruct()
+	for _, col := range columns {
+		meta.beginListStruct()
+		meta.i32Field(1, physicalType(col.kind))
+		meta.i32Field(3, parquetRequired)
+		meta.binaryField(4, col.name)
+		if col.kind == kindString {
+			meta.i32Field(6, parquetUTF8)
+		}
+		meta.endListStruct()
+	}
+
+	meta.i64Field(3, int64(len(spreads)))
+
+	var totalSize int64
+	for _, c := range chunks {
+		totalSize += c.size
+	}
+
+	meta.listField(4, thriftStruct, 1)
+	meta.beginListStruct()
+	meta.listField(1, thriftStruct, len(chunks))
+	for _, c := range chunks {
+		meta.beginListStruct()
+		meta.i64Field(2, c.offset)
+		meta.beginStruct(3)
+		meta.i32Field(1, c.physical)
+		meta.listField(2, thriftI32, 1)
+		meta.writeVarint(zigzag(parquetPlain))
+		meta.listField(3, thriftBinary, 1)
+		meta.writeBinary(c.col.name)
+		meta.i32Field(4, parquetUncompressed)
+		meta.i64Field(5, c.numValues)
+		meta.i64Field(6, c.size)
+		meta.i64Field(7, c.size)
+		meta.i64Field(9, c.offset)
+		meta.endStruct()
+		meta.endListStruct()
+	}
+	meta.i64Field(2, totalSize)
+	meta.i64Field(3, int64(len(spreads)))
+	meta.endListStruct()
+
+	meta.binaryField(6, "stocd")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len()))
+	file.WriteString("PAR1")
+
+	if _, err := w.Write(file.Bytes()); err != nil {
+		return fmt.Errorf("failed to write parquet data: %s", err)
+	}
+	return nil
+}
+
+func physicalType(kind columnKind) int32 {
+	switch kind {
+	case kindString:
+		return parquetByteArray
+	case kindInt:
+		return parquetInt64
+	default:
+		return parquetDouble
+	}
+}
+
+func encodePlain(col column, spreads []models.SpreadWithProbabilities) []byte {
+	var buf bytes.Buffer
+	var scratch [8]byte
+	for _, spread := range spreads {
+		switch v := col.value(spread).(type) {
+		case string:
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(len(v)))
+			buf.Write(scratch[:4])
+			buf.WriteString(v)
+		case float64:
+			binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(v))
+			buf.Write(scratch[:])
+		case int64:
+			binary.LittleEndian.PutUint64(scratch[:], uint64(v))
+			buf.Write(scratch[:])
+		}
+	}
+	return buf.Bytes()
+}
+
+// thriftWriter encodes the subset of the Thrift compact protocol needed for Parquet metadata.
+type thriftWriter struct {
+	buf     bytes.Buffer
+	lastID  []int16
+	fieldID int16
+}
+
+func (t *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	delta := id - t.fieldID
+	if delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buf.WriteByte(fieldType)
+		t.writeVarint(zigzag(int64(id)))
+	}
+	t.fieldID = id
+}
+
+func (t *thriftWriter) i32Field(id int16, value int32) {
+	t.fieldHeader(id, thriftI32)
+	t.writeVarint(zigzag(int64(value)))
+}
+
+func (t *thriftWriter) i64Field(id int16, value int64) {
+	t.fieldHeader(id, thriftI64)
+	t.writeVarint(zigzag(value))
+}
+
+func (t *thriftWriter) binaryField(id int16, value string) {
+	t.fieldHeader(id, thriftBinary)
+	t.writeBinary(value)
+}
+
+func (t *thriftWriter) listField(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xF0 | elemType)
+		t.writeVarint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.beginListStruct()
+}
+
+func (t *thriftWriter) endStruct() {
+	t.endListStruct()
+}
+
+// beginListStruct starts a struct that is an element of a list and therefore has no field header.
+func (t *thriftWriter) beginListStruct() {
+	t.lastID = append(t.lastID, t.fieldID)
+	t.fieldID = 0
+}
+
+func (t *thriftWriter) endListStruct() {
+	t.stop()
+	t.fieldID = t.lastID[len(t.lastID)-1]
+	t.lastID = t.lastID[:len(t.lastID)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
+
+func (t *thriftWriter) writeBinary(value string) {
+	t.writeVarint(uint64(len(value)))
+	t.buf.WriteString(value)
+}
+
+func (t *thriftWriter) writeVarint(value uint64) {
+	for value >= 0x80 {
+		t.buf.WriteByte(byte(value) | 0x80)
+		value >>= 7
+	}
+	t.buf.WriteByte(byte(value))
+}
+
+func zigzag(value int64) uint64 {
+	return uint64((value << 1) ^ (value >> 63))
+}
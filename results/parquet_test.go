@@ -0,0 +1,163 @@
+package results
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// thriftReader decodes the Thrift compact protocol into maps of field id to value: int64 for integers,
+// string for binaries, []interface{} for lists and map[int16]interface{} for structs.
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	if r.pos >= len(r.buf) {
+		panic("unexpected end of thrift data")
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	var value uint64
+	for shift := 0; ; shift += 7 {
+		b := r.byte()
+		value |= uint64(b&0x7F) << shift
+		if b < 0x80 {
+			return value
+		}
+	}
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(fieldType byte) interface{} {
+	switch fieldType {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.varint())
+		s := string(r.buf[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0F)
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d", fieldType))
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var id int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0F)
+	}
+}
+
+func parquetSpread(underlying string, strike, credit float64) models.SpreadWithProbabilities {
+	var s models.SpreadWithProbabilities
+	s.Spread.SpreadType = "Bull Put"
+	s.Spread.ShortLeg.Option.Underlying = underlying
+	s.Spread.ShortLeg.Option.ExpirationDate = "2026-01-16"
+	s.Spread.ShortLeg.Option.Strike = strike
+	s.Spread.LongLeg.Option.Strike = strike - 5
+	s.Spread.SpreadCredit = credit
+	return s
+}
+
+func TestWriteParquet(t *testing.T) {
+	spreads := []models.SpreadWithProbabilities{parquetSpread("SPY", 500, 1.25), parquetSpread("QQQ", 420, 0.8)}
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, spreads); err != nil {
+		t.Fatalf("WriteParquet: %s", err)
+	}
+	file := buf.Bytes()
+
+	if !bytes.HasPrefix(file, []byte("PAR1")) || !bytes.HasSuffix(file, []byte("PAR1")) {
+		t.Fatalf("file does not start and end with the PAR1 magic")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footerStart := len(file) - 8 - footerLength
+	if footerLength <= 0 || footerStart < 4 {
+		t.Fatalf("footer length %d does not fit a %d byte file", footerLength, len(file))
+	}
+
+	footer := &thriftReader{buf: file[footerStart : len(file)-8]}
+	meta := footer.structure()
+	if footer.pos != footerLength {
+		t.Errorf("FileMetaData is %d bytes, the footer length says %d", footer.pos, footerLength)
+	}
+	if rows := meta[3]; rows != int64(len(spreads)) {
+		t.Errorf("num_rows = %v, want %d", rows, len(spreads))
+	}
+	if schema := meta[2].([]interface{}); len(schema) != len(columns)+1 {
+		t.Errorf("schema has %d elements, want the root and %d columns", len(schema), len(columns))
+	}
+	rowGroups := meta[4].([]interface{})
+	if len(rowGroups) != 1 {
+		t.Fatalf("got %d row groups, want 1", len(rowGroups))
+	}
+	chunks := rowGroups[0].(map[int16]interface{})[1].([]interface{})
+	if len(chunks) != len(columns) {
+		t.Fatalf("got %d column chunks, want %d", len(chunks), len(columns))
+	}
+
+	next := int64(4)
+	for i, chunk := range chunks {
+		columnMeta := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+		name := columnMeta[3].([]interface{})[0]
+		if name != columns[i].name {
+			t.Errorf("column %d is %v, want %s", i, name, columns[i].name)
+		}
+		offset := columnMeta[9].(int64)
+		if offset != next {
+			t.Errorf("%s data page at %d, want %d after the previous column", name, offset, next)
+		}
+		next = offset + columnMeta[7].(int64)
+
+		page := &thriftReader{buf: file[offset:footerStart]}
+		header := page.structure()
+		if numValues := header[5].(map[int16]interface{})[1]; header[1] != int64(parquetDataPage) || numValues != int64(len(spreads)) {
+			t.Errorf("%s page header = %v, want a data page of %d values", name, header, len(spreads))
+		}
+		if i == 0 {
+			data := page.buf[page.pos:]
+			if first := string(data[4 : 4+binary.LittleEndian.Uint32(data)]); first != "SPY" {
+				t.Errorf("first underlying = %q, want SPY", first)
+			}
+		}
+	}
+	if next != int64(footerStart) {
+		t.Errorf("column chunks end at %d, the footer starts at %d", next, footerStart)
+	}
+}
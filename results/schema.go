@@ -0,0 +1,447 @@
+package results
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/probability"
+)
+
+// SchemaVersion is the version of the JSON export schema written by WriteJSON. Version 1 is the legacy
+// format, a bare array of models.SpreadWithProbabilities with Go field names and the probabilities keyed
+// by volatility and model names, e.g. YZ_1m_Kou_Heston_probability. Fields are only added within a
+// version; renaming or removing one starts the next.
+const SchemaVersion = 2
+
+// Document is a JSON export: the schema version and every spread of the scan.
+type Document struct {
+	SchemaVersion int      `json:"schemaVersion"`
+	GeneratedAt   string   `json:"generatedAt,omitempty"` // RFC 3339 time of the export, empty for converted legacy exports
+	Spreads       []Spread `json:"spreads"`
+}
+
+// Spread is one spread of a Document. Prices and risk are per share unless under dollars.
+type Spread struct {
+	ID          string       `json:"id"` // Short and long leg symbols joined by _, as used by --trace-spread
+	Underlying  string       `json:"underlying"`
+	Strategy    string       `json:"strategy"`
+	Expiration  string       `json:"expiration"`
+	Legs        []Leg        `json:"legs"`
+	Pricing     Pricing      `json:"pricing"`
+	Greeks      Greeks       `json:"greeks"`
+	Probability Probability  `json:"probability"`
+	Risk        Risk         `json:"risk"`
+	Dollars     Dollars      `json:"dollars"`
+	Breakeven   Breakeven    `json:"breakeven"`
+	Margin      Margin       `json:"margin"`
+	Score       Score        `json:"score"`
+	Contract    Contract     `json:"contract"`
+	Quotes      Quotes       `json:"quotes"`
+	Models      ModelParams  `json:"models"`
+	Volatility  Volatility   `json:"volatility"`
+	Scenarios   []Scenario   `json:"scenarios,omitempty"`
+	Payoff      *PayoffCurve `json:"payoff,omitempty"`
+}
+
+// Leg is one option of a spread, its quantity positive when sold and negative when bought.
+type Leg struct {
+	Symbol            string  `json:"symbol"`
+	Type              string  `json:"type"` // call or put
+	Strike            float64 `json:"strike"`
+	Expiration        string  `json:"expiration"`
+	Quantity          int     `json:"quantity"`
+	Bid               float64 `json:"bid"`
+	Ask               float64 `json:"ask"`
+	ImpliedVolatility float64 `json:"impliedVolatility"`
+	Delta             float64 `json:"delta"`
+	Volume            int     `json:"volume"`
+	OpenInterest      int     `json:"openInterest"`
+}
+
+// Pricing is the credit of a spread and what it is made of.
+type Pricing struct {
+	Credit           float64 `json:"credit"` // Net of fees
+	BSMPrice         float64 `json:"bsmPrice"`
+	Extrinsic        float64 `json:"extrinsic"`
+	Intrinsic        float64 `json:"intrinsic"`
+	ROR              float64 `json:"ror"`
+	CreditWidthRatio float64 `json:"creditWidthRatio"`
+	Fees             float64 `json:"fees"`
+	FillAdjustment   float64 `json:"fillAdjustment"`
+	CreditAdjustment float64 `json:"creditAdjustment"`
+	ExpectedValue    float64 `json:"expectedValue"`
+	ExpectedProfit   float64 `json:"expectedProfit"`
+}
+
+// Greeks are the spread's BSM greeks, in the sign convention of the structure sold, and its decay.
+type Greeks struct {
+	Delta            float64 `json:"delta"`
+	Gamma            float64 `json:"gamma"`
+	Theta            float64 `json:"theta"` // Annualized
+	Vega             float64 `json:"vega"`
+	Rho              float64 `json:"rho"`
+	ThetaPerDay      float64 `json:"thetaPerDay"`
+	ThetaVegaRatio   float64 `json:"thetaVegaRatio"`
+	ThetaCreditRatio float64 `json:"thetaCreditRatio"`
+}
+
+// Probability is the spread's probability of profit and the estimates it averages.
+type Probability struct {
+	Average       float64    `json:"average"`
+	StandardError float64    `json:"standardError"`
+	Interval      Interval   `json:"interval"`
+	MarketImplied float64    `json:"marketImplied"`
+	Bootstrap     float64    `json:"bootstrap"`
+	Estimates     []Estimate `json:"estimates"` // Ordered by volatility input, then model
+}
+
+// Estimate is the probability of profit of one volatility input simulated with one model.
+type Estimate struct {
+	Volatility    string    `json:"volatility"` // e.g. YZ_1m, or Historical and MarketImplied for the bootstrap and risk-neutral density
+	Model         string    `json:"model"`      // e.g. Kou_Heston
+	Probability   float64   `json:"probability"`
+	StandardError float64   `json:"standardError"`
+	Weight        float64   `json:"weight"` // Weight in the average
+	Interval      *Interval `json:"interval,omitempty"`
+}
+
+// Interval is a 95% confidence interval.
+type Interval struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// Risk is the spread's simulated tail risk, losses positive.
+type Risk struct {
+	VaR95               float64    `json:"var95"`
+	VaR99               float64    `json:"var99"`
+	ExpectedShortfall95 float64    `json:"expectedShortfall95"`
+	ExpectedShortfall99 float64    `json:"expectedShortfall99"`
+	TailRisks           []TailRisk `json:"tailRisks,omitempty"`
+	WorstScenarioLoss   float64    `json:"worstScenarioLoss"`
+}
+
+// TailRisk is the VaR and expected shortfall at one confidence level.
+type TailRisk struct {
+	Level             float64 `json:"level"`
+	VaR               float64 `json:"var"`
+	ExpectedShortfall float64 `json:"expectedShortfall"`
+}
+
+// Dollars is the spread's risk in dollars per contract.
+type Dollars struct {
+	MaxProfit           float64    `json:"maxProfit"`
+	MaxLoss             float64    `json:"maxLoss"` // 0 when unboundedLoss
+	UnboundedLoss       bool       `json:"unboundedLoss"`
+	VaR95               float64    `json:"var95"`
+	VaR99               float64    `json:"var99"`
+	ExpectedShortfall95 float64    `json:"expectedShortfall95"`
+	ExpectedShortfall99 float64    `json:"expectedShortfall99"`
+	TailRisks           []TailRisk `json:"tailRisks,omitempty"`
+}
+
+// Breakeven is where the spread breaks even at expiration.
+type Breakeven struct {
+	Price       float64 `json:"price"`
+	UpperPrice  float64 `json:"upperPrice,omitempty"`
+	DistancePct float64 `json:"distancePct"`
+	DistanceSD  float64 `json:"distanceSD"`
+}
+
+// Margin is the spread's collateral, per share.
+type Margin struct {
+	Requirement     float64 `json:"requirement"` // Reg-T requirement of undefined risk positions, 0 for others
+	BuyingPower     float64 `json:"buyingPower"`
+	PortfolioMargin float64 `json:"portfolioMargin"`
+	ReturnOnMargin  float64 `json:"returnOnMargin"`
+}
+
+// Score is the spread's composite score and the inputs of it not found elsewhere.
+type Score struct {
+	Composite           float64 `json:"composite"`
+	Liquidity           float64 `json:"liquidity"`
+	VarianceRiskPremium float64 `json:"varianceRiskPremium"`
+	ExpectedMove        float64 `json:"expectedMove"`
+	ShortStrikeMoves    float64 `json:"shortStrikeMoves"`
+}
+
+// Contract describes the contracts traded and how they settle.
+type Contract struct {
+	Multiplier  float64 `json:"multiplier"`
+	Nonstandard bool    `json:"nonstandard"`
+	Settlement  string  `json:"settlement"` // physical, cash-am or cash-pm
+	Exercise    string  `json:"exercise"`   // american or european
+	Section1256 bool    `json:"section1256"`
+}
+
+// Quotes is how recently the spread's legs were quoted.
+type Quotes struct {
+	QuotedAt   string  `json:"quotedAt,omitempty"` // RFC 3339 time of the stalest leg's last bid or ask, empty when unknown
+	AgeSeconds float64 `json:"ageSeconds"`
+	Stale      bool    `json:"stale"`
+}
+
+// ModelParams are the parameters of the models the spread was simulated with.
+type ModelParams struct {
+	Merton map[string]float64 `json:"merton"`
+	Kou    map[string]float64 `json:"kou"`
+	Heston map[string]float64 `json:"heston"`
+	CGMY   map[string]float64 `json:"cgmy"`
+}
+
+// Volatility is the volatility inputs of the spread's simulations.
+type Volatility struct {
+	ShortLeg       float64            `json:"shortLeg"`
+	LongLeg        float64            `json:"longLeg"`
+	Surface        float64            `json:"surface"`
+	Heston         float64            `json:"heston"`
+	YangZhang      map[string]float64 `json:"yangZhang,omitempty"`
+	RogersSatchell map[string]float64 `json:"rogersSatchell,omitempty"`
+}
+
+// Scenario is the outcome of a historical stress replay.
+type Scenario struct {
+	Name       string  `json:"name"`
+	WorstLoss  float64 `json:"worstLoss"`
+	WorstDay   int     `json:"worstDay"`
+	FinalPnL   float64 `json:"finalPnL"`
+	SpotChange float64 `json:"spotChange"`
+}
+
+// PayoffCurve is the spread's P&L over a grid of underlying prices.
+type PayoffCurve struct {
+	Prices        []float64 `json:"prices"`
+	AtExpiration  []float64 `json:"atExpiration"`
+	AtHalfLife    []float64 `json:"atHalfLife"`
+	HalfLifeDelta []float64 `json:"halfLifeDelta"`
+}
+
+// NewDocument converts the spreads to the current schema.
+func NewDocument(spreads []models.SpreadWithProbabilities, generatedAt time.Time) Document {
+	doc := Document{SchemaVersion: SchemaVersion, Spreads: make([]Spread, len(spreads))}
+	if !generatedAt.IsZero() {
+		doc.GeneratedAt = generatedAt.Format(time.RFC3339)
+	}
+	for i, spread := range spreads {
+		doc.Spreads[i] = NewSpread(spread)
+	}
+	return doc
+}
+
+// NewSpread converts a spread to the current schema.
+func NewSpread(s models.SpreadWithProbabilities) Spread {
+	spread := s.Spread
+	out := Spread{
+		ID:         spread.ShortLeg.Option.Symbol + "_" + spread.LongLeg.Option.Symbol,
+		Underlying: spread.ShortLeg.Option.Underlying,
+		Strategy:   spread.SpreadType,
+		Expiration: spread.ShortLeg.Option.ExpirationDate,
+		Pricing: Pricing{
+			Credit:           spread.SpreadCredit,
+			BSMPrice:         spread.SpreadBSMPrice,
+			Extrinsic:        spread.ExtrinsicValue,
+			Intrinsic:        spread.IntrinsicValue,
+			ROR:              spread.ROR,
+			CreditWidthRatio: spread.CreditWidthRatio,
+			Fees:             spread.Fees,
+			FillAdjustment:   spread.FillAdjustment,
+			CreditAdjustment: spread.CreditAdjustment,
+			ExpectedValue:    s.ExpectedValue,
+			ExpectedProfit:   s.ExpectedProfit,
+		},
+		Greeks: Greeks{
+			Delta:            spread.Greeks.Delta,
+			Gamma:            spread.Greeks.Gamma,
+			Theta:            spread.Greeks.Theta,
+			Vega:             spread.Greeks.Vega,
+			Rho:              spread.Greeks.Rho,
+			ThetaPerDay:      spread.Decay.ThetaPerDay,
+			ThetaVegaRatio:   spread.Decay.ThetaVegaRatio,
+			ThetaCreditRatio: spread.Decay.ThetaCreditRatio,
+		},
+		Probability: newProbability(s.Probability),
+		Risk: Risk{
+			VaR95:               s.VaR95,
+			VaR99:               s.VaR99,
+			ExpectedShortfall95: s.ExpectedShortfall,
+			ExpectedShortfall99: s.ExpectedShortfall99,
+			TailRisks:           newTailRisks(s.TailRisks),
+			WorstScenarioLoss:   s.WorstScenarioLoss,
+		},
+		Dollars: Dollars{
+			MaxProfit:           s.Dollars.MaxProfit,
+			MaxLoss:             s.Dollars.MaxLoss,
+			UnboundedLoss:       s.Dollars.UnboundedLoss,
+			VaR95:               s.Dollars.VaR95,
+			VaR99:               s.Dollars.VaR99,
+			ExpectedShortfall95: s.Dollars.ExpectedShortfall,
+			ExpectedShortfall99: s.Dollars.ExpectedShortfall99,
+			TailRisks:           newTailRisks(s.Dollars.TailRisks),
+		},
+		Breakeven: Breakeven{
+			Price:       s.Breakeven.Price,
+			UpperPrice:  s.Breakeven.UpperPrice,
+			DistancePct: s.Breakeven.DistancePct,
+			DistanceSD:  s.Breakeven.DistanceSD,
+		},
+		Margin: Margin{
+			Requirement:     spread.Margin,
+			BuyingPower:     spread.BuyingPower,
+			PortfolioMargin: spread.PortfolioMargin,
+			ReturnOnMargin:  spread.ReturnOnMargin,
+		},
+		Score: Score{
+			Composite:           s.CompositeScore,
+			Liquidity:           s.Liquidity,
+			VarianceRiskPremium: s.VarianceRiskPremium,
+			ExpectedMove:        spread.ExpectedMove,
+			ShortStrikeMoves:    spread.ShortStrikeMoves,
+		},
+		Contract: Contract{
+			Multiplier:  models.SpreadMultiplier(spread),
+			Nonstandard: models.HasNonstandardLegs(spread),
+			Settlement:  models.SettlementStyle(spread),
+			Exercise:    models.ExerciseStyle(spread),
+			Section1256: models.IsSection1256(spread),
+		},
+		Quotes: Quotes{
+			AgeSeconds: s.Quotes.Age.Seconds(),
+			Stale:      s.Quotes.Stale,
+		},
+		Models: ModelParams{
+			Merton: map[string]float64{"lambda": s.MertonParams.Lambda, "mu": s.MertonParams.Mu, "delta": s.MertonParams.Delta},
+			Kou:    map[string]float64{"lambda": s.KouParams.Lambda, "p": s.KouParams.P, "eta1": s.KouParams.Eta1, "eta2": s.KouParams.Eta2},
+			Heston: map[string]float64{"v0": s.HestonParams.V0, "kappa": s.HestonParams.Kappa, "theta": s.HestonParams.Theta, "xi": s.HestonParams.Xi, "rho": s.HestonParams.Rho},
+			CGMY:   map[string]float64{"c": s.CGMYParams.C, "g": s.CGMYParams.G, "m": s.CGMYParams.M, "y": s.CGMYParams.Y},
+		},
+		Volatility: Volatility{
+			ShortLeg:       s.VolatilityInfo.ShortLegVol,
+			LongLeg:        s.VolatilityInfo.LongLegVol,
+			Surface:        s.VolatilityInfo.TotalAvgVolSurface,
+			Heston:         s.VolatilityInfo.HestonVolatility,
+			YangZhang:      s.VolatilityInfo.YangZhang,
+			RogersSatchell: s.VolatilityInfo.RogersSatchel,
+		},
+	}
+	if !s.Quotes.QuotedAt.IsZero() {
+		out.Quotes.QuotedAt = s.Quotes.QuotedAt.Format(time.RFC3339)
+	}
+	for _, leg := range models.PositionLegs(spread) {
+		option := leg.Option
+		out.Legs = append(out.Legs, Leg{
+			Symbol:            option.Symbol,
+			Type:              option.OptionType,
+			Strike:            option.Strike,
+			Expiration:        option.ExpirationDate,
+			Quantity:          leg.Quantity,
+			Bid:               option.Bid,
+			Ask:               option.Ask,
+			ImpliedVolatility: leg.BSMResult.ImpliedVolatility,
+			Delta:             leg.BSMResult.Delta,
+			Volume:            option.Volume,
+			OpenInterest:      option.OpenInterest,
+		})
+	}
+	for _, scenario := range s.Scenarios {
+		out.Scenarios = append(out.Scenarios, Scenario(scenario))
+	}
+	if curve := s.PayoffCurve; len(curve.Prices) > 0 {
+		out.Payoff = &PayoffCurve{Prices: curve.Prices, AtExpiration: curve.AtExpiration, AtHalfLife: curve.AtHalfLife, HalfLifeDelta: curve.HalfLifeDelta}
+	}
+	return out
+}
+
+// newProbability structures the probability maps, whose keys name the volatility input and model, into
+// a list of estimates. Keys of an unknown form are kept whole as the volatility.
+func newProbability(p models.ProbabilityResult) Probability {
+	out := Probability{
+		Average:       p.AverageProbability,
+		StandardError: p.StandardError,
+		Interval:      Interval(p.AverageInterval),
+		MarketImplied: p.MarketImplied,
+		Bootstrap:     p.Bootstrap,
+		Estimates:     make([]Estimate, 0, len(p.Probabilities)),
+	}
+	for key, value := range p.Probabilities {
+		volatility, model, ok := probability.SplitProbabilityKey(key)
+		if !ok {
+			volatility = key
+		}
+		estimate := Estimate{Volatility: volatility, Model: model, Probability: value, StandardError: p.StandardErrors[key], Weight: p.Weights[key]}
+		if interval, ok := p.Intervals[key]; ok {
+			estimate.Interval = &Interval{Lower: interval.Lower, Upper: interval.Upper}
+		}
+		out.Estimates = append(out.Estimates, estimate)
+	}
+	sort.Slice(out.Estimates, func(i, j int) bool {
+		if out.Estimates[i].Volatility != out.Estimates[j].Volatility {
+			return out.Estimates[i].Volatility < out.Estimates[j].Volatility
+		}
+		return out.Estimates[i].Model < out.Estimates[j].Model
+	})
+	return out
+}
+
+func newTailRisks(risks []models.TailRisk) []TailRisk {
+	var out []TailRisk
+	for _, risk := range risks {
+		out = append(out, TailRisk(risk))
+	}
+	return out
+}
+
+// ConvertLegacy reads a legacy (version 1) JSON export, such as jspreads.json, and converts it to the
+// current schema. The decay and dollar figures older exports lack are derived from the spreads. A document
+// already in the current schema is returned as is.
+func ConvertLegacy(r io.Reader) (Document, error) {
+	reader := bufio.NewReader(r)
+	first, err := firstToken(reader)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to read export: %s", err)
+	}
+
+	if first == '{' {
+		var doc Document
+		if err := json.NewDecoder(reader).Decode(&doc); err != nil {
+			return Document{}, fmt.Errorf("failed to decode export: %s", err)
+		}
+		if doc.SchemaVersion != SchemaVersion {
+			return Document{}, fmt.Errorf("unsupported schema version %d, expected a legacy export or version %d", doc.SchemaVersion, SchemaVersion)
+		}
+		return doc, nil
+	}
+
+	var spreads []models.SpreadWithProbabilities
+	if err := json.NewDecoder(reader).Decode(&spreads); err != nil {
+		return Document{}, fmt.Errorf("failed to decode legacy export: %s", err)
+	}
+	for i := range spreads {
+		if spreads[i].Spread.Decay == (models.Decay{}) {
+			spreads[i].Spread.Decay = models.SpreadDecay(spreads[i].Spread)
+		}
+		if spreads[i].Dollars.MaxProfit == 0 && spreads[i].Dollars.MaxLoss == 0 && !spreads[i].Dollars.UnboundedLoss {
+			spreads[i].Dollars = models.NewDollarRisk(spreads[i])
+		}
+	}
+	return NewDocument(spreads, time.Time{}), nil
+}
+
+// firstToken returns the first byte of the reader that is not white space, leaving it unread.
+func firstToken(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, reader.UnreadByte()
+	}
+}
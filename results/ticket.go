@@ -0,0 +1,112 @@
+package results
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/bcdannyboy/stocd/models"
+)
+
+// TicketLeg is one leg of a ticket's order, using Tradier's multileg order fields.
+type TicketLeg struct {
+	OptionSymbol string `json:"option_symbol"`
+	Side         string `json:"side"`
+	Quantity     int    `json:"quantity"`
+}
+
+// TicketOrder holds the parameters needed to place the spread as a single credit order.
+type TicketOrder struct {
+	Class    string      `json:"class"`
+	Type     string      `json:"type"`
+	Duration string      `json:"duration"`
+	Price    float64     `json:"price"`
+	Legs     []TicketLeg `json:"legs"`
+}
+
+// Ticket is a compact, machine readable summary of a recommended spread for downstream
+// automation that should not have to parse the formatted report.
+type Ticket struct {
+	Underlying  string      `json:"underlying"`
+	Strategy    string      `json:"strategy"`
+	Expiration  string      `json:"expiration"`
+	ShortSymbol string      `json:"short_symbol"`
+	LongSymbol  string      `json:"long_symbol,omitempty"`
+	CallSymbol  string      `json:"call_symbol,omitempty"`
+	ShortStrike float64     `json:"short_strike"`
+	LongStrike  float64     `json:"long_strike,omitempty"`
+	CallStrike  float64     `json:"call_strike,omitempty"`
+	Width       float64     `json:"width,omitempty"`
+	Credit      float64     `json:"credit"`
+	PoP         float64     `json:"pop"`
+	EV          float64     `json:"expected_value"` // Predicted expected value per share, net of fees
+	Multiplier  float64     `json:"multiplier"`     // Dollars per point of the position's price, e.g. 100 for equity options
+	Order       TicketOrder `json:"order"`
+}
+
+// NewTicket builds the ticket for a spread, with the limit price at the modeled credit. A single leg is a
+// plain option order; the shares of a covered call are assumed to be held already. A declared strategy
+// orders every leg it holds.
+func NewTicket(spread models.SpreadWithProbabilities) Ticket {
+	shortLeg := spread.Spread.ShortLeg.Option
+	longLeg := spread.Spread.LongLeg.Option
+	callLeg := spread.Spread.CallLeg.Option
+	credit := round(spread.Spread.SpreadCredit+spread.Spread.Fees, 2) // The order's limit is before fees
+
+	ticket := Ticket{
+		Underlying:  shortLeg.Underlying,
+		Strategy:    spread.Spread.SpreadType,
+		Expiration:  shortLeg.ExpirationDate,
+		ShortSymbol: shortLeg.Symbol,
+		LongSymbol:  longLeg.Symbol,
+		CallSymbol:  callLeg.Symbol,
+		ShortStrike: shortLeg.Strike,
+		LongStrike:  longLeg.Strike,
+		CallStrike:  callLeg.Strike,
+		Credit:      credit,
+		PoP:         round(spread.Probability.AverageProbability, 4),
+		EV:          round(spread.ExpectedValue, 4),
+		Multiplier:  models.SpreadMultiplier(spread.Spread),
+		Order: TicketOrder{
+			Class:    "multileg",
+			Type:     "credit",
+			Duration: "day",
+			Price:    credit,
+			Legs:     []TicketLeg{{OptionSymbol: shortLeg.Symbol, Side: "sell_to_open", Quantity: int(models.ShortContracts(spread.Spread))}},
+		},
+	}
+	if len(spread.Spread.Legs) > 0 {
+		ticket.Order.Legs = ticket.Order.Legs[:0]
+		for _, leg := range spread.Spread.Legs {
+			side, quantity := "sell_to_open", leg.Quantity
+			if quantity < 0 {
+				side, quantity = "buy_to_open", -quantity
+			}
+			ticket.Order.Legs = append(ticket.Order.Legs, TicketLeg{OptionSymbol: leg.Option.Symbol, Side: side, Quantity: quantity})
+		}
+	}
+	if longLeg.Symbol != "" {
+		ticket.Width = round(math.Abs(shortLeg.Strike-longLeg.Strike), 2)
+		ticket.Order.Legs = append(ticket.Order.Legs, TicketLeg{OptionSymbol: longLeg.Symbol, Side: "buy_to_open", Quantity: 1})
+	}
+	if callLeg.Symbol != "" {
+		ticket.Order.Legs = append(ticket.Order.Legs, TicketLeg{OptionSymbol: callLeg.Symbol, Side: "sell_to_open", Quantity: 1})
+	}
+	if len(ticket.Order.Legs) == 1 {
+		ticket.Order.Class, ticket.Order.Type = "option", "limit"
+	}
+	return ticket
+}
+
+// TicketJSON returns the spread's ticket as single line JSON.
+func TicketJSON(spread models.SpreadWithProbabilities) (string, error) {
+	data, err := json.Marshal(NewTicket(spread))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func round(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(v*scale) / scale
+}
@@ -3,7 +3,7 @@
 # Function to build and run the program
 run_program() {
     # Build the program
-    go build -o stocd .
+    go build -o stocd ./cmd/stocd
 
     # Check if the build was successful
     if [ $? -eq 0 ]; then
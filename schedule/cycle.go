@@ -0,0 +1,119 @@
+package schedule
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	monthlyLeadDays = 7             // Calendar days before a monthly expiration during which scans are boosted
+	boostInterval   = 2 * time.Hour // Time between the extra runs of a boosted schedule during market hours
+)
+
+// Effort is how much work a scheduled run gets.
+type Effort int
+
+const (
+	EffortSkip   Effort = iota // Nothing changed since the previous scan
+	EffortNormal               // Run the scan as configured
+	EffortDeep                 // Cover the next monthly cycle too, show more results and run again during the day
+)
+
+func (e Effort) String() string {
+	switch e {
+	case EffortSkip:
+		return "skip"
+	case EffortDeep:
+		return "deep"
+	}
+	return "normal"
+}
+
+// WindowState summarizes the options chain in a schedule's DTE window at the time of a scan.
+type WindowState struct {
+	Expirations map[string]string `json:"expirations"` // Expiration date to Tradier expiration type (standard, weeklys, ...)
+	Fingerprint string            `json:"fingerprint"` // Hash of every contract's quote, volume and open interest
+}
+
+// WindowOf summarizes a chain fetched for a schedule's DTE window.
+func WindowOf(chain map[string]*tradier.OptionChain) WindowState {
+	state := WindowState{Expirations: make(map[string]string)}
+
+	expirations := make([]string, 0, len(chain))
+	for expiration := range chain {
+		expirations = append(expirations, expiration)
+	}
+	sort.Strings(expirations)
+
+	hash := sha256.New()
+	for _, expiration := range expirations {
+		options := chain[expiration].Options.Option
+		state.Expirations[expiration] = ""
+		if len(options) > 0 {
+			state.Expirations[expiration] = options[0].ExpirationType
+		}
+		for _, option := range options {
+			fmt.Fprintf(hash, "%s|%g|%g|%d|%d\n", option.Symbol, option.Bid, option.Ask, option.Volume, option.OpenInterest)
+		}
+	}
+	state.Fingerprint = hex.EncodeToString(hash.Sum(nil))
+
+	return state
+}
+
+// Plan decides the effort of a run of s from the current state of its DTE window, with the reason.
+// Scans go deep in the week before a monthly expiration and when new weeklies were listed since
+// the previous scan, and are skipped when nothing in the window changed.
+func (s Schedule) Plan(now time.Time, state WindowState) (Effort, string) {
+	monthly := MonthlyExpiration(now)
+	if days, _ := market.DaysToExpiration(monthly.Format(market.DateLayout), now); days <= monthlyLeadDays {
+		return EffortDeep, fmt.Sprintf("monthly expiration %s is %d days away", monthly.Format(market.DateLayout), days)
+	}
+
+	if s.Window == nil {
+		return EffortNormal, "first scan of this schedule"
+	}
+
+	var listed []string
+	for expiration, expirationType := range state.Expirations {
+		if _, ok := s.Window.Expirations[expiration]; !ok && expirationType != "standard" {
+			listed = append(listed, expiration)
+		}
+	}
+	if len(listed) > 0 {
+		sort.Strings(listed)
+		return EffortDeep, fmt.Sprintf("new weekly expirations listed: %v", listed)
+	}
+
+	if state.Fingerprint == s.Window.Fingerprint {
+		return EffortSkip, "nothing in the DTE window changed since the previous scan"
+	}
+	return EffortNormal, "the DTE window changed since the previous scan"
+}
+
+// MonthlyExpiration returns the standard monthly expiration, the third Friday of the month or the Thursday
+// before it when the Friday is an exchange holiday, on or after now's market date.
+func MonthlyExpiration(now time.Time) time.Time {
+	now = now.In(market.Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, market.Location)
+
+	for month := 0; ; month++ {
+		first := time.Date(now.Year(), now.Month()+time.Month(month), 1, 0, 0, 0, 0, market.Location)
+		expiration := market.MonthlyExpiration(first.Year(), first.Month())
+		if !expiration.Before(today) {
+			return expiration
+		}
+	}
+}
+
+// marketOpen reports whether t falls in a regular US equity session, which holidays skip and half days
+// end at 1:00 PM.
+func marketOpen(t time.Time) bool {
+	return market.IsOpen(t)
+}
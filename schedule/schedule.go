@@ -0,0 +1,109 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Schedule is a recurring scan. Times are in market time (America/New_York).
+type Schedule struct {
+	ID        int       `json:"id"`
+	ChannelID string    `json:"channel_id"`
+	Args      string    `json:"args"`      // Arguments of the /fcs command to run
+	Frequency string    `json:"frequency"` // daily, weekdays or a day of the week (mon-sun)
+	At        string    `json:"at"`        // Time of day as HH:MM
+	CreatedAt time.Time `json:"created_at"`
+	LastRun   time.Time `json:"last_run,omitempty"`
+
+	Window  *WindowState `json:"window,omitempty"`  // DTE window at the previous scan, nil before the first
+	Boosted bool         `json:"boosted,omitempty"` // The previous scan was deep, so the schedule also runs every two hours
+}
+
+// Validate checks the frequency and time of day.
+func (s Schedule) Validate() error {
+	if _, err := time.Parse("15:04", s.At); err != nil {
+		return fmt.Errorf("invalid time %q: expected HH:MM", s.At)
+	}
+	switch s.Frequency {
+	case "daily", "weekdays":
+		return nil
+	}
+	if _, ok := weekdays[s.Frequency]; ok {
+		return nil
+	}
+	return fmt.Errorf("invalid frequency %q: expected daily, weekdays or a day (mon-sun)", s.Frequency)
+}
+
+// Next returns the first run time strictly after t.
+func (s Schedule) Next(t time.Time) time.Time {
+	at, err := time.Parse("15:04", s.At)
+	if err != nil {
+		return time.Time{}
+	}
+
+	t = t.In(market.Location)
+	day := time.Date(t.Year(), t.Month(), t.Day(), at.Hour(), at.Minute(), 0, 0, market.Location)
+	for i := 0; i < 8; i++ {
+		candidate := day.AddDate(0, 0, i)
+		if candidate.After(t) && s.runsOn(candidate.Weekday()) {
+			return candidate
+		}
+	}
+	return time.Time{}
+}
+
+// Due reports whether a run was scheduled since the last one (or since the schedule was created).
+// Boosted schedules are also due every two hours while the market is open.
+func (s Schedule) Due(now time.Time) bool {
+	last := s.LastRun
+	if last.IsZero() {
+		last = s.CreatedAt
+	}
+	if s.Boosted && marketOpen(now) && now.Sub(last) >= boostInterval {
+		return true
+	}
+	next := s.Next(last)
+	return !next.IsZero() && !next.After(now)
+}
+
+func (s Schedule) runsOn(day time.Weekday) bool {
+	switch s.Frequency {
+	case "daily":
+		return true
+	case "weekdays":
+		return day != time.Saturday && day != time.Sunday
+	}
+	return weekdays[s.Frequency] == day
+}
+
+// Describe renders the schedule for listings.
+func (s Schedule) Describe() string {
+	description := fmt.Sprintf("%d: /fcs %s, %s at %s ET", s.ID, s.Args, s.Frequency, s.At)
+	if s.Boosted {
+		description += " (boosted: also every 2 hours during market hours)"
+	}
+	return description
+}
+
+// Parse splits "/schedule" arguments of the form "<fcs args...> <frequency> <HH:MM>".
+func Parse(text string) (Schedule, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 3 {
+		return Schedule{}, fmt.Errorf("expected <fcs arguments> <daily|weekdays|mon-sun> <HH:MM>")
+	}
+
+	s := Schedule{
+		Args:      strings.Join(fields[:len(fields)-2], " "),
+		Frequency: strings.ToLower(fields[len(fields)-2]),
+		At:        fields[len(fields)-1],
+	}
+	return s, s.Validate()
+}
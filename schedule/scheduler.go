@@ -0,0 +1,149 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const checkInterval = 30 * time.Second
+
+// Scheduler persists schedules to a JSON file and runs them when they are due.
+type Scheduler struct {
+	path      string
+	mu        sync.Mutex
+	schedules []Schedule
+}
+
+// Open loads the schedules at path, starting empty if the file does not exist yet.
+func Open(path string) (*Scheduler, error) {
+	scheduler := &Scheduler{path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return scheduler, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedules: %s", err)
+	}
+
+	if err := json.Unmarshal(data, &scheduler.schedules); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schedules: %s", err)
+	}
+
+	return scheduler, nil
+}
+
+// Add assigns the schedule the next free ID and saves it.
+func (s *Scheduler) Add(schedule Schedule) (Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule.ID = 1
+	for _, existing := range s.schedules {
+		schedule.ID = max(schedule.ID, existing.ID+1)
+	}
+	if schedule.CreatedAt.IsZero() {
+		schedule.CreatedAt = time.Now()
+	}
+	s.schedules = append(s.schedules, schedule)
+
+	return schedule, s.save()
+}
+
+// Delete removes the schedule with the given ID from channelID. Schedules can only be deleted
+// from the channel they post to.
+func (s *Scheduler) Delete(id int, channelID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, schedule := range s.schedules {
+		if schedule.ID == id && schedule.ChannelID == channelID {
+			s.schedules = append(s.schedules[:i], s.schedules[i+1:]...)
+			return s.save()
+		}
+	}
+	return fmt.Errorf("no schedule with ID %d in this channel", id)
+}
+
+// List returns the schedules posting to channelID, or every schedule if channelID is empty.
+func (s *Scheduler) List(channelID string) []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var schedules []Schedule
+	for _, schedule := range s.schedules {
+		if channelID == "" || schedule.ChannelID == channelID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules
+}
+
+// Record stores the DTE window seen by a run of the schedule with the given ID and boosts the
+// schedule when the run was deep.
+func (s *Scheduler) Record(id int, state WindowState, effort Effort) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.schedules {
+		if s.schedules[i].ID == id {
+			s.schedules[i].Window = &state
+			s.schedules[i].Boosted = effort == EffortDeep
+			return s.save()
+		}
+	}
+	return fmt.Errorf("no schedule with ID %d", id)
+}
+
+// Run checks the schedules every 30 seconds until stop is closed, calling run for each one that is
+// due. A schedule missed while the bot was down runs once when it comes back.
+func (s *Scheduler) Run(stop <-chan struct{}, run func(Schedule)) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			for _, schedule := range s.due(now) {
+				go run(schedule)
+			}
+		}
+	}
+}
+
+// due marks the schedules due at now as run and returns them.
+func (s *Scheduler) due(now time.Time) []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Schedule
+	for i := range s.schedules {
+		if s.schedules[i].Due(now) {
+			s.schedules[i].LastRun = now
+			due = append(due, s.schedules[i])
+		}
+	}
+	if len(due) > 0 {
+		if err := s.save(); err != nil {
+			log.Printf("Error saving schedules: %v", err)
+		}
+	}
+	return due
+}
+
+func (s *Scheduler) save() error {
+	data, err := json.MarshalIndent(s.schedules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedules: %s", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schedules: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package screener
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	neutralPutCall = 0.7  // Typical equity put/call volume ratio, above which puts are in unusual demand
+	neutralSkew    = 0.04 // Typical 25-delta risk reversal of equities, in volatility points
+)
+
+// Signal votes on the direction to sell premium in, from -1 (bear call spreads) to 1 (bull put
+// spreads). A signal returns NaN when it cannot be measured and is then left out.
+type Signal struct {
+	Name        string
+	Weight      float64
+	Description string
+	Vote        func(Input) float64
+}
+
+var signalsByName = map[string]Signal{
+	"trend": {
+		Name:        "trend",
+		Description: "price above its 50-day average and the 50-day above the 200-day, each counting half",
+		Vote:        trendVote,
+	},
+	"momentum": {
+		Name:        "momentum",
+		Description: "20-day return, full vote at 5% either way",
+		Vote:        momentumVote,
+	},
+	"put_call": {
+		Name:        "put_call",
+		Description: "put/call volume ratio above 0.7 favors selling the rich puts, below favors calls",
+		Vote:        putCallVote,
+	},
+	"skew": {
+		Name:        "skew",
+		Description: "25-delta risk reversal above 4 volatility points favors selling puts, below favors calls",
+		Vote:        skewVote,
+	},
+}
+
+// DefaultSignals is the signal list used when none is configured.
+const DefaultSignals = "trend=0.4,momentum=0.2,put_call=0.2,skew=0.2"
+
+// ParseSignals reads a signal list of the form "name=weight,name=weight", e.g. "trend=0.7,skew=0.3".
+// An empty spec selects DefaultSignals.
+func ParseSignals(spec string) ([]Signal, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = DefaultSignals
+	}
+
+	names, weights, err := parseWeights(spec, "signal", SignalNames())
+	if err != nil {
+		return nil, err
+	}
+
+	signals := make([]Signal, len(names))
+	for i, name := range names {
+		signals[i] = signalsByName[name]
+		signals[i].Weight = weights[i]
+	}
+	return signals, nil
+}
+
+// SignalNames lists the known direction signals in a stable order.
+func SignalNames() []string {
+	return []string{"trend", "momentum", "put_call", "skew"}
+}
+
+// Direction is the outcome of weighing the direction signals for a symbol.
+type Direction struct {
+	Score float64            // Weighted average vote, positive for bull put spreads
+	Votes map[string]float64 // Vote of each signal, NaN when it could not be measured
+}
+
+// Bullish reports whether the signals favor bull put spreads. A tie favors bull puts.
+func (d Direction) Bullish() bool {
+	return d.Score >= 0
+}
+
+// SpreadType names the credit spread the signals favor.
+func (d Direction) SpreadType() string {
+	if d.Bullish() {
+		return "Bull Put"
+	}
+	return "Bear Call"
+}
+
+// Describe renders the score and votes, e.g. "Bull Put (score 0.35: trend 1.00, momentum -0.20)".
+func (d Direction) Describe() string {
+	names := make([]string, 0, len(d.Votes))
+	for name := range d.Votes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	votes := make([]string, len(names))
+	for i, name := range names {
+		if math.IsNaN(d.Votes[name]) {
+			votes[i] = name + " n/a"
+		} else {
+			votes[i] = fmt.Sprintf("%s %.2f", name, d.Votes[name])
+		}
+	}
+	return fmt.Sprintf("%s (score %.2f: %s)", d.SpreadType(), d.Score, strings.Join(votes, ", "))
+}
+
+// ChooseDirection weighs the signals' votes for in.
+func ChooseDirection(in Input, signals []Signal) Direction {
+	direction := Direction{Votes: make(map[string]float64, len(signals))}
+
+	total, weight := 0.0, 0.0
+	for _, signal := range signals {
+		vote := signal.Vote(in)
+		direction.Votes[signal.Name] = vote
+		if math.IsNaN(vote) {
+			continue
+		}
+		total += signal.Weight * vote
+		weight += signal.Weight
+	}
+	if weight > 0 {
+		direction.Score = total / weight
+	}
+	return direction
+}
+
+func trendVote(in Input) float64 {
+	if len(in.Closes) < 200 {
+		return math.NaN()
+	}
+	price := in.Closes[len(in.Closes)-1]
+	sma50, sma200 := mean(in.Closes[len(in.Closes)-50:]), mean(in.Closes[len(in.Closes)-200:])
+	return (sign(price-sma50) + sign(sma50-sma200)) / 2
+}
+
+func momentumVote(in Input) float64 {
+	if len(in.Closes) < 21 {
+		return math.NaN()
+	}
+	past := in.Closes[len(in.Closes)-21]
+	if past <= 0 {
+		return math.NaN()
+	}
+	return clamp((in.Closes[len(in.Closes)-1]/past-1)/0.05, -1, 1)
+}
+
+func putCallVote(in Input) float64 {
+	puts, calls := 0, 0
+	for _, option := range in.options() {
+		if option.OptionType == "put" {
+			puts += option.Volume
+		} else {
+			calls += option.Volume
+		}
+	}
+	if puts+calls == 0 || calls == 0 {
+		return math.NaN()
+	}
+	return clamp((float64(puts)/float64(calls)-neutralPutCall)/0.5, -1, 1)
+}
+
+func skewVote(in Input) float64 {
+	rr := riskReversal(in)
+	if math.IsNaN(rr) {
+		return rr
+	}
+	return clamp((rr-neutralSkew)/0.05, -1, 1)
+}
+
+func mean(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
+
+func sign(value float64) float64 {
+	switch {
+	case value > 0:
+		return 1
+	case value < 0:
+		return -1
+	}
+	return 0
+}
+
+func clamp(value, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, value))
+}
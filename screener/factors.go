@@ -0,0 +1,191 @@
+package screener
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Factor scores one aspect of a symbol's options market. Values are normalized across the symbols
+// being screened, so only their order matters; higher values rank a symbol higher. A factor returns
+// NaN when it cannot be measured for a symbol, which then scores in the middle on it.
+type Factor struct {
+	Name        string
+	Weight      float64
+	Description string
+	Value       func(Input) float64
+}
+
+var factorsByName = map[string]Factor{
+	"volume": {
+		Name:        "volume",
+		Description: "log of the total option volume in the window",
+		Value:       totalVolume,
+	},
+	"liquidity": {
+		Name:        "liquidity",
+		Description: "one minus the volume-weighted bid/ask spread as a fraction of the mid price",
+		Value:       liquidity,
+	},
+	"max_pain": {
+		Name:        "max_pain",
+		Description: "closeness of the price to the max pain strike of the nearest expiration",
+		Value:       maxPainProximity,
+	},
+	"iv_rank": {
+		Name:        "iv_rank",
+		Description: "ATM implied volatility between the lowest (0) and highest (1) of the past year",
+		Value:       ivRank,
+	},
+	"iv_percentile": {
+		Name:        "iv_percentile",
+		Description: "fraction of the past year's days with a lower ATM implied volatility",
+		Value:       ivPercentile,
+	},
+	"skew": {
+		Name:        "skew",
+		Description: "25-delta risk reversal, the put implied volatility minus the call's",
+		Value:       riskReversal,
+	},
+	"term_slope": {
+		Name:        "term_slope",
+		Description: "front expiration ATM implied volatility minus the back expiration's",
+		Value:       termSlope,
+	},
+	"vrp": {
+		Name:        "vrp",
+		Description: "variance risk premium, the ATM implied variance at 30 DTE minus the realized variance forecast to it",
+		Value:       varianceRiskPremium,
+	},
+	"put_call": {
+		Name:        "put_call",
+		Description: "put to call volume ratio, higher when puts are in demand and richer to sell",
+		Value:       putCallRatio,
+	},
+	"liquidity_bias": {
+		Name:        "liquidity_bias",
+		Description: "share of open interest in puts minus the share in calls, from -1 to 1",
+		Value:       liquidityBias,
+	},
+}
+
+// DefaultFactors is the factor list used when none is configured.
+const DefaultFactors = "iv_rank=0.2,vrp=0.1,volume=0.15,liquidity=0.2,skew=0.1,put_call=0.1,max_pain=0.05,term_slope=0.05,liquidity_bias=0.05"
+
+// ParseFactors reads a factor list of the form "name=weight,name=weight", e.g. "volume=0.5,liquidity=0.5".
+// An empty spec selects DefaultFactors.
+func ParseFactors(spec string) ([]Factor, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = DefaultFactors
+	}
+
+	names, weights, err := parseWeights(spec, "factor", FactorNames())
+	if err != nil {
+		return nil, err
+	}
+
+	factors := make([]Factor, len(names))
+	for i, name := range names {
+		factors[i] = factorsByName[name]
+		factors[i].Weight = weights[i]
+	}
+	return factors, nil
+}
+
+// parseWeights reads a "name=weight,name=weight" list, checking each name is one of known and
+// each weight is a non-negative number. kind names the entries in errors.
+func parseWeights(spec, kind string, known []string) ([]string, []float64, error) {
+	var names []string
+	var weights []float64
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid %s %q: expected name=weight", kind, entry)
+		}
+		if !contains(known, name) {
+			return nil, nil, fmt.Errorf("unknown %s %q (known: %s)", kind, name, strings.Join(known, ", "))
+		}
+		if seen[name] {
+			return nil, nil, fmt.Errorf("%s %s is given more than once", kind, name)
+		}
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight < 0 {
+			return nil, nil, fmt.Errorf("invalid weight %q for %s %s: expected a non-negative number", value, kind, name)
+		}
+		seen[name] = true
+		names = append(names, name)
+		weights = append(weights, weight)
+	}
+	return names, weights, nil
+}
+
+func contains(list []string, name string) bool {
+	for _, entry := range list {
+		if entry == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FactorNames lists the known factors in a stable order.
+func FactorNames() []string {
+	return []string{"volume", "liquidity", "max_pain", "iv_rank", "iv_percentile", "skew", "term_slope", "vrp", "put_call", "liquidity_bias"}
+}
+
+func totalVolume(in Input) float64 {
+	total := 0
+	for _, option := range in.options() {
+		total += option.Volume
+	}
+	return math.Log1p(float64(total))
+}
+
+func liquidity(in Input) float64 {
+	weighted, volume := 0.0, 0.0
+	for _, option := range in.options() {
+		mid := (option.Bid + option.Ask) / 2
+		if mid <= 0 || option.Ask < option.Bid {
+			continue
+		}
+		weight := float64(option.Volume) + 1 // Quoted contracts that did not trade still count a little
+		weighted += weight * (option.Ask - option.Bid) / mid
+		volume += weight
+	}
+	if volume == 0 {
+		return 0
+	}
+	return math.Max(0, 1-weighted/volume)
+}
+
+func putCallRatio(in Input) float64 {
+	puts, calls := 0, 0
+	for _, option := range in.options() {
+		if option.OptionType == "put" {
+			puts += option.Volume
+		} else {
+			calls += option.Volume
+		}
+	}
+	if calls == 0 {
+		return float64(puts)
+	}
+	return float64(puts) / float64(calls)
+}
+
+func liquidityBias(in Input) float64 {
+	puts, calls := 0, 0
+	for _, option := range in.options() {
+		if option.OptionType == "put" {
+			puts += option.OpenInterest
+		} else {
+			calls += option.OpenInterest
+		}
+	}
+	if puts+calls == 0 {
+		return 0
+	}
+	return float64(puts-calls) / float64(puts+calls)
+}
@@ -0,0 +1,67 @@
+package screener
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// MaxPain returns the strike at which the options of one expiration pay out the least in total
+// intrinsic value, weighted by open interest. It reports false when no contract has open interest.
+func MaxPain(options []tradier.Option) (float64, bool) {
+	strikes := make(map[float64]bool)
+	openInterest := 0
+	for _, option := range options {
+		strikes[option.Strike] = true
+		openInterest += option.OpenInterest
+	}
+	if openInterest == 0 {
+		return 0, false
+	}
+
+	best, bestPayout := 0.0, math.Inf(1)
+	for settle := range strikes {
+		payout := 0.0
+		for _, option := range options {
+			if option.OptionType == "put" {
+				payout += float64(option.OpenInterest) * math.Max(option.Strike-settle, 0)
+			} else {
+				payout += float64(option.OpenInterest) * math.Max(settle-option.Strike, 0)
+			}
+		}
+		// Ties go to the lower strike so the result does not depend on map order
+		if payout < bestPayout || (payout == bestPayout && settle < best) {
+			best, bestPayout = settle, payout
+		}
+	}
+	return best, true
+}
+
+// MaxPainByExpiration computes the max pain strike of every expiration in the chain that has open interest.
+func MaxPainByExpiration(chain map[string]*tradier.OptionChain) map[string]float64 {
+	maxPain := make(map[string]float64, len(chain))
+	for expiration, expirationChain := range chain {
+		if strike, ok := MaxPain(expirationChain.Options.Option); ok {
+			maxPain[expiration] = strike
+		}
+	}
+	return maxPain
+}
+
+// maxPainProximity scores how close the price is to the max pain strike of the nearest expiration,
+// 1 at max pain falling off with the distance as a fraction of the price.
+func maxPainProximity(in Input) float64 {
+	maxPain := MaxPainByExpiration(in.Chain)
+	if len(maxPain) == 0 || in.Price <= 0 {
+		return 0
+	}
+
+	expirations := make([]string, 0, len(maxPain))
+	for expiration := range maxPain {
+		expirations = append(expirations, expiration)
+	}
+	sort.Strings(expirations)
+
+	return math.Max(0, 1-math.Abs(in.Price-maxPain[expirations[0]])/in.Price)
+}
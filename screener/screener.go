@@ -0,0 +1,161 @@
+package screener
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/bcdannyboy/stocd/archive"
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// Default DTE window of the chains a symbol is screened on, matching the /fcs defaults.
+const (
+	DefaultMinDTE = 14
+	DefaultMaxDTE = 45
+)
+
+// Input is the market data a symbol is screened on.
+type Input struct {
+	Symbol string
+	Price  float64
+	Chain  map[string]*tradier.OptionChain // Options chain in the screened DTE window
+
+	IVHistory []float64            // Daily ATM implied volatility over the past year, for IV rank and percentile
+	Closes    []float64            // Daily closes, oldest first, for the trend and momentum direction signals
+	Bars      tradier.QuoteHistory // Daily bars, oldest first, for the realized volatility forecast of the variance risk premium
+}
+
+func (in Input) options() []tradier.Option {
+	var options []tradier.Option
+	for _, chain := range in.Chain {
+		options = append(options, chain.Options.Option...)
+	}
+	return options
+}
+
+// Result is a symbol's screener score with the raw value of every factor.
+type Result struct {
+	Symbol  string
+	Price   float64
+	Score   float64            // Weighted average of the normalized factor values, from 0 to 1
+	Factors map[string]float64 // Raw factor values by name
+	MaxPain map[string]float64 // Max pain strike by expiration
+}
+
+// Fetch loads a year of daily closes and the options chain between minDTE and maxDTE for symbol.
+func Fetch(symbol, token string, minDTE, maxDTE int) (Input, error) {
+	quotes, err := tradier.GET_QUOTES(symbol, market.Now().AddDate(-1, 0, 0).Format(market.DateLayout), market.Today(), "daily", token)
+	if err != nil {
+		return Input{}, fmt.Errorf("failed to fetch quotes for %s: %s", symbol, err)
+	}
+	if len(quotes.History.Day) == 0 {
+		return Input{}, fmt.Errorf("no recent quotes for %s", symbol)
+	}
+
+	chain, err := tradier.GET_OPTIONS_CHAIN(symbol, token, minDTE, maxDTE)
+	if err != nil {
+		return Input{}, fmt.Errorf("failed to fetch options chain for %s: %s", symbol, err)
+	}
+
+	closes := make([]float64, len(quotes.History.Day))
+	for i, day := range quotes.History.Day {
+		closes[i] = day.Close
+	}
+	return Input{Symbol: symbol, Price: closes[len(closes)-1], Chain: chain, Closes: closes, Bars: *quotes}, nil
+}
+
+// ScreenSymbols fetches and screens symbols, returning the results and the symbols that could not be
+// fetched. The IV history is read from the archived chains, if an archive is given.
+func ScreenSymbols(symbols []string, token string, minDTE, maxDTE int, factors []Factor, history *archive.Archive) ([]Result, []string) {
+	var inputs []Input
+	var failed []string
+	for _, symbol := range symbols {
+		input, err := Fetch(symbol, token, minDTE, maxDTE)
+		if err != nil {
+			log.Printf("Error screening %s: %v", symbol, err)
+			failed = append(failed, symbol)
+			continue
+		}
+		input.IVHistory, err = IVHistory(history, symbol, market.Now())
+		if err != nil {
+			log.Printf("Error loading IV history for %s: %v", symbol, err)
+		}
+		inputs = append(inputs, input)
+	}
+	return Screen(inputs, factors), failed
+}
+
+// Screen scores every input on the factors and returns the results, best first. Each factor is
+// min-max normalized across the inputs before weighting.
+func Screen(inputs []Input, factors []Factor) []Result {
+	results := make([]Result, len(inputs))
+	for i, in := range inputs {
+		results[i] = Result{Symbol: in.Symbol, Price: in.Price, Factors: make(map[string]float64, len(factors)), MaxPain: MaxPainByExpiration(in.Chain)}
+		for _, factor := range factors {
+			results[i].Factors[factor.Name] = factor.Value(in)
+		}
+	}
+
+	totalWeight := 0.0
+	for _, factor := range factors {
+		totalWeight += factor.Weight
+	}
+	if totalWeight == 0 {
+		return results
+	}
+
+	for _, factor := range factors {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, result := range results {
+			if value := result.Factors[factor.Name]; !math.IsNaN(value) {
+				lo, hi = math.Min(lo, value), math.Max(hi, value)
+			}
+		}
+		for i := range results {
+			normalized := 0.5 // Unmeasured, or every input scores the same on this factor
+			if value := results[i].Factors[factor.Name]; hi > lo && !math.IsNaN(value) {
+				normalized = (value - lo) / (hi - lo)
+			}
+			results[i].Score += normalized * factor.Weight / totalWeight
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results
+}
+
+// Describe renders a result with the factors in the order given, followed by a line with the max
+// pain strike of each expiration.
+func Describe(rank int, result Result, factors []Factor) string {
+	text := fmt.Sprintf("%d. %s (%.2f): score %.2f", rank, result.Symbol, result.Price, result.Score)
+	for i, factor := range factors {
+		separator := ", "
+		if i == 0 {
+			separator = " - "
+		}
+		if value := result.Factors[factor.Name]; math.IsNaN(value) {
+			text += fmt.Sprintf("%s%s n/a", separator, factor.Name)
+		} else {
+			text += fmt.Sprintf("%s%s %.2f", separator, factor.Name, value)
+		}
+	}
+
+	expirations := make([]string, 0, len(result.MaxPain))
+	for expiration := range result.MaxPain {
+		expirations = append(expirations, expiration)
+	}
+	sort.Strings(expirations)
+	for i, expiration := range expirations {
+		separator := ", "
+		if i == 0 {
+			separator = "\n    Max pain: "
+		}
+		text += fmt.Sprintf("%s%s %.2f", separator, expiration, result.MaxPain[expiration])
+	}
+	return text
+}
@@ -0,0 +1,181 @@
+package screener
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bcdannyboy/stocd/archive"
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	ivTargetDTE  = 30 // Expiration the ATM implied volatility is read from, the one closest to this DTE
+	minIVHistory = 20 // Daily observations needed before IV rank and percentile are scored
+)
+
+// IVHistory reads the ATM implied volatility of symbol from each day's archived chain over the year
+// before now, oldest first. Days whose chain has no usable quotes are skipped.
+func IVHistory(a *archive.Archive, symbol string, now time.Time) ([]float64, error) {
+	if a == nil {
+		return nil, nil
+	}
+	snapshots, err := a.Chains(symbol, 365, now)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := now.AddDate(-1, 0, 0)
+	var history []float64
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if snapshots[i].ScannedAt.Before(cutoff) {
+			continue
+		}
+		if iv := atmVolatility(snapshots[i].Chain, snapshots[i].ScannedAt); iv > 0 {
+			history = append(history, iv)
+		}
+	}
+	return history, nil
+}
+
+// atmVolatility averages the implied volatility of the call and put closest to 50 delta in the
+// expiration closest to 30 DTE. Using delta rather than the strike nearest the price lets the same
+// measure be read from archived chains, which do not store the price.
+func atmVolatility(chain map[string]*tradier.OptionChain, now time.Time) float64 {
+	options := expirationNear(chain, now, ivTargetDTE)
+	return average(deltaVolatility(options, "call", 0.5), deltaVolatility(options, "put", -0.5))
+}
+
+// expirationNear returns the options of the expiration whose DTE is closest to target.
+func expirationNear(chain map[string]*tradier.OptionChain, now time.Time, target int) []tradier.Option {
+	var options []tradier.Option
+	best := math.MaxInt
+	for expiration, expirationChain := range chain {
+		dte, err := market.DaysToExpiration(expiration, now)
+		if err != nil || dte < 0 {
+			continue
+		}
+		if distance := abs(dte - target); distance < best {
+			best = distance
+			options = expirationChain.Options.Option
+		}
+	}
+	return options
+}
+
+// deltaVolatility returns the mid implied volatility of the option of the given type whose delta
+// is closest to target, or 0 when no option of that type has both.
+func deltaVolatility(options []tradier.Option, optionType string, target float64) float64 {
+	iv, best := 0.0, math.Inf(1)
+	for _, option := range options {
+		if option.OptionType != optionType || option.Greeks.MidIv <= 0 || option.Greeks.Delta == 0 {
+			continue
+		}
+		if distance := math.Abs(option.Greeks.Delta - target); distance < best {
+			iv, best = option.Greeks.MidIv, distance
+		}
+	}
+	return iv
+}
+
+// ivRank places the current ATM IV between the lowest and highest of the past year, from 0 to 1.
+func ivRank(in Input) float64 {
+	current := atmVolatility(in.Chain, market.Now())
+	if current <= 0 || len(in.IVHistory) < minIVHistory {
+		return math.NaN()
+	}
+	lo, hi := current, current
+	for _, iv := range in.IVHistory {
+		lo, hi = math.Min(lo, iv), math.Max(hi, iv)
+	}
+	if hi == lo {
+		return 0.5
+	}
+	return (current - lo) / (hi - lo)
+}
+
+// ivPercentile is the fraction of the past year's days with a lower ATM IV than today.
+func ivPercentile(in Input) float64 {
+	current := atmVolatility(in.Chain, market.Now())
+	if current <= 0 || len(in.IVHistory) < minIVHistory {
+		return math.NaN()
+	}
+	below := 0
+	for _, iv := range in.IVHistory {
+		if iv < current {
+			below++
+		}
+	}
+	return float64(below) / float64(len(in.IVHistory))
+}
+
+// riskReversal is the 25-delta put IV minus the 25-delta call IV at the expiration closest to 30 DTE.
+// A larger put skew means puts are bid up relative to calls.
+func riskReversal(in Input) float64 {
+	options := expirationNear(in.Chain, market.Now(), ivTargetDTE)
+	put, call := deltaVolatility(options, "put", -0.25), deltaVolatility(options, "call", 0.25)
+	if put <= 0 || call <= 0 {
+		return math.NaN()
+	}
+	return put - call
+}
+
+// termSlope is the front expiration's ATM IV minus the back expiration's, positive when the term
+// structure is inverted and near-term premium is rich.
+func termSlope(in Input) float64 {
+	now := market.Now()
+	var expirations []string
+	for expiration := range in.Chain {
+		if dte, err := market.DaysToExpiration(expiration, now); err == nil && dte >= 0 {
+			expirations = append(expirations, expiration)
+		}
+	}
+	if len(expirations) < 2 {
+		return math.NaN()
+	}
+	sort.Strings(expirations)
+
+	front := in.Chain[expirations[0]].Options.Option
+	back := in.Chain[expirations[len(expirations)-1]].Options.Option
+	frontIV := average(deltaVolatility(front, "call", 0.5), deltaVolatility(front, "put", -0.5))
+	backIV := average(deltaVolatility(back, "call", 0.5), deltaVolatility(back, "put", -0.5))
+	if frontIV <= 0 || backIV <= 0 {
+		return math.NaN()
+	}
+	return frontIV - backIV
+}
+
+// varianceRiskPremium is the ATM implied variance at 30 DTE minus the realized variance forecast over
+// the same horizon, the premium sold by selling the symbol's options.
+func varianceRiskPremium(in Input) float64 {
+	term := models.ATMTermStructure(in.Chain, in.Price, market.Now())
+	premium, ok := models.VarianceRiskPremiumAt(term, in.Bars, ivTargetDTE)
+	if !ok {
+		return math.NaN()
+	}
+	return premium.Premium
+}
+
+// average returns the mean of the positive values, or 0 if there are none.
+func average(values ...float64) float64 {
+	total, count := 0.0, 0
+	for _, value := range values {
+		if value > 0 {
+			total += value
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
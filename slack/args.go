@@ -0,0 +1,226 @@
+package stocdslack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type paramKind int
+
+const (
+	stringParam paramKind = iota
+	floatParam
+	intParam
+)
+
+// param describes one slash command argument. Arguments can be given positionally, in schema
+// order, or by name as name=value.
+type param struct {
+	name        string
+	kind        paramKind
+	def         string // Default when omitted, empty for required parameters
+	env         string // Environment variable overriding the default, if any
+	description string
+}
+
+func (p param) required() bool {
+	return p.def == ""
+}
+
+// defaultValue is the value of the parameter when omitted, from its environment variable if set.
+func (p param) defaultValue() string {
+	if value := os.Getenv(p.env); p.env != "" && value != "" {
+		return value
+	}
+	return p.def
+}
+
+// commandSchema is the parameter list of a slash command, used for parsing and for /help.
+type commandSchema struct {
+	command     string
+	description string
+	params      []param
+}
+
+var fcsSchema = commandSchema{
+	command:     "/fcs",
+	description: "Find credit spreads, showing the top results with a button for more",
+	params: []param{
+		{name: "symbol", kind: stringParam, description: "underlying symbol, e.g. AAPL, the futures symbol /ES or the coin BTC-USD, or comma-separated symbols ranked together, e.g. AAPL,MSFT"},
+		{name: "indicator", kind: stringParam, def: "1", description: "> 0 for bull put spreads, a number <= 0 for bear call spreads, auto to choose from trend, put/call and skew signals, best to screen both sides and keep the better one, both to rank both sides together, or a strategy: csp, cc, strangle, straddle, putratio, callratio, lizard or a declared strategy such as iron_condor"},
+		{name: "minDTE", kind: intParam, def: "14", env: "MIN_DTE", description: "minimum days to expiration"},
+		{name: "maxDTE", kind: intParam, def: "45", env: "MAX_DTE", description: "maximum days to expiration"},
+		{name: "minRoR", kind: floatParam, def: "0.15", env: "MIN_ROR", description: "minimum return on risk"},
+		{name: "rfr", kind: floatParam, def: "0.04", env: "RISK_FREE_RATE", description: "annual risk-free rate"},
+		{name: "top", kind: intParam, def: "0", description: "spreads shown per page, 0 for the bot's default"},
+		{name: "expirations", kind: stringParam, def: "all", env: "EXPIRATION_TYPES", description: "expiration cycles to trade: all, or comma-separated weeklys, monthlys, quarterlys and eom"},
+		{name: "shortDated", kind: stringParam, def: "false", env: "SHORT_DATED", description: "true to simulate the trading hours left to expiration and intraday volatility, for 0-3 DTE spreads with minDTE=0"},
+		{name: "sort", kind: stringParam, def: "score", env: "SORT_BY", description: "comma-separated keys the results are ranked by, ties broken by the next: score, ev, pop, ror, theta_per_day, theta_vega, theta_credit or dollar_var"},
+	},
+}
+
+var scanAllSchema = commandSchema{
+	command:     "/scanall",
+	description: "Scan every symbol on this channel's watchlist and rank the best spreads across all of them",
+	params: []param{
+		{name: "indicator", kind: stringParam, def: "1", description: "> 0 for bull put spreads, a number <= 0 for bear call spreads, auto to choose from trend, put/call and skew signals, best to screen both sides and keep the better one, both to rank both sides together, or a strategy: csp, cc, strangle, straddle, putratio, callratio, lizard or a declared strategy such as iron_condor"},
+		{name: "minDTE", kind: intParam, def: "14", env: "MIN_DTE", description: "minimum days to expiration"},
+		{name: "maxDTE", kind: intParam, def: "45", env: "MAX_DTE", description: "maximum days to expiration"},
+		{name: "minRoR", kind: floatParam, def: "0.15", env: "MIN_ROR", description: "minimum return on risk"},
+		{name: "rfr", kind: floatParam, def: "0.04", env: "RISK_FREE_RATE", description: "annual risk-free rate"},
+		{name: "top", kind: intParam, def: "0", description: "spreads shown, 0 for the bot's default"},
+		{name: "expirations", kind: stringParam, def: "all", env: "EXPIRATION_TYPES", description: "expiration cycles to trade: all, or comma-separated weeklys, monthlys, quarterlys and eom"},
+		{name: "shortDated", kind: stringParam, def: "false", env: "SHORT_DATED", description: "true to simulate the trading hours left to expiration and intraday volatility, for 0-3 DTE spreads with minDTE=0"},
+		{name: "sort", kind: stringParam, def: "score", env: "SORT_BY", description: "comma-separated keys the results are ranked by, ties broken by the next: score, ev, pop, ror, theta_per_day, theta_vega, theta_credit or dollar_var"},
+	},
+}
+
+var hedgeSchema = commandSchema{
+	command:     "/hedge",
+	description: "Aggregate the greeks of the open positions and suggest the ETF shares and options that flatten their delta and vega",
+	params: []param{
+		{name: "source", kind: stringParam, def: "auto", description: "positions to hedge: broker, paper, monitor, or auto for the brokerage account when configured and the paper trades otherwise"},
+		{name: "with", kind: stringParam, def: "SPY", description: "ETF the positions are hedged with, e.g. SPY or QQQ"},
+		{name: "vega", kind: stringParam, def: "false", description: "true to also flatten vega with an at-the-money call of the ETF"},
+	},
+}
+
+var termSchema = commandSchema{
+	command:     "/term",
+	description: "Show the simulated probability of finishing above or below a strike for each expiration",
+	params: []param{
+		{name: "symbol", kind: stringParam, description: "underlying symbol, e.g. AAPL, the futures symbol /ES or the coin BTC-USD"},
+		{name: "strike", kind: floatParam, description: "strike to report probabilities for"},
+		{name: "rfr", kind: floatParam, def: "0.04", env: "RISK_FREE_RATE", description: "annual risk-free rate"},
+		{name: "maxDTE", kind: intParam, def: "365", description: "latest expiration to include, in days"},
+	},
+}
+
+// commandArgs holds validated slash command arguments by parameter name.
+type commandArgs struct {
+	values map[string]string
+}
+
+func (a commandArgs) String(name string) string {
+	return a.values[name]
+}
+
+func (a commandArgs) Float(name string) float64 {
+	value, _ := strconv.ParseFloat(a.values[name], 64)
+	return value
+}
+
+func (a commandArgs) Int(name string) int {
+	value, _ := strconv.Atoi(a.values[name])
+	return value
+}
+
+// parse matches the command text against the schema, filling defaults and checking that every
+// value has the expected type. Names are matched case-insensitively.
+func (s commandSchema) parse(text string) (commandArgs, error) {
+	values := make(map[string]string)
+	var positional []string
+
+	for _, field := range strings.Fields(text) {
+		name, value, named := strings.Cut(field, "=")
+		if !named {
+			positional = append(positional, field)
+			continue
+		}
+		p, ok := s.lookup(name)
+		if !ok {
+			return commandArgs{}, fmt.Errorf("unknown parameter %q", name)
+		}
+		if _, dup := values[p.name]; dup {
+			return commandArgs{}, fmt.Errorf("%s is given more than once", p.name)
+		}
+		values[p.name] = value
+	}
+
+	// Positional arguments fill the parameters not given by name, in order
+	for _, p := range s.params {
+		if len(positional) == 0 {
+			break
+		}
+		if _, ok := values[p.name]; ok {
+			continue
+		}
+		values[p.name] = positional[0]
+		positional = positional[1:]
+	}
+	if len(positional) > 0 {
+		return commandArgs{}, fmt.Errorf("too many arguments: %s", strings.Join(positional, " "))
+	}
+
+	for _, p := range s.params {
+		value, ok := values[p.name]
+		if !ok || value == "" {
+			if p.required() {
+				return commandArgs{}, fmt.Errorf("missing required parameter %s (%s)", p.name, p.description)
+			}
+			value = p.defaultValue()
+			values[p.name] = value
+		}
+
+		switch p.kind {
+		case floatParam:
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return commandArgs{}, fmt.Errorf("invalid %s %q: expected a number", p.name, value)
+			}
+		case intParam:
+			if _, err := strconv.Atoi(value); err != nil {
+				return commandArgs{}, fmt.Errorf("invalid %s %q: expected a whole number", p.name, value)
+			}
+		}
+	}
+
+	return commandArgs{values: values}, nil
+}
+
+func (s commandSchema) lookup(name string) (param, bool) {
+	for _, p := range s.params {
+		if strings.EqualFold(p.name, name) {
+			return p, true
+		}
+	}
+	return param{}, false
+}
+
+// format renders args back into command text with every parameter named, e.g. for re-running a command.
+func (s commandSchema) format(args commandArgs) string {
+	parts := make([]string, 0, len(s.params))
+	for _, p := range s.params {
+		parts = append(parts, p.name+"="+args.values[p.name])
+	}
+	return strings.Join(parts, " ")
+}
+
+// usage renders the command's synopsis, e.g. "/term <symbol> <strike> [rfr=0.04] [maxDTE=365]".
+func (s commandSchema) usage() string {
+	parts := []string{s.command}
+	for _, p := range s.params {
+		if p.required() {
+			parts = append(parts, "<"+p.name+">")
+		} else {
+			parts = append(parts, fmt.Sprintf("[%s=%s]", p.name, p.defaultValue()))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// help renders the synopsis followed by a line per parameter.
+func (s commandSchema) help() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s - %s\n", s.usage(), s.description))
+	for _, p := range s.params {
+		b.WriteString(fmt.Sprintf("    %s: %s\n", p.name, p.description))
+	}
+	return b.String()
+}
+
+// usageError explains a parse or validation failure together with the command's usage.
+func (s commandSchema) usageError(err error) string {
+	return fmt.Sprintf("%s\nUsage: %s\nArguments may be positional or named as name=value", err, s.usage())
+}
@@ -0,0 +1,20 @@
+package stocdslack
+
+// spreadTypeLabel names a spread type in messages.
+func spreadTypeLabel(spreadType string) string {
+	if spreadType == "Both" {
+		return "Bull Put and Bear Call"
+	}
+	return spreadType
+}
+
+// strategyName names the positions of a spread type in progress messages, e.g. "Bull Put Spreads".
+func strategyName(spreadType string) string {
+	switch spreadType {
+	case "Both":
+		return "Bull Put and Bear Call Spreads"
+	case "Bull Put", "Bear Call", "Put Ratio", "Call Ratio":
+		return spreadType + " Spreads"
+	}
+	return spreadType + "s"
+}
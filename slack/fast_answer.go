@@ -0,0 +1,55 @@
+package stocdslack
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd"
+	"github.com/bcdannyboy/stocd/positions"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const (
+	fastAnswerTopN   = 5
+	fastAnswerBudget = 20 * time.Second // Screening time, leaving room for fetching data within ~30 seconds
+)
+
+// preliminaryAnswer is a posted analytic ranking, edited once the simulated ranking is available.
+type preliminaryAnswer struct {
+	timestamp string
+	text      string
+}
+
+// postPreliminary screens the scan's chain analytically and posts the top spreads to the scan's thread.
+func (h *FCSHandler) postPreliminary(client *socketmode.Client, channelID, timestamp string, scan stocd.Scan, minRoR float64) preliminaryAnswer {
+	spreads := positions.QuickScreen(scan.Data.Chain, scan.Data.Price, scan.RiskFreeRate, minRoR, scan.Now, scan.SpreadType, scan.Options, fastAnswerTopN, fastAnswerBudget)
+	if len(spreads) == 0 {
+		return preliminaryAnswer{}
+	}
+
+	f := h.config.Report
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("Preliminary top %d %s spreads from an analytic screen (full simulation still running):\n", len(spreads), spreadTypeLabel(scan.SpreadType)))
+	for i, spread := range spreads {
+		msg.WriteString(fmt.Sprintf("  %d. %s / %s: credit %s, ROR %s, PoP %s, breakeven %s\n", i+1,
+			spread.Spread.ShortLeg.Option.Symbol, spread.Spread.LongLeg.Option.Symbol, f.Number(spread.Spread.SpreadCredit, 2),
+			f.Percent(spread.Spread.ROR, 2), f.Percent(spread.Probability.AverageProbability, 2), f.Number(spread.Breakeven.Price, 2)))
+	}
+
+	_, ts, err := client.PostMessage(channelID, slack.MsgOptionText(msg.String(), false), slack.MsgOptionTS(timestamp))
+	if err != nil {
+		return preliminaryAnswer{}
+	}
+	return preliminaryAnswer{timestamp: ts, text: msg.String()}
+}
+
+// supersede marks a preliminary answer as replaced by the fully simulated ranking.
+func (a preliminaryAnswer) supersede(client *socketmode.Client, channelID string) {
+	if a.timestamp == "" {
+		return
+	}
+	text := strings.Replace(a.text, "(full simulation still running)", "(superseded by the fully simulated ranking below)", 1)
+	client.UpdateMessage(channelID, a.timestamp, slack.MsgOptionText(text, false))
+}
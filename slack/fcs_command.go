@@ -1,261 +1,396 @@
 package stocdslack
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"math"
-	"os"
-	"sort"
+	"log"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/bcdannyboy/stocd"
+	"github.com/bcdannyboy/stocd/market"
 	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/notify"
 	"github.com/bcdannyboy/stocd/positions"
-	"github.com/bcdannyboy/stocd/tradier"
+	"github.com/bcdannyboy/stocd/progress"
+	"github.com/bcdannyboy/stocd/report"
+	"github.com/bcdannyboy/stocd/results"
+	"github.com/bcdannyboy/stocd/slippage"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/socketmode"
 )
 
 const (
-	weightLiquidity   = 0.5
-	weightProbability = 0.3
-	weightVaR         = 0.1
-	weightES          = 0.1
+	progressInterval = 2 * time.Second // Interval between progress snapshots of a scan
+	statusQueueSize  = 1000            // Calibration messages waiting to be posted before more are dropped
 )
 
-type FCSHandler struct{}
-
-var calibrationCache sync.Map // Cache to store calibrated models for each symbol
+type FCSHandler struct {
+	fills  *slippage.Store
+	config Config
+	pages  *resultPages
+}
 
-func NewFCSHandler() *FCSHandler {
-	return &FCSHandler{}
+func NewFCSHandler(fills *slippage.Store, config Config) *FCSHandler {
+	return &FCSHandler{fills: fills, config: config, pages: newResultPages()}
 }
 
 func (h *FCSHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Client) error {
 	data := evt.Data.(slack.SlashCommand)
-	args := strings.Fields(data.Text)
+	return h.startScan(client, data.ChannelID, data.Text)
+}
 
-	if len(args) != 6 {
-		_, _, err := client.PostMessage(data.ChannelID,
-			slack.MsgOptionText("Invalid number of arguments. Usage: /fcs <symbol> <indicator> <minDTE> <maxDTE> <minRoR> <RFR>", false))
+// startScan validates /fcs arguments and runs the scan in the background, posting to channelID.
+func (h *FCSHandler) startScan(client *socketmode.Client, channelID, text string) error {
+	args, err := fcsSchema.parse(text)
+	if err == nil {
+		err = validateFCSArgs(args)
+	}
+	if err != nil {
+		_, _, err := client.PostMessage(channelID, slack.MsgOptionText(fcsSchema.usageError(err), false))
 		return err
 	}
 
-	symbol := args[0]
-	indicator, _ := strconv.ParseFloat(args[1], 64)
-	minDTE, _ := strconv.ParseFloat(args[2], 64)
-	maxDTE, _ := strconv.ParseFloat(args[3], 64)
-	minRoR, _ := strconv.ParseFloat(args[4], 64)
-	rfr, _ := strconv.ParseFloat(args[5], 64)
+	symbol := strings.ToUpper(args.String("symbol"))
+	indicator := strings.ToLower(args.String("indicator"))
+	minDTE := float64(args.Int("minDTE"))
+	maxDTE := float64(args.Int("maxDTE"))
+	minRoR := args.Float("minRoR")
+	rfr := args.Float("rfr")
 
-	indicators := map[string]float64{symbol: indicator}
+	topN := args.Int("top")
+	if topN <= 0 {
+		topN = h.config.TopN
+	}
+	if topN <= 0 {
+		topN = defaultTopN
+	}
+
+	if symbols := strings.Split(symbol, ","); len(symbols) > 1 {
+		_, ts, err := client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Starting credit spread analysis for %d symbols: %s", len(symbols), strings.Join(symbols, ", ")), false))
+		if err != nil {
+			return err
+		}
+		go h.scanSymbols(client, channelID, ts, symbols, args, topN)
+		return nil
+	}
 
 	// Send initial message
-	_, ts, err := client.PostMessage(data.ChannelID,
-		slack.MsgOptionText(fmt.Sprintf("Starting credit spread analysis for: %s %f %d %d %f %f", symbol, indicator, int(minDTE), int(maxDTE), minRoR, rfr), false))
+	_, ts, err := client.PostMessage(channelID,
+		slack.MsgOptionText(fmt.Sprintf("Starting credit spread analysis for: %s %s %d %d %f %f", symbol, indicator, int(minDTE), int(maxDTE), minRoR, rfr), false))
 	if err != nil {
 		return err
 	}
 
 	// Run STOCD with progress updates
-	go runSTOCDWithProgress(client, data.ChannelID, ts, indicators, minDTE, maxDTE, rfr, minRoR)
+	scanOptions := positions.ScanOptionsFromEnv()
+	scanOptions.Slippage = h.fills
+	scanOptions.Fill = h.config.Fill
+	scanOptions.ExpirationTypes, _ = positions.ParseExpirationTypes(args.String("expirations")) // Validated by validateFCSArgs
+	scanOptions.ShortDated, _ = strconv.ParseBool(args.String("shortDated"))
+
+	go h.runSTOCDWithProgress(client, channelID, ts, symbol, indicator, minDTE, maxDTE, rfr, minRoR, topN, scanOptions, h.ranking(args))
 
 	return nil
 }
 
-func runSTOCDWithProgress(client *socketmode.Client, channelID, timestamp string, indicators map[string]float64, minDTE, maxDTE, rfr, minRoR float64) {
-	tradierKey := os.Getenv("TRADIER_KEY")
-	symbol := getFirstKey(indicators)
-	indicator := indicators[symbol]
+func (h *FCSHandler) runSTOCDWithProgress(client *socketmode.Client, channelID, timestamp, symbol, indicator string, minDTE, maxDTE, rfr, minRoR float64, topN int, scanOptions positions.ScanOptions, ranking positions.RankingConstraints) {
+	status, stopStatus := positions.QueueStatus(func(msg string) {
+		client.PostMessage(channelID, slack.MsgOptionText(msg, false), slack.MsgOptionTS(timestamp))
+	}, statusQueueSize)
+
+	tracker := progress.Start(symbol)
+	go tracker.Watch(progressInterval, nil, h.progressReporter(client, channelID, timestamp, symbol))
+
+	// Stream an ndjson export as the spreads are simulated rather than holding them for export
+	var stream *results.NDJSONWriter
+	var streamPath string
+	if h.config.ExportFormat == results.FormatNDJSON {
+		var err error
+		if stream, streamPath, err = results.CreateNDJSON(h.config.ExportPath, symbol); err != nil {
+			client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Error exporting results: %v", err), false), slack.MsgOptionTS(timestamp))
+		} else {
+			scanOptions.Stream = stream
+		}
+	}
 
-	client.PostMessage(channelID, slack.MsgOptionText("Fetching quotes...", false), slack.MsgOptionTS(timestamp))
-	quotes, err := tradier.GET_QUOTES(symbol, time.Now().AddDate(-10, 0, 0).Format("2006-01-02"), time.Now().Format("2006-01-02"), "daily", tradierKey)
-	if err != nil {
-		client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Error fetching quotes: %v", err), false), slack.MsgOptionTS(timestamp))
-		return
+	// Only the spreads the result pages can show are ranked, unless every spread is exported
+	limit := topN * maxResultPages
+	export := h.config.ExportFormat != "" && h.config.ExportFormat != results.FormatNDJSON
+	if export {
+		limit = 0
 	}
 
-	client.PostMessage(channelID, slack.MsgOptionText("Fetching options chain...", false), slack.MsgOptionTS(timestamp))
-	optionsChain, err := tradier.GET_OPTIONS_CHAIN(symbol, tradierKey, int(minDTE), int(maxDTE))
+	var scan stocd.Scan
+	var preliminary chan preliminaryAnswer
+	client.PostMessage(channelID, slack.MsgOptionText("Fetching quotes and options chain...", false), slack.MsgOptionTS(timestamp))
+	result, err := h.config.Analyzer.Analyze(context.Background(), stocd.AnalyzeRequest{
+		Symbol:       symbol,
+		Indicator:    indicator,
+		MinDTE:       int(minDTE),
+		MaxDTE:       int(maxDTE),
+		MinRoR:       minRoR,
+		RiskFreeRate: rfr,
+		Options:      scanOptions,
+		Ranking:      ranking,
+		Top:          topN,
+		Limit:        limit,
+		Tracker:      tracker,
+		Status:       status,
+		Started: func(s stocd.Scan) {
+			scan = s
+			if s.Direction != "" {
+				client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Direction: %s selected %s", indicator, s.Direction), false), slack.MsgOptionTS(timestamp))
+			}
+
+			// Answer interactive users quickly while the full simulation runs
+			if h.config.FastAnswer {
+				preliminary = make(chan preliminaryAnswer, 1)
+				go func() {
+					preliminary <- h.postPreliminary(client, channelID, timestamp, s, minRoR)
+				}()
+			}
+
+			client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Identifying %s...", strategyName(s.SpreadType)), false), slack.MsgOptionTS(timestamp))
+		},
+	})
+	stopStatus()
+	tracker.Finish()
+	if stream != nil {
+		if err := stream.Close(); err != nil {
+			client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Error exporting results: %v", err), false), slack.MsgOptionTS(timestamp))
+		} else {
+			client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Streamed %d spreads to %s", stream.Count(), streamPath), false), slack.MsgOptionTS(timestamp))
+		}
+	}
 	if err != nil {
-		client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Error fetching options chain: %v", err), false), slack.MsgOptionTS(timestamp))
+		client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Error analyzing %s: %v", symbol, err), false), slack.MsgOptionTS(timestamp))
 		return
 	}
 
-	lastPrice := quotes.History.Day[len(quotes.History.Day)-1].Close
+	// Export every spread before the ranking is truncated to the result pages
+	spreads := result.Spreads
+	if export {
+		path, err := results.Export(h.config.ExportFormat, h.config.ExportPath, symbol, spreads)
+		if err != nil {
+			client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Error exporting results: %v", err), false), slack.MsgOptionTS(timestamp))
+		} else {
+			client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Exported %d spreads to %s", len(spreads), path), false), slack.MsgOptionTS(timestamp))
+		}
+		spreads = spreads[:min(topN*maxResultPages, len(spreads))]
+	}
 
-	calibrationChan := make(chan string, 100000)
-	go func() {
-		// Handle calibration messages
-		for msg := range calibrationChan {
-			client.PostMessage(channelID, slack.MsgOptionText(msg, false), slack.MsgOptionTS(timestamp))
+	if h.config.Archive != nil {
+		scanArgs := map[string]interface{}{
+			"symbol":    symbol,
+			"indicator": indicator,
+			"direction": result.SpreadType,
+			"min_dte":   minDTE,
+			"max_dte":   maxDTE,
+			"min_ror":   minRoR,
+			"rfr":       scan.RiskFreeRate,
 		}
-		close(calibrationChan) // Ensure the channel is closed after calibration messages are processed
-	}()
-
-	client.PostMessage(channelID, slack.MsgOptionText("Running analysis...", false), slack.MsgOptionTS(timestamp))
-	progressChan := make(chan int)
-	resultChan := make(chan []models.SpreadWithProbabilities)
-
-	go func() {
-		var spreads []models.SpreadWithProbabilities
-		if indicator > 0 {
-			client.PostMessage(channelID, slack.MsgOptionText("Identifying Bull Put Spreads...", false), slack.MsgOptionTS(timestamp))
-			spreads = positions.IdentifyBullPutSpreads(optionsChain, lastPrice, rfr, *quotes, minRoR, time.Now(), progressChan, &client.Client, channelID, calibrationChan)
+		dir, err := h.config.Archive.Save(symbol, map[string]interface{}{
+			"scan":    scanArgs,
+			"quotes":  scan.Data.Quotes,
+			"chain":   scan.Data.Chain,
+			"results": spreads,
+		})
+		if err != nil {
+			client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Error archiving scan: %v", err), false), slack.MsgOptionTS(timestamp))
 		} else {
-			client.PostMessage(channelID, slack.MsgOptionText("Identifying Bear Call Spreads...", false), slack.MsgOptionTS(timestamp))
-			spreads = positions.IdentifyBearCallSpreads(optionsChain, lastPrice, rfr, *quotes, minRoR, time.Now(), progressChan, &client.Client, channelID, calibrationChan)
+			client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Archived scan to %s", dir), false), slack.MsgOptionTS(timestamp))
 		}
-		resultChan <- spreads
-	}()
-
-	said10 := false
-	said25 := false
-	said33 := false
-	said50 := false
-	said66 := false
-	said75 := false
-	said90 := false
-	said95 := false
-
-	for {
-		select {
-		case progress := <-progressChan:
-			fmt.Printf("Progress: %d\n", progress)
-			if progress >= 10 && !said10 {
-				client.PostMessage(channelID,
-					slack.MsgOptionText(fmt.Sprintf("Analysis %d%% complete...", progress), false),
-					slack.MsgOptionTS(timestamp))
-				said10 = true
-			} else if progress >= 25 && !said25 {
-				client.PostMessage(channelID,
-					slack.MsgOptionText(fmt.Sprintf("Analysis %d%% complete...", progress), false),
-					slack.MsgOptionTS(timestamp))
-				said25 = true
-			} else if progress >= 33 && !said33 {
-				client.PostMessage(channelID,
-					slack.MsgOptionText(fmt.Sprintf("Analysis %d%% complete...", progress), false),
-					slack.MsgOptionTS(timestamp))
-				said33 = true
-			} else if progress >= 50 && !said50 {
-				client.PostMessage(channelID,
-					slack.MsgOptionText(fmt.Sprintf("Analysis %d%% complete...", progress), false),
-					slack.MsgOptionTS(timestamp))
-				said50 = true
-			} else if progress >= 66 && !said66 {
-				client.PostMessage(channelID,
-					slack.MsgOptionText(fmt.Sprintf("Analysis %d%% complete...", progress), false),
-					slack.MsgOptionTS(timestamp))
-				said66 = true
-			} else if progress >= 75 && !said75 {
-				client.PostMessage(channelID,
-					slack.MsgOptionText(fmt.Sprintf("Analysis %d%% complete...", progress), false),
-					slack.MsgOptionTS(timestamp))
-				said75 = true
-			} else if progress >= 90 && !said90 {
-				client.PostMessage(channelID,
-					slack.MsgOptionText(fmt.Sprintf("Analysis %d%% complete...", progress), false),
-					slack.MsgOptionTS(timestamp))
-				said90 = true
-			} else if progress >= 95 && !said95 {
-				client.PostMessage(channelID,
-					slack.MsgOptionText(fmt.Sprintf("Analysis %d%% complete...", progress), false),
-					slack.MsgOptionTS(timestamp))
-				said95 = true
+	}
+
+	// Prepare the result message
+	var resultMsg strings.Builder
+	f := h.config.Report
+	resultMsg.WriteString(fmt.Sprintf("Analysis complete at %s. Found %d spreads meeting criteria.\n\n", f.Time(time.Now()), result.Found))
+
+	pages := &scanResults{channelID: channelID, timestamp: timestamp, pageSize: topN, format: f, spreads: spreads, ranking: ranking}
+	nextOffset := min(topN, len(spreads))
+
+	// With more spreads than fit on a page, summarize one representative per cluster of similar spreads
+	if len(spreads) > topN {
+		pages.clusters = positions.ClusterSpreads(spreads, min(topN, maxClusters), market.Now())
+		nextOffset = 0
+		resultMsg.WriteString(fmt.Sprintf("%d distinct setups (grouped by short delta, width, DTE, PoP and ROR):\n\n", len(pages.clusters)))
+		for _, cluster := range pages.clusters {
+			text := formatSpread(f, cluster.Indices[0]+1, cluster.Representative())
+			if similar := len(cluster.Spreads) - 1; similar > 0 {
+				text = strings.TrimSuffix(text, "\n") + fmt.Sprintf("  Similar Spreads: %d\n\n", similar)
 			}
-		case spreads := <-resultChan:
-			// Calculate composite scores
-			calculateCompositeScores(spreads)
+			resultMsg.WriteString(text)
+		}
+	} else {
+		for i, spread := range spreads {
+			resultMsg.WriteString(formatSpread(f, i+1, spread))
+		}
+	}
+	h.pages.store(pages)
 
-			// Sort spreads by composite score
-			sort.Slice(spreads, func(i, j int) bool {
-				return spreads[i].CompositeScore > spreads[j].CompositeScore
-			})
+	if result.SpreadType == "Both" {
+		resultMsg.WriteString(directionSummary(f, spreads))
+	}
+
+	if groups := positions.GroupSpreadsByWidth(spreads); len(groups) > 1 {
+		resultMsg.WriteString("Results by width:\n")
+		for _, group := range groups {
+			best := group.Spreads[0]
+			resultMsg.WriteString(fmt.Sprintf("  $%s wide: %d spreads, best %s / %s (Score: %s, PoP: %s, ROR: %s)\n",
+				f.Number(group.Width, 2), len(group.Spreads), best.Spread.ShortLeg.Option.Symbol, best.Spread.LongLeg.Option.Symbol,
+				f.Number(best.CompositeScore, 2), f.Percent(best.Probability.AverageProbability, 2), f.Percent(best.Spread.ROR, 2)))
+		}
+	}
 
-			// Prepare the result message
-			var resultMsg strings.Builder
-			resultMsg.WriteString(fmt.Sprintf("Analysis complete. Found %d spreads meeting criteria.\n\n", len(spreads)))
-
-			for i, spread := range spreads[:min(10, len(spreads))] {
-				resultMsg.WriteString(fmt.Sprintf("Spread %d:\n", i+1))
-				resultMsg.WriteString(fmt.Sprintf("  Short Leg: %s, Long Leg: %s\n", spread.Spread.ShortLeg.Option.Symbol, spread.Spread.LongLeg.Option.Symbol))
-				resultMsg.WriteString(fmt.Sprintf("  Spread Credit: %.2f, ROR: %.2f%%\n", spread.Spread.SpreadCredit, spread.Spread.ROR*100))
-				resultMsg.WriteString(fmt.Sprintf("  Spread BSM Price: %.2f\n", spread.Spread.SpreadBSMPrice))
-				resultMsg.WriteString(fmt.Sprintf("  Average Spread Price: %.2f\n", (spread.Spread.ShortLeg.BSMResult.Price+spread.Spread.LongLeg.BSMResult.Price)/2))
-				resultMsg.WriteString(fmt.Sprintf("  Probability of Profit: %.2f%%\n", spread.Probability.AverageProbability*100))
-				resultMsg.WriteString(fmt.Sprintf("  Composite Score: %.2f\n", spread.CompositeScore))
-				resultMsg.WriteString(fmt.Sprintf("  Expected Shortfall: %.2f%%\n", spread.ExpectedShortfall*100))
-				resultMsg.WriteString(fmt.Sprintf("  VaR (95%%): %.2f%%\n", spread.VaR95*100))
-				resultMsg.WriteString(fmt.Sprintf("  Liquidity: %.2f\n", spread.Liquidity))
-				resultMsg.WriteString(fmt.Sprintf("  Volume: %d\n\n", spread.Spread.ShortLeg.Option.Volume+spread.Spread.LongLeg.Option.Volume))
+	// Send the final result
+	client.PostMessage(channelID, slack.MsgOptionText(resultMsg.String(), false), slack.MsgOptionTS(timestamp))
+	if preliminary != nil {
+		(<-preliminary).supersede(client, channelID)
+	}
+	postClusterButtons(client, pages)
+	postNextPageButton(client, pages, nextOffset)
+	postSortButtons(client, pages)
+
+	var attachments []notify.Attachment
+	if h.config.HTMLReport {
+		html, err := report.HTML(report.Report{
+			Symbol:          symbol,
+			GeneratedAt:     time.Now(),
+			UnderlyingPrice: result.UnderlyingPrice,
+			Spreads:         spreads[:min(topN, len(spreads))],
+			Chain:           scan.Data.Chain,
+			Cones:           models.VolatilityCones(*scan.Data.Quotes, models.DefaultConeLookback),
+		}, f)
+		if err != nil {
+			client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Error rendering report: %v", err), false), slack.MsgOptionTS(timestamp))
+		} else {
+			attachment := notify.Attachment{Name: fmt.Sprintf("%s_report.html", symbol), ContentType: "text/html", Data: html}
+			attachments = append(attachments, attachment)
+			_, err := client.UploadFileV2(slack.UploadFileV2Parameters{
+				Reader:          bytes.NewReader(html),
+				FileSize:        len(html),
+				Filename:        attachment.Name,
+				Title:           fmt.Sprintf("%s report", symbol),
+				Channel:         channelID,
+				ThreadTimestamp: timestamp,
+			})
+			if err != nil {
+				log.Printf("Error uploading report: %v", err)
 			}
+		}
+	}
 
-			// Send the final result
-			client.PostMessage(channelID, slack.MsgOptionText(resultMsg.String(), false), slack.MsgOptionTS(timestamp))
+	notify.NotifyAll(h.config.Notifiers, fmt.Sprintf("STOCD results for %s", symbol), resultMsg.String(), attachments...)
+}
+
+// progressReporter logs every progress snapshot and posts the simulation's progress to the thread as it
+// passes each milestone.
+func (h *FCSHandler) progressReporter(client *socketmode.Client, channelID, timestamp, symbol string) func(progress.Snapshot) {
+	milestones := []float64{10, 25, 33, 50, 66, 75, 90, 95}
+	next := 0
+	return func(s progress.Snapshot) {
+		fmt.Printf("%s progress: %s\n", symbol, s)
+		if s.Stage != positions.StageSimulating || s.Finished {
 			return
 		}
+		passed := next
+		for passed < len(milestones) && s.Percent >= milestones[passed] {
+			passed++
+		}
+		if passed == next {
+			return
+		}
+		next = passed
+		msg := fmt.Sprintf("Analysis %.0f%% complete (%d/%d spreads, %.1f/s", s.Percent, s.Done, s.Total, s.Rate)
+		if s.ETA > 0 {
+			msg += fmt.Sprintf(", about %s left", s.ETA.Round(time.Second))
+		}
+		client.PostMessage(channelID, slack.MsgOptionText(msg+")...", false), slack.MsgOptionTS(timestamp))
 	}
 }
 
-func calculateCompositeScores(spreads []models.SpreadWithProbabilities) {
-	var minProb, maxProb, minVaR, maxVaR, minES, maxES, minLiquidity, maxLiquidity float64
-	maxLiquidity = math.Inf(-1) // Initialize to negative infinity
-	minLiquidity = math.Inf(1)  // Initialize to positive infinity
-
-	// Find min and max values
-	for _, spread := range spreads {
-		prob := spread.Probability.AverageProbability
-		var95 := math.Abs(spread.VaR95)
-		es := math.Abs(spread.ExpectedShortfall)
-		liquidity := spread.Liquidity
-
-		minProb = math.Min(minProb, prob)
-		maxProb = math.Max(maxProb, prob)
-		minVaR = math.Min(minVaR, var95)
-		maxVaR = math.Max(maxVaR, var95)
-		minES = math.Min(minES, es)
-		maxES = math.Max(maxES, es)
-		minLiquidity = math.Min(minLiquidity, liquidity)
-		maxLiquidity = math.Max(maxLiquidity, liquidity)
+// directionSummary counts the spreads of each direction in a ranked scan of both, with the best of each.
+func directionSummary(f report.Formatter, spreads []models.SpreadWithProbabilities) string {
+	var msg strings.Builder
+	msg.WriteString("Results by direction:\n")
+	for _, spreadType := range []string{"Bull Put", "Bear Call"} {
+		count := 0
+		var best models.SpreadWithProbabilities
+		for _, spread := range spreads {
+			if spread.Spread.SpreadType != spreadType {
+				continue
+			}
+			if count == 0 {
+				best = spread
+			}
+			count++
+		}
+		if count == 0 {
+			msg.WriteString(fmt.Sprintf("  %s: no spreads\n", spreadType))
+			continue
+		}
+		msg.WriteString(fmt.Sprintf("  %s: %d spreads, best %s / %s (Score: %s, PoP: %s, ROR: %s)\n",
+			spreadType, count, best.Spread.ShortLeg.Option.Symbol, best.Spread.LongLeg.Option.Symbol,
+			f.Number(best.CompositeScore, 2), f.Percent(best.Probability.AverageProbability, 2), f.Percent(best.Spread.ROR, 2)))
 	}
+	return msg.String()
+}
 
-	normalizeValue := func(value, min, max float64) float64 {
-		if min == max {
-			return 0.5 // Return middle value if min and max are the same
+func validateFCSArgs(args commandArgs) error {
+	switch indicator := strings.ToLower(args.String("indicator")); indicator {
+	case "auto", "best", "both":
+	default:
+		if _, ok := positions.StrategyIndicator(indicator); ok {
+			break
+		}
+		if _, err := strconv.ParseFloat(indicator, 64); err != nil {
+			return fmt.Errorf("invalid indicator %q: expected a number, auto, best, both or one of %s", indicator, strings.Join(positions.StrategyIndicatorNames(), ", "))
 		}
-		return (value - min) / (max - min)
 	}
 
-	// Calculate composite scores
-	for i := range spreads {
-		prob := spreads[i].Probability.AverageProbability
-		var95 := math.Abs(spreads[i].VaR95)
-		es := math.Abs(spreads[i].ExpectedShortfall)
-		liquidity := spreads[i].Liquidity
-		vol := float64(spreads[i].Spread.ShortLeg.Option.Volume + spreads[i].Spread.LongLeg.Option.Volume)
-
-		// Normalize values
-		normProb := normalizeValue(prob, minProb, maxProb)
-		normVaR := 1 - normalizeValue(var95, minVaR, maxVaR)                       // Invert so lower is better
-		normES := 1 - normalizeValue(es, minES, maxES)                             // Invert so lower is better
-		normLiquidity := 1 - normalizeValue(liquidity, minLiquidity, maxLiquidity) // Invert so lower is better
-
-		// Calculate weighted score
-		weightedScore := (normLiquidity * weightLiquidity) +
-			(normProb * weightProbability) +
-			(normVaR * weightVaR) +
-			(normES * weightES)
-
-		spreads[i].CompositeScore = weightedScore * (1 + math.Log1p(vol)) // Use log to dampen the effect of volume
+	switch {
+	case args.Int("minDTE") < 0:
+		return fmt.Errorf("minDTE must not be negative")
+	case args.Int("maxDTE") < args.Int("minDTE"):
+		return fmt.Errorf("maxDTE (%d) must be at least minDTE (%d)", args.Int("maxDTE"), args.Int("minDTE"))
+	case args.Float("minRoR") < 0:
+		return fmt.Errorf("minRoR must not be negative")
+	case args.Float("rfr") < 0 || args.Float("rfr") >= 1:
+		return fmt.Errorf("rfr must be an annual rate between 0 and 1, e.g. 0.04 for 4%%")
+	case args.Int("top") < 0:
+		return fmt.Errorf("top must not be negative")
+	}
+	if _, err := strconv.ParseBool(args.String("shortDated")); err != nil {
+		return fmt.Errorf("invalid shortDated %q: expected true or false", args.String("shortDated"))
+	}
+	if _, err := positions.ParseSort(args.String("sort")); err != nil {
+		return err
 	}
+	_, err := positions.ParseExpirationTypes(args.String("expirations"))
+	return err
 }
 
-func getFirstKey(m map[string]float64) string {
-	for k := range m {
-		return k
+// ranking is the bot's ranking constraints with the sort keys of the command's sort argument.
+func (h *FCSHandler) ranking(args commandArgs) positions.RankingConstraints {
+	ranking := h.config.Ranking
+	ranking.Sort, _ = positions.ParseSort(args.String("sort")) // Validated by validateFCSArgs
+	return ranking
+}
+
+func worstScenario(spread models.SpreadWithProbabilities) (models.ScenarioResult, bool) {
+	var worst models.ScenarioResult
+	found := false
+	for _, scenario := range spread.Scenarios {
+		if !found || scenario.WorstLoss > worst.WorstLoss {
+			worst = scenario
+			found = true
+		}
 	}
-	return ""
+	return worst, found
 }
 
 func min(a, b int) int {
@@ -0,0 +1,91 @@
+package stocdslack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/slippage"
+	"github.com/bcdannyboy/stocd/tradier"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+type FillHandler struct {
+	fills *slippage.Store
+}
+
+func NewFillHandler(fills *slippage.Store) *FillHandler {
+	return &FillHandler{fills: fills}
+}
+
+func (h *FillHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Client) error {
+	data := evt.Data.(slack.SlashCommand)
+	args := strings.Fields(data.Text)
+
+	if len(args) != 3 {
+		_, _, err := client.PostMessage(data.ChannelID,
+			slack.MsgOptionText("Invalid number of arguments. Usage: /fill <shortSymbol> <longSymbol> <fillCredit>", false))
+		return err
+	}
+
+	if h.fills == nil {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText("Fill tracking is unavailable: the fill history could not be opened", false))
+		return err
+	}
+
+	fillCredit, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(fmt.Sprintf("Invalid fill credit %q", args[2]), false))
+		return err
+	}
+
+	fill, err := h.modelFill(args[0], args[1], fillCredit)
+	if err != nil {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(fmt.Sprintf("Error recording fill: %v", err), false))
+		return err
+	}
+
+	if err := h.fills.Record(fill); err != nil {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(fmt.Sprintf("Error recording fill: %v", err), false))
+		return err
+	}
+
+	_, _, err = client.PostMessage(data.ChannelID, slack.MsgOptionText(
+		fmt.Sprintf("Recorded fill for %s / %s: filled %.2f vs modeled %.2f (slippage %+.2f, %s liquidity)",
+			fill.ShortSymbol, fill.LongSymbol, fill.FillCredit, fill.ModeledCredit, fill.Slippage(), fill.Bucket), false))
+	return err
+}
+
+// modelFill fetches current quotes for both legs to reconstruct the credit the scanner would have assumed.
+func (h *FillHandler) modelFill(shortSymbol, longSymbol string, fillCredit float64) (slippage.Fill, error) {
+	quotes, err := tradier.GET_MARKET_QUOTES([]string{shortSymbol, longSymbol}, os.Getenv("TRADIER_KEY"))
+	if err != nil {
+		return slippage.Fill{}, err
+	}
+
+	var shortLeg, longLeg *tradier.Option
+	for i := range quotes {
+		switch quotes[i].Symbol {
+		case shortSymbol:
+			shortLeg = &quotes[i]
+		case longSymbol:
+			longLeg = &quotes[i]
+		}
+	}
+	if shortLeg == nil || longLeg == nil {
+		return slippage.Fill{}, fmt.Errorf("quotes not found for %s and %s", shortSymbol, longSymbol)
+	}
+
+	return slippage.Fill{
+		Underlying:    shortLeg.Underlying,
+		ShortSymbol:   shortSymbol,
+		LongSymbol:    longSymbol,
+		Bucket:        slippage.LiquidityBucket(*shortLeg, *longLeg),
+		ModeledCredit: shortLeg.Bid - longLeg.Ask,
+		FillCredit:    fillCredit,
+		FilledAt:      time.Now(),
+	}, nil
+}
@@ -1,6 +1,11 @@
 package stocdslack
 
 import (
+	"log"
+	"os"
+
+	"github.com/bcdannyboy/stocd"
+	"github.com/bcdannyboy/stocd/slippage"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/socketmode"
 )
@@ -8,12 +13,50 @@ import (
 type Handler struct {
 	helpHandler *HelpHandler
 	fcsHandler  *FCSHandler
+	fillHandler *FillHandler
+	termHandler *TermHandler
+
+	monitorHandler   *MonitorHandler
+	scheduleHandler  *ScheduleHandler
+	watchlistHandler *WatchlistHandler
+	scanAllHandler   *ScanAllHandler
+	screenHandler    *ScreenHandler
+	orderHandler     *OrderHandler
+	paperHandler     *PaperHandler
+	hedgeHandler     *HedgeHandler
 }
 
-func NewHandler() *Handler {
+func NewHandler(config Config) *Handler {
+	fillsPath := os.Getenv("FILLS_PATH")
+	if fillsPath == "" {
+		fillsPath = "fills.json"
+	}
+	fills, err := slippage.Open(fillsPath)
+	if err != nil {
+		log.Printf("Error opening fill history, slippage adjustment disabled: %v", err)
+	}
+
+	if config.Analyzer == nil {
+		config.Analyzer = stocd.NewAnalyzer(os.Getenv("TRADIER_KEY"))
+		config.Analyzer.Archive = config.Archive
+	}
+
+	fcsHandler := NewFCSHandler(fills, config)
+
 	return &Handler{
 		helpHandler: NewHelpHandler(),
-		fcsHandler:  NewFCSHandler(),
+		fcsHandler:  fcsHandler,
+		fillHandler: NewFillHandler(fills),
+		termHandler: NewTermHandler(config),
+
+		monitorHandler:   NewMonitorHandler(config.Monitor),
+		scheduleHandler:  NewScheduleHandler(config.Scheduler, fcsHandler),
+		watchlistHandler: NewWatchlistHandler(config.Watchlist),
+		scanAllHandler:   NewScanAllHandler(fcsHandler, config.Watchlist),
+		screenHandler:    NewScreenHandler(config.ScreenerFactors, config.Watchlist, config.Archive),
+		orderHandler:     NewOrderHandler(config.Broker),
+		paperHandler:     NewPaperHandler(config.Paper),
+		hedgeHandler:     NewHedgeHandler(config),
 	}
 }
 
@@ -30,8 +73,76 @@ func (h *Handler) Handle(evt *socketmode.Event, client *socketmode.Client) error
 		if err != nil {
 			return err
 		}
+	case "/fill":
+		err := h.fillHandler.HandleCommand(evt, client)
+		if err != nil {
+			return err
+		}
+	case "/term":
+		err := h.termHandler.HandleCommand(evt, client)
+		if err != nil {
+			return err
+		}
+	case "/monitor":
+		err := h.monitorHandler.HandleCommand(evt, client)
+		if err != nil {
+			return err
+		}
+	case "/schedule":
+		err := h.scheduleHandler.HandleCommand(evt, client)
+		if err != nil {
+			return err
+		}
+	case "/watchlist":
+		err := h.watchlistHandler.HandleCommand(evt, client)
+		if err != nil {
+			return err
+		}
+	case "/scanall":
+		err := h.scanAllHandler.HandleCommand(evt, client)
+		if err != nil {
+			return err
+		}
+	case "/screen":
+		err := h.screenHandler.HandleCommand(evt, client)
+		if err != nil {
+			return err
+		}
+	case "/order":
+		err := h.orderHandler.HandleCommand(evt, client)
+		if err != nil {
+			return err
+		}
+	case "/paper":
+		err := h.paperHandler.HandleCommand(evt, client)
+		if err != nil {
+			return err
+		}
+	case "/hedge":
+		err := h.hedgeHandler.HandleCommand(evt, client)
+		if err != nil {
+			return err
+		}
 	}
 
 	client.Ack(*evt.Request)
 	return nil
 }
+
+func (h *Handler) HandleInteraction(evt *socketmode.Event, client *socketmode.Client) error {
+	client.Ack(*evt.Request)
+
+	callback, ok := evt.Data.(slack.InteractionCallback)
+	if !ok || callback.Type != slack.InteractionTypeBlockActions {
+		return nil
+	}
+
+	err := h.fcsHandler.pages.HandleInteraction(callback, client)
+	if err == nil {
+		err = h.orderHandler.HandleInteraction(callback, client)
+	}
+	if err != nil {
+		log.Printf("Error handling interaction: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package stocdslack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bcdannyboy/stocd/market"
+)
+
+func TestHandlerFetchesCoinsFromDeribit(t *testing.T) {
+	now := market.Now()
+	expiration := strings.ToUpper(now.AddDate(0, 0, 30).Format("2Jan06"))
+	results := map[string]interface{}{
+		"/public/get_tradingview_chart_data": map[string]interface{}{
+			"ticks": []int64{now.AddDate(0, 0, -2).UnixMilli(), now.AddDate(0, 0, -1).UnixMilli()},
+			"open":  []float64{59000, 60000},
+			"high":  []float64{61000, 61000},
+			"low":   []float64{58000, 59000},
+			"close": []float64{60000, 60500},
+		},
+		"/public/get_index_price": map[string]float64{"index_price": 60400},
+		"/public/get_book_summary_by_currency": []map[string]interface{}{
+			{"instrument_name": "BTC-" + expiration + "-55000-P", "bid_price": 0.01, "ask_price": 0.012, "mark_price": 0.011, "mark_iv": 50, "underlying_price": 60400},
+		},
+	}
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		result, ok := results[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request for %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+	}))
+	defer server.Close()
+
+	// The bot's default Analyzer takes Deribit's root from DERIBIT_URL
+	t.Setenv("DERIBIT_URL", server.URL)
+	t.Setenv("FILLS_PATH", filepath.Join(t.TempDir(), "fills.json"))
+	h := NewHandler(Config{})
+	data, err := h.fcsHandler.config.Analyzer.MarketData(context.Background(), "BTC-USD", 0, 60)
+	if err != nil {
+		t.Fatalf("MarketData(BTC-USD) = %v", err)
+	}
+
+	if len(requested) != len(results) {
+		t.Errorf("requested %v from Deribit, want each of its %d endpoints", requested, len(results))
+	}
+	if data.Price != 60400 {
+		t.Errorf("underlying price = %v, want Deribit's index price 60400", data.Price)
+	}
+	if len(data.Quotes.History.Day) != 2 {
+		t.Errorf("history has %d days, want 2", len(data.Quotes.History.Day))
+	}
+	if len(data.Chain) != 1 {
+		t.Errorf("chain has %d expirations, want 1", len(data.Chain))
+	}
+}
@@ -0,0 +1,75 @@
+package stocdslack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bcdannyboy/stocd/hedge"
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/monitor"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// HedgeHandler aggregates the greeks of a book of positions and suggests the ETF trades that flatten them.
+type HedgeHandler struct {
+	config Config
+}
+
+func NewHedgeHandler(config Config) *HedgeHandler {
+	return &HedgeHandler{config: config}
+}
+
+func (h *HedgeHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Client) error {
+	data := evt.Data.(slack.SlashCommand)
+
+	args, err := hedgeSchema.parse(data.Text)
+	var hedgeVega bool
+	if err == nil {
+		hedgeVega, err = strconv.ParseBool(args.String("vega"))
+		if err != nil {
+			err = fmt.Errorf("invalid vega %q: expected true or false", args.String("vega"))
+		}
+	}
+	if err != nil {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(hedgeSchema.usageError(err), false))
+		return err
+	}
+
+	source := strings.ToLower(args.String("source"))
+	if source == "auto" {
+		source = hedge.SourcePaper
+		if h.config.Broker != nil {
+			source = hedge.SourceBroker
+		}
+	}
+	with := strings.ToUpper(args.String("with"))
+
+	_, ts, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(fmt.Sprintf("Computing the %s hedge of the %s positions...", with, source), false))
+	if err != nil {
+		return err
+	}
+	go func() {
+		client.PostMessage(data.ChannelID, slack.MsgOptionText(h.plan(source, with, hedgeVega), false), slack.MsgOptionTS(ts))
+	}()
+	return nil
+}
+
+// plan describes the hedge of the source's positions, or the error preventing it.
+func (h *HedgeHandler) plan(source, with string, hedgeVega bool) string {
+	var monitorStore *monitor.Store
+	if h.config.Monitor != nil {
+		monitorStore = h.config.Monitor.Store()
+	}
+	holdings, err := hedge.Holdings(source, h.config.Broker, h.config.Paper, monitorStore)
+	if err != nil {
+		return fmt.Sprintf("Error loading positions: %v", err)
+	}
+	portfolio, suggestion, err := hedge.Plan(holdings, with, hedgeVega, os.Getenv("TRADIER_KEY"), market.Now())
+	if err != nil {
+		return fmt.Sprintf("Error computing hedge: %v", err)
+	}
+	return "```" + hedge.Describe(portfolio, suggestion) + "```"
+}
@@ -15,7 +15,18 @@ func (h *HelpHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Cl
 	data := evt.Data.(slack.SlashCommand)
 	helpText := "Available commands:\n" +
 		"/help - Show this help message\n" +
-		"/fcs <symbol> <indicator> <minDTE> <maxDTE> <minRoR> <RFR> - Find credit spreads"
+		fcsSchema.help() +
+		"/fill <shortSymbol> <longSymbol> <fillCredit> - Record an actual fill to calibrate future credit assumptions\n" +
+		termSchema.help() +
+		"/monitor add <shortSymbol> <longSymbol> <credit> | list | remove <id> | check - Track open spreads and alert when an exit rule triggers\n" +
+		"/schedule <fcs arguments> <daily|weekdays|mon-sun> <HH:MM> | list | delete <id> - Run a scan on a recurring schedule (US/Eastern time) in this channel\n" +
+		"/watchlist add <symbol>... | remove <symbol>... | list - Manage this channel's watchlist\n" +
+		scanAllSchema.help() +
+		"/screen [symbol...] - Rank symbols, or this channel's watchlist, by the screener factors\n" +
+		"/order <ticket> [quantity] | positions - Preview a result's ticket as a limit order, placing it once you confirm, or list the brokerage positions\n" +
+		"/paper enter <ticket> [quantity] | list | close <id> | report | calibration [bins] - Paper trade a result's ticket and compare its P&L and outcomes with the predicted probabilities\n" +
+		hedgeSchema.help() +
+		"Arguments of /fcs, /scanall, /term and /hedge may be positional or named, e.g. /fcs symbol=AAPL minDTE=30"
 
 	_, _, err := client.PostMessage(data.ChannelID,
 		slack.MsgOptionText(helpText, false))
@@ -0,0 +1,143 @@
+package stocdslack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/monitor"
+	"github.com/bcdannyboy/stocd/tradier"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const monitorUsage = "Usage: /monitor add <shortSymbol> <longSymbol> <credit> | /monitor list | /monitor remove <id> | /monitor check"
+
+type MonitorHandler struct {
+	monitor *monitor.Monitor
+}
+
+func NewMonitorHandler(m *monitor.Monitor) *MonitorHandler {
+	return &MonitorHandler{monitor: m}
+}
+
+func (h *MonitorHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Client) error {
+	data := evt.Data.(slack.SlashCommand)
+	args := strings.Fields(data.Text)
+
+	reply := func(text string) error {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(text, false))
+		return err
+	}
+
+	if h.monitor == nil {
+		return reply("Position monitoring is unavailable: the position store could not be opened")
+	}
+	if len(args) == 0 {
+		return reply(monitorUsage)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 4 {
+			return reply(monitorUsage)
+		}
+		credit, err := strconv.ParseFloat(args[3], 64)
+		if err != nil {
+			return reply(fmt.Sprintf("Invalid credit %q", args[3]))
+		}
+		position, err := newPosition(args[1], args[2], credit, data.ChannelID)
+		if err != nil {
+			return reply(fmt.Sprintf("Error adding position: %v", err))
+		}
+		position, err = h.monitor.Store().Add(position)
+		if err != nil {
+			return reply(fmt.Sprintf("Error adding position: %v", err))
+		}
+		return reply(fmt.Sprintf("Monitoring position %d: %s %s %s / %s expiring %s for %.2f credit",
+			position.ID, position.Underlying, position.SpreadType, position.ShortSymbol, position.LongSymbol, position.Expiration, position.Credit))
+
+	case "list":
+		positions := h.monitor.Store().List()
+		if len(positions) == 0 {
+			return reply("No positions are being monitored")
+		}
+		var msg strings.Builder
+		msg.WriteString("Monitored positions:\n")
+		for _, p := range positions {
+			msg.WriteString(fmt.Sprintf("  %d: %s %s %s / %s expiring %s, %.2f credit\n", p.ID, p.Underlying, p.SpreadType, p.ShortSymbol, p.LongSymbol, p.Expiration, p.Credit))
+		}
+		return reply(msg.String())
+
+	case "remove":
+		if len(args) != 2 {
+			return reply(monitorUsage)
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return reply(fmt.Sprintf("Invalid position ID %q", args[1]))
+		}
+		if err := h.monitor.Store().Remove(id); err != nil {
+			return reply(fmt.Sprintf("Error removing position: %v", err))
+		}
+		return reply(fmt.Sprintf("Stopped monitoring position %d", id))
+
+	case "check":
+		signals, err := h.monitor.Evaluate()
+		if err != nil {
+			return reply(fmt.Sprintf("Error checking positions: %v", err))
+		}
+		if len(signals) == 0 {
+			return reply("No exit signals for the monitored positions")
+		}
+		var msg strings.Builder
+		for _, signal := range signals {
+			msg.WriteString(signal.Message())
+			msg.WriteString("\n")
+		}
+		return reply(msg.String())
+	}
+
+	return reply(monitorUsage)
+}
+
+// newPosition looks up both legs to fill in the position's underlying, strikes, expiration and type.
+func newPosition(shortSymbol, longSymbol string, credit float64, channelID string) (monitor.Position, error) {
+	quotes, err := tradier.GET_MARKET_QUOTES([]string{shortSymbol, longSymbol}, os.Getenv("TRADIER_KEY"))
+	if err != nil {
+		return monitor.Position{}, err
+	}
+
+	var shortLeg, longLeg *tradier.Option
+	for i := range quotes {
+		switch quotes[i].Symbol {
+		case shortSymbol:
+			shortLeg = &quotes[i]
+		case longSymbol:
+			longLeg = &quotes[i]
+		}
+	}
+	if shortLeg == nil || longLeg == nil {
+		return monitor.Position{}, fmt.Errorf("quotes not found for %s and %s", shortSymbol, longSymbol)
+	}
+
+	spreadType := "Bull Put"
+	if shortLeg.OptionType == "call" {
+		spreadType = "Bear Call"
+	}
+
+	return monitor.Position{
+		Underlying:  shortLeg.Underlying,
+		SpreadType:  spreadType,
+		ShortSymbol: shortSymbol,
+		LongSymbol:  longSymbol,
+		ShortStrike: shortLeg.Strike,
+		LongStrike:  longLeg.Strike,
+		Expiration:  shortLeg.ExpirationDate,
+		Credit:      credit,
+		ChannelID:   channelID,
+		OpenedAt:    time.Now(),
+	}, nil
+}
@@ -0,0 +1,142 @@
+package stocdslack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bcdannyboy/stocd/execution"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const (
+	orderPlaceActionID  = "order_place"
+	orderCancelActionID = "order_cancel"
+
+	orderUsage = "Usage: /order <ticket> [quantity] | /order positions"
+)
+
+// OrderHandler previews the order of a scan result's ticket and places it once the requesting user
+// confirms with a button.
+type OrderHandler struct {
+	broker execution.Broker
+
+	mu      sync.Mutex
+	pending map[string]pendingOrder
+	nextID  int
+}
+
+// pendingOrder is a previewed order awaiting confirmation by the user who requested it.
+type pendingOrder struct {
+	order  execution.Order
+	userID string
+}
+
+func NewOrderHandler(broker execution.Broker) *OrderHandler {
+	return &OrderHandler{broker: broker, pending: make(map[string]pendingOrder)}
+}
+
+func (h *OrderHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Client) error {
+	data := evt.Data.(slack.SlashCommand)
+
+	reply := func(text string) error {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(text, false))
+		return err
+	}
+
+	if h.broker == nil {
+		return reply("Order execution is unavailable: set TRADIER_ACCOUNT_ID to enable it")
+	}
+	if strings.TrimSpace(data.Text) == "positions" {
+		positions, err := h.broker.Positions()
+		if err != nil {
+			return reply(fmt.Sprintf("Error fetching positions: %v", err))
+		}
+		if len(positions) == 0 {
+			return reply("The account has no open positions")
+		}
+		var msg strings.Builder
+		msg.WriteString("Open positions:\n")
+		for _, p := range positions {
+			msg.WriteString(fmt.Sprintf("  %s: %g, cost basis %.2f, acquired %s\n", p.Symbol, p.Quantity, p.CostBasis, p.DateAcquired))
+		}
+		return reply(msg.String())
+	}
+
+	ticketText, quantity, err := ticketArgs(data.Text)
+	if err != nil {
+		return reply(fmt.Sprintf("%s\n%s", err, orderUsage))
+	}
+	ticket, err := execution.ParseTicket(ticketText)
+	if err != nil {
+		return reply(fmt.Sprintf("Error reading ticket: %v", err))
+	}
+	order, err := execution.NewOrder(ticket, quantity)
+	if err != nil {
+		return reply(fmt.Sprintf("Invalid order: %v", err))
+	}
+
+	preview, err := h.broker.PreviewOrder(order)
+	if err != nil {
+		return reply(fmt.Sprintf("Order preview failed: %v", err))
+	}
+
+	h.mu.Lock()
+	h.nextID++
+	id := strconv.Itoa(h.nextID)
+	h.pending[id] = pendingOrder{order: order, userID: data.UserID}
+	h.mu.Unlock()
+
+	text := fmt.Sprintf("Order preview: %s\nStatus %s, cost %.2f, commission %.2f, fees %.2f, margin change %.2f",
+		order.Describe(), preview.Status, preview.Cost, preview.Commission, preview.Fees, preview.MarginChange)
+	place := slack.NewButtonBlockElement(orderPlaceActionID, id, slack.NewTextBlockObject(slack.PlainTextType, "Place order", false, false))
+	place.Style = slack.StylePrimary
+	cancel := slack.NewButtonBlockElement(orderCancelActionID, id, slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false))
+	_, _, err = client.PostMessage(data.ChannelID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.PlainTextType, text, false, false), nil, nil),
+			slack.NewActionBlock("", place, cancel),
+		))
+	return err
+}
+
+// HandleInteraction places or discards a previewed order. Only the user who requested the preview can
+// confirm it, and each preview is placed at most once.
+func (h *OrderHandler) HandleInteraction(callback slack.InteractionCallback, client *socketmode.Client) error {
+	for _, action := range callback.ActionCallback.BlockActions {
+		if action.ActionID != orderPlaceActionID && action.ActionID != orderCancelActionID {
+			continue
+		}
+
+		reply := func(text string) error {
+			_, _, err := client.PostMessage(callback.Channel.ID, slack.MsgOptionText(text, false))
+			return err
+		}
+
+		h.mu.Lock()
+		pending, ok := h.pending[action.Value]
+		if ok && pending.userID == callback.User.ID {
+			delete(h.pending, action.Value)
+		}
+		h.mu.Unlock()
+
+		switch {
+		case !ok:
+			return reply("This order is no longer pending, please preview it again.")
+		case pending.userID != callback.User.ID:
+			return reply("Only the user who previewed this order can place or cancel it.")
+		case action.ActionID == orderCancelActionID:
+			return reply(fmt.Sprintf("Cancelled order: %s", pending.order.Describe()))
+		}
+
+		result, err := h.broker.PlaceOrder(pending.order)
+		if err != nil {
+			return reply(fmt.Sprintf("Order failed: %v", err))
+		}
+		return reply(fmt.Sprintf("Placed order %d (%s): %s", result.ID, result.Status, pending.order.Describe()))
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+package stocdslack
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/positions"
+	"github.com/bcdannyboy/stocd/report"
+	"github.com/bcdannyboy/stocd/results"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const (
+	defaultTopN      = 10
+	maxResultPages   = 10 // Pages of results /fcs ranks and keeps for "show next" requests
+	nextPageActionID = "fcs_next_page"
+	clusterActionID  = "fcs_cluster"
+	sortActionID     = "fcs_sort"
+	maxStoredScans   = 20 // Completed scans kept in memory for "show next" requests
+	maxClusters      = 20 // Keeps one button per cluster within Slack's 25 element action block limit
+)
+
+// scanResults holds the ranked spreads of a completed scan so further pages can be shown.
+type scanResults struct {
+	channelID string
+	timestamp string
+	pageSize  int
+	format    report.Formatter
+	spreads   []models.SpreadWithProbabilities
+	clusters  []positions.SpreadCluster    // Set when the summary shows one representative per cluster
+	ranking   positions.RankingConstraints // Sort keys and constraints the spreads were ranked with
+}
+
+// resortKeys are the sort keys offered as buttons to re-rank a stored scan without running it again.
+var resortKeys = []string{positions.SortScore, positions.SortEV, positions.SortPoP, positions.SortROR, positions.SortThetaPerDay, positions.SortDollarVaR}
+
+// resultPages keeps the most recent scans, keyed by the timestamp of the scan's thread.
+type resultPages struct {
+	mu    sync.Mutex
+	scans map[string]*scanResults
+	order []string
+}
+
+func newResultPages() *resultPages {
+	return &resultPages{scans: make(map[string]*scanResults)}
+}
+
+func (p *resultPages) store(scan *scanResults) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.scans[scan.timestamp]; !ok {
+		p.order = append(p.order, scan.timestamp)
+	}
+	p.scans[scan.timestamp] = scan
+
+	for len(p.order) > maxStoredScans {
+		delete(p.scans, p.order[0])
+		p.order = p.order[1:]
+	}
+}
+
+func (p *resultPages) get(timestamp string) (*scanResults, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	scan, ok := p.scans[timestamp]
+	return scan, ok
+}
+
+// postPage posts the spreads starting at offset and, if more remain, a button to show the next page.
+func (p *resultPages) postPage(client *socketmode.Client, scan *scanResults, offset int) {
+	end := min(offset+scan.pageSize, len(scan.spreads))
+	if offset >= end {
+		return
+	}
+
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("Spreads %d-%d of %d:\n\n", offset+1, end, len(scan.spreads)))
+	for i := offset; i < end; i++ {
+		msg.WriteString(formatSpread(scan.format, i+1, scan.spreads[i]))
+	}
+	client.PostMessage(scan.channelID, slack.MsgOptionText(msg.String(), false), slack.MsgOptionTS(scan.timestamp))
+
+	postNextPageButton(client, scan, end)
+}
+
+func postNextPageButton(client *socketmode.Client, scan *scanResults, offset int) {
+	remaining := len(scan.spreads) - offset
+	if remaining <= 0 {
+		return
+	}
+
+	label := fmt.Sprintf("Show next %d", min(scan.pageSize, remaining))
+	if offset == 0 {
+		label = fmt.Sprintf("Show top %d by %s", min(scan.pageSize, remaining), positions.DescribeSort(scan.ranking.Sort))
+	}
+	button := slack.NewButtonBlockElement(nextPageActionID, scan.timestamp+":"+strconv.Itoa(offset), slack.NewTextBlockObject(slack.PlainTextType, label, false, false))
+	text := fmt.Sprintf("%d more spreads available.", remaining)
+
+	client.PostMessage(scan.channelID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.PlainTextType, text, false, false), nil, nil),
+			slack.NewActionBlock("", button),
+		),
+		slack.MsgOptionTS(scan.timestamp))
+}
+
+// postClusterButtons posts a "see N similar" button for every cluster with more than its representative.
+func postClusterButtons(client *socketmode.Client, scan *scanResults) {
+	var buttons []slack.BlockElement
+	for i, cluster := range scan.clusters {
+		if len(cluster.Spreads) < 2 {
+			continue
+		}
+		label := fmt.Sprintf("See %d similar to #%d", len(cluster.Spreads)-1, cluster.Indices[0]+1)
+		value := fmt.Sprintf("%s:%d:0", scan.timestamp, i)
+		buttons = append(buttons, slack.NewButtonBlockElement(clusterActionID, value, slack.NewTextBlockObject(slack.PlainTextType, label, false, false)))
+	}
+	if len(buttons) == 0 {
+		return
+	}
+
+	text := "Expand a setup to see the similar spreads it represents."
+	client.PostMessage(scan.channelID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.PlainTextType, text, false, false), nil, nil),
+			slack.NewActionBlock("", buttons...),
+		),
+		slack.MsgOptionTS(scan.timestamp))
+}
+
+// postSortButtons posts a button for each of resortKeys, re-ranking the scan's spreads by the key.
+func postSortButtons(client *socketmode.Client, scan *scanResults) {
+	if len(scan.spreads) < 2 {
+		return
+	}
+	buttons := make([]slack.BlockElement, 0, len(resortKeys))
+	for _, key := range resortKeys {
+		label := "Sort by " + positions.DescribeSort([]string{key})
+		buttons = append(buttons, slack.NewButtonBlockElement(sortActionID, scan.timestamp+":"+key, slack.NewTextBlockObject(slack.PlainTextType, label, false, false)))
+	}
+	text := "Re-rank these results without running the scan again."
+	client.PostMessage(scan.channelID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.PlainTextType, text, false, false), nil, nil),
+			slack.NewActionBlock("", buttons...),
+		),
+		slack.MsgOptionTS(scan.timestamp))
+}
+
+// resort stores and returns a copy of the scan with its spreads ranked by key, ties broken by the scan's
+// sort keys, and the ranking constraints applied again. The copy summarizes no clusters.
+func (p *resultPages) resort(scan *scanResults, key string) *scanResults {
+	resorted := *scan
+	resorted.clusters = nil
+	resorted.ranking.Sort = []string{key}
+	for _, previous := range scan.ranking.Sort {
+		if previous != key {
+			resorted.ranking.Sort = append(resorted.ranking.Sort, previous)
+		}
+	}
+	resorted.spreads = append([]models.SpreadWithProbabilities(nil), scan.spreads...)
+	resorted.spreads = positions.RankSpreads(resorted.spreads, scan.pageSize, 0, resorted.ranking)
+	p.store(&resorted)
+	return &resorted
+}
+
+// postClusterPage posts a page of the spreads a cluster's representative stands for.
+func (p *resultPages) postClusterPage(client *socketmode.Client, scan *scanResults, index, offset int) {
+	if index < 0 || index >= len(scan.clusters) {
+		return
+	}
+	cluster := scan.clusters[index]
+	similar := len(cluster.Spreads) - 1
+	end := min(offset+scan.pageSize, similar)
+	if offset < 0 || offset >= end {
+		return
+	}
+
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("Spreads similar to #%d (%d-%d of %d):\n\n", cluster.Indices[0]+1, offset+1, end, similar))
+	for i := offset + 1; i <= end; i++ {
+		msg.WriteString(formatSpread(scan.format, cluster.Indices[i]+1, cluster.Spreads[i]))
+	}
+	client.PostMessage(scan.channelID, slack.MsgOptionText(msg.String(), false), slack.MsgOptionTS(scan.timestamp))
+
+	if remaining := similar - end; remaining > 0 {
+		label := fmt.Sprintf("Show %d more similar to #%d", min(scan.pageSize, remaining), cluster.Indices[0]+1)
+		button := slack.NewButtonBlockElement(clusterActionID, fmt.Sprintf("%s:%d:%d", scan.timestamp, index, end), slack.NewTextBlockObject(slack.PlainTextType, label, false, false))
+		client.PostMessage(scan.channelID,
+			slack.MsgOptionText(label, false),
+			slack.MsgOptionBlocks(slack.NewActionBlock("", button)),
+			slack.MsgOptionTS(scan.timestamp))
+	}
+}
+
+// HandleInteraction serves "show next", "see similar" and "sort by" button presses for a stored scan.
+func (p *resultPages) HandleInteraction(callback slack.InteractionCallback, client *socketmode.Client) error {
+	for _, action := range callback.ActionCallback.BlockActions {
+		if action.ActionID != nextPageActionID && action.ActionID != clusterActionID && action.ActionID != sortActionID {
+			continue
+		}
+
+		parts := strings.Split(action.Value, ":")
+		if (action.ActionID != clusterActionID && len(parts) != 2) || (action.ActionID == clusterActionID && len(parts) != 3) {
+			return fmt.Errorf("invalid page reference %q", action.Value)
+		}
+		timestamp := parts[0]
+
+		scan, ok := p.get(timestamp)
+		if !ok {
+			_, _, err := client.PostMessage(callback.Channel.ID,
+				slack.MsgOptionText("These results are no longer available, please run the scan again.", false),
+				slack.MsgOptionTS(timestamp))
+			return err
+		}
+
+		if action.ActionID == sortActionID {
+			keys, err := positions.ParseSort(parts[1])
+			if err != nil {
+				return fmt.Errorf("invalid sort key %q", parts[1])
+			}
+			scan = p.resort(scan, keys[0])
+			client.PostMessage(scan.channelID, slack.MsgOptionText(fmt.Sprintf("Re-ranked by %s.", positions.DescribeSort(scan.ranking.Sort[:1])), false), slack.MsgOptionTS(scan.timestamp))
+			p.postPage(client, scan, 0)
+			continue
+		}
+
+		offset, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			return fmt.Errorf("invalid page offset %q", parts[len(parts)-1])
+		}
+
+		if action.ActionID == clusterActionID {
+			index, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return fmt.Errorf("invalid cluster %q", parts[1])
+			}
+			p.postClusterPage(client, scan, index, offset)
+			continue
+		}
+
+		p.postPage(client, scan, offset)
+	}
+	return nil
+}
+
+// dollars formats an amount in dollars, e.g. $185.00 or -$20.00.
+func dollars(f report.Formatter, v float64) string {
+	if v < 0 {
+		return "-$" + f.Number(-v, 2)
+	}
+	return "$" + f.Number(v, 2)
+}
+
+// formatSpread renders a ranked spread for the result messages.
+func formatSpread(f report.Formatter, rank int, spread models.SpreadWithProbabilities) string {
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("Spread %d (%s):\n", rank, spread.Spread.SpreadType))
+	if models.HasNonstandardLegs(spread.Spread) {
+		msg.WriteString(fmt.Sprintf("  Nonstandard contract: %s shares per contract, root %s; check the deliverable before trading\n", f.Number(models.SpreadMultiplier(spread.Spread), 0), spread.Spread.ShortLeg.Option.RootSymbol))
+	}
+	if spread.Quotes.Stale {
+		msg.WriteString(fmt.Sprintf("  Stale quotes: last quoted %s (%s ago); check the market before trading\n", spread.Quotes.QuotedAt.Format("Jan 2 15:04:05"), spread.Quotes.Age.Round(time.Second)))
+	}
+	if note := models.SettlementNote(spread.Spread); note != "" {
+		msg.WriteString(fmt.Sprintf("  Index options: %s\n", note))
+	}
+	if spread.Spread.Margin > 0 {
+		msg.WriteString(fmt.Sprintf("  Legs: %s, Margin: %s\n", describeLegs(spread.Spread), f.Number(spread.Spread.Margin*100, 2)))
+		msg.WriteString(fmt.Sprintf("  Credit: %s, ROR on Margin: %s\n", f.Number(spread.Spread.SpreadCredit, 2), f.Percent(spread.Spread.ROR, 2)))
+		if spread.Breakeven.UpperPrice > 0 {
+			msg.WriteString(fmt.Sprintf("  Expected Move: ±%s, breakevens %s apart (±%s)\n", f.Number(spread.Spread.ExpectedMove, 2), f.Number(spread.Breakeven.UpperPrice-spread.Breakeven.Price, 2), f.Number((spread.Breakeven.UpperPrice-spread.Breakeven.Price)/2, 2)))
+		} else {
+			msg.WriteString(fmt.Sprintf("  Expected Move: ±%s\n", f.Number(spread.Spread.ExpectedMove, 2)))
+		}
+		if below, above := models.UndefinedRisk(spread.Spread); below && above {
+			msg.WriteString("  Undefined risk on both sides\n")
+		} else if below {
+			msg.WriteString(fmt.Sprintf("  Undefined risk below %s\n", f.Number(spread.Breakeven.Price, 2)))
+		} else if above {
+			msg.WriteString(fmt.Sprintf("  Undefined risk above %s\n", f.Number(spread.Breakeven.Price, 2)))
+		}
+		msg.WriteString(fmt.Sprintf("  Tail Risk: VaR (99%%) %s, Expected Shortfall (99%%) %s per contract\n", dollars(f, spread.Dollars.VaR99), dollars(f, spread.Dollars.ExpectedShortfall99)))
+	} else if models.IsSingleLeg(spread.Spread) {
+		msg.WriteString(fmt.Sprintf("  Short Leg: %s, Collateral: %s\n", spread.Spread.ShortLeg.Option.Symbol, f.Number(positions.SpreadWidth(spread.Spread)*100, 2)))
+		msg.WriteString(fmt.Sprintf("  Credit: %s, ROR: %s, Credit/Collateral: %s\n", f.Number(spread.Spread.SpreadCredit, 2), f.Percent(spread.Spread.ROR, 2), f.Percent(spread.Spread.CreditWidthRatio, 2)))
+	} else {
+		msg.WriteString(fmt.Sprintf("  Short Leg: %s, Long Leg: %s\n", spread.Spread.ShortLeg.Option.Symbol, spread.Spread.LongLeg.Option.Symbol))
+		msg.WriteString(fmt.Sprintf("  Spread Credit: %s, ROR: %s, Credit/Width: %s\n", f.Number(spread.Spread.SpreadCredit, 2), f.Percent(spread.Spread.ROR, 2), f.Percent(spread.Spread.CreditWidthRatio, 2)))
+	}
+	if spread.Spread.ExpectedMove > 0 {
+		if spread.Spread.Margin <= 0 {
+			msg.WriteString(fmt.Sprintf("  Expected Move: ±%s\n", f.Number(spread.Spread.ExpectedMove, 2)))
+		}
+		msg.WriteString(fmt.Sprintf("  Short Strike: %s expected moves from spot\n", f.Number(spread.Spread.ShortStrikeMoves, 2)))
+	}
+	if spread.Spread.BuyingPower > 0 {
+		msg.WriteString(fmt.Sprintf("  Buying Power: %s Reg-T (Return on Margin %s), Portfolio Margin: %s\n", f.Number(spread.Spread.BuyingPower*100, 2), f.Percent(spread.Spread.ReturnOnMargin, 2), f.Number(spread.Spread.PortfolioMargin*100, 2)))
+	}
+	if spread.Spread.CreditAdjustment != 0 {
+		msg.WriteString(fmt.Sprintf("  Credit includes %s historical fill adjustment\n", f.Signed(spread.Spread.CreditAdjustment, 2)))
+	}
+	if spread.Spread.FillAdjustment != 0 {
+		msg.WriteString(fmt.Sprintf("  Credit assumes a fill %s better than the natural price\n", f.Number(spread.Spread.FillAdjustment, 2)))
+	}
+	if spread.Spread.Fees != 0 {
+		msg.WriteString(fmt.Sprintf("  Credit is net of %s fees\n", f.Number(spread.Spread.Fees, 2)))
+	}
+	msg.WriteString(fmt.Sprintf("  Theta/Day: %s, Theta/Vega: %s, Theta/Credit: %s per day\n", f.Number(spread.Spread.Decay.ThetaPerDay, 4), f.Number(spread.Spread.Decay.ThetaVegaRatio, 2), f.Percent(spread.Spread.Decay.ThetaCreditRatio, 2)))
+	msg.WriteString(fmt.Sprintf("  Spread BSM Price: %s\n", f.Number(spread.Spread.SpreadBSMPrice, 2)))
+	msg.WriteString(fmt.Sprintf("  Average Spread Price: %s\n", f.Number((spread.Spread.ShortLeg.BSMResult.Price+spread.Spread.LongLeg.BSMResult.Price)/2, 2)))
+	if spread.Probability.StandardError > 0 {
+		ci := spread.Probability.AverageInterval
+		msg.WriteString(fmt.Sprintf("  Probability of Profit: %s (95%% CI %s to %s)\n", f.Percent(spread.Probability.AverageProbability, 2), f.Percent(ci.Lower, 2), f.Percent(ci.Upper, 2)))
+	} else {
+		msg.WriteString(fmt.Sprintf("  Probability of Profit: %s\n", f.Percent(spread.Probability.AverageProbability, 2)))
+	}
+	if spread.Probability.MarketImplied > 0 {
+		msg.WriteString(fmt.Sprintf("  Market-Implied Probability of Profit: %s\n", f.Percent(spread.Probability.MarketImplied, 2)))
+	}
+	if spread.Probability.Bootstrap > 0 {
+		msg.WriteString(fmt.Sprintf("  Historical Bootstrap Probability of Profit: %s\n", f.Percent(spread.Probability.Bootstrap, 2)))
+	}
+	if spread.Breakeven.UpperPrice > 0 {
+		msg.WriteString(fmt.Sprintf("  Breakevens: %s and %s (nearer %s / %s SD from spot)\n", f.Number(spread.Breakeven.Price, 2), f.Number(spread.Breakeven.UpperPrice, 2), f.Percent(spread.Breakeven.DistancePct, 2), f.Number(spread.Breakeven.DistanceSD, 2)))
+	} else {
+		msg.WriteString(fmt.Sprintf("  Breakeven: %s (%s / %s SD from spot)\n", f.Number(spread.Breakeven.Price, 2), f.Percent(spread.Breakeven.DistancePct, 2), f.Number(spread.Breakeven.DistanceSD, 2)))
+	}
+	msg.WriteString(fmt.Sprintf("  Expected Value: %s, Expected Profit: %s\n", f.Number(spread.ExpectedValue, 2), f.Number(spread.ExpectedProfit, 2)))
+	msg.WriteString(fmt.Sprintf("  Composite Score: %s\n", f.Number(spread.CompositeScore, 2)))
+	if spread.Dollars.UnboundedLoss {
+		msg.WriteString(fmt.Sprintf("  Max Profit: %s, Max Loss: unbounded per contract\n", dollars(f, spread.Dollars.MaxProfit)))
+	} else {
+		msg.WriteString(fmt.Sprintf("  Max Profit: %s, Max Loss: %s per contract\n", dollars(f, spread.Dollars.MaxProfit), dollars(f, spread.Dollars.MaxLoss)))
+	}
+	if len(spread.Dollars.TailRisks) == 0 {
+		msg.WriteString(fmt.Sprintf("  VaR (95%%): %s, Expected Shortfall: %s per contract\n", dollars(f, spread.Dollars.VaR95), dollars(f, spread.Dollars.ExpectedShortfall)))
+	}
+	for _, risk := range spread.Dollars.TailRisks {
+		levelDecimals := 0
+		if math.Abs(risk.Level*100-math.Round(risk.Level*100)) > 1e-9 {
+			levelDecimals = 1 // e.g. 99.5%
+		}
+		msg.WriteString(fmt.Sprintf("  VaR (%s): %s, Expected Shortfall: %s per contract\n", f.Percent(risk.Level, levelDecimals), dollars(f, risk.VaR), dollars(f, risk.ExpectedShortfall)))
+	}
+	if worst, ok := worstScenario(spread); ok {
+		msg.WriteString(fmt.Sprintf("  Worst Historical Scenario: %s, Marked Loss: %s (day %d)\n", worst.Name, f.Number(worst.WorstLoss, 2), worst.WorstDay))
+	}
+	msg.WriteString(fmt.Sprintf("  Liquidity: %s\n", f.Number(spread.Liquidity, 2)))
+	msg.WriteString(fmt.Sprintf("  Variance Risk Premium: %s\n", f.Number(spread.VarianceRiskPremium, 4)))
+	msg.WriteString(fmt.Sprintf("  Volume: %s\n", f.Number(float64(spread.Spread.ShortLeg.Option.Volume+spread.Spread.LongLeg.Option.Volume), 0)))
+	if activity := spread.Activity; activity.Days > 1 {
+		msg.WriteString(fmt.Sprintf("  Activity: all legs traded %d of %d days, median volume %s, open interest change %+d\n", activity.ActiveDays, activity.Days, f.Number(activity.MedianVolume, 0), activity.OpenInterestChange))
+	}
+	if ticket, err := results.TicketJSON(spread); err == nil {
+		msg.WriteString(fmt.Sprintf("  Ticket: `%s`\n", ticket))
+	}
+	msg.WriteString("\n")
+	return msg.String()
+}
+
+// describeLegs lists the legs of a position with undefined risk, e.g. "short 2x AAPL...P00180000, long AAPL...P00190000".
+func describeLegs(spread models.OptionSpread) string {
+	if len(spread.Legs) > 0 {
+		legs := make([]string, len(spread.Legs))
+		for i, leg := range spread.Legs {
+			side, quantity := "short ", leg.Quantity
+			if quantity < 0 {
+				side, quantity = "long ", -quantity
+			}
+			if quantity > 1 {
+				side += fmt.Sprintf("%dx ", quantity)
+			}
+			legs[i] = side + leg.Option.Symbol
+		}
+		return strings.Join(legs, ", ")
+	}
+	short := spread.ShortLeg.Option.Symbol
+	if q := models.ShortContracts(spread); q > 1 {
+		short = fmt.Sprintf("%.0fx %s", q, short)
+	}
+	legs := []string{"short " + short}
+	if spread.CallLeg.Option.Symbol != "" {
+		legs = append(legs, "short "+spread.CallLeg.Option.Symbol)
+	}
+	if spread.LongLeg.Option.Symbol != "" {
+		legs = append(legs, "long "+spread.LongLeg.Option.Symbol)
+	}
+	return strings.Join(legs, ", ")
+}
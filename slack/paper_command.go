@@ -0,0 +1,114 @@
+package stocdslack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bcdannyboy/stocd/calibration"
+	"github.com/bcdannyboy/stocd/execution"
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/paper"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const paperUsage = "Usage: /paper enter <ticket> [quantity] | /paper list | /paper close <id> | /paper report | /paper calibration [bins]"
+
+// PaperHandler records hypothetical fills of scan results and reports their P&L against the predictions.
+type PaperHandler struct {
+	store *paper.Store
+}
+
+func NewPaperHandler(store *paper.Store) *PaperHandler {
+	return &PaperHandler{store: store}
+}
+
+func (h *PaperHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Client) error {
+	data := evt.Data.(slack.SlashCommand)
+	args := strings.Fields(data.Text)
+
+	reply := func(text string) error {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(text, false))
+		return err
+	}
+
+	if h.store == nil {
+		return reply("Paper trading is unavailable: the paper trade store could not be opened")
+	}
+	if len(args) == 0 {
+		return reply(paperUsage)
+	}
+
+	switch args[0] {
+	case "enter":
+		ticketText, quantity, err := ticketArgs(strings.TrimPrefix(strings.TrimSpace(data.Text), "enter"))
+		if err != nil {
+			return reply(fmt.Sprintf("%s\n%s", err, paperUsage))
+		}
+		ticket, err := execution.ParseTicket(ticketText)
+		if err != nil {
+			return reply(fmt.Sprintf("Error reading ticket: %v", err))
+		}
+		trade, err := paper.NewTrade(ticket, quantity, data.ChannelID, market.Now())
+		if err != nil {
+			return reply(fmt.Sprintf("Invalid paper trade: %v", err))
+		}
+		trade, err = h.store.Enter(trade)
+		if err != nil {
+			return reply(fmt.Sprintf("Error entering paper trade: %v", err))
+		}
+		return reply(fmt.Sprintf("Entered paper trade %d: %dx %s %s expiring %s for %.2f credit (PoP %.1f%%, EV %.2f)",
+			trade.ID, trade.Quantity, trade.Underlying, trade.Strategy, trade.Expiration, trade.Credit, trade.PoP*100, trade.EV))
+
+	case "list", "report":
+		marks, err := paper.Refresh(h.store, os.Getenv("TRADIER_KEY"), market.Now())
+		if err != nil {
+			return reply(fmt.Sprintf("Error marking paper trades: %v", err))
+		}
+		trades := h.store.List()
+		if args[0] == "list" {
+			return reply(paper.Report(trades, marks))
+		}
+		s := paper.Summarize(trades, marks)
+		if s.Closed == 0 {
+			return reply(fmt.Sprintf("No closed paper trades yet; %d open with P&L %+.2f", s.Open, s.OpenPnL))
+		}
+		return reply(fmt.Sprintf("Paper trading: %d closed, realized P&L %+.2f, win rate %.1f%% vs %.1f%% predicted PoP (Brier %.3f), mean %+.2f per share vs %+.2f predicted EV; %d open with P&L %+.2f",
+			s.Closed, s.RealizedPnL, s.WinRate*100, s.PredictedPoP*100, s.Brier, s.MeanPnL, s.PredictedEV, s.Open, s.OpenPnL))
+
+	case "calibration":
+		if len(args) > 2 {
+			return reply(paperUsage)
+		}
+		bins := calibration.DefaultBins
+		if len(args) == 2 {
+			var err error
+			bins, err = strconv.Atoi(args[1])
+			if err != nil || bins < 1 {
+				return reply(fmt.Sprintf("Invalid number of bins %q", args[1]))
+			}
+		}
+		if _, err := paper.Refresh(h.store, os.Getenv("TRADIER_KEY"), market.Now()); err != nil {
+			return reply(fmt.Sprintf("Error marking paper trades: %v", err))
+		}
+		return reply("```" + calibration.Compute(calibration.FromPaper(h.store.List()), bins).Describe() + "```")
+
+	case "close":
+		if len(args) != 2 {
+			return reply(paperUsage)
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return reply(fmt.Sprintf("Invalid paper trade ID %q", args[1]))
+		}
+		trade, err := paper.CloseAtMarket(h.store, id, os.Getenv("TRADIER_KEY"), market.Now())
+		if err != nil {
+			return reply(fmt.Sprintf("Error closing paper trade: %v", err))
+		}
+		return reply(fmt.Sprintf("Closed paper trade %d for %.2f debit, realized P&L %+.2f", trade.ID, trade.ExitDebit, trade.RealizedPnL()))
+	}
+
+	return reply(paperUsage)
+}
@@ -0,0 +1,144 @@
+package stocdslack
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd"
+	"github.com/bcdannyboy/stocd/notify"
+	"github.com/bcdannyboy/stocd/positions"
+	"github.com/bcdannyboy/stocd/progress"
+	"github.com/bcdannyboy/stocd/watchlist"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// ScanAllHandler runs the /fcs scan over every symbol on a channel's watchlist.
+type ScanAllHandler struct {
+	fcs       *FCSHandler
+	watchlist *watchlist.Store
+}
+
+func NewScanAllHandler(fcs *FCSHandler, store *watchlist.Store) *ScanAllHandler {
+	return &ScanAllHandler{fcs: fcs, watchlist: store}
+}
+
+func (h *ScanAllHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Client) error {
+	data := evt.Data.(slack.SlashCommand)
+
+	reply := func(text string) (string, error) {
+		_, ts, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(text, false))
+		return ts, err
+	}
+
+	if h.watchlist == nil {
+		_, err := reply("Watchlists are unavailable: the watchlist store could not be opened")
+		return err
+	}
+	symbols := h.watchlist.List(data.ChannelID)
+	if len(symbols) == 0 {
+		_, err := reply("This channel's watchlist is empty, add symbols with /watchlist add <symbol>...")
+		return err
+	}
+
+	args, err := scanAllSchema.parse(data.Text)
+	if err == nil {
+		err = validateFCSArgs(args)
+	}
+	if err != nil {
+		_, err := reply(scanAllSchema.usageError(err))
+		return err
+	}
+
+	topN := args.Int("top")
+	if topN <= 0 {
+		topN = h.fcs.config.TopN
+	}
+	if topN <= 0 {
+		topN = defaultTopN
+	}
+
+	ts, err := reply(fmt.Sprintf("Starting credit spread analysis for %d watchlist symbols: %s", len(symbols), strings.Join(symbols, ", ")))
+	if err != nil {
+		return err
+	}
+
+	go h.fcs.scanSymbols(client, data.ChannelID, ts, symbols, args, topN)
+	return nil
+}
+
+// scanSymbols scans every symbol through the Analyzer, which fetches, calibrates and scans at most
+// SymbolWorkers symbols at a time, then scores and ranks the combined spreads so they compare across
+// symbols.
+func (h *FCSHandler) scanSymbols(client *socketmode.Client, channelID, timestamp string, symbols []string, args commandArgs, topN int) {
+	post := func(text string) {
+		client.PostMessage(channelID, slack.MsgOptionText(text, false), slack.MsgOptionTS(timestamp))
+	}
+
+	scanOptions := positions.ScanOptionsFromEnv()
+	scanOptions.Slippage = h.fills
+	scanOptions.Fill = h.config.Fill
+	scanOptions.ExpirationTypes, _ = positions.ParseExpirationTypes(args.String("expirations")) // Validated by validateFCSArgs
+	scanOptions.ShortDated, _ = strconv.ParseBool(args.String("shortDated"))
+
+	// Progress and calibration details of each symbol would flood the thread, so they are only logged
+	track := func(symbol string) *progress.Tracker {
+		tracker := progress.Start(symbol)
+		go tracker.Watch(progressInterval, nil, func(s progress.Snapshot) {
+			fmt.Printf("%s progress: %s\n", symbol, s)
+		})
+		return tracker
+	}
+	indicator := strings.ToLower(args.String("indicator"))
+	scanned, err := h.config.Analyzer.AnalyzeSymbols(context.Background(), symbols, stocd.AnalyzeRequest{
+		Indicator:    indicator,
+		MinDTE:       args.Int("minDTE"),
+		MaxDTE:       args.Int("maxDTE"),
+		MinRoR:       args.Float("minRoR"),
+		RiskFreeRate: args.Float("rfr"),
+		Options:      scanOptions,
+		Ranking:      h.ranking(args),
+		Top:          topN,
+		Limit:        topN,
+		Status: positions.StatusFunc(func(msg string) {
+			log.Printf("Multi-symbol calibration: %s", msg)
+		}),
+		Started: func(s stocd.Scan) {
+			if s.Direction != "" {
+				post(fmt.Sprintf("%s direction: %s selected %s", s.Symbol, indicator, s.Direction))
+			}
+		},
+	}, track)
+	if err != nil {
+		log.Printf("Error scanning %s: %v", strings.Join(symbols, ", "), err)
+	}
+
+	var failed []string
+	for _, symbol := range symbols {
+		if err, ok := scanned.Failed[strings.ToUpper(symbol)]; ok {
+			post(fmt.Sprintf("Skipping %s: %v", symbol, err))
+			failed = append(failed, symbol)
+		}
+	}
+	for _, result := range scanned.Results {
+		post(fmt.Sprintf("%s: %d %s spreads meeting criteria", result.Symbol, result.Found, spreadTypeLabel(result.SpreadType)))
+	}
+
+	var resultMsg strings.Builder
+	f := h.config.Report
+	resultMsg.WriteString(fmt.Sprintf("Multi-symbol analysis complete at %s. Found %d spreads across %d symbols.\n", f.Time(time.Now()), scanned.Found, len(scanned.Results)))
+	if len(failed) > 0 {
+		resultMsg.WriteString(fmt.Sprintf("Failed to scan: %s\n", strings.Join(failed, ", ")))
+	}
+	resultMsg.WriteString("\n")
+	for i, spread := range scanned.Spreads[:min(topN, len(scanned.Spreads))] {
+		resultMsg.WriteString(formatSpread(f, i+1, spread))
+	}
+
+	post(resultMsg.String())
+	notify.NotifyAll(h.config.Notifiers, fmt.Sprintf("STOCD results for %s", strings.Join(symbols, ", ")), resultMsg.String())
+}
@@ -0,0 +1,137 @@
+package stocdslack
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/schedule"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const scheduleUsage = "Usage: /schedule <fcs arguments> <daily|weekdays|mon-sun> <HH:MM> | /schedule list | /schedule delete <id>\nTimes are US/Eastern, e.g. /schedule AAPL minDTE=30 weekdays 09:45"
+
+type ScheduleHandler struct {
+	scheduler *schedule.Scheduler
+	fcs       *FCSHandler
+}
+
+func NewScheduleHandler(scheduler *schedule.Scheduler, fcs *FCSHandler) *ScheduleHandler {
+	return &ScheduleHandler{scheduler: scheduler, fcs: fcs}
+}
+
+func (h *ScheduleHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Client) error {
+	data := evt.Data.(slack.SlashCommand)
+	args := strings.Fields(data.Text)
+
+	reply := func(text string) error {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(text, false))
+		return err
+	}
+
+	if h.scheduler == nil {
+		return reply("Scheduled scans are unavailable: the schedule store could not be opened")
+	}
+	if len(args) == 0 {
+		return reply(scheduleUsage)
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		schedules := h.scheduler.List(data.ChannelID)
+		if len(schedules) == 0 {
+			return reply("No scans are scheduled in this channel")
+		}
+		var msg strings.Builder
+		msg.WriteString("Scheduled scans:\n")
+		for _, s := range schedules {
+			msg.WriteString(fmt.Sprintf("  %s\n", s.Describe()))
+		}
+		return reply(msg.String())
+
+	case args[0] == "delete" && len(args) == 2:
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return reply(fmt.Sprintf("Invalid schedule ID %q", args[1]))
+		}
+		if err := h.scheduler.Delete(id, data.ChannelID); err != nil {
+			return reply(fmt.Sprintf("Error deleting schedule: %v", err))
+		}
+		return reply(fmt.Sprintf("Deleted schedule %d", id))
+	}
+
+	s, err := schedule.Parse(data.Text)
+	if err != nil {
+		return reply(fmt.Sprintf("%v\n%s", err, scheduleUsage))
+	}
+	scanArgs, err := fcsSchema.parse(s.Args)
+	if err == nil {
+		err = validateFCSArgs(scanArgs)
+	}
+	if err != nil {
+		return reply(fcsSchema.usageError(err))
+	}
+
+	s.ChannelID = data.ChannelID
+	s, err = h.scheduler.Add(s)
+	if err != nil {
+		return reply(fmt.Sprintf("Error saving schedule: %v", err))
+	}
+	return reply(fmt.Sprintf("Scheduled scan %s", s.Describe()))
+}
+
+// run plans a due schedule from the current state of its DTE window and starts the scan, if any.
+func (h *ScheduleHandler) run(client *socketmode.Client, s schedule.Schedule) error {
+	args, err := fcsSchema.parse(s.Args)
+	if err != nil {
+		return err
+	}
+
+	data, err := h.fcs.config.Analyzer.MarketData(context.Background(), strings.ToUpper(args.String("symbol")), args.Int("minDTE"), args.Int("maxDTE"))
+	if err != nil {
+		log.Printf("Error fetching market data to plan scheduled scan %d, running it as configured: %v", s.ID, err)
+		return h.fcs.startScan(client, s.ChannelID, s.Args)
+	}
+
+	now := market.Now()
+	state := schedule.WindowOf(data.Chain)
+	effort, reason := s.Plan(now, state)
+	if err := h.scheduler.Record(s.ID, state, effort); err != nil {
+		log.Printf("Error recording scheduled scan %d: %v", s.ID, err)
+	}
+	log.Printf("Scheduled scan %d effort %s: %s", s.ID, effort, reason)
+
+	switch effort {
+	case schedule.EffortSkip:
+		return nil
+	case schedule.EffortDeep:
+		client.PostMessage(s.ChannelID, slack.MsgOptionText(fmt.Sprintf("Running a deep scan for schedule %d: %s", s.ID, reason), false))
+		return h.fcs.startScan(client, s.ChannelID, fcsSchema.format(h.deepen(args, now)))
+	}
+	return h.fcs.startScan(client, s.ChannelID, s.Args)
+}
+
+// deepen extends a scan through the monthly expiration after the upcoming one, so rolls out of the
+// expiring cycle are covered, and doubles the number of spreads shown.
+func (h *ScheduleHandler) deepen(args commandArgs, now time.Time) commandArgs {
+	next := schedule.MonthlyExpiration(schedule.MonthlyExpiration(now).AddDate(0, 0, 1))
+	if dte, err := market.DaysToExpiration(next.Format(market.DateLayout), now); err == nil && dte > args.Int("maxDTE") {
+		args.values["maxDTE"] = strconv.Itoa(dte)
+	}
+
+	topN := args.Int("top")
+	if topN <= 0 {
+		topN = h.fcs.config.TopN
+	}
+	if topN <= 0 {
+		topN = defaultTopN
+	}
+	args.values["top"] = strconv.Itoa(2 * topN)
+
+	return args
+}
@@ -0,0 +1,62 @@
+package stocdslack
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bcdannyboy/stocd/archive"
+	"github.com/bcdannyboy/stocd/screener"
+	"github.com/bcdannyboy/stocd/watchlist"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+type ScreenHandler struct {
+	factors   []screener.Factor
+	watchlist *watchlist.Store
+	archive   *archive.Archive
+}
+
+func NewScreenHandler(factors []screener.Factor, store *watchlist.Store, history *archive.Archive) *ScreenHandler {
+	return &ScreenHandler{factors: factors, watchlist: store, archive: history}
+}
+
+func (h *ScreenHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Client) error {
+	data := evt.Data.(slack.SlashCommand)
+
+	symbols := strings.Fields(strings.ToUpper(data.Text))
+	if len(symbols) == 0 && h.watchlist != nil {
+		symbols = h.watchlist.List(data.ChannelID)
+	}
+	if len(symbols) == 0 {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText("Usage: /screen <symbol>... (or add symbols with /watchlist add to screen the watchlist)", false))
+		return err
+	}
+
+	_, ts, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(fmt.Sprintf("Screening %s...", strings.Join(symbols, ", ")), false))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		text := screenSymbols(symbols, h.factors, h.archive)
+		client.PostMessage(data.ChannelID, slack.MsgOptionText(text, false), slack.MsgOptionTS(ts))
+	}()
+	return nil
+}
+
+// screenSymbols screens the symbols, rendering the ranking and any symbols that failed.
+func screenSymbols(symbols []string, factors []screener.Factor, history *archive.Archive) string {
+	results, failed := screener.ScreenSymbols(symbols, os.Getenv("TRADIER_KEY"), screener.DefaultMinDTE, screener.DefaultMaxDTE, factors, history)
+
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("Screener ranking of options between %d and %d DTE:\n", screener.DefaultMinDTE, screener.DefaultMaxDTE))
+	for i, result := range results {
+		msg.WriteString(screener.Describe(i+1, result, factors) + "\n")
+	}
+	if len(failed) > 0 {
+		msg.WriteString(fmt.Sprintf("Failed to fetch: %s\n", strings.Join(failed, ", ")))
+	}
+	return msg.String()
+}
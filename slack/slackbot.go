@@ -4,17 +4,60 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/bcdannyboy/stocd"
+	"github.com/bcdannyboy/stocd/archive"
+	"github.com/bcdannyboy/stocd/execution"
+	"github.com/bcdannyboy/stocd/monitor"
+	"github.com/bcdannyboy/stocd/notify"
+	"github.com/bcdannyboy/stocd/paper"
+	"github.com/bcdannyboy/stocd/positions"
+	"github.com/bcdannyboy/stocd/report"
+	"github.com/bcdannyboy/stocd/schedule"
+	"github.com/bcdannyboy/stocd/screener"
+	"github.com/bcdannyboy/stocd/watchlist"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/socketmode"
 )
 
+// Config holds the command line options that change how the bot runs scans.
+type Config struct {
+	Analyzer *stocd.Analyzer // Runs scans on market data from Tradier, the futures gateway or Deribit, nil for one using TRADIER_KEY and Archive
+
+	ExportFormat string // Export format for the full scan results (json, ndjson, csv or parquet), empty to disable
+	ExportPath   string // File or directory the results are exported to
+
+	Archive *archive.Archive // Archive for scan results and their input data, nil to disable
+
+	TopN int // Spreads shown per page of results, 0 for the default of 10
+
+	Notifiers []notify.Notifier // Additional channels the results of every scan are pushed to
+
+	Report     report.Formatter // Locale and timezone used to render results
+	HTMLReport bool             // Render an HTML report with charts for each scan
+	FastAnswer bool             // Post a preliminary analytic ranking while the full simulation runs
+
+	Monitor   *monitor.Monitor    // Exit signal monitor for open positions, nil to disable
+	Scheduler *schedule.Scheduler // Recurring scans, nil to disable
+	Watchlist *watchlist.Store    // Per-channel symbol lists for /scanall, nil to disable
+
+	ScreenerFactors []screener.Factor // Factors and weights /screen ranks symbols by
+
+	Fill    positions.FillModel          // Fill price assumed for the legs of scanned positions
+	Ranking positions.RankingConstraints // Limits on near-duplicate spreads among the top results
+
+	Broker execution.Broker // Brokerage /order previews and places tickets through, nil to disable
+	Paper  *paper.Store     // Hypothetical fills tracked by /paper, nil to disable
+}
+
 type SlackBot struct {
 	client       *slack.Client
 	socketClient *socketmode.Client
 	eventHandler *Handler
+	monitor      *monitor.Monitor
+	scheduler    *schedule.Scheduler
 }
 
-func NewSlackBot(appToken, botToken string) *SlackBot {
+func NewSlackBot(appToken, botToken string, config Config) *SlackBot {
 	client := slack.New(
 		botToken,
 		slack.OptionAppLevelToken(appToken),
@@ -29,7 +72,9 @@ func NewSlackBot(appToken, botToken string) *SlackBot {
 	bot := &SlackBot{
 		client:       client,
 		socketClient: socketClient,
-		eventHandler: NewHandler(),
+		eventHandler: NewHandler(config),
+		monitor:      config.Monitor,
+		scheduler:    config.Scheduler,
 	}
 
 	// Send startup message to all channels
@@ -70,14 +115,37 @@ func (sb *SlackBot) notifyAllChannels() {
 }
 
 func (sb *SlackBot) Start() error {
+	if sb.monitor != nil {
+		go sb.monitor.Run(nil, func(signal monitor.Signal) {
+			if signal.Position.ChannelID == "" {
+				return
+			}
+			_, _, err := sb.client.PostMessage(signal.Position.ChannelID, slack.MsgOptionText(signal.Message(), false))
+			if err != nil {
+				log.Printf("Error posting exit signal: %v", err)
+			}
+		})
+	}
+
 	go func() {
 		for evt := range sb.socketClient.Events {
 			switch evt.Type {
 			case socketmode.EventTypeSlashCommand:
 				sb.eventHandler.Handle(&evt, sb.socketClient)
+			case socketmode.EventTypeInteractive:
+				sb.eventHandler.HandleInteraction(&evt, sb.socketClient)
 			}
 		}
 	}()
 
+	if sb.scheduler != nil {
+		go sb.scheduler.Run(nil, func(s schedule.Schedule) {
+			log.Printf("Running scheduled scan %s", s.Describe())
+			if err := sb.eventHandler.scheduleHandler.run(sb.socketClient, s); err != nil {
+				log.Printf("Error starting scheduled scan %d: %v", s.ID, err)
+			}
+		})
+	}
+
 	return sb.socketClient.Run()
 }
@@ -0,0 +1,91 @@
+package stocdslack
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bcdannyboy/stocd/futures"
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/positions"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+type TermHandler struct {
+	config Config
+}
+
+func NewTermHandler(config Config) *TermHandler {
+	return &TermHandler{config: config}
+}
+
+func (h *TermHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Client) error {
+	data := evt.Data.(slack.SlashCommand)
+
+	args, err := termSchema.parse(data.Text)
+	if err == nil {
+		switch {
+		case args.Float("strike") <= 0:
+			err = fmt.Errorf("strike must be positive")
+		case args.Float("rfr") < 0 || args.Float("rfr") >= 1:
+			err = fmt.Errorf("rfr must be an annual rate between 0 and 1, e.g. 0.04 for 4%%")
+		case args.Int("maxDTE") <= 0:
+			err = fmt.Errorf("maxDTE must be positive")
+		}
+	}
+	if err != nil {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(termSchema.usageError(err), false))
+		return err
+	}
+
+	symbol := strings.ToUpper(args.String("symbol"))
+	strike := args.Float("strike")
+	rfr := args.Float("rfr")
+	maxDTE := args.Int("maxDTE")
+
+	_, ts, err := client.PostMessage(data.ChannelID,
+		slack.MsgOptionText(fmt.Sprintf("Simulating %s probabilities around %.2f for expirations up to %d DTE", symbol, strike, maxDTE), false))
+	if err != nil {
+		return err
+	}
+
+	go h.runTermStructure(client, data.ChannelID, ts, symbol, strike, rfr, maxDTE)
+
+	return nil
+}
+
+func (h *TermHandler) runTermStructure(client *socketmode.Client, channelID, timestamp, symbol string, strike, rfr float64, maxDTE int) {
+	data, err := h.config.Analyzer.MarketData(context.Background(), symbol, 0, maxDTE)
+	if err != nil {
+		client.PostMessage(channelID, slack.MsgOptionText(fmt.Sprintf("Error fetching market data: %v", err), false), slack.MsgOptionTS(timestamp))
+		return
+	}
+	quotes, optionsChain, lastPrice := data.Quotes, data.Chain, data.Price
+	if futures.IsSymbol(symbol) {
+		rfr = 0 // A futures price has no drift under the risk-neutral measure (Black-76)
+	}
+
+	status := positions.StatusFunc(func(msg string) {
+		log.Printf("Term structure calibration: %s", msg)
+	})
+
+	points := positions.StrikeTermStructure(optionsChain, lastPrice, rfr, *quotes, strike, market.Now(), status)
+
+	if len(points) == 0 {
+		client.PostMessage(channelID, slack.MsgOptionText("No expirations available to simulate", false), slack.MsgOptionTS(timestamp))
+		return
+	}
+
+	f := h.config.Report
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("%s probability of finishing beyond %s (spot %s):\n```\n", symbol, f.Number(strike, 2), f.Number(lastPrice, 2)))
+	msg.WriteString(fmt.Sprintf("%-12s %5s %8s %9s %9s\n", "Expiration", "DTE", "IV", "P(above)", "P(below)"))
+	for _, point := range points {
+		msg.WriteString(fmt.Sprintf("%-12s %5d %8s %9s %9s\n", point.Expiration, point.DTE, f.Percent(point.Volatility, 1), f.Percent(point.ProbAbove, 1), f.Percent(1-point.ProbAbove, 1)))
+	}
+	msg.WriteString("```")
+
+	client.PostMessage(channelID, slack.MsgOptionText(msg.String(), false), slack.MsgOptionTS(timestamp))
+}
@@ -0,0 +1,34 @@
+package stocdslack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ticketArgs splits the text of a command taking a ticket into the ticket's JSON, from the first { to the
+// last }, and the optional quantity after it, 1 by default. The JSON is not split at its spaces, e.g. those
+// of "strategy":"Bull Put".
+func ticketArgs(text string) (string, int, error) {
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return "", 0, fmt.Errorf("missing ticket")
+	}
+	rest := strings.Fields(strings.Trim(strings.TrimSpace(text[end+1:]), "`"))
+	if before := strings.Trim(strings.TrimSpace(text[:start]), "`"); before != "" {
+		return "", 0, fmt.Errorf("unexpected %q before the ticket", before)
+	}
+
+	quantity := 1
+	switch len(rest) {
+	case 0:
+	case 1:
+		var err error
+		if quantity, err = strconv.Atoi(rest[0]); err != nil {
+			return "", 0, fmt.Errorf("invalid quantity %q", rest[0])
+		}
+	default:
+		return "", 0, fmt.Errorf("too many arguments after the ticket: %s", strings.Join(rest, " "))
+	}
+	return text[start : end+1], quantity, nil
+}
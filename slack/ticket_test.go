@@ -0,0 +1,55 @@
+package stocdslack
+
+import (
+	"testing"
+
+	"github.com/bcdannyboy/stocd/execution"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/results"
+)
+
+func TestTicketArgsKeepsTicketWhole(t *testing.T) {
+	var spread models.SpreadWithProbabilities
+	spread.Spread.SpreadType = "Bull Put"
+	spread.Spread.ShortLeg.Option.Underlying = "SPY"
+	spread.Spread.ShortLeg.Option.Symbol, spread.Spread.ShortLeg.Option.Strike = "SPY260116P00500000", 500
+	spread.Spread.LongLeg.Option.Symbol, spread.Spread.LongLeg.Option.Strike = "SPY260116P00495000", 495
+	spread.Spread.SpreadCredit = 1.25
+	ticket, err := results.TicketJSON(spread)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text     string
+		quantity int
+	}{
+		{ticket, 1},
+		{ticket + " 3", 3},
+		{"`" + ticket + "` 2", 2},
+	}
+	for _, tt := range tests {
+		text, quantity, err := ticketArgs(tt.text)
+		if err != nil {
+			t.Errorf("ticketArgs(%s): %s", tt.text, err)
+			continue
+		}
+		if quantity != tt.quantity {
+			t.Errorf("quantity = %d, want %d", quantity, tt.quantity)
+		}
+		parsed, err := execution.ParseTicket(text)
+		if err != nil {
+			t.Errorf("ParseTicket(%s): %s", text, err)
+			continue
+		}
+		if parsed.Strategy != "Bull Put" || parsed.ShortSymbol != "SPY260116P00500000" {
+			t.Errorf("parsed %+v", parsed)
+		}
+	}
+
+	for _, text := range []string{"", "positions", ticket + " two", ticket + " 1 2"} {
+		if _, _, err := ticketArgs(text); err == nil {
+			t.Errorf("ticketArgs(%q) succeeded", text)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package stocdslack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bcdannyboy/stocd/watchlist"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const watchlistUsage = "Usage: /watchlist add <symbol>... | /watchlist remove <symbol>... | /watchlist list"
+
+type WatchlistHandler struct {
+	watchlist *watchlist.Store
+}
+
+func NewWatchlistHandler(store *watchlist.Store) *WatchlistHandler {
+	return &WatchlistHandler{watchlist: store}
+}
+
+func (h *WatchlistHandler) HandleCommand(evt *socketmode.Event, client *socketmode.Client) error {
+	data := evt.Data.(slack.SlashCommand)
+	args := strings.Fields(data.Text)
+
+	reply := func(text string) error {
+		_, _, err := client.PostMessage(data.ChannelID, slack.MsgOptionText(text, false))
+		return err
+	}
+
+	if h.watchlist == nil {
+		return reply("Watchlists are unavailable: the watchlist store could not be opened")
+	}
+	if len(args) == 0 {
+		return reply(watchlistUsage)
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		symbols := h.watchlist.List(data.ChannelID)
+		if len(symbols) == 0 {
+			return reply("This channel's watchlist is empty")
+		}
+		return reply(fmt.Sprintf("Watchlist: %s", strings.Join(symbols, ", ")))
+
+	case args[0] == "add" && len(args) > 1:
+		added, err := h.watchlist.Add(data.ChannelID, args[1:]...)
+		if err != nil {
+			return reply(fmt.Sprintf("Error saving watchlist: %v", err))
+		}
+		if len(added) == 0 {
+			return reply("Already on the watchlist")
+		}
+		return reply(fmt.Sprintf("Added %s to the watchlist", strings.Join(added, ", ")))
+
+	case args[0] == "remove" && len(args) > 1:
+		removed, err := h.watchlist.Remove(data.ChannelID, args[1:]...)
+		if err != nil {
+			return reply(fmt.Sprintf("Error saving watchlist: %v", err))
+		}
+		if len(removed) == 0 {
+			return reply("None of those symbols are on the watchlist")
+		}
+		return reply(fmt.Sprintf("Removed %s from the watchlist", strings.Join(removed, ", ")))
+	}
+
+	return reply(watchlistUsage)
+}
@@ -0,0 +1,131 @@
+package slippage
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+const (
+	minSamples = 3 // Fills required before a bucket's average slippage is trusted
+)
+
+// Fill records the credit actually received for a spread against the credit the model assumed.
+type Fill struct {
+	Underlying    string    `json:"underlying"`
+	ShortSymbol   string    `json:"short_symbol"`
+	LongSymbol    string    `json:"long_symbol"`
+	Bucket        string    `json:"bucket"`
+	ModeledCredit float64   `json:"modeled_credit"`
+	FillCredit    float64   `json:"fill_credit"`
+	FilledAt      time.Time `json:"filled_at"`
+}
+
+// Slippage is the credit received beyond the modeled credit (positive = better than modeled).
+func (f Fill) Slippage() float64 {
+	return f.FillCredit - f.ModeledCredit
+}
+
+// Store persists fills to a JSON file and derives per symbol/liquidity bucket slippage from them.
+type Store struct {
+	path  string
+	mu    sync.RWMutex
+	fills []Fill
+}
+
+// Open loads the fill history at path, starting empty if the file does not exist yet.
+func Open(path string) (*Store, error) {
+	store := &Store{path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fill history: %s", err)
+	}
+
+	if err := json.Unmarshal(data, &store.fills); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal fill history: %s", err)
+	}
+
+	return store, nil
+}
+
+// Record appends a fill and writes the history back to disk.
+func (s *Store) Record(fill Fill) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fills = append(s.fills, fill)
+
+	data, err := json.MarshalIndent(s.fills, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal fill history: %s", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write fill history: %s", err)
+	}
+
+	return nil
+}
+
+// Adjustment returns the average historical slippage for an underlying and liquidity bucket,
+// falling back to the bucket across all symbols when the symbol has too few fills.
+func (s *Store) Adjustment(underlying, bucket string) float64 {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var symbolSum, bucketSum float64
+	var symbolCount, bucketCount int
+	for _, fill := range s.fills {
+		if fill.Bucket != bucket {
+			continue
+		}
+		bucketSum += fill.Slippage()
+		bucketCount++
+		if fill.Underlying == underlying {
+			symbolSum += fill.Slippage()
+			symbolCount++
+		}
+	}
+
+	if symbolCount >= minSamples {
+		return symbolSum / float64(symbolCount)
+	}
+	if bucketCount >= minSamples {
+		return bucketSum / float64(bucketCount)
+	}
+	return 0
+}
+
+// LiquidityBucket classifies a spread by the average relative bid-ask spread of its legs.
+func LiquidityBucket(shortLeg, longLeg tradier.Option) string {
+	relativeSpread := (relativeBidAsk(shortLeg) + relativeBidAsk(longLeg)) / 2
+
+	switch {
+	case relativeSpread < 0.05:
+		return "tight"
+	case relativeSpread < 0.15:
+		return "normal"
+	default:
+		return "wide"
+	}
+}
+
+func relativeBidAsk(option tradier.Option) float64 {
+	mid := (option.Bid + option.Ask) / 2
+	if mid <= 0 {
+		return math.Inf(1)
+	}
+	return (option.Ask - option.Bid) / mid
+}
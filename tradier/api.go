@@ -1,111 +1,88 @@
 package tradier
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
-)
-
-func GET_QUOTES(Symbol, Start, End, Interval, Token string) (*QuoteHistory, error) {
-	apiURL := fmt.Sprintf("https://api.tradier.com/v1/markets/history?symbol=%s&interval=%s&start=%s&end=%s&session_filter=all", Symbol, Interval, Start, End)
 
-	u, _ := url.ParseRequestURI(apiURL)
-	urlStr := u.String()
-
-	client := &http.Client{}
-	r, _ := http.NewRequest("GET", urlStr, nil)
-	r.Header.Add("Authorization", fmt.Sprintf("Bearer %s", Token))
-	r.Header.Add("Accept", "application/json")
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/metrics"
+)
 
-	resp, _ := client.Do(r)
-	responseData, err := ioutil.ReadAll(resp.Body)
+var (
+	requestDuration = metrics.NewHistogram("stocd_tradier_request_duration_seconds", "Latency of Tradier API requests, by endpoint.", metrics.DurationBuckets, "endpoint")
+	requestErrors   = metrics.NewCounter("stocd_tradier_request_errors_total", "Tradier API requests that failed or returned an error status, by endpoint.", "endpoint")
+)
 
+// do sends the request, recording its latency and any failure under endpoint.
+func do(client *http.Client, r *http.Request, endpoint string) (*http.Response, error) {
+	span := metrics.StartSpan("tradier "+endpoint, nil)
+	start := time.Now()
+	resp, err := client.Do(r)
+	requestDuration.ObserveSince(start, endpoint)
+	if err == nil && resp.StatusCode >= 400 {
+		span.End(fmt.Errorf("tradier returned %s", resp.Status))
+		requestErrors.Inc(endpoint)
+		return resp, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response data: %s", err)
+		requestErrors.Inc(endpoint)
 	}
+	span.End(err)
+	return resp, err
+}
 
-	defer resp.Body.Close()
-
+func GET_QUOTES(Symbol, Start, End, Interval, Token string) (*QuoteHistory, error) {
+	query := url.Values{"symbol": {Symbol}, "interval": {Interval}, "start": {Start}, "end": {End}, "session_filter": {"all"}}
 	quoteHistory := &QuoteHistory{}
-
-	err = json.Unmarshal(responseData, quoteHistory)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response data: %s", err.Error())
+	if err := get("history", "/v1/markets/history", query, Token, quoteHistory); err != nil {
+		return nil, err
 	}
-
 	return quoteHistory, nil
 }
 
-func GET_OPTIONS_CHAIN(Symbol, Token string, minDTE, maxDTE int) (map[string]*OptionChain, error) {
-	expiratons_apiURL := fmt.Sprintf("https://api.tradier.com/v1/markets/options/expirations?symbol=%s&includeAllRoots=true&strikes=true&contractSize=true&expirationType=true", Symbol)
-
-	eu, _ := url.ParseRequestURI(expiratons_apiURL)
-	expiratons_urlStr := eu.String()
-
-	client := &http.Client{}
-	er, _ := http.NewRequest("GET", expiratons_urlStr, nil)
-	er.Header.Add("Authorization", fmt.Sprintf("Bearer %s", Token))
-	er.Header.Add("Accept", "application/json")
-
-	expiratons_resp, _ := client.Do(er)
-	expiratons_responseData, err := ioutil.ReadAll(expiratons_resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read expirations response data: %s", err)
+// GET_TIMESALES fetches the intraday bars of the regular sessions between start and end, formatted
+// YYYY-MM-DD HH:MM, at an interval of 1min, 5min or 15min.
+func GET_TIMESALES(Symbol, Interval, Start, End, Token string) (*TimeSales, error) {
+	query := url.Values{"symbol": {Symbol}, "interval": {Interval}, "start": {Start}, "end": {End}, "session_filter": {"open"}}
+	timeSales := &TimeSales{}
+	if err := get("timesales", "/v1/markets/timesales", query, Token, timeSales); err != nil {
+		return nil, err
 	}
+	return timeSales, nil
+}
 
-	defer expiratons_resp.Body.Close()
-
-	expiratons_optionChain := &OptionExpirations{}
-	err = json.Unmarshal(expiratons_responseData, expiratons_optionChain)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal expirations response data: %s", err)
+func GET_OPTIONS_CHAIN(Symbol, Token string, minDTE, maxDTE int) (map[string]*OptionChain, error) {
+	query := url.Values{"symbol": {Symbol}, "includeAllRoots": {"true"}, "strikes": {"true"}, "contractSize": {"true"}, "expirationType": {"true"}}
+	expirations := &OptionExpirations{}
+	if err := get("expirations", "/v1/markets/options/expirations", query, Token, expirations); err != nil {
+		return nil, err
 	}
 
 	ChainMap := make(map[string]*OptionChain)
-	today := time.Now()
+	now := market.Now()
 
-	for _, expiration := range expiratons_optionChain.Expirations.Expiration {
+	for _, expiration := range expirations.Expirations.Expiration {
 		exp_date := expiration.Date
 		if exp_date == "" {
 			continue // Skip empty expiration dates
 		}
 
-		expirationTime, err := time.Parse("2006-01-02", exp_date)
+		dte, err := market.DaysToExpiration(exp_date, now)
 		if err != nil {
 			fmt.Printf("Warning: failed to parse expiration date %s: %s\n", exp_date, err)
 			continue
 		}
-
-		dte := int(expirationTime.Sub(today).Hours() / 24)
 		if dte < minDTE || dte > maxDTE {
 			continue
 		}
 
-		chain_apiURL := fmt.Sprintf("https://api.tradier.com/v1/markets/options/chains?symbol=%s&expiration=%s&greeks=true", Symbol, exp_date)
-		cu, _ := url.ParseRequestURI(chain_apiURL)
-		chain_urlStr := cu.String()
-
-		cr, _ := http.NewRequest("GET", chain_urlStr, nil)
-		cr.Header.Add("Authorization", fmt.Sprintf("Bearer %s", Token))
-		cr.Header.Add("Accept", "application/json")
-
-		chain_resp, _ := client.Do(cr)
-		chain_responseData, err := ioutil.ReadAll(chain_resp.Body)
-		if err != nil {
-			fmt.Printf("Error reading chain response data for expiration %s: %s\n", exp_date, err)
-			continue
-		}
-
-		defer chain_resp.Body.Close()
-
 		optionChain := &OptionChain{}
-		err = json.Unmarshal(chain_responseData, optionChain)
-		if err != nil {
-			fmt.Printf("Error unmarshalling chain response data for expiration %s: %s\n", exp_date, err)
+		chainQuery := url.Values{"symbol": {Symbol}, "expiration": {exp_date}, "greeks": {"true"}}
+		if err := get("chains", "/v1/markets/options/chains", chainQuery, Token, optionChain); err != nil {
+			fmt.Printf("Error fetching chain for expiration %s: %s\n", exp_date, err)
 			continue
 		}
 
@@ -121,30 +98,25 @@ func GET_OPTIONS_CHAIN(Symbol, Token string, minDTE, maxDTE int) (map[string]*Op
 }
 
 func GET_PRICE_STATISTICS(symbols, token string) (*PriceStatistics, error) {
-	apiURL := fmt.Sprintf("https://api.tradier.com/beta/markets/fundamentals/statistics?symbols=%s", symbols)
-
-	u, _ := url.ParseRequestURI(apiURL)
-	urlStr := u.String()
-
-	client := &http.Client{}
-	r, _ := http.NewRequest("GET", urlStr, nil)
-	r.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
-	r.Header.Add("Accept", "application/json")
-
-	resp, _ := client.Do(r)
-	responseData, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response data: %s", err)
+	priceStatistics := &PriceStatistics{}
+	if err := get("statistics", "/beta/markets/fundamentals/statistics", url.Values{"symbols": {symbols}}, token, priceStatistics); err != nil {
+		return nil, err
 	}
+	return priceStatistics, nil
+}
 
-	defer resp.Body.Close()
-
-	priceStatistics := &PriceStatistics{}
+func GET_MARKET_QUOTES(symbols []string, token string) ([]Option, error) {
+	query := url.Values{"symbols": {strings.Join(symbols, ",")}, "greeks": {"true"}}
+	marketQuotes := &MarketQuotes{}
+	if err := get("quotes", "/v1/markets/quotes", query, token, marketQuotes); err != nil {
+		return nil, err
+	}
 
-	err = json.Unmarshal(responseData, priceStatistics)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response data: %s", err)
+	// Tradier returns a bare object instead of an array when a single symbol is requested
+	var quotes []Option
+	if err := unmarshalOneOrMany(marketQuotes.Quotes.Quote, &quotes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quotes: %s", err)
 	}
 
-	return priceStatistics, nil
+	return quotes, nil
 }
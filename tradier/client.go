@@ -0,0 +1,153 @@
+package tradier
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	DefaultBaseURL = "https://api.tradier.com"     // Root of Tradier's production market data API
+	SandboxBaseURL = "https://sandbox.tradier.com" // Root of Tradier's sandbox, with delayed data and its own keys
+)
+
+// Endpoints names the endpoints the package requests, which Client.Endpoints can send elsewhere.
+var Endpoints = []string{"history", "timesales", "expirations", "chains", "quotes", "statistics"}
+
+// Client is how the package's functions reach Tradier. Tests replace its Transport, e.g. with a
+// tradiertest.Transport, to serve canned responses without the network.
+type Client struct {
+	BaseURL   string            // Root of the API, DefaultBaseURL when empty
+	Endpoints map[string]string // Roots replacing BaseURL for particular endpoints, by name
+	Token     string            // Replaces the token callers pass to BaseURL when set, e.g. with a sandbox key
+	Transport http.RoundTripper // Sends the requests, http.DefaultTransport when nil
+}
+
+// ClientFromEnv returns the client configured by the environment: TRADIER_SANDBOX=true sends requests to
+// SandboxBaseURL with TRADIER_SANDBOX_KEY, which it then requires, TRADIER_URL sets the root of the API
+// (taking precedence over the sandbox's), and TRADIER_<ENDPOINT>_URL, e.g. TRADIER_STATISTICS_URL, sets
+// the root of one endpoint.
+func ClientFromEnv() (Client, error) {
+	var c Client
+	if value := os.Getenv("TRADIER_SANDBOX"); value != "" {
+		sandbox, err := strconv.ParseBool(value)
+		if err != nil {
+			return Client{}, fmt.Errorf("invalid TRADIER_SANDBOX %q", value)
+		}
+		if sandbox {
+			// Sandbox keys and production keys are not interchangeable, so the production key is never sent
+			c.Token = os.Getenv("TRADIER_SANDBOX_KEY")
+			if c.Token == "" {
+				return Client{}, fmt.Errorf("TRADIER_SANDBOX is set without TRADIER_SANDBOX_KEY")
+			}
+			c.BaseURL = SandboxBaseURL
+		}
+	}
+	if value := os.Getenv("TRADIER_URL"); value != "" {
+		if err := validateBaseURL(value); err != nil {
+			return Client{}, fmt.Errorf("invalid TRADIER_URL: %s", err)
+		}
+		c.BaseURL = value
+	}
+	for _, endpoint := range Endpoints {
+		env := "TRADIER_" + strings.ToUpper(endpoint) + "_URL"
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		if err := validateBaseURL(value); err != nil {
+			return Client{}, fmt.Errorf("invalid %s: %s", env, err)
+		}
+		if c.Endpoints == nil {
+			c.Endpoints = make(map[string]string)
+		}
+		c.Endpoints[endpoint] = value
+	}
+	return c, nil
+}
+
+// validateBaseURL checks that base is an absolute http or https URL.
+func validateBaseURL(base string) error {
+	u, err := url.Parse(base)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https URL", base)
+	}
+	return nil
+}
+
+// baseURL returns the root the endpoint is requested from.
+func (c Client) baseURL(endpoint string) string {
+	base := c.BaseURL
+	if override := c.Endpoints[endpoint]; override != "" {
+		base = override
+	}
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+var client atomic.Pointer[Client]
+
+func init() {
+	SetClient(Client{})
+}
+
+// CurrentClient returns the client the package's functions use.
+func CurrentClient() Client {
+	return *client.Load()
+}
+
+// SetClient changes the client for requests made afterwards.
+func SetClient(c Client) {
+	client.Store(&c)
+}
+
+// get requests path with the query from the current client and decodes the JSON response into v,
+// recording the request's latency and any failure under endpoint.
+func get(endpoint, path string, query url.Values, token string, v interface{}) error {
+	c := CurrentClient()
+	r, err := http.NewRequest("GET", c.baseURL(endpoint)+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %s", endpoint, err)
+	}
+	if _, overridden := c.Endpoints[endpoint]; c.Token != "" && !overridden {
+		token = c.Token
+	}
+	r.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	r.Header.Add("Accept", "application/json")
+
+	resp, err := do(&http.Client{Transport: c.Transport}, r, endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %s", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response data: %s", endpoint, err)
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("tradier returned %s for %s: %s", resp.Status, endpoint, truncate(string(responseData), 200))
+	}
+	if err := json.Unmarshal(responseData, v); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response data: %s", endpoint, err)
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
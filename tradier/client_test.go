@@ -0,0 +1,140 @@
+package tradier_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/tradier"
+	"github.com/bcdannyboy/stocd/tradiertest"
+)
+
+// install serves the transport's responses to the package's functions for the rest of the test.
+func install(t *testing.T) *tradiertest.Transport {
+	t.Helper()
+	mock := tradiertest.NewTransport()
+	t.Cleanup(mock.Install())
+	return mock
+}
+
+func handleFixture(t *testing.T, mock *tradiertest.Transport, path string, params map[string]string, name string) {
+	t.Helper()
+	if err := mock.HandleFixture(path, params, name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetOptionsChain(t *testing.T) {
+	mock := install(t)
+	near := market.Now().AddDate(0, 0, 10).Format(market.DateLayout)
+	far := market.Now().AddDate(0, 0, 20).Format(market.DateLayout)
+	failing := market.Now().AddDate(0, 0, 30).Format(market.DateLayout)
+	tooFar := market.Now().AddDate(0, 0, 90).Format(market.DateLayout)
+	mock.Handle("/v1/markets/options/expirations", map[string]string{"symbol": "SPY"}, tradiertest.Expirations(near, far, failing, tooFar))
+	handleFixture(t, mock, "/v1/markets/options/chains", map[string]string{"symbol": "SPY", "expiration": near}, "chain.json")
+	handleFixture(t, mock, "/v1/markets/options/chains", map[string]string{"symbol": "SPY", "expiration": far}, "chain_single.json")
+	mock.HandleStatus("/v1/markets/options/chains", map[string]string{"symbol": "SPY", "expiration": failing}, http.StatusInternalServerError, []byte("internal error"))
+
+	chains, err := tradier.GET_OPTIONS_CHAIN("SPY", "token", 7, 45)
+	if err != nil {
+		t.Fatalf("GET_OPTIONS_CHAIN: %s", err)
+	}
+	if len(chains) != 2 {
+		t.Fatalf("got %d expirations, want the 2 within 7 to 45 days that were served", len(chains))
+	}
+	if chain := chains[near]; chain == nil || len(chain.Options.Option) != 5 || chain.ExpirationDate != near {
+		t.Errorf("chain of %s = %+v, want chain.json's 5 options", near, chain)
+	}
+	if chain := chains[far]; chain == nil || len(chain.Options.Option) != 1 || chain.Options.Option[0].Strike != 540 {
+		t.Errorf("chain of %s = %+v, want chain_single.json's option", far, chain)
+	}
+
+	requests := mock.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("sent %d requests, want the expirations and 3 chains", len(requests))
+	}
+	for _, r := range requests {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("%s sent Authorization %q", r.URL.Path, got)
+		}
+		if strings.Contains(r.URL.RawQuery, tooFar) {
+			t.Errorf("requested the chain of %s, beyond the maximum DTE", tooFar)
+		}
+	}
+}
+
+func TestGetOptionsChainErrors(t *testing.T) {
+	mock := install(t)
+	mock.HandleStatus("/v1/markets/options/expirations", nil, http.StatusUnauthorized, []byte("Invalid Access Token"))
+	_, err := tradier.GET_OPTIONS_CHAIN("SPY", "bad", 7, 45)
+	if err == nil || !strings.Contains(err.Error(), "401") || !strings.Contains(err.Error(), "Invalid Access Token") {
+		t.Errorf("error = %v, want the 401 and its body", err)
+	}
+
+	handleFixture(t, mock, "/v1/markets/options/expirations", nil, "expirations.json")
+	if _, err := tradier.GET_OPTIONS_CHAIN("SPY", "token", 7, 45); err == nil {
+		t.Errorf("expected an error when every expiration has passed")
+	}
+}
+
+func TestGetMarketQuotes(t *testing.T) {
+	mock := install(t)
+	handleFixture(t, mock, "/v1/markets/quotes", map[string]string{"symbols": "SPY,SPY240621P00530000"}, "quotes.json")
+	handleFixture(t, mock, "/v1/markets/quotes", map[string]string{"symbols": "SPY"}, "quote.json")
+	mock.HandleStatus("/v1/markets/quotes", map[string]string{"symbols": "QQQ"}, http.StatusTooManyRequests, []byte("Quota Violation"))
+
+	quotes, err := tradier.GET_MARKET_QUOTES([]string{"SPY", "SPY240621P00530000"}, "token")
+	if err != nil {
+		t.Fatalf("GET_MARKET_QUOTES: %s", err)
+	}
+	if len(quotes) != 2 || quotes[0].Symbol != "SPY" || quotes[1].Strike != 530 || quotes[1].Greeks.MidIv != 0.123 {
+		t.Errorf("quotes = %+v", quotes)
+	}
+
+	quotes, err = tradier.GET_MARKET_QUOTES([]string{"SPY"}, "token")
+	if err != nil {
+		t.Fatalf("GET_MARKET_QUOTES of one symbol: %s", err)
+	}
+	if len(quotes) != 1 || quotes[0].Symbol != "SPY" {
+		t.Errorf("quote = %+v", quotes)
+	}
+
+	if _, err := tradier.GET_MARKET_QUOTES([]string{"QQQ"}, "token"); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("error = %v, want the 429", err)
+	}
+}
+
+func TestGetQuotes(t *testing.T) {
+	mock := install(t)
+	handleFixture(t, mock, "/v1/markets/history", map[string]string{"symbol": "SPY", "interval": "daily"}, "history.json")
+
+	history, err := tradier.GET_QUOTES("SPY", "2024-06-03", "2024-06-14", "daily", "token")
+	if err != nil {
+		t.Fatalf("GET_QUOTES: %s", err)
+	}
+	if len(history.History.Day) == 0 || history.History.Day[0].Date != "2024-06-03" || history.History.Day[0].Close != 527.8 {
+		t.Errorf("history starts %+v", history.History.Day)
+	}
+
+	if _, err := tradier.GET_QUOTES("QQQ", "2024-06-03", "2024-06-14", "daily", "token"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("error = %v, want the 404 of an unserved request", err)
+	}
+}
+
+func TestClientFromEnvSandbox(t *testing.T) {
+	t.Setenv("TRADIER_SANDBOX", "true")
+	t.Setenv("TRADIER_SANDBOX_KEY", "")
+	if _, err := tradier.ClientFromEnv(); err == nil {
+		t.Errorf("expected an error in the sandbox without TRADIER_SANDBOX_KEY")
+	}
+
+	t.Setenv("TRADIER_SANDBOX_KEY", "sandbox")
+	c, err := tradier.ClientFromEnv()
+	if err != nil {
+		t.Fatalf("ClientFromEnv: %s", err)
+	}
+	if c.BaseURL != tradier.SandboxBaseURL || c.Token != "sandbox" {
+		t.Errorf("client = %+v, want the sandbox with its key", c)
+	}
+}
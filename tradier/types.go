@@ -1,5 +1,13 @@
 package tradier
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
 type QuoteHistory struct {
 	History struct {
 		Day []struct {
@@ -13,54 +21,103 @@ type QuoteHistory struct {
 	} `json:"history"`
 }
 
+type TimeSales struct {
+	Series TimeSaleList `json:"series"`
+}
+
+// TimeSale is an intraday bar.
+type TimeSale struct {
+	Time      string  `json:"time"` // Start of the bar, e.g. 2024-06-14T09:30:00, in New York time
+	Timestamp int64   `json:"timestamp"`
+	Price     float64 `json:"price"`
+	Open      float64 `json:"open"`
+	High      float64 `json:"high"`
+	Low       float64 `json:"low"`
+	Close     float64 `json:"close"`
+	Volume    int64   `json:"volume"`
+	Vwap      float64 `json:"vwap"`
+}
+
+type TimeSaleList struct {
+	Data []TimeSale
+}
+
+// UnmarshalJSON accepts Tradier's bars however many there are: a bare object for one and null for none,
+// as well as an array.
+func (l *TimeSaleList) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := unmarshalObject(data, &raw); err != nil {
+		return fmt.Errorf("failed to unmarshal time and sales: %s", err)
+	}
+	return unmarshalOneOrMany(raw.Data, &l.Data)
+}
+
 type OptionExpirations struct {
-	Expirations struct {
-		Expiration []struct {
-			Date           string `json:"date"`
-			ContractSize   int    `json:"contract_size"`
-			ExpirationType string `json:"expiration_type"`
-			Strikes        struct {
-				Strike []float64 `json:"strike"`
-			} `json:"strikes"`
-		} `json:"expiration"`
-	} `json:"expirations"`
+	Expirations ExpirationList `json:"expirations"`
+}
+
+type Expiration struct {
+	Date           string `json:"date"`
+	ContractSize   int    `json:"contract_size"`
+	ExpirationType string `json:"expiration_type"`
+	Strikes        struct {
+		Strike []float64 `json:"strike"`
+	} `json:"strikes"`
+}
+
+type ExpirationList struct {
+	Expiration []Expiration
+}
+
+// UnmarshalJSON accepts Tradier's expirations however many there are: a bare object for one and null for
+// none, as well as an array.
+func (l *ExpirationList) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Expiration json.RawMessage `json:"expiration"`
+	}
+	if err := unmarshalObject(data, &raw); err != nil {
+		return fmt.Errorf("failed to unmarshal expirations: %s", err)
+	}
+	return unmarshalOneOrMany(raw.Expiration, &l.Expiration)
 }
 
 type Option struct {
-	Symbol           string      `json:"symbol"`
-	Description      string      `json:"description"`
-	Exch             string      `json:"exch"`
-	Type             string      `json:"type"`
-	Last             interface{} `json:"last"`
-	Change           interface{} `json:"change"`
-	Volume           int         `json:"volume"`
-	Open             interface{} `json:"open"`
-	High             interface{} `json:"high"`
-	Low              interface{} `json:"low"`
-	Close            interface{} `json:"close"`
-	Bid              float64     `json:"bid"`
-	Ask              float64     `json:"ask"`
-	Underlying       string      `json:"underlying"`
-	Strike           float64     `json:"strike"`
-	ChangePercentage interface{} `json:"change_percentage"`
-	AverageVolume    int         `json:"average_volume"`
-	LastVolume       int         `json:"last_volume"`
-	TradeDate        int         `json:"trade_date"`
-	Prevclose        interface{} `json:"prevclose"`
-	Week52High       float64     `json:"week_52_high"`
-	Week52Low        float64     `json:"week_52_low"`
-	Bidsize          int         `json:"bidsize"`
-	Bidexch          string      `json:"bidexch"`
-	BidDate          int64       `json:"bid_date"`
-	Asksize          int         `json:"asksize"`
-	Askexch          string      `json:"askexch"`
-	AskDate          int64       `json:"ask_date"`
-	OpenInterest     int         `json:"open_interest"`
-	ContractSize     int         `json:"contract_size"`
-	ExpirationDate   string      `json:"expiration_date"`
-	ExpirationType   string      `json:"expiration_type"`
-	OptionType       string      `json:"option_type"`
-	RootSymbol       string      `json:"root_symbol"`
+	Symbol           string    `json:"symbol"`
+	Description      string    `json:"description"`
+	Exch             string    `json:"exch"`
+	Type             string    `json:"type"`
+	Last             FlexFloat `json:"last"`
+	Change           FlexFloat `json:"change"`
+	Volume           int       `json:"volume"`
+	Open             FlexFloat `json:"open"`
+	High             FlexFloat `json:"high"`
+	Low              FlexFloat `json:"low"`
+	Close            FlexFloat `json:"close"`
+	Bid              float64   `json:"bid"`
+	Ask              float64   `json:"ask"`
+	Underlying       string    `json:"underlying"`
+	Strike           float64   `json:"strike"`
+	ChangePercentage FlexFloat `json:"change_percentage"`
+	AverageVolume    int       `json:"average_volume"`
+	LastVolume       int       `json:"last_volume"`
+	TradeDate        int       `json:"trade_date"`
+	Prevclose        FlexFloat `json:"prevclose"`
+	Week52High       float64   `json:"week_52_high"`
+	Week52Low        float64   `json:"week_52_low"`
+	Bidsize          int       `json:"bidsize"`
+	Bidexch          string    `json:"bidexch"`
+	BidDate          int64     `json:"bid_date"`
+	Asksize          int       `json:"asksize"`
+	Askexch          string    `json:"askexch"`
+	AskDate          int64     `json:"ask_date"`
+	OpenInterest     int       `json:"open_interest"`
+	ContractSize     int       `json:"contract_size"`
+	ExpirationDate   string    `json:"expiration_date"`
+	ExpirationType   string    `json:"expiration_type"`
+	OptionType       string    `json:"option_type"`
+	RootSymbol       string    `json:"root_symbol"`
 	Greeks           struct {
 		Delta     float64 `json:"delta"`
 		Gamma     float64 `json:"gamma"`
@@ -76,6 +133,36 @@ type Option struct {
 	} `json:"greeks"`
 }
 
+// FlexFloat is a price Tradier may send as a number, a numeric string or null. Anything that is not a number
+// decodes as zero, as does a price that has not traded.
+type FlexFloat float64
+
+func (f *FlexFloat) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+	value, err := strconv.ParseFloat(string(data), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		*f = 0
+		return nil
+	}
+	*f = FlexFloat(value)
+	return nil
+}
+
+// Price is the quote's last trade, or its mid price when it has not traded.
+func (o Option) Price() float64 {
+	if o.Last > 0 {
+		return float64(o.Last)
+	}
+	return (o.Bid + o.Ask) / 2
+}
+
 type OptionChain struct {
 	Options        OptionList `json:"options"`
 	ExpirationDate string     `json:"expiration_date"`
@@ -85,6 +172,56 @@ type OptionList struct {
 	Option []Option
 }
 
+// UnmarshalJSON accepts Tradier's options however many there are: a bare object when an expiration has a
+// single option and null for an empty chain, as well as an array.
+func (l *OptionList) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Option json.RawMessage `json:"option"`
+	}
+	if err := unmarshalObject(data, &raw); err != nil {
+		return fmt.Errorf("failed to unmarshal options: %s", err)
+	}
+	return unmarshalOneOrMany(raw.Option, &l.Option)
+}
+
+type MarketQuotes struct {
+	Quotes struct {
+		Quote json.RawMessage `json:"quote"`
+	} `json:"quotes"`
+}
+
+// unmarshalObject unmarshals a JSON object into v, leaving v alone when data is null or the string "null",
+// which Tradier sends for an empty list.
+func unmarshalObject(data []byte, v interface{}) error {
+	if isNull(data) {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// unmarshalOneOrMany unmarshals a JSON array, a single object or null into list, which is empty for null.
+func unmarshalOneOrMany[T any](data []byte, list *[]T) error {
+	*list = nil
+	if isNull(data) {
+		return nil
+	}
+	data = bytes.TrimSpace(data)
+	if data[0] == '{' {
+		var item T
+		if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+		*list = []T{item}
+		return nil
+	}
+	return json.Unmarshal(data, list)
+}
+
+func isNull(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) == 0 || bytes.Equal(data, []byte("null")) || bytes.Equal(data, []byte(`"null"`))
+}
+
 type PriceStatistics []struct {
 	Request string `json:"request"`
 	Type    string `json:"type"`
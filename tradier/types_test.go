@@ -0,0 +1,125 @@
+package tradier_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bcdannyboy/stocd/tradier"
+	"github.com/bcdannyboy/stocd/tradiertest"
+)
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := tradiertest.Fixture(name)
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %s", name, err)
+	}
+	return body
+}
+
+func TestOptionListOneOrMany(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    []byte
+		symbols []string
+	}{
+		{"array", nil, []string{"SPY240621P00530000", "SPY240621P00535000", "SPY240621P00540000", "SPY240621C00545000", "SPY240621C00550000"}},
+		{"single object", nil, []string{"SPY240621P00540000"}},
+		{"null options", nil, nil},
+		{"null option", []byte(`{"options": {"option": null}}`), nil},
+		{"string null", []byte(`{"options": "null"}`), nil},
+	}
+	tests[0].body = fixture(t, "chain.json")
+	tests[1].body = fixture(t, "chain_single.json")
+	tests[2].body = fixture(t, "chain_empty.json")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chain tradier.OptionChain
+			if err := json.Unmarshal(tt.body, &chain); err != nil {
+				t.Fatalf("failed to unmarshal: %s", err)
+			}
+			if len(chain.Options.Option) != len(tt.symbols) {
+				t.Fatalf("got %d options, want %d", len(chain.Options.Option), len(tt.symbols))
+			}
+			for i, option := range chain.Options.Option {
+				if option.Symbol != tt.symbols[i] {
+					t.Errorf("option %d is %s, want %s", i, option.Symbol, tt.symbols[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSingleOptionFields(t *testing.T) {
+	var chain tradier.OptionChain
+	if err := json.Unmarshal(fixture(t, "chain_single.json"), &chain); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	option := chain.Options.Option[0]
+	if option.Strike != 540 || option.Bid != 2.04 || option.Ask != 2.06 || option.Last != 2.05 || option.OptionType != "put" {
+		t.Errorf("decoded %+v", option)
+	}
+	if option.Greeks.MidIv <= 0 || option.BidDate == 0 {
+		t.Errorf("greeks or quote times missing: %+v", option.Greeks)
+	}
+}
+
+func TestExpirationListOneOrMany(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  []byte
+		dates []string
+	}{
+		{"array", fixture(t, "expirations.json"), []string{"2024-06-21", "2024-06-28"}},
+		{"single object", fixture(t, "expirations_single.json"), []string{"2024-06-21"}},
+		{"null", []byte(`{"expirations": null}`), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expirations tradier.OptionExpirations
+			if err := json.Unmarshal(tt.body, &expirations); err != nil {
+				t.Fatalf("failed to unmarshal: %s", err)
+			}
+			got := expirations.Expirations.Expiration
+			if len(got) != len(tt.dates) {
+				t.Fatalf("got %d expirations, want %d", len(got), len(tt.dates))
+			}
+			for i, expiration := range got {
+				if expiration.Date != tt.dates[i] {
+					t.Errorf("expiration %d is %s, want %s", i, expiration.Date, tt.dates[i])
+				}
+			}
+			if len(got) > 0 && len(got[0].Strikes.Strike) != 5 {
+				t.Errorf("got %d strikes, want 5", len(got[0].Strikes.Strike))
+			}
+		})
+	}
+}
+
+func TestFlexFloat(t *testing.T) {
+	tests := []struct {
+		json string
+		want float64
+	}{
+		{`1.25`, 1.25},
+		{`"1.25"`, 1.25},
+		{`-0.5`, -0.5},
+		{`null`, 0},
+		{`""`, 0},
+		{`"NaN"`, 0},
+		{`"n/a"`, 0},
+	}
+	for _, tt := range tests {
+		var quote struct {
+			Last tradier.FlexFloat `json:"last"`
+		}
+		if err := json.Unmarshal([]byte(`{"last": `+tt.json+`}`), &quote); err != nil {
+			t.Errorf("%s: failed to unmarshal: %s", tt.json, err)
+			continue
+		}
+		if float64(quote.Last) != tt.want {
+			t.Errorf("%s decoded as %v, want %v", tt.json, quote.Last, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package tradiertest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/bcdannyboy/stocd/market"
+	"github.com/bcdannyboy/stocd/models"
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// Fixture returns the named file of responses recorded in the shape Tradier sends them: history.json,
+// expirations.json, chain.json and quotes.json, and the shapes of its quirks: quote.json, a single symbol's
+// quote, chain_single.json, an expiration with one option, and expirations_single.json, a symbol with one
+// expiration, each sent as an object rather than an array, and chain_empty.json, whose options are null.
+func Fixture(name string) ([]byte, error) {
+	body, err := fixtures.ReadFile("fixtures/" + name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s: %s", name, err)
+	}
+	return body, nil
+}
+
+// History returns a markets/history response of daily bars closing at closes, one per weekday from start.
+// Each bar opens at the previous close and its high and low bracket the open and close by 0.5%.
+func History(closes []float64, start time.Time) []byte {
+	type day struct {
+		Date   string  `json:"date"`
+		Open   float64 `json:"open"`
+		High   float64 `json:"high"`
+		Low    float64 `json:"low"`
+		Close  float64 `json:"close"`
+		Volume int     `json:"volume"`
+	}
+	days := make([]day, 0, len(closes))
+	date := start
+	for i, currClose := range closes {
+		for date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+			date = date.AddDate(0, 0, 1)
+		}
+		open := currClose
+		if i > 0 {
+			open = closes[i-1]
+		}
+		days = append(days, day{
+			Date:   date.Format(market.DateLayout),
+			Open:   open,
+			High:   math.Max(open, currClose) * 1.005,
+			Low:    math.Min(open, currClose) * 0.995,
+			Close:  currClose,
+			Volume: 1000000,
+		})
+		date = date.AddDate(0, 0, 1)
+	}
+	return mustMarshal(map[string]interface{}{"history": map[string]interface{}{"day": days}})
+}
+
+// Expirations returns a markets/options/expirations response listing the dates as standard expirations.
+func Expirations(dates ...string) []byte {
+	type expiration struct {
+		Date           string `json:"date"`
+		ContractSize   int    `json:"contract_size"`
+		ExpirationType string `json:"expiration_type"`
+	}
+	list := make([]expiration, len(dates))
+	for i, date := range dates {
+		list[i] = expiration{Date: date, ContractSize: 100, ExpirationType: "standard"}
+	}
+	return mustMarshal(map[string]interface{}{"expirations": map[string]interface{}{"expiration": list}})
+}
+
+// Chain returns a markets/options/chains response of the options.
+func Chain(options []tradier.Option) []byte {
+	return mustMarshal(map[string]interface{}{"options": map[string]interface{}{"option": options}})
+}
+
+// Quotes returns a markets/quotes response of the quotes, as an array even for a single quote.
+func Quotes(quotes ...tradier.Option) []byte {
+	return mustMarshal(map[string]interface{}{"quotes": map[string]interface{}{"quote": quotes}})
+}
+
+// SyntheticChain returns puts and calls on underlying at the strikes for the expiration, priced by
+// Black-Scholes at the flat volatility with a zero rate. Quotes are 5% wide around the model price, at least
+// a cent, and carry the delta and implied volatility in their greeks.
+func SyntheticChain(underlying string, price, vol float64, expiration string, strikes []float64) []tradier.Option {
+	days, err := market.DaysToExpiration(expiration, market.Now())
+	if err != nil {
+		return nil
+	}
+	tau := math.Max(float64(days), 1) / 365
+
+	var options []tradier.Option
+	for _, optionType := range []string{"put", "call"} {
+		for _, strike := range strikes {
+			isCall := optionType == "call"
+			mid := models.BlackScholesPrice(price, strike, tau, 0, vol, isCall)
+			half := math.Max(mid*0.025, 0.005)
+			d1 := (math.Log(price/strike) + vol*vol/2*tau) / (vol * math.Sqrt(tau))
+			delta := 0.5 * math.Erfc(-d1/math.Sqrt2)
+			if !isCall {
+				delta -= 1
+			}
+
+			option := tradier.Option{
+				Symbol:         optionSymbol(underlying, expiration, optionType, strike),
+				Description:    fmt.Sprintf("%s %s %.2f %s", underlying, expiration, strike, strings.ToUpper(optionType[:1])+optionType[1:]),
+				Type:           "option",
+				Bid:            math.Max(math.Round((mid-half)*100)/100, 0),
+				Ask:            math.Round((mid+half)*100) / 100,
+				Underlying:     underlying,
+				Strike:         strike,
+				Volume:         100,
+				OpenInterest:   1000,
+				ContractSize:   100,
+				ExpirationDate: expiration,
+				ExpirationType: "standard",
+				OptionType:     optionType,
+				RootSymbol:     underlying,
+			}
+			option.Greeks.Delta = delta
+			option.Greeks.BidIv, option.Greeks.MidIv, option.Greeks.AskIv, option.Greeks.SmvVol = vol, vol, vol, vol
+			options = append(options, option)
+		}
+	}
+	return options
+}
+
+// SyntheticMarket generates a market needing no network: two years of geometric Brownian motion daily closes
+// of underlying from $100 at the flat volatility, and SyntheticChain priced chains of the next expirations
+// weekly Fridays from two weeks out, with strikes every dollar from 70% to 130% of the last close.
+func SyntheticMarket(underlying string, vol float64, expirations int) (map[string]*tradier.OptionChain, float64, tradier.QuoteHistory) {
+	rng := rand.New(rand.NewSource(1))
+	closes := make([]float64, 504)
+	price := 100.0
+	dailyVol := vol / math.Sqrt(252)
+	for i := range closes {
+		price *= math.Exp(-dailyVol*dailyVol/2 + dailyVol*rng.NormFloat64())
+		closes[i] = price
+	}
+	var history tradier.QuoteHistory
+	json.Unmarshal(History(closes, market.Now().AddDate(-2, 0, 0)), &history)
+
+	var strikes []float64
+	for strike := math.Round(price * 0.7); strike <= price*1.3; strike++ {
+		strikes = append(strikes, strike)
+	}
+	chain := make(map[string]*tradier.OptionChain)
+	expiration := market.Now().AddDate(0, 0, 14)
+	for expiration.Weekday() != time.Friday {
+		expiration = expiration.AddDate(0, 0, 1)
+	}
+	for i := 0; i < expirations; i++ {
+		date := expiration.AddDate(0, 0, 7*i).Format(market.DateLayout)
+		chain[date] = &tradier.OptionChain{ExpirationDate: date}
+		chain[date].Options.Option = SyntheticChain(underlying, price, vol, date, strikes)
+	}
+	return chain, price, history
+}
+
+// optionSymbol is the OCC symbol of the contract, e.g. SPY240621P00500000.
+func optionSymbol(underlying, expiration, optionType string, strike float64) string {
+	date, err := time.Parse(market.DateLayout, expiration)
+	if err != nil {
+		return underlying
+	}
+	return fmt.Sprintf("%s%s%s%08d", underlying, date.Format("060102"), strings.ToUpper(optionType[:1]), int(math.Round(strike*1000)))
+}
+
+func mustMarshal(v interface{}) []byte {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("failed to marshal fixture: %s", err))
+	}
+	return body
+}
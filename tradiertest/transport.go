@@ -0,0 +1,119 @@
+// Package tradiertest serves canned Tradier API responses so code that fetches market data through the
+// tradier package can run without the network or a token.
+//
+//	mock := tradiertest.NewTransport()
+//	mock.Handle("/v1/markets/history", nil, tradiertest.History(closes, start))
+//	restore := mock.Install()
+//	defer restore()
+package tradiertest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/bcdannyboy/stocd/tradier"
+)
+
+// BaseURL is the base URL Install points the tradier package at; requests never leave the process.
+const BaseURL = "http://tradier.test"
+
+type route struct {
+	path   string
+	params map[string]string
+	status int
+	body   []byte
+}
+
+// Transport is an http.RoundTripper that answers requests from the responses registered with Handle and
+// records every request it receives. A request without a matching response gets a 404.
+type Transport struct {
+	mu       sync.Mutex
+	routes   []route
+	requests []*http.Request
+}
+
+// NewTransport returns a Transport without responses.
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// Handle responds with body and status 200 to requests for path whose query has the params. Responses
+// registered later take precedence, so a general response can be overridden for particular parameters.
+func (t *Transport) Handle(path string, params map[string]string, body []byte) {
+	t.HandleStatus(path, params, http.StatusOK, body)
+}
+
+// HandleStatus is Handle with the response's status, for exercising Tradier's error responses.
+func (t *Transport) HandleStatus(path string, params map[string]string, status int, body []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routes = append(t.routes, route{path: path, params: params, status: status, body: body})
+}
+
+// HandleFixture responds to requests for path with the params with the named file from the package's
+// fixtures, e.g. "chain.json".
+func (t *Transport) HandleFixture(path string, params map[string]string, name string) error {
+	body, err := Fixture(name)
+	if err != nil {
+		return err
+	}
+	t.Handle(path, params, body)
+	return nil
+}
+
+// Requests returns the requests received so far, oldest first.
+func (t *Transport) Requests() []*http.Request {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*http.Request(nil), t.requests...)
+}
+
+// RoundTrip answers the request from the registered responses.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests = append(t.requests, r)
+
+	query := r.URL.Query()
+	for i := len(t.routes) - 1; i >= 0; i-- {
+		route := t.routes[i]
+		if route.path != r.URL.Path {
+			continue
+		}
+		matches := true
+		for key, value := range route.params {
+			if query.Get(key) != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return response(r, route.status, route.body), nil
+		}
+	}
+	return response(r, http.StatusNotFound, []byte(fmt.Sprintf("no response for %s", r.URL.RequestURI()))), nil
+}
+
+// Install points the tradier package at the transport and returns a function restoring its client.
+func (t *Transport) Install() (restore func()) {
+	previous := tradier.CurrentClient()
+	tradier.SetClient(tradier.Client{BaseURL: BaseURL, Transport: t})
+	return func() { tradier.SetClient(previous) }
+}
+
+func response(r *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(string(body))),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
@@ -0,0 +1,106 @@
+package watchlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store persists a list of symbols per Slack channel to a JSON file.
+type Store struct {
+	path  string
+	mu    sync.RWMutex
+	lists map[string][]string
+}
+
+// Open loads the watchlists at path, starting empty if the file does not exist yet.
+func Open(path string) (*Store, error) {
+	store := &Store{path: path, lists: make(map[string][]string)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watchlists: %s", err)
+	}
+
+	if err := json.Unmarshal(data, &store.lists); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal watchlists: %s", err)
+	}
+
+	return store, nil
+}
+
+// Add puts symbols on the channel's watchlist, returning those that were not already on it.
+func (s *Store) Add(channelID string, symbols ...string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var added []string
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		if !contains(s.lists[channelID], symbol) {
+			s.lists[channelID] = append(s.lists[channelID], symbol)
+			added = append(added, symbol)
+		}
+	}
+	sort.Strings(s.lists[channelID])
+
+	return added, s.save()
+}
+
+// Remove takes symbols off the channel's watchlist, returning those that were on it.
+func (s *Store) Remove(channelID string, symbols ...string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed []string
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		list := s.lists[channelID]
+		for i := range list {
+			if list[i] == symbol {
+				s.lists[channelID] = append(list[:i], list[i+1:]...)
+				removed = append(removed, symbol)
+				break
+			}
+		}
+	}
+	if len(s.lists[channelID]) == 0 {
+		delete(s.lists, channelID)
+	}
+
+	return removed, s.save()
+}
+
+// List returns the channel's watchlist in alphabetical order.
+func (s *Store) List(channelID string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]string(nil), s.lists[channelID]...)
+}
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.lists, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal watchlists: %s", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write watchlists: %s", err)
+	}
+	return nil
+}
+
+func contains(list []string, symbol string) bool {
+	for _, s := range list {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}